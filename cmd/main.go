@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"nanoleaf-go/internal"
 	"os"
@@ -11,6 +12,37 @@ import (
 )
 
 func main() {
+	args, err := internal.SelectProfile(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(internal.ExitCode(err))
+	}
+
+	// Plain numbered menus for screen readers and dumb terminals
+	plain := len(args) == 1 && args[0] == "--no-tui"
+	if plain || (len(args) == 0 && os.Getenv("TERM") == "dumb") {
+		if err := internal.RunPlain(internal.NewDevice(), os.Stdin, os.Stdout); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err := internal.RunCommand(ctx, args)
+		stop()
+		if err != nil {
+			if internal.QuietMode() {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				fmt.Println("Error:", err)
+			}
+			os.Exit(internal.ExitCode(err))
+		}
+		return
+	}
+
 	// Set up graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -27,8 +59,13 @@ func main() {
 		program.Quit()
 	}()
 
-	if _, err := program.Run(); err != nil {
+	model, err := program.Run()
+	if err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
+	// Losing the session only means starting from the top next time
+	if final, ok := model.(internal.UI); ok {
+		final.SaveSession()
+	}
 }
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// action is a single textual device command such as "on", "brightness 80",
+// "brightness +10", "color teal", "temperature 2700", "effect Northern Lights", "preset 2" or
+// a delay like "2s"
+type action struct {
+	verb  string
+	arg   string
+	value int
+	// relative brightness changes by value rather than to it
+	relative bool
+	color    rgbColor
+	delay    time.Duration
+}
+
+func parseAction(s string) (action, error) {
+	s = strings.TrimSpace(s)
+	verb, arg, _ := strings.Cut(s, " ")
+	verb = strings.ToLower(verb)
+	arg = strings.TrimSpace(arg)
+
+	if delay, err := time.ParseDuration(verb); err == nil && arg == "" {
+		return action{verb: "wait", delay: delay}, nil
+	}
+
+	a := action{verb: verb, arg: arg}
+	switch verb {
+	case "on", "off":
+		if arg != "" {
+			return a, fmt.Errorf("%s takes no argument", verb)
+		}
+	case "brightness":
+		value, err := strconv.Atoi(arg)
+		a.relative = strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-")
+		if err != nil || value < -100 || value > 100 || (value < 0 && !a.relative) {
+			return a, fmt.Errorf("brightness must be a number (0-100), or a step like +10 or -10")
+		}
+		a.value = value
+	case "color":
+		color, err := parseColor(arg)
+		if err != nil {
+			return a, err
+		}
+		a.color = color
+	case "temperature", "ct":
+		kelvin, err := parseColorTemp(arg)
+		if err != nil {
+			return a, err
+		}
+		a.verb = "temperature"
+		a.value = kelvin
+	case "effect":
+		if arg == "" {
+			return a, fmt.Errorf("effect needs a name")
+		}
+	case "preset":
+		if len(arg) != 1 || arg[0] < '1' || arg[0] > '9' {
+			return a, fmt.Errorf("preset must be a number (1-9)")
+		}
+	case "wait", "sleep":
+		delay, err := time.ParseDuration(arg)
+		if err != nil {
+			return a, fmt.Errorf("invalid delay %q", arg)
+		}
+		a.verb = "wait"
+		a.delay = delay
+	case "":
+		return a, fmt.Errorf("empty action")
+	default:
+		return a, fmt.Errorf("unknown action %q", verb)
+	}
+	return a, nil
+}
+
+func (a action) run(ctx context.Context, device *Device) error {
+	switch a.verb {
+	case "on":
+		return device.TurnOn(ctx)
+	case "off":
+		return device.TurnOff(ctx)
+	case "brightness":
+		if a.relative {
+			return device.StepBrightness(ctx, a.value)
+		}
+		return device.SetBrightness(ctx, a.value)
+	case "color":
+		hue, sat, _ := a.color.hsb()
+		return device.SetColor(ctx, hue, sat)
+	case "temperature":
+		return device.SetColorTemp(ctx, a.value)
+	case "effect":
+		return device.SelectEffect(ctx, a.arg)
+	case "preset":
+		preset, ok := device.GetConfig().Presets[a.arg]
+		if !ok {
+			return fmt.Errorf("preset %s is not configured", a.arg)
+		}
+		return preset.apply(ctx, device)
+	case "wait":
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.delay):
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown action %q", a.verb)
+}
+
+func (a action) String() string {
+	if a.verb == "wait" {
+		return a.delay.String()
+	}
+	if a.arg == "" {
+		return a.verb
+	}
+	return a.verb + " " + a.arg
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		input string
+		verb  string
+		arg   string
+		value int
+		delay time.Duration
+	}{
+		{"on", "on", "", 0, 0},
+		{" OFF ", "off", "", 0, 0},
+		{"brightness 80", "brightness", "80", 80, 0},
+		{"brightness -10", "brightness", "-10", -10, 0},
+		{"effect Northern Lights", "effect", "Northern Lights", 0, 0},
+		{"color teal", "color", "teal", 0, 0},
+		{"temperature 2700", "temperature", "2700", 2700, 0},
+		{"ct 4000K", "temperature", "4000K", 4000, 0},
+		{"preset 3", "preset", "3", 0, 0},
+		{"2s", "wait", "", 0, 2 * time.Second},
+		{"wait 500ms", "wait", "500ms", 0, 500 * time.Millisecond},
+		{"sleep 1m", "wait", "1m", 0, time.Minute},
+	}
+
+	for _, tt := range tests {
+		a, err := parseAction(tt.input)
+		if err != nil {
+			t.Errorf("parseAction(%q) should not fail: %v", tt.input, err)
+			continue
+		}
+		if a.verb != tt.verb || a.arg != tt.arg || a.value != tt.value || a.delay != tt.delay {
+			t.Errorf("parseAction(%q) = %+v", tt.input, a)
+		}
+	}
+}
+
+func TestParseActionInvalid(t *testing.T) {
+	for _, input := range []string{"", "dance", "on now", "brightness", "brightness 101", "brightness +101", "color tael", "temperature warm", "effect", "preset 0", "wait soon"} {
+		if _, err := parseAction(input); err == nil {
+			t.Errorf("parseAction(%q) should fail", input)
+		}
+	}
+}
+
+func TestActionString(t *testing.T) {
+	a, _ := parseAction("2s")
+	if a.String() != "2s" {
+		t.Errorf("expected 2s, got %s", a)
+	}
+	a, _ = parseAction("effect Blaze")
+	if a.String() != "effect Blaze" {
+		t.Errorf("expected 'effect Blaze', got %s", a)
+	}
+}
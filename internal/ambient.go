@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// brightnessController nudges the panel brightness until the ambient light
+// is at target lux. Readings within band of the target change nothing, so
+// the panels, which the sensor also sees, do not keep chasing their own
+// light.
+type brightnessController struct {
+	target     float64
+	band       float64
+	min, max   int
+	brightness int
+}
+
+// maxBrightnessStep limits how far one reading moves the brightness
+const maxBrightnessStep = 15
+
+// next returns the brightness for a lux reading and whether it changed
+func (c *brightnessController) next(lux float64) (int, bool) {
+	diff := c.target - lux
+	if math.Abs(diff) <= c.band {
+		return c.brightness, false
+	}
+
+	// A room at half the target gets about 12 points more
+	step := int(math.Round(diff / c.target * 25))
+	if step == 0 {
+		step = int(math.Copysign(1, diff))
+	}
+	step = max(-maxBrightnessStep, min(maxBrightnessStep, step))
+
+	next := max(c.min, min(c.max, c.brightness+step))
+	if next == c.brightness {
+		return next, false
+	}
+	c.brightness = next
+	return next, true
+}
+
+// parseLux reads a lux value from a plain number or, with path, a JSON
+// document such as a Zigbee2MQTT message
+func parseLux(payload []byte, path string) (float64, error) {
+	text := strings.TrimSpace(string(payload))
+	if path != "" {
+		var data interface{}
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return 0, fmt.Errorf("failed to parse %q: %w", text, err)
+		}
+		value, err := extractJSONPath(data, path)
+		if err != nil {
+			return 0, err
+		}
+		encoded, _ := json.Marshal(value)
+		text = strings.Trim(string(encoded), `"`)
+	}
+	lux, err := strconv.ParseFloat(text, 64)
+	if err != nil || lux < 0 {
+		return 0, fmt.Errorf("invalid lux value %q", text)
+	}
+	return lux, nil
+}
+
+// luxReading is one value from a sensor, or the error reading it
+type luxReading struct {
+	lux float64
+	err error
+}
+
+// pollLux reads the sensor at url every interval until ctx is done
+func pollLux(ctx context.Context, httpClient *http.Client, url, path string, interval time.Duration) <-chan luxReading {
+	readings := make(chan luxReading)
+	go func() {
+		defer close(readings)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			var reading luxReading
+			value, err := fetchJSONValue(ctx, httpClient, url, ".")
+			if err == nil {
+				reading.lux, reading.err = parseLux([]byte(value), path)
+			} else {
+				reading.err = err
+			}
+			select {
+			case readings <- reading:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return readings
+}
+
+// subscribeLux reads every message on an MQTT topic through mosquitto_sub,
+// which prints one message per line
+func subscribeLux(ctx context.Context, broker, topic, path string) (<-chan luxReading, error) {
+	host, port, ok := strings.Cut(broker, ":")
+	if !ok {
+		port = "1883"
+	}
+	if _, err := exec.LookPath("mosquitto_sub"); err != nil {
+		return nil, fmt.Errorf("reading MQTT needs mosquitto_sub (from the mosquitto clients)")
+	}
+
+	cmd := exec.CommandContext(ctx, "mosquitto_sub", "-h", host, "-p", port, "-t", topic)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mosquitto_sub: %w", err)
+	}
+
+	readings := make(chan luxReading)
+	go func() {
+		defer close(readings)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lux, err := parseLux(scanner.Bytes(), path)
+			select {
+			case readings <- luxReading{lux: lux, err: err}:
+			case <-ctx.Done():
+			}
+		}
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			readings <- luxReading{err: fmt.Errorf("mosquitto_sub stopped: %w", err)}
+		}
+	}()
+	return readings, nil
+}
+
+func runAdaptiveBrightness(ctx context.Context, args []string) error {
+	fs := newFlagSet("adaptive-brightness")
+	url := fs.String("url", "", "HTTP sensor endpoint to poll")
+	broker := fs.String("mqtt", "", "MQTT broker host[:port] to subscribe to instead")
+	topic := fs.String("topic", "", "MQTT topic with the lux readings")
+	path := fs.String("jq", "", "path of the lux value in JSON readings, e.g. .illuminance_lux")
+	target := fs.Float64("target", 200, "ambient light level to keep, in lux")
+	band := fs.Float64("hysteresis", 0, "readings this close to the target change nothing (default 10% of --target)")
+	minBrightness := fs.Int("min", 5, "lowest brightness to set")
+	maxBrightness := fs.Int("max", 100, "highest brightness to set")
+	interval := fs.Duration("interval", 30*time.Second, "poll interval of --url")
+	ignoreQuiet := addQuietHoursFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if err := checkInterval(*interval); err != nil {
+		return err
+	}
+	if (*url == "") == (*broker == "") {
+		return invalidArgs(fmt.Errorf("either --url or --mqtt is required"))
+	}
+	if *broker != "" && *topic == "" {
+		return invalidArgs(fmt.Errorf("--topic is required with --mqtt"))
+	}
+	if *target <= 0 {
+		return invalidArgs(fmt.Errorf("--target must be above 0"))
+	}
+	if *minBrightness < 0 || *maxBrightness > 100 || *minBrightness > *maxBrightness {
+		return invalidArgs(fmt.Errorf("--min and --max must be between 0 and 100, min first"))
+	}
+	if *band <= 0 {
+		*band = *target * 0.1
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	quiet, err := newQuietGate(device.GetConfig(), *ignoreQuiet)
+	if err != nil {
+		return err
+	}
+	status, err := device.GetStatus(ctx)
+	if err != nil {
+		return err
+	}
+	controller := &brightnessController{
+		target:     *target,
+		band:       *band,
+		min:        *minBrightness,
+		max:        *maxBrightness,
+		brightness: max(*minBrightness, min(*maxBrightness, status.Brightness)),
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var readings <-chan luxReading
+	if *url != "" {
+		readings = pollLux(ctx, &http.Client{Timeout: 10 * time.Second}, *url, *path, *interval)
+		statusf("Keeping %.0f lux from %s every %s (ctrl+c to stop)\n", *target, *url, *interval)
+	} else {
+		if readings, err = subscribeLux(ctx, *broker, *topic, *path); err != nil {
+			return err
+		}
+		statusf("Keeping %.0f lux from %s on %s (ctrl+c to stop)\n", *target, *topic, *broker)
+	}
+	statusf("%s\n", quiet)
+	watchManualChanges(ctx, device)
+
+	errs, err := newErrorLog(device.GetConfig(), "Brightness update")
+	if err != nil {
+		return err
+	}
+	for reading := range readings {
+		err := reading.err
+		if err == nil {
+			err = quiet.settle(ctx, device)
+		}
+		// Stay under the quiet hours cap rather than fight it
+		controller.max = min(*maxBrightness, quiet.limit())
+		controller.min = min(*minBrightness, controller.max)
+		if err == nil && !quiet.held() {
+			brightness, ok := controller.next(reading.lux)
+			if ok {
+				err = device.SetBrightness(ctx, brightness)
+				if err == nil {
+					statusf("%.0f lux -> brightness %d%%\n", reading.lux, brightness)
+				}
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			errs.failed(err)
+		} else {
+			errs.succeeded()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package internal
+
+import "testing"
+
+func TestBrightnessController(t *testing.T) {
+	c := &brightnessController{target: 200, band: 20, min: 5, max: 100, brightness: 50}
+
+	if _, changed := c.next(190); changed {
+		t.Error("a reading within the band should change nothing")
+	}
+	if brightness, changed := c.next(100); !changed || brightness != 63 {
+		t.Errorf("expected a darker room to raise brightness to 63, got %d", brightness)
+	}
+	if brightness, _ := c.next(2000); brightness != 48 {
+		t.Errorf("expected steps of at most %d, got %d", maxBrightnessStep, brightness)
+	}
+	if brightness, _ := c.next(225); brightness != 45 {
+		t.Errorf("expected a small step just outside the band, got %d", brightness)
+	}
+
+	c.brightness = 98
+	c.next(0)
+	if brightness, changed := c.next(0); changed || brightness != 100 {
+		t.Errorf("expected brightness to stop at the maximum, got %d", brightness)
+	}
+}
+
+func TestParseLux(t *testing.T) {
+	tests := []struct {
+		payload string
+		path    string
+		want    float64
+		wantErr bool
+	}{
+		{"312.5\n", "", 312.5, false},
+		{`{"illuminance_lux":87,"battery":90}`, ".illuminance_lux", 87, false},
+		{`{"state":"42"}`, ".state", 42, false},
+		{`{"state":"unavailable"}`, ".state", 0, true},
+		{"-3", "", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLux([]byte(tt.payload), tt.path)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseLux(%q, %q) = %v, %v", tt.payload, tt.path, got, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var apiMethods = map[string]string{
+	"get":    http.MethodGet,
+	"put":    http.MethodPut,
+	"post":   http.MethodPost,
+	"delete": http.MethodDelete,
+}
+
+func runAPI(ctx context.Context, args []string) error {
+	fs := newFlagSet("api")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nanoleaf-go api <get|put|post|delete> <path> [json body]")
+		fmt.Fprintln(fs.Output(), "Example: nanoleaf-go api put state '{\"hue\":{\"value\":120}}'")
+	}
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if fs.NArg() < 2 || fs.NArg() > 3 {
+		fs.Usage()
+		return invalidArgs(fmt.Errorf("expected a method and a path"))
+	}
+
+	method, ok := apiMethods[strings.ToLower(fs.Arg(0))]
+	if !ok {
+		return invalidArgs(fmt.Errorf("unknown method %q", fs.Arg(0)))
+	}
+	var body []byte
+	if fs.NArg() == 3 {
+		body = []byte(fs.Arg(2))
+		if !json.Valid(body) {
+			return fmt.Errorf("request body is not valid JSON")
+		}
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	// There is no telling what an arbitrary path does, so a request that
+	// is not a get counts as a change to the device
+	if method != http.MethodGet {
+		ctx = asDeviceChange(ctx)
+	}
+	status, data, err := device.Request(ctx, method, fs.Arg(1), body)
+	if err != nil {
+		return err
+	}
+	if output := formatAPIResponse(data); output != "" {
+		fmt.Println(output)
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("request failed with status %d", status)
+	}
+	return nil
+}
+
+// formatAPIResponse indents JSON responses and returns anything else as-is
+func formatAPIResponse(data []byte) string {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return strings.TrimSpace(string(data))
+	}
+	return indented.String()
+}
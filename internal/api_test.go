@@ -0,0 +1,21 @@
+package internal
+
+import "testing"
+
+func TestFormatAPIResponse(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{`{"value":true}`, "{\n  \"value\": true\n}"},
+		{`"Northern Lights"`, `"Northern Lights"`},
+		{"not json\n", "not json"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := formatAPIResponse([]byte(tt.data)); got != tt.expected {
+			t.Errorf("formatAPIResponse(%q) = %q, expected %q", tt.data, got, tt.expected)
+		}
+	}
+}
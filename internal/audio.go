@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// audioSampleRate is the rate every backend is asked to capture at
+const audioSampleRate = 44100
+
+// AudioSource delivers mono samples in [-1, 1] from a capture backend
+type AudioSource interface {
+	// Read fills buf with samples and returns how many were read
+	Read(buf []float64) (int, error)
+	SampleRate() int
+	Close() error
+}
+
+// AudioConfig selects the capture backend for music mode. Backend is one of
+// audioBackends or "auto"; Device names the capture device where the
+// backend needs one; Command replaces the backend with any program that
+// writes signed 16-bit little endian mono PCM at 44.1kHz to stdout.
+type AudioConfig struct {
+	Backend string   `json:"backend,omitempty"`
+	Device  string   `json:"device,omitempty"`
+	Command []string `json:"command,omitempty"`
+}
+
+// audioBackends build the capture command of each backend. Capture runs in
+// a separate program, so no backend needs cgo or build tags and every one
+// is available on every platform as long as its tool is installed.
+var audioBackends = map[string]func(device string) []string{
+	// The monitor of the default sink is what is playing
+	"pulse": func(device string) []string {
+		if device == "" {
+			device = "@DEFAULT_MONITOR@"
+		}
+		return []string{"parec", "--raw", "--format=s16le", "--rate=44100", "--channels=1", "--device=" + device}
+	},
+	"pipewire": func(device string) []string {
+		args := []string{"pw-record", "--format=s16", "--rate=44100", "--channels=1"}
+		if device != "" {
+			args = append(args, "--target="+device)
+		} else {
+			args = append(args, "-P", "{ stream.capture.sink=true }")
+		}
+		return append(args, "-")
+	},
+	// macOS has no system loopback; Device selects an input such as a
+	// BlackHole loopback device by its AVFoundation index or name
+	"coreaudio": func(device string) []string {
+		if device == "" {
+			device = "0"
+		}
+		return []string{"ffmpeg", "-loglevel", "error", "-f", "avfoundation", "-i", ":" + device, "-f", "s16le", "-ac", "1", "-ar", "44100", "-"}
+	},
+	// Windows has no loopback ffmpeg can open directly, so this records a
+	// DirectShow input. "Stereo Mix", the default, is a loopback some sound
+	// drivers offer and many leave out or disable; a virtual cable such as
+	// VB-CABLE is the alternative.
+	"dshow": func(device string) []string {
+		if device == "" {
+			device = "Stereo Mix"
+		}
+		return []string{"ffmpeg", "-loglevel", "error", "-f", "dshow", "-i", "audio=" + device, "-f", "s16le", "-ac", "1", "-ar", "44100", "-"}
+	},
+}
+
+// audioBackendAliases are earlier names of backends, kept so configs
+// written with them still work
+var audioBackendAliases = map[string]string{
+	// dshow was called wasapi, which it never used
+	"wasapi": "dshow",
+}
+
+// maxCaptureStderr bounds how much of the capture program's error output
+// is kept for the error when it stops
+const maxCaptureStderr = 4096
+
+func audioBackendNames() []string {
+	names := make([]string, 0, len(audioBackends))
+	for name := range audioBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// audioCommand resolves the capture command for config on goos. lookPath
+// reports whether a program is installed and picks between PipeWire and
+// PulseAudio on Linux.
+func audioCommand(config AudioConfig, goos string, lookPath func(string) bool) ([]string, error) {
+	if len(config.Command) > 0 {
+		return config.Command, nil
+	}
+
+	backend := config.Backend
+	if backend == "" || backend == "auto" {
+		switch goos {
+		case "darwin":
+			backend = "coreaudio"
+		case "windows":
+			backend = "dshow"
+		default:
+			backend = "pulse"
+			if lookPath("pw-record") {
+				backend = "pipewire"
+			}
+		}
+	}
+
+	if alias, ok := audioBackendAliases[backend]; ok {
+		backend = alias
+	}
+	build, ok := audioBackends[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown audio backend %q (available: auto, %s)", backend, strings.Join(audioBackendNames(), ", "))
+	}
+	args := build(config.Device)
+	if !lookPath(args[0]) {
+		return nil, fmt.Errorf("the %s audio backend needs %s, install it or set audio.command", backend, args[0])
+	}
+	return args, nil
+}
+
+// openAudioSource starts capturing with the configured backend
+func openAudioSource(config AudioConfig) (AudioSource, error) {
+	args, err := audioCommand(config, runtime.GOOS, func(name string) bool {
+		_, err := exec.LookPath(name)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return startCommandSource(args)
+}
+
+// startCommandSource runs a capture program, keeping the end of what it
+// writes to stderr to explain why it stopped
+func startCommandSource(args []string) (*commandSource, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &tailBuffer{max: maxCaptureStderr}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+	s := &commandSource{pcmSource: newPCMSource(stdout, audioSampleRate), cmd: cmd, name: args[0], stderr: stderr, exited: make(chan struct{})}
+	go func() {
+		s.waitErr = cmd.Wait()
+		close(s.exited)
+	}()
+	return s, nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+	max  int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > b.max {
+		b.data = b.data[len(b.data)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.data))
+}
+
+// pcmSource decodes signed 16-bit little endian mono PCM
+type pcmSource struct {
+	r    *bufio.Reader
+	raw  io.Closer
+	rate int
+}
+
+func newPCMSource(r io.ReadCloser, rate int) *pcmSource {
+	return &pcmSource{r: bufio.NewReader(r), raw: r, rate: rate}
+}
+
+func (s *pcmSource) Read(buf []float64) (int, error) {
+	var sample [2]byte
+	for i := range buf {
+		if _, err := io.ReadFull(s.r, sample[:]); err != nil {
+			if i > 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+				return i, nil
+			}
+			return i, err
+		}
+		buf[i] = float64(int16(binary.LittleEndian.Uint16(sample[:]))) / math.MaxInt16
+	}
+	return len(buf), nil
+}
+
+func (s *pcmSource) SampleRate() int {
+	return s.rate
+}
+
+func (s *pcmSource) Close() error {
+	return s.raw.Close()
+}
+
+// commandSource is a pcmSource fed by a capture program
+type commandSource struct {
+	*pcmSource
+	cmd    *exec.Cmd
+	name   string
+	stderr *tailBuffer
+	// exited is closed once the program ended, with waitErr set
+	exited  chan struct{}
+	waitErr error
+}
+
+// Read fails with what the program wrote to stderr when it stopped, such
+// as ffmpeg naming a capture device that does not exist
+func (s *commandSource) Read(buf []float64) (int, error) {
+	n, err := s.pcmSource.Read(buf)
+	if err == nil {
+		return n, nil
+	}
+	// The program closes its output as it exits; give it a moment to
+	// finish writing its error
+	select {
+	case <-s.exited:
+	case <-time.After(2 * time.Second):
+	}
+	if message := s.stderr.String(); message != "" {
+		return n, fmt.Errorf("%s stopped: %s", s.name, message)
+	}
+	if err == io.EOF {
+		return n, fmt.Errorf("%s stopped without an error message", s.name)
+	}
+	return n, err
+}
+
+func (s *commandSource) Close() error {
+	s.pcmSource.Close()
+	s.cmd.Process.Kill()
+	<-s.exited
+	// The program was killed, so its exit status says nothing
+	var exitErr *exec.ExitError
+	if s.waitErr != nil && !errors.As(s.waitErr, &exitErr) {
+		return s.waitErr
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAudioCommand(t *testing.T) {
+	installed := func(names ...string) func(string) bool {
+		return func(name string) bool {
+			for _, n := range names {
+				if n == name {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	tests := []struct {
+		name     string
+		config   AudioConfig
+		goos     string
+		lookPath func(string) bool
+		program  string
+	}{
+		{"pipewire preferred on linux", AudioConfig{}, "linux", installed("pw-record", "parec"), "pw-record"},
+		{"pulse without pipewire", AudioConfig{Backend: "auto"}, "linux", installed("parec"), "parec"},
+		{"coreaudio on macOS", AudioConfig{}, "darwin", installed("ffmpeg"), "ffmpeg"},
+		{"explicit backend", AudioConfig{Backend: "pulse"}, "darwin", installed("parec"), "parec"},
+		{"custom command", AudioConfig{Backend: "pulse", Command: []string{"arecord", "-t", "raw"}}, "linux", installed(), "arecord"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := audioCommand(tt.config, tt.goos, tt.lookPath)
+			if err != nil {
+				t.Fatalf("audioCommand should not fail: %v", err)
+			}
+			if args[0] != tt.program {
+				t.Errorf("expected %s, got %v", tt.program, args)
+			}
+		})
+	}
+
+	args, _ := audioCommand(AudioConfig{Backend: "dshow", Device: "CABLE Output (VB-Audio Virtual Cable)"}, "windows", installed("ffmpeg"))
+	if !reflect.DeepEqual(args[3:7], []string{"-f", "dshow", "-i", "audio=CABLE Output (VB-Audio Virtual Cable)"}) {
+		t.Errorf("the device should be passed to ffmpeg, got %v", args)
+	}
+	// Configs from before the rename still work
+	if _, err := audioCommand(AudioConfig{Backend: "wasapi"}, "windows", installed("ffmpeg")); err != nil {
+		t.Errorf("wasapi should still select dshow: %v", err)
+	}
+
+	if _, err := audioCommand(AudioConfig{Backend: "alsa"}, "linux", installed()); err == nil {
+		t.Error("audioCommand should fail for an unknown backend")
+	}
+	_, err := audioCommand(AudioConfig{}, "windows", installed())
+	if err == nil || !strings.Contains(err.Error(), "ffmpeg") {
+		t.Errorf("a missing tool should be named, got %v", err)
+	}
+}
+
+func TestCommandSourceReportsStderr(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	source, err := startCommandSource([]string{"sh", "-c", "echo 'Could not find audio only device with name [Stereo Mix]' >&2; exit 1"})
+	if err != nil {
+		t.Fatalf("startCommandSource should not fail: %v", err)
+	}
+	defer source.Close()
+
+	_, err = source.Read(make([]float64, 64))
+	if err == nil || !strings.Contains(err.Error(), "Could not find audio only device") {
+		t.Errorf("expected the error output of the program, got %v", err)
+	}
+}
+
+func TestPCMSource(t *testing.T) {
+	data := []byte{0xff, 0x7f, 0x00, 0x00, 0x01, 0x80, 0x00}
+	source := newPCMSource(io.NopCloser(bytes.NewReader(data)), audioSampleRate)
+
+	buf := make([]float64, 4)
+	n, err := source.Read(buf)
+	if err != nil {
+		t.Fatalf("Read should not fail: %v", err)
+	}
+	// The trailing odd byte is not a whole sample
+	if n != 3 || buf[0] != 1 || buf[1] != 0 || buf[2] != -1 {
+		t.Errorf("unexpected samples %v (%d)", buf, n)
+	}
+	if _, err := source.Read(buf); err == nil {
+		t.Error("Read should fail at the end of the stream")
+	}
+}
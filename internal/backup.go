@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// backupVersion is bumped when the backup format changes incompatibly
+const backupVersion = 1
+
+// deviceBackup is everything needed to set a device up again after a
+// factory reset. Effects holds every installed definition; the effects that
+// come with the device are skipped on restore since they are back already.
+type deviceBackup struct {
+	Version  int       `json:"version"`
+	Created  time.Time `json:"created"`
+	Model    string    `json:"model"`
+	Firmware string    `json:"firmware"`
+	// Name is the name given to the device here, see rename
+	Name              string                   `json:"name,omitempty"`
+	GlobalOrientation int                      `json:"globalOrientation"`
+	LayoutTransform   *LayoutTransform         `json:"layoutTransform,omitempty"`
+	Effects           []map[string]interface{} `json:"effects"`
+}
+
+func (c *NanoleafClient) getGlobalOrientation(ctx context.Context, ip, token string) (int, error) {
+	ctx = withAuthToken(ctx, token)
+	var result struct {
+		Value int `json:"value"`
+	}
+	err := c.getJSON(ctx, c.buildURL(ip, "api/v1/panelLayout/globalOrientation"), &result)
+	return result.Value, err
+}
+
+func (c *NanoleafClient) setGlobalOrientation(ctx context.Context, ip, token string, degrees int) error {
+	ctx = withAuthToken(ctx, token)
+	url := c.buildURL(ip, "api/v1/panelLayout")
+
+	payload := map[string]interface{}{
+		"globalOrientation": map[string]int{"value": degrees},
+	}
+
+	return c.sendStateUpdate(ctx, url, payload)
+}
+
+func createBackup(ctx context.Context, device *Device) (deviceBackup, error) {
+	ip, token := device.GetConfig().IP, device.GetConfig().Token
+	backup := deviceBackup{
+		Version:         backupVersion,
+		Created:         time.Now().UTC().Truncate(time.Second),
+		Name:            device.GetConfig().Devices[ip].Name,
+		LayoutTransform: device.GetConfig().LayoutTransform,
+	}
+
+	caps, err := device.GetCapabilities(ctx)
+	if err != nil {
+		return backup, err
+	}
+	backup.Model, backup.Firmware = caps.Model, caps.Firmware
+
+	if backup.GlobalOrientation, err = device.client.getGlobalOrientation(ctx, ip, token); err != nil {
+		return backup, err
+	}
+	if backup.Effects, err = device.ListEffects(ctx); err != nil {
+		return backup, err
+	}
+	return backup, nil
+}
+
+// restoreResult tells what a restore changed
+type restoreResult struct {
+	Installed []string
+	Skipped   []string
+}
+
+// restoreBackup installs the effects of backup with installer, and restores
+// the orientation and name. Unless overwrite is set, effects the device
+// already has are skipped.
+func restoreBackup(ctx context.Context, device *Device, backup deviceBackup, installer *effectInstaller, overwrite bool) (restoreResult, error) {
+	var result restoreResult
+	if backup.Version != backupVersion {
+		return result, fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+
+	if !overwrite {
+		if err := installer.skipInstalled(ctx, device); err != nil {
+			return result, err
+		}
+	}
+	var err error
+	if result.Installed, result.Skipped, err = installer.install(ctx, device, backup.Effects); err != nil {
+		return result, err
+	}
+
+	device.warnFirmware(ctx, featureOrientation)
+	err = device.client.setGlobalOrientation(ctx, device.GetConfig().IP, device.GetConfig().Token, backup.GlobalOrientation)
+	if err := device.logAction(fmt.Sprintf("orientation %d", backup.GlobalOrientation), err); err != nil {
+		return result, err
+	}
+	if backup.LayoutTransform != nil {
+		if err := device.SetLayoutTransform(*backup.LayoutTransform); err != nil {
+			return result, err
+		}
+	}
+	if backup.Name != "" {
+		if err := device.Rename(device.GetConfig().IP, backup.Name); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func runBackup(ctx context.Context, args []string) error {
+	fs := newFlagSet("backup")
+	out := fs.String("out", "", "backup file (default nanoleaf-backup-DATE.json)")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	backup, err := createBackup(ctx, device)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		*out = fmt.Sprintf("nanoleaf-backup-%s.json", backup.Created.Format("2006-01-02"))
+	}
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		return err
+	}
+	statusf("Saved %d effect(s) and the orientation of %s to %s\n", len(backup.Effects), device.GetDeviceName(), *out)
+	return nil
+}
+
+func runRestore(ctx context.Context, args []string) error {
+	fs := newFlagSet("restore")
+	overwrite := fs.Bool("overwrite", false, "reinstall effects the device already has")
+	delay := fs.Duration("delay", defaultInstallDelay, "pause between effect installs")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if fs.NArg() != 1 {
+		return usageError("restore [--overwrite] [--delay 500ms] FILE")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var backup deviceBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", fs.Arg(0), err)
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	if caps, err := device.GetCapabilities(ctx); err == nil && backup.Model != "" && caps.Model != backup.Model {
+		fmt.Fprintf(os.Stderr, "Warning: the backup is of a %s, this device is a %s\n", backup.Model, caps.Model)
+	}
+
+	installer := newEffectInstaller(*delay, defaultInstallRetries)
+	installer.progress = func(done, total int, name string) {
+		statusf("[%d/%d] %s\n", done+1, total, name)
+	}
+	result, err := restoreBackup(ctx, device, backup, installer, *overwrite)
+	if len(result.Installed) > 0 {
+		statusf("Installed %d effect(s)\n", len(result.Installed))
+	}
+	if err != nil {
+		var installErr *InstallError
+		if errors.As(err, &installErr) && !*overwrite {
+			return fmt.Errorf("%w (run restore again to continue)", err)
+		}
+		return err
+	}
+	if len(result.Skipped) > 0 {
+		statusf("Skipped %d effect(s) the device already has (--overwrite reinstalls them)\n", len(result.Skipped))
+	}
+	statusf("Restored %s\n", device.GetDeviceName())
+	return nil
+}
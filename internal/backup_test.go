@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	effects := `{"animations":[{"animName":"Northern Lights","animType":"flow"},{"animName":"Blaze","animType":"fade"}]}`
+	var added []string
+	orientation := -1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/test-token":
+			w.Write([]byte(`{"model":"NL42","firmwareVersion":"9.2.4"}`))
+		case "GET /api/v1/test-token/panelLayout/globalOrientation":
+			w.Write([]byte(`{"value":120,"max":360,"min":0}`))
+		case "PUT /api/v1/test-token/panelLayout":
+			var payload map[string]map[string]int
+			json.NewDecoder(r.Body).Decode(&payload)
+			orientation = payload["globalOrientation"]["value"]
+			w.WriteHeader(http.StatusNoContent)
+		case "PUT /api/v1/test-token/effects":
+			var payload map[string]map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			switch payload["write"]["command"] {
+			case "requestAll":
+				w.Write([]byte(effects))
+			case "add":
+				added = append(added, payload["write"]["animName"].(string))
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.config.Devices = map[string]PairedDevice{server.URL: {Token: "test-token", Name: "Hallway"}}
+	ctx := context.Background()
+
+	backup, err := createBackup(ctx, device)
+	if err != nil {
+		t.Fatalf("createBackup should not fail: %v", err)
+	}
+	if len(backup.Effects) != 2 || backup.GlobalOrientation != 120 || backup.Name != "Hallway" {
+		t.Fatalf("unexpected backup %+v", backup)
+	}
+	if backup.Model != "Shapes" || backup.Firmware != "9.2.4" {
+		t.Errorf("expected the model and firmware to be saved, got %q %q", backup.Model, backup.Firmware)
+	}
+
+	// after a factory reset only Blaze is left and the name is gone
+	effects = `{"animations":[{"animName":"Blaze","animType":"fade"}]}`
+	device.config.Devices = nil
+
+	result, err := restoreBackup(ctx, device, backup, newEffectInstaller(0, 0), false)
+	if err != nil {
+		t.Fatalf("restoreBackup should not fail: %v", err)
+	}
+	if len(added) != 1 || added[0] != "Northern Lights" {
+		t.Errorf("expected only Northern Lights to be installed, got %v", added)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0] != "Blaze" {
+		t.Errorf("expected Blaze to be skipped, got %v", result.Skipped)
+	}
+	if orientation != 120 {
+		t.Errorf("expected the orientation to be restored, got %d", orientation)
+	}
+	if name := device.GetDeviceName(); name != "Hallway" {
+		t.Errorf("expected the name to be restored, got %q", name)
+	}
+
+	added = nil
+	if _, err := restoreBackup(ctx, device, backup, newEffectInstaller(0, 0), true); err != nil {
+		t.Fatalf("restoreBackup should not fail: %v", err)
+	}
+	if len(added) != 2 {
+		t.Errorf("expected --overwrite to reinstall every effect, got %v", added)
+	}
+
+	backup.Version = 99
+	if _, err := restoreBackup(ctx, device, backup, newEffectInstaller(0, 0), false); err == nil {
+		t.Error("expected an unknown backup version to be rejected")
+	}
+}
@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CalendarSource is an iCalendar feed for the calendar command. While one
+// of its events is under way the panels show Actions; once none is, After
+// runs, or the panels get back what they showed before without it. Match
+// keeps only events whose summary contains it, ignoring case.
+type CalendarSource struct {
+	Name    string   `json:"name,omitempty"`
+	URL     string   `json:"url"`
+	Match   string   `json:"match,omitempty"`
+	Actions []string `json:"actions"`
+	After   []string `json:"after,omitempty"`
+}
+
+type calendarSource struct {
+	CalendarSource
+	actions []action
+	after   []action
+}
+
+// label names the calendar in messages, by its name or else its position
+func (s calendarSource) label(i int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("calendar %d", i+1)
+}
+
+func parseCalendarSources(sources []CalendarSource) ([]calendarSource, error) {
+	var parsed []calendarSource
+	for i, source := range sources {
+		if source.URL == "" {
+			return nil, fmt.Errorf("calendars[%d]: url is required", i)
+		}
+		actions, err := parseMacro(source.Actions)
+		if err != nil {
+			return nil, fmt.Errorf("calendars[%d].actions: %w", i, err)
+		}
+		var after []action
+		if len(source.After) > 0 {
+			if after, err = parseMacro(source.After); err != nil {
+				return nil, fmt.Errorf("calendars[%d].after: %w", i, err)
+			}
+		}
+		parsed = append(parsed, calendarSource{CalendarSource: source, actions: actions, after: after})
+	}
+	return parsed, nil
+}
+
+// fetchCalendar downloads a feed; webcal:// links are fetched over https
+func fetchCalendar(ctx context.Context, httpClient *http.Client, url string) (string, error) {
+	if rest, ok := strings.CutPrefix(url, "webcal://"); ok {
+		url = "https://" + rest
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("calendar request failed with status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	return string(data), err
+}
+
+// calendarFollower lights the panels for the events of the calendars
+type calendarFollower struct {
+	device  *Device
+	quiet   *quietGate
+	sources []calendarSource
+	events  [][]calendarEvent
+	// current is the calendar whose event the panels show, -1 for none
+	current int
+	// before is what the panels showed before the first event, restored
+	// after the last unless its calendar has after actions
+	before *streamExit
+}
+
+// active returns the first calendar, in config order, with an event under
+// way at now, and that event, or -1
+func (f *calendarFollower) active(now time.Time) (int, string) {
+	for i, source := range f.sources {
+		if i >= len(f.events) {
+			break
+		}
+		for _, event := range f.events[i] {
+			if source.Match != "" && !strings.Contains(strings.ToLower(event.Summary), strings.ToLower(source.Match)) {
+				continue
+			}
+			if _, ok := event.occurrenceAt(now); ok {
+				return i, event.Summary
+			}
+		}
+	}
+	return -1, ""
+}
+
+// step applies the calendars at now and returns what changed, if anything.
+// A change the gate holds is tried again on the next step. When the last
+// event ends while a manual change or a higher priority holds the device,
+// the panels are left as they are rather than restored over it.
+func (f *calendarFollower) step(ctx context.Context, now time.Time) (string, error) {
+	next, summary := f.active(now)
+	if next == f.current {
+		return "", nil
+	}
+	if f.quiet.held() {
+		if next < 0 {
+			f.current, f.before = -1, nil
+			return "Events over, leaving the panels as they are", nil
+		}
+		return "", nil
+	}
+
+	if next < 0 {
+		source := f.sources[f.current]
+		var err error
+		if len(source.after) > 0 {
+			err = runActions(ctx, f.device, source.after)
+		} else if f.before != nil {
+			restoreCtx, cancel := f.device.createContext()
+			err = f.before.restore(restoreCtx)
+			cancel()
+		}
+		if err != nil {
+			return "", err
+		}
+		f.current, f.before = -1, nil
+		return "Events over, the panels are back", nil
+	}
+
+	if f.current < 0 {
+		prepareCtx, cancel := f.device.createContext()
+		before, err := prepareStreamExit(prepareCtx, f.device, streamExitRestore)
+		cancel()
+		if err != nil {
+			return "", err
+		}
+		before.after = "the calendar events"
+		f.before = before
+	}
+	if err := runActions(ctx, f.device, f.sources[next].actions); err != nil {
+		return "", err
+	}
+	f.current = next
+	return fmt.Sprintf("%s: %s", f.sources[next].label(next), summary), nil
+}
+
+// runActions runs parsed macro actions in order
+func runActions(ctx context.Context, device *Device, actions []action) error {
+	for _, a := range actions {
+		if err := a.run(ctx, device); err != nil {
+			return fmt.Errorf("failed at %q: %w", a, err)
+		}
+	}
+	return nil
+}
+
+func runCalendar(ctx context.Context, args []string) error {
+	fs := newFlagSet("calendar")
+	interval := fs.Duration("interval", time.Minute, "how often to check for events starting or ending")
+	refresh := fs.Duration("refresh", 5*time.Minute, "how often to download the calendars again")
+	ignoreQuiet := addQuietHoursFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if err := checkInterval(*interval); err != nil {
+		return err
+	}
+	if *refresh < *interval {
+		return invalidArgs(fmt.Errorf("--refresh must be at least --interval"))
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	config := device.GetConfig()
+	if len(config.Calendars) == 0 {
+		return fmt.Errorf("no calendars in the config, add one such as {\"url\": \"https://...ics\", \"actions\": [\"color red\"]}")
+	}
+	sources, err := parseCalendarSources(config.Calendars)
+	if err != nil {
+		return err
+	}
+	local, err := config.scheduleZone("")
+	if err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	quiet, err := newQuietGate(config, *ignoreQuiet)
+	if err != nil {
+		return err
+	}
+	errs, err := newErrorLog(config, "Calendar")
+	if err != nil {
+		return err
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	follower := &calendarFollower{device: device, quiet: quiet, sources: sources, events: make([][]calendarEvent, len(sources)), current: -1}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	statusf("Following %d calendar(s), checking every %s (ctrl+c to stop)\n", len(sources), *interval)
+	statusf("%s\n", quiet)
+	watchManualChanges(ctx, device)
+	var refreshed time.Time
+	for {
+		var err error
+		if time.Since(refreshed) >= *refresh {
+			// A calendar that cannot be read keeps the events read before
+			for i, source := range sources {
+				data, fetchErr := fetchCalendar(ctx, httpClient, source.URL)
+				var events []calendarEvent
+				if fetchErr == nil {
+					events, fetchErr = parseICal(data, local)
+				}
+				if fetchErr != nil {
+					err = fmt.Errorf("%s: %w", source.label(i), fetchErr)
+					continue
+				}
+				follower.events[i] = events
+			}
+			refreshed = time.Now()
+		}
+		changed := ""
+		stepErr := quiet.settle(ctx, device)
+		if stepErr == nil {
+			changed, stepErr = follower.step(ctx, time.Now())
+		}
+		if err == nil {
+			err = stepErr
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			errs.failed(err)
+		} else {
+			errs.succeeded()
+		}
+		if changed != "" {
+			statusf("%s\n", changed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
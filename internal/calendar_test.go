@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarFollower(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var updates []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/test-token/")
+		if r.Method == http.MethodGet {
+			switch path {
+			case "state":
+				w.Write([]byte(`{"on":{"value":true},"brightness":{"value":40},"colorMode":"effect"}`))
+			case "effects/select":
+				w.Write([]byte(`"Forest"`))
+			}
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		updates = append(updates, path+" "+string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	sources, err := parseCalendarSources([]CalendarSource{
+		{Name: "work", URL: "https://example.com/work.ics", Match: "standup", Actions: []string{"color red"}},
+		{Name: "home", URL: "https://example.com/home.ics", Actions: []string{"brightness 20"}, After: []string{"off"}},
+	})
+	if err != nil {
+		t.Fatalf("parseCalendarSources should not fail: %v", err)
+	}
+	at := func(hour, minute int) time.Time { return time.Date(2024, 10, 21, hour, minute, 0, 0, time.UTC) }
+	follower := &calendarFollower{device: device, sources: sources, current: -1, events: [][]calendarEvent{
+		{
+			{Summary: "Standup", Start: at(9, 0), Duration: 15 * time.Minute},
+			{Summary: "1:1", Start: at(10, 0), Duration: time.Hour},
+		},
+		{{Summary: "Dentist", Start: at(9, 10), Duration: 30 * time.Minute}},
+	}}
+
+	steps := []struct {
+		at      time.Time
+		changed string
+		updates []string
+	}{
+		{at(8, 59), "", nil},
+		{at(9, 0), "work: Standup", []string{`state {"hue":{"value":0},"sat":{"value":100}}`}},
+		{at(9, 12), "", nil},
+		{at(9, 15), "home: Dentist", []string{`state {"brightness":{"value":20}}`}},
+		{at(9, 40), "Events over, the panels are back", []string{`state {"on":{"value":false}}`}},
+		{at(10, 30), "", nil},
+	}
+	for _, step := range steps {
+		updates = nil
+		changed, err := follower.step(context.Background(), step.at)
+		if err != nil {
+			t.Fatalf("step at %s should not fail: %v", step.at.Format("15:04"), err)
+		}
+		if changed != step.changed || strings.Join(updates, "\n") != strings.Join(step.updates, "\n") {
+			t.Errorf("at %s expected %q and %v, got %q and %v", step.at.Format("15:04"), step.changed, step.updates, changed, updates)
+		}
+	}
+
+	// Without after actions the state from before comes back
+	follower.sources[1].after = nil
+	follower.step(context.Background(), at(9, 15))
+	updates = nil
+	if changed, _ := follower.step(context.Background(), at(9, 45)); changed == "" {
+		t.Fatal("expected the events to end")
+	}
+	if want := []string{`effects {"select":"Forest"}`, `state {"brightness":{"value":40}}`}; strings.Join(updates, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected the effect from before restored, got %v", updates)
+	}
+
+	if _, err := parseCalendarSources([]CalendarSource{{URL: "https://example.com/a.ics"}}); err == nil {
+		t.Error("expected a calendar without actions to be rejected")
+	}
+}
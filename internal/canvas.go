@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CanvasPlacement positions one device's layout on the shared canvas, in
+// layout units around the center of its panels
+type CanvasPlacement struct {
+	X        int `json:"x"`
+	Y        int `json:"y"`
+	Rotation int `json:"rotation,omitempty"` // degrees clockwise, a multiple of 90
+}
+
+// canvasSpacing separates devices that have not been placed yet
+const canvasSpacing = 1000
+
+// composeCanvas merges the layouts of several devices into one, ordered by
+// ip. Panels get sequential IDs; owners maps each ID to the device's IP.
+// Devices without a placement are lined up left to right.
+func composeCanvas(layouts map[string]Layout, placements map[string]CanvasPlacement) (canvas Layout, owners []string) {
+	ips := make([]string, 0, len(layouts))
+	for ip := range layouts {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	for i, ip := range ips {
+		placement, ok := placements[ip]
+		if !ok {
+			placement = CanvasPlacement{X: i * canvasSpacing}
+		}
+		layout := LayoutTransform{Rotation: placement.Rotation}.apply(layouts[ip])
+		panels := layout.LightPanels()
+		if len(panels) == 0 {
+			continue
+		}
+
+		minX, maxX, minY, maxY := panels[0].X, panels[0].X, panels[0].Y, panels[0].Y
+		for _, p := range panels {
+			minX, maxX = min(minX, p.X), max(maxX, p.X)
+			minY, maxY = min(minY, p.Y), max(maxY, p.Y)
+		}
+		centerX, centerY := (minX+maxX)/2, (minY+maxY)/2
+
+		for _, p := range panels {
+			p.ID = len(canvas.Panels)
+			p.X += placement.X - centerX
+			p.Y += placement.Y - centerY
+			canvas.Panels = append(canvas.Panels, p)
+			owners = append(owners, ip)
+		}
+	}
+	return canvas, owners
+}
+
+// pairedDevices returns the token of every paired device by IP, including
+// the active one
+func (c Config) pairedDevices() map[string]string {
+	tokens := make(map[string]string)
+	for ip, paired := range c.Devices {
+		if paired.Token != "" {
+			tokens[ip] = paired.Token
+		}
+	}
+	if c.IP != "" && c.Token != "" {
+		tokens[c.IP] = c.Token
+	}
+	return tokens
+}
+
+// PairedLayouts fetches the layout of every paired device. Devices that
+// cannot be reached are left out; it fails only when none answers.
+func (d *Device) PairedLayouts(ctx context.Context) (map[string]Layout, error) {
+	layouts := make(map[string]Layout)
+	var lastErr error
+	for ip, token := range d.GetConfig().pairedDevices() {
+		layout, err := d.client.getLayout(ctx, ip, token)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", ip, err)
+			continue
+		}
+		layouts[ip] = layout
+	}
+	if len(layouts) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return layouts, nil
+}
+
+// SetCanvas persists where each device in placements sits on the shared
+// canvas. The placements of other devices, such as ones that could not be
+// reached to be shown, are kept.
+func (d *Device) SetCanvas(placements map[string]CanvasPlacement) error {
+	return d.updateConfig(func(config *Config) {
+		canvas := make(map[string]CanvasPlacement, len(config.Canvas)+len(placements))
+		for ip, placement := range config.Canvas {
+			canvas[ip] = placement
+		}
+		for ip, placement := range placements {
+			canvas[ip] = placement
+		}
+		config.Canvas = canvas
+	})
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestComposeCanvas(t *testing.T) {
+	layouts := map[string]Layout{
+		"192.168.1.101": {Panels: []Panel{
+			{ID: 10, X: 0, Y: 0, ShapeType: 7},
+			{ID: 11, X: 200, Y: 0, ShapeType: 7},
+			{ID: 0, X: 100, Y: 0, ShapeType: 12},
+		}},
+		"192.168.1.100": {Panels: []Panel{
+			{ID: 20, X: 0, Y: 0, ShapeType: 8},
+			{ID: 21, X: 0, Y: 100, ShapeType: 8},
+		}},
+	}
+	placements := map[string]CanvasPlacement{
+		"192.168.1.101": {X: 500, Y: 50, Rotation: 90},
+	}
+
+	canvas, owners := composeCanvas(layouts, placements)
+	if len(canvas.Panels) != 4 || len(owners) != 4 {
+		t.Fatalf("expected the 4 light panels, got %v", canvas.Panels)
+	}
+
+	expected := []struct {
+		owner string
+		x, y  int
+	}{
+		// Unplaced devices are centered on their default slot
+		{"192.168.1.100", 0, -50},
+		{"192.168.1.100", 0, 50},
+		// Rotated a quarter turn clockwise around its center, then moved
+		{"192.168.1.101", 500, 150},
+		{"192.168.1.101", 500, -50},
+	}
+	for i, e := range expected {
+		p := canvas.Panels[i]
+		if p.ID != i || owners[i] != e.owner || p.X != e.x || p.Y != e.y {
+			t.Errorf("panel %d: expected %s at (%d, %d), got %s %+v", i, e.owner, e.x, e.y, owners[i], p)
+		}
+	}
+}
+
+func TestPairedLayouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"sideLength":150,"positionData":[{"panelId":5,"x":0,"y":0,"shapeType":7}]}`))
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config = Config{
+		IP:    server.URL,
+		Token: "test-token",
+		Devices: map[string]PairedDevice{
+			"http://127.0.0.1:1": {Token: "unreachable"},
+			"192.168.1.102":      {Name: "not paired"},
+		},
+	}
+
+	layouts, err := device.PairedLayouts(context.Background())
+	if err != nil {
+		t.Fatalf("PairedLayouts should not fail: %v", err)
+	}
+	if len(layouts) != 1 || len(layouts[server.URL].Panels) != 1 {
+		t.Errorf("expected only the reachable device, got %v", layouts)
+	}
+}
+
+func TestSetCanvas(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	device := NewDevice()
+	device.config = Config{IP: "192.168.1.100", Token: "test-token", Canvas: map[string]CanvasPlacement{
+		"192.168.1.100": {X: 0},
+		"192.168.1.101": {X: 1000, Y: 200},
+	}}
+	if err := device.SetCanvas(map[string]CanvasPlacement{"192.168.1.100": {X: 300, Rotation: 180}}); err != nil {
+		t.Fatalf("SetCanvas should not fail: %v", err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config should be saved: %v", err)
+	}
+	if config.Canvas["192.168.1.100"] != (CanvasPlacement{X: 300, Rotation: 180}) {
+		t.Errorf("unexpected saved canvas %v", config.Canvas)
+	}
+	if config.Canvas["192.168.1.101"] != (CanvasPlacement{X: 1000, Y: 200}) {
+		t.Errorf("expected the placement of a device left out to be kept, got %v", config.Canvas)
+	}
+}
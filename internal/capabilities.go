@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Capabilities describes which features a device model supports
+type Capabilities struct {
+	Model    string
+	Firmware string
+	// Color is false for white-spectrum-only devices
+	Color bool
+	// Touch reports whether the panels react to touch
+	Touch bool
+	// Rhythm reports whether a microphone is available, either built in
+	// or through a connected Rhythm module
+	Rhythm bool
+	// StreamingVersion is the highest external control protocol supported
+	StreamingVersion int
+	ColorTempMin     int
+	ColorTempMax     int
+	// PanelWatts is the approximate draw of one panel at full brightness
+	PanelWatts float64
+}
+
+type modelCapabilities struct {
+	name          string
+	color         bool
+	touch         bool
+	builtinRhythm bool
+	// v2Firmware is the first firmware with external control v2, empty if
+	// every firmware supports it
+	v2Firmware     string
+	colorTempRange [2]int
+	panelWatts     float64
+}
+
+// models is keyed by the model number reported in the info response
+var models = map[string]modelCapabilities{
+	"NL22": {name: "Light Panels", color: true, v2Firmware: "5.0.0", colorTempRange: [2]int{1200, 6500}, panelWatts: 2},
+	"NL29": {name: "Canvas", color: true, touch: true, builtinRhythm: true, colorTempRange: [2]int{1200, 6500}, panelWatts: 1.6},
+	"NL42": {name: "Shapes", color: true, touch: true, builtinRhythm: true, colorTempRange: [2]int{1200, 6500}, panelWatts: 2},
+	"NL52": {name: "Elements", touch: true, builtinRhythm: true, colorTempRange: [2]int{1500, 4000}, panelWatts: 2.2},
+	"NL59": {name: "Lines", color: true, touch: true, builtinRhythm: true, colorTempRange: [2]int{1200, 6500}, panelWatts: 1},
+}
+
+// resolveCapabilities derives capabilities from the device info response.
+// Unknown models are assumed to support everything so nothing is hidden.
+func resolveCapabilities(info map[string]interface{}) Capabilities {
+	model, _ := info["model"].(string)
+	firmware, _ := info["firmwareVersion"].(string)
+
+	known, ok := models[model]
+	if !ok {
+		return Capabilities{
+			Model:            model,
+			Firmware:         firmware,
+			Color:            true,
+			Touch:            true,
+			Rhythm:           true,
+			StreamingVersion: 2,
+			ColorTempMin:     1200,
+			ColorTempMax:     6500,
+			PanelWatts:       defaultPanelWatts,
+		}
+	}
+
+	caps := Capabilities{
+		Model:            known.name,
+		Firmware:         firmware,
+		Color:            known.color,
+		Touch:            known.touch,
+		Rhythm:           known.builtinRhythm,
+		StreamingVersion: 2,
+		ColorTempMin:     known.colorTempRange[0],
+		ColorTempMax:     known.colorTempRange[1],
+		PanelWatts:       known.panelWatts,
+	}
+	if known.v2Firmware != "" && compareVersions(firmware, known.v2Firmware) < 0 {
+		caps.StreamingVersion = 1
+	}
+	if rhythm, ok := info["rhythm"].(map[string]interface{}); ok {
+		if connected, _ := rhythm["rhythmConnected"].(bool); connected {
+			caps.Rhythm = true
+		}
+	}
+	return caps
+}
+
+// compareVersions compares dotted version strings numerically
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// GetCapabilities fetches the device info and resolves its capabilities.
+// The result is cached for the lifetime of the Device.
+func (d *Device) GetCapabilities(ctx context.Context) (Capabilities, error) {
+	d.capsMu.Lock()
+	defer d.capsMu.Unlock()
+
+	if d.caps != nil {
+		return *d.caps, nil
+	}
+	info, err := d.client.getInfo(ctx, d.GetConfig().IP, d.GetConfig().Token)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	caps := resolveCapabilities(info)
+	d.caps = &caps
+	return caps, nil
+}
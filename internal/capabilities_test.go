@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveCapabilities(t *testing.T) {
+	elements := resolveCapabilities(map[string]interface{}{"model": "NL52", "firmwareVersion": "7.1.0"})
+	if elements.Model != "Elements" || elements.Color || !elements.Touch || elements.ColorTempMax != 4000 {
+		t.Errorf("unexpected Elements capabilities %+v", elements)
+	}
+
+	aurora := resolveCapabilities(map[string]interface{}{"model": "NL22", "firmwareVersion": "3.3.2"})
+	if aurora.StreamingVersion != 1 || aurora.Touch || aurora.Rhythm {
+		t.Errorf("unexpected old Light Panels capabilities %+v", aurora)
+	}
+
+	aurora = resolveCapabilities(map[string]interface{}{
+		"model":           "NL22",
+		"firmwareVersion": "12.0.2",
+		"rhythm":          map[string]interface{}{"rhythmConnected": true},
+	})
+	if aurora.StreamingVersion != 2 || !aurora.Rhythm {
+		t.Errorf("unexpected Light Panels capabilities %+v", aurora)
+	}
+
+	unknown := resolveCapabilities(map[string]interface{}{"model": "NL99"})
+	if !unknown.Color || unknown.StreamingVersion != 2 || unknown.Model != "NL99" {
+		t.Errorf("unknown models should allow everything: %+v", unknown)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"5.0.0", "5.0.0", 0},
+		{"3.3.2", "5.0.0", -1},
+		{"12.0.2", "5.0.0", 1},
+		{"5.1", "5.0.9", 1},
+		{"5", "5.0.0", 0},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("compareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestCapabilitiesFollowDeviceChanges(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	device := func(model string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				w.Write([]byte(`{"auth_token":"new-token"}`))
+				return
+			}
+			w.Write([]byte(`{"model":"` + model + `","firmwareVersion":"9.0.0"}`))
+		}))
+	}
+	elements, shapes := device("NL52"), device("NL42")
+	defer elements.Close()
+	defer shapes.Close()
+
+	d := NewDevice()
+	d.SetDevice(elements.URL)
+	if caps, err := d.GetCapabilities(context.Background()); err != nil || caps.Model != "Elements" {
+		t.Fatalf("expected Elements, got %+v, %v", caps, err)
+	}
+	d.SetDevice(shapes.URL)
+	if caps, _ := d.GetCapabilities(context.Background()); caps.Model == "Elements" {
+		t.Errorf("expected the capabilities of the new device after SetDevice, got %+v", caps)
+	}
+
+	d.SetDevice(elements.URL)
+	d.GetCapabilities(context.Background())
+	if err := d.PairDevice(context.Background()); err != nil {
+		t.Fatalf("PairDevice should not fail: %v", err)
+	}
+	d.capsMu.Lock()
+	cached := d.caps
+	d.capsMu.Unlock()
+	if cached != nil {
+		t.Error("expected pairing to forget the capabilities read with the old token")
+	}
+}
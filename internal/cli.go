@@ -0,0 +1,326 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// command is a non-interactive subcommand run as `nanoleaf-go <name> [flags]`
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+	// automation marks the long-running commands that daemon pause and
+	// the pause signals hold
+	automation bool
+}
+
+var commands = map[string]command{
+	"adaptive-brightness": {
+		usage:      "Adjust brightness to keep a room at a light level from a lux sensor",
+		run:        runAdaptiveBrightness,
+		automation: true,
+	},
+	"api": {
+		usage: "Send a raw request to the device API, e.g. api get state",
+		run:   runAPI,
+	},
+	"backup": {
+		usage: "Save the effects, name and orientation of the device to a file",
+		run:   runBackup,
+	},
+	"bind-keys": {
+		usage: "Write global key bindings to actions for xbindkeys, skhd or AutoHotkey",
+		run:   runBindKeys,
+	},
+	"calendar": {
+		usage:      "Light the panels for the events of iCal calendars, e.g. red during meetings",
+		run:        runCalendar,
+		automation: true,
+	},
+	"config": {
+		usage: "List or restore the automatic backups of the config, or convert it to YAML or TOML",
+		run:   runConfigCommand,
+	},
+	"daemon": {
+		usage: "Pause or resume the automations without stopping them (also SIGUSR1 and SIGUSR2)",
+		run:   runDaemon,
+	},
+	"devices": {
+		usage: "List known devices and whether they are reachable",
+		run:   runDevices,
+	},
+	"doctor": {
+		usage: "Check the config, devices and network, with how to fix what fails",
+		run:   runDoctor,
+	},
+	"effects": {
+		usage: "Export installed effects to JSON files (pull) or install them (push)",
+		run:   runEffects,
+	},
+	"export": {
+		usage: "Write the panel layout (JSON or --svg), device state or pairing to share",
+		run:   runExport,
+	},
+	"history": {
+		usage: "Show the commands sent to devices and their results",
+		run:   runHistory,
+	},
+	"holidays": {
+		usage:      "Show themed scenes on holidays, or list them with --list",
+		run:        runHolidays,
+		automation: true,
+	},
+	"hue-sync": {
+		usage:      "Mirror a Philips Hue light onto the paired device",
+		run:        runHueSync,
+		automation: true,
+	},
+	"import": {
+		usage: "Use a token from another app (--ip and --token, or --file)",
+		run:   runImport,
+	},
+	"integration": {
+		usage: "Generate a menu-bar plugin that talks to serve (xbar, for xbar and SwiftBar)",
+		run:   runIntegration,
+	},
+	"lines": {
+		usage: "Show a gradient or flow effect with a direction (for Lines)",
+		run:   runLines,
+	},
+	"macro": {
+		usage: "Run a macro from the config, or list macros without a name",
+		run:   runMacroCommand,
+	},
+	"maintenance": {
+		usage: "Identify, reboot, reset the orientation or delete all effects",
+		run:   runMaintenance,
+	},
+	"music": {
+		usage: "Stream panels that react to the audio playing on this computer",
+		run:   runMusic,
+	},
+	"now-playing": {
+		usage:      "Show the album art colors of the playing song (MPRIS or Spotify)",
+		run:        runNowPlaying,
+		automation: true,
+	},
+	"obs": {
+		usage:      "Run actions on OBS events, e.g. red panels while streaming",
+		run:        runOBS,
+		automation: true,
+	},
+	"openrgb": {
+		usage: "Mirror the LED colors of OpenRGB onto the panels",
+		run:   runOpenRGB,
+	},
+	"palette": {
+		usage: "Create an effect from the dominant colors of an image (from-image)",
+		run:   runPalette,
+	},
+	"profiles": {
+		usage: "List the config profiles, the active one marked with *",
+		run:   runProfiles,
+	},
+	"qr": {
+		usage: "Show the credentials of a paired device as a QR code",
+		run:   runQR,
+	},
+	"rename": {
+		usage: "Name a device, e.g. rename Living Room Hexagons",
+		run:   runRename,
+	},
+	"replay": {
+		usage: "Play a recording made with stream or music --record, once or looping",
+		run:   runReplay,
+	},
+	"restore": {
+		usage: "Reinstall a backup, e.g. after a factory reset",
+		run:   runRestore,
+	},
+	"run": {
+		usage: "Run a Lua script against the paired device",
+		run:   runScriptCommand,
+	},
+	"scan": {
+		usage: "Scan the local networks for devices",
+		run:   runScan,
+	},
+	"schedule": {
+		usage: "Simulate the quiet hours and holiday scenes, e.g. schedule simulate --to +7d",
+		run:   runSchedule,
+	},
+	"serve": {
+		usage:      "Listen for presence and trigger webhooks",
+		run:        runServe,
+		automation: true,
+	},
+	"stats": {
+		usage: "Show on-time and estimated energy use of the paired device",
+		run:   runStats,
+	},
+	"stream": {
+		usage: "Stream a client-side generated animation (--generator)",
+		run:   runStream,
+	},
+	"system-load": {
+		usage: "Show the CPU load, memory use or temperature of this computer, green to red",
+		run:   runSystemLoad,
+	},
+	"uri": {
+		usage: "Run a nanoleaf:// link, or register them for shortcuts (Windows)",
+		run:   runURI,
+	},
+	"watch-url": {
+		usage:      "Poll a JSON endpoint and set the panel color from a value",
+		run:        runWatchURL,
+		automation: true,
+	},
+	"weather": {
+		usage:      "Pick effects from the current weather, refreshed hourly",
+		run:        runWeather,
+		automation: true,
+	},
+}
+
+// Set by the global flags given before the command
+var (
+	ignoreFirmware bool
+	quietMode      bool
+)
+
+// QuietMode reports whether --quiet was given, in which case status
+// messages are left out and only requested output and errors are printed
+func QuietMode() bool {
+	return quietMode
+}
+
+// RunCommand dispatches args[0] to the matching subcommand, after any
+// global flags
+func RunCommand(ctx context.Context, args []string) error {
+	stdin := false
+	for ; len(args) > 0; args = args[1:] {
+		if args[0] == "--ignore-firmware" {
+			ignoreFirmware = true
+		} else if args[0] == "--quiet" {
+			quietMode = true
+		} else if args[0] == "--stdin" {
+			stdin = true
+		} else {
+			break
+		}
+	}
+	if stdin {
+		return runStdin(ctx, args)
+	}
+	if len(args) == 0 {
+		if !quietMode {
+			printUsage()
+		}
+		return invalidArgs(fmt.Errorf("no command given"))
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return nil
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		if !quietMode {
+			printUsage()
+		}
+		return invalidArgs(fmt.Errorf("unknown command %q", name))
+	}
+	if cmd.automation {
+		runningAutomation = name
+		watchPauseSignals(ctx)
+	}
+	err := cmd.run(ctx, args[1:])
+	if errors.Is(err, ErrUnauthorized) {
+		return fmt.Errorf("%w, pair the device again from the interactive UI", err)
+	}
+	return err
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: nanoleaf-go [--profile NAME] [--quiet] [--ignore-firmware] [--stdin | command] [flags]")
+	fmt.Fprintln(os.Stderr, "\nRun without a command to start the interactive UI, or with --no-tui for")
+	fmt.Fprintln(os.Stderr, "plain numbered menus that work with screen readers.")
+	fmt.Fprintln(os.Stderr, "\n--profile, or $NANOLEAF_PROFILE, picks a separate config with its own")
+	fmt.Fprintln(os.Stderr, "devices, presets and automations, e.g. home, office or travel.")
+	fmt.Fprintln(os.Stderr, "\n--quiet leaves out status messages for scripts and CI, --ignore-firmware")
+	fmt.Fprintln(os.Stderr, "silences the warnings about the device's firmware. --stdin runs one")
+	fmt.Fprintln(os.Stderr, "command per line from stdin (on, brightness 40, effect NAME, status, ...)")
+	fmt.Fprintln(os.Stderr, "and answers each with a line starting with ok or error:.")
+	fmt.Fprintln(os.Stderr, "\nExit codes: 1 failed, 2 device unreachable, 3 token rejected, 4 invalid arguments.")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].usage)
+	}
+}
+
+// statusf prints a status message, unless --quiet was given
+func statusf(format string, args ...interface{}) {
+	if !quietMode {
+		fmt.Printf(format, args...)
+	}
+}
+
+// errorf prints a failure a long-running command goes on after, such as a
+// poll that failed. It goes to stderr and --quiet does not hide it.
+func errorf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// checkInterval rejects a polling --interval that is not above zero, which
+// the ticker of the loop would panic on
+func checkInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return invalidArgs(fmt.Errorf("--interval must be above 0, e.g. 30s"))
+	}
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if quietMode {
+		fs.SetOutput(io.Discard)
+	}
+	return fs
+}
+
+// newCommandDevice returns a Device that prints firmware warnings and
+// honors --ignore-firmware
+func newCommandDevice() *Device {
+	device := NewDevice()
+	device.ignoreFirmware = ignoreFirmware
+	device.warn = func(message string) {
+		fmt.Fprintln(os.Stderr, "Warning: "+message)
+	}
+	return device
+}
+
+// loadPairedDevice returns a Device ready for use from the saved config
+func loadPairedDevice() (*Device, error) {
+	device := newCommandDevice()
+	if err := device.LoadConfig(); err != nil {
+		return nil, fmt.Errorf("%w (pair a device with the interactive UI first)", err)
+	}
+	if device.GetConfig().Token == "" {
+		return nil, fmt.Errorf("device %s is not paired", device.GetConfig().IP)
+	}
+	return device, nil
+}
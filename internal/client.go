@@ -4,20 +4,80 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
+// ErrUnauthorized is returned when the device rejects the auth token, which
+// happens after it was reset or the token was revoked
+var ErrUnauthorized = errors.New("device token rejected")
+
+// errNotFound is returned for a path the firmware does not serve
+var errNotFound = errors.New("not found")
+
+// updateStatusError is returned when the device refuses a write
+type updateStatusError struct {
+	status int
+	body   string
+}
+
+func (e *updateStatusError) Error() string {
+	return fmt.Sprintf("state update failed with status %d: %s", e.status, e.body)
+}
+
+// Connection settings of apiTransport. Devices are on the local network,
+// so a dial that takes seconds means the device is gone. Idle connections
+// are kept for minutes since hotkeys and automations send commands minutes
+// apart, and TCP keep-alives notice a device that went away meanwhile.
+const (
+	apiDialTimeout         = 3 * time.Second
+	apiKeepAlive           = 15 * time.Second
+	apiIdleTimeout         = 5 * time.Minute
+	apiMaxIdleConnsPerHost = 4
+)
+
+// apiTransport is shared by the clients of every device, so a connection
+// to a device is reused by the next command rather than dialed again. The
+// status refresh of the UI sends four requests at once, hence more than
+// the two idle connections per host of http.DefaultTransport.
+var apiTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   apiDialTimeout,
+		KeepAlive: apiKeepAlive,
+	}).DialContext,
+	MaxIdleConns:          64,
+	MaxIdleConnsPerHost:   apiMaxIdleConnsPerHost,
+	IdleConnTimeout:       apiIdleTimeout,
+	ResponseHeaderTimeout: 10 * time.Second,
+}
+
+// closeBody reads what is left of a response before closing it, which lets
+// the connection go back to apiTransport for the next request
+func closeBody(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	body.Close()
+}
+
 type NanoleafClient struct {
 	httpClient *http.Client
+	// capMu guards maxBrightness, which, when set, returns the highest
+	// brightness a change may leave the panels at, see setBrightnessCap
+	capMu         sync.Mutex
+	maxBrightness func() int
 }
 
 func newClient() *NanoleafClient {
 	return &NanoleafClient{
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Transport: authTokenMiddleware(apiTransport),
+			Timeout:   10 * time.Second,
 		},
 	}
 }
@@ -41,7 +101,7 @@ func (c *NanoleafClient) pair(ctx context.Context, ip string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("pairing request failed: %w", err)
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -64,7 +124,8 @@ func (c *NanoleafClient) pair(ctx context.Context, ip string) (string, error) {
 }
 
 func (c *NanoleafClient) getInfo(ctx context.Context, ip, token string) (map[string]interface{}, error) {
-	url := c.buildURL(ip, fmt.Sprintf("api/v1/%s", token))
+	ctx = withAuthToken(ctx, token)
+	url := c.buildURL(ip, "api/v1")
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -75,8 +136,11 @@ func (c *NanoleafClient) getInfo(ctx context.Context, ip, token string) (map[str
 	if err != nil {
 		return nil, fmt.Errorf("get info request failed: %w", err)
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("get info failed with status %d", resp.StatusCode)
 	}
@@ -89,8 +153,123 @@ func (c *NanoleafClient) getInfo(ctx context.Context, ip, token string) (map[str
 	return info, nil
 }
 
+func (c *NanoleafClient) getLayout(ctx context.Context, ip, token string) (Layout, error) {
+	ctx = withAuthToken(ctx, token)
+	var layout Layout
+	err := c.getJSON(ctx, c.buildURL(ip, "api/v1/panelLayout/layout"), &layout)
+	return layout, err
+}
+
+func (c *NanoleafClient) getPower(ctx context.Context, ip, token string) (bool, error) {
+	ctx = withAuthToken(ctx, token)
+	var result struct {
+		Value bool `json:"value"`
+	}
+	err := c.getJSON(ctx, c.buildURL(ip, "api/v1/state/on"), &result)
+	return result.Value, err
+}
+
+func (c *NanoleafClient) getBrightness(ctx context.Context, ip, token string) (int, error) {
+	ctx = withAuthToken(ctx, token)
+	var result struct {
+		Value int `json:"value"`
+	}
+	err := c.getJSON(ctx, c.buildURL(ip, "api/v1/state/brightness"), &result)
+	return result.Value, err
+}
+
+func (c *NanoleafClient) getColor(ctx context.Context, ip, token string) (hue, saturation int, err error) {
+	ctx = withAuthToken(ctx, token)
+	var result struct {
+		Value int `json:"value"`
+	}
+	if err := c.getJSON(ctx, c.buildURL(ip, "api/v1/state/hue"), &result); err != nil {
+		return 0, 0, err
+	}
+	hue = result.Value
+	err = c.getJSON(ctx, c.buildURL(ip, "api/v1/state/sat"), &result)
+	return hue, result.Value, err
+}
+
+func (c *NanoleafClient) getSelectedEffect(ctx context.Context, ip, token string) (string, error) {
+	ctx = withAuthToken(ctx, token)
+	var name string
+	err := c.getJSON(ctx, c.buildURL(ip, "api/v1/effects/select"), &name)
+	return name, err
+}
+
+// getEffectNames lists the names of the effects installed on the device
+func (c *NanoleafClient) getEffectNames(ctx context.Context, ip, token string) ([]string, error) {
+	ctx = withAuthToken(ctx, token)
+	var names []string
+	err := c.getJSON(ctx, c.buildURL(ip, "api/v1/effects/effectsList"), &names)
+	return names, err
+}
+
+// getJSON fetches a single endpoint and decodes its JSON response into out
+func (c *NanoleafClient) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("get request failed: %w", err)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("get request failed with status %d: %w", resp.StatusCode, errNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get request failed with status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
+
+// brightnessCap returns the highest brightness a change may send, 100
+// without a cap
+func (c *NanoleafClient) brightnessCap() int {
+	c.capMu.Lock()
+	maxBrightness := c.maxBrightness
+	c.capMu.Unlock()
+	if maxBrightness == nil {
+		return 100
+	}
+	return maxBrightness()
+}
+
+// lowerToCap lowers the brightness of the device to the cap when it is
+// above it, so a change that keeps the brightness never shows above it
+func (c *NanoleafClient) lowerToCap(ctx context.Context, ip, token string) error {
+	limit := c.brightnessCap()
+	if limit >= 100 {
+		return nil
+	}
+	current, err := c.getBrightness(ctx, ip, token)
+	if err != nil || current <= limit {
+		return err
+	}
+	return c.setBrightness(ctx, ip, token, limit)
+}
+
 func (c *NanoleafClient) setPower(ctx context.Context, ip, token string, on bool) error {
-	url := c.buildURL(ip, fmt.Sprintf("api/v1/%s/state", token))
+	ctx = withAuthToken(ctx, token)
+	if on {
+		if err := c.lowerToCap(ctx, ip, token); err != nil {
+			return err
+		}
+	}
+	url := c.buildURL(ip, "api/v1/state")
 
 	payload := map[string]interface{}{
 		"on": map[string]bool{"value": on},
@@ -100,22 +279,199 @@ func (c *NanoleafClient) setPower(ctx context.Context, ip, token string, on bool
 }
 
 func (c *NanoleafClient) setBrightness(ctx context.Context, ip, token string, brightness int) error {
-	url := c.buildURL(ip, fmt.Sprintf("api/v1/%s/state", token))
+	ctx = withAuthToken(ctx, token)
+	url := c.buildURL(ip, "api/v1/state")
+	brightness = min(brightness, c.brightnessCap())
+
+	payload := map[string]interface{}{
+		"brightness": map[string]int{"value": brightness},
+	}
+
+	return c.sendStateUpdate(ctx, url, payload)
+}
+
+func (c *NanoleafClient) fadeBrightness(ctx context.Context, ip, token string, brightness, duration int) error {
+	ctx = withAuthToken(ctx, token)
+	url := c.buildURL(ip, "api/v1/state")
+	brightness = min(brightness, c.brightnessCap())
+
+	payload := map[string]interface{}{
+		"brightness": map[string]int{"value": brightness, "duration": duration},
+	}
+
+	return c.sendStateUpdate(ctx, url, payload)
+}
+
+func (c *NanoleafClient) setColor(ctx context.Context, ip, token string, hue, saturation int) error {
+	ctx = withAuthToken(ctx, token)
+	if err := c.lowerToCap(ctx, ip, token); err != nil {
+		return err
+	}
+	url := c.buildURL(ip, "api/v1/state")
+
+	payload := map[string]interface{}{
+		"hue": map[string]int{"value": hue},
+		"sat": map[string]int{"value": saturation},
+	}
+
+	return c.sendStateUpdate(ctx, url, payload)
+}
+
+// setHSB sets color and brightness in one request
+func (c *NanoleafClient) setHSB(ctx context.Context, ip, token string, hue, saturation, brightness int) error {
+	ctx = withAuthToken(ctx, token)
+	url := c.buildURL(ip, "api/v1/state")
+	brightness = min(brightness, c.brightnessCap())
 
 	payload := map[string]interface{}{
+		"hue":        map[string]int{"value": hue},
+		"sat":        map[string]int{"value": saturation},
 		"brightness": map[string]int{"value": brightness},
 	}
 
 	return c.sendStateUpdate(ctx, url, payload)
 }
 
+func (c *NanoleafClient) setColorTemp(ctx context.Context, ip, token string, kelvin int) error {
+	ctx = withAuthToken(ctx, token)
+	if err := c.lowerToCap(ctx, ip, token); err != nil {
+		return err
+	}
+	url := c.buildURL(ip, "api/v1/state")
+
+	payload := map[string]interface{}{
+		"ct": map[string]int{"value": kelvin},
+	}
+
+	return c.sendStateUpdate(ctx, url, payload)
+}
+
+func (c *NanoleafClient) selectEffect(ctx context.Context, ip, token, name string) error {
+	ctx = withAuthToken(ctx, token)
+	if err := c.lowerToCap(ctx, ip, token); err != nil {
+		return err
+	}
+	url := c.buildURL(ip, "api/v1/effects")
+
+	payload := map[string]interface{}{
+		"select": name,
+	}
+
+	return c.sendStateUpdate(ctx, url, payload)
+}
+
+func (c *NanoleafClient) writeEffect(ctx context.Context, ip, token string, effect map[string]interface{}) error {
+	ctx = withAuthToken(ctx, token)
+	url := c.buildURL(ip, "api/v1/effects")
+
+	payload := map[string]interface{}{
+		"write": effect,
+	}
+
+	return c.sendStateUpdate(ctx, url, payload)
+}
+
+func (c *NanoleafClient) requestAllEffects(ctx context.Context, ip, token string) ([]map[string]interface{}, error) {
+	ctx = withAuthToken(ctx, token)
+	url := c.buildURL(ip, "api/v1/effects")
+
+	payload := map[string]interface{}{
+		"write": map[string]string{"command": "requestAll"},
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("effects request failed: %w", err)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("effects request failed with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Animations []map[string]interface{} `json:"animations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse effects response: %w", err)
+	}
+
+	return result.Animations, nil
+}
+
+// rawRequest sends body to an arbitrary path below the authenticated API root
+// and returns the status code and response body without interpreting them
+func (c *NanoleafClient) rawRequest(ctx context.Context, ip, token, method, path string, body []byte) (int, []byte, error) {
+	ctx = withAuthToken(ctx, token)
+	url := c.buildURL(ip, "api/v1/"+strings.TrimPrefix(path, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s request failed: %w", method, err)
+	}
+	defer closeBody(resp.Body)
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, data, nil
+}
+
+// authTokenKey holds the token of the device a request goes to
+type authTokenKey struct{}
+
+// withAuthToken attaches token to the requests made with ctx, which
+// authTokenMiddleware puts into their path. URLs are built without it so
+// it stays out of anything that logs or reports them.
+func withAuthToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, authTokenKey{}, token)
+}
+
+// deviceChangeKey marks the context of a request that changes the device
+type deviceChangeKey struct{}
+
+// asDeviceChange marks the requests made with ctx as changes to the device,
+// as opposed to writes that only read, such as requestAll
+func asDeviceChange(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deviceChangeKey{}, true)
+}
+
+// isDeviceChange reports whether ctx was marked by asDeviceChange
+func isDeviceChange(ctx context.Context) bool {
+	changes, _ := ctx.Value(deviceChangeKey{}).(bool)
+	return changes
+}
+
+// sendStateUpdate writes payload to url, a change to the device
 func (c *NanoleafClient) sendStateUpdate(ctx context.Context, url string, payload map[string]interface{}) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(asDeviceChange(ctx), "PUT", url, bytes.NewBuffer(data))
 	if err != nil {
 		return err
 	}
@@ -125,11 +481,14 @@ func (c *NanoleafClient) sendStateUpdate(ctx context.Context, url string, payloa
 	if err != nil {
 		return fmt.Errorf("state update request failed: %w", err)
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
 	if resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("state update failed with status %d: %s", resp.StatusCode, string(body))
+		return &updateStatusError{status: resp.StatusCode, body: string(body)}
 	}
 
 	return nil
@@ -3,8 +3,13 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 )
@@ -179,3 +184,349 @@ func TestSendStateUpdateError(t *testing.T) {
 		t.Error("setPower should fail with non-204 status")
 	}
 }
+
+func TestWriteEffect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("expected PUT request, got %s", r.Method)
+		}
+		if r.URL.Path != "/api/v1/test-token/effects" {
+			t.Errorf("expected path /api/v1/test-token/effects, got %s", r.URL.Path)
+		}
+
+		var payload map[string]map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		if payload["write"]["command"] != "display" {
+			t.Errorf("expected write.command display, got %v", payload["write"]["command"])
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := newClient()
+	ctx := context.Background()
+
+	effect := map[string]interface{}{"command": "display", "animType": "fade"}
+	if err := client.writeEffect(ctx, server.URL, "test-token", effect); err != nil {
+		t.Fatalf("writeEffect should not fail: %v", err)
+	}
+}
+
+func TestRequestAllEffects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		if payload["write"]["command"] != "requestAll" {
+			t.Errorf("expected write.command requestAll, got %v", payload["write"]["command"])
+		}
+
+		w.Write([]byte(`{"animations":[{"animName":"Blaze","animType":"flow"},{"animName":"Snowfall","animType":"fade"}]}`))
+	}))
+	defer server.Close()
+
+	client := newClient()
+	ctx := context.Background()
+
+	effects, err := client.requestAllEffects(ctx, server.URL, "test-token")
+	if err != nil {
+		t.Fatalf("requestAllEffects should not fail: %v", err)
+	}
+	if len(effects) != 2 || effects[0]["animName"] != "Blaze" {
+		t.Errorf("unexpected effects %v", effects)
+	}
+}
+
+func TestSelectEffect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/test-token/effects" {
+			t.Errorf("expected path /api/v1/test-token/effects, got %s", r.URL.Path)
+		}
+
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		if payload["select"] != "Blaze" {
+			t.Errorf("expected select Blaze, got %v", payload["select"])
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := newClient()
+	ctx := context.Background()
+
+	if err := client.selectEffect(ctx, server.URL, "test-token", "Blaze"); err != nil {
+		t.Fatalf("selectEffect should not fail: %v", err)
+	}
+}
+
+func TestGetLayout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/test-token/panelLayout/layout" {
+			t.Errorf("expected path /api/v1/test-token/panelLayout/layout, got %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"numPanels":2,"sideLength":150,"positionData":[{"panelId":11,"x":0,"y":0,"o":0,"shapeType":7},{"panelId":0,"x":75,"y":0,"o":0,"shapeType":12}]}`))
+	}))
+	defer server.Close()
+
+	client := newClient()
+	ctx := context.Background()
+
+	layout, err := client.getLayout(ctx, server.URL, "test-token")
+	if err != nil {
+		t.Fatalf("getLayout should not fail: %v", err)
+	}
+	if layout.SideLength != 150 || len(layout.Panels) != 2 {
+		t.Fatalf("unexpected layout %+v", layout)
+	}
+	if layout.Panels[0].ID != 11 || layout.Panels[0].ShapeType != 7 {
+		t.Errorf("unexpected panel %+v", layout.Panels[0])
+	}
+}
+
+func TestGetStateEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/test-token/state/on":
+			w.Write([]byte(`{"value":true}`))
+		case "/api/v1/test-token/state/brightness":
+			w.Write([]byte(`{"value":80,"max":100,"min":0}`))
+		case "/api/v1/test-token/effects/select":
+			w.Write([]byte(`"Northern Lights"`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := newClient()
+	ctx := context.Background()
+
+	on, err := client.getPower(ctx, server.URL, "test-token")
+	if err != nil || !on {
+		t.Errorf("expected power on, got %v (%v)", on, err)
+	}
+
+	brightness, err := client.getBrightness(ctx, server.URL, "test-token")
+	if err != nil || brightness != 80 {
+		t.Errorf("expected brightness 80, got %d (%v)", brightness, err)
+	}
+
+	effect, err := client.getSelectedEffect(ctx, server.URL, "test-token")
+	if err != nil || effect != "Northern Lights" {
+		t.Errorf("expected effect Northern Lights, got %q (%v)", effect, err)
+	}
+}
+
+func TestGetJSONError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := newClient()
+	if _, err := client.getPower(context.Background(), server.URL, "test-token"); err == nil {
+		t.Error("getPower should fail with non-200 status")
+	}
+}
+
+func TestRawRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/api/v1/test-token/state" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"hue":{"value":120}}` {
+			t.Errorf("unexpected body %s", body)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := newClient()
+	status, data, err := client.rawRequest(context.Background(), server.URL, "test-token", "PUT", "/state", []byte(`{"hue":{"value":120}}`))
+	if err != nil {
+		t.Fatalf("rawRequest should not fail: %v", err)
+	}
+	if status != http.StatusNoContent || len(data) != 0 {
+		t.Errorf("unexpected response %d %q", status, data)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := newClient()
+	ctx := context.Background()
+
+	if err := client.setPower(ctx, server.URL, "stale-token", true); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("setPower should report a rejected token, got %v", err)
+	}
+	if _, err := client.getPower(ctx, server.URL, "stale-token"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("getPower should report a rejected token, got %v", err)
+	}
+	if _, err := client.getInfo(ctx, server.URL, "stale-token"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("getInfo should report a rejected token, got %v", err)
+	}
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	dials := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		// A trailing newline the decoder does not need
+		w.Write([]byte("{\"value\": 40}\n\n"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			dials++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	for i := 0; i < 5; i++ {
+		if _, err := device.client.getBrightness(context.Background(), server.URL, "test-token"); err != nil {
+			t.Fatalf("getBrightness failed: %v", err)
+		}
+		if err := device.client.setBrightness(context.Background(), server.URL, "test-token", 50); err != nil {
+			t.Fatalf("setBrightness failed: %v", err)
+		}
+	}
+	if dials != 1 {
+		t.Errorf("expected one connection for all requests, got %d", dials)
+	}
+	if NewDevice().client.httpClient.Transport.(*deviceTransport).base != apiTransport {
+		t.Error("devices should share the API transport")
+	}
+}
+
+// simulatedInfo builds a GetInfo payload the size of a large installation,
+// with panels panels and effects effects
+func simulatedInfo(panels, effects int) map[string]interface{} {
+	positions := make([]map[string]interface{}, panels)
+	for i := range positions {
+		positions[i] = map[string]interface{}{"panelId": i + 1, "x": i % 20 * 150, "y": i / 20 * 130, "o": i % 6 * 60, "shapeType": 7}
+	}
+	names := make([]string, effects)
+	for i := range names {
+		names[i] = fmt.Sprintf("Effect %d", i+1)
+	}
+	return map[string]interface{}{
+		"name":            "Shapes Simulator",
+		"serialNo":        "S19124C8036",
+		"manufacturer":    "Nanoleaf",
+		"firmwareVersion": "9.2.4",
+		"model":           "NL42",
+		"state": map[string]interface{}{
+			"on":         map[string]bool{"value": true},
+			"brightness": map[string]int{"value": 80, "max": 100, "min": 0},
+			"hue":        map[string]int{"value": 120, "max": 360, "min": 0},
+			"sat":        map[string]int{"value": 50, "max": 100, "min": 0},
+			"ct":         map[string]int{"value": 4000, "max": 6500, "min": 1200},
+			"colorMode":  "hs",
+		},
+		"effects": map[string]interface{}{"select": names[0], "effectsList": names},
+		"panelLayout": map[string]interface{}{
+			"globalOrientation": map[string]int{"value": 0, "max": 360, "min": 0},
+			"layout":            map[string]interface{}{"numPanels": panels, "sideLength": 150, "positionData": positions},
+		},
+	}
+}
+
+// newSimulatedDevice serves the parts of the device API the benchmarks use,
+// keeping state updates in memory like a device would
+func newSimulatedDevice(tb testing.TB, info map[string]interface{}) *httptest.Server {
+	var mu sync.Mutex
+	state := info["state"].(map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/token":
+			json.NewEncoder(w).Encode(info)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/token/state":
+			json.NewEncoder(w).Encode(state)
+		case r.Method == http.MethodPut && r.URL.Path == "/api/v1/token/state":
+			var update map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for key, value := range update {
+				state[key] = value
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	tb.Cleanup(server.Close)
+	return server
+}
+
+func BenchmarkStateRoundTrip(b *testing.B) {
+	server := newSimulatedDevice(b, simulatedInfo(20, 10))
+	device := NewDevice()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := device.client.setBrightness(ctx, server.URL, "token", i%101); err != nil {
+			b.Fatal(err)
+		}
+		state, err := device.client.getState(ctx, server.URL, "token")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if state.Brightness != i%101 {
+			b.Fatalf("expected brightness %d, got %d", i%101, state.Brightness)
+		}
+	}
+}
+
+func BenchmarkGetInfoLarge(b *testing.B) {
+	server := newSimulatedDevice(b, simulatedInfo(500, 200))
+	client := newClient()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.getInfo(ctx, server.URL, "token"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseInfoLarge(b *testing.B) {
+	data, err := json.Marshal(simulatedInfo(500, 200))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var info map[string]interface{}
+		if err := json.Unmarshal(data, &info); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
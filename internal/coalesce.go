@@ -0,0 +1,51 @@
+package internal
+
+// coalescer collapses rapid updates of a value so only the latest target is
+// sent, with at most one request in flight. Callers send on a timer tick
+// after set or done asks for one, which caps the request rate.
+type coalescer struct {
+	target    int
+	sent      int
+	hasSent   bool
+	scheduled bool
+	inFlight  bool
+}
+
+// set updates the target and reports whether a flush should be scheduled
+func (c *coalescer) set(value int) bool {
+	c.target = value
+	if c.scheduled || c.inFlight {
+		return false
+	}
+	c.scheduled = true
+	return true
+}
+
+// busy reports whether an update is waiting to be sent or being sent
+func (c *coalescer) busy() bool {
+	return c.scheduled || c.inFlight
+}
+
+// flush returns the value to send, if it differs from the last sent value
+func (c *coalescer) flush() (int, bool) {
+	c.scheduled = false
+	if c.inFlight || (c.hasSent && c.target == c.sent) {
+		return 0, false
+	}
+	c.inFlight = true
+	return c.target, true
+}
+
+// done records a finished send and reports whether the target moved on in
+// the meantime and another flush should be scheduled
+func (c *coalescer) done(value int, ok bool) bool {
+	c.inFlight = false
+	if ok {
+		c.sent, c.hasSent = value, true
+	}
+	if !ok || c.target == c.sent || c.scheduled {
+		return false
+	}
+	c.scheduled = true
+	return true
+}
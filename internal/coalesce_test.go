@@ -0,0 +1,50 @@
+package internal
+
+import "testing"
+
+func TestCoalescer(t *testing.T) {
+	var c coalescer
+
+	// Only the first change schedules a flush
+	if !c.set(55) || c.set(60) || c.set(65) {
+		t.Fatal("expected a single scheduled flush")
+	}
+	value, ok := c.flush()
+	if !ok || value != 65 {
+		t.Fatalf("expected to send 65, got %d %v", value, ok)
+	}
+
+	// Changes while a request is in flight wait for it to finish
+	if c.set(70) || c.set(75) {
+		t.Error("no flush should be scheduled while sending")
+	}
+	if !c.done(65, true) {
+		t.Fatal("a moved target should schedule another flush")
+	}
+	value, ok = c.flush()
+	if !ok || value != 75 {
+		t.Fatalf("expected to send 75, got %d %v", value, ok)
+	}
+	if c.done(75, true) {
+		t.Error("nothing left to send")
+	}
+
+	// Returning to the sent value sends nothing
+	c.set(80)
+	c.set(75)
+	if _, ok := c.flush(); ok {
+		t.Error("the target matches the sent value")
+	}
+}
+
+func TestCoalescerFailure(t *testing.T) {
+	var c coalescer
+	c.set(40)
+	c.flush()
+	if c.done(40, false) {
+		t.Error("a failed send should not retry on its own")
+	}
+	if value, ok := c.flush(); !ok || value != 40 {
+		t.Errorf("the failed value should be sent on the next flush, got %d %v", value, ok)
+	}
+}
@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type rgbColor struct {
+	R, G, B uint8
+}
+
+// parseColor accepts a hex color ("#ff8800" or "ff8800") or a color name.
+// Names ignore case, spaces, dashes and underscores ("Warm White").
+func parseColor(s string) (rgbColor, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := colorNames[normalizeColorName(s)]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		if value, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return rgbColor{uint8(value >> 16), uint8(value >> 8), uint8(value)}, nil
+		}
+	}
+
+	if suggestions := suggestColorNames(s); len(suggestions) > 0 {
+		return rgbColor{}, fmt.Errorf("unknown color %q, did you mean %s?", s, strings.Join(suggestions, ", "))
+	}
+	return rgbColor{}, fmt.Errorf("unknown color %q", s)
+}
+
+func normalizeColorName(s string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(s)
+}
+
+// suggestColorNames returns up to three known names closest to a misspelled one
+func suggestColorNames(s string) []string {
+	name := normalizeColorName(s)
+	if name == "" || strings.HasPrefix(name, "#") {
+		return nil
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for known := range colorNames {
+		distance := editDistance(name, known)
+		if distance <= len(name)/3+1 || (len(name) >= 3 && strings.Contains(known, name)) {
+			candidates = append(candidates, candidate{known, distance})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var names []string
+	for i := 0; i < len(candidates) && i < 3; i++ {
+		names = append(names, candidates[i].name)
+	}
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// hsb converts the color to the device's hue (0-360), saturation (0-100)
+// and brightness (0-100) scales
+func (c rgbColor) hsb() (hue, sat, bri int) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	max := r
+	if g > max {
+		max = g
+	}
+	if b > max {
+		max = b
+	}
+	min := r
+	if g < min {
+		min = g
+	}
+	if b < min {
+		min = b
+	}
+	delta := max - min
+
+	var h float64
+	switch {
+	case delta == 0:
+		h = 0
+	case max == r:
+		h = 60 * (g - b) / delta
+	case max == g:
+		h = 60 * ((b-r)/delta + 2)
+	default:
+		h = 60 * ((r-g)/delta + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+
+	var s float64
+	if max > 0 {
+		s = delta / max
+	}
+
+	return int(h+0.5) % 360, int(s*100 + 0.5), int(max*100 + 0.5)
+}
+
+// hsbToRGB converts a color in the device's HSB scales back to RGB
+func hsbToRGB(hue, sat, bri int) rgbColor {
+	h := float64(((hue%360)+360)%360) / 60
+	s := float64(sat) / 100
+	v := float64(bri) / 100
+
+	chroma := v * s
+	x := chroma * (1 - math.Abs(math.Mod(h, 2)-1))
+	m := v - chroma
+
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g, b = chroma, x, 0
+	case 1:
+		r, g, b = x, chroma, 0
+	case 2:
+		r, g, b = 0, chroma, x
+	case 3:
+		r, g, b = 0, x, chroma
+	case 4:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+
+	return rgbColor{
+		uint8((r+m)*255 + 0.5),
+		uint8((g+m)*255 + 0.5),
+		uint8((b+m)*255 + 0.5),
+	}
+}
+
+func (c rgbColor) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// parseColorTemp parses a color temperature such as "2700" or "2700K"
+func parseColorTemp(s string) (int, error) {
+	s = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(s), "K"), "k")
+	kelvin, err := strconv.Atoi(s)
+	if err != nil || kelvin <= 0 {
+		return 0, fmt.Errorf("color temperature must be a number of Kelvin, e.g. 2700K")
+	}
+	return kelvin, nil
+}
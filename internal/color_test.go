@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected rgbColor
+	}{
+		{"#ff8800", rgbColor{255, 136, 0}},
+		{"FF8800", rgbColor{255, 136, 0}},
+		{"red", rgbColor{255, 0, 0}},
+		{" Green ", rgbColor{0, 128, 0}},
+		{"rebeccapurple", rgbColor{102, 51, 153}},
+		{"Warm White", rgbColor{255, 169, 87}},
+	}
+
+	for _, tt := range tests {
+		c, err := parseColor(tt.input)
+		if err != nil {
+			t.Errorf("parseColor(%q) should not fail: %v", tt.input, err)
+			continue
+		}
+		if c != tt.expected {
+			t.Errorf("parseColor(%q) = %v, expected %v", tt.input, c, tt.expected)
+		}
+	}
+}
+
+func TestParseColorInvalid(t *testing.T) {
+	for _, input := range []string{"", "#ff88", "#gggggg", "notacolor"} {
+		if _, err := parseColor(input); err == nil {
+			t.Errorf("parseColor(%q) should fail", input)
+		}
+	}
+}
+
+func TestColorHSB(t *testing.T) {
+	tests := []struct {
+		color         rgbColor
+		hue, sat, bri int
+	}{
+		{rgbColor{255, 0, 0}, 0, 100, 100},
+		{rgbColor{0, 255, 0}, 120, 100, 100},
+		{rgbColor{0, 0, 255}, 240, 100, 100},
+		{rgbColor{255, 255, 255}, 0, 0, 100},
+		{rgbColor{0, 0, 0}, 0, 0, 0},
+		{rgbColor{128, 0, 128}, 300, 100, 50},
+	}
+
+	for _, tt := range tests {
+		hue, sat, bri := tt.color.hsb()
+		if hue != tt.hue || sat != tt.sat || bri != tt.bri {
+			t.Errorf("%v.hsb() = (%d, %d, %d), expected (%d, %d, %d)", tt.color, hue, sat, bri, tt.hue, tt.sat, tt.bri)
+		}
+	}
+}
+
+func TestHSBToRGB(t *testing.T) {
+	for _, c := range []rgbColor{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}, {255, 255, 255}, {0, 0, 0}, {255, 136, 0}} {
+		hue, sat, bri := c.hsb()
+		got := hsbToRGB(hue, sat, bri)
+		if absDiff(got.R, c.R) > 3 || absDiff(got.G, c.G) > 3 || absDiff(got.B, c.B) > 3 {
+			t.Errorf("hsbToRGB(%d, %d, %d) = %v, expected about %v", hue, sat, bri, got, c)
+		}
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestParseColorSuggestions(t *testing.T) {
+	_, err := parseColor("tael")
+	if err == nil || !strings.Contains(err.Error(), "teal") {
+		t.Errorf("expected a suggestion for teal, got %v", err)
+	}
+
+	_, err = parseColor("rebeccapurpel")
+	if err == nil || !strings.Contains(err.Error(), "rebeccapurple") {
+		t.Errorf("expected a suggestion for rebeccapurple, got %v", err)
+	}
+
+	if suggestions := suggestColorNames("zzzzzzzzzz"); len(suggestions) != 0 {
+		t.Errorf("expected no suggestions, got %v", suggestions)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"teal", "teal", 0},
+		{"tael", "teal", 2},
+		{"gren", "green", 1},
+		{"", "red", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.expected {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// colorMode is how many colors the terminal can show
+type colorMode int
+
+const (
+	colorNone colorMode = iota
+	color16
+	color256
+	colorTrue
+)
+
+// activeColorMode is the mode the UI styles are rendered in
+var activeColorMode = colorTrue
+
+// detectColorMode reads the color support of the terminal from the
+// environment. NO_COLOR (https://no-color.org) turns colors off entirely.
+func detectColorMode(getenv func(string) string) colorMode {
+	if getenv("NO_COLOR") != "" {
+		return colorNone
+	}
+	term := getenv("TERM")
+	colorTerm := strings.ToLower(getenv("COLORTERM"))
+	switch {
+	case term == "dumb":
+		return colorNone
+	case colorTerm == "truecolor" || colorTerm == "24bit", strings.HasSuffix(term, "-direct"):
+		return colorTrue
+	// Windows Terminal supports true color but sets no TERM
+	case getenv("WT_SESSION") != "":
+		return colorTrue
+	case strings.Contains(term, "256color"):
+		return color256
+	}
+	return color16
+}
+
+// applyColorMode renders every style in mode, mapping the theme colors to
+// their hand-picked 256 or 16 color equivalents on smaller palettes
+func applyColorMode(mode colorMode) {
+	activeColorMode = mode
+	switch mode {
+	case colorNone:
+		lipgloss.SetColorProfile(termenv.Ascii)
+	case color16:
+		lipgloss.SetColorProfile(termenv.ANSI)
+	case color256:
+		lipgloss.SetColorProfile(termenv.ANSI256)
+	default:
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	}
+}
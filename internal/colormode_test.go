@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectColorMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected colorMode
+	}{
+		{"no color", map[string]string{"NO_COLOR": "1", "COLORTERM": "truecolor"}, colorNone},
+		{"dumb terminal", map[string]string{"TERM": "dumb"}, colorNone},
+		{"true color", map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"}, colorTrue},
+		{"direct color terminfo", map[string]string{"TERM": "xterm-direct"}, colorTrue},
+		{"windows terminal", map[string]string{"WT_SESSION": "abc"}, colorTrue},
+		{"256 colors", map[string]string{"TERM": "screen-256color"}, color256},
+		{"16 colors", map[string]string{"TERM": "xterm"}, color16},
+		{"linux console", map[string]string{"TERM": "linux"}, color16},
+	}
+	for _, tt := range tests {
+		getenv := func(key string) string { return tt.env[key] }
+		if got := detectColorMode(getenv); got != tt.expected {
+			t.Errorf("%s: expected mode %d, got %d", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestColorModeDegradesThemeColors(t *testing.T) {
+	defer func() {
+		applyColorMode(colorTrue)
+		applyTheme("")
+	}()
+
+	applyColorMode(color16)
+	applyTheme("")
+	if got := separatorStyle.Render("x"); !strings.Contains(got, "\x1b[93m") {
+		t.Errorf("expected the bright yellow of the 16 color palette, got %q", got)
+	}
+
+	applyColorMode(colorNone)
+	applyTheme("")
+	if got := separatorStyle.Render("x"); got != "x" {
+		t.Errorf("expected no escape codes without colors, got %q", got)
+	}
+	// The selection must stay visible without a background color
+	if got := selectedStyle.Render("Turn On"); !strings.HasPrefix(got, selectedSymbol) {
+		t.Errorf("expected the selection to be marked with %s, got %q", selectedSymbol, got)
+	}
+}
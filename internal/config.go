@@ -1,38 +1,223 @@
 package internal
 
 import (
-	"encoding/json"
 	"errors"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
-type Config struct {
-	IP    string `json:"ip"`
+// PairedDevice holds the credentials and display name of a paired device
+type PairedDevice struct {
 	Token string `json:"token"`
+	Name  string `json:"name,omitempty"`
+	// RecentEffects holds the effects last applied, newest first
+	RecentEffects   []string `json:"recentEffects,omitempty"`
+	FavoriteEffects []string `json:"favoriteEffects,omitempty"`
+	// HTTP overrides the top-level http settings for this device
+	HTTP *HTTPSettings `json:"http,omitempty"`
+}
+
+// Config holds the active device in IP and Token; Devices keeps every
+// paired device by IP so several can be set up at once
+type Config struct {
+	IP         string                  `json:"ip"`
+	Token      string                  `json:"token"`
+	Devices    map[string]PairedDevice `json:"devices,omitempty"`
+	GalleryURL string                  `json:"galleryUrl,omitempty"`
+	Theme      string                  `json:"theme,omitempty"`
+	StreamFPS  int                     `json:"streamFps,omitempty"`
+	// StreamTransport is auto, udp or rest, see StartStream
+	StreamTransport string `json:"streamTransport,omitempty"`
+	// StreamExit is restore, keep, off or an effect name, see streamExit
+	StreamExit string       `json:"streamExit,omitempty"`
+	Audio      *AudioConfig `json:"audio,omitempty"`
+	Music      *MusicConfig `json:"music,omitempty"`
+	// Photosensitive turns off strobe and flash effects everywhere
+	Photosensitive bool                `json:"photosensitive,omitempty"`
+	Presets        map[string]Preset   `json:"presets,omitempty"`
+	Macros         map[string][]string `json:"macros,omitempty"`
+	QuietHours     *QuietHours         `json:"quietHours,omitempty"`
+	// Timezone is the zone of the quiet hours and holidays that do not set
+	// one, the zone of this computer when empty
+	Timezone string `json:"timezone,omitempty"`
+	// ManualOverride is how long automations leave a device alone after
+	// it was changed by hand, e.g. 1h
+	ManualOverride string `json:"manualOverride,omitempty"`
+	// Priorities overrides the priority of automation commands by name,
+	// and PriorityHold how long a change holds lower priorities, see
+	// priority
+	Priorities     map[string]string `json:"priorities,omitempty"`
+	PriorityHold   string            `json:"priorityHold,omitempty"`
+	Holidays       *HolidayConfig    `json:"holidays,omitempty"`
+	Serve          *ServeConfig      `json:"serve,omitempty"`
+	OBS            *OBSConfig        `json:"obs,omitempty"`
+	Calendars      []CalendarSource  `json:"calendars,omitempty"`
+	ErrorSummaries *ErrorSummaries   `json:"errorSummaries,omitempty"`
+	// GlobalKeys maps keys such as "ctrl+alt+up" to actions for bind-keys
+	GlobalKeys map[string]string `json:"globalKeys,omitempty"`
+
+	BrightnessCorrection *BrightnessCorrection `json:"brightnessCorrection,omitempty"`
+	LayoutTransform      *LayoutTransform      `json:"layoutTransform,omitempty"`
+	// Canvas places every paired device on one shared plane, by IP
+	Canvas map[string]CanvasPlacement `json:"canvas,omitempty"`
+	// Groups names sets of paired devices, each given by IP or name, that
+	// the device pane turns on and off together
+	Groups map[string][]string `json:"groups,omitempty"`
+	HTTP   *HTTPSettings       `json:"http,omitempty"`
+
+	// secrets are the cmd: references the secrets were read through, so
+	// saving writes the references back rather than the secrets
+	secrets map[string]secretRef
+}
+
+// displayName returns the name given to the device at ip, or ip itself
+func (c Config) displayName(ip string) string {
+	if name := c.Devices[ip].Name; name != "" {
+		return name
+	}
+	return ip
 }
 
 func getConfigPath() string {
-	homeDir, _ := os.UserHomeDir()
-	return filepath.Join(homeDir, ".nanoleaf_config.json")
+	return findConfigFile(profilePath(".nanoleaf_config", ""))
+}
+
+func getConfigLockPath() string {
+	return profilePath(".nanoleaf_config", ".lock")
+}
+
+// configMu serializes config access within the process, and the lock file
+// between processes, e.g. serve, the UI and a CLI command at once
+var configMu sync.Mutex
+
+// withConfigLock runs fn holding the config locks, exclusive for writes.
+// Without a lock file, e.g. in a read-only home, fn runs with the
+// in-process lock only.
+func withConfigLock(exclusive bool, fn func() error) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	f, err := os.OpenFile(getConfigLockPath(), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fn()
+	}
+	defer f.Close()
+	if err := lockFile(f, exclusive); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+	return fn()
+}
+
+// configStamp identifies a version of the config file, to tell whether
+// another process saved it. It is zero when there is no file.
+type configStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func readConfigStamp() configStamp {
+	info, err := os.Stat(getConfigPath())
+	if err != nil {
+		return configStamp{}
+	}
+	return configStamp{modTime: info.ModTime(), size: info.Size()}
 }
 
-func saveConfig(ip, token string) error {
-	config := Config{IP: ip, Token: token}
-	data, err := json.MarshalIndent(config, "", "  ")
+// saveConfig replaces the config file in one step, after keeping a backup
+// of the previous one, so a crash while saving never leaves a partial file
+func saveConfig(config Config) error {
+	return withConfigLock(true, func() error {
+		return writeConfig(config)
+	})
+}
+
+// writeConfig is saveConfig for callers holding the config lock
+func writeConfig(config Config) error {
+	previous, _ := os.ReadFile(getConfigPath())
+	data, err := encodeConfig(getConfigPath(), config, previous)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(getConfigPath(), data, 0600)
+	// Losing a backup is better than losing the change
+	backupConfig(false, time.Now())
+	return writeFileAtomic(getConfigPath(), data, 0600)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path, which readers then see either whole or not at all
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func loadConfig() (Config, error) {
+	config, _, err := loadConfigStamped()
+	return config, err
+}
+
+// loadConfigStamped reads the config together with the stamp of the file
+// it was read from. The secrets are resolved after the lock is released,
+// since a cmd: reference may wait up to a minute on a password manager and
+// every other process would wait on the lock meanwhile.
+func loadConfigStamped() (Config, configStamp, error) {
 	var config Config
-	data, err := os.ReadFile(getConfigPath())
+	var stamp configStamp
+	err := withConfigLock(false, func() error {
+		var err error
+		stamp = readConfigStamp()
+		config, err = readConfigFile(getConfigPath())
+		return err
+	})
+	if err != nil {
+		return config, stamp, err
+	}
+	err = config.resolveSecrets()
+	return config, stamp, err
+}
+
+// loadConfigFile reads the config at path and resolves its secrets
+func loadConfigFile(path string) (Config, error) {
+	config, err := readConfigFile(path)
+	if err != nil {
+		return config, err
+	}
+	err = config.resolveSecrets()
+	return config, err
+}
+
+// readConfigFile reads the config at path with its cmd: references left
+// as they are
+func readConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	config, err := decodeConfig(path, data)
 	if err != nil {
 		return config, err
 	}
-	err = json.Unmarshal(data, &config)
+	err = config.validateHTTP()
 	return config, err
 }
 
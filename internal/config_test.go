@@ -26,7 +26,7 @@ func TestSaveAndLoadConfig(t *testing.T) {
 	testIP := "192.168.1.100"
 	testToken := "test-token-123"
 
-	err := saveConfig(testIP, testToken)
+	err := saveConfig(Config{IP: testIP, Token: testToken})
 	if err != nil {
 		t.Fatalf("failed to save config: %v", err)
 	}
@@ -66,7 +66,7 @@ func TestConfigExists(t *testing.T) {
 		t.Error("config should not exist initially")
 	}
 
-	err := saveConfig("test", "test")
+	err := saveConfig(Config{IP: "test", Token: "test"})
 	if err != nil {
 		t.Fatalf("failed to save config: %v", err)
 	}
@@ -88,7 +88,7 @@ func TestSaveConfigInvalidJSON(t *testing.T) {
 	os.Setenv("HOME", tempDir)
 	defer os.Setenv("HOME", originalHome)
 
-	err = saveConfig(config.IP, config.Token)
+	err = saveConfig(config)
 	if err != nil {
 		t.Fatalf("save config should not fail: %v", err)
 	}
@@ -102,3 +102,33 @@ func TestSaveConfigInvalidJSON(t *testing.T) {
 		t.Error("saved data does not match expected JSON")
 	}
 }
+
+func TestSaveAndLoadConfigPresets(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	brightness := 100
+	err := saveConfig(Config{
+		IP:      "192.168.1.100",
+		Token:   "test-token",
+		Presets: map[string]Preset{"1": {Name: "Daylight", Color: "daylight", Brightness: &brightness}},
+	})
+	if err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	preset, ok := config.Presets["1"]
+	if !ok {
+		t.Fatal("expected preset 1 to be loaded")
+	}
+	if preset.Name != "Daylight" || preset.Brightness == nil || *preset.Brightness != 100 {
+		t.Errorf("unexpected preset %+v", preset)
+	}
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// configBackups is how many backups of the config are kept
+	configBackups = 5
+	// configBackupEvery spaces out the backups, the config is saved on
+	// every favorite or recent effect and would rotate good backups out
+	configBackupEvery = time.Hour
+	// configBackupLayout names backups by the time they were taken
+	configBackupLayout = "20060102-150405"
+)
+
+func getConfigBackupDir() string {
+	return profilePath(".nanoleaf_config_backups", "")
+}
+
+// configBackup is a saved copy of the config file
+type configBackup struct {
+	path  string
+	taken time.Time
+}
+
+// listConfigBackups returns the backups, newest first
+func listConfigBackups() ([]configBackup, error) {
+	entries, err := os.ReadDir(getConfigBackupDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []configBackup
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		stamp, ok := strings.CutPrefix(strings.TrimSuffix(entry.Name(), ext), "config-")
+		taken, err := time.ParseInLocation(configBackupLayout, stamp, time.Local)
+		if !ok || err != nil || !slices.Contains(configExts, ext) {
+			continue
+		}
+		backups = append(backups, configBackup{path: filepath.Join(getConfigBackupDir(), entry.Name()), taken: taken})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].taken.After(backups[j].taken) })
+	return backups, nil
+}
+
+// backupConfig copies the config file into the backups, unless the newest
+// backup is recent and force is not set, and drops the oldest backups
+// beyond configBackups. A config file that does not parse is not backed up
+// so it cannot rotate good backups out.
+func backupConfig(force bool, now time.Time) error {
+	configPath := getConfigPath()
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := decodeConfig(configPath, data); err != nil {
+		return nil
+	}
+
+	backups, err := listConfigBackups()
+	if err != nil {
+		return err
+	}
+	if !force && len(backups) > 0 && now.Sub(backups[0].taken) < configBackupEvery {
+		return nil
+	}
+	if err := os.MkdirAll(getConfigBackupDir(), 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(getConfigBackupDir(), "config-"+now.Format(configBackupLayout)+filepath.Ext(configPath))
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return err
+	}
+
+	backups, err = listConfigBackups()
+	if err != nil {
+		return err
+	}
+	for _, old := range backups[min(len(backups), configBackups):] {
+		os.Remove(old.path)
+	}
+	return nil
+}
+
+// restoreConfigBackup puts a backup back in place of the config, keeping
+// the current config as a backup so the restore can be undone. A backup
+// taken before the config changed format is converted to the current one.
+func restoreConfigBackup(backup configBackup, now time.Time) error {
+	data, err := os.ReadFile(backup.path)
+	if err != nil {
+		return err
+	}
+	config, err := decodeConfig(backup.path, data)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid config: %w", backup.path, err)
+	}
+	return withConfigLock(true, func() error {
+		if err := backupConfig(true, now); err != nil {
+			return err
+		}
+		configPath := getConfigPath()
+		if !strings.EqualFold(filepath.Ext(configPath), filepath.Ext(backup.path)) {
+			if data, err = encodeConfig(configPath, config, nil); err != nil {
+				return err
+			}
+		}
+		return writeFileAtomic(configPath, data, 0600)
+	})
+}
+
+// pairedDevices counts the devices paired in the backup. The cmd:
+// references of its tokens are counted as they are, listing the backups
+// runs none of them.
+func (b configBackup) pairedDevices() int {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return 0
+	}
+	config, _ := decodeConfig(b.path, data)
+	return len(config.pairedDevices())
+}
+
+func runConfigCommand(ctx context.Context, args []string) error {
+	const usage = "config backups | config restore-backup [N] | config convert json|yaml|toml"
+	if len(args) == 2 && args[0] == "convert" {
+		switch args[1] {
+		case "json", "yaml", "toml":
+		default:
+			return usageError(usage)
+		}
+		path, err := convertConfig("." + args[1])
+		if err != nil {
+			return err
+		}
+		statusf("The config is now %s, the previous file was kept as a backup\n", path)
+		return nil
+	}
+	if len(args) == 0 || (args[0] != "backups" && args[0] != "restore-backup") || len(args) > 2 || (args[0] == "backups" && len(args) > 1) {
+		return usageError(usage)
+	}
+
+	backups, err := listConfigBackups()
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("no config backups in %s yet", getConfigBackupDir())
+	}
+
+	if args[0] == "backups" {
+		for i, backup := range backups {
+			fmt.Printf("%d  %s  %d paired device(s)\n", i+1, backup.taken.Format("2006-01-02 15:04:05"), backup.pairedDevices())
+		}
+		return nil
+	}
+
+	n := 1
+	if len(args) == 2 {
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(backups) {
+			return invalidArgs(fmt.Errorf("backup must be a number from 1 to %d, see config backups", len(backups)))
+		}
+	}
+	backup := backups[n-1]
+	if err := restoreConfigBackup(backup, time.Now()); err != nil {
+		return err
+	}
+	statusf("Restored the config from %s, the replaced config was kept as a backup\n", backup.taken.Format("2006-01-02 15:04:05"))
+	return nil
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigBackupRotation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := backupConfig(true, time.Now()); err != nil {
+		t.Fatalf("backing up a missing config should do nothing: %v", err)
+	}
+
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	for i := 0; i < 8; i++ {
+		if err := writeFileAtomic(getConfigPath(), []byte(`{"ip":"10.0.0.1","token":"t"}`), 0600); err != nil {
+			t.Fatalf("writeFileAtomic should not fail: %v", err)
+		}
+		if err := backupConfig(false, now); err != nil {
+			t.Fatalf("backupConfig should not fail: %v", err)
+		}
+		// A second save within the hour is not backed up
+		backupConfig(false, now.Add(10*time.Minute))
+		now = now.Add(time.Hour)
+	}
+
+	backups, err := listConfigBackups()
+	if err != nil {
+		t.Fatalf("listConfigBackups should not fail: %v", err)
+	}
+	if len(backups) != configBackups {
+		t.Fatalf("expected %d backups, got %d", configBackups, len(backups))
+	}
+	if want := time.Date(2024, 3, 1, 16, 0, 0, 0, time.Local); !backups[0].taken.Equal(want) {
+		t.Errorf("expected the newest backup first, got %s", backups[0].taken)
+	}
+
+	os.WriteFile(getConfigPath(), []byte(`{"ip":`), 0600)
+	if err := backupConfig(true, now); err != nil {
+		t.Fatalf("backupConfig should not fail: %v", err)
+	}
+	if again, _ := listConfigBackups(); again[0].taken.Equal(now) {
+		t.Error("expected a config that does not parse not to be backed up")
+	}
+}
+
+func TestRestoreConfigBackup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	taken := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	writeFileAtomic(getConfigPath(), []byte(`{"ip":"10.0.0.1","token":"good"}`), 0600)
+	backupConfig(true, taken)
+	writeFileAtomic(getConfigPath(), []byte(`{"ip":"10.0.0.1","token":""}`), 0600)
+
+	backups, _ := listConfigBackups()
+	if err := restoreConfigBackup(backups[0], taken.Add(time.Hour)); err != nil {
+		t.Fatalf("restoreConfigBackup should not fail: %v", err)
+	}
+	config, err := loadConfig()
+	if err != nil || config.Token != "good" {
+		t.Errorf("expected the token to be back, got %+v, %v", config, err)
+	}
+	backups, _ = listConfigBackups()
+	replaced, _ := loadConfigFile(backups[0].path)
+	if replaced.IP != "10.0.0.1" || replaced.Token != "" {
+		t.Errorf("expected the replaced config to be kept as the newest backup, got %+v", replaced)
+	}
+
+	bad := filepath.Join(getConfigBackupDir(), "config-20240101-000000.json")
+	os.WriteFile(bad, []byte("not json"), 0600)
+	if err := restoreConfigBackup(configBackup{path: bad}, time.Now()); err == nil {
+		t.Error("expected a broken backup to be refused")
+	}
+}
+
+func TestConfigBackupPairedDevices(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	marker := filepath.Join(home, "ran")
+	config := `{"ip":"10.0.0.1","token":"cmd:touch ` + marker + `","devices":{"10.0.0.2":{"token":"t"}}}`
+	writeFileAtomic(getConfigPath(), []byte(config), 0600)
+	backupConfig(true, time.Now())
+
+	backups, _ := listConfigBackups()
+	if n := backups[0].pairedDevices(); n != 2 {
+		t.Errorf("expected 2 paired devices, got %d", n)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("expected listing the backups to run no secret command")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte("old"), 0600)
+	if err := writeFileAtomic(path, []byte("new"), 0600); err != nil {
+		t.Fatalf("writeFileAtomic should not fail: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("expected the file to be replaced, got %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected no temporary files to be left, got %d entries", len(entries))
+	}
+}
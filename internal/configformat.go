@@ -0,0 +1,302 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configExts are the config formats, by extension, in the order a config
+// is looked for when there are several
+var configExts = []string{".json", ".yaml", ".yml", ".toml"}
+
+// findConfigFile returns base with the extension of the first config
+// format that exists, or .json when there is none yet
+func findConfigFile(base string) string {
+	for _, ext := range configExts {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return base + ".json"
+}
+
+// decodeConfig parses a config in the format of the extension of path.
+// YAML and TOML go through the same field names as JSON, so every format
+// reads the same settings.
+func decodeConfig(path string, data []byte) (Config, error) {
+	var config Config
+	var doc interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return config, err
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return config, err
+		}
+	default:
+		err := json.Unmarshal(data, &config)
+		return config, err
+	}
+	if doc == nil {
+		return config, nil
+	}
+	data, err := json.Marshal(plainValue(doc))
+	if err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+// encodeConfig writes config in the format of the extension of path. The
+// comments of previous, the file being replaced, are carried over to the
+// settings that are still there, so saves from the app keep what was
+// written by hand.
+func encodeConfig(path string, config Config, previous []byte) ([]byte, error) {
+	data, err := json.MarshalIndent(config.withSecretRefs(), "", "  ")
+	ext := strings.ToLower(filepath.Ext(path))
+	if err != nil || (ext != ".yaml" && ext != ".yml" && ext != ".toml") {
+		return data, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	doc = plainValue(doc)
+	var b bytes.Buffer
+	if ext == ".toml" {
+		encoder := toml.NewEncoder(&b)
+		encoder.Indent = ""
+		if err := encoder.Encode(doc); err != nil {
+			return nil, err
+		}
+		return keepTOMLComments(previous, b.Bytes()), nil
+	}
+	node := yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{}}}
+	if err := node.Content[0].Encode(doc); err != nil {
+		return nil, err
+	}
+	var old yaml.Node
+	if yaml.Unmarshal(previous, &old) == nil {
+		copyYAMLComments(&old, &node)
+	}
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&node)
+	return b.Bytes(), err
+}
+
+// plainValue turns a decoded document into maps with string keys, which
+// JSON needs, and whole JSON numbers into integers, which TOML tells apart
+// from floats. Null settings are dropped since TOML has none, but a null in
+// a list is kept, as dropping it would move the entries after it.
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		plain := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if value != nil {
+				plain[key] = plainValue(value)
+			}
+		}
+		return plain
+	case map[interface{}]interface{}:
+		plain := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if value != nil {
+				plain[fmt.Sprint(key)] = plainValue(value)
+			}
+		}
+		return plain
+	case []interface{}:
+		plain := make([]interface{}, len(v))
+		for i, value := range v {
+			plain[i] = plainValue(value)
+		}
+		return plain
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// copyYAMLComments copies the comments of from onto to, matching settings
+// by key and list entries by position, and keeps lists and maps that were
+// written inline inline
+func copyYAMLComments(from, to *yaml.Node) {
+	to.HeadComment, to.LineComment, to.FootComment = from.HeadComment, from.LineComment, from.FootComment
+	if from.Kind != to.Kind {
+		return
+	}
+	switch to.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		to.Style = from.Style
+		for i := 0; i < len(from.Content) && i < len(to.Content); i++ {
+			copyYAMLComments(from.Content[i], to.Content[i])
+		}
+	case yaml.MappingNode:
+		to.Style = from.Style
+		for i := 0; i+1 < len(to.Content); i += 2 {
+			for j := 0; j+1 < len(from.Content); j += 2 {
+				if from.Content[j].Value == to.Content[i].Value {
+					copyYAMLComments(from.Content[j], to.Content[i])
+					copyYAMLComments(from.Content[j+1], to.Content[i+1])
+					break
+				}
+			}
+		}
+	}
+}
+
+// tomlComment is what was written around a setting or table of a TOML
+// config: the comment lines above it and the comment after it on its line
+type tomlComment struct {
+	above []string
+	after string
+}
+
+// keepTOMLComments carries the comments of previous over to the settings
+// and tables of data with the same names. The TOML library has no syntax
+// tree, so the files are matched line by line: a setting is its table and
+// key, and the comment lines above a line and after it on the line belong
+// to it. Comments after the last setting stay at the end.
+func keepTOMLComments(previous, data []byte) []byte {
+	if len(previous) == 0 {
+		return data
+	}
+	comments := make(map[string]tomlComment)
+	var block []string
+	ids := tomlLineIDs()
+	for _, line := range strings.Split(string(previous), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#"):
+			block = append(block, line)
+			continue
+		case line == "":
+			if len(block) > 0 {
+				block = append(block, "")
+			}
+			continue
+		}
+		if id := ids(line); id != "" {
+			for len(block) > 0 && block[len(block)-1] == "" {
+				block = block[:len(block)-1]
+			}
+			comments[id] = tomlComment{above: block, after: trailingComment(line)}
+		}
+		block = nil
+	}
+
+	var out []string
+	ids = tomlLineIDs()
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if c, ok := comments[ids(strings.TrimSpace(line))]; ok {
+			out = append(out, c.above...)
+			if c.after != "" {
+				line += " " + c.after
+			}
+		}
+		out = append(out, line)
+	}
+	for len(block) > 0 && block[len(block)-1] == "" {
+		block = block[:len(block)-1]
+	}
+	if len(block) > 0 {
+		out = append(append(out, ""), block...)
+	}
+	return []byte(strings.Join(out, "\n") + "\n")
+}
+
+// tomlLineIDs returns a function naming each line of a TOML file in turn:
+// a table by its name, an array of tables by its name and count, and a
+// setting by its table and key. Other lines get no name.
+func tomlLineIDs() func(line string) string {
+	table := ""
+	tables := make(map[string]int)
+	plain := strings.NewReplacer(`"`, "", "'", "", " ", "", "\t", "")
+	return func(line string) string {
+		if strings.HasPrefix(line, "[[") {
+			name, _, _ := strings.Cut(strings.TrimPrefix(line, "[["), "]]")
+			name = plain.Replace(name)
+			tables[name]++
+			table = fmt.Sprintf("[[%s]]%d", name, tables[name])
+			return table
+		}
+		if strings.HasPrefix(line, "[") {
+			name, _, _ := strings.Cut(strings.TrimPrefix(line, "["), "]")
+			table = "[" + plain.Replace(name) + "]"
+			return table
+		}
+		if key, _, ok := strings.Cut(line, "="); ok && line != "" {
+			return table + " " + plain.Replace(key)
+		}
+		return ""
+	}
+}
+
+// trailingComment returns the comment at the end of a TOML line, skipping
+// a # inside a string
+func trailingComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[i:]
+		}
+	}
+	return ""
+}
+
+// convertConfig rewrites the config in the format of ext, removing the
+// file in the previous format
+func convertConfig(ext string) (string, error) {
+	from := getConfigPath()
+	to := strings.TrimSuffix(from, filepath.Ext(from)) + ext
+	if to == from {
+		return "", fmt.Errorf("%s is already %s", from, strings.TrimPrefix(ext, "."))
+	}
+	err := withConfigLock(true, func() error {
+		config, err := readConfigFile(from)
+		if err != nil {
+			return err
+		}
+		if err := backupConfig(true, time.Now()); err != nil {
+			return err
+		}
+		data, err := encodeConfig(to, config, nil)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(to, data, 0600); err != nil {
+			return err
+		}
+		return os.Remove(from)
+	})
+	return to, err
+}
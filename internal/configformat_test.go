@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeConfigYAML(t *testing.T) {
+	data := []byte(`
+ip: 192.168.1.100
+token: yaml-token
+devices:
+  192.168.1.100:
+    token: yaml-token
+    name: Living Room
+presets:
+  1:
+    effect: Northern Lights
+    brightness: 40
+macros:
+  evening: [on, brightness 30]
+quietHours:
+  start: "22:00"
+  end: 07:00
+`)
+	config, err := decodeConfig("config.yaml", data)
+	if err != nil {
+		t.Fatalf("decodeConfig should not fail: %v", err)
+	}
+	if config.IP != "192.168.1.100" || config.Devices["192.168.1.100"].Name != "Living Room" {
+		t.Errorf("unexpected devices %+v", config)
+	}
+	if preset := config.Presets["1"]; preset.Effect != "Northern Lights" || preset.Brightness == nil || *preset.Brightness != 40 {
+		t.Errorf("numbered presets should be read, got %+v", config.Presets)
+	}
+	if !reflect.DeepEqual(config.Macros["evening"], []string{"on", "brightness 30"}) {
+		t.Errorf("unexpected macros %v", config.Macros)
+	}
+	if config.QuietHours == nil || config.QuietHours.End != "07:00" {
+		t.Errorf("unexpected quiet hours %+v", config.QuietHours)
+	}
+}
+
+func TestDecodeConfigTOML(t *testing.T) {
+	data := []byte(`
+ip = "192.168.1.100"
+token = "toml-token"
+
+[presets.1]
+color = "#FF0000"
+transition = 2.5
+
+[serve]
+listen = ":9000"
+
+[[serve.presence]]
+event = "home"
+actions = ["on"]
+`)
+	config, err := decodeConfig("config.toml", data)
+	if err != nil {
+		t.Fatalf("decodeConfig should not fail: %v", err)
+	}
+	if config.Token != "toml-token" || config.Presets["1"].Transition != 2.5 {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if config.Serve == nil || len(config.Serve.Presence) != 1 || config.Serve.Presence[0].Actions[0] != "on" {
+		t.Errorf("unexpected serve settings %+v", config.Serve)
+	}
+	if _, err := decodeConfig("config.toml", []byte("ip = ")); err == nil {
+		t.Error("expected an error for invalid TOML")
+	}
+}
+
+func TestEncodeConfigRoundTrip(t *testing.T) {
+	brightness := 40
+	config := Config{
+		IP:        "192.168.1.100",
+		Token:     "test-token",
+		StreamFPS: 30,
+		Devices:   map[string]PairedDevice{"192.168.1.100": {Token: "test-token", Name: "Hexagons"}},
+		Presets:   map[string]Preset{"1": {Effect: "Forest", Brightness: &brightness, Transition: 1.5}},
+		Serve:     &ServeConfig{Presence: []PresenceRule{{Event: "away", Actions: []string{"off"}}}},
+	}
+	for _, path := range []string{"config.json", "config.yaml", "config.toml"} {
+		data, err := encodeConfig(path, config, nil)
+		if err != nil {
+			t.Fatalf("%s: encodeConfig should not fail: %v", path, err)
+		}
+		decoded, err := decodeConfig(path, data)
+		if err != nil {
+			t.Fatalf("%s: decodeConfig should not fail: %v\n%s", path, err, data)
+		}
+		if !reflect.DeepEqual(decoded, config) {
+			t.Errorf("%s: expected %+v, got %+v", path, config, decoded)
+		}
+	}
+}
+
+func TestConfigKeepsItsFormat(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	yamlPath := filepath.Join(home, ".nanoleaf_config.yaml")
+	if err := os.WriteFile(yamlPath, []byte("ip: 192.168.1.100\ntoken: test-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if getConfigPath() != yamlPath {
+		t.Fatalf("expected the YAML config to be found, got %s", getConfigPath())
+	}
+	if err := saveConfig(Config{IP: "192.168.1.100", Token: "new-token"}); err != nil {
+		t.Fatalf("saveConfig should not fail: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".nanoleaf_config.json")); !os.IsNotExist(err) {
+		t.Error("saving should not create a JSON config next to the YAML one")
+	}
+
+	path, err := convertConfig(".toml")
+	if err != nil {
+		t.Fatalf("convertConfig should not fail: %v", err)
+	}
+	if path != filepath.Join(home, ".nanoleaf_config.toml") || getConfigPath() != path {
+		t.Errorf("expected the TOML config to be used, got %s", path)
+	}
+	if _, err := os.Stat(yamlPath); !os.IsNotExist(err) {
+		t.Error("the YAML config should be removed after converting")
+	}
+	config, err := loadConfig()
+	if err != nil || config.Token != "new-token" {
+		t.Errorf("the converted config should load, got %+v, %v", config, err)
+	}
+	if _, err := convertConfig(".toml"); err == nil {
+		t.Error("expected an error converting to the current format")
+	}
+}
+
+func TestEncodeConfigKeepsComments(t *testing.T) {
+	config := Config{
+		IP:     "192.168.1.100",
+		Token:  "new-token",
+		Macros: map[string][]string{"evening": {"on", "brightness 30"}},
+		Serve:  &ServeConfig{Presence: []PresenceRule{{Event: "away", Actions: []string{"off"}}}},
+	}
+
+	yamlFile := []byte(`# Living room panels
+ip: 192.168.1.100 # fixed lease on the router
+token: old-token
+macros:
+  # dimmed for the evening
+  evening: [on, brightness 30]
+`)
+	data, err := encodeConfig("config.yaml", config, yamlFile)
+	if err != nil {
+		t.Fatalf("encodeConfig should not fail: %v", err)
+	}
+	for _, want := range []string{"# Living room panels", "ip: 192.168.1.100 # fixed lease on the router", "  # dimmed for the evening\n  evening: [\"on\", brightness 30]", "token: new-token"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in\n%s", want, data)
+		}
+	}
+
+	tomlFile := []byte(`# Living room panels
+ip = "192.168.1.100" # fixed lease on the router
+token = "old-token"
+
+# presence rules for the phone
+[[serve.presence]]
+event = "away" # "#" is not a comment in a string
+actions = ["off"]
+
+# end of file
+`)
+	data, err = encodeConfig("config.toml", config, tomlFile)
+	if err != nil {
+		t.Fatalf("encodeConfig should not fail: %v", err)
+	}
+	for _, want := range []string{"# Living room panels\nip = \"192.168.1.100\" # fixed lease on the router", "# presence rules for the phone\n[[serve.presence]]", `event = "away" # "#" is not a comment in a string`, `token = "new-token"`, "\n# end of file\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in\n%s", want, data)
+		}
+	}
+	for _, path := range []string{"config.yaml", "config.toml"} {
+		previous := yamlFile
+		if path == "config.toml" {
+			previous = tomlFile
+		}
+		data, _ := encodeConfig(path, config, previous)
+		if decoded, err := decodeConfig(path, data); err != nil || !reflect.DeepEqual(decoded, config) {
+			t.Errorf("%s: expected the commented config to read back, got %+v, %v\n%s", path, decoded, err, data)
+		}
+	}
+}
+
+func TestDecodeConfigKeepsNullListEntries(t *testing.T) {
+	config, err := decodeConfig("config.yaml", []byte("macros:\n  evening: [on, ~, off]\n"))
+	if err != nil {
+		t.Fatalf("decodeConfig should not fail: %v", err)
+	}
+	if !reflect.DeepEqual(config.Macros["evening"], []string{"on", "", "off"}) {
+		t.Errorf("expected the null entry to keep its place, got %q", config.Macros["evening"])
+	}
+}
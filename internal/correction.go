@@ -0,0 +1,58 @@
+package internal
+
+import "math"
+
+// BrightnessCorrection scales streamed panel colors to even out differences
+// between panel types and to soften panels near eye level
+type BrightnessCorrection struct {
+	// ShapeScale multiplies the output of panels by shape type (e.g. 7 for
+	// Hexagons, 8 for Triangles)
+	ShapeScale map[int]float64 `json:"shapeScale,omitempty"`
+	// EyeLevel is the height, from 0 (bottom of the layout) to 1 (top),
+	// where panels are dimmed the most
+	EyeLevel *float64 `json:"eyeLevel,omitempty"`
+	// EyeLevelDim is the fraction of brightness removed at eye level
+	EyeLevelDim float64 `json:"eyeLevelDim,omitempty"`
+	// EyeLevelSpread is the height over which dimming fades out (default 0.25)
+	EyeLevelSpread float64 `json:"eyeLevelSpread,omitempty"`
+}
+
+// factors computes the brightness multiplier of every panel in the layout.
+// A nil correction leaves all panels unchanged.
+func (c *BrightnessCorrection) factors(layout Layout) map[int]float64 {
+	if c == nil {
+		return nil
+	}
+
+	spread := c.EyeLevelSpread
+	if spread <= 0 {
+		spread = 0.25
+	}
+
+	factors := make(map[int]float64, len(layout.Panels))
+	for _, p := range layout.LightPanels() {
+		factor := 1.0
+		if scale, ok := c.ShapeScale[p.ShapeType]; ok {
+			factor = scale
+		}
+		if c.EyeLevel != nil && c.EyeLevelDim > 0 {
+			_, y := layout.Normalized(p)
+			closeness := math.Max(0, 1-math.Abs(y-*c.EyeLevel)/spread)
+			factor *= 1 - c.EyeLevelDim*closeness
+		}
+		factors[p.ID] = math.Max(0, math.Min(1, factor))
+	}
+	return factors
+}
+
+// correctFrame scales each panel color in place by its factor
+func correctFrame(frame []PanelColor, factors map[int]float64) {
+	for i, pc := range frame {
+		factor, ok := factors[pc.PanelID]
+		if !ok || factor == 1 {
+			continue
+		}
+		c := scaleColor(rgbColor{pc.R, pc.G, pc.B}, factor)
+		frame[i].R, frame[i].G, frame[i].B = c.R, c.G, c.B
+	}
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCorrectionFactorsShapeScale(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 1, ShapeType: 7},
+		{ID: 2, ShapeType: 8},
+	}}
+	correction := &BrightnessCorrection{ShapeScale: map[int]float64{7: 0.8}}
+
+	factors := correction.factors(layout)
+	if factors[1] != 0.8 {
+		t.Errorf("expected hexagon factor 0.8, got %v", factors[1])
+	}
+	if factors[2] != 1 {
+		t.Errorf("expected triangle factor 1, got %v", factors[2])
+	}
+}
+
+func TestCorrectionFactorsEyeLevel(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 1, Y: 0, ShapeType: 7},
+		{ID: 2, Y: 50, ShapeType: 7},
+		{ID: 3, Y: 100, ShapeType: 7},
+	}}
+	eyeLevel := 0.5
+	correction := &BrightnessCorrection{EyeLevel: &eyeLevel, EyeLevelDim: 0.4}
+
+	factors := correction.factors(layout)
+	if math.Abs(factors[2]-0.6) > 1e-9 {
+		t.Errorf("expected the eye level panel dimmed to 0.6, got %v", factors[2])
+	}
+	if factors[1] != 1 || factors[3] != 1 {
+		t.Errorf("expected panels outside the spread untouched, got %v and %v", factors[1], factors[3])
+	}
+}
+
+func TestCorrectFrame(t *testing.T) {
+	frame := []PanelColor{
+		{PanelID: 1, R: 200, G: 100, B: 50},
+		{PanelID: 2, R: 200, G: 100, B: 50},
+	}
+	correctFrame(frame, map[int]float64{1: 0.5})
+
+	if frame[0].R != 100 || frame[0].G != 50 || frame[0].B != 25 {
+		t.Errorf("expected panel 1 at half brightness, got %+v", frame[0])
+	}
+	if frame[1].R != 200 {
+		t.Errorf("expected panel 2 unchanged, got %+v", frame[1])
+	}
+}
+
+func TestCorrectionFactorsNil(t *testing.T) {
+	var correction *BrightnessCorrection
+	if factors := correction.factors(Layout{Panels: []Panel{{ID: 1}}}); factors != nil {
+		t.Errorf("expected no factors without a correction, got %v", factors)
+	}
+}
@@ -3,82 +3,595 @@ package internal
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
 // Device handles all device operations
 type Device struct {
 	client *NanoleafClient
-	config Config
+	// configMu guards config and stamp. The UI replaces the config from
+	// command goroutines, e.g. when an effect is remembered, while it and
+	// the transport read it elsewhere, so config is only read through
+	// GetConfig.
+	configMu sync.RWMutex
+	config   Config
+	// transport applies the http settings of the config to the client
+	transport *deviceTransport
+
+	capsMu sync.Mutex
+	caps   *Capabilities
+
+	// record enables the usage and command history logs; it is set once a
+	// saved config is loaded so tests and one-off devices leave no files
+	record bool
+	// stamp is the version of the config file last read or written
+	stamp configStamp
+	// changeMu guards when and as whose a change was last recorded, for the
+	// manual override and priorities, and whose changes are being made
+	changeMu       sync.Mutex
+	lastChange     time.Time
+	lastSource     string
+	changeSource   string
+	changePriority priority
+
+	// ignoreFirmware silences the firmware warnings, and warn shows
+	// warnings when set
+	ignoreFirmware bool
+	warn           func(message string)
+}
+
+// UnsavedTokenError is returned when pairing worked but the token could not
+// be written to the config. The device is usable until the program exits;
+// Token lets the caller show it so it can be kept elsewhere.
+type UnsavedTokenError struct {
+	IP    string
+	Token string
+	Err   error
+}
+
+func (e *UnsavedTokenError) Error() string {
+	return fmt.Sprintf("paired but failed to save the token: %v", e.Err)
+}
+
+func (e *UnsavedTokenError) Unwrap() error {
+	return e.Err
 }
 
 func NewDevice() *Device {
-	return &Device{
-		client: newClient(),
-	}
+	d := &Device{client: newClient()}
+	d.transport = newDeviceTransport(apiTransport, func(host string) HTTPSettings {
+		return d.GetConfig().httpSettings(host)
+	})
+	d.transport.change = d.noteChange
+	d.client.httpClient.Transport = d.transport
+	return d
+}
+
+// HTTPMetrics returns the request counts and times by device address since
+// the device was created
+func (d *Device) HTTPMetrics() map[string]HostMetrics {
+	return d.transport.metrics.snapshot()
 }
 
 func (d *Device) LoadConfig() error {
 	if !configExists() {
 		return fmt.Errorf("no config found")
 	}
-	config, err := loadConfig()
+	config, stamp, err := loadConfigStamped()
 	if err != nil {
 		return err
 	}
-	d.config = config
+	d.setConfig(config, stamp)
+	d.record = true
 	return nil
 }
 
+// ReloadConfig reads the config again when another process, such as a CLI
+// command, saved it since this device last read or wrote it, and reports
+// whether it did. Devices that were not loaded from the config are left
+// alone.
+func (d *Device) ReloadConfig() (bool, error) {
+	before := d.configStamp()
+	if !d.record || readConfigStamp() == before {
+		return false, nil
+	}
+	config, stamp, err := loadConfigStamped()
+	if err != nil {
+		return false, err
+	}
+	// updateConfig may have saved and applied a newer config meanwhile
+	if d.configStamp() != before {
+		return false, nil
+	}
+	d.setConfig(config, stamp)
+	return true, nil
+}
+
+// updateConfig applies change to the config and saves it under the config
+// lock. When another process saved the config since this device read it,
+// change is applied to the newer config so its edits are not overwritten.
+// The change is kept in memory even when saving fails. A newer config is
+// read before taking the lock, so its secrets are resolved outside it;
+// only a save that lands in between is read under the lock.
+func (d *Device) updateConfig(change func(config *Config)) error {
+	d.ReloadConfig()
+	return withConfigLock(true, func() error {
+		config := d.GetConfig()
+		if readConfigStamp() != d.configStamp() {
+			if fresh, err := loadConfigFile(getConfigPath()); err == nil {
+				config = fresh
+			}
+		}
+		change(&config)
+		err := writeConfig(config)
+		d.setConfig(config, readConfigStamp())
+		return err
+	})
+}
+
+// setConfig replaces the config, forgetting the capabilities when the
+// active device changed
+func (d *Device) setConfig(config Config, stamp configStamp) {
+	d.configMu.Lock()
+	changed := config.IP != d.config.IP
+	d.config, d.stamp = config, stamp
+	d.configMu.Unlock()
+	if changed {
+		d.forgetCapabilities()
+	}
+}
+
+// configStamp returns the stamp of the config last read or written
+func (d *Device) configStamp() configStamp {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.stamp
+}
+
+// forgetCapabilities drops the cached capabilities, which belong to the
+// active device
+func (d *Device) forgetCapabilities() {
+	d.capsMu.Lock()
+	d.caps = nil
+	d.capsMu.Unlock()
+}
+
 func (d *Device) IsDeviceReady(ctx context.Context) bool {
-	if d.config.IP == "" || d.config.Token == "" {
+	if d.GetConfig().IP == "" || d.GetConfig().Token == "" {
 		return false
 	}
-	_, err := d.client.getInfo(ctx, d.config.IP, d.config.Token)
+	_, err := d.client.getPower(ctx, d.GetConfig().IP, d.GetConfig().Token)
 	return err == nil
 }
 
-func (d *Device) ScanForDevices(ctx context.Context) ([]string, error) {
-	return scanForDevices(ctx)
+// Ping measures the round trip of a minimal authenticated request
+func (d *Device) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	_, err := d.client.getPower(ctx, d.GetConfig().IP, d.GetConfig().Token)
+	return time.Since(start), err
+}
+
+// ScanForDevices looks for devices on the local networks, calling found,
+// if set, as each one answers
+func (d *Device) ScanForDevices(ctx context.Context, found func(ip string)) ([]string, error) {
+	return scanForDevices(ctx, scanOptions{Found: found})
 }
 
 func (d *Device) SetDevice(ip string) {
+	d.configMu.Lock()
 	d.config.IP = ip
+	d.configMu.Unlock()
+	d.forgetCapabilities()
 }
 
 func (d *Device) PairDevice(ctx context.Context) error {
-	if d.config.IP == "" {
+	if d.GetConfig().IP == "" {
 		return fmt.Errorf("no device IP set")
 	}
 
-	token, err := d.client.pair(ctx, d.config.IP)
+	token, err := d.client.pair(ctx, d.GetConfig().IP)
 	if err != nil {
 		return err
 	}
 
+	d.configMu.Lock()
 	d.config.Token = token
-	return saveConfig(d.config.IP, d.config.Token)
+	ip := d.config.IP
+	d.configMu.Unlock()
+	d.forgetCapabilities()
+	return d.savePaired(ip, token, true)
+}
+
+// PairAnother pairs the device at ip and stores its token without changing
+// the active device, unless there is none yet
+func (d *Device) PairAnother(ctx context.Context, ip string) error {
+	token, err := d.client.pair(ctx, ip)
+	if err != nil {
+		return err
+	}
+	return d.addPaired(ip, token)
+}
+
+// ImportPairing stores a token obtained by another app after checking that
+// the device at ip accepts it, so no pairing button has to be pressed
+func (d *Device) ImportPairing(ctx context.Context, ip, token string) error {
+	if _, err := d.client.getPower(ctx, ip, token); err != nil {
+		return err
+	}
+	return d.addPaired(ip, token)
+}
+
+// addPaired stores a token, making the device active if none is paired yet
+func (d *Device) addPaired(ip, token string) error {
+	return d.savePaired(ip, token, false)
+}
+
+// Rename gives the device at ip a display name, or clears it when name is
+// empty. The device API has no writable name, so it is kept in the config.
+func (d *Device) Rename(ip, name string) error {
+	if ip == "" {
+		return fmt.Errorf("no device IP set")
+	}
+	return d.updatePaired(ip, func(paired *PairedDevice) {
+		paired.Name = strings.TrimSpace(name)
+	})
+}
+
+// GetDeviceName returns the display name of the active device, or its IP
+func (d *Device) GetDeviceName() string {
+	return d.GetConfig().displayName(d.GetConfig().IP)
+}
+
+// savePaired stores the token of the device at ip, making it the active
+// device when activate is set or no device is active yet
+func (d *Device) savePaired(ip, token string, activate bool) error {
+	err := d.updateConfig(func(config *Config) {
+		if activate || config.Token == "" {
+			config.IP, config.Token = ip, token
+		}
+		devices := copyDevices(config.Devices)
+		paired := devices[ip]
+		paired.Token = token
+		devices[ip] = paired
+		config.Devices = devices
+	})
+	if err != nil {
+		return &UnsavedTokenError{IP: ip, Token: token, Err: err}
+	}
+	return nil
+}
+
+// movePaired moves the pairing of the device at from to its new address,
+// along with its place on the canvas and in groups
+func (d *Device) movePaired(from, to string) error {
+	return d.updateConfig(func(config *Config) {
+		if config.IP == from {
+			config.IP = to
+		}
+		devices := copyDevices(config.Devices)
+		if paired, ok := devices[from]; ok {
+			devices[to] = paired
+			delete(devices, from)
+		}
+		config.Devices = devices
+		if placement, ok := config.Canvas[from]; ok {
+			canvas := make(map[string]CanvasPlacement, len(config.Canvas))
+			for ip, p := range config.Canvas {
+				canvas[ip] = p
+			}
+			canvas[to] = placement
+			delete(canvas, from)
+			config.Canvas = canvas
+		}
+		groups := make(map[string][]string, len(config.Groups))
+		for name, members := range config.Groups {
+			moved := append([]string{}, members...)
+			for i, member := range moved {
+				if member == from {
+					moved[i] = to
+				}
+			}
+			groups[name] = moved
+		}
+		if config.Groups != nil {
+			config.Groups = groups
+		}
+	})
+}
+
+// copyDevices copies the paired devices before a change. The map is
+// replaced rather than written to, since the UI may be reading it.
+func copyDevices(devices map[string]PairedDevice) map[string]PairedDevice {
+	copied := make(map[string]PairedDevice, len(devices)+1)
+	for ip, paired := range devices {
+		copied[ip] = paired
+	}
+	return copied
 }
 
 func (d *Device) TurnOn(ctx context.Context) error {
-	return d.client.setPower(ctx, d.config.IP, d.config.Token, true)
+	return d.setPower(ctx, true)
 }
 
 func (d *Device) TurnOff(ctx context.Context) error {
-	return d.client.setPower(ctx, d.config.IP, d.config.Token, false)
+	return d.setPower(ctx, false)
+}
+
+// TogglePower turns the device off when it is on and on otherwise, and
+// reports whether it is now on
+func (d *Device) TogglePower(ctx context.Context) (bool, error) {
+	on, err := d.client.getPower(ctx, d.GetConfig().IP, d.GetConfig().Token)
+	if err != nil {
+		return false, err
+	}
+	return !on, d.setPower(ctx, !on)
+}
+
+func (d *Device) setPower(ctx context.Context, on bool) error {
+	action := "off"
+	if on {
+		action = "on"
+	}
+	if err := d.logAction(action, d.client.setPower(ctx, d.GetConfig().IP, d.GetConfig().Token, on)); err != nil {
+		return err
+	}
+	d.recordUsage(func(s *usageSample) { s.On = on })
+	return nil
+}
+
+// setBrightnessCap makes the changes to the device keep the panels at or
+// below the brightness maxBrightness returns, e.g. the quiet hours cap; nil
+// lifts the cap
+func (d *Device) setBrightnessCap(maxBrightness func() int) {
+	d.client.capMu.Lock()
+	defer d.client.capMu.Unlock()
+	d.client.maxBrightness = maxBrightness
+}
+
+// UseDevice makes the paired device at ip the active one. The previously
+// active device stays paired.
+func (d *Device) UseDevice(ip string) error {
+	if _, ok := d.GetConfig().pairedDevices()[ip]; !ok {
+		return fmt.Errorf("%s is not paired", ip)
+	}
+	return d.updateConfig(func(config *Config) {
+		token, ok := config.pairedDevices()[ip]
+		if !ok {
+			token = d.GetConfig().pairedDevices()[ip]
+		}
+		devices := copyDevices(config.Devices)
+		// Configs from before Devices existed only hold the active token
+		if active := devices[config.IP]; config.IP != "" && active.Token == "" {
+			active.Token = config.Token
+			devices[config.IP] = active
+		}
+		config.Devices = devices
+		config.IP, config.Token = ip, token
+	})
+}
+
+// Unpair forgets the token of the device at ip. When it was the active
+// device, the first other paired device takes its place, if any.
+func (d *Device) Unpair(ip string) error {
+	if _, ok := d.GetConfig().pairedDevices()[ip]; !ok {
+		return fmt.Errorf("%s is not paired", ip)
+	}
+	return d.updateConfig(func(config *Config) {
+		devices := copyDevices(config.Devices)
+		delete(devices, ip)
+		config.Devices = devices
+		if ip != config.IP {
+			return
+		}
+		config.IP, config.Token = "", ""
+		var ips []string
+		for other := range config.pairedDevices() {
+			ips = append(ips, other)
+		}
+		sort.Strings(ips)
+		if len(ips) > 0 {
+			config.IP, config.Token = ips[0], devices[ips[0]].Token
+		}
+	})
+}
+
+// SetPairedPower turns another paired device on or off without making it
+// the active one
+func (d *Device) SetPairedPower(ctx context.Context, ip string, on bool) error {
+	token, ok := d.GetConfig().pairedDevices()[ip]
+	if !ok {
+		return fmt.Errorf("%s is not paired", ip)
+	}
+	return d.client.setPower(ctx, ip, token, on)
 }
 
 func (d *Device) SetBrightness(ctx context.Context, brightness int) error {
 	if brightness < 0 || brightness > 100 {
 		return fmt.Errorf("brightness must be between 0 and 100")
 	}
-	return d.client.setBrightness(ctx, d.config.IP, d.config.Token, brightness)
+	err := d.client.setBrightness(ctx, d.GetConfig().IP, d.GetConfig().Token, brightness)
+	if err := d.logAction(fmt.Sprintf("brightness %d", brightness), err); err != nil {
+		return err
+	}
+	d.recordUsage(func(s *usageSample) { s.On, s.Brightness = true, brightness })
+	return nil
+}
+
+// StepBrightness changes the brightness by delta, staying within 0-100
+func (d *Device) StepBrightness(ctx context.Context, delta int) error {
+	current, err := d.client.getBrightness(ctx, d.GetConfig().IP, d.GetConfig().Token)
+	if err != nil {
+		return err
+	}
+	return d.SetBrightness(ctx, min(max(current+delta, 0), 100))
+}
+
+// FadeBrightness transitions to brightness over duration seconds on the device
+func (d *Device) FadeBrightness(ctx context.Context, brightness, duration int) error {
+	if brightness < 0 || brightness > 100 {
+		return fmt.Errorf("brightness must be between 0 and 100")
+	}
+	err := d.client.fadeBrightness(ctx, d.GetConfig().IP, d.GetConfig().Token, brightness, duration)
+	if err := d.logAction(fmt.Sprintf("brightness %d over %ds", brightness, duration), err); err != nil {
+		return err
+	}
+	d.recordUsage(func(s *usageSample) { s.On, s.Brightness = true, brightness })
+	return nil
+}
+
+func (d *Device) SetColor(ctx context.Context, hue, saturation int) error {
+	if hue < 0 || hue > 360 {
+		return fmt.Errorf("hue must be between 0 and 360")
+	}
+	if saturation < 0 || saturation > 100 {
+		return fmt.Errorf("saturation must be between 0 and 100")
+	}
+	if caps, err := d.GetCapabilities(ctx); err == nil && !caps.Color {
+		return fmt.Errorf("%s panels are white only, set a color temperature instead", caps.Model)
+	}
+	err := d.client.setColor(ctx, d.GetConfig().IP, d.GetConfig().Token, hue, saturation)
+	return d.logAction(fmt.Sprintf("color hue %d sat %d", hue, saturation), err)
+}
+
+// SetColorTemp sets a white color temperature in Kelvin, limited to the
+// range of the device model when it is known
+func (d *Device) SetColorTemp(ctx context.Context, kelvin int) error {
+	minTemp, maxTemp := 1200, 6500
+	if caps, err := d.GetCapabilities(ctx); err == nil {
+		minTemp, maxTemp = caps.ColorTempMin, caps.ColorTempMax
+	}
+	if kelvin < minTemp || kelvin > maxTemp {
+		return fmt.Errorf("color temperature must be between %dK and %dK", minTemp, maxTemp)
+	}
+	err := d.client.setColorTemp(ctx, d.GetConfig().IP, d.GetConfig().Token, kelvin)
+	return d.logAction(fmt.Sprintf("temperature %d", kelvin), err)
+}
+
+// DeviceStatus is a lightweight snapshot of the device state
+type DeviceStatus struct {
+	On         bool
+	Brightness int
+	Effect     string
+}
+
+func (s DeviceStatus) String() string {
+	power := "Off"
+	if s.On {
+		power = "On"
+	}
+	status := fmt.Sprintf("%s · %d%%", power, s.Brightness)
+	if s.Effect != "" {
+		status += " · " + s.Effect
+	}
+	return status
+}
+
+// GetStatus reads power, brightness and the selected effect through their
+// individual endpoints rather than the full info payload
+func (d *Device) GetStatus(ctx context.Context) (DeviceStatus, error) {
+	var status DeviceStatus
+	var err error
+	if status.On, err = d.client.getPower(ctx, d.GetConfig().IP, d.GetConfig().Token); err != nil {
+		return status, err
+	}
+	if status.Brightness, err = d.client.getBrightness(ctx, d.GetConfig().IP, d.GetConfig().Token); err != nil {
+		return status, err
+	}
+	status.Effect, err = d.client.getSelectedEffect(ctx, d.GetConfig().IP, d.GetConfig().Token)
+	if err == nil {
+		d.recordUsage(func(s *usageSample) { s.On, s.Brightness = status.On, status.Brightness })
+	}
+	return status, err
+}
+
+// Request sends a raw API request relative to the authenticated API root,
+// e.g. "state" or "effects/effectsList"
+func (d *Device) Request(ctx context.Context, method, path string, body []byte) (int, []byte, error) {
+	status, data, err := d.client.rawRequest(ctx, d.GetConfig().IP, d.GetConfig().Token, method, path, body)
+	if method != http.MethodGet {
+		logErr := err
+		if err == nil && (status < 200 || status >= 300) {
+			logErr = fmt.Errorf("status %d", status)
+		}
+		d.logAction(fmt.Sprintf("api %s %s", method, path), logErr)
+	}
+	return status, data, err
+}
+
+func (d *Device) GetLayout(ctx context.Context) (Layout, error) {
+	return d.client.getLayout(ctx, d.GetConfig().IP, d.GetConfig().Token)
+}
+
+func (d *Device) SelectEffect(ctx context.Context, name string) error {
+	err := d.client.selectEffect(ctx, d.GetConfig().IP, d.GetConfig().Token, name)
+	if err == nil {
+		d.rememberEffect(name)
+	}
+	return d.logAction("effect "+name, err)
+}
+
+// DisplayEffect shows an effect definition without saving it on the device
+func (d *Device) DisplayEffect(ctx context.Context, effect map[string]interface{}) error {
+	if err := d.client.lowerToCap(ctx, d.GetConfig().IP, d.GetConfig().Token); err != nil {
+		return d.logAction(fmt.Sprintf("display %v effect", effect["animType"]), err)
+	}
+	err := d.client.writeEffect(ctx, d.GetConfig().IP, d.GetConfig().Token, withCommand(effect, "display"))
+	return d.logAction(fmt.Sprintf("display %v effect", effect["animType"]), err)
+}
+
+// ListEffects returns the full definitions of all effects installed on the device
+func (d *Device) ListEffects(ctx context.Context) ([]map[string]interface{}, error) {
+	return d.client.requestAllEffects(ctx, d.GetConfig().IP, d.GetConfig().Token)
+}
+
+// AddEffect installs an effect definition on the device, replacing any effect with the same name
+func (d *Device) AddEffect(ctx context.Context, effect map[string]interface{}) error {
+	if name, _ := effect["animName"].(string); name == "" {
+		return fmt.Errorf("effect has no animName")
+	}
+	d.warnFirmware(ctx, featureEffectWrite)
+	err := d.client.writeEffect(ctx, d.GetConfig().IP, d.GetConfig().Token, withCommand(effect, "add"))
+	return d.logAction(fmt.Sprintf("add effect %v", effect["animName"]), err)
 }
 
 func (d *Device) GetDeviceIP() string {
-	return d.config.IP
+	return d.GetConfig().IP
+}
+
+// ClearToken forgets a token the device rejected, keeping the rest of the
+// config so the device can simply be paired again
+func (d *Device) ClearToken() error {
+	ip := d.GetConfig().IP
+	return d.updateConfig(func(config *Config) {
+		if config.IP == ip {
+			config.Token = ""
+		}
+		devices := copyDevices(config.Devices)
+		delete(devices, ip)
+		config.Devices = devices
+	})
+}
+
+// SetLayoutTransform persists how the panel map is displayed
+func (d *Device) SetLayoutTransform(transform LayoutTransform) error {
+	return d.updateConfig(func(config *Config) {
+		config.LayoutTransform = &transform
+	})
+}
+
+func (d *Device) GetConfig() Config {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.config
 }
 
+// commandTimeout bounds a single command sent to the device
+const commandTimeout = 10 * time.Second
+
 func (d *Device) createContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 10*time.Second)
+	return context.WithTimeout(context.Background(), commandTimeout)
 }
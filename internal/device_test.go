@@ -3,9 +3,12 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -28,7 +31,7 @@ func TestLoadConfigSuccess(t *testing.T) {
 
 	testIP := "192.168.1.100"
 	testToken := "test-token"
-	err := saveConfig(testIP, testToken)
+	err := saveConfig(Config{IP: testIP, Token: testToken})
 	if err != nil {
 		t.Fatalf("failed to save config: %v", err)
 	}
@@ -141,6 +144,105 @@ func TestPairDeviceSuccess(t *testing.T) {
 	}
 }
 
+func TestPairDeviceUnsavedToken(t *testing.T) {
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", filepath.Join(t.TempDir(), "missing"))
+	defer os.Setenv("HOME", originalHome)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"auth_token": "new-auth-token"})
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+
+	err := device.PairDevice(context.Background())
+	var unsaved *UnsavedTokenError
+	if !errors.As(err, &unsaved) {
+		t.Fatalf("expected an UnsavedTokenError, got %v", err)
+	}
+	if unsaved.Token != "new-auth-token" || unsaved.IP != server.URL {
+		t.Errorf("unexpected error details %+v", unsaved)
+	}
+	if device.config.Token != "new-auth-token" {
+		t.Error("the token should still be usable for this session")
+	}
+}
+
+func TestPairAnother(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"auth_token": "second-token"})
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config = Config{IP: "192.168.1.100", Token: "first-token"}
+
+	if err := device.PairAnother(context.Background(), server.URL); err != nil {
+		t.Fatalf("PairAnother should not fail: %v", err)
+	}
+	if device.config.IP != "192.168.1.100" || device.config.Token != "first-token" {
+		t.Errorf("the active device should be kept, got %+v", device.config)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config should be saved: %v", err)
+	}
+	if config.Devices[server.URL].Token != "second-token" {
+		t.Errorf("expected the new token to be stored, got %+v", config.Devices)
+	}
+
+	device.config = Config{}
+	if err := device.PairAnother(context.Background(), server.URL); err != nil {
+		t.Fatalf("PairAnother should not fail: %v", err)
+	}
+	if device.config.IP != server.URL || device.config.Token != "second-token" {
+		t.Errorf("the first paired device should become active, got %+v", device.config)
+	}
+}
+
+func TestRename(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	device := NewDevice()
+	device.config = Config{IP: "192.168.1.100", Token: "test-token"}
+
+	if device.GetDeviceName() != "192.168.1.100" {
+		t.Errorf("an unnamed device should show its IP, got %s", device.GetDeviceName())
+	}
+	if err := device.Rename("192.168.1.100", "  Living Room Hexagons "); err != nil {
+		t.Fatalf("Rename should not fail: %v", err)
+	}
+	if device.GetDeviceName() != "Living Room Hexagons" {
+		t.Errorf("unexpected name %q", device.GetDeviceName())
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config should be saved: %v", err)
+	}
+	if paired := config.Devices["192.168.1.100"]; paired.Name != "Living Room Hexagons" || paired.Token != "test-token" {
+		t.Errorf("unexpected saved device %+v", paired)
+	}
+
+	if err := device.Rename("192.168.1.100", ""); err != nil {
+		t.Fatalf("Rename should not fail: %v", err)
+	}
+	if device.GetDeviceName() != "192.168.1.100" {
+		t.Errorf("clearing the name should show the IP again, got %s", device.GetDeviceName())
+	}
+}
+
 func TestTurnOnOff(t *testing.T) {
 	var receivedPowerState bool
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -178,6 +280,113 @@ func TestTurnOnOff(t *testing.T) {
 	}
 }
 
+func TestSetPairedPower(t *testing.T) {
+	var receivedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedPath = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config = Config{
+		IP:      "192.168.1.100",
+		Token:   "test-token",
+		Devices: map[string]PairedDevice{server.URL: {Token: "other-token"}},
+	}
+
+	if err := device.SetPairedPower(context.Background(), server.URL, false); err != nil {
+		t.Fatalf("SetPairedPower should not fail: %v", err)
+	}
+	if receivedPath != "/api/v1/other-token/state" {
+		t.Errorf("expected the other device's token to be used, got %s", receivedPath)
+	}
+	if device.GetDeviceIP() != "192.168.1.100" {
+		t.Errorf("the active device should not change, got %s", device.GetDeviceIP())
+	}
+	if err := device.SetPairedPower(context.Background(), "192.168.1.200", true); err == nil {
+		t.Error("expected an error for an unpaired device")
+	}
+}
+
+func TestUseDevice(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	device := NewDevice()
+	device.config = Config{
+		IP:      "192.168.1.100",
+		Token:   "first-token",
+		Devices: map[string]PairedDevice{"192.168.1.101": {Token: "second-token", Name: "Bedroom"}},
+	}
+	device.caps = &Capabilities{Model: "Shapes"}
+
+	if err := device.UseDevice("192.168.1.101"); err != nil {
+		t.Fatalf("UseDevice should not fail: %v", err)
+	}
+	if device.GetDeviceIP() != "192.168.1.101" || device.config.Token != "second-token" {
+		t.Errorf("expected the second device to be active, got %+v", device.config)
+	}
+	if device.caps != nil {
+		t.Error("the capabilities of the previous device should be dropped")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config should be saved: %v", err)
+	}
+	if config.IP != "192.168.1.101" || config.Devices["192.168.1.100"].Token != "first-token" {
+		t.Errorf("the previous device should stay paired, got %+v", config)
+	}
+	if err := device.UseDevice("192.168.1.200"); err == nil {
+		t.Error("expected an error for an unpaired device")
+	}
+}
+
+func TestUnpair(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	device := NewDevice()
+	device.config = Config{
+		IP:    "192.168.1.100",
+		Token: "first-token",
+		Devices: map[string]PairedDevice{
+			"192.168.1.100": {Token: "first-token"},
+			"192.168.1.101": {Token: "second-token"},
+			"192.168.1.102": {Token: "third-token"},
+		},
+	}
+
+	if err := device.Unpair("192.168.1.102"); err != nil {
+		t.Fatalf("Unpair should not fail: %v", err)
+	}
+	if device.GetDeviceIP() != "192.168.1.100" {
+		t.Errorf("unpairing another device should keep the active one, got %s", device.GetDeviceIP())
+	}
+	if err := device.Unpair("192.168.1.100"); err != nil {
+		t.Fatalf("Unpair should not fail: %v", err)
+	}
+	if device.GetDeviceIP() != "192.168.1.101" || device.config.Token != "second-token" {
+		t.Errorf("the remaining device should become active, got %+v", device.config)
+	}
+	if err := device.Unpair("192.168.1.101"); err != nil {
+		t.Fatalf("Unpair should not fail: %v", err)
+	}
+	if device.GetDeviceIP() != "" || len(device.config.pairedDevices()) != 0 {
+		t.Errorf("no device should be left, got %+v", device.config)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config should be saved: %v", err)
+	}
+	if len(config.Devices) != 0 || config.Token != "" {
+		t.Errorf("unexpected saved config %+v", config)
+	}
+	if err := device.Unpair("192.168.1.100"); err == nil {
+		t.Error("expected an error for an unpaired device")
+	}
+}
+
 func TestSetBrightnessValid(t *testing.T) {
 	var receivedBrightness int
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -208,6 +417,36 @@ func TestSetBrightnessValid(t *testing.T) {
 	}
 }
 
+func TestStepBrightness(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	brightness := 95
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			json.NewEncoder(w).Encode(map[string]int{"value": brightness})
+			return
+		}
+		var payload map[string]map[string]int
+		json.NewDecoder(r.Body).Decode(&payload)
+		brightness = payload["brightness"]["value"]
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	for _, step := range []struct{ delta, want int }{{10, 100}, {-30, 70}, {-80, 0}} {
+		if err := device.StepBrightness(context.Background(), step.delta); err != nil {
+			t.Fatalf("StepBrightness should not fail: %v", err)
+		}
+		if brightness != step.want {
+			t.Errorf("expected a step of %d to end at %d, got %d", step.delta, step.want, brightness)
+		}
+	}
+}
+
 func TestSetBrightnessInvalid(t *testing.T) {
 	device := NewDevice()
 	ctx := context.Background()
@@ -223,6 +462,51 @@ func TestSetBrightnessInvalid(t *testing.T) {
 	}
 }
 
+func TestSetColorInvalid(t *testing.T) {
+	device := NewDevice()
+	ctx := context.Background()
+
+	if err := device.SetColor(ctx, 361, 50); err == nil {
+		t.Error("SetColor should fail with hue > 360")
+	}
+	if err := device.SetColor(ctx, 120, 101); err == nil {
+		t.Error("SetColor should fail with saturation > 100")
+	}
+}
+
+func TestWhiteOnlyDevice(t *testing.T) {
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			w.Write([]byte(`{"model":"NL52","firmwareVersion":"7.1.0"}`))
+			return
+		}
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	ctx := context.Background()
+
+	if err := device.SetColor(ctx, 120, 100); err == nil {
+		t.Error("SetColor should fail on a white-only device")
+	}
+	if err := device.SetColorTemp(ctx, 6500); err == nil {
+		t.Error("SetColorTemp should fail outside the Elements range")
+	}
+	if err := device.SetColorTemp(ctx, 2700); err != nil {
+		t.Fatalf("SetColorTemp should not fail: %v", err)
+	}
+	if len(payloads) != 1 || payloads[0]["ct"].(map[string]interface{})["value"].(float64) != 2700 {
+		t.Errorf("expected a single ct update, got %v", payloads)
+	}
+}
+
 func TestGetDeviceIP(t *testing.T) {
 	device := NewDevice()
 	testIP := "192.168.1.100"
@@ -252,3 +536,199 @@ func TestCreateContext(t *testing.T) {
 		t.Error("context deadline is too far in the future")
 	}
 }
+
+func TestSetLayoutTransform(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	device := NewDevice()
+	device.config = Config{IP: "192.168.1.100", Token: "test-token"}
+
+	err := device.SetLayoutTransform(LayoutTransform{Rotation: 90, Mirror: true})
+	if err != nil {
+		t.Fatalf("SetLayoutTransform should not fail: %v", err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config should be saved: %v", err)
+	}
+	if config.LayoutTransform == nil || config.LayoutTransform.Rotation != 90 || !config.LayoutTransform.Mirror {
+		t.Errorf("unexpected saved transform %+v", config.LayoutTransform)
+	}
+	if config.Token != "test-token" {
+		t.Error("saving the transform should keep the token")
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/test-token/state/on":
+			w.Write([]byte(`{"value":false}`))
+		case "/api/v1/test-token/state/brightness":
+			w.Write([]byte(`{"value":40}`))
+		case "/api/v1/test-token/effects/select":
+			w.Write([]byte(`"*Solid*"`))
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	status, err := device.GetStatus(context.Background())
+	if err != nil {
+		t.Fatalf("GetStatus should not fail: %v", err)
+	}
+	if status.String() != "Off · 40% · *Solid*" {
+		t.Errorf("unexpected status %q", status)
+	}
+}
+
+func TestPing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/test-token/state/on" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"value":true}`))
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	latency, err := device.Ping(context.Background())
+	if err != nil {
+		t.Fatalf("Ping should not fail: %v", err)
+	}
+	if latency < 20*time.Millisecond {
+		t.Errorf("latency should include the response time, got %s", latency)
+	}
+
+	server.Close()
+	if _, err := device.Ping(context.Background()); err == nil {
+		t.Error("Ping should fail when the device is unreachable")
+	}
+}
+
+func TestClearToken(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	device := NewDevice()
+	device.config = Config{IP: "192.168.1.100", Token: "stale-token", GalleryURL: "https://example.com/index.json"}
+
+	if err := device.ClearToken(); err != nil {
+		t.Fatalf("ClearToken should not fail: %v", err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config should be saved: %v", err)
+	}
+	if config.Token != "" {
+		t.Error("token should be cleared")
+	}
+	if config.IP != "192.168.1.100" || config.GalleryURL != "https://example.com/index.json" {
+		t.Errorf("other settings should be kept, got %+v", config)
+	}
+}
+
+func TestUpdateConfigKeepsOtherEdits(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := saveConfig(Config{IP: "192.168.1.100", Token: "test-token"}); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	device := NewDevice()
+	if err := device.LoadConfig(); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	// Another process, e.g. a CLI command, saves the config meanwhile
+	if err := saveConfig(Config{IP: "192.168.1.100", Token: "test-token", GalleryURL: "https://example.com/index.json"}); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	if err := device.SetLayoutTransform(LayoutTransform{Rotation: 90}); err != nil {
+		t.Fatalf("SetLayoutTransform should not fail: %v", err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config should be saved: %v", err)
+	}
+	if config.GalleryURL != "https://example.com/index.json" {
+		t.Error("the edit of the other process should be kept")
+	}
+	if config.LayoutTransform == nil || config.LayoutTransform.Rotation != 90 {
+		t.Errorf("the layout transform should be saved, got %+v", config.LayoutTransform)
+	}
+	if device.GetConfig().GalleryURL != "https://example.com/index.json" {
+		t.Error("the device should see the edit of the other process")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := saveConfig(Config{IP: "192.168.1.100", Token: "test-token"}); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	device := NewDevice()
+	if err := device.LoadConfig(); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	device.caps = &Capabilities{Model: "Shapes"}
+
+	if reloaded, err := device.ReloadConfig(); reloaded || err != nil {
+		t.Errorf("an unchanged config should not be reloaded, got %v, %v", reloaded, err)
+	}
+	if err := saveConfig(Config{IP: "192.168.1.101", Token: "other-token"}); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	if reloaded, err := device.ReloadConfig(); !reloaded || err != nil {
+		t.Fatalf("a changed config should be reloaded, got %v, %v", reloaded, err)
+	}
+	if device.GetDeviceIP() != "192.168.1.101" {
+		t.Errorf("expected the new active device, got %s", device.GetDeviceIP())
+	}
+	if device.caps != nil {
+		t.Error("the capabilities of the previous device should be dropped")
+	}
+}
+
+// TestConfigConcurrentAccess replaces the config from goroutines, as the
+// UI's commands do, while it is reloaded and read; run with -race
+func TestConfigConcurrentAccess(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := saveConfig(Config{IP: "10.0.0.5", Token: "token", Devices: map[string]PairedDevice{"10.0.0.5": {Token: "token"}}}); err != nil {
+		t.Fatal(err)
+	}
+	device := NewDevice()
+	if err := device.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			device.rememberEffect(fmt.Sprintf("Effect %d", i))
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		device.ReloadConfig()
+		device.GetConfig().httpSettings("10.0.0.5:16021")
+		device.GetDeviceIP()
+	}
+	<-done
+	if recent := device.RecentEffects(); len(recent) == 0 || recent[0] != "Effect 19" {
+		t.Errorf("expected the last remembered effect first, got %v", recent)
+	}
+}
@@ -0,0 +1,371 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxClockSkew is how far the clock may be off before doctor warns; quiet
+// hours and daily automations work in whole minutes
+const maxClockSkew = time.Minute
+
+// mdnsService is what Nanoleaf devices advertise over mDNS
+const mdnsService = "_nanoleafapi._tcp.local"
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// doctorCheck is the outcome of one check. A failed warning does not make
+// doctor fail.
+type doctorCheck struct {
+	name string
+	err  error
+	warn bool
+	// detail is shown when the check passes, fix when it does not
+	detail string
+	fix    string
+}
+
+func (c doctorCheck) String() string {
+	switch {
+	case c.err == nil && c.detail != "":
+		return fmt.Sprintf("ok    %s: %s", c.name, c.detail)
+	case c.err == nil:
+		return "ok    " + c.name
+	}
+	status := "FAIL  "
+	if c.warn {
+		status = "warn  "
+	}
+	line := fmt.Sprintf("%s%s: %v", status, c.name, c.err)
+	if c.fix != "" {
+		line += "\n      → " + c.fix
+	}
+	return line
+}
+
+// configProblems lists what is wrong with the sections of config that are
+// otherwise only checked when the command using them starts
+func configProblems(config Config) []error {
+	var problems []error
+	if config.QuietHours != nil {
+		if err := config.QuietHours.validate(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if _, err := config.scheduleZone(""); err != nil {
+		problems = append(problems, fmt.Errorf("timezone: %w", err))
+	}
+	if _, err := config.manualOverride(); err != nil {
+		problems = append(problems, err)
+	}
+	if err := config.validatePriorities(); err != nil {
+		problems = append(problems, err)
+	}
+	for _, name := range config.groupNames() {
+		_, unknown := config.groupMembers(name)
+		for _, member := range unknown {
+			problems = append(problems, fmt.Errorf("groups.%s: %s is not a paired device", name, member))
+		}
+	}
+	for _, name := range macroNames(config.Macros) {
+		if _, err := parseMacro(config.Macros[name]); err != nil {
+			problems = append(problems, fmt.Errorf("macros.%s: %w", name, err))
+		}
+	}
+	if _, err := parseCalendarSources(config.Calendars); err != nil {
+		problems = append(problems, err)
+	}
+	if config.OBS != nil {
+		if _, err := newOBSRules(nil, config.OBS.Rules); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if config.Serve != nil {
+		if _, err := newPresenceTracker(nil, nil, config.Serve.Presence); err != nil {
+			problems = append(problems, fmt.Errorf("serve.%w", err))
+		}
+		if _, err := newTriggerSet(nil, nil, config.Serve.Triggers); err != nil {
+			problems = append(problems, fmt.Errorf("serve.%w", err))
+		}
+		if _, err := newPresenceProber(nil, config.Serve.NetworkPresence); err != nil {
+			problems = append(problems, fmt.Errorf("serve.%w", err))
+		}
+		if _, err := newRediscoverer(nil, config.Serve.Rediscovery); err != nil {
+			problems = append(problems, fmt.Errorf("serve.%w", err))
+		}
+		if config.Serve.Listen != "" {
+			if err := checkServeListen(config.Serve.Listen, config.Serve.Secret); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+	if config.Holidays != nil {
+		if _, err := holidayCalendar(*config.Holidays); err != nil {
+			problems = append(problems, fmt.Errorf("holidays: %w", err))
+		}
+		if config.Holidays.Timezone != "" {
+			if _, err := config.scheduleZone(config.Holidays.Timezone); err != nil {
+				problems = append(problems, fmt.Errorf("holidays.timezone: %w", err))
+			}
+		}
+	}
+	if _, err := newErrorLog(config, ""); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := config.streamFPS(); err != nil {
+		problems = append(problems, err)
+	}
+	switch config.StreamTransport {
+	case "", transportAuto, transportUDP, transportREST:
+	default:
+		problems = append(problems, fmt.Errorf("streamTransport: unknown transport %q, expected auto, udp or rest", config.StreamTransport))
+	}
+	if len(config.GlobalKeys) > 0 {
+		if _, err := keyBindings(hotkeyTools[defaultHotkeyTool(runtime.GOOS)], "nanoleaf-go", config.GlobalKeys); err != nil {
+			problems = append(problems, fmt.Errorf("globalKeys: %w", err))
+		}
+	}
+	return problems
+}
+
+// checkConfig reads the config and checks every section of it
+func checkConfig() ([]doctorCheck, Config) {
+	path := getConfigPath()
+	if !configExists() {
+		return []doctorCheck{{name: "Config", err: fmt.Errorf("%s does not exist", path), fix: "start nanoleaf-go without a command and pair a device"}}, Config{}
+	}
+	config, err := loadConfig()
+	if err != nil {
+		check := doctorCheck{name: "Config", err: err, fix: "fix the file by hand, or pair again after moving it away"}
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			data, _ := os.ReadFile(path)
+			line := 1 + strings.Count(string(data[:min(int(syntaxErr.Offset), len(data))]), "\n")
+			check.err = fmt.Errorf("%s, line %d: %w", path, line, err)
+		}
+		return []doctorCheck{check}, config
+	}
+
+	checks := []doctorCheck{{name: "Config", detail: path}}
+	for _, problem := range configProblems(config) {
+		checks = append(checks, doctorCheck{name: "Config", err: problem, fix: "correct the setting, see the Configuration section of the README"})
+	}
+	if len(config.pairedDevices()) == 0 {
+		checks = append(checks, doctorCheck{name: "Devices", err: fmt.Errorf("no device is paired"), fix: "start nanoleaf-go without a command and pair a device"})
+	}
+	return checks, config
+}
+
+// checkDevice checks that the device at ip answers and accepts its token
+func checkDevice(ctx context.Context, config Config, ip, token string) []doctorCheck {
+	name := config.displayName(ip)
+	if name != ip {
+		name = fmt.Sprintf("%s (%s)", name, ip)
+	}
+	device := newCommandDevice()
+	device.config = Config{IP: ip, Token: token}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	latency, err := device.Ping(ctx)
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return []doctorCheck{
+			{name: name, detail: "reachable"},
+			{name: name + " token", err: err, fix: "hold the power button for 5-7 seconds until the lights flash, then pair again from the interactive UI"},
+		}
+	case err != nil:
+		return []doctorCheck{{name: name, err: err, fix: "check that the device is powered and on this network; if its address changed, run scan and pair it again"}}
+	}
+	return []doctorCheck{
+		{name: name, detail: fmt.Sprintf("reachable in %s", latency.Round(time.Millisecond))},
+		{name: name + " token", detail: "accepted"},
+	}
+}
+
+// mdnsQuery builds an mDNS question for the PTR records of service, asking
+// for unicast answers so they come back to the port it was sent from
+func mdnsQuery(service string) []byte {
+	// ID 0, no flags, one question
+	msg := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range strings.Split(service, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	// Type PTR, class IN with the unicast response bit
+	return append(msg, 0, 0, 12, 0x80, 1)
+}
+
+// probeMDNS asks for Nanoleaf devices over mDNS and returns the addresses
+// that answered within timeout
+func probeMDNS(timeout time.Duration) ([]string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP(mdnsQuery(mdnsService), mdnsGroup); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	buf := make([]byte, 9000)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		// Responses with at least one answer
+		if n >= 12 && buf[2]&0x80 != 0 && buf[6]|buf[7] != 0 {
+			seen[from.IP.String()] = true
+		}
+	}
+	found := make([]string, 0, len(seen))
+	for ip := range seen {
+		found = append(found, ip)
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+func checkMDNS() doctorCheck {
+	check := doctorCheck{name: "mDNS", warn: true}
+	found, err := probeMDNS(2 * time.Second)
+	switch {
+	case err != nil:
+		check.err = fmt.Errorf("cannot send multicast: %w", err)
+		check.fix = "this machine has no multicast route; scan still finds devices by probing the local networks"
+	case len(found) == 0:
+		check.err = fmt.Errorf("no device answered for %s", mdnsService)
+		check.fix = "the network may block multicast (client or AP isolation, IGMP snooping); scan still works, other apps may not find the devices"
+	default:
+		check.detail = fmt.Sprintf("%d device(s) answered: %s", len(found), strings.Join(found, ", "))
+	}
+	return check
+}
+
+// clockSkew compares the local clock with the Date header of url, taking
+// the middle of the request as the moment the server answered
+func clockSkew(ctx context.Context, httpClient *http.Client, url string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	end := time.Now()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("%s sent no usable Date header", url)
+	}
+	local := start.Add(end.Sub(start) / 2)
+	return local.Sub(date), nil
+}
+
+func checkClock(ctx context.Context, url string) doctorCheck {
+	check := doctorCheck{name: "Clock", warn: true}
+	skew, err := clockSkew(ctx, &http.Client{Timeout: 10 * time.Second}, url)
+	switch {
+	case err != nil:
+		check.err = fmt.Errorf("could not compare with %s: %w", url, err)
+		check.fix = "needs internet access; the check can be skipped when offline"
+	case skew > maxClockSkew || skew < -maxClockSkew:
+		check.err = fmt.Errorf("this computer is %s off", skew.Round(time.Second).Abs())
+		check.fix = "turn on automatic time (timedatectl set-ntp true on Linux, Date & Time settings on macOS and Windows); quiet hours and automations follow this clock"
+	default:
+		check.detail = "within " + shortDuration(maxClockSkew)
+	}
+	return check
+}
+
+// checkStreamPort switches device to external control and sends empty
+// frames to the UDP port, which shows up as an error when the network
+// rejects them. The effect that was showing is selected again afterwards,
+// without being recorded as an effect the user picked.
+func checkStreamPort(ctx context.Context, device *Device) doctorCheck {
+	check := doctorCheck{name: fmt.Sprintf("UDP port %d", streamPort)}
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	status, err := device.GetStatus(ctx)
+	if err == nil {
+		device.config.StreamTransport = transportUDP
+		var session *streamSession
+		if session, err = device.StartStream(ctx); err == nil {
+			for i := 0; i < 3 && err == nil; i++ {
+				time.Sleep(100 * time.Millisecond)
+				err = session.sendFrame(ctx, nil, 0)
+			}
+			session.Close()
+		}
+		if status.Effect != "" && !strings.HasPrefix(status.Effect, "*") {
+			config := device.GetConfig()
+			device.client.selectEffect(ctx, config.IP, config.Token, status.Effect)
+		}
+	}
+	if err != nil {
+		check.err = err
+		check.fix = "allow UDP to the device (firewall, or between VLANs); until then set streamTransport to rest for live effects and music"
+		return check
+	}
+	check.detail = "frames are not rejected"
+	return check
+}
+
+func runDoctor(ctx context.Context, args []string) error {
+	fs := newFlagSet("doctor")
+	udp := fs.Bool("udp", false, fmt.Sprintf("also check UDP port %d, which briefly switches the active device to external control", streamPort))
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+
+	checks, config := checkConfig()
+	pairedDevices := config.pairedDevices()
+	ips := make([]string, 0, len(pairedDevices))
+	for ip := range pairedDevices {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	reachable := false
+	for _, ip := range ips {
+		deviceChecks := checkDevice(ctx, config, ip, pairedDevices[ip])
+		reachable = reachable || ip == config.IP && deviceChecks[len(deviceChecks)-1].err == nil
+		checks = append(checks, deviceChecks...)
+	}
+	checks = append(checks, checkMDNS())
+	if *udp && reachable {
+		device := newCommandDevice()
+		device.config = config
+		checks = append(checks, checkStreamPort(ctx, device))
+	} else if *udp {
+		checks = append(checks, doctorCheck{name: fmt.Sprintf("UDP port %d", streamPort), warn: true, err: fmt.Errorf("skipped, the active device is not reachable")})
+	}
+	checks = append(checks, checkClock(ctx, galleryURL(config)))
+
+	failed := 0
+	for _, check := range checks {
+		fmt.Println(check)
+		if check.err != nil && !check.warn {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
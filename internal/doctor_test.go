@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigProblems(t *testing.T) {
+	if problems := configProblems(Config{Macros: map[string][]string{"dim": {"brightness 10"}}}); len(problems) != 0 {
+		t.Errorf("expected a valid config to pass, got %v", problems)
+	}
+
+	config := Config{
+		QuietHours:      &QuietHours{Start: "22:00", End: "7am"},
+		Macros:          map[string][]string{"party": {"on", "dance"}},
+		Serve:           &ServeConfig{Presence: []PresenceRule{{Event: "arrive", Actions: []string{"on"}}}},
+		ErrorSummaries:  &ErrorSummaries{Every: "hourly"},
+		StreamFPS:       -1,
+		StreamTransport: "tcp",
+		GlobalKeys:      map[string]string{"ctrl+alt+q": "explode"},
+		Groups:          map[string][]string{"upstairs": {"Attic"}},
+	}
+	problems := configProblems(config)
+	if len(problems) != 8 {
+		t.Fatalf("expected 8 problems, got %d: %v", len(problems), problems)
+	}
+	if problems[1].Error() != "groups.upstairs: Attic is not a paired device" {
+		t.Errorf("expected the unknown group member to be named, got %v", problems[1])
+	}
+	if !strings.HasPrefix(problems[2].Error(), "macros.party: step 2") {
+		t.Errorf("expected the failing macro step to be named, got %v", problems[2])
+	}
+}
+
+func TestCheckDevice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/good-token/") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"value":true}`))
+	}))
+	defer server.Close()
+	config := Config{Devices: map[string]PairedDevice{server.URL: {Name: "Office"}}}
+
+	checks := checkDevice(context.Background(), config, server.URL, "good-token")
+	if len(checks) != 2 || checks[0].err != nil || checks[1].err != nil {
+		t.Errorf("expected the device and token to pass, got %v", checks)
+	}
+	checks = checkDevice(context.Background(), config, server.URL, "old-token")
+	if len(checks) != 2 || checks[0].err != nil || checks[1].err == nil || checks[1].fix == "" {
+		t.Errorf("expected the token to fail with a fix, got %v", checks)
+	}
+	if !strings.HasPrefix(checks[1].name, "Office (") {
+		t.Errorf("expected the device name in the check, got %q", checks[1].name)
+	}
+
+	server.Close()
+	checks = checkDevice(context.Background(), config, server.URL, "good-token")
+	if len(checks) != 1 || checks[0].err == nil {
+		t.Errorf("expected an unreachable device to fail, got %v", checks)
+	}
+}
+
+func TestCheckStreamPortRestoresQuietly(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer udp.Close()
+	originalPort := streamPort
+	streamPort = udp.LocalAddr().(*net.UDPAddr).Port
+	defer func() { streamPort = originalPort }()
+
+	var selected string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/test-token/")
+		switch {
+		case r.Method == http.MethodPut && path == "effects":
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), `"select"`) {
+				selected = string(body)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case path == "effects/select":
+			w.Write([]byte(`"Forest"`))
+		case path == "state":
+			w.Write([]byte(`{"on":{"value":true},"brightness":{"value":40}}`))
+		default:
+			w.Write([]byte(`{"sideLength":150,"positionData":[{"panelId":5,"x":0,"y":0,"shapeType":7}]}`))
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.record = true
+
+	if check := checkStreamPort(context.Background(), device); check.err != nil {
+		t.Fatalf("expected the port check to pass, got %v", check.err)
+	}
+	if selected != `{"select":"Forest"}` {
+		t.Errorf("expected the effect that was showing to be selected again, got %q", selected)
+	}
+	if recent := device.RecentEffects(); len(recent) != 0 {
+		t.Errorf("expected the restore to stay out of the recent effects, got %v", recent)
+	}
+}
+
+func TestMDNSQuery(t *testing.T) {
+	query := mdnsQuery("_nanoleafapi._tcp.local")
+	want := "\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00" +
+		"\x0c_nanoleafapi\x04_tcp\x05local\x00" +
+		"\x00\x0c\x80\x01"
+	if string(query) != want {
+		t.Errorf("expected %q, got %q", want, query)
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-5*time.Minute).UTC().Format(http.TimeFormat))
+	}))
+	defer server.Close()
+
+	skew, err := clockSkew(context.Background(), server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("clockSkew should not fail: %v", err)
+	}
+	if skew < 4*time.Minute || skew > 6*time.Minute {
+		t.Errorf("expected the clock to be about 5m ahead, got %s", skew)
+	}
+}
+
+func TestDoctorCheckString(t *testing.T) {
+	check := doctorCheck{name: "mDNS", warn: true, err: errors.New("no answers"), fix: "allow multicast"}
+	if got := check.String(); got != "warn  mDNS: no answers\n      → allow multicast" {
+		t.Errorf("unexpected %q", got)
+	}
+	if got := (doctorCheck{name: "Clock", detail: "within 1m"}).String(); got != "ok    Clock: within 1m" {
+		t.Errorf("unexpected %q", got)
+	}
+}
@@ -0,0 +1,31 @@
+package internal
+
+// paletteColor is a palette entry in the device's HSB scales
+type paletteColor struct {
+	Hue        int `json:"hue"`
+	Saturation int `json:"saturation"`
+	Brightness int `json:"brightness"`
+}
+
+// fadeEffect builds an effect that fades between the palette colors.
+// Times are in tenths of a second.
+func fadeEffect(palette []paletteColor, transTime, delayTime int) map[string]interface{} {
+	return map[string]interface{}{
+		"animType":  "fade",
+		"colorType": "HSB",
+		"palette":   palette,
+		"transTime": map[string]int{"minValue": transTime, "maxValue": transTime},
+		"delayTime": map[string]int{"minValue": delayTime, "maxValue": delayTime},
+		"loop":      true,
+	}
+}
+
+// withCommand copies an effect definition for use with the given write command
+func withCommand(effect map[string]interface{}, command string) map[string]interface{} {
+	write := make(map[string]interface{}, len(effect)+1)
+	for key, value := range effect {
+		write[key] = value
+	}
+	write["command"] = command
+	return write
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// effectPreview approximates the animation of an effect definition so it
+// can be auditioned on the panel map without changing the room lighting.
+// It follows the animation type, palette, timing and direction; the device
+// adds randomness and detail that the preview leaves out.
+type effectPreview struct {
+	anim      string
+	palette   []rgbColor
+	direction string
+	// step is how long each palette color holds, the transition plus delay
+	step time.Duration
+}
+
+// defaultPreviewStep is used for effects without timing, e.g. solid colors
+const defaultPreviewStep = 2 * time.Second
+
+func newEffectPreview(effect map[string]interface{}) (*effectPreview, error) {
+	palette, ok := effect["palette"].([]paletteColor)
+	if !ok {
+		palette = effectPalette(effect)
+	}
+	if len(palette) == 0 {
+		return nil, fmt.Errorf("the effect has no palette to preview")
+	}
+
+	preview := &effectPreview{step: defaultPreviewStep}
+	preview.anim, _ = effect["animType"].(string)
+	preview.direction, _ = effect["direction"].(string)
+	for _, c := range palette {
+		preview.palette = append(preview.palette, hsbToRGB(c.Hue, c.Saturation, c.Brightness))
+	}
+	if step := effectTime(effect["transTime"]) + effectTime(effect["delayTime"]); step > 0 {
+		preview.step = step
+	}
+	return preview, nil
+}
+
+// effectTime reads a time of an effect definition, a number or a
+// minValue/maxValue range in tenths of a second, as the average duration
+func effectTime(value interface{}) time.Duration {
+	tenths := 0.0
+	switch v := value.(type) {
+	case float64:
+		tenths = v
+	case int:
+		tenths = float64(v)
+	case map[string]int:
+		tenths = float64(v["minValue"]+v["maxValue"]) / 2
+	case map[string]interface{}:
+		low, _ := v["minValue"].(float64)
+		high, _ := v["maxValue"].(float64)
+		tenths = (low + high) / 2
+	}
+	return time.Duration(tenths * float64(time.Second) / 10)
+}
+
+func (e *effectPreview) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	// cycle runs from 0 to 1 once the whole palette has been shown
+	cycle := t.Seconds() / (e.step.Seconds() * float64(len(e.palette)))
+	return eachPanel(layout, func(p Panel, x, y float64) rgbColor {
+		switch e.anim {
+		case "solid":
+			return e.palette[0]
+		case "fade":
+			return paletteAt(e.palette, cycle)
+		case "flow":
+			return paletteAt(e.palette, e.flowPosition(x, y)*0.5-cycle)
+		case "wheel":
+			angle := math.Atan2(y-0.5, x-0.5) / (2 * math.Pi)
+			return paletteAt(e.palette, angle-cycle)
+		case "explode":
+			return paletteAt(e.palette, math.Hypot(x-0.5, y-0.5)-cycle)
+		case "static":
+			return e.palette[p.ID%len(e.palette)]
+		default:
+			// random, highlight and custom effects change panels independently
+			return paletteAt(e.palette, noise(float64(p.ID), t.Seconds()/e.step.Seconds()))
+		}
+	})
+}
+
+// flowPosition is how far along the flow direction a panel is, from 0 at
+// the panels the colors start from
+func (e *effectPreview) flowPosition(x, y float64) float64 {
+	switch e.direction {
+	case "left":
+		return 1 - x
+	case "up":
+		return y
+	case "down":
+		return 1 - y
+	case "outwards":
+		return math.Hypot(x-0.5, y-0.5) * 2
+	case "inwards":
+		return 1 - math.Hypot(x-0.5, y-0.5)*2
+	default:
+		return x
+	}
+}
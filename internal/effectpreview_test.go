@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEffectPreview(t *testing.T) {
+	var effect map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"animType": "flow",
+		"palette": [
+			{"hue": 0, "saturation": 100, "brightness": 100},
+			{"hue": 240, "saturation": 100, "brightness": 100}
+		],
+		"transTime": {"minValue": 10, "maxValue": 30},
+		"delayTime": 10,
+		"direction": "right"
+	}`), &effect)
+
+	preview, err := newEffectPreview(effect)
+	if err != nil {
+		t.Fatalf("newEffectPreview should not fail: %v", err)
+	}
+	if preview.step != 3*time.Second {
+		t.Errorf("expected a step of the average transition plus delay, got %s", preview.step)
+	}
+
+	layout := Layout{Panels: []Panel{{ID: 1, X: 0}, {ID: 2, X: 100}}}
+	start := preview.NextFrame(layout, 0)
+	if len(start) != 2 || start[0] == start[1] {
+		t.Fatalf("expected the flow to spread colors across the panels, got %v", start)
+	}
+	later := preview.NextFrame(layout, time.Second)
+	if later[0] == start[0] {
+		t.Error("expected the colors to move over time")
+	}
+
+	// Effects built here carry typed palettes
+	built, _ := paletteEffect("Built", "fade", []paletteColor{{Hue: 120, Saturation: 100, Brightness: 100}}, Layout{})
+	if preview, err := newEffectPreview(built); err != nil || preview.anim != "fade" {
+		t.Errorf("expected a fade preview of a built effect, got %v", err)
+	}
+
+	if _, err := newEffectPreview(map[string]interface{}{"animType": "custom"}); err == nil {
+		t.Error("expected an error for an effect without a palette")
+	}
+}
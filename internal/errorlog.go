@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults of ErrorSummaries
+const (
+	defaultErrorsBeforeSummary = 3
+	defaultErrorSummaryEvery   = time.Hour
+)
+
+// ErrorSummaries sets how the automation commands report failures that
+// keep happening, e.g. while the device is offline overnight. The first
+// After failures in a row are printed as they happen, the rest are summed
+// up every Every until the command recovers.
+type ErrorSummaries struct {
+	After int    `json:"after,omitempty"`
+	Every string `json:"every,omitempty"`
+}
+
+// errorLog prints the failures of an automation loop, summarizing them
+// once they repeat rather than printing one line per attempt
+type errorLog struct {
+	label  string
+	after  int
+	every  time.Duration
+	now    func() time.Time
+	printf func(format string, args ...interface{})
+
+	failures int
+	since    time.Time
+	// summarized is when the last summary, or the last failure printed
+	// one by one, was written
+	summarized time.Time
+	last       error
+}
+
+func newErrorLog(config Config, label string) (*errorLog, error) {
+	l := &errorLog{label: label, after: defaultErrorsBeforeSummary, every: defaultErrorSummaryEvery, now: time.Now, printf: errorf}
+	if s := config.ErrorSummaries; s != nil {
+		if s.After < 0 {
+			return nil, fmt.Errorf("errorSummaries.after must not be negative")
+		}
+		if s.After > 0 {
+			l.after = s.After
+		}
+		if s.Every != "" {
+			every, err := time.ParseDuration(s.Every)
+			if err != nil || every <= 0 {
+				return nil, fmt.Errorf("errorSummaries.every: invalid duration %q", s.Every)
+			}
+			l.every = every
+		}
+	}
+	return l, nil
+}
+
+// failed records a failed attempt
+func (l *errorLog) failed(err error) {
+	now := l.now()
+	l.failures++
+	l.last = err
+	if l.failures == 1 {
+		l.since = now
+	}
+	if l.failures <= l.after {
+		l.printf("%s failed: %v\n", l.label, err)
+		if l.failures == l.after {
+			l.printf("%s keeps failing, further failures are summarized every %s\n", l.label, shortDuration(l.every))
+		}
+		l.summarized = now
+		return
+	}
+	if now.Sub(l.summarized) >= l.every {
+		l.printf("%s: %s for %s, %d attempts (last error: %v)\n", l.label, l.describe(), l.span(now), l.failures, err)
+		l.summarized = now
+	}
+}
+
+// succeeded records a successful attempt, reporting the recovery when
+// failures were being summarized
+func (l *errorLog) succeeded() {
+	if l.failures > l.after {
+		l.printf("%s is working again after %s, %d failed attempts\n", l.label, l.span(l.now()), l.failures)
+	}
+	l.failures = 0
+}
+
+// describe says how the attempts fail, telling an unreachable device apart
+// from other failures
+func (l *errorLog) describe() string {
+	if ExitCode(l.last) == ExitUnreachable {
+		return "unreachable"
+	}
+	return "failing"
+}
+
+// span is how long the attempts have been failing at now, to the minute
+// once past one
+func (l *errorLog) span(now time.Time) string {
+	d := now.Sub(l.since)
+	if d < time.Minute {
+		return shortDuration(d.Round(time.Second))
+	}
+	return shortDuration(d.Round(time.Minute))
+}
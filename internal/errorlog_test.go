@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestErrorLogSummaries(t *testing.T) {
+	now := time.Date(2024, 1, 10, 22, 0, 0, 0, time.UTC)
+	var lines []string
+	l, err := newErrorLog(Config{ErrorSummaries: &ErrorSummaries{After: 2, Every: "1h"}}, "Weather update")
+	if err != nil {
+		t.Fatalf("newErrorLog should not fail: %v", err)
+	}
+	l.now = func() time.Time { return now }
+	l.printf = func(format string, args ...interface{}) { lines = append(lines, fmt.Sprintf(format, args...)) }
+
+	offline := &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}
+	// One attempt a minute for three hours
+	for i := 0; i < 180; i++ {
+		l.failed(offline)
+		now = now.Add(time.Minute)
+	}
+	l.succeeded()
+
+	want := []string{
+		"Weather update failed: dial tcp: connection refused\n",
+		"Weather update failed: dial tcp: connection refused\n",
+		"Weather update keeps failing, further failures are summarized every 1h\n",
+		"Weather update: unreachable for 1h1m, 62 attempts (last error: dial tcp: connection refused)\n",
+		"Weather update: unreachable for 2h1m, 122 attempts (last error: dial tcp: connection refused)\n",
+		"Weather update is working again after 3h, 180 failed attempts\n",
+	}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("expected\n%q\ngot\n%q", want, lines)
+	}
+
+	// A single failure after recovering is printed again
+	lines = nil
+	l.failed(fmt.Errorf("bad payload"))
+	l.succeeded()
+	if len(lines) != 1 || lines[0] != "Weather update failed: bad payload\n" {
+		t.Errorf("expected the failure to be printed, got %q", lines)
+	}
+}
+
+func TestNewErrorLogInvalid(t *testing.T) {
+	for _, s := range []ErrorSummaries{{After: -1}, {Every: "hourly"}, {Every: "-5m"}} {
+		if _, err := newErrorLog(Config{ErrorSummaries: &s}, "Watch"); err == nil {
+			t.Errorf("expected %+v to be rejected", s)
+		}
+	}
+}
+
+func TestErrorLogSpan(t *testing.T) {
+	since := time.Date(2024, 1, 10, 22, 0, 0, 0, time.UTC)
+	l := &errorLog{since: since}
+	tests := map[time.Duration]string{
+		30*time.Second + 200*time.Millisecond: "30s",
+		45*time.Minute + 10*time.Second:       "45m",
+		3 * time.Hour:                         "3h",
+		3*time.Hour + 20*time.Minute:          "3h20m",
+		26*time.Hour + 29*time.Second:         "26h",
+	}
+	for d, want := range tests {
+		if got := l.span(since.Add(d)); got != want {
+			t.Errorf("span after %s = %q, expected %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Event stream types of the device API
+const (
+	eventState   = 1
+	eventLayout  = 2
+	eventEffects = 3
+)
+
+// deviceEvent is one change reported by the device event stream, e.g. a
+// state event with attr 2 (brightness) and value 40
+type deviceEvent struct {
+	Type  int
+	Attr  int
+	Value interface{}
+}
+
+// parseEventStream reads server-sent events until r ends, calling emit for
+// every event they carry
+func parseEventStream(r io.Reader, emit func(deviceEvent)) error {
+	scanner := bufio.NewScanner(r)
+	eventType := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id:"):
+			eventType, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "id:")))
+		case strings.HasPrefix(line, "data:"):
+			var data struct {
+				Events []struct {
+					Attr  int         `json:"attr"`
+					Value interface{} `json:"value"`
+				} `json:"events"`
+			}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &data); err != nil {
+				return fmt.Errorf("failed to parse event %q: %w", line, err)
+			}
+			for _, event := range data.Events {
+				emit(deviceEvent{Type: eventType, Attr: event.Attr, Value: event.Value})
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// WatchEvents subscribes to the device event stream for the given types. The
+// channel closes when ctx is done or the device ends the stream.
+func (d *Device) WatchEvents(ctx context.Context, types ...int) (<-chan deviceEvent, error) {
+	d.warnFirmware(ctx, featureEvents)
+	ids := make([]string, len(types))
+	for i, t := range types {
+		ids[i] = strconv.Itoa(t)
+	}
+	url := d.client.buildURL(d.GetConfig().IP, "api/v1/events?id="+strings.Join(ids, ","))
+	req, err := http.NewRequestWithContext(withAuthToken(ctx, d.GetConfig().Token), "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stream stays open, so the client has no timeout
+	resp, err := (&http.Client{Transport: authTokenMiddleware(apiTransport)}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("event stream request failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("event stream request failed with status %d", resp.StatusCode)
+	}
+
+	events := make(chan deviceEvent)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		parseEventStream(resp.Body, func(event deviceEvent) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return events, nil
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseEventStream(t *testing.T) {
+	stream := "id: 1\ndata: {\"events\":[{\"attr\":2,\"value\":40},{\"attr\":1,\"value\":true}]}\n\n" +
+		"id: 3\ndata: {\"events\":[{\"attr\":1,\"value\":\"Forest\"}]}\n\n"
+
+	var events []deviceEvent
+	if err := parseEventStream(strings.NewReader(stream), func(e deviceEvent) { events = append(events, e) }); err != nil {
+		t.Fatalf("parseEventStream should not fail: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %v", events)
+	}
+	if events[0] != (deviceEvent{Type: eventState, Attr: 2, Value: 40.0}) {
+		t.Errorf("unexpected brightness event %+v", events[0])
+	}
+	if events[2] != (deviceEvent{Type: eventEffects, Attr: 1, Value: "Forest"}) {
+		t.Errorf("unexpected effect event %+v", events[2])
+	}
+
+	if err := parseEventStream(strings.NewReader("id: 1\ndata: {oops\n"), func(deviceEvent) {}); err == nil {
+		t.Error("expected an error for malformed data")
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/test-token/events" || r.URL.Query().Get("id") != "1,3" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("id: 1\ndata: {\"events\":[{\"attr\":1,\"value\":false}]}\n\n"))
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.caps = &Capabilities{Model: "Shapes", Firmware: "9.2.4"}
+
+	events, err := device.WatchEvents(context.Background(), eventState, eventEffects)
+	if err != nil {
+		t.Fatalf("WatchEvents should not fail: %v", err)
+	}
+	if event := <-events; event != (deviceEvent{Type: eventState, Attr: 1, Value: false}) {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if _, ok := <-events; ok {
+		t.Error("expected the channel to close when the stream ends")
+	}
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Exit codes of the commands, one per failure class so scripts and CI jobs
+// can tell them apart
+const (
+	ExitFailure      = 1
+	ExitUnreachable  = 2
+	ExitUnauthorized = 3
+	ExitInvalidArgs  = 4
+)
+
+// ErrInvalidArgs matches errors caused by the command line rather than the
+// device
+var ErrInvalidArgs = errors.New("invalid arguments")
+
+type argsError struct {
+	err error
+}
+
+func (e *argsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *argsError) Unwrap() error {
+	return e.err
+}
+
+func (e *argsError) Is(target error) bool {
+	return target == ErrInvalidArgs
+}
+
+// invalidArgs marks err as a command line error, keeping its message
+func invalidArgs(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &argsError{err: err}
+}
+
+// usageError reports a command called the wrong way
+func usageError(usage string) error {
+	return invalidArgs(fmt.Errorf("usage: %s", usage))
+}
+
+// ExitCode returns the exit code for an error returned by RunCommand
+func ExitCode(err error) int {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrUnauthorized):
+		return ExitUnauthorized
+	case errors.Is(err, ErrInvalidArgs):
+		return ExitInvalidArgs
+	case errors.As(err, &netErr):
+		return ExitUnreachable
+	}
+	return ExitFailure
+}
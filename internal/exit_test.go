@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	client := newClient()
+	_, unreachable := client.getPower(context.Background(), closed.URL, "test-token")
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"success", nil, 0},
+		{"failure", errors.New("no config found"), ExitFailure},
+		{"unreachable", unreachable, ExitUnreachable},
+		{"unauthorized", fmt.Errorf("%w, pair again", ErrUnauthorized), ExitUnauthorized},
+		{"usage", usageError("run SCRIPT.lua"), ExitInvalidArgs},
+		{"bad flag", invalidArgs(errors.New("flag provided but not defined: -x")), ExitInvalidArgs},
+	}
+
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.expected {
+			t.Errorf("%s: ExitCode(%v) = %d, expected %d", tt.name, tt.err, got, tt.expected)
+		}
+	}
+}
+
+func TestRunCommandInvalidArgs(t *testing.T) {
+	defer func() { quietMode = false }()
+
+	err := RunCommand(context.Background(), []string{"--quiet", "no-such-command"})
+	if ExitCode(err) != ExitInvalidArgs {
+		t.Errorf("expected an unknown command to be invalid arguments, got %v", err)
+	}
+	if !QuietMode() {
+		t.Error("expected --quiet to be picked up before the command")
+	}
+
+	err = RunCommand(context.Background(), []string{"--quiet", "history", "--no-such-flag"})
+	if ExitCode(err) != ExitInvalidArgs {
+		t.Errorf("expected an unknown flag to be invalid arguments, got %v", err)
+	}
+}
+
+func TestCommandArgumentErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := map[string][]string{
+		"adaptive-brightness": {},
+		"api":                 {"get"},
+		"hue-sync":            {},
+		"import":              {},
+		"music":               {"--fps", "0"},
+		"now-playing":         {"--colors", "0"},
+		"palette":             {},
+		"stream":              {"--fps", "90"},
+		"watch-url":           {},
+		"weather":             {},
+	}
+	for name, args := range tests {
+		if err := commands[name].run(context.Background(), args); ExitCode(err) != ExitInvalidArgs {
+			t.Errorf("%s %v: expected invalid arguments, got %v", name, args, err)
+		}
+	}
+}
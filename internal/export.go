@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// panelShape is the outline of a panel type for drawing: the number of sides
+// and their length in layout units. Lines have two "sides", a segment.
+type panelShape struct {
+	sides  int
+	length float64
+}
+
+var panelShapes = map[int]panelShape{
+	0:  {3, 150}, // Aurora triangle
+	2:  {4, 100}, // Canvas square
+	3:  {4, 100}, // Canvas control square
+	4:  {4, 100}, // Canvas control square
+	7:  {6, 67},  // Shapes hexagon
+	8:  {3, 134}, // Shapes triangle
+	9:  {3, 67},  // Shapes mini triangle
+	14: {6, 134}, // Elements hexagon
+	15: {6, 134}, // Elements hexagon corner
+	17: {2, 134}, // Lines
+	18: {2, 134}, // Lines single zone
+}
+
+// panelOutline returns the corners of p in layout coordinates, rotated by
+// its orientation
+func panelOutline(p Panel, shape panelShape) [][2]float64 {
+	// Distance from the center to a corner of a regular polygon
+	radius := shape.length / (2 * math.Sin(math.Pi/float64(shape.sides)))
+	if shape.sides == 2 {
+		radius = shape.length / 2
+	}
+	start := math.Pi/2 + float64(p.O)*math.Pi/180
+	if shape.sides == 4 {
+		start += math.Pi / 4
+	}
+
+	points := make([][2]float64, shape.sides)
+	for i := range points {
+		angle := start + 2*math.Pi*float64(i)/float64(shape.sides)
+		points[i] = [2]float64{float64(p.X) + radius*math.Cos(angle), float64(p.Y) + radius*math.Sin(angle)}
+	}
+	return points
+}
+
+// layoutSVG draws the light panels of a layout with their IDs. The device's
+// y axis grows upwards, so it is flipped for SVG.
+func layoutSVG(layout Layout) string {
+	type outline struct {
+		panel  Panel
+		points [][2]float64
+	}
+	var outlines []outline
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range layout.LightPanels() {
+		shape, ok := panelShapes[p.ShapeType]
+		if !ok {
+			shape = panelShape{4, float64(max(layout.SideLength, 50))}
+		}
+		points := panelOutline(p, shape)
+		for _, pt := range points {
+			minX, maxX = math.Min(minX, pt[0]), math.Max(maxX, pt[0])
+			minY, maxY = math.Min(minY, pt[1]), math.Max(maxY, pt[1])
+		}
+		outlines = append(outlines, outline{p, points})
+	}
+	if len(outlines) == 0 {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+
+	const margin = 20
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%.0f %.0f %.0f %.0f">`+"\n",
+		minX-margin, -maxY-margin, maxX-minX+2*margin, maxY-minY+2*margin)
+	for _, o := range outlines {
+		coords := make([]string, len(o.points))
+		for i, pt := range o.points {
+			coords[i] = fmt.Sprintf("%.1f,%.1f", pt[0], -pt[1])
+		}
+		if len(o.points) == 2 {
+			fmt.Fprintf(&b, `  <polyline points="%s" fill="none" stroke="#555" stroke-width="12" stroke-linecap="round"/>`+"\n", strings.Join(coords, " "))
+		} else {
+			fmt.Fprintf(&b, `  <polygon points="%s" fill="#eee" stroke="#555" stroke-width="3"/>`+"\n", strings.Join(coords, " "))
+		}
+		fmt.Fprintf(&b, `  <text x="%d" y="%d" font-family="sans-serif" font-size="16" text-anchor="middle" dominant-baseline="middle">%s</text>`+"\n",
+			o.panel.X, -o.panel.Y, html.EscapeString(fmt.Sprint(o.panel.ID)))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// stateSnapshot is a shareable description of a device without its token
+type stateSnapshot struct {
+	Name        string           `json:"name"`
+	Model       string           `json:"model"`
+	Firmware    string           `json:"firmware"`
+	On          bool             `json:"on"`
+	Brightness  int              `json:"brightness"`
+	ColorMode   string           `json:"colorMode"`
+	Hue         int              `json:"hue"`
+	Saturation  int              `json:"saturation"`
+	ColorTemp   int              `json:"colorTemp"`
+	Effect      string           `json:"effect,omitempty"`
+	Effects     []string         `json:"effects"`
+	Orientation *LayoutTransform `json:"orientation,omitempty"`
+	Layout      Layout           `json:"layout"`
+}
+
+func captureState(ctx context.Context, device *Device) (stateSnapshot, error) {
+	ip, token := device.GetConfig().IP, device.GetConfig().Token
+	var snapshot stateSnapshot
+
+	info, err := device.client.getInfo(ctx, ip, token)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Name, _ = info["name"].(string)
+	snapshot.Model, _ = info["model"].(string)
+	snapshot.Firmware, _ = info["firmwareVersion"].(string)
+
+	state, err := device.client.getState(ctx, ip, token)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.On, snapshot.Brightness, snapshot.ColorMode = state.On, state.Brightness, state.ColorMode
+	snapshot.Hue, snapshot.Saturation, snapshot.ColorTemp = state.Hue, state.Sat, state.ColorTemp
+
+	if snapshot.Effect, err = device.client.getSelectedEffect(ctx, ip, token); err != nil {
+		return snapshot, err
+	}
+	effects, err := device.ListEffects(ctx)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Effects = []string{}
+	for _, effect := range effects {
+		if name, _ := effect["animName"].(string); name != "" {
+			snapshot.Effects = append(snapshot.Effects, name)
+		}
+	}
+	sort.Strings(snapshot.Effects)
+
+	snapshot.Orientation = device.GetConfig().LayoutTransform
+	snapshot.Layout, err = device.GetLayout(ctx)
+	return snapshot, err
+}
+
+func runExport(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError("export layout|state|pairing [--out FILE] [--svg FILE] [--ip IP]")
+	}
+
+	fs := newFlagSet("export " + args[0])
+	out := fs.String("out", "", "file to write the JSON to instead of stdout")
+	var svg, ip *string
+	var raw *bool
+	switch args[0] {
+	case "layout":
+		svg = fs.String("svg", "", "also draw the layout to this SVG file")
+		raw = fs.Bool("raw", false, "keep the device's orientation instead of the one saved from the layout view")
+	case "pairing":
+		ip = fs.String("ip", "", "paired device to export (defaults to the active device)")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return invalidArgs(err)
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	var data interface{}
+	switch args[0] {
+	case "layout":
+		layout, err := device.GetLayout(ctx)
+		if err != nil {
+			return err
+		}
+		if saved := device.GetConfig().LayoutTransform; saved != nil && !*raw {
+			layout = saved.apply(layout)
+		}
+		if *svg != "" {
+			if err := os.WriteFile(*svg, []byte(layoutSVG(layout)), 0644); err != nil {
+				return err
+			}
+			statusf("Drew %d panel(s) to %s\n", len(layout.LightPanels()), *svg)
+			if *out == "" {
+				return nil
+			}
+		}
+		data = layout
+	case "state":
+		if data, err = captureState(ctx, device); err != nil {
+			return err
+		}
+	case "pairing":
+		payload, err := configPairingPayload(device.GetConfig(), *ip)
+		if err != nil {
+			return err
+		}
+		data = json.RawMessage(payload)
+	default:
+		return fmt.Errorf("unknown export %q, expected layout, state or pairing", args[0])
+	}
+
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	encoded = append(encoded, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(encoded)
+		return err
+	}
+	perm := os.FileMode(0644)
+	if args[0] == "pairing" {
+		// The pairing holds the token
+		perm = 0600
+	}
+	return os.WriteFile(*out, encoded, perm)
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLayoutSVG(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 1, X: 0, Y: 0, ShapeType: 7},
+		{ID: 2, X: 100, Y: 58, O: 60, ShapeType: 7},
+		{ID: 3, X: 200, Y: 0, ShapeType: 17},
+		{ID: 0, X: 50, Y: -50, ShapeType: 12}, // controller
+	}}
+	svg := layoutSVG(layout)
+
+	var doc struct {
+		Polygons  []struct{} `xml:"polygon"`
+		Polylines []struct{} `xml:"polyline"`
+		Texts     []string   `xml:"text"`
+	}
+	if err := xml.Unmarshal([]byte(svg), &doc); err != nil {
+		t.Fatalf("expected valid SVG, got %v:\n%s", err, svg)
+	}
+	if len(doc.Polygons) != 2 || len(doc.Polylines) != 1 {
+		t.Errorf("expected 2 hexagons and 1 line, got %d and %d", len(doc.Polygons), len(doc.Polylines))
+	}
+	if strings.Join(doc.Texts, ",") != "1,2,3" {
+		t.Errorf("expected labels for the light panels only, got %v", doc.Texts)
+	}
+}
+
+func TestPanelOutline(t *testing.T) {
+	points := panelOutline(Panel{X: 10, Y: 20}, panelShape{4, 100})
+	for _, pt := range points {
+		if dx, dy := pt[0]-10, pt[1]-20; dx*dx+dy*dy < 4999 || dx*dx+dy*dy > 5001 {
+			t.Errorf("expected square corners 70.7 from the center, got %v", pt)
+		}
+	}
+}
+
+func TestCaptureState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/test-token":
+			w.Write([]byte(`{"name":"Shapes 1A2B","model":"NL42","firmwareVersion":"9.2.4"}`))
+		case "/api/v1/test-token/state":
+			w.Write([]byte(`{"on":{"value":true},"brightness":{"value":60},"colorMode":"effect"}`))
+		case "/api/v1/test-token/effects/select":
+			w.Write([]byte(`"Forest"`))
+		case "/api/v1/test-token/effects":
+			w.Write([]byte(`{"animations":[{"animName":"Ocean"},{"animName":"Forest"}]}`))
+		case "/api/v1/test-token/panelLayout/layout":
+			w.Write([]byte(`{"sideLength":67,"positionData":[{"panelId":1,"shapeType":7}]}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	snapshot, err := captureState(context.Background(), device)
+	if err != nil {
+		t.Fatalf("captureState should not fail: %v", err)
+	}
+	if snapshot.Model != "NL42" || snapshot.Effect != "Forest" || strings.Join(snapshot.Effects, ",") != "Forest,Ocean" {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	if len(snapshot.Layout.Panels) != 1 {
+		t.Errorf("expected the layout in the snapshot, got %+v", snapshot.Layout)
+	}
+
+	data, _ := json.Marshal(snapshot)
+	if strings.Contains(string(data), "test-token") {
+		t.Error("the snapshot must not contain the token")
+	}
+}
@@ -0,0 +1,73 @@
+package internal
+
+// recentEffectsLimit is how many recently applied effects are kept per device
+const recentEffectsLimit = 8
+
+// pushRecent moves name to the front of list, keeping at most limit names
+func pushRecent(list []string, name string, limit int) []string {
+	recent := []string{name}
+	for _, existing := range list {
+		if existing != name && len(recent) < limit {
+			recent = append(recent, existing)
+		}
+	}
+	return recent
+}
+
+// updatePaired changes the saved entry of the device at ip and saves the
+// config, creating the entry for a device paired before entries existed
+func (d *Device) updatePaired(ip string, update func(*PairedDevice)) error {
+	return d.updateConfig(func(config *Config) {
+		devices := copyDevices(config.Devices)
+		paired := devices[ip]
+		if paired.Token == "" && ip == config.IP {
+			paired.Token = config.Token
+		}
+		update(&paired)
+		devices[ip] = paired
+		config.Devices = devices
+	})
+}
+
+// rememberEffect records name as the most recently applied effect of the
+// active device, when recording is enabled
+func (d *Device) rememberEffect(name string) {
+	if !d.record || d.GetConfig().IP == "" || name == "" {
+		return
+	}
+	d.updatePaired(d.GetConfig().IP, func(paired *PairedDevice) {
+		paired.RecentEffects = pushRecent(paired.RecentEffects, name, recentEffectsLimit)
+	})
+}
+
+// RecentEffects returns the effects last applied on the active device,
+// newest first
+func (d *Device) RecentEffects() []string {
+	return d.GetConfig().Devices[d.GetConfig().IP].RecentEffects
+}
+
+// FavoriteEffects returns the favorite effects of the active device
+func (d *Device) FavoriteEffects() []string {
+	return d.GetConfig().Devices[d.GetConfig().IP].FavoriteEffects
+}
+
+// ToggleFavorite adds name to the favorites of the active device, or
+// removes it, and reports whether it is a favorite now
+func (d *Device) ToggleFavorite(name string) (bool, error) {
+	favorite := true
+	err := d.updatePaired(d.GetConfig().IP, func(paired *PairedDevice) {
+		var kept []string
+		for _, existing := range paired.FavoriteEffects {
+			if existing == name {
+				favorite = false
+			} else {
+				kept = append(kept, existing)
+			}
+		}
+		if favorite {
+			kept = append(kept, name)
+		}
+		paired.FavoriteEffects = kept
+	})
+	return favorite, err
+}
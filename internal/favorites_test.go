@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPushRecent(t *testing.T) {
+	recent := pushRecent([]string{"Blaze", "Forest", "Aurora"}, "Aurora", 3)
+	if !reflect.DeepEqual(recent, []string{"Aurora", "Blaze", "Forest"}) {
+		t.Errorf("expected Aurora to move to the front, got %v", recent)
+	}
+	recent = pushRecent(recent, "Snow", 3)
+	if !reflect.DeepEqual(recent, []string{"Snow", "Aurora", "Blaze"}) {
+		t.Errorf("expected the oldest effect to be dropped, got %v", recent)
+	}
+}
+
+func TestRecentAndFavoriteEffects(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.record = true
+	ctx := context.Background()
+
+	for _, name := range []string{"Blaze", "Forest", "Blaze"} {
+		if err := device.SelectEffect(ctx, name); err != nil {
+			t.Fatalf("SelectEffect should not fail: %v", err)
+		}
+	}
+	if got := device.RecentEffects(); !reflect.DeepEqual(got, []string{"Blaze", "Forest"}) {
+		t.Errorf("expected Blaze then Forest, got %v", got)
+	}
+
+	if favorite, err := device.ToggleFavorite("Forest"); err != nil || !favorite {
+		t.Fatalf("expected Forest to become a favorite, got %v, %v", favorite, err)
+	}
+	device.ToggleFavorite("Aurora")
+	if favorite, _ := device.ToggleFavorite("Forest"); favorite {
+		t.Error("expected toggling again to remove Forest")
+	}
+	if got := device.FavoriteEffects(); !reflect.DeepEqual(got, []string{"Aurora"}) {
+		t.Errorf("expected only Aurora to be a favorite, got %v", got)
+	}
+
+	// Both lists are saved with the device
+	saved, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig should not fail: %v", err)
+	}
+	paired := saved.Devices[server.URL]
+	if paired.Token != "test-token" || len(paired.RecentEffects) != 2 || len(paired.FavoriteEffects) != 1 {
+		t.Errorf("unexpected saved device %+v", paired)
+	}
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+)
+
+// Features gated on firmware, named the way they appear in messages
+const (
+	featureEvents      = "live events"
+	featureEffectWrite = "installing effects"
+	featureOrientation = "changing the orientation"
+)
+
+// featureMinimums is the first firmware with each feature, keyed by model
+// name. Models missing from a feature's map have always had it.
+var featureMinimums = map[string]map[string]string{
+	featureEvents:      {"Light Panels": "3.1.0"},
+	featureEffectWrite: {"Light Panels": "1.5.0"},
+	featureOrientation: {"Light Panels": "2.0.0"},
+}
+
+// firmwareCheck looks feature up for caps and returns a warning to show for
+// it, or "" when there is none
+func firmwareCheck(caps Capabilities, feature string) string {
+	if min := featureMinimums[feature][caps.Model]; min != "" && compareVersions(caps.Firmware, min) < 0 {
+		return fmt.Sprintf("%s firmware %s: %s may not work, it needs firmware %s or later", caps.Model, caps.Firmware, feature, min)
+	}
+	return ""
+}
+
+// warnFirmware warns about feature on the device's firmware, unless the
+// firmware is ignored. Unknown capabilities warn about nothing.
+func (d *Device) warnFirmware(ctx context.Context, feature string) {
+	if d.ignoreFirmware || d.warn == nil {
+		return
+	}
+	caps, err := d.GetCapabilities(ctx)
+	if err != nil {
+		return
+	}
+	if warning := firmwareCheck(caps, feature); warning != "" {
+		d.warn(warning)
+	}
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFirmwareCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		caps    Capabilities
+		feature string
+		warned  bool
+	}{
+		{"below minimum", Capabilities{Model: "Light Panels", Firmware: "2.4.0"}, featureEvents, true},
+		{"at minimum", Capabilities{Model: "Light Panels", Firmware: "3.1.0"}, featureEvents, false},
+		{"other model", Capabilities{Model: "Shapes", Firmware: "1.0.0"}, featureEvents, false},
+		{"below install minimum", Capabilities{Model: "Light Panels", Firmware: "1.4.2"}, featureEffectWrite, true},
+	}
+
+	for _, tt := range tests {
+		if warning := firmwareCheck(tt.caps, tt.feature); (warning != "") != tt.warned {
+			t.Errorf("%s: got warning %q", tt.name, warning)
+		}
+	}
+}
+
+func TestWarnFirmware(t *testing.T) {
+	var warnings []string
+	device := NewDevice()
+	device.caps = &Capabilities{Model: "Light Panels", Firmware: "1.4.2"}
+	device.warn = func(message string) { warnings = append(warnings, message) }
+	ctx := context.Background()
+
+	device.warnFirmware(ctx, featureEffectWrite)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "firmware 1.4.2") {
+		t.Fatalf("expected a warning with the firmware, got %v", warnings)
+	}
+
+	device.ignoreFirmware = true
+	device.warnFirmware(ctx, featureEffectWrite)
+	if len(warnings) != 1 {
+		t.Errorf("expected --ignore-firmware to silence the warning, got %v", warnings)
+	}
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// frameStats summarizes the frames of a streaming session
+type frameStats struct {
+	Sent      int
+	Dropped   int
+	SendTotal time.Duration
+	SendMax   time.Duration
+	Elapsed   time.Duration
+	// REST is set when frames went out as REST commands at a reduced rate
+	REST bool
+}
+
+// FPS is the achieved rate of sent frames
+func (s frameStats) FPS() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Sent) / s.Elapsed.Seconds()
+}
+
+// AvgSend is the mean time it took to render and send a frame
+func (s frameStats) AvgSend() time.Duration {
+	if s.Sent == 0 {
+		return 0
+	}
+	return s.SendTotal / time.Duration(s.Sent)
+}
+
+func (s frameStats) String() string {
+	stats := fmt.Sprintf("%d frames at %.1f fps, %d dropped, send avg %s max %s",
+		s.Sent, s.FPS(), s.Dropped, s.AvgSend().Round(time.Microsecond), s.SendMax.Round(time.Microsecond))
+	if s.REST {
+		stats += ", over REST (UDP blocked)"
+	}
+	return stats
+}
+
+// frameScheduler paces streamed frames at a target rate. When sending falls
+// a whole frame behind, the missed slots are dropped instead of sent late so
+// the animation keeps time on a congested network. Stats and the last frame
+// may be read while the stream runs.
+type frameScheduler struct {
+	interval time.Duration
+	started  time.Time
+	next     time.Time
+
+	mu    sync.Mutex
+	stats frameStats
+	frame []PanelColor
+}
+
+func newFrameScheduler(fps int) *frameScheduler {
+	return &frameScheduler{interval: time.Second / time.Duration(fps)}
+}
+
+// start makes the first frame due at now
+func (s *frameScheduler) start(now time.Time) {
+	s.started = now
+	s.next = now
+}
+
+// wait returns how long until the next frame is due
+func (s *frameScheduler) wait(now time.Time) time.Duration {
+	return max(0, s.next.Sub(now))
+}
+
+// sent records a frame that took latency to send, finishing at now, and
+// schedules the next one
+func (s *frameScheduler) sent(now time.Time, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Sent++
+	s.stats.SendTotal += latency
+	s.stats.SendMax = max(s.stats.SendMax, latency)
+	s.stats.Elapsed = now.Sub(s.started)
+
+	s.next = s.next.Add(s.interval)
+	if behind := now.Sub(s.next); behind >= s.interval {
+		missed := int(behind / s.interval)
+		s.next = s.next.Add(time.Duration(missed) * s.interval)
+		s.stats.Dropped += missed
+	}
+}
+
+// useREST lowers the rate to at most fps and marks the stats, once frames
+// go out as REST commands
+func (s *frameScheduler) useREST(fps int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval = max(s.interval, time.Second/time.Duration(fps))
+	s.stats.REST = true
+}
+
+// record keeps a copy of the frame being sent for LastFrame
+func (s *frameScheduler) record(frame []PanelColor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frame = append(s.frame[:0], frame...)
+}
+
+// LastFrame returns a copy of the most recently sent frame
+func (s *frameScheduler) LastFrame() []PanelColor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PanelColor(nil), s.frame...)
+}
+
+// Stats returns a snapshot of the session so far
+func (s *frameScheduler) Stats() frameStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrameScheduler(t *testing.T) {
+	s := newFrameScheduler(10)
+	t0 := time.Now()
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+
+	s.start(t0)
+	if wait := s.wait(t0); wait != 0 {
+		t.Errorf("the first frame should be due immediately, got %s", wait)
+	}
+
+	s.sent(at(5), 5*time.Millisecond)
+	if wait := s.wait(at(5)); wait != 95*time.Millisecond {
+		t.Errorf("expected to wait for the next slot, got %s", wait)
+	}
+
+	// Slightly late frames are sent right away without dropping
+	s.sent(at(140), 35*time.Millisecond)
+	if wait := s.wait(at(140)); wait != 60*time.Millisecond {
+		t.Errorf("expected the regular slot at 200ms, got %s", wait)
+	}
+	if s.Stats().Dropped != 0 {
+		t.Errorf("no frames should be dropped yet, got %d", s.Stats().Dropped)
+	}
+
+	// Falling more than a frame behind skips the missed slots
+	s.sent(at(450), 260*time.Millisecond)
+	stats := s.Stats()
+	if stats.Dropped != 1 {
+		t.Errorf("expected 1 dropped frame, got %d", stats.Dropped)
+	}
+	if wait := s.wait(at(450)); wait != 0 {
+		t.Errorf("the frame for 400ms should be due, got %s", wait)
+	}
+
+	if stats.Sent != 3 || stats.SendMax != 260*time.Millisecond || stats.AvgSend() != 100*time.Millisecond {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if fps := stats.FPS(); fps < 6.6 || fps > 6.7 {
+		t.Errorf("expected 3 frames in 450ms, got %.2f fps", fps)
+	}
+}
+
+func TestFrameSchedulerLastFrame(t *testing.T) {
+	s := newFrameScheduler(10)
+	frame := []PanelColor{{PanelID: 1, R: 255}}
+	s.record(frame)
+	frame[0].R = 0
+
+	last := s.LastFrame()
+	if len(last) != 1 || last[0].R != 255 {
+		t.Errorf("expected a copy of the recorded frame, got %v", last)
+	}
+}
+
+func TestStreamFPS(t *testing.T) {
+	if fps, err := (Config{}).streamFPS(); err != nil || fps != liveFPS {
+		t.Errorf("expected %d fps by default, got %d, %v", liveFPS, fps, err)
+	}
+	if fps, err := (Config{StreamFPS: 30}).streamFPS(); err != nil || fps != 30 {
+		t.Errorf("expected the configured 30 fps, got %d, %v", fps, err)
+	}
+	for _, invalid := range []int{-1, 61, 1000} {
+		if _, err := (Config{StreamFPS: invalid}).streamFPS(); err == nil {
+			t.Errorf("expected streamFps %d to be rejected", invalid)
+		}
+	}
+}
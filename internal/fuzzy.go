@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// fuzzyScore matches query against label the way a command palette does:
+// every word of the query must appear in label as a subsequence, in any
+// order. Matches at word starts and runs of consecutive characters score
+// higher, so "liv off" prefers "Living Room: Turn Off" to "Live Effects".
+func fuzzyScore(query, label string) (int, bool) {
+	target := []rune(strings.ToLower(label))
+	total := 0
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		score, ok := subsequenceScore([]rune(word), target)
+		if !ok {
+			return 0, false
+		}
+		total += score
+	}
+	// Among equal matches, shorter labels are closer
+	return total*100 - len(target), true
+}
+
+// subsequenceScore finds word in target as a subsequence, preferring the
+// start position that scores best
+func subsequenceScore(word, target []rune) (int, bool) {
+	best, found := 0, false
+	for start := range target {
+		if target[start] != word[0] {
+			continue
+		}
+		score, ok := scoreFrom(word, target, start)
+		if ok && (!found || score > best) {
+			best, found = score, true
+		}
+	}
+	return best, found
+}
+
+func scoreFrom(word, target []rune, start int) (int, bool) {
+	score, last := 0, -2
+	i := start
+	for _, r := range word {
+		for i < len(target) && target[i] != r {
+			i++
+		}
+		if i == len(target) {
+			return 0, false
+		}
+		switch {
+		case i == 0 || !unicode.IsLetter(target[i-1]) && !unicode.IsDigit(target[i-1]):
+			score += 10
+		case i == last+1:
+			score += 5
+		default:
+			score++
+		}
+		last = i
+		i++
+	}
+	return score, true
+}
+
+// fuzzyRank returns the indexes of the labels matching query, best first.
+// An empty query matches everything in the original order.
+func fuzzyRank(query string, labels []string) []int {
+	type match struct{ index, score int }
+	var matches []match
+	for i, label := range labels {
+		if score, ok := fuzzyScore(query, label); ok {
+			matches = append(matches, match{i, score})
+		}
+	}
+	if strings.TrimSpace(query) != "" {
+		sort.SliceStable(matches, func(a, b int) bool { return matches[a].score > matches[b].score })
+	}
+	ranked := make([]int, len(matches))
+	for i, m := range matches {
+		ranked[i] = m.index
+	}
+	return ranked
+}
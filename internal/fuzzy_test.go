@@ -0,0 +1,42 @@
+package internal
+
+import "testing"
+
+func TestFuzzyRank(t *testing.T) {
+	labels := []string{
+		"Live Effects",
+		"Turn Off",
+		"Living Room: Turn Off",
+		"Living Room: Turn On",
+		"Effect: Northern Lights",
+		"Effect: Nightlight",
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"liv off", "Living Room: Turn Off"},
+		{"north lig", "Effect: Northern Lights"},
+		{"live", "Live Effects"},
+		{"off", "Turn Off"},
+		{"nightl", "Effect: Nightlight"},
+	}
+	for _, tt := range tests {
+		ranked := fuzzyRank(tt.query, labels)
+		if len(ranked) == 0 || labels[ranked[0]] != tt.expected {
+			var got string
+			if len(ranked) > 0 {
+				got = labels[ranked[0]]
+			}
+			t.Errorf("fuzzyRank(%q) best match %q, expected %q", tt.query, got, tt.expected)
+		}
+	}
+
+	if ranked := fuzzyRank("xyz", labels); len(ranked) != 0 {
+		t.Errorf("expected no matches, got %v", ranked)
+	}
+	if ranked := fuzzyRank("", labels); len(ranked) != len(labels) || ranked[0] != 0 {
+		t.Errorf("expected an empty query to keep every label in order, got %v", ranked)
+	}
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultGalleryURL = "https://raw.githubusercontent.com/imedgar/nanoleaf-go/main/gallery/index.json"
+
+// galleryEntry is a shareable effect definition from the gallery index
+type galleryEntry struct {
+	Name        string                 `json:"name"`
+	Author      string                 `json:"author"`
+	Description string                 `json:"description"`
+	Effect      map[string]interface{} `json:"effect"`
+}
+
+func galleryURL(config Config) string {
+	if config.GalleryURL != "" {
+		return config.GalleryURL
+	}
+	return defaultGalleryURL
+}
+
+func fetchGallery(ctx context.Context, url string) ([]galleryEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gallery request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gallery request failed with status %d", resp.StatusCode)
+	}
+
+	var index struct {
+		Effects []galleryEntry `json:"effects"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("failed to parse gallery index: %w", err)
+	}
+
+	var entries []galleryEntry
+	for _, entry := range index.Effects {
+		if entry.Effect == nil {
+			continue
+		}
+		if entry.Name == "" {
+			entry.Name, _ = entry.Effect["animName"].(string)
+		}
+		if entry.Name == "" {
+			continue
+		}
+		entry.Effect["animName"] = entry.Name
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// effectPalette extracts the palette of an effect definition
+func effectPalette(effect map[string]interface{}) []paletteColor {
+	raw, _ := effect["palette"].([]interface{})
+
+	palette := make([]paletteColor, 0, len(raw))
+	for _, item := range raw {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hue, _ := entry["hue"].(float64)
+		sat, _ := entry["saturation"].(float64)
+		bri, _ := entry["brightness"].(float64)
+		palette = append(palette, paletteColor{Hue: int(hue), Saturation: int(sat), Brightness: int(bri)})
+	}
+	return palette
+}
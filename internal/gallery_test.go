@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchGallery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"effects":[
+			{"name":"Sunset","author":"someone","effect":{"animType":"fade","palette":[{"hue":15,"saturation":90,"brightness":100}]}},
+			{"effect":{"animName":"Named Inside","animType":"flow"}},
+			{"name":"Broken"}
+		]}`))
+	}))
+	defer server.Close()
+
+	entries, err := fetchGallery(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("fetchGallery should not fail: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 valid entries, got %d", len(entries))
+	}
+	if entries[0].Effect["animName"] != "Sunset" {
+		t.Errorf("expected the entry name to be used as animName, got %v", entries[0].Effect["animName"])
+	}
+	if entries[1].Name != "Named Inside" {
+		t.Errorf("expected the animName to be used as entry name, got %s", entries[1].Name)
+	}
+
+	palette := effectPalette(entries[0].Effect)
+	if len(palette) != 1 || palette[0] != (paletteColor{Hue: 15, Saturation: 90, Brightness: 100}) {
+		t.Errorf("unexpected palette %v", palette)
+	}
+}
+
+func TestGalleryURL(t *testing.T) {
+	if galleryURL(Config{}) != defaultGalleryURL {
+		t.Error("expected the default gallery URL when none is configured")
+	}
+	if galleryURL(Config{GalleryURL: "http://example.com/index.json"}) != "http://example.com/index.json" {
+		t.Error("expected the configured gallery URL")
+	}
+}
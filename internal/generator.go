@@ -0,0 +1,295 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// EffectGenerator renders client-side animations frame by frame for streaming.
+// NextFrame returns a color for every light panel at time t since the start.
+type EffectGenerator interface {
+	NextFrame(layout Layout, t time.Duration) []PanelColor
+}
+
+// frameAppender is implemented by generators that can draw into a frame the
+// caller reuses, which keeps streaming free of allocations per frame.
+// AppendFrame returns the frame NextFrame would, in dst[:0].
+type frameAppender interface {
+	AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor
+}
+
+// nextFrame draws the frame of generator at t, into dst when the generator
+// supports it
+func nextFrame(generator EffectGenerator, dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	if appender, ok := generator.(frameAppender); ok {
+		return appender.AppendFrame(dst, layout, t)
+	}
+	return generator.NextFrame(layout, t)
+}
+
+// GeneratorOptions tune a generator. Speed scales animation time (1 is the
+// default pace); generators that use colors fall back to their own palette
+// when Palette is empty.
+type GeneratorOptions struct {
+	Speed   float64
+	Palette []rgbColor
+}
+
+var generators = map[string]func(GeneratorOptions) EffectGenerator{}
+
+// RegisterGenerator makes a generator selectable by name
+func RegisterGenerator(name string, factory func(GeneratorOptions) EffectGenerator) {
+	generators[name] = factory
+}
+
+func newGenerator(name string, opts GeneratorOptions) (EffectGenerator, error) {
+	factory, ok := generators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown generator %q (available: %v)", name, generatorNames())
+	}
+	if opts.Speed <= 0 {
+		opts.Speed = 1
+	}
+	return factory(opts), nil
+}
+
+func generatorNames() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterGenerator("rainbow-wave", func(o GeneratorOptions) EffectGenerator { return rainbowWave{o} })
+	RegisterGenerator("fire", func(o GeneratorOptions) EffectGenerator { return fire{o} })
+	RegisterGenerator("matrix", func(o GeneratorOptions) EffectGenerator { return matrixRain{o} })
+	RegisterGenerator("plasma", func(o GeneratorOptions) EffectGenerator {
+		return plasma{withPalette(o, "#ff0080", "#7000ff", "#00c8ff", "#00ff80")}
+	})
+	RegisterGenerator("breathing", func(o GeneratorOptions) EffectGenerator {
+		return breathing{withPalette(o, "#00ffff", "#ff00ff", "#ffff00")}
+	})
+	RegisterGenerator("color-wipe", func(o GeneratorOptions) EffectGenerator {
+		return colorWipe{withPalette(o, "red", "orange", "yellow", "lime", "blue", "purple")}
+	})
+	RegisterGenerator("sparkle", func(o GeneratorOptions) EffectGenerator {
+		return &sparkle{opts: withPalette(o, "#101040", "white", "#a0c0ff"), levels: map[int]float64{}, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	})
+	RegisterGenerator("gradient-sweep", func(o GeneratorOptions) EffectGenerator {
+		return gradientSweep{withPalette(o, "#ff4000", "#ff0080", "#4000ff")}
+	})
+}
+
+// withPalette fills in a default palette when none was given
+func withPalette(opts GeneratorOptions, defaults ...string) GeneratorOptions {
+	if len(opts.Palette) > 0 {
+		return opts
+	}
+	for _, name := range defaults {
+		c, _ := parseColor(name)
+		opts.Palette = append(opts.Palette, c)
+	}
+	return opts
+}
+
+// rainbowWave scrolls the color wheel horizontally across the layout
+type rainbowWave struct{ opts GeneratorOptions }
+
+func (g rainbowWave) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g rainbowWave) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	return appendEachPanel(dst, layout, func(p Panel, x, y float64) rgbColor {
+		hue := int(x*360+t.Seconds()*60*g.opts.Speed) % 360
+		return hsbToRGB(hue, 100, 100)
+	})
+}
+
+// fire flickers between red and yellow, hotter towards the bottom
+type fire struct{ opts GeneratorOptions }
+
+func (g fire) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g fire) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	return appendEachPanel(dst, layout, func(p Panel, x, y float64) rgbColor {
+		flicker := noise(float64(p.ID), t.Seconds()*4*g.opts.Speed)
+		heat := math.Max(0, math.Min(1, (1-y)*0.7+flicker*0.5))
+		return hsbToRGB(int(heat*50), 100, int(30+heat*70))
+	})
+}
+
+// matrixRain drops green trails down columns of the layout
+type matrixRain struct{ opts GeneratorOptions }
+
+func (g matrixRain) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g matrixRain) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	return appendEachPanel(dst, layout, func(p Panel, x, y float64) rgbColor {
+		column := math.Floor(x * 8)
+		head := math.Mod(t.Seconds()*0.5*g.opts.Speed+noise(column, 0), 1)
+		// Distance behind the falling head, which moves from top (y=1) to bottom
+		behind := math.Mod(y-(1-head)+1, 1)
+		return hsbToRGB(120, 100, int(100*math.Exp(-behind*6)))
+	})
+}
+
+// plasma blends overlapping sine fields through the palette
+type plasma struct{ opts GeneratorOptions }
+
+func (g plasma) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g plasma) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	s := t.Seconds() * g.opts.Speed
+	return appendEachPanel(dst, layout, func(p Panel, x, y float64) rgbColor {
+		v := math.Sin(x*6+s) + math.Sin(y*6+s*1.3) + math.Sin((x+y)*4+s*0.7)
+		return paletteAt(g.opts.Palette, (v+3)/6)
+	})
+}
+
+// breathing fades the whole layout in and out, moving to the next palette
+// color on every breath
+type breathing struct{ opts GeneratorOptions }
+
+func (g breathing) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g breathing) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	breaths := t.Seconds() * g.opts.Speed / 4
+	level := (1 - math.Cos(2*math.Pi*breaths)) / 2
+	c := g.opts.Palette[int(breaths)%len(g.opts.Palette)]
+	c = scaleColor(c, 0.05+0.95*level)
+	return appendEachPanel(dst, layout, func(p Panel, x, y float64) rgbColor { return c })
+}
+
+// colorWipe fills the layout left to right with each palette color in turn
+type colorWipe struct{ opts GeneratorOptions }
+
+func (g colorWipe) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g colorWipe) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	wipes := t.Seconds() * g.opts.Speed / 3
+	n := len(g.opts.Palette)
+	current := g.opts.Palette[int(wipes)%n]
+	previous := g.opts.Palette[(int(wipes)+n-1)%n]
+	progress := wipes - math.Floor(wipes)
+	return appendEachPanel(dst, layout, func(p Panel, x, y float64) rgbColor {
+		if x <= progress {
+			return current
+		}
+		return previous
+	})
+}
+
+// sparkle flashes random panels over a dim background, using the first
+// palette color as background and the others for sparks
+type sparkle struct {
+	opts   GeneratorOptions
+	levels map[int]float64
+	colors map[int]rgbColor
+	last   time.Duration
+	rng    *rand.Rand
+}
+
+func (g *sparkle) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g *sparkle) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	if g.colors == nil {
+		g.colors = map[int]rgbColor{}
+	}
+	elapsed := (t - g.last).Seconds() * g.opts.Speed
+	g.last = t
+
+	background := g.opts.Palette[0]
+	sparks := g.opts.Palette
+	if len(sparks) > 1 {
+		sparks = sparks[1:]
+	}
+
+	return appendEachPanel(dst, layout, func(p Panel, x, y float64) rgbColor {
+		level := g.levels[p.ID] * math.Exp(-elapsed*3)
+		if g.rng.Float64() < elapsed*0.5 {
+			level = 1
+			g.colors[p.ID] = sparks[g.rng.Intn(len(sparks))]
+		}
+		g.levels[p.ID] = level
+		return mixColors(background, g.colors[p.ID], level)
+	})
+}
+
+// gradientSweep slides a gradient of the palette across the layout
+type gradientSweep struct{ opts GeneratorOptions }
+
+func (g gradientSweep) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g gradientSweep) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	offset := t.Seconds() * g.opts.Speed * 0.2
+	return appendEachPanel(dst, layout, func(p Panel, x, y float64) rgbColor {
+		return paletteAt(g.opts.Palette, x*0.5+offset)
+	})
+}
+
+// eachPanel builds a frame by computing a color for every light panel from
+// its normalized position
+func eachPanel(layout Layout, color func(p Panel, x, y float64) rgbColor) []PanelColor {
+	return appendEachPanel(nil, layout, color)
+}
+
+// appendEachPanel is eachPanel reusing the frame dst, which allocates
+// nothing once dst has room for every panel
+func appendEachPanel(dst []PanelColor, layout Layout, color func(p Panel, x, y float64) rgbColor) []PanelColor {
+	dst = dst[:0]
+	bounds := layout.extent()
+	for _, p := range layout.Panels {
+		if nonLightShapes[p.ShapeType] {
+			continue
+		}
+		x, y := bounds.normalized(p)
+		c := color(p, x, y)
+		dst = append(dst, PanelColor{PanelID: p.ID, R: c.R, G: c.G, B: c.B})
+	}
+	return dst
+}
+
+// paletteAt interpolates around the palette as a loop, pos wrapping at 1
+func paletteAt(palette []rgbColor, pos float64) rgbColor {
+	pos = (pos - math.Floor(pos)) * float64(len(palette))
+	i := int(pos) % len(palette)
+	return mixColors(palette[i], palette[(i+1)%len(palette)], pos-math.Floor(pos))
+}
+
+// mixColors blends from a to b by amount in [0, 1]
+func mixColors(a, b rgbColor, amount float64) rgbColor {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*amount + 0.5)
+	}
+	return rgbColor{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B)}
+}
+
+func scaleColor(c rgbColor, factor float64) rgbColor {
+	return mixColors(rgbColor{}, c, factor)
+}
+
+// noise is a smooth deterministic value in [0, 1] for a seed over time
+func noise(seed, t float64) float64 {
+	return (math.Sin(t*1.7+seed*12.9898)*0.5+math.Sin(t*3.1+seed*78.233)*0.3+math.Sin(t*5.3+seed*37.719)*0.2)*0.5 + 0.5
+}
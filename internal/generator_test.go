@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGeneratorsCoverLightPanels(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 1, X: 0, Y: 0, ShapeType: 7},
+		{ID: 2, X: 100, Y: 100, ShapeType: 7},
+		{ID: 3, X: 200, Y: 0, ShapeType: 7},
+		{ID: 0, X: 50, Y: 50, ShapeType: 12},
+	}}
+
+	for _, name := range generatorNames() {
+		generator, err := newGenerator(name, GeneratorOptions{})
+		if err != nil {
+			t.Fatalf("newGenerator(%q) should not fail: %v", name, err)
+		}
+		for _, at := range []time.Duration{0, 750 * time.Millisecond, 10 * time.Second} {
+			frame := generator.NextFrame(layout, at)
+			if len(frame) != 3 {
+				t.Fatalf("%s: expected 3 panel colors, got %d", name, len(frame))
+			}
+			for i, c := range frame {
+				if c.PanelID != layout.Panels[i].ID {
+					t.Errorf("%s: expected panel %d at index %d, got %d", name, layout.Panels[i].ID, i, c.PanelID)
+				}
+			}
+		}
+	}
+}
+
+func TestAppendFrameDoesNotAllocate(t *testing.T) {
+	var layout Layout
+	for i := 0; i < 60; i++ {
+		layout.Panels = append(layout.Panels, Panel{ID: i + 1, X: i % 10 * 100, Y: i / 10 * 100, ShapeType: 7})
+	}
+
+	for _, name := range generatorNames() {
+		generator, err := newGenerator(name, GeneratorOptions{})
+		if err != nil {
+			t.Fatalf("newGenerator(%q) should not fail: %v", name, err)
+		}
+		frame := generator.NextFrame(layout, 0)
+		at := time.Duration(0)
+		allocs := testing.AllocsPerRun(100, func() {
+			at += 16 * time.Millisecond
+			frame = nextFrame(generator, frame, layout, at)
+		})
+		if allocs != 0 {
+			t.Errorf("%s: expected no allocations per frame, got %v", name, allocs)
+		}
+		if want := generator.NextFrame(layout, at); !slices.Equal(frame, want) {
+			t.Errorf("%s: the appended frame differs from NextFrame", name)
+		}
+	}
+}
+
+func TestNewGeneratorUnknown(t *testing.T) {
+	if _, err := newGenerator("disco", GeneratorOptions{}); err == nil {
+		t.Error("newGenerator should fail for an unknown name")
+	}
+}
+
+func TestRegisterGenerator(t *testing.T) {
+	var speed float64
+	RegisterGenerator("test-solid", func(o GeneratorOptions) EffectGenerator {
+		speed = o.Speed
+		return rainbowWave{o}
+	})
+	defer delete(generators, "test-solid")
+
+	if _, err := newGenerator("test-solid", GeneratorOptions{}); err != nil {
+		t.Errorf("registered generator should be available: %v", err)
+	}
+	if speed != 1 {
+		t.Errorf("expected the default speed of 1, got %v", speed)
+	}
+}
+
+func TestNoiseRange(t *testing.T) {
+	for seed := 0.0; seed < 20; seed++ {
+		for tt := 0.0; tt < 10; tt += 0.37 {
+			if v := noise(seed, tt); v < 0 || v > 1 {
+				t.Fatalf("noise(%v, %v) = %v out of range", seed, tt, v)
+			}
+		}
+	}
+}
+
+func TestPaletteAt(t *testing.T) {
+	palette := []rgbColor{{255, 0, 0}, {0, 0, 255}}
+
+	if c := paletteAt(palette, 0); c != palette[0] {
+		t.Errorf("expected the first color at 0, got %v", c)
+	}
+	if c := paletteAt(palette, 0.25); c != (rgbColor{128, 0, 128}) {
+		t.Errorf("expected a blend halfway to the second color, got %v", c)
+	}
+	if c := paletteAt(palette, 1.5); c != palette[1] {
+		t.Errorf("expected positions to wrap around, got %v", c)
+	}
+}
+
+func TestWithPalette(t *testing.T) {
+	opts := withPalette(GeneratorOptions{}, "red", "blue")
+	if len(opts.Palette) != 2 || opts.Palette[0] != (rgbColor{255, 0, 0}) {
+		t.Errorf("expected the default palette, got %v", opts.Palette)
+	}
+
+	custom := GeneratorOptions{Palette: []rgbColor{{1, 2, 3}}}
+	if opts := withPalette(custom, "red"); len(opts.Palette) != 1 || opts.Palette[0] != (rgbColor{1, 2, 3}) {
+		t.Errorf("expected the custom palette to be kept, got %v", opts.Palette)
+	}
+}
+
+func TestBreathingCyclesPalette(t *testing.T) {
+	generator, _ := newGenerator("breathing", GeneratorOptions{Palette: []rgbColor{{255, 0, 0}, {0, 0, 255}}})
+	layout := Layout{Panels: []Panel{{ID: 1, ShapeType: 7}}}
+
+	first := generator.NextFrame(layout, 2*time.Second)[0]
+	second := generator.NextFrame(layout, 6*time.Second)[0]
+	if first.R != 255 || first.B != 0 {
+		t.Errorf("expected full red at the first breath's peak, got %+v", first)
+	}
+	if second.B != 255 || second.R != 0 {
+		t.Errorf("expected full blue at the second breath's peak, got %+v", second)
+	}
+}
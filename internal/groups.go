@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// groupNames returns the names of the device groups, sorted
+func (c Config) groupNames() []string {
+	names := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// groupMembers resolves the members of group name, each the IP or name of
+// a paired device, to IPs. Members that match no paired device are
+// returned in unknown.
+func (c Config) groupMembers(name string) (ips, unknown []string) {
+	paired := c.pairedDevices()
+	for _, member := range c.Groups[name] {
+		ip := member
+		if _, ok := paired[ip]; !ok {
+			ip = ""
+			for candidate := range paired {
+				if strings.EqualFold(c.Devices[candidate].Name, member) {
+					ip = candidate
+					break
+				}
+			}
+		}
+		if ip == "" {
+			unknown = append(unknown, member)
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	return ips, unknown
+}
+
+// SetGroupPower turns every device of group name on or off. A device that
+// fails does not stop the others; their errors are returned together.
+func (d *Device) SetGroupPower(ctx context.Context, name string, on bool) error {
+	config := d.GetConfig()
+	ips, _ := config.groupMembers(name)
+	if len(ips) == 0 {
+		return fmt.Errorf("group %s has no paired devices", name)
+	}
+	var errs []error
+	for _, ip := range ips {
+		if err := d.SetPairedPower(ctx, ip, on); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", config.displayName(ip), err))
+		}
+	}
+	return errors.Join(errs...)
+}
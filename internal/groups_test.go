@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroupMembers(t *testing.T) {
+	config := Config{
+		IP:      "192.168.1.100",
+		Token:   "first-token",
+		Devices: map[string]PairedDevice{"192.168.1.101": {Token: "second-token", Name: "Bedroom"}},
+		Groups:  map[string][]string{"upstairs": {"bedroom", "192.168.1.100", "Attic"}, "all": nil},
+	}
+	ips, unknown := config.groupMembers("upstairs")
+	if !reflect.DeepEqual(ips, []string{"192.168.1.101", "192.168.1.100"}) {
+		t.Errorf("expected members by name and IP, got %v", ips)
+	}
+	if !reflect.DeepEqual(unknown, []string{"Attic"}) {
+		t.Errorf("expected the unpaired member to be reported, got %v", unknown)
+	}
+	if names := config.groupNames(); !reflect.DeepEqual(names, []string{"all", "upstairs"}) {
+		t.Errorf("expected sorted group names, got %v", names)
+	}
+}
+
+func TestSetGroupPower(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, r.URL.Path+" "+string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	device := NewDevice()
+	device.config = Config{
+		IP:    server.URL,
+		Token: "first-token",
+		Devices: map[string]PairedDevice{
+			broken.URL: {Token: "broken-token", Name: "Hall"},
+		},
+		Groups: map[string][]string{"home": {broken.URL, server.URL}, "empty": {"Attic"}},
+	}
+
+	err := device.SetGroupPower(context.Background(), "home", true)
+	if err == nil || !strings.HasPrefix(err.Error(), "Hall: ") {
+		t.Errorf("expected the failing device to be named, got %v", err)
+	}
+	if len(bodies) != 1 || bodies[0] != `/api/v1/first-token/state {"on":{"value":true}}` {
+		t.Errorf("expected the other device to be turned on anyway, got %v", bodies)
+	}
+	if err := device.SetGroupPower(context.Background(), "empty", false); err == nil {
+		t.Error("expected an error for a group without paired devices")
+	}
+}
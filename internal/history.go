@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// historyEntry is one logged device command
+type historyEntry struct {
+	Time   time.Time `json:"time"`
+	Device string    `json:"device"`
+	Action string    `json:"action"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// historyMu serializes writes to the history log between concurrent UI commands
+var historyMu sync.Mutex
+
+// maxHistorySize is the size at which the history log is rotated, keeping
+// the log before it as the old log. About 10000 commands fit in one.
+var maxHistorySize int64 = 1 << 20
+
+func getHistoryPath() string {
+	return profilePath(".nanoleaf_history", ".jsonl")
+}
+
+// getOldHistoryPath is where the log goes when it is rotated, replacing
+// the one rotated before
+func getOldHistoryPath() string {
+	return getHistoryPath() + ".old"
+}
+
+// appendHistory adds an entry to the JSON lines history log
+func appendHistory(entry historyEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	// A failed rotation, e.g. while another process has the log open on
+	// Windows, is tried again on the next entry
+	if info, err := os.Stat(getHistoryPath()); err == nil && info.Size() >= maxHistorySize {
+		os.Rename(getHistoryPath(), getOldHistoryPath())
+	}
+	file, err := os.OpenFile(getHistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// readHistory returns the entries logged at or after since, oldest first,
+// keeping only the last limit entries when limit is positive. The old log
+// is read before the current one.
+func readHistory(since time.Time, limit int) ([]historyEntry, error) {
+	var entries []historyEntry
+	for _, path := range []string{getOldHistoryPath(), getHistoryPath()} {
+		var err error
+		if entries, err = readHistoryFile(path, entries, since, limit); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// readHistoryFile appends the entries of the log at path to entries
+func readHistoryFile(path string, entries []historyEntry, since time.Time, limit int) ([]historyEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry historyEntry
+		// Skip lines cut short by a crash rather than failing the whole log
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func (e historyEntry) String() string {
+	result := "ok"
+	if e.Error != "" {
+		result = "failed: " + e.Error
+	}
+	return fmt.Sprintf("%s  %s  %s  %s", e.Time.Local().Format("2006-01-02 15:04:05"), e.Device, e.Action, result)
+}
+
+// logAction records a device command and its outcome when recording is
+// enabled, and passes err through so calls can be wrapped
+func (d *Device) logAction(action string, err error) error {
+	if !d.record || d.GetConfig().IP == "" {
+		return err
+	}
+	entry := historyEntry{Time: time.Now(), Device: d.GetConfig().IP, Action: action}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	appendHistory(entry)
+	return err
+}
+
+func runHistory(ctx context.Context, args []string) error {
+	fs := newFlagSet("history")
+	limit := fs.Int("limit", 50, "number of entries to show, 0 for all")
+	since := fs.Duration("since", 0, "only show entries from this long ago, e.g. 12h")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	entries, err := readHistory(from, *limit)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No commands recorded")
+		return nil
+	}
+	for _, entry := range entries {
+		fmt.Println(entry)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadHistory(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	entries, err := readHistory(time.Time{}, 0)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("missing history should be empty: %v %v", entries, err)
+	}
+
+	start := time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)
+	for i, action := range []string{"on", "brightness 40", "effect Snowfall", "off"} {
+		appendHistory(historyEntry{Time: start.Add(time.Duration(i) * time.Hour), Device: "192.168.1.57", Action: action})
+	}
+	// A partially written line must not hide the rest of the log
+	file, _ := os.OpenFile(getHistoryPath(), os.O_APPEND|os.O_WRONLY, 0600)
+	file.WriteString("{\"time\":\n")
+	file.Close()
+	appendHistory(historyEntry{Time: start.Add(5 * time.Hour), Device: "192.168.1.57", Action: "on", Error: "timeout"})
+
+	entries, err = readHistory(time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("readHistory should not fail: %v", err)
+	}
+	if len(entries) != 5 || entries[4].Error != "timeout" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	entries, _ = readHistory(start.Add(2*time.Hour), 2)
+	if len(entries) != 2 || entries[0].Action != "off" || entries[1].Action != "on" {
+		t.Errorf("expected the last two entries, got %+v", entries)
+	}
+}
+
+func TestHistoryRotation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	original := maxHistorySize
+	maxHistorySize = 200
+	defer func() { maxHistorySize = original }()
+
+	start := time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		appendHistory(historyEntry{Time: start.Add(time.Duration(i) * time.Minute), Device: "192.168.1.57", Action: "on"})
+	}
+	for _, path := range []string{getHistoryPath(), getOldHistoryPath()} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", path, err)
+		}
+		// A log is rotated once it reaches the limit, so it may exceed it
+		// by the last entry
+		if info.Size() > maxHistorySize+100 {
+			t.Errorf("expected %s to stay near %d bytes, got %d", path, maxHistorySize, info.Size())
+		}
+	}
+
+	entries, err := readHistory(time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("readHistory should not fail: %v", err)
+	}
+	if len(entries) == 0 || len(entries) >= 10 {
+		t.Fatalf("expected the oldest entries to be dropped, got %d", len(entries))
+	}
+	for i := 1; i < len(entries); i++ {
+		if !entries[i].Time.After(entries[i-1].Time) {
+			t.Errorf("expected the old log to be read first, got %+v", entries)
+			break
+		}
+	}
+	if last := entries[len(entries)-1]; !last.Time.Equal(start.Add(9 * time.Minute)) {
+		t.Errorf("expected the latest entry last, got %+v", last)
+	}
+}
+
+func TestLogAction(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	device := NewDevice()
+	device.config.IP = "192.168.1.57"
+	device.logAction("on", nil)
+	if _, err := os.Stat(getHistoryPath()); !errors.Is(err, os.ErrNotExist) {
+		t.Error("actions should not be logged until recording is enabled")
+	}
+
+	device.record = true
+	failure := errors.New("connection refused")
+	if err := device.logAction("off", failure); err != failure {
+		t.Errorf("logAction should pass the error through, got %v", err)
+	}
+
+	entries, _ := readHistory(time.Time{}, 0)
+	if len(entries) != 1 || entries[0].Action != "off" || entries[0].Error != "connection refused" {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// holiday is a day with a themed scene. Countries lists the ISO 3166 codes
+// that celebrate it; an empty list means it is shown everywhere.
+type holiday struct {
+	Name      string
+	Countries []string
+	Colors    []string
+	date      func(year int) time.Time
+}
+
+func fixedDate(month time.Month, day int) func(int) time.Time {
+	return func(year int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+}
+
+// nthWeekday returns the nth weekday of month, e.g. the fourth Thursday
+func nthWeekday(n int, weekday time.Weekday, month time.Month) func(int) time.Time {
+	return func(year int) time.Time {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+		offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, offset+(n-1)*7)
+	}
+}
+
+// easterSunday computes the Gregorian date of Easter (anonymous Gregorian
+// algorithm)
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+}
+
+var holidays = []holiday{
+	{Name: "New Year's Day", Colors: []string{"gold", "white", "silver"}, date: fixedDate(time.January, 1)},
+	{Name: "Valentine's Day", Colors: []string{"red", "hotpink", "crimson"}, date: fixedDate(time.February, 14)},
+	{Name: "St. Patrick's Day", Countries: []string{"IE", "GB", "US", "CA", "AU"}, Colors: []string{"green", "limegreen", "gold"}, date: fixedDate(time.March, 17)},
+	{Name: "Easter", Colors: []string{"lightpink", "lightyellow", "lightskyblue", "palegreen"}, date: easterSunday},
+	{Name: "Canada Day", Countries: []string{"CA"}, Colors: []string{"red", "white"}, date: fixedDate(time.July, 1)},
+	{Name: "Independence Day", Countries: []string{"US"}, Colors: []string{"red", "white", "blue"}, date: fixedDate(time.July, 4)},
+	{Name: "Bastille Day", Countries: []string{"FR"}, Colors: []string{"blue", "white", "red"}, date: fixedDate(time.July, 14)},
+	{Name: "German Unity Day", Countries: []string{"DE"}, Colors: []string{"black", "red", "gold"}, date: fixedDate(time.October, 3)},
+	{Name: "Halloween", Colors: []string{"darkorange", "purple"}, date: fixedDate(time.October, 31)},
+	{Name: "Day of the Dead", Countries: []string{"MX"}, Colors: []string{"orange", "magenta", "gold"}, date: fixedDate(time.November, 2)},
+	{Name: "Thanksgiving", Countries: []string{"US"}, Colors: []string{"darkorange", "goldenrod", "saddlebrown"}, date: nthWeekday(4, time.Thursday, time.November)},
+	{Name: "Christmas", Colors: []string{"red", "green", "gold"}, date: fixedDate(time.December, 25)},
+	{Name: "New Year's Eve", Colors: []string{"gold", "purple", "silver"}, date: fixedDate(time.December, 31)},
+}
+
+// HolidayConfig selects the holidays of the holidays command. Countries are
+// ISO 3166 codes whose holidays are added to the ones shown everywhere;
+// Disabled turns holidays off by name and Colors replaces their scenes.
+// Days begin at midnight in Timezone, else the timezone of the config.
+type HolidayConfig struct {
+	Countries []string            `json:"countries,omitempty"`
+	Disabled  []string            `json:"disabled,omitempty"`
+	Colors    map[string][]string `json:"colors,omitempty"`
+	Timezone  string              `json:"timezone,omitempty"`
+}
+
+// findHoliday looks a built-in holiday up by name, ignoring case
+func findHoliday(name string) (holiday, bool) {
+	for _, h := range holidays {
+		if strings.EqualFold(h.Name, name) {
+			return h, true
+		}
+	}
+	return holiday{}, false
+}
+
+// holidayCalendar returns the holidays config enables, with their colors
+// overridden, after checking that every holiday it names exists
+func holidayCalendar(config HolidayConfig) ([]holiday, error) {
+	disabled := make(map[string]bool)
+	for _, name := range config.Disabled {
+		h, ok := findHoliday(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown holiday %q in holidays.disabled", name)
+		}
+		disabled[h.Name] = true
+	}
+	colors := make(map[string][]string)
+	for name, list := range config.Colors {
+		h, ok := findHoliday(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown holiday %q in holidays.colors", name)
+		}
+		colors[h.Name] = list
+	}
+
+	var calendar []holiday
+	for _, h := range holidays {
+		if disabled[h.Name] || !h.celebratedIn(config.Countries) {
+			continue
+		}
+		if list, ok := colors[h.Name]; ok {
+			h.Colors = list
+		}
+		calendar = append(calendar, h)
+	}
+	return calendar, nil
+}
+
+func (h holiday) celebratedIn(countries []string) bool {
+	if len(h.Countries) == 0 {
+		return true
+	}
+	for _, want := range countries {
+		for _, country := range h.Countries {
+			if strings.EqualFold(want, country) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// holidayOn returns the holiday of the calendar on the day of now
+func holidayOn(calendar []holiday, now time.Time) (holiday, bool) {
+	for _, h := range calendar {
+		date := h.date(now.Year())
+		if date.Month() == now.Month() && date.Day() == now.Day() {
+			return h, true
+		}
+	}
+	return holiday{}, false
+}
+
+// holidayEffect builds the flow effect of a holiday scene
+func holidayEffect(h holiday) (map[string]interface{}, error) {
+	palette := make([]paletteColor, len(h.Colors))
+	for i, name := range h.Colors {
+		color, err := parseColor(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", h.Name, err)
+		}
+		hue, sat, bri := color.hsb()
+		palette[i] = paletteColor{Hue: hue, Saturation: sat, Brightness: bri}
+	}
+	return paletteEffect(h.Name, "flow", palette, Layout{})
+}
+
+func runHolidays(ctx context.Context, args []string) error {
+	fs := newFlagSet("holidays")
+	countries := fs.String("countries", "", "comma separated ISO country codes, overrides holidays.countries in the config")
+	list := fs.Bool("list", false, "list this year's holidays and exit")
+	interval := fs.Duration("interval", time.Hour, "how often to check the date")
+	ignoreQuiet := addQuietHoursFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if err := checkInterval(*interval); err != nil {
+		return err
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	config := device.GetConfig()
+	settings := HolidayConfig{}
+	if config.Holidays != nil {
+		settings = *config.Holidays
+	}
+	if *countries != "" {
+		settings.Countries = strings.Split(*countries, ",")
+	}
+	calendar, err := holidayCalendar(settings)
+	if err != nil {
+		return err
+	}
+	loc, err := config.scheduleZone(settings.Timezone)
+	if err != nil {
+		return fmt.Errorf("holidays.timezone: %w", err)
+	}
+
+	if *list {
+		year := time.Now().Year()
+		sort.SliceStable(calendar, func(i, j int) bool {
+			return calendar[i].date(year).Before(calendar[j].date(year))
+		})
+		for _, h := range calendar {
+			fmt.Printf("%s  %-18s %s\n", h.date(year).Format("Jan 02"), h.Name, strings.Join(h.Colors, ", "))
+		}
+		return nil
+	}
+
+	quiet, err := newQuietGate(config, *ignoreQuiet)
+	if err != nil {
+		return err
+	}
+
+	errs, err := newErrorLog(device.GetConfig(), "Holiday update")
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	statusf("Watching for holidays every %s (ctrl+c to stop)\n", *interval)
+	statusf("%s\n", quiet)
+	watchManualChanges(ctx, device)
+	// last is the holiday whose scene is showing, "" when none is
+	last := ""
+	for {
+		held := quiet.held()
+		err := quiet.settle(ctx, device)
+		today, ok := holidayOn(calendar, time.Now().In(loc))
+		changed := err == nil && ok && today.Name != last && !held
+		if changed {
+			var effect map[string]interface{}
+			effect, err = holidayEffect(today)
+			if err == nil {
+				err = device.DisplayEffect(ctx, effect)
+			}
+			if err == nil {
+				statusf("Happy %s!\n", today.Name)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			errs.failed(err)
+		} else {
+			errs.succeeded()
+			if !held {
+				last = today.Name
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHolidayDates(t *testing.T) {
+	tests := []struct {
+		name string
+		year int
+		want string
+	}{
+		{"Easter", 2024, "2024-03-31"},
+		{"Easter", 2025, "2025-04-20"},
+		{"Thanksgiving", 2024, "2024-11-28"},
+		{"Thanksgiving", 2025, "2025-11-27"},
+		{"Halloween", 2025, "2025-10-31"},
+	}
+	for _, tt := range tests {
+		h, ok := findHoliday(tt.name)
+		if !ok {
+			t.Fatalf("%s should be a built-in holiday", tt.name)
+		}
+		if got := h.date(tt.year).Format("2006-01-02"); got != tt.want {
+			t.Errorf("%s %d: expected %s, got %s", tt.name, tt.year, tt.want, got)
+		}
+	}
+}
+
+func TestHolidayEffects(t *testing.T) {
+	for _, h := range holidays {
+		if _, err := holidayEffect(h); err != nil {
+			t.Errorf("%s: %v", h.Name, err)
+		}
+	}
+}
+
+func TestHolidayCalendar(t *testing.T) {
+	calendar, err := holidayCalendar(HolidayConfig{
+		Countries: []string{"us"},
+		Disabled:  []string{"valentine's day"},
+		Colors:    map[string][]string{"halloween": {"black", "orange"}},
+	})
+	if err != nil {
+		t.Fatalf("holidayCalendar should not fail: %v", err)
+	}
+
+	on := func(month time.Month, day int) (holiday, bool) {
+		return holidayOn(calendar, time.Date(2025, month, day, 20, 0, 0, 0, time.Local))
+	}
+	if h, ok := on(time.July, 4); !ok || h.Name != "Independence Day" {
+		t.Errorf("expected Independence Day in the US, got %v", h.Name)
+	}
+	if _, ok := on(time.July, 14); ok {
+		t.Error("Bastille Day should not be shown outside France")
+	}
+	if _, ok := on(time.February, 14); ok {
+		t.Error("a disabled holiday should not be shown")
+	}
+	if h, _ := on(time.October, 31); len(h.Colors) != 2 || h.Colors[0] != "black" {
+		t.Errorf("expected the overridden Halloween colors, got %v", h.Colors)
+	}
+	if _, ok := on(time.March, 3); ok {
+		t.Error("no holiday expected on an ordinary day")
+	}
+
+	if _, err := holidayCalendar(HolidayConfig{Disabled: []string{"Festivus"}}); err == nil {
+		t.Error("expected an error for an unknown holiday")
+	}
+}
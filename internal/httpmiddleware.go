@@ -0,0 +1,330 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPSettings tunes the requests to a device. Retries repeats the requests
+// that read from it after a network error or a 5xx response,
+// MinInterval spaces requests out for devices that drop bursts, and Log
+// appends every request to ~/.nanoleaf_http.log. The top-level http applies
+// to every device, the http of a paired device overrides it.
+type HTTPSettings struct {
+	Retries     int    `json:"retries,omitempty"`
+	MinInterval string `json:"minInterval,omitempty"`
+	Log         bool   `json:"log,omitempty"`
+}
+
+// maxRetries keeps a mistyped retries from stalling every command
+const maxRetries = 5
+
+// retryBackoff is the wait before the first retry, doubled for each next one
+const retryBackoff = 200 * time.Millisecond
+
+func (s HTTPSettings) validate() error {
+	if s.Retries < 0 || s.Retries > maxRetries {
+		return fmt.Errorf("retries must be from 0 to %d", maxRetries)
+	}
+	if s.MinInterval != "" {
+		if d, err := time.ParseDuration(s.MinInterval); err != nil || d < 0 {
+			return fmt.Errorf("minInterval: invalid duration %q", s.MinInterval)
+		}
+	}
+	return nil
+}
+
+// validateHTTP checks the top-level http and the http of every paired
+// device, so a config that would stall or flood a device is not loaded
+func (c Config) validateHTTP() error {
+	if c.HTTP != nil {
+		if err := c.HTTP.validate(); err != nil {
+			return fmt.Errorf("http: %w", err)
+		}
+	}
+	for _, ip := range slices.Sorted(maps.Keys(c.Devices)) {
+		if settings := c.Devices[ip].HTTP; settings != nil {
+			if err := settings.validate(); err != nil {
+				return fmt.Errorf("devices.%s.http: %w", ip, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s HTTPSettings) minInterval() time.Duration {
+	d, _ := time.ParseDuration(s.MinInterval)
+	return d
+}
+
+// httpSettings returns the settings for requests to host, a host:port as
+// in request URLs
+func (c Config) httpSettings(host string) HTTPSettings {
+	var settings HTTPSettings
+	if c.HTTP != nil {
+		settings = *c.HTTP
+	}
+	for ip, paired := range c.Devices {
+		if paired.HTTP != nil && apiAddress(ip) == host {
+			return *paired.HTTP
+		}
+	}
+	return settings
+}
+
+// apiAddress returns the host:port requests to the device at ip go to
+func apiAddress(ip string) string {
+	if strings.HasPrefix(ip, "http") {
+		if u, err := url.Parse(ip); err == nil {
+			return u.Host
+		}
+	}
+	return net.JoinHostPort(ip, "16021")
+}
+
+// middleware wraps a transport with behavior every request shares, so it
+// is not repeated in each endpoint of the client
+type middleware func(next http.RoundTripper) http.RoundTripper
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// chainMiddleware wraps base in middlewares, the first one outermost
+func chainMiddleware(base http.RoundTripper, middlewares ...middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		base = middlewares[i](base)
+	}
+	return base
+}
+
+// retryMiddleware repeats GET and HEAD requests up to retries times after a
+// network error or a 5xx response, backing off between attempts. Every
+// change to a device is a PUT, and not all of them are safe to repeat: a
+// brightness increment applied by an attempt whose answer got lost would
+// be applied twice.
+func retryMiddleware(retries int, backoff time.Duration) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != "GET" && req.Method != "HEAD" {
+				return next.RoundTrip(req)
+			}
+			wait := backoff
+			for attempt := 0; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				if (err == nil && resp.StatusCode < 500) || attempt >= retries {
+					return resp, err
+				}
+				if resp != nil {
+					resp.Body.Close()
+				}
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+				wait *= 2
+			}
+		})
+	}
+}
+
+// rateLimitMiddleware starts requests at least interval apart
+func rateLimitMiddleware(interval time.Duration) middleware {
+	var mu sync.Mutex
+	var next time.Time
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			now := time.Now()
+			start := next
+			if start.Before(now) {
+				start = now
+			}
+			next = start.Add(interval)
+			mu.Unlock()
+			if wait := start.Sub(now); wait > 0 {
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+			}
+			return rt.RoundTrip(req)
+		})
+	}
+}
+
+// logMiddleware passes a line for every request to logf. It runs before
+// authTokenMiddleware, so the paths it logs have no token.
+func logMiddleware(logf func(line string)) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			outcome := ""
+			if err != nil {
+				outcome = "error: " + err.Error()
+			} else {
+				outcome = fmt.Sprint(resp.StatusCode)
+			}
+			logf(fmt.Sprintf("%s %s %s %s %s %s", start.Format(time.RFC3339), req.URL.Host, req.Method, req.URL.Path, outcome, time.Since(start).Round(time.Millisecond)))
+			return resp, err
+		})
+	}
+}
+
+// authTokenMiddleware puts the token attached by withAuthToken into the
+// path of a request, /api/v1/state becoming /api/v1/TOKEN/state. It is
+// the innermost middleware, so the token only reaches the connection.
+func authTokenMiddleware(next http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		token, _ := req.Context().Value(authTokenKey{}).(string)
+		rest, ok := strings.CutPrefix(req.URL.Path, "/api/v1")
+		if token == "" || !ok || (rest != "" && rest[0] != '/') {
+			return next.RoundTrip(req)
+		}
+		if rest == "/" {
+			rest = ""
+		}
+		req = req.Clone(req.Context())
+		req.URL.Path = "/api/v1/" + token + rest
+		req.URL.RawPath = ""
+		return next.RoundTrip(req)
+	})
+}
+
+var httpLogMu sync.Mutex
+
+func getHTTPLogPath() string {
+	return profilePath(".nanoleaf_http", ".log")
+}
+
+// appendHTTPLog adds a line to the request log. The log is a debugging
+// aid, so failing to write it does not fail the request.
+func appendHTTPLog(line string) {
+	httpLogMu.Lock()
+	defer httpLogMu.Unlock()
+	file, err := os.OpenFile(getHTTPLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.WriteString(line + "\n")
+}
+
+// HostMetrics counts the requests to one device
+type HostMetrics struct {
+	Requests int `json:"requests"`
+	Failures int `json:"failures"`
+	// AverageMs is the mean time per request including retries
+	AverageMs float64 `json:"averageMs"`
+
+	total time.Duration
+}
+
+// httpMetrics keeps HostMetrics by host
+type httpMetrics struct {
+	mu    sync.Mutex
+	hosts map[string]*HostMetrics
+}
+
+// snapshot copies the metrics so far
+func (m *httpMetrics) snapshot() map[string]HostMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := make(map[string]HostMetrics, len(m.hosts))
+	for host, metrics := range m.hosts {
+		copied[host] = *metrics
+	}
+	return copied
+}
+
+// metricsMiddleware counts requests, failures and time spent in m. A
+// failure is a network error or a 5xx response.
+func metricsMiddleware(m *httpMetrics) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			elapsed := time.Since(start)
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if m.hosts == nil {
+				m.hosts = make(map[string]*HostMetrics)
+			}
+			host := m.hosts[req.URL.Host]
+			if host == nil {
+				host = &HostMetrics{}
+				m.hosts[req.URL.Host] = host
+			}
+			host.Requests++
+			if err != nil || resp.StatusCode >= 500 {
+				host.Failures++
+			}
+			host.total += elapsed
+			host.AverageMs = float64(host.total.Microseconds()) / 1000 / float64(host.Requests)
+			return resp, err
+		})
+	}
+}
+
+// deviceTransport runs each request through the middlewares of the device
+// it goes to, rebuilding them when the settings change, e.g. on a reload
+type deviceTransport struct {
+	base     http.RoundTripper
+	settings func(host string) HTTPSettings
+	metrics  *httpMetrics
+	// change, when set, is called before each request marked by
+	// asDeviceChange
+	change func()
+
+	mu     sync.Mutex
+	chains map[string]deviceChain
+}
+
+type deviceChain struct {
+	settings HTTPSettings
+	rt       http.RoundTripper
+}
+
+func newDeviceTransport(base http.RoundTripper, settings func(host string) HTTPSettings) *deviceTransport {
+	return &deviceTransport{base: base, settings: settings, metrics: &httpMetrics{}, chains: make(map[string]deviceChain)}
+}
+
+func (t *deviceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	settings := t.settings(req.URL.Host)
+	t.mu.Lock()
+	chain, ok := t.chains[req.URL.Host]
+	if !ok || chain.settings != settings {
+		middlewares := []middleware{metricsMiddleware(t.metrics)}
+		if settings.Retries > 0 {
+			middlewares = append(middlewares, retryMiddleware(min(settings.Retries, maxRetries), retryBackoff))
+		}
+		if settings.Log {
+			middlewares = append(middlewares, logMiddleware(appendHTTPLog))
+		}
+		if interval := settings.minInterval(); interval > 0 {
+			middlewares = append(middlewares, rateLimitMiddleware(interval))
+		}
+		middlewares = append(middlewares, authTokenMiddleware)
+		chain = deviceChain{settings: settings, rt: chainMiddleware(t.base, middlewares...)}
+		t.chains[req.URL.Host] = chain
+	}
+	t.mu.Unlock()
+	if t.change != nil && isDeviceChange(req.Context()) {
+		t.change()
+	}
+	return chain.rt.RoundTrip(req)
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChainMiddlewareOrder(t *testing.T) {
+	var order []string
+	tag := func(name string) middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		order = append(order, "base")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	req, _ := http.NewRequest("GET", "http://device/", nil)
+	chainMiddleware(base, tag("outer"), tag("inner")).RoundTrip(req)
+	if strings.Join(order, ",") != "outer,inner,base" {
+		t.Errorf("unexpected order %v", order)
+	}
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: chainMiddleware(http.DefaultTransport, retryMiddleware(2, time.Millisecond))}
+
+	resp, err := client.Get(server.URL)
+	if err != nil || resp.StatusCode != http.StatusNoContent || calls.Load() != 3 {
+		t.Fatalf("expected the third attempt to succeed, got %v, %v after %d call(s)", resp, err, calls.Load())
+	}
+
+	for _, method := range []string{"PUT", "POST"} {
+		calls.Store(0)
+		req, _ := http.NewRequest(method, server.URL, strings.NewReader(`{"brightness":{"increment":10}}`))
+		resp, err = client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+			t.Errorf("%s should not be retried, got %d call(s)", method, calls.Load())
+		}
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	var starts []time.Time
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		starts = append(starts, time.Now())
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	rt := chainMiddleware(base, rateLimitMiddleware(20*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "http://device/", nil)
+		rt.RoundTrip(req)
+	}
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < 15*time.Millisecond {
+			t.Errorf("request %d started %v after the previous one", i+1, gap)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://device/", nil)
+	rt = chainMiddleware(base, rateLimitMiddleware(time.Hour))
+	rt.RoundTrip(req)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Error("a canceled request should not wait for its turn")
+	}
+}
+
+func TestAuthTokenMiddleware(t *testing.T) {
+	var lines, paths []string
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.Path)
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+	})
+	rt := chainMiddleware(base, logMiddleware(func(line string) { lines = append(lines, line) }), authTokenMiddleware)
+	ctx := withAuthToken(context.Background(), "secret-token")
+	for _, url := range []string{"http://192.168.1.100:16021/api/v1/state", "http://192.168.1.100:16021/api/v1"} {
+		req, _ := http.NewRequestWithContext(ctx, "PUT", url, nil)
+		rt.RoundTrip(req)
+	}
+	req, _ := http.NewRequest("POST", "http://192.168.1.100:16021/api/v1/new", nil)
+	rt.RoundTrip(req)
+
+	if want := []string{"/api/v1/secret-token/state", "/api/v1/secret-token", "/api/v1/new"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("expected the device to get %v, got %v", want, paths)
+	}
+	if len(lines) != 3 || strings.Contains(strings.Join(lines, "\n"), "secret-token") || !strings.Contains(lines[0], "PUT /api/v1/state 204") {
+		t.Errorf("unexpected log %q", lines)
+	}
+}
+
+func TestDeviceHTTPSettings(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"value": true}`))
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	if _, err := device.Ping(context.Background()); err == nil {
+		t.Fatal("without retries the failed request should fail the ping")
+	}
+
+	device.config.Devices = map[string]PairedDevice{server.URL: {Token: "test-token", HTTP: &HTTPSettings{Retries: 1}}}
+	calls.Store(0)
+	if _, err := device.Ping(context.Background()); err != nil {
+		t.Fatalf("the retry of the device should recover, got %v", err)
+	}
+
+	metrics := device.HTTPMetrics()[strings.TrimPrefix(server.URL, "http://")]
+	if metrics.Requests != 2 || metrics.Failures != 1 {
+		t.Errorf("expected 2 requests with 1 failure, got %+v", metrics)
+	}
+}
+
+func TestHTTPSettingsValidate(t *testing.T) {
+	for _, settings := range []HTTPSettings{{Retries: -1}, {Retries: maxRetries + 1}, {MinInterval: "soon"}} {
+		if settings.validate() == nil {
+			t.Errorf("expected %+v to be rejected", settings)
+		}
+	}
+	if err := (HTTPSettings{Retries: 2, MinInterval: "50ms", Log: true}).validate(); err != nil {
+		t.Errorf("valid settings rejected: %v", err)
+	}
+}
+
+func TestLoadConfigValidatesHTTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"devices": {"192.168.1.100": {"token": "t", "http": {"retries": 50}}}}`), 0600)
+	if _, err := loadConfigFile(path); err == nil || !strings.Contains(err.Error(), "devices.192.168.1.100.http") {
+		t.Errorf("expected the http of the device to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// hueLightState is the subset of a Hue bridge light state that is mirrored
+type hueLightState struct {
+	On  bool `json:"on"`
+	Bri int  `json:"bri"`
+	Hue int  `json:"hue"`
+	Sat int  `json:"sat"`
+}
+
+type hueBridge struct {
+	httpClient *http.Client
+	host       string
+	user       string
+}
+
+func newHueBridge(host, user string) *hueBridge {
+	return &hueBridge{
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		host:       host,
+		user:       user,
+	}
+}
+
+func (b *hueBridge) lightState(ctx context.Context, light string) (hueLightState, error) {
+	var result struct {
+		State hueLightState `json:"state"`
+	}
+
+	url := fmt.Sprintf("%s/api/%s/lights/%s", b.baseURL(), b.user, light)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return result.State, err
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return result.State, fmt.Errorf("hue bridge request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return result.State, fmt.Errorf("hue bridge returned status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result.State, fmt.Errorf("failed to parse hue light state: %w", err)
+	}
+	return result.State, nil
+}
+
+func (b *hueBridge) baseURL() string {
+	if len(b.host) >= 4 && b.host[0:4] == "http" {
+		return b.host
+	}
+	return "http://" + b.host
+}
+
+// mirrorHueState applies the differences between prev and next to the device.
+// A nil prev applies the full state.
+func mirrorHueState(ctx context.Context, device *Device, prev *hueLightState, next hueLightState) error {
+	if prev == nil || prev.On != next.On {
+		var err error
+		if next.On {
+			err = device.TurnOn(ctx)
+		} else {
+			err = device.TurnOff(ctx)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !next.On {
+		return nil
+	}
+
+	if prev == nil || !prev.On || prev.Bri != next.Bri {
+		if err := device.SetBrightness(ctx, scaleRange(next.Bri, 254, 100)); err != nil {
+			return err
+		}
+	}
+	if prev == nil || !prev.On || prev.Hue != next.Hue || prev.Sat != next.Sat {
+		hue := scaleRange(next.Hue, 65536, 360) % 360
+		if err := device.SetColor(ctx, hue, scaleRange(next.Sat, 254, 100)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaleRange maps value from [0, from] onto [0, to] with rounding
+func scaleRange(value, from, to int) int {
+	if value <= 0 {
+		return 0
+	}
+	if value >= from {
+		return to
+	}
+	return (value*to + from/2) / from
+}
+
+func runHueSync(ctx context.Context, args []string) error {
+	fs := newFlagSet("hue-sync")
+	bridge := fs.String("bridge", "", "Hue bridge address")
+	user := fs.String("user", "", "Hue bridge API username")
+	light := fs.String("light", "1", "Hue light ID to mirror")
+	interval := fs.Duration("interval", time.Second, "poll interval")
+	ignoreQuiet := addQuietHoursFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if err := checkInterval(*interval); err != nil {
+		return err
+	}
+	if *bridge == "" || *user == "" {
+		return invalidArgs(fmt.Errorf("--bridge and --user are required"))
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	quiet, err := newQuietGate(device.GetConfig(), *ignoreQuiet)
+	if err != nil {
+		return err
+	}
+
+	statusf("Mirroring Hue light %s onto %s (ctrl+c to stop)\n", *light, device.GetDeviceIP())
+	statusf("%s\n", quiet)
+	watchManualChanges(ctx, device)
+	return syncHue(ctx, device, newHueBridge(*bridge, *user), quiet, *light, *interval)
+}
+
+func syncHue(ctx context.Context, device *Device, bridge *hueBridge, quiet *quietGate, light string, interval time.Duration) error {
+	errs, err := newErrorLog(device.GetConfig(), "Sync")
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *hueLightState
+	for {
+		// While held, last stays put so the differences are mirrored once
+		// the quiet hours end
+		held := quiet.held()
+		err := quiet.settle(ctx, device)
+		var state hueLightState
+		if err == nil {
+			state, err = bridge.lightState(ctx, light)
+		}
+		changed := err == nil && !held && (last == nil || *last != state)
+		if changed {
+			err = mirrorHueState(ctx, device, last, state)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			errs.failed(err)
+			last = nil
+		} else {
+			errs.succeeded()
+			if !held {
+				last = &state
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHueLightState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/hue-user/lights/3" {
+			t.Errorf("expected path /api/hue-user/lights/3, got %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"state":{"on":true,"bri":127,"hue":21845,"sat":254},"name":"Desk"}`))
+	}))
+	defer server.Close()
+
+	bridge := newHueBridge(server.URL, "hue-user")
+	state, err := bridge.lightState(context.Background(), "3")
+	if err != nil {
+		t.Fatalf("lightState should not fail: %v", err)
+	}
+
+	expected := hueLightState{On: true, Bri: 127, Hue: 21845, Sat: 254}
+	if state != expected {
+		t.Errorf("expected %+v, got %+v", expected, state)
+	}
+}
+
+func TestMirrorHueState(t *testing.T) {
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			// Capability lookup before setting a color
+			w.Write([]byte(`{"model":"NL42"}`))
+			return
+		}
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	ctx := context.Background()
+
+	state := hueLightState{On: true, Bri: 254, Hue: 21845, Sat: 127}
+	if err := mirrorHueState(ctx, device, nil, state); err != nil {
+		t.Fatalf("mirrorHueState should not fail: %v", err)
+	}
+	if len(payloads) != 3 {
+		t.Fatalf("expected power, brightness and color updates, got %d", len(payloads))
+	}
+
+	color := payloads[2]
+	if hue := color["hue"].(map[string]interface{})["value"].(float64); hue != 120 {
+		t.Errorf("expected hue 120, got %v", hue)
+	}
+	if sat := color["sat"].(map[string]interface{})["value"].(float64); sat != 50 {
+		t.Errorf("expected sat 50, got %v", sat)
+	}
+
+	payloads = nil
+	next := state
+	next.Bri = 127
+	if err := mirrorHueState(ctx, device, &state, next); err != nil {
+		t.Fatalf("mirrorHueState should not fail: %v", err)
+	}
+	if len(payloads) != 1 || payloads[0]["brightness"] == nil {
+		t.Errorf("expected a single brightness update, got %v", payloads)
+	}
+}
+
+func TestScaleRange(t *testing.T) {
+	tests := []struct {
+		value, from, to, expected int
+	}{
+		{0, 254, 100, 0},
+		{254, 254, 100, 100},
+		{127, 254, 100, 50},
+		{300, 254, 100, 100},
+		{65535, 65536, 360, 360},
+	}
+
+	for _, tt := range tests {
+		if got := scaleRange(tt.value, tt.from, tt.to); got != tt.expected {
+			t.Errorf("scaleRange(%d, %d, %d) = %d, expected %d", tt.value, tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
+
+func TestRunHueSyncRejectsInterval(t *testing.T) {
+	for _, interval := range []string{"0", "-5s"} {
+		err := runHueSync(context.Background(), []string{"--bridge", "10.0.0.2", "--user", "hue-user", "--interval", interval})
+		if ExitCode(err) != ExitInvalidArgs {
+			t.Errorf("expected --interval %s to be invalid arguments, got %v", interval, err)
+		}
+	}
+}
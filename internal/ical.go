@@ -0,0 +1,377 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// calendarEvent is a VEVENT of an iCalendar feed, with the part of its
+// recurrence rule the calendar command understands
+type calendarEvent struct {
+	Summary  string
+	Start    time.Time
+	Duration time.Duration
+	rule     *recurrence
+	// except are the starts of occurrences left out by EXDATE or replaced
+	// by an event of their own with a RECURRENCE-ID
+	except map[int64]bool
+}
+
+// recurrence is an RRULE: FREQ of DAILY, WEEKLY, MONTHLY or YEARLY with
+// INTERVAL, COUNT, UNTIL and, for WEEKLY, BYDAY without ordinals. Rules
+// with other parts have only their first occurrence, rather than ones at
+// the wrong times.
+type recurrence struct {
+	freq     string
+	interval int
+	count    int
+	until    time.Time
+	byDay    []time.Weekday
+}
+
+// maxOccurrences bounds the occurrences walked to find the one at a time,
+// e.g. a daily standup every day for 27 years
+const maxOccurrences = 10000
+
+var icalWeekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// icalProperty is a content line: NAME;PARAM=VALUE:VALUE
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// unfoldICal splits a feed into content lines, joining the continuation
+// lines that start with a space or tab
+func unfoldICal(data string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, "\r"))
+	}
+	return lines
+}
+
+// parseICalLine splits a content line, minding quoted parameter values
+// that may hold colons
+func parseICalLine(line string) icalProperty {
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icalProperty{name: strings.ToUpper(line)}
+	}
+	parts := strings.Split(line[:colon], ";")
+	prop := icalProperty{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return prop
+}
+
+// parseICalTime reads a DATE-TIME in UTC, in the zone of its TZID, or
+// floating in local. An all-day DATE reports allDay. A TZID Go does not
+// know, such as the Windows names of Outlook, is read as local.
+func parseICalTime(prop icalProperty, local *time.Location) (t time.Time, allDay bool, err error) {
+	value := prop.value
+	if prop.params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err = time.ParseInLocation("20060102", value, local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	loc := local
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if zone, zoneErr := time.LoadLocation(tzid); zoneErr == nil {
+			loc = zone
+		}
+	}
+	t, err = time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// parseICalDuration reads a DURATION such as PT1H30M or P1D
+func parseICalDuration(s string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(s, "+"), "P")
+	if !ok {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	var d time.Duration
+	number := ""
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == 'T':
+		case c >= '0' && c <= '9':
+			number += string(c)
+		default:
+			n, err := strconv.Atoi(number)
+			unit, known := units[c]
+			if err != nil || !known {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			d += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	return d, nil
+}
+
+// parseRecurrence reads an RRULE, returning nil for rules with parts
+// outside the supported subset
+func parseRecurrence(value string, local *time.Location) *recurrence {
+	r := &recurrence{interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, v, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.freq = strings.ToUpper(v)
+		case "INTERVAL":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil
+			}
+			r.interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil
+			}
+			r.count = n
+		case "UNTIL":
+			until, allDay, err := parseICalTime(icalProperty{value: v}, local)
+			if err != nil {
+				return nil
+			}
+			if allDay {
+				// A date includes the whole day
+				until = until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+			r.until = until
+		case "BYDAY":
+			for _, day := range strings.Split(v, ",") {
+				weekday, ok := icalWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil
+				}
+				r.byDay = append(r.byDay, weekday)
+			}
+		case "WKST":
+		default:
+			return nil
+		}
+	}
+	sort.Slice(r.byDay, func(i, j int) bool { return (r.byDay[i]+6)%7 < (r.byDay[j]+6)%7 })
+	switch r.freq {
+	case "DAILY", "MONTHLY", "YEARLY":
+		if len(r.byDay) > 0 {
+			return nil
+		}
+	case "WEEKLY":
+	default:
+		return nil
+	}
+	return r
+}
+
+// parseICal reads the events of a feed. Cancelled events, events marked
+// free (TRANSP:TRANSPARENT) and all-day events are left out, since none
+// of them is a meeting to light the panels for.
+func parseICal(data string, local *time.Location) ([]calendarEvent, error) {
+	var events []calendarEvent
+	// replaced are the occurrences that RECURRENCE-ID events replace, by UID
+	replaced := make(map[string][]time.Time)
+	uids := make(map[int]string)
+	var props []icalProperty
+	inEvent := false
+	for _, line := range unfoldICal(data) {
+		switch strings.ToUpper(line) {
+		case "BEGIN:VEVENT":
+			inEvent, props = true, nil
+			continue
+		case "END:VEVENT":
+			inEvent = false
+			event, uid, recurrenceID, keep, err := calendarEventOf(props, local)
+			if err != nil {
+				return nil, err
+			}
+			if !recurrenceID.IsZero() {
+				replaced[uid] = append(replaced[uid], recurrenceID)
+			}
+			if keep {
+				uids[len(events)] = uid
+				events = append(events, event)
+			}
+			continue
+		}
+		if inEvent {
+			props = append(props, parseICalLine(line))
+		}
+	}
+	for i := range events {
+		for _, t := range replaced[uids[i]] {
+			if events[i].rule != nil {
+				events[i].except[t.Unix()] = true
+			}
+		}
+	}
+	return events, nil
+}
+
+// calendarEventOf builds the event of a VEVENT's properties. keep is
+// false for events to leave out; recurrenceID is set for an event that
+// replaces an occurrence of the event with the same uid.
+func calendarEventOf(props []icalProperty, local *time.Location) (event calendarEvent, uid string, recurrenceID time.Time, keep bool, err error) {
+	event.except = make(map[int64]bool)
+	var end time.Time
+	var rrule string
+	allDay, skip := false, false
+	for _, prop := range props {
+		switch prop.name {
+		case "UID":
+			uid = prop.value
+		case "SUMMARY":
+			event.Summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\\`, `\`).Replace(prop.value)
+		case "DTSTART":
+			if event.Start, allDay, err = parseICalTime(prop, local); err != nil {
+				return event, uid, recurrenceID, false, fmt.Errorf("DTSTART: %w", err)
+			}
+		case "DTEND":
+			if end, _, err = parseICalTime(prop, local); err != nil {
+				return event, uid, recurrenceID, false, fmt.Errorf("DTEND: %w", err)
+			}
+		case "DURATION":
+			if event.Duration, err = parseICalDuration(prop.value); err != nil {
+				return event, uid, recurrenceID, false, err
+			}
+		case "RRULE":
+			rrule = prop.value
+		case "EXDATE":
+			for _, value := range strings.Split(prop.value, ",") {
+				prop.value = value
+				if t, _, err := parseICalTime(prop, local); err == nil {
+					event.except[t.Unix()] = true
+				}
+			}
+		case "RECURRENCE-ID":
+			recurrenceID, _, _ = parseICalTime(prop, local)
+		case "STATUS":
+			skip = skip || strings.EqualFold(prop.value, "CANCELLED")
+		case "TRANSP":
+			skip = skip || strings.EqualFold(prop.value, "TRANSPARENT")
+		}
+	}
+	if event.Start.IsZero() || allDay || skip {
+		return event, uid, recurrenceID, false, nil
+	}
+	if !end.IsZero() {
+		event.Duration = end.Sub(event.Start)
+	}
+	if rrule != "" {
+		event.rule = parseRecurrence(rrule, local)
+	}
+	return event, uid, recurrenceID, event.Duration > 0, nil
+}
+
+// occurrenceAt returns the start of the occurrence under way at t
+func (e calendarEvent) occurrenceAt(t time.Time) (time.Time, bool) {
+	if t.Before(e.Start) {
+		return time.Time{}, false
+	}
+	if e.rule == nil {
+		return e.Start, t.Before(e.Start.Add(e.Duration))
+	}
+	var found time.Time
+	e.rule.each(e.Start, func(start time.Time) bool {
+		if start.After(t) {
+			return false
+		}
+		if !e.except[start.Unix()] && t.Before(start.Add(e.Duration)) {
+			found = start
+		}
+		return true
+	})
+	return found, !found.IsZero()
+}
+
+// each calls fn with the occurrences from start in order, until fn returns
+// false or the rule ends. Every occurrence keeps the wall clock time of
+// start in its zone, across DST changes.
+func (r *recurrence) each(start time.Time, fn func(time.Time) bool) {
+	y, m, d := start.Date()
+	clock := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+	emitted := 0
+	emit := func(t time.Time) bool {
+		if !r.until.IsZero() && t.After(r.until) {
+			return false
+		}
+		if r.count > 0 && emitted >= r.count {
+			return false
+		}
+		emitted++
+		return fn(t)
+	}
+	days := r.byDay
+	if len(days) == 0 {
+		days = []time.Weekday{start.Weekday()}
+	}
+	// The Monday that starts the week of start, as WKST defaults to MO
+	monday := d - int((start.Weekday()+6)%7)
+
+	for k := 0; k < maxOccurrences; k++ {
+		switch r.freq {
+		case "DAILY":
+			if !emit(clock(y, m, d+k*r.interval)) {
+				return
+			}
+		case "WEEKLY":
+			for _, day := range days {
+				t := clock(y, m, monday+k*7*r.interval+int((day+6)%7))
+				if t.Before(start) {
+					continue
+				}
+				if !emit(t) {
+					return
+				}
+			}
+		case "MONTHLY", "YEARLY":
+			month, year := m+time.Month(k*r.interval), y
+			if r.freq == "YEARLY" {
+				month, year = m, y+k*r.interval
+			}
+			t := clock(year, month, d)
+			// The 31st is left out of shorter months, and the 29th of
+			// February out of other years
+			if t.Day() != d {
+				continue
+			}
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:standup
+SUMMARY:Daily standup
+DTSTART;TZID=Europe/Berlin:20241021T093000
+DTEND;TZID=Europe/Berlin:20241021T094500
+RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20241231
+EXDATE;TZID=Europe/Berlin:20241023T093000
+END:VEVENT
+BEGIN:VEVENT
+UID:standup
+RECURRENCE-ID;TZID=Europe/Berlin:20241025T093000
+SUMMARY:Daily standup (moved)
+DTSTART;TZID=Europe/Berlin:20241025T110000
+DURATION:PT15M
+END:VEVENT
+BEGIN:VEVENT
+UID:review
+SUMMARY:Design review\, Q4
+DTSTART:20241022T130000Z
+DTEND:20241022T140000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:lunch
+SUMMARY:Lunch
+DTSTART:20241022T100000Z
+DTEND:20241022T110000Z
+TRANSP:TRANSPARENT
+END:VEVENT
+BEGIN:VEVENT
+UID:holiday
+SUMMARY:Public holiday
+DTSTART;VALUE=DATE:20241022
+DTEND;VALUE=DATE:20241023
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled
+SUMMARY:Cancelled sync
+DTSTART:20241022T150000Z
+DTEND:20241022T160000Z
+STATUS:CANCELLED
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseICal(t *testing.T) {
+	berlin := mustZone(t, "Europe/Berlin")
+	events, err := parseICal(strings.ReplaceAll(testCalendar, "\n", "\r\n"), time.UTC)
+	if err != nil {
+		t.Fatalf("parseICal should not fail: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected the standup, its moved occurrence and the review, got %+v", events)
+	}
+	if events[2].Summary != "Design review, Q4" {
+		t.Errorf("expected the escaped comma read, got %q", events[2].Summary)
+	}
+
+	under := func(at time.Time) string {
+		var summaries []string
+		for _, event := range events {
+			if _, ok := event.occurrenceAt(at); ok {
+				summaries = append(summaries, event.Summary)
+			}
+		}
+		return strings.Join(summaries, ", ")
+	}
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"the first occurrence", time.Date(2024, 10, 21, 9, 40, 0, 0, berlin), "Daily standup"},
+		{"after it ends", time.Date(2024, 10, 21, 9, 45, 0, 0, berlin), ""},
+		{"a day BYDAY leaves out", time.Date(2024, 10, 22, 9, 35, 0, 0, berlin), ""},
+		{"an EXDATE", time.Date(2024, 10, 23, 9, 35, 0, 0, berlin), ""},
+		{"a moved occurrence's old time", time.Date(2024, 10, 25, 9, 35, 0, 0, berlin), ""},
+		{"a moved occurrence's new time", time.Date(2024, 10, 25, 11, 5, 0, 0, berlin), "Daily standup (moved)"},
+		{"the wall clock across DST", time.Date(2024, 10, 28, 9, 35, 0, 0, berlin), "Daily standup"},
+		{"after UNTIL", time.Date(2025, 1, 3, 9, 35, 0, 0, berlin), ""},
+		{"a single event in UTC", time.Date(2024, 10, 22, 13, 30, 0, 0, time.UTC), "Design review, Q4"},
+		{"an event marked free", time.Date(2024, 10, 22, 10, 30, 0, 0, time.UTC), ""},
+		{"a cancelled event", time.Date(2024, 10, 22, 15, 30, 0, 0, time.UTC), ""},
+	}
+	for _, tt := range tests {
+		if got := under(tt.at); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRecurrence(t *testing.T) {
+	start := time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC)
+	starts := func(rule string) string {
+		var dates []string
+		parseRecurrence(rule, time.UTC).each(start, func(t time.Time) bool {
+			dates = append(dates, t.Format("06-01-02"))
+			return len(dates) < 4
+		})
+		return strings.Join(dates, " ")
+	}
+	tests := map[string]string{
+		"FREQ=DAILY;INTERVAL=2":           "24-01-31 24-02-02 24-02-04 24-02-06",
+		"FREQ=DAILY;COUNT=2":              "24-01-31 24-02-01",
+		"FREQ=WEEKLY;BYDAY=TU,TH;WKST=MO": "24-02-01 24-02-06 24-02-08 24-02-13",
+		"FREQ=MONTHLY":                    "24-01-31 24-03-31 24-05-31 24-07-31",
+		"FREQ=YEARLY;INTERVAL=2":          "24-01-31 26-01-31 28-01-31 30-01-31",
+	}
+	for rule, want := range tests {
+		if got := starts(rule); got != want {
+			t.Errorf("%s: expected %s, got %s", rule, want, got)
+		}
+	}
+	for _, unsupported := range []string{"FREQ=MONTHLY;BYDAY=1MO", "FREQ=MONTHLY;BYMONTHDAY=15", "FREQ=HOURLY", "FREQ=DAILY;BYDAY=MO"} {
+		if parseRecurrence(unsupported, time.UTC) != nil {
+			t.Errorf("expected %s to be left out of the subset", unsupported)
+		}
+	}
+
+	if d, err := parseICalDuration("P1DT2H30M"); err != nil || d != 26*time.Hour+30*time.Minute {
+		t.Errorf("expected 26h30m, got %v, %v", d, err)
+	}
+	if _, err := parseICalDuration("1H"); err == nil {
+		t.Error("expected a duration without P to be rejected")
+	}
+}
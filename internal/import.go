@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// importedPairing is a device token found in another tool's config
+type importedPairing struct {
+	IP    string
+	Token string
+}
+
+// flatPairing matches single-device files such as the ones nanoleaf-cli and
+// this app write
+type flatPairing struct {
+	IP        string `json:"ip"`
+	Host      string `json:"host"`
+	Token     string `json:"token"`
+	AuthToken string `json:"auth_token"`
+}
+
+func (f flatPairing) pairing() (importedPairing, bool) {
+	ip, token := f.IP, f.Token
+	if ip == "" {
+		ip = f.Host
+	}
+	if token == "" {
+		token = f.AuthToken
+	}
+	return importedPairing{IP: ip, Token: token}, ip != "" && token != ""
+}
+
+// parsePairings reads tokens from the formats other tools store them in:
+// Home Assistant config entries (.storage/core.config_entries), the legacy
+// Home Assistant nanoleaf.conf keyed by host, and flat objects (or lists of
+// them) with an ip or host and a token or auth_token
+func parsePairings(data []byte) ([]importedPairing, error) {
+	var pairings []importedPairing
+
+	var list []flatPairing
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, f := range list {
+			if p, ok := f.pairing(); ok {
+				pairings = append(pairings, p)
+			}
+		}
+		return sortPairings(pairings)
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("not a JSON file: %w", err)
+	}
+
+	var homeAssistant struct {
+		Data struct {
+			Entries []struct {
+				Domain string      `json:"domain"`
+				Data   flatPairing `json:"data"`
+			} `json:"entries"`
+		} `json:"data"`
+	}
+	var flat flatPairing
+	switch {
+	case json.Unmarshal(data, &homeAssistant) == nil && len(homeAssistant.Data.Entries) > 0:
+		for _, entry := range homeAssistant.Data.Entries {
+			if p, ok := entry.Data.pairing(); ok && entry.Domain == "nanoleaf" {
+				pairings = append(pairings, p)
+			}
+		}
+	case json.Unmarshal(data, &flat) == nil && flat.Token+flat.AuthToken != "":
+		if p, ok := flat.pairing(); ok {
+			pairings = append(pairings, p)
+		}
+	default:
+		for host, raw := range object {
+			var entry flatPairing
+			if json.Unmarshal(raw, &entry) != nil {
+				continue
+			}
+			entry.IP = host
+			if p, ok := entry.pairing(); ok {
+				pairings = append(pairings, p)
+			}
+		}
+	}
+	return sortPairings(pairings)
+}
+
+func sortPairings(pairings []importedPairing) ([]importedPairing, error) {
+	if len(pairings) == 0 {
+		return nil, fmt.Errorf("no device tokens found")
+	}
+	sort.Slice(pairings, func(i, j int) bool { return pairings[i].IP < pairings[j].IP })
+	return pairings, nil
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs := newFlagSet("import")
+	ip := fs.String("ip", "", "device IP address")
+	token := fs.String("token", "", "auth token created by another app")
+	file := fs.String("file", "", "Home Assistant or nanoleaf-cli JSON file to read tokens from")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+
+	var pairings []importedPairing
+	switch {
+	case *file != "":
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		if pairings, err = parsePairings(data); err != nil {
+			return fmt.Errorf("%s: %w", *file, err)
+		}
+	case *ip != "" && *token != "":
+		pairings = []importedPairing{{IP: *ip, Token: strings.TrimSpace(*token)}}
+	default:
+		return invalidArgs(fmt.Errorf("expected --ip and --token, or --file"))
+	}
+
+	device := NewDevice()
+	if configExists() {
+		if err := device.LoadConfig(); err != nil {
+			return err
+		}
+	}
+
+	imported := 0
+	for _, p := range pairings {
+		if err := device.ImportPairing(ctx, p.IP, p.Token); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", p.IP, err)
+			continue
+		}
+		statusf("Imported %s\n", p.IP)
+		imported++
+	}
+	if imported == 0 {
+		return fmt.Errorf("no devices imported")
+	}
+	return nil
+}
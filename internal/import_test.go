@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParsePairings(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []importedPairing
+	}{
+		{
+			name: "home assistant config entries",
+			data: `{"version":1,"data":{"entries":[
+				{"domain":"hue","data":{"host":"192.168.1.2","api_key":"x"}},
+				{"domain":"nanoleaf","data":{"host":"192.168.1.100","token":"abc"}}
+			]}}`,
+			expected: []importedPairing{{IP: "192.168.1.100", Token: "abc"}},
+		},
+		{
+			name:     "legacy home assistant nanoleaf.conf",
+			data:     `{"192.168.1.101":{"token":"def"},"192.168.1.100":{"token":"abc"}}`,
+			expected: []importedPairing{{IP: "192.168.1.100", Token: "abc"}, {IP: "192.168.1.101", Token: "def"}},
+		},
+		{
+			name:     "flat object",
+			data:     `{"ip":"192.168.1.100","auth_token":"abc"}`,
+			expected: []importedPairing{{IP: "192.168.1.100", Token: "abc"}},
+		},
+		{
+			name:     "list of objects",
+			data:     `[{"host":"192.168.1.100","token":"abc"},{"host":"192.168.1.101"}]`,
+			expected: []importedPairing{{IP: "192.168.1.100", Token: "abc"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pairings, err := parsePairings([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("parsePairings should not fail: %v", err)
+			}
+			if !reflect.DeepEqual(pairings, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, pairings)
+			}
+		})
+	}
+
+	for _, data := range []string{`{"galleryUrl":"x"}`, `not json`} {
+		if _, err := parsePairings([]byte(data)); err == nil {
+			t.Errorf("parsePairings(%q) should fail", data)
+		}
+	}
+}
+
+func TestImportPairing(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/good-token/state/on" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"value":true}`))
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	ctx := context.Background()
+
+	if err := device.ImportPairing(ctx, server.URL, "bad-token"); err == nil {
+		t.Error("ImportPairing should fail when the device rejects the token")
+	}
+	if err := device.ImportPairing(ctx, server.URL, "good-token"); err != nil {
+		t.Fatalf("ImportPairing should not fail: %v", err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("config should be saved: %v", err)
+	}
+	if config.IP != server.URL || config.Token != "good-token" || config.Devices[server.URL].Token != "good-token" {
+		t.Errorf("imported device should be saved and active, got %+v", config)
+	}
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Devices drop connections when flooded with write commands, so bulk
+// installs go one effect at a time with a pause in between
+const (
+	defaultInstallDelay   = 500 * time.Millisecond
+	defaultInstallRetries = 2
+)
+
+// effectInstaller installs effects one after another
+type effectInstaller struct {
+	// delay is the pause between writes, doubled before each retry
+	delay   time.Duration
+	retries int
+	// skip names effects to leave out, e.g. those already installed
+	skip map[string]bool
+	// progress is called before each effect is installed
+	progress func(done, total int, name string)
+}
+
+func newEffectInstaller(delay time.Duration, retries int) *effectInstaller {
+	return &effectInstaller{delay: delay, retries: retries}
+}
+
+// skipInstalled leaves out effects the device already has, so a failed
+// install can be resumed
+func (in *effectInstaller) skipInstalled(ctx context.Context, device *Device) error {
+	effects, err := device.ListEffects(ctx)
+	if err != nil {
+		return err
+	}
+	in.skip = make(map[string]bool, len(effects))
+	for _, effect := range effects {
+		name, _ := effect["animName"].(string)
+		in.skip[name] = true
+	}
+	return nil
+}
+
+// InstallError is returned when an effect could not be installed after
+// retrying. Installed holds the effects that made it before.
+type InstallError struct {
+	Name      string
+	Installed []string
+	Err       error
+}
+
+func (e *InstallError) Error() string {
+	return fmt.Sprintf("failed to install %q after %d effect(s): %v", e.Name, len(e.Installed), e.Err)
+}
+
+func (e *InstallError) Unwrap() error {
+	return e.Err
+}
+
+// install adds effects in order, skipping the ones in skip. It returns the
+// names installed and the names skipped.
+func (in *effectInstaller) install(ctx context.Context, device *Device, effects []map[string]interface{}) (installed, skipped []string, err error) {
+	var pending []map[string]interface{}
+	for _, effect := range effects {
+		name, _ := effect["animName"].(string)
+		if in.skip[name] {
+			skipped = append(skipped, name)
+			continue
+		}
+		pending = append(pending, effect)
+	}
+
+	for i, effect := range pending {
+		name, _ := effect["animName"].(string)
+		if in.progress != nil {
+			in.progress(i, len(pending), name)
+		}
+		if i > 0 {
+			if err := sleepContext(ctx, in.delay); err != nil {
+				return installed, skipped, err
+			}
+		}
+		if err := in.add(ctx, device, effect); err != nil {
+			return installed, skipped, &InstallError{Name: name, Installed: installed, Err: err}
+		}
+		installed = append(installed, name)
+	}
+	return installed, skipped, nil
+}
+
+// add installs one effect, retrying with a growing pause when the write
+// fails for any reason other than the token
+func (in *effectInstaller) add(ctx context.Context, device *Device, effect map[string]interface{}) error {
+	pause := in.delay
+	for attempt := 0; ; attempt++ {
+		err := device.AddEffect(ctx, effect)
+		if err == nil || attempt >= in.retries || errors.Is(err, ErrUnauthorized) {
+			return err
+		}
+		pause *= 2
+		if err := sleepContext(ctx, pause); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext waits for d, or returns early when ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEffectInstallerRetriesAndResumes(t *testing.T) {
+	installed := map[string]bool{}
+	failures := map[string]int{"Blaze": 3}
+	var adds []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		switch payload["write"]["command"] {
+		case "requestAll":
+			var animations []map[string]string
+			for name := range installed {
+				animations = append(animations, map[string]string{"animName": name})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"animations": animations})
+		case "add":
+			name := payload["write"]["animName"].(string)
+			adds = append(adds, name)
+			if failures[name] > 0 {
+				failures[name]--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			installed[name] = true
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.caps = &Capabilities{Model: "Shapes", Firmware: "9.2.4"}
+	ctx := context.Background()
+	effects := []map[string]interface{}{{"animName": "Aurora"}, {"animName": "Blaze"}, {"animName": "Cascade"}}
+
+	var progress []int
+	installer := newEffectInstaller(0, 2)
+	installer.progress = func(done, total int, name string) {
+		progress = append(progress, done)
+		if total != 3 {
+			t.Errorf("expected 3 effects in total, got %d", total)
+		}
+	}
+
+	done, _, err := installer.install(ctx, device, effects)
+	var installErr *InstallError
+	if !errors.As(err, &installErr) || installErr.Name != "Blaze" {
+		t.Fatalf("expected Blaze to fail after retrying, got %v", err)
+	}
+	if len(done) != 1 || len(installErr.Installed) != 1 {
+		t.Errorf("expected Aurora to be installed before the failure, got %v", done)
+	}
+	if len(adds) != 4 {
+		t.Errorf("expected 1 install and 3 attempts at Blaze, got %v", adds)
+	}
+	if len(progress) != 2 || progress[1] != 1 {
+		t.Errorf("expected progress for Aurora and Blaze, got %v", progress)
+	}
+
+	// Blaze works on the next attempt, and Aurora is not installed again
+	adds = nil
+	installer = newEffectInstaller(0, 2)
+	if err := installer.skipInstalled(ctx, device); err != nil {
+		t.Fatalf("skipInstalled should not fail: %v", err)
+	}
+	done, skipped, err := installer.install(ctx, device, effects)
+	if err != nil {
+		t.Fatalf("resuming should not fail: %v", err)
+	}
+	if len(skipped) != 1 || skipped[0] != "Aurora" {
+		t.Errorf("expected Aurora to be skipped, got %v", skipped)
+	}
+	if len(done) != 2 || len(adds) != 2 {
+		t.Errorf("expected Blaze and Cascade to be installed, got %v (requests %v)", done, adds)
+	}
+}
+
+func TestEffectInstallerStopsOnRejectedToken(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.caps = &Capabilities{Model: "Shapes", Firmware: "9.2.4"}
+
+	_, _, err := newEffectInstaller(0, 2).install(context.Background(), device, []map[string]interface{}{{"animName": "Aurora"}})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("a rejected token should not be retried, got %d requests", requests)
+	}
+}
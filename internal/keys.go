@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// defaultGlobalKeys are bound when the config has no globalKeys
+var defaultGlobalKeys = map[string]string{
+	"ctrl+alt+space": "toggle",
+	"ctrl+alt+up":    "brightness +10",
+	"ctrl+alt+down":  "brightness -10",
+}
+
+// hotkeyTool writes bindings for a global hotkey daemon, since there is no
+// portable way to grab keys system-wide. Keys missing from keys cannot be
+// bound with the tool.
+type hotkeyTool struct {
+	mods map[string]string
+	keys map[string]string
+	// fkey is the format of function keys, F1 or f1
+	fkey    string
+	header  string
+	command func(exe, link string) string
+	line    func(mods []string, key, command string) string
+	// load tells how to start the tool with the written file
+	load string
+}
+
+var hotkeyTools = map[string]hotkeyTool{
+	// Linux with X11
+	"xbindkeys": {
+		mods: map[string]string{"ctrl": "Control", "alt": "Alt", "shift": "Shift", "super": "Mod4"},
+		keys: map[string]string{
+			"up": "Up", "down": "Down", "left": "Left", "right": "Right",
+			"space": "space", "enter": "Return", "escape": "Escape", "tab": "Tab",
+			"brightness-up": "XF86MonBrightnessUp", "brightness-down": "XF86MonBrightnessDown",
+			"kbd-brightness-up": "XF86KbdBrightnessUp", "kbd-brightness-down": "XF86KbdBrightnessDown",
+			"play": "XF86AudioPlay", "next": "XF86AudioNext", "previous": "XF86AudioPrev",
+			"mute": "XF86AudioMute", "volume-up": "XF86AudioRaiseVolume", "volume-down": "XF86AudioLowerVolume",
+		},
+		fkey:   "F%d",
+		header: "# nanoleaf-go key bindings for xbindkeys\n",
+		command: func(exe, link string) string {
+			return shellQuote(exe) + " uri " + shellQuote(link)
+		},
+		line: func(mods []string, key, command string) string {
+			keys := key
+			if len(mods) > 0 {
+				keys = strings.Join(mods, "+") + " + " + key
+			}
+			return fmt.Sprintf("%q\n    %s\n", command, keys)
+		},
+		load: "xbindkeys -f %s",
+	},
+	// macOS
+	"skhd": {
+		mods: map[string]string{"ctrl": "ctrl", "alt": "alt", "shift": "shift", "super": "cmd"},
+		keys: map[string]string{
+			"up": "up", "down": "down", "left": "left", "right": "right",
+			"space": "space", "enter": "return", "escape": "escape", "tab": "tab",
+			"brightness-up": "brightness_up", "brightness-down": "brightness_down",
+			"kbd-brightness-up": "illumination_up", "kbd-brightness-down": "illumination_down",
+			"play": "play", "next": "next", "previous": "previous",
+			"mute": "mute", "volume-up": "sound_up", "volume-down": "sound_down",
+		},
+		fkey:   "f%d",
+		header: "# nanoleaf-go key bindings for skhd\n",
+		command: func(exe, link string) string {
+			return shellQuote(exe) + " uri " + shellQuote(link)
+		},
+		line: func(mods []string, key, command string) string {
+			if len(mods) > 0 {
+				key = strings.Join(mods, " + ") + " - " + key
+			}
+			return fmt.Sprintf("%s : %s\n", key, command)
+		},
+		load: "skhd -c %s",
+	},
+	// Windows, where the brightness keys never reach applications
+	"autohotkey": {
+		mods: map[string]string{"ctrl": "^", "alt": "!", "shift": "+", "super": "#"},
+		keys: map[string]string{
+			"up": "Up", "down": "Down", "left": "Left", "right": "Right",
+			"space": "Space", "enter": "Enter", "escape": "Escape", "tab": "Tab",
+			"play": "Media_Play_Pause", "next": "Media_Next", "previous": "Media_Prev",
+			"mute": "Volume_Mute", "volume-up": "Volume_Up", "volume-down": "Volume_Down",
+		},
+		fkey:   "F%d",
+		header: "#Requires AutoHotkey v2.0\n; nanoleaf-go key bindings\n",
+		command: func(exe, link string) string {
+			return fmt.Sprintf(`"%s" uri "%s"`, exe, link)
+		},
+		line: func(mods []string, key, command string) string {
+			return fmt.Sprintf("%s%s::Run('%s', , \"Hide\")\n", strings.Join(mods, ""), key, strings.ReplaceAll(command, "'", "`'"))
+		},
+		load: "open %s, or put a shortcut to it in shell:startup",
+	},
+}
+
+// defaultHotkeyTool picks the hotkey daemon usual on goos
+func defaultHotkeyTool(goos string) string {
+	switch goos {
+	case "darwin":
+		return "skhd"
+	case "windows":
+		return "autohotkey"
+	}
+	return "xbindkeys"
+}
+
+// toolKey translates a key such as "ctrl+alt+up" or "brightness-up" to the
+// modifiers and key names of tool
+func (tool hotkeyTool) toolKey(spec string) ([]string, string, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(spec)), "+")
+	var mods []string
+	for _, mod := range parts[:len(parts)-1] {
+		switch mod {
+		case "control":
+			mod = "ctrl"
+		case "option":
+			mod = "alt"
+		case "cmd", "win", "meta":
+			mod = "super"
+		}
+		name, ok := tool.mods[mod]
+		if !ok {
+			return nil, "", fmt.Errorf("%q: unknown modifier %q (expected ctrl, alt, shift or super)", spec, mod)
+		}
+		mods = append(mods, name)
+	}
+
+	key := parts[len(parts)-1]
+	if name, ok := tool.keys[key]; ok {
+		return mods, name, nil
+	}
+	if len(key) == 1 && (key[0] >= 'a' && key[0] <= 'z' || key[0] >= '0' && key[0] <= '9') {
+		return mods, key, nil
+	}
+	var n int
+	if _, err := fmt.Sscanf(key, "f%d", &n); err == nil && n >= 1 && n <= 24 && key == fmt.Sprintf("f%d", n) {
+		return mods, fmt.Sprintf(tool.fkey, n), nil
+	}
+	return nil, "", fmt.Errorf("%q: the key %q cannot be bound here", spec, key)
+}
+
+// keyBindings writes the bindings of keys to run actions through exe, in
+// the format of tool
+func keyBindings(tool hotkeyTool, exe string, keys map[string]string) (string, error) {
+	specs := make([]string, 0, len(keys))
+	for spec := range keys {
+		specs = append(specs, spec)
+	}
+	sort.Strings(specs)
+
+	var b strings.Builder
+	b.WriteString(tool.header)
+	for _, spec := range specs {
+		mods, key, err := tool.toolKey(spec)
+		if err != nil {
+			return "", err
+		}
+		link, err := actionLink(keys[spec])
+		if err != nil {
+			return "", fmt.Errorf("%q: %w", spec, err)
+		}
+		b.WriteString(tool.line(mods, key, tool.command(exe, link)))
+	}
+	return b.String(), nil
+}
+
+func runBindKeys(ctx context.Context, args []string) error {
+	fs := newFlagSet("bind-keys")
+	toolName := fs.String("tool", defaultHotkeyTool(runtime.GOOS), "hotkey daemon to write bindings for (xbindkeys, skhd or autohotkey)")
+	out := fs.String("out", "", "file to write the bindings to (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	tool, ok := hotkeyTools[*toolName]
+	if !ok {
+		return invalidArgs(fmt.Errorf("unknown tool %q, expected xbindkeys, skhd or autohotkey", *toolName))
+	}
+
+	keys := defaultGlobalKeys
+	if config, err := loadConfig(); err == nil && len(config.GlobalKeys) > 0 {
+		keys = config.GlobalKeys
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	bindings, err := keyBindings(tool, exe, keys)
+	if err != nil {
+		return invalidArgs(err)
+	}
+
+	if *out == "" {
+		fmt.Print(bindings)
+		return nil
+	}
+	if err := os.WriteFile(*out, []byte(bindings), 0644); err != nil {
+		return err
+	}
+	statusf("Wrote %d binding(s) to %s, to use them: "+tool.load+"\n", len(keys), *out, *out)
+	return nil
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyBindings(t *testing.T) {
+	keys := map[string]string{
+		"ctrl+alt+up":   "brightness +10",
+		"brightness-up": "effect Northern Lights",
+		"super+f5":      "toggle",
+	}
+	tests := map[string]string{
+		"xbindkeys": `"'/usr/bin/nanoleaf-go' uri 'nanoleaf://effect/Northern%20Lights'"
+    XF86MonBrightnessUp
+"'/usr/bin/nanoleaf-go' uri 'nanoleaf://brightness/+10'"
+    Control+Alt + Up
+"'/usr/bin/nanoleaf-go' uri 'nanoleaf://toggle'"
+    Mod4 + F5
+`,
+		"skhd": `brightness_up : '/usr/bin/nanoleaf-go' uri 'nanoleaf://effect/Northern%20Lights'
+ctrl + alt - up : '/usr/bin/nanoleaf-go' uri 'nanoleaf://brightness/+10'
+cmd - f5 : '/usr/bin/nanoleaf-go' uri 'nanoleaf://toggle'
+`,
+	}
+	for name, want := range tests {
+		tool := hotkeyTools[name]
+		got, err := keyBindings(tool, "/usr/bin/nanoleaf-go", keys)
+		if err != nil {
+			t.Fatalf("%s: keyBindings should not fail: %v", name, err)
+		}
+		if got != tool.header+want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, want, strings.TrimPrefix(got, tool.header))
+		}
+	}
+
+	got, err := keyBindings(hotkeyTools["autohotkey"], `C:\nanoleaf-go.exe`, map[string]string{"ctrl+shift+k": "macro it's late"})
+	if err != nil {
+		t.Fatalf("keyBindings should not fail: %v", err)
+	}
+	if want := "^+k::Run('\"C:\\nanoleaf-go.exe\" uri \"nanoleaf://macro/it%27s%20late\"', , \"Hide\")\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	for _, bad := range []map[string]string{
+		{"brightness-up": "toggle"},
+		{"hyper+a": "toggle"},
+		{"ctrl+f30": "toggle"},
+		{"ctrl+a": "dance"},
+	} {
+		if _, err := keyBindings(hotkeyTools["autohotkey"], "nanoleaf-go", bad); err == nil {
+			t.Errorf("expected %v to be rejected for autohotkey", bad)
+		}
+	}
+}
+
+func TestActionLink(t *testing.T) {
+	for _, line := range []string{"toggle", "brightness -10", "effect Northern Lights", "preset 2", "macro movie night"} {
+		link, err := actionLink(line)
+		if err != nil {
+			t.Fatalf("actionLink(%q) should not fail: %v", line, err)
+		}
+		if back, err := uriAction(link); err != nil || back != line {
+			t.Errorf("expected %s to run %q, got %q, %v", link, line, back, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package internal
+
+// Panel is one light panel in the device's layout
+type Panel struct {
+	ID        int `json:"panelId"`
+	X         int `json:"x"`
+	Y         int `json:"y"`
+	O         int `json:"o"`
+	ShapeType int `json:"shapeType"`
+}
+
+// Layout is the physical arrangement of a device's panels
+type Layout struct {
+	SideLength int     `json:"sideLength"`
+	Panels     []Panel `json:"positionData"`
+}
+
+// Shape types that are part of the layout but do not emit light
+var nonLightShapes = map[int]bool{
+	1:  true, // Rhythm module
+	12: true, // Shapes controller
+	16: true, // Lines connector
+	19: true, // Controller cap
+	20: true, // Power connector
+}
+
+// LightPanels returns the panels that can display colors
+func (l Layout) LightPanels() []Panel {
+	panels := make([]Panel, 0, len(l.Panels))
+	for _, p := range l.Panels {
+		if !nonLightShapes[p.ShapeType] {
+			panels = append(panels, p)
+		}
+	}
+	return panels
+}
+
+// Normalized maps a panel position into [0, 1] across the layout's extent,
+// with y growing upwards as in the device's coordinate system
+func (l Layout) Normalized(p Panel) (x, y float64) {
+	return l.extent().normalized(p)
+}
+
+// layoutExtent is the range of panel positions in a layout
+type layoutExtent struct {
+	minX, maxX, minY, maxY int
+}
+
+// extent finds the range of the panel positions, so that normalizing every
+// panel of a frame takes one pass over the layout rather than one per panel
+func (l Layout) extent() layoutExtent {
+	if len(l.Panels) == 0 {
+		return layoutExtent{}
+	}
+	e := layoutExtent{l.Panels[0].X, l.Panels[0].X, l.Panels[0].Y, l.Panels[0].Y}
+	for _, q := range l.Panels {
+		e.minX, e.maxX = min(e.minX, q.X), max(e.maxX, q.X)
+		e.minY, e.maxY = min(e.minY, q.Y), max(e.maxY, q.Y)
+	}
+	return e
+}
+
+func (e layoutExtent) normalized(p Panel) (x, y float64) {
+	if e.maxX > e.minX {
+		x = float64(p.X-e.minX) / float64(e.maxX-e.minX)
+	}
+	if e.maxY > e.minY {
+		y = float64(p.Y-e.minY) / float64(e.maxY-e.minY)
+	}
+	return x, y
+}
@@ -0,0 +1,39 @@
+package internal
+
+import "testing"
+
+func TestLayoutLightPanels(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 1, ShapeType: 7},
+		{ID: 0, ShapeType: 12},
+		{ID: 2, ShapeType: 8},
+		{ID: 3, ShapeType: 20},
+	}}
+
+	panels := layout.LightPanels()
+	if len(panels) != 2 || panels[0].ID != 1 || panels[1].ID != 2 {
+		t.Errorf("expected panels 1 and 2, got %+v", panels)
+	}
+}
+
+func TestLayoutNormalized(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 1, X: 100, Y: 50},
+		{ID: 2, X: 300, Y: 150},
+		{ID: 3, X: 200, Y: 100},
+	}}
+
+	x, y := layout.Normalized(layout.Panels[2])
+	if x != 0.5 || y != 0.5 {
+		t.Errorf("expected (0.5, 0.5), got (%v, %v)", x, y)
+	}
+	x, y = layout.Normalized(layout.Panels[1])
+	if x != 1 || y != 1 {
+		t.Errorf("expected (1, 1), got (%v, %v)", x, y)
+	}
+
+	single := Layout{Panels: []Panel{{ID: 1, X: 10, Y: 10}}}
+	if x, y := single.Normalized(single.Panels[0]); x != 0 || y != 0 {
+		t.Errorf("expected (0, 0) for a single panel, got (%v, %v)", x, y)
+	}
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"math"
+	"strings"
+)
+
+// LayoutTransform rotates and mirrors the on-screen panel map so it matches
+// how the panels are mounted
+type LayoutTransform struct {
+	Rotation int  `json:"rotation,omitempty"` // degrees clockwise, a multiple of 90
+	Mirror   bool `json:"mirror,omitempty"`   // flip horizontally after rotating
+}
+
+// rotated returns the transform turned a further 90 degrees clockwise
+func (t LayoutTransform) rotated() LayoutTransform {
+	t.Rotation = (t.Rotation + 90) % 360
+	return t
+}
+
+// apply returns a copy of the layout with the transform applied to panel positions
+func (t LayoutTransform) apply(layout Layout) Layout {
+	panels := make([]Panel, len(layout.Panels))
+	for i, p := range layout.Panels {
+		switch ((t.Rotation % 360) + 360) % 360 {
+		case 90:
+			p.X, p.Y = p.Y, -p.X
+		case 180:
+			p.X, p.Y = -p.X, -p.Y
+		case 270:
+			p.X, p.Y = -p.Y, p.X
+		}
+		if t.Mirror {
+			p.X = -p.X
+		}
+		panels[i] = p
+	}
+	layout.Panels = panels
+	return layout
+}
+
+// shapeSymbols are the map markers for panel shape types
+var shapeSymbols = map[int]string{
+	0:  "▲", // Aurora triangle
+	2:  "■", // Canvas square
+	3:  "■", // Canvas control square
+	4:  "■", // Canvas control square
+	7:  "⬢", // Shapes hexagon
+	8:  "▲", // Shapes triangle
+	9:  "▴", // Shapes mini triangle
+	14: "⬢", // Elements hexagon
+	15: "⬢", // Elements hexagon corner
+	17: "━", // Lines
+	18: "━", // Lines single zone
+}
+
+func panelSymbol(p Panel) string {
+	if symbol, ok := shapeSymbols[p.ShapeType]; ok {
+		return symbol
+	}
+	return "●"
+}
+
+// renderLayoutMap draws the light panels of a layout onto a character grid
+// of the given size. cell renders the marker for each panel. Terminal cells
+// are about twice as tall as wide, so vertical distances are halved.
+func renderLayoutMap(layout Layout, width, height int, cell func(Panel) string) []string {
+	grid := make([][]string, height)
+	for row := range grid {
+		grid[row] = make([]string, width)
+		for col := range grid[row] {
+			grid[row][col] = " "
+		}
+	}
+
+	panels := layout.LightPanels()
+	if len(panels) > 0 {
+		minX, maxX, minY, maxY := panels[0].X, panels[0].X, panels[0].Y, panels[0].Y
+		for _, p := range panels {
+			minX, maxX = min(minX, p.X), max(maxX, p.X)
+			minY, maxY = min(minY, p.Y), max(maxY, p.Y)
+		}
+
+		scale := math.Inf(1)
+		if maxX > minX {
+			scale = float64(width-1) / float64(maxX-minX)
+		}
+		if maxY > minY {
+			scale = math.Min(scale, 2*float64(height-1)/float64(maxY-minY))
+		}
+		if math.IsInf(scale, 1) {
+			scale = 0
+		}
+
+		// Center the drawing in the grid
+		offsetCol := (float64(width-1) - float64(maxX-minX)*scale) / 2
+		offsetRow := (float64(height-1) - float64(maxY-minY)*scale/2) / 2
+		for _, p := range panels {
+			col := int(math.Round(offsetCol + float64(p.X-minX)*scale))
+			row := int(math.Round(offsetRow + float64(maxY-p.Y)*scale/2))
+			grid[row][col] = cell(p)
+		}
+	}
+
+	lines := make([]string, height)
+	for row := range grid {
+		lines[row] = strings.Join(grid[row], "")
+	}
+	return lines
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLayoutTransformApply(t *testing.T) {
+	layout := Layout{Panels: []Panel{{ID: 1, X: 100, Y: 0}}}
+
+	tests := []struct {
+		transform LayoutTransform
+		x, y      int
+	}{
+		{LayoutTransform{}, 100, 0},
+		{LayoutTransform{Rotation: 90}, 0, -100},
+		{LayoutTransform{Rotation: 180}, -100, 0},
+		{LayoutTransform{Rotation: 270}, 0, 100},
+		{LayoutTransform{Mirror: true}, -100, 0},
+		{LayoutTransform{Rotation: 90, Mirror: true}, 0, -100},
+	}
+
+	for _, tt := range tests {
+		p := tt.transform.apply(layout).Panels[0]
+		if p.X != tt.x || p.Y != tt.y {
+			t.Errorf("%+v: expected (%d, %d), got (%d, %d)", tt.transform, tt.x, tt.y, p.X, p.Y)
+		}
+	}
+
+	if layout.Panels[0].X != 100 {
+		t.Error("apply should not modify the original layout")
+	}
+}
+
+func TestLayoutTransformRotated(t *testing.T) {
+	transform := LayoutTransform{Rotation: 270, Mirror: true}.rotated()
+	if transform.Rotation != 0 || !transform.Mirror {
+		t.Errorf("expected rotation to wrap to 0 and keep mirroring, got %+v", transform)
+	}
+}
+
+func TestRenderLayoutMap(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 1, X: 0, Y: 100, ShapeType: 7},
+		{ID: 2, X: 200, Y: 0, ShapeType: 8},
+		{ID: 0, X: 100, Y: 50, ShapeType: 12},
+	}}
+
+	lines := renderLayoutMap(layout, 21, 6, panelSymbol)
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 lines, got %d", len(lines))
+	}
+
+	hexRow, hexCol, triRow, triCol := -1, -1, -1, -1
+	for row, line := range lines {
+		for col, r := range []rune(line) {
+			switch string(r) {
+			case "⬢":
+				hexRow, hexCol = row, col
+			case "▲":
+				triRow, triCol = row, col
+			}
+		}
+	}
+	if hexCol != 0 || triCol != 20 {
+		t.Errorf("expected the panels to span the width, got columns %d and %d", hexCol, triCol)
+	}
+	if hexRow != 0 || triRow != 5 {
+		t.Errorf("expected the panels to span the height, got rows %d and %d", hexRow, triRow)
+	}
+	if strings.Contains(strings.Join(lines, ""), "●") {
+		t.Error("expected the controller not to be drawn")
+	}
+}
+
+func TestRenderLayoutMapSinglePanel(t *testing.T) {
+	layout := Layout{Panels: []Panel{{ID: 1, X: 50, Y: 50, ShapeType: 2}}}
+
+	lines := renderLayoutMap(layout, 5, 3, panelSymbol)
+	if lines[1] != "  ■  " {
+		t.Errorf("expected a centered panel, got %q", lines[1])
+	}
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The effect library is a directory holding one JSON definition per effect,
+// used to copy effect sets between devices.
+
+func runEffects(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError("effects pull|push [--dir DIR] [--ip IP --token TOKEN] [--delay 500ms] [--resume]")
+	}
+
+	fs := newFlagSet("effects " + args[0])
+	dir := fs.String("dir", "effects", "library directory")
+	ip := fs.String("ip", "", "target device IP instead of the paired device")
+	token := fs.String("token", "", "auth token for --ip")
+	delay := fs.Duration("delay", defaultInstallDelay, "pause between installs when pushing")
+	retries := fs.Int("retries", defaultInstallRetries, "attempts to repeat a failed install before giving up")
+	resume := fs.Bool("resume", false, "skip effects the device already has, to continue a failed push")
+	if err := fs.Parse(args[1:]); err != nil {
+		return invalidArgs(err)
+	}
+
+	device, err := targetDevice(*ip, *token)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "pull":
+		names, err := pullEffects(ctx, device, *dir)
+		if err != nil {
+			return err
+		}
+		statusf("Saved %d effect(s) to %s\n", len(names), *dir)
+	case "push":
+		installer := newEffectInstaller(*delay, *retries)
+		installer.progress = func(done, total int, name string) {
+			statusf("[%d/%d] %s\n", done+1, total, name)
+		}
+		if *resume {
+			if err := installer.skipInstalled(ctx, device); err != nil {
+				return err
+			}
+		}
+		names, skipped, err := pushEffects(ctx, device, *dir, installer)
+		if len(skipped) > 0 {
+			statusf("Skipped %d effect(s) the device already has\n", len(skipped))
+		}
+		if err != nil {
+			var installErr *InstallError
+			if errors.As(err, &installErr) {
+				return fmt.Errorf("%w (rerun with --resume to continue)", err)
+			}
+			return err
+		}
+		statusf("Installed %d effect(s) on %s\n", len(names), device.GetDeviceIP())
+	default:
+		return fmt.Errorf("unknown effects command %q", args[0])
+	}
+	return nil
+}
+
+// targetDevice returns the device given by ip and token, or the paired device
+func targetDevice(ip, token string) (*Device, error) {
+	if ip == "" {
+		return loadPairedDevice()
+	}
+	if token == "" {
+		return nil, invalidArgs(fmt.Errorf("--token is required with --ip"))
+	}
+	device := newCommandDevice()
+	device.config = Config{IP: ip, Token: token}
+	return device, nil
+}
+
+func pullEffects(ctx context.Context, device *Device, dir string) ([]string, error) {
+	effects, err := device.ListEffects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	// Names such as "Blaze!" and "Blaze?" share a file name, so later ones
+	// get a number rather than overwrite the first
+	used := make(map[string]bool)
+	for _, effect := range effects {
+		name, _ := effect["animName"].(string)
+		if name == "" {
+			continue
+		}
+		data, err := json.MarshalIndent(effect, "", "  ")
+		if err != nil {
+			return names, err
+		}
+		file := effectFileName(name)
+		for n := 2; used[file]; n++ {
+			file = fmt.Sprintf("%s_%d.json", strings.TrimSuffix(effectFileName(name), ".json"), n)
+		}
+		used[file] = true
+		if err := os.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// pushEffects installs the library in dir with installer, returning the
+// names installed and skipped
+func pushEffects(ctx context.Context, device *Device, dir string, installer *effectInstaller) (installed, skipped []string, err error) {
+	effects, err := loadEffectLibrary(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return installer.install(ctx, device, effects)
+}
+
+// loadEffectLibrary reads all effect definitions in dir, sorted by file name
+func loadEffectLibrary(dir string) ([]map[string]interface{}, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no effect files found in %s", dir)
+	}
+	sort.Strings(files)
+
+	var effects []map[string]interface{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var effect map[string]interface{}
+		if err := json.Unmarshal(data, &effect); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		if name, _ := effect["animName"].(string); name == "" {
+			return nil, fmt.Errorf("%s has no animName", file)
+		}
+		effects = append(effects, effect)
+	}
+	return effects, nil
+}
+
+// effectFileName turns an effect name into a safe file name
+func effectFileName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '_'
+		}
+	}, name)
+	return safe + ".json"
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPullAndPushEffects(t *testing.T) {
+	var added []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		switch payload["write"]["command"] {
+		case "requestAll":
+			w.Write([]byte(`{"animations":[{"animName":"Northern Lights","animType":"flow"},{"animName":"Blaze","animType":"fade"}]}`))
+		case "add":
+			added = append(added, payload["write"]["animName"].(string))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected command %v", payload["write"]["command"])
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.caps = &Capabilities{Model: "Shapes", Firmware: "9.2.4"}
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	names, err := pullEffects(ctx, device, dir)
+	if err != nil {
+		t.Fatalf("pullEffects should not fail: %v", err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("expected 2 effects, got %d", len(names))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "northern_lights.json")); err != nil {
+		t.Errorf("expected northern_lights.json to be written: %v", err)
+	}
+
+	names, _, err = pushEffects(ctx, device, dir, newEffectInstaller(0, 0))
+	if err != nil {
+		t.Fatalf("pushEffects should not fail: %v", err)
+	}
+	if len(names) != 2 || len(added) != 2 {
+		t.Errorf("expected 2 effects pushed, got %v", added)
+	}
+	if added[0] != "Blaze" {
+		t.Errorf("expected effects to be pushed in file order, got %v", added)
+	}
+}
+
+func TestLoadEffectLibraryErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := loadEffectLibrary(dir); err == nil {
+		t.Error("loadEffectLibrary should fail for an empty directory")
+	}
+
+	os.WriteFile(filepath.Join(dir, "nameless.json"), []byte(`{"animType":"flow"}`), 0644)
+	if _, err := loadEffectLibrary(dir); err == nil {
+		t.Error("loadEffectLibrary should fail for an effect without a name")
+	}
+}
+
+func TestPullEffectsNameCollision(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"animations":[{"animName":"Blaze!"},{"animName":"Blaze?"},{"animName":"blaze_"}]}`))
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.caps = &Capabilities{Model: "Shapes", Firmware: "9.2.4"}
+	dir := t.TempDir()
+
+	if _, err := pullEffects(context.Background(), device, dir); err != nil {
+		t.Fatalf("pullEffects should not fail: %v", err)
+	}
+	effects, err := loadEffectLibrary(dir)
+	if err != nil {
+		t.Fatalf("loadEffectLibrary should not fail: %v", err)
+	}
+	if len(effects) != 3 {
+		t.Errorf("expected every effect to keep its own file, got %d", len(effects))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blaze__2.json")); err != nil {
+		t.Errorf("expected the second Blaze to be numbered: %v", err)
+	}
+}
+
+func TestEffectFileName(t *testing.T) {
+	if got := effectFileName("Northern Lights/2"); got != "northern_lights_2.json" {
+		t.Errorf("expected northern_lights_2.json, got %s", got)
+	}
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// linesDirections are the directions a Lines effect can run in
+var linesDirections = []string{"right", "left", "up", "down"}
+
+// linesPalettes are the palettes offered by the Lines controls in the UI
+var linesPalettes = []struct {
+	name   string
+	colors []string
+}{
+	{"Sunset", []string{"#ff4000", "#ff0080", "#4000ff"}},
+	{"Ocean", []string{"navy", "teal", "aquamarine"}},
+	{"Forest", []string{"darkgreen", "yellowgreen", "gold"}},
+	{"Candy", []string{"hotpink", "white", "deepskyblue"}},
+	{"Ember", []string{"darkred", "orangered", "orange"}},
+}
+
+// linesEffect builds an effect for Lines. A "gradient" spreads the palette
+// across the layout as a static blend along direction; a "flow" moves the
+// palette colors through the lines in that direction.
+func linesEffect(layout Layout, style, direction string, palette []rgbColor) (map[string]interface{}, error) {
+	if len(palette) < 2 {
+		return nil, fmt.Errorf("a Lines effect needs at least two colors")
+	}
+	if !validDirection(direction) {
+		return nil, fmt.Errorf("unknown direction %q (available: %s)", direction, strings.Join(linesDirections, ", "))
+	}
+
+	switch style {
+	case "gradient":
+		panels := layout.LightPanels()
+		if len(panels) == 0 {
+			return nil, fmt.Errorf("device layout has no light panels")
+		}
+		var animData strings.Builder
+		fmt.Fprintf(&animData, "%d", len(panels))
+		for _, p := range panels {
+			c := gradientAt(palette, directionPosition(layout, p, direction))
+			fmt.Fprintf(&animData, " %d 1 %d %d %d 0 10", p.ID, c.R, c.G, c.B)
+		}
+		return map[string]interface{}{
+			"animType": "static",
+			"animData": animData.String(),
+			"loop":     false,
+			"palette":  []paletteColor{},
+		}, nil
+	case "flow":
+		colors := make([]paletteColor, len(palette))
+		for i, c := range palette {
+			hue, sat, bri := c.hsb()
+			colors[i] = paletteColor{Hue: hue, Saturation: sat, Brightness: bri}
+		}
+		effect := fadeEffect(colors, 30, 20)
+		effect["animType"] = "flow"
+		effect["flowFactor"] = 1.5
+		effect["direction"] = direction
+		return effect, nil
+	}
+	return nil, fmt.Errorf("unknown style %q (available: gradient, flow)", style)
+}
+
+func validDirection(direction string) bool {
+	for _, d := range linesDirections {
+		if d == direction {
+			return true
+		}
+	}
+	return false
+}
+
+// directionPosition is how far along direction a panel sits, from 0 to 1
+func directionPosition(layout Layout, p Panel, direction string) float64 {
+	x, y := layout.Normalized(p)
+	switch direction {
+	case "left":
+		return 1 - x
+	case "up":
+		return y
+	case "down":
+		return 1 - y
+	default:
+		return x
+	}
+}
+
+// gradientAt blends linearly through the palette, pos 0 being the first
+// color and pos 1 the last
+func gradientAt(palette []rgbColor, pos float64) rgbColor {
+	pos = max(0, min(1, pos)) * float64(len(palette)-1)
+	i := min(int(pos), len(palette)-2)
+	return mixColors(palette[i], palette[i+1], pos-float64(i))
+}
+
+func runLines(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "gradient" && args[0] != "flow") {
+		return usageError("lines gradient|flow [--colors red,blue] [--direction right|left|up|down] [--save NAME]")
+	}
+	style := args[0]
+
+	fs := newFlagSet("lines " + style)
+	colors := fs.String("colors", strings.Join(linesPalettes[0].colors, ","), "comma separated colors")
+	direction := fs.String("direction", "right", "direction of the gradient or flow")
+	save := fs.String("save", "", "add the effect to the device under this name instead of only displaying it")
+	if err := fs.Parse(args[1:]); err != nil {
+		return invalidArgs(err)
+	}
+
+	var palette []rgbColor
+	for _, name := range strings.Split(*colors, ",") {
+		c, err := parseColor(name)
+		if err != nil {
+			return err
+		}
+		palette = append(palette, c)
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	layout, err := device.GetLayout(ctx)
+	if err != nil {
+		return err
+	}
+	effect, err := linesEffect(layout, style, *direction, palette)
+	if err != nil {
+		return err
+	}
+
+	if *save == "" {
+		return device.DisplayEffect(ctx, effect)
+	}
+	effect["animName"] = *save
+	if err := device.AddEffect(ctx, effect); err != nil {
+		return err
+	}
+	if err := device.SelectEffect(ctx, *save); err != nil {
+		return err
+	}
+	statusf("Saved and selected %q\n", *save)
+	return nil
+}
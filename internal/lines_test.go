@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGradientAt(t *testing.T) {
+	palette := []rgbColor{{255, 0, 0}, {0, 0, 255}, {0, 255, 0}}
+
+	tests := []struct {
+		pos      float64
+		expected rgbColor
+	}{
+		{0, rgbColor{255, 0, 0}},
+		{0.25, rgbColor{128, 0, 128}},
+		{0.5, rgbColor{0, 0, 255}},
+		{1, rgbColor{0, 255, 0}},
+		{1.5, rgbColor{0, 255, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := gradientAt(palette, tt.pos); got != tt.expected {
+			t.Errorf("gradientAt(%v) = %v, expected %v", tt.pos, got, tt.expected)
+		}
+	}
+}
+
+func TestLinesGradientEffect(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 1, X: 0, Y: 0, ShapeType: 18},
+		{ID: 2, X: 100, Y: 0, ShapeType: 18},
+		{ID: 3, X: 50, Y: 0, ShapeType: 19},
+	}}
+	palette := []rgbColor{{255, 0, 0}, {0, 0, 255}}
+
+	effect, err := linesEffect(layout, "gradient", "left", palette)
+	if err != nil {
+		t.Fatalf("linesEffect should not fail: %v", err)
+	}
+	if effect["animType"] != "static" {
+		t.Errorf("expected a static effect, got %v", effect["animType"])
+	}
+	// Running left, the rightmost line starts the gradient; the controller
+	// (shape 19) is skipped
+	expected := "2 1 1 0 0 255 0 10 2 1 255 0 0 0 10"
+	if effect["animData"] != expected {
+		t.Errorf("expected animData %q, got %q", expected, effect["animData"])
+	}
+}
+
+func TestLinesFlowEffect(t *testing.T) {
+	effect, err := linesEffect(Layout{}, "flow", "up", []rgbColor{{255, 0, 0}, {0, 0, 255}})
+	if err != nil {
+		t.Fatalf("linesEffect should not fail: %v", err)
+	}
+	if effect["animType"] != "flow" || effect["direction"] != "up" {
+		t.Errorf("unexpected flow effect %v", effect)
+	}
+}
+
+func TestLinesEffectInvalid(t *testing.T) {
+	palette := []rgbColor{{255, 0, 0}, {0, 0, 255}}
+	if _, err := linesEffect(Layout{}, "flow", "sideways", palette); err == nil || !strings.Contains(err.Error(), "direction") {
+		t.Errorf("expected a direction error, got %v", err)
+	}
+	if _, err := linesEffect(Layout{}, "sparkle", "up", palette); err == nil {
+		t.Error("unknown styles should fail")
+	}
+	if _, err := linesEffect(Layout{}, "flow", "up", palette[:1]); err == nil {
+		t.Error("a single color should fail")
+	}
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// parseMacro parses the steps of a macro as configured under "macros"
+func parseMacro(steps []string) ([]action, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("macro has no steps")
+	}
+
+	actions := make([]action, 0, len(steps))
+	for i, step := range steps {
+		a, err := parseAction(step)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+// runMacro runs the named macro from the device's config, stopping at the first failing step
+func runMacro(ctx context.Context, device *Device, name string) error {
+	steps, ok := device.GetConfig().Macros[name]
+	if !ok {
+		return fmt.Errorf("macro %q is not configured", name)
+	}
+	actions, err := parseMacro(steps)
+	if err != nil {
+		return fmt.Errorf("macro %q: %w", name, err)
+	}
+
+	for _, a := range actions {
+		if err := a.run(ctx, device); err != nil {
+			return fmt.Errorf("macro %q failed at %q: %w", name, a, err)
+		}
+	}
+	return nil
+}
+
+func macroNames(macros map[string][]string) []string {
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func runMacroCommand(ctx context.Context, args []string) error {
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		names := macroNames(device.GetConfig().Macros)
+		if len(names) == 0 {
+			fmt.Println("No macros configured")
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	if err := runMacro(ctx, device, args[0]); err != nil {
+		return err
+	}
+	statusf("Macro %s done\n", args[0])
+	return nil
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunMacro(t *testing.T) {
+	var updates []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		for key := range payload {
+			updates = append(updates, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.config.Macros = map[string][]string{
+		"wake": {"off", "10ms", "on", "brightness 80", "effect Blaze"},
+	}
+
+	if err := runMacro(context.Background(), device, "wake"); err != nil {
+		t.Fatalf("runMacro should not fail: %v", err)
+	}
+
+	expected := []string{"on", "on", "brightness", "select"}
+	if !reflect.DeepEqual(updates, expected) {
+		t.Errorf("expected updates %v, got %v", expected, updates)
+	}
+}
+
+func TestRunMacroErrors(t *testing.T) {
+	device := NewDevice()
+	device.config.Macros = map[string][]string{
+		"broken": {"on", "dance"},
+		"empty":  {},
+	}
+	ctx := context.Background()
+
+	if err := runMacro(ctx, device, "missing"); err == nil {
+		t.Error("runMacro should fail for an unknown macro")
+	}
+	if err := runMacro(ctx, device, "broken"); err == nil {
+		t.Error("runMacro should fail for an invalid step")
+	}
+	if err := runMacro(ctx, device, "empty"); err == nil {
+		t.Error("runMacro should fail for a macro without steps")
+	}
+}
+
+func TestMacroStopsWithItsScreen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.config.Macros = map[string][]string{"slow": {"on", "1m", "off"}}
+
+	ui := UI{device: device, operations: &operationQueue{}}.openMacros()
+	done := make(chan interface{})
+	go func() { done <- ui.handleRunMacro("slow", ui.macroCtx)() }()
+	time.Sleep(50 * time.Millisecond)
+	ui = ui.closeMacros()
+
+	select {
+	case msg := <-done:
+		result, ok := msg.(actionResultMsg)
+		if !ok || result.err == nil || !strings.Contains(result.err.Error(), "stopped") {
+			t.Errorf("expected the macro to be stopped, got %v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected closing the macro screen to stop the macro")
+	}
+	if ui.macroMode {
+		t.Error("expected the macro screen to be closed")
+	}
+}
+
+func TestMacroNames(t *testing.T) {
+	names := macroNames(map[string][]string{"wake": nil, "bedtime": nil})
+	if !reflect.DeepEqual(names, []string{"bedtime", "wake"}) {
+		t.Errorf("expected sorted names, got %v", names)
+	}
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maintenanceTimeout bounds a maintenance action, long enough to delete
+// a full effect list one effect at a time
+const maintenanceTimeout = 2 * time.Minute
+
+// maintenanceAction is a device upkeep task shared by the maintenance
+// command and the TUI maintenance screen
+type maintenanceAction struct {
+	name  string
+	label string
+	// confirm is the question asked before running, empty to run straight away
+	confirm string
+	run     func(ctx context.Context, device *Device) (string, error)
+}
+
+var maintenanceActions = []maintenanceAction{
+	{
+		name:  "identify",
+		label: "Identify (flash the panels)",
+		run: func(ctx context.Context, device *Device) (string, error) {
+			return "Flashed the panels", device.Identify(ctx)
+		},
+	},
+	{
+		name:    "reboot",
+		label:   "Reboot",
+		confirm: "Reboot the controller? The panels go dark for about a minute.",
+		run: func(ctx context.Context, device *Device) (string, error) {
+			return "Rebooting, the device is back in about a minute", device.Reboot(ctx)
+		},
+	},
+	{
+		name:    "reset-orientation",
+		label:   "Reset Orientation",
+		confirm: "Reset the orientation to 0°? Effects that depend on it will look rotated.",
+		run: func(ctx context.Context, device *Device) (string, error) {
+			return "Orientation reset to 0°", device.ResetOrientation(ctx)
+		},
+	},
+	{
+		name:    "delete-effects",
+		label:   "Delete All Effects",
+		confirm: "Delete every installed effect? Run `nanoleaf-go backup` first to keep them.",
+		run: func(ctx context.Context, device *Device) (string, error) {
+			names, err := device.DeleteAllEffects(ctx)
+			return fmt.Sprintf("Deleted %d effect(s)", len(names)), err
+		},
+	},
+}
+
+func findMaintenanceAction(name string) (maintenanceAction, bool) {
+	for _, action := range maintenanceActions {
+		if action.name == name {
+			return action, true
+		}
+	}
+	return maintenanceAction{}, false
+}
+
+func (c *NanoleafClient) identify(ctx context.Context, ip, token string) error {
+	ctx = withAuthToken(ctx, token)
+	url := c.buildURL(ip, "api/v1/identify")
+	return c.sendStateUpdate(ctx, url, map[string]interface{}{})
+}
+
+// Identify flashes the panels so the device can be found
+func (d *Device) Identify(ctx context.Context) error {
+	err := d.client.identify(ctx, d.GetConfig().IP, d.GetConfig().Token)
+	return d.logAction("identify", err)
+}
+
+// Reboot restarts the controller. Firmware without the reboot command
+// refuses the write, which is reported as unsupported.
+func (d *Device) Reboot(ctx context.Context) error {
+	err := d.client.writeEffect(ctx, d.GetConfig().IP, d.GetConfig().Token, map[string]interface{}{"command": "reboot"})
+	var statusErr *updateStatusError
+	if errors.As(err, &statusErr) && (statusErr.status == http.StatusBadRequest || statusErr.status == http.StatusNotFound ||
+		statusErr.status == http.StatusUnprocessableEntity) {
+		err = fmt.Errorf("this firmware does not support rebooting, unplug the controller instead")
+		if caps, capsErr := d.GetCapabilities(ctx); capsErr == nil {
+			err = fmt.Errorf("%s firmware %s does not support rebooting, unplug the controller instead", caps.Model, caps.Firmware)
+		}
+	}
+	return d.logAction("reboot", err)
+}
+
+// ResetOrientation turns the layout back to the orientation it had out of
+// the box
+func (d *Device) ResetOrientation(ctx context.Context) error {
+	d.warnFirmware(ctx, featureOrientation)
+	err := d.client.setGlobalOrientation(ctx, d.GetConfig().IP, d.GetConfig().Token, 0)
+	return d.logAction("orientation 0", err)
+}
+
+// DeleteEffect removes an installed effect
+func (d *Device) DeleteEffect(ctx context.Context, name string) error {
+	err := d.client.writeEffect(ctx, d.GetConfig().IP, d.GetConfig().Token, map[string]interface{}{"command": "delete", "animName": name})
+	return d.logAction(fmt.Sprintf("delete effect %s", name), err)
+}
+
+// DeleteAllEffects removes every installed effect, pausing between deletes
+// like installs do. It returns the names deleted.
+func (d *Device) DeleteAllEffects(ctx context.Context) ([]string, error) {
+	effects, err := d.ListEffects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	for i, effect := range effects {
+		name, _ := effect["animName"].(string)
+		if name == "" {
+			continue
+		}
+		if i > 0 {
+			if err := sleepContext(ctx, defaultInstallDelay); err != nil {
+				return deleted, err
+			}
+		}
+		if err := d.DeleteEffect(ctx, name); err != nil {
+			return deleted, fmt.Errorf("failed to delete %q: %w", name, err)
+		}
+		deleted = append(deleted, name)
+	}
+	return deleted, nil
+}
+
+func runMaintenance(ctx context.Context, args []string) error {
+	names := make([]string, len(maintenanceActions))
+	for i, action := range maintenanceActions {
+		names[i] = action.name
+	}
+	usage := fmt.Sprintf("maintenance %s [--yes]", strings.Join(names, "|"))
+	if len(args) == 0 {
+		return usageError(usage)
+	}
+	action, ok := findMaintenanceAction(args[0])
+	if !ok {
+		return usageError(usage)
+	}
+
+	fs := newFlagSet("maintenance " + action.name)
+	yes := fs.Bool("yes", false, "skip the confirmation")
+	if err := fs.Parse(args[1:]); err != nil {
+		return invalidArgs(err)
+	}
+	if action.confirm != "" && !*yes {
+		return invalidArgs(fmt.Errorf("%s Rerun with --yes to go ahead", action.confirm))
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, maintenanceTimeout)
+	defer cancel()
+	message, err := action.run(ctx, device)
+	if err != nil {
+		return err
+	}
+	statusf("%s\n", message)
+	return nil
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaintenanceActions(t *testing.T) {
+	var writes []string
+	rebootStatus := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/test-token/identify" {
+			writes = append(writes, "identify")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var payload map[string]map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if r.URL.Path == "/api/v1/test-token/panelLayout" {
+			writes = append(writes, "orientation")
+			if payload["globalOrientation"]["value"] != 0.0 {
+				t.Errorf("expected orientation 0, got %v", payload["globalOrientation"])
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		switch payload["write"]["command"] {
+		case "requestAll":
+			w.Write([]byte(`{"animations":[{"animName":"Northern Lights"},{"animName":"Blaze"}]}`))
+		case "delete":
+			writes = append(writes, "delete "+payload["write"]["animName"].(string))
+			w.WriteHeader(http.StatusNoContent)
+		case "reboot":
+			writes = append(writes, "reboot")
+			w.WriteHeader(rebootStatus)
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.caps = &Capabilities{Model: "Shapes", Firmware: "9.2.4"}
+	ctx := context.Background()
+
+	for _, name := range []string{"identify", "reboot", "reset-orientation", "delete-effects"} {
+		action, ok := findMaintenanceAction(name)
+		if !ok {
+			t.Fatalf("expected a %s action", name)
+		}
+		if _, err := action.run(ctx, device); err != nil {
+			t.Errorf("%s should not fail: %v", name, err)
+		}
+	}
+	expected := "identify,reboot,orientation,delete Northern Lights,delete Blaze"
+	if got := strings.Join(writes, ","); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	rebootStatus = http.StatusBadRequest
+	err := device.Reboot(ctx)
+	if err == nil || !strings.Contains(err.Error(), "firmware 9.2.4 does not support rebooting") {
+		t.Errorf("expected a refused reboot to be reported as unsupported, got %v", err)
+	}
+}
+
+func TestRunMaintenanceNeedsConfirmation(t *testing.T) {
+	err := runMaintenance(context.Background(), []string{"delete-effects"})
+	if ExitCode(err) != ExitInvalidArgs || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("expected deleting without --yes to be refused, got %v", err)
+	}
+	if err := runMaintenance(context.Background(), []string{"format-disk"}); ExitCode(err) != ExitInvalidArgs {
+		t.Errorf("expected an unknown action to be invalid arguments, got %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// menubarAction is one entry of the quick actions menu-bar apps list. Its
+// ID is the group and the name of the favorite effect, preset or macro, so
+// it stays the same when others are added or removed.
+type menubarAction struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Group string `json:"group"`
+
+	run func(ctx context.Context, device *Device) error
+}
+
+// menubarState is the summary menu-bar apps show in the bar itself
+type menubarState struct {
+	Device     string `json:"device"`
+	IP         string `json:"ip"`
+	On         bool   `json:"on"`
+	Brightness int    `json:"brightness"`
+	Effect     string `json:"effect,omitempty"`
+	// Title is short enough for the menu bar, Summary for the menu
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+// menubar serves the endpoints shaped for menu-bar apps. Requests are run
+// one at a time so a double click on toggle cannot race itself.
+type menubar struct {
+	mu     sync.Mutex
+	device *Device
+}
+
+func newMenubarState(device *Device, status DeviceStatus) menubarState {
+	title := "Off"
+	if status.On {
+		title = fmt.Sprintf("%d%%", status.Brightness)
+	}
+	return menubarState{
+		Device:     device.GetDeviceName(),
+		IP:         device.GetDeviceIP(),
+		On:         status.On,
+		Brightness: status.Brightness,
+		Effect:     status.Effect,
+		Title:      title,
+		Summary:    status.String(),
+	}
+}
+
+// actions lists the favorite effects, presets and macros in a stable order
+func (m *menubar) actions() []menubarAction {
+	config := m.device.GetConfig()
+	var actions []menubarAction
+	add := func(name, title, group string, run func(ctx context.Context, device *Device) error) {
+		actions = append(actions, menubarAction{ID: group + "/" + name, Title: title, Group: group, run: run})
+	}
+	for _, name := range m.device.FavoriteEffects() {
+		effect := name
+		add(effect, effect, "effects", func(ctx context.Context, device *Device) error {
+			return device.SelectEffect(ctx, effect)
+		})
+	}
+	for _, key := range presetKeys(config.Presets) {
+		preset := config.Presets[key]
+		add(key, preset.String(), "presets", func(ctx context.Context, device *Device) error {
+			ctx, cancel := context.WithTimeout(ctx, commandTimeout+preset.transition())
+			defer cancel()
+			return preset.apply(ctx, device)
+		})
+	}
+	for _, name := range macroNames(config.Macros) {
+		macro := name
+		add(macro, macro, "macros", func(ctx context.Context, device *Device) error {
+			return runMacro(ctx, device, macro)
+		})
+	}
+	return actions
+}
+
+func (m *menubar) state(ctx context.Context) (menubarState, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	status, err := m.device.GetStatus(ctx)
+	if err != nil {
+		return menubarState{}, err
+	}
+	return newMenubarState(m.device, status), nil
+}
+
+// byHand makes the changes of the request being served count as made by
+// hand rather than by serve, which is an automation; undo it when done
+func (m *menubar) byHand() (undo func()) {
+	m.device.setChangeSource(handSource, priorityManual)
+	return func() { m.device.setChangeSource("", 0) }
+}
+
+// toggle turns the device off when it is on and on otherwise
+func (m *menubar) toggle(ctx context.Context) (menubarState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer m.byHand()()
+	toggleCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	if _, err := m.device.TogglePower(toggleCtx); err != nil {
+		return menubarState{}, err
+	}
+	return m.state(ctx)
+}
+
+// errNoQuickAction is returned for an action id that is not listed
+var errNoQuickAction = errors.New("no such quick action")
+
+func (m *menubar) run(ctx context.Context, id string) (menubarState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer m.byHand()()
+	for _, action := range m.actions() {
+		if action.ID != id {
+			continue
+		}
+		if err := action.run(ctx, m.device); err != nil {
+			return menubarState{}, err
+		}
+		return m.state(ctx)
+	}
+	return menubarState{}, fmt.Errorf("%w %q, see GET /menubar/actions", errNoQuickAction, id)
+}
+
+// handle adds the menu-bar endpoints to mux. Like the presence rules,
+// actions run on ctx rather than the request context.
+func (m *menubar) handle(ctx context.Context, mux *http.ServeMux) {
+	reply := func(w http.ResponseWriter, state menubarState, err error) {
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]interface{}{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	}
+	mux.HandleFunc("GET /menubar/state", func(w http.ResponseWriter, r *http.Request) {
+		state, err := m.state(r.Context())
+		reply(w, state, err)
+	})
+	mux.HandleFunc("GET /menubar/actions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, m.actions())
+	})
+	mux.HandleFunc("POST /menubar/toggle", func(w http.ResponseWriter, r *http.Request) {
+		state, err := m.toggle(ctx)
+		reply(w, state, err)
+	})
+	mux.HandleFunc("POST /menubar/actions/{group}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		state, err := m.run(ctx, r.PathValue("group")+"/"+r.PathValue("name"))
+		if errors.Is(err, errNoQuickAction) {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+			return
+		}
+		reply(w, state, err)
+	})
+}
+
+// xbarPlugin is an xbar/SwiftBar plugin that shows the device state in the
+// menu bar, with a toggle and the quick actions in its menu. It needs curl
+// and jq, which macOS ships from Sequoia on. The secret of serve is not
+// written into the plugin: it asks nanoleaf-go for it on every run and
+// sends it as a bearer token, and the menu entries run the plugin itself
+// with the request to make.
+const xbarPlugin = `#!/bin/bash
+# <xbar.title>Nanoleaf</xbar.title>
+# <xbar.desc>Power, favorite effects, presets and macros of the paired Nanoleaf device, through nanoleaf-go serve</xbar.desc>
+# <xbar.dependencies>curl,jq</xbar.dependencies>
+# <swiftbar.hideRunInTerminal>true</swiftbar.hideRunInTerminal>
+# <swiftbar.hideLastUpdated>true</swiftbar.hideLastUpdated>
+
+URL=%s
+export PATH="/opt/homebrew/bin:/usr/local/bin:$PATH"
+KEY=$(%s integration secret 2>/dev/null)
+request() {
+  curl -fsS --max-time 5 -H "Authorization: Bearer $KEY" "$@"
+}
+
+if [ "$1" = post ]; then
+  request -X POST "$URL/$2" >/dev/null
+  exit
+fi
+
+if ! state=$(request "$URL/state"); then
+  echo "◇ –"
+  echo "---"
+  echo "Not reachable, is nanoleaf-go serve running?"
+  exit 0
+fi
+
+echo "◆ $(jq -r .title <<<"$state")"
+echo "---"
+jq -r '.device + ": " + .summary' <<<"$state"
+echo "Toggle | shell=\"$0\" param1=post param2=toggle terminal=false refresh=true"
+request "$URL/actions" | jq -r --arg self "$0" '
+  group_by(.group)[] | "---", (.[] | "\(.title) | shell=\"\($self)\" param1=post param2=actions/\(.id | split("/") | map(@uri) | join("/")) terminal=false refresh=true")'
+`
+
+// localURL turns a listen address such as ":8421" into a URL on this machine
+func localURL(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "http://" + listen
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func runIntegration(ctx context.Context, args []string) error {
+	const usage = "integration xbar [--url URL] [--out FILE] | integration secret"
+	if len(args) == 1 && args[0] == "secret" {
+		// The plugin reads the secret through this on every run, so that
+		// it is kept only in the config, cmd: references included
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if config.Serve != nil {
+			fmt.Println(config.Serve.Secret)
+		}
+		return nil
+	}
+	if len(args) == 0 || args[0] != "xbar" {
+		return usageError(usage)
+	}
+
+	fs := newFlagSet("integration xbar")
+	url := fs.String("url", "", "address of nanoleaf-go serve (default from serve.listen in the config)")
+	out := fs.String("out", "", "file to write the plugin to, e.g. nanoleaf.30s.sh in the plugin folder (default stdout)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return invalidArgs(err)
+	}
+
+	if *url == "" {
+		config, _ := loadConfig()
+		listen := defaultServeAddr
+		if config.Serve != nil && config.Serve.Listen != "" {
+			listen = config.Serve.Listen
+		}
+		*url = localURL(listen)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the nanoleaf-go binary for the plugin: %w", err)
+	}
+	plugin := fmt.Sprintf(xbarPlugin, shellQuote(strings.TrimSuffix(*url, "/")+"/menubar"), shellQuote(self))
+
+	if *out == "" {
+		fmt.Print(plugin)
+		return nil
+	}
+	if err := os.WriteFile(*out, []byte(plugin), 0700); err != nil {
+		return err
+	}
+	statusf("Wrote %s, keep nanoleaf-go serve running for it to work\n", *out)
+	return nil
+}
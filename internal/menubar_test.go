@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMenubarEndpoints(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	on := false
+	var sent []string
+	nanoleaf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/state/on"):
+			json.NewEncoder(w).Encode(map[string]bool{"value": on})
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/state/brightness"):
+			json.NewEncoder(w).Encode(map[string]int{"value": 40})
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/effects/select"):
+			json.NewEncoder(w).Encode("Northern Lights")
+		default:
+			body, _ := io.ReadAll(r.Body)
+			sent = append(sent, string(body))
+			if string(body) == `{"on":{"value":true}}` {
+				on = true
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer nanoleaf.Close()
+
+	device := NewDevice()
+	device.config.IP = nanoleaf.URL
+	device.config.Token = "test-token"
+	device.config.Macros = map[string][]string{"dim": {"brightness 10"}}
+
+	server := httptest.NewServer(serveHandler(context.Background(), "", nil, nil, nil, &menubar{device: device}))
+	defer server.Close()
+
+	request := func(method, path string, out interface{}) int {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(out)
+		return resp.StatusCode
+	}
+
+	var state menubarState
+	if request("GET", "/menubar/state", &state); state.Title != "Off" || state.Summary != "Off · 40% · Northern Lights" {
+		t.Errorf("expected the device to be off, got %+v", state)
+	}
+	if request("POST", "/menubar/toggle", &state); !state.On || state.Title != "40%" {
+		t.Errorf("expected toggle to turn the device on, got %+v", state)
+	}
+
+	var actions []menubarAction
+	request("GET", "/menubar/actions", &actions)
+	if len(actions) != 1 || actions[0].ID != "macros/dim" || actions[0].Title != "dim" || actions[0].Group != "macros" {
+		t.Fatalf("expected the dim macro as the only action, got %+v", actions)
+	}
+	if status := request("POST", "/menubar/actions/macros/dim", &state); status != http.StatusOK {
+		t.Errorf("expected the macro to run, got %d", status)
+	}
+	if status := request("POST", "/menubar/actions/macros/bright", &state); status != http.StatusNotFound {
+		t.Errorf("expected an unknown action to be not found, got %d", status)
+	}
+
+	want := []string{`{"on":{"value":true}}`, `{"brightness":{"value":10}}`}
+	if strings.Join(sent, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, sent)
+	}
+}
+
+func TestLocalURL(t *testing.T) {
+	tests := map[string]string{
+		":8421":          "http://127.0.0.1:8421",
+		"0.0.0.0:9000":   "http://127.0.0.1:9000",
+		"10.0.0.5:8421":  "http://10.0.0.5:8421",
+		"localhost:8421": "http://localhost:8421",
+	}
+	for listen, want := range tests {
+		if got := localURL(listen); got != want {
+			t.Errorf("localURL(%q) = %q, expected %q", listen, got, want)
+		}
+	}
+}
+
+func TestXbarPluginKeepsTheSecretOut(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	saveConfig(Config{Serve: &ServeConfig{Secret: "s3cret&key"}})
+
+	out := filepath.Join(home, "nanoleaf.30s.sh")
+	if err := runIntegration(context.Background(), []string{"xbar", "--out", out}); err != nil {
+		t.Fatalf("runIntegration should not fail: %v", err)
+	}
+	plugin, _ := os.ReadFile(out)
+	if strings.Contains(string(plugin), "s3cret") {
+		t.Error("expected the secret to be read at runtime rather than written into the plugin")
+	}
+	if !strings.Contains(string(plugin), "integration secret") {
+		t.Error("expected the plugin to ask nanoleaf-go for the secret")
+	}
+}
+
+func TestMenubarChangesAreManual(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	runningAutomation = "serve"
+	defer func() { runningAutomation = "" }()
+	nanoleaf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/state/on"):
+			json.NewEncoder(w).Encode(map[string]bool{"value": false})
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/state/brightness"):
+			json.NewEncoder(w).Encode(map[string]int{"value": 40})
+		case r.Method == "GET":
+			json.NewEncoder(w).Encode("Northern Lights")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer nanoleaf.Close()
+
+	device := NewDevice()
+	device.config.IP = nanoleaf.URL
+	device.config.Token = "test-token"
+	device.config.ManualOverride = "1h"
+	device.record = true
+	m := &menubar{device: device}
+	if _, err := m.toggle(context.Background()); err != nil {
+		t.Fatalf("toggle should not fail: %v", err)
+	}
+	if last := lastChanges(nanoleaf.URL); last.Manual.IsZero() || !last.Automated.IsZero() {
+		t.Errorf("expected the toggle to count as a manual change, got %+v", last)
+	}
+
+	device.SetBrightness(context.Background(), 10)
+	if last := lastChanges(nanoleaf.URL); last.Source != "serve" {
+		t.Errorf("expected later changes to be serve's again, got %+v", last)
+	}
+}
@@ -0,0 +1,315 @@
+package internal
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// audioWindow is the number of samples analyzed at a time, about 23ms
+const audioWindow = 1024
+
+// energyMeter turns the RMS of successive sample windows into a level in
+// [0, 1]. It rises quickly, falls slowly and normalizes against a peak that
+// decays over a few seconds, so quiet and loud music both use the full range.
+type energyMeter struct {
+	level float64
+	peak  float64
+}
+
+func (m *energyMeter) update(rms float64) float64 {
+	m.peak = max(rms, m.peak*0.995, 0.01)
+	target := rms / m.peak
+	if target > m.level {
+		m.level += (target - m.level) * 0.6
+	} else {
+		m.level += (target - m.level) * 0.15
+	}
+	return m.level
+}
+
+// MusicConfig holds the music mode settings. Palettes are comma separated
+// color lists; the next one is used when a new song starts, or on every
+// beat with BeatPalettes.
+type MusicConfig struct {
+	Sensitivity  float64  `json:"sensitivity,omitempty"`
+	Palettes     []string `json:"palettes,omitempty"`
+	BeatPalettes bool     `json:"beatPalettes,omitempty"`
+	Strobe       bool     `json:"strobe,omitempty"`
+}
+
+func rms(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// Beat detection works on windows of audioWindow samples, about 43 a second
+const (
+	beatHistory    = 43 // windows in the running average, about a second
+	minBeatGap     = 10 // windows between beats, about 230ms
+	songGapWindows = 86 // windows of silence that end a song, about 2s
+	silenceRMS     = 0.01
+)
+
+// defaultBeatSensitivity is how far above the recent average the energy of
+// a window must jump to count as a beat
+const defaultBeatSensitivity = 1.4
+
+// beatDetector flags beats when the energy of a window jumps above the
+// recent average, and new songs when sound returns after a silence
+type beatDetector struct {
+	sensitivity float64
+	history     [beatHistory]float64
+	pos         int
+	filled      bool
+	sinceBeat   int
+	quiet       int
+}
+
+func newBeatDetector(sensitivity float64) *beatDetector {
+	if sensitivity <= 1 {
+		sensitivity = defaultBeatSensitivity
+	}
+	return &beatDetector{sensitivity: sensitivity, sinceBeat: minBeatGap}
+}
+
+func (d *beatDetector) update(rms float64) (beat, newSong bool) {
+	energy := rms * rms
+	var average float64
+	for _, e := range d.history {
+		average += e
+	}
+	average /= beatHistory
+
+	d.history[d.pos] = energy
+	d.pos = (d.pos + 1) % beatHistory
+	d.filled = d.filled || d.pos == 0
+	d.sinceBeat++
+
+	if rms < silenceRMS {
+		d.quiet++
+		return false, false
+	}
+	newSong = d.quiet >= songGapWindows
+	d.quiet = 0
+
+	if d.filled && d.sinceBeat >= minBeatGap && energy > average*d.sensitivity {
+		d.sinceBeat = 0
+		beat = true
+	}
+	return beat, newSong
+}
+
+// musicState is written by the analyzer and read by the generator
+type musicState struct {
+	mu    sync.Mutex
+	level float64
+	beats int
+	songs int
+}
+
+// musicSnapshot is the state at one point in time; beats and songs count up
+type musicSnapshot struct {
+	level float64
+	beats int
+	songs int
+}
+
+func (s *musicState) snapshot() musicSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return musicSnapshot{level: s.level, beats: s.beats, songs: s.songs}
+}
+
+// analyzeAudio feeds the state from source until it fails or is closed
+func analyzeAudio(source AudioSource, state *musicState, detector *beatDetector) error {
+	var meter energyMeter
+	buf := make([]float64, audioWindow)
+	for {
+		n, err := source.Read(buf)
+		if n > 0 {
+			r := rms(buf[:n])
+			level := meter.update(r)
+			beat, newSong := detector.update(r)
+			state.mu.Lock()
+			state.level = level
+			if beat {
+				state.beats++
+			}
+			if newSong {
+				state.songs++
+			}
+			state.mu.Unlock()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// minFlashInterval keeps strobe accents to three flashes a second, the
+// limit photosensitivity guidelines give for flashing content
+const minFlashInterval = time.Second / 3
+
+// musicGenerator lights the panels from the bottom up with the music level,
+// colored along the current palette. A new song moves to the next palette,
+// and so can every beat; beats can also flash the panels white.
+type musicGenerator struct {
+	opts          GeneratorOptions
+	palettes      [][]rgbColor
+	state         func() musicSnapshot
+	beatPalettes  bool
+	strobe        bool
+	palette       int
+	seenBeats     int
+	seenSongs     int
+	lastFlash     time.Duration
+	flashedBefore bool
+}
+
+func (g *musicGenerator) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g *musicGenerator) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	snap := g.state()
+	beat := snap.beats != g.seenBeats
+	if snap.songs != g.seenSongs || (beat && g.beatPalettes) {
+		g.palette = (g.palette + 1) % len(g.palettes)
+	}
+	g.seenBeats, g.seenSongs = snap.beats, snap.songs
+
+	if beat && g.strobe && (!g.flashedBefore || t-g.lastFlash >= minFlashInterval) {
+		g.lastFlash, g.flashedBefore = t, true
+		white := rgbColor{255, 255, 255}
+		return appendEachPanel(dst, layout, func(Panel, float64, float64) rgbColor { return white })
+	}
+
+	palette := g.palettes[g.palette]
+	drift := t.Seconds() * g.opts.Speed * 0.05
+	return appendEachPanel(dst, layout, func(p Panel, x, y float64) rgbColor {
+		// Panels above the level fade out over a short band
+		lit := math.Max(0, math.Min(1, (snap.level*1.2-y)*5))
+		return scaleColor(paletteAt(palette, y*0.5+drift), 0.1+0.9*lit*snap.level)
+	})
+}
+
+func runMusic(ctx context.Context, args []string) error {
+	fs := newFlagSet("music")
+	fps := fs.Int("fps", 30, "frames per second")
+	palette := fs.String("palette", "", "comma separated colors, several palettes separated by ; e.g. red,orange;navy,teal")
+	backend := fs.String("backend", "", "audio backend (auto, "+strings.Join(audioBackendNames(), ", ")+"), overrides the config")
+	sensitivity := fs.Float64("sensitivity", 0, fmt.Sprintf("how far above the average a beat must be (default %.1f, lower finds more beats)", defaultBeatSensitivity))
+	beatPalettes := fs.Bool("beat-palettes", false, "move to the next palette on every beat instead of every song")
+	strobe := fs.Bool("strobe", false, "flash white on beats, at most three times a second")
+	record := fs.String("record", "", "also record the frames to this file, for replay")
+	onExit := streamExitFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if *fps < 1 || *fps > 60 {
+		return invalidArgs(fmt.Errorf("--fps must be between 1 and 60"))
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	config := device.GetConfig()
+	settings := MusicConfig{}
+	if config.Music != nil {
+		settings = *config.Music
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "palette":
+			settings.Palettes = strings.Split(*palette, ";")
+		case "sensitivity":
+			settings.Sensitivity = *sensitivity
+		case "beat-palettes":
+			settings.BeatPalettes = *beatPalettes
+		case "strobe":
+			settings.Strobe = *strobe
+		}
+	})
+	if settings.Strobe && config.Photosensitive {
+		fmt.Fprintln(os.Stderr, "Strobe accents are off because photosensitive is set in the config")
+		settings.Strobe = false
+	}
+
+	generator := &musicGenerator{
+		opts:         GeneratorOptions{Speed: 1},
+		beatPalettes: settings.BeatPalettes,
+		strobe:       settings.Strobe,
+	}
+	for _, names := range settings.Palettes {
+		var colors []rgbColor
+		for _, name := range strings.Split(names, ",") {
+			color, err := parseColor(name)
+			if err != nil {
+				return err
+			}
+			colors = append(colors, color)
+		}
+		generator.palettes = append(generator.palettes, colors)
+	}
+	if len(generator.palettes) == 0 {
+		generator.palettes = [][]rgbColor{withPalette(GeneratorOptions{}, "#ff0080", "#7000ff", "#00c8ff").Palette}
+	}
+
+	audio := AudioConfig{}
+	if config.Audio != nil {
+		audio = *config.Audio
+	}
+	if *backend != "" {
+		audio.Backend, audio.Command = *backend, nil
+	}
+
+	source, err := openAudioSource(audio)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	state := &musicState{}
+	generator.state = state.snapshot
+	analyzed := make(chan error, 1)
+	go func() {
+		analyzed <- analyzeAudio(source, state, newBeatDetector(settings.Sensitivity))
+		cancel()
+	}()
+
+	recorded, stopRecording, err := startRecording(generator, *record)
+	if err != nil {
+		return err
+	}
+
+	statusf("Streaming music to %s (ctrl+c to stop)\n", device.GetDeviceIP())
+	scheduler := newFrameScheduler(*fps)
+	err = streamWithExit(ctx, device, recorded, scheduler, *onExit)
+	if recErr := stopRecording(); err == nil {
+		err = recErr
+	}
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-analyzed:
+		return fmt.Errorf("audio capture stopped: %w", err)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnergyMeter(t *testing.T) {
+	var m energyMeter
+	for i := 0; i < 20; i++ {
+		m.update(0.5)
+	}
+	if m.level < 0.95 {
+		t.Errorf("a steady signal should reach full level, got %.2f", m.level)
+	}
+
+	// Quiet music is normalized against its own peak after a while
+	for i := 0; i < 2000; i++ {
+		m.update(0.05)
+	}
+	if m.level < 0.9 {
+		t.Errorf("a quiet steady signal should be normalized, got %.2f", m.level)
+	}
+
+	// Silence falls off gradually rather than at once
+	level := m.update(0)
+	if level <= 0 || level >= 0.9 {
+		t.Errorf("expected a gradual fall, got %.2f", level)
+	}
+}
+
+func TestBeatDetector(t *testing.T) {
+	d := newBeatDetector(0)
+	beats := 0
+	// A kick every 20 windows over a steady background
+	for i := 0; i < 200; i++ {
+		level := 0.1
+		if i%20 == 0 {
+			level = 0.4
+		}
+		if beat, _ := d.update(level); beat {
+			beats++
+		}
+	}
+	// The first kicks fall before the average has a second of history
+	if beats < 7 || beats > 10 {
+		t.Errorf("expected a beat per kick, got %d", beats)
+	}
+
+	for i := 0; i < songGapWindows; i++ {
+		if _, newSong := d.update(0); newSong {
+			t.Fatal("silence is not a new song")
+		}
+	}
+	if _, newSong := d.update(0.1); !newSong {
+		t.Error("sound after a long silence should start a new song")
+	}
+	if _, newSong := d.update(0.1); newSong {
+		t.Error("only the first window after the silence is a new song")
+	}
+}
+
+func TestMusicGenerator(t *testing.T) {
+	layout := Layout{Panels: []Panel{{ID: 1, Y: 0, ShapeType: 7}, {ID: 2, Y: 200, ShapeType: 7}}}
+	white, _ := parseColor("white")
+	red, _ := parseColor("red")
+	snap := musicSnapshot{}
+	g := &musicGenerator{
+		opts:     GeneratorOptions{Speed: 1},
+		palettes: [][]rgbColor{{white}, {red}},
+		state:    func() musicSnapshot { return snap },
+	}
+
+	quiet := g.NextFrame(layout, time.Second)
+	snap.level = 0.5
+	half := g.NextFrame(layout, time.Second)
+
+	if quiet[0].R != quiet[1].R || quiet[0].R > 30 {
+		t.Errorf("silence should dim every panel, got %v", quiet)
+	}
+	if half[0].R <= quiet[0].R || half[1].R != quiet[1].R {
+		t.Errorf("half level should light only the bottom panel, got %v", half)
+	}
+
+	snap.songs++
+	if frame := g.NextFrame(layout, time.Second); frame[0].G != 0 {
+		t.Errorf("a new song should switch to the next palette, got %v", frame)
+	}
+
+	g.strobe = true
+	snap.beats++
+	if frame := g.NextFrame(layout, 2*time.Second); frame[1] != (PanelColor{PanelID: 2, R: 255, G: 255, B: 255}) {
+		t.Errorf("a beat should flash every panel, got %v", frame)
+	}
+	snap.beats++
+	if frame := g.NextFrame(layout, 2*time.Second+100*time.Millisecond); frame[1].G == 255 {
+		t.Error("flashes should be limited to three a second")
+	}
+}
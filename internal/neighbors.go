@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+var macPattern = regexp.MustCompile(`(?i)\b[0-9a-f]{1,2}(?:[:-][0-9a-f]{1,2}){5}\b`)
+
+// arpTable returns the neighbor table neighborHosts reads
+var arpTable = readARPTable
+
+// neighborHosts returns the addresses in the OS ARP/neighbor table that fall
+// inside one of networks. Errors are ignored since the table is only a hint.
+func neighborHosts(networks []*net.IPNet) []string {
+	var hosts []string
+	for _, ip := range parseARPTable(arpTable()) {
+		parsed := net.ParseIP(ip)
+		for _, network := range networks {
+			if parsed != nil && network.Contains(parsed) {
+				hosts = append(hosts, ip)
+				break
+			}
+		}
+	}
+	return hosts
+}
+
+// readARPTable reads /proc/net/arp on Linux and falls back to `arp -a`
+// elsewhere
+func readARPTable() string {
+	if data, err := os.ReadFile("/proc/net/arp"); err == nil {
+		return string(data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "arp", "-a").Output()
+	if err != nil {
+		return ""
+	}
+	return string(output)
+}
+
+// parseARPTable extracts the resolved IPv4 addresses from /proc/net/arp or
+// `arp -a` output (Linux, macOS and Windows formats)
+func parseARPTable(table string) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, line := range strings.Split(table, "\n") {
+		if strings.Contains(line, "incomplete") || strings.Contains(line, "00:00:00:00:00:00") {
+			continue
+		}
+		ip := ipv4Pattern.FindString(line)
+		if ip == "" || net.ParseIP(ip) == nil || seen[ip] {
+			continue
+		}
+		seen[ip] = true
+		hosts = append(hosts, ip)
+	}
+	return hosts
+}
+
+// parseARPMACs maps the MAC addresses in the same tables as parseARPTable,
+// normalized, to their IPv4 addresses
+func parseARPMACs(table string) map[string]string {
+	macs := make(map[string]string)
+	for _, line := range strings.Split(table, "\n") {
+		if strings.Contains(line, "incomplete") || strings.Contains(line, "00:00:00:00:00:00") {
+			continue
+		}
+		ip, mac := ipv4Pattern.FindString(line), macPattern.FindString(line)
+		if ip != "" && mac != "" && net.ParseIP(ip) != nil {
+			macs[normalizeMAC(mac)] = ip
+		}
+	}
+	return macs
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParseARPTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    string
+		expected []string
+	}{
+		{
+			name: "linux",
+			table: `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.1      0x1         0x2         a4:91:b1:00:11:22     *        wlan0
+192.168.1.57     0x1         0x2         00:55:da:50:12:34     *        wlan0
+192.168.1.99     0x1         0x0         00:00:00:00:00:00     *        wlan0
+`,
+			expected: []string{"192.168.1.1", "192.168.1.57"},
+		},
+		{
+			name: "macos",
+			table: `? (192.168.1.1) at a4:91:b1:0:11:22 on en0 ifscope [ethernet]
+? (192.168.1.57) at 0:55:da:50:12:34 on en0 ifscope [ethernet]
+? (192.168.1.80) at (incomplete) on en0 ifscope [ethernet]
+`,
+			expected: []string{"192.168.1.1", "192.168.1.57"},
+		},
+		{
+			name: "windows",
+			table: `Interface: 192.168.1.20 --- 0x5
+  Internet Address      Physical Address      Type
+  192.168.1.57          00-55-da-50-12-34     dynamic
+`,
+			expected: []string{"192.168.1.20", "192.168.1.57"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := parseARPTable(tt.table); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestParseARPMACs(t *testing.T) {
+	tables := map[string]string{
+		"linux": `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.57     0x1         0x2         00:55:DA:50:12:34     *        wlan0
+192.168.1.99     0x1         0x0         00:00:00:00:00:00     *        wlan0
+`,
+		"macos": `? (192.168.1.57) at 0:55:da:50:12:34 on en0 ifscope [ethernet]
+? (192.168.1.80) at (incomplete) on en0 ifscope [ethernet]
+`,
+		"windows": `Interface: 192.168.1.20 --- 0x5
+  Internet Address      Physical Address      Type
+  192.168.1.57          00-55-da-50-12-34     dynamic
+`,
+	}
+	expected := map[string]string{"00:55:da:50:12:34": "192.168.1.57"}
+	for name, table := range tables {
+		if got := parseARPMACs(table); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, got)
+		}
+	}
+}
+
+func TestProbeHosts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:16021")
+	if err != nil {
+		t.Skipf("port 16021 unavailable: %v", err)
+	}
+	defer listener.Close()
+
+	devices, err := probeHosts(context.Background(), []string{"127.0.0.1", "127.0.0.2"}, nil)
+	if err != nil {
+		t.Fatalf("probeHosts should not fail: %v", err)
+	}
+	if !reflect.DeepEqual(devices, []string{"127.0.0.1"}) {
+		t.Errorf("expected [127.0.0.1], got %v", devices)
+	}
+}
+
+func TestProbeHostsCancelledKeepsFound(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:16021")
+	if err != nil {
+		t.Skipf("port 16021 unavailable: %v", err)
+	}
+	defer listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var found []string
+	devices, err := probeHosts(ctx, []string{"127.0.0.1"}, func(ip string) {
+		found = append(found, ip)
+		cancel()
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(found, []string{"127.0.0.1"}) {
+		t.Errorf("expected found to report 127.0.0.1, got %v", found)
+	}
+	if !reflect.DeepEqual(devices, []string{"127.0.0.1"}) {
+		t.Errorf("devices found before cancelling should be returned, got %v", devices)
+	}
+}
+
+func TestScanSweepsBeyondNeighbors(t *testing.T) {
+	for _, ip := range []string{"127.0.0.2", "127.0.0.5"} {
+		listener, err := net.Listen("tcp", ip+":16021")
+		if err != nil {
+			t.Skipf("port 16021 unavailable on %s: %v", ip, err)
+		}
+		defer listener.Close()
+	}
+	original := arpTable
+	arpTable = func() string { return "127.0.0.2 0x1 0x2 aa:bb:cc:dd:ee:ff * lo" }
+	defer func() { arpTable = original }()
+
+	_, network, _ := net.ParseCIDR("127.0.0.0/29")
+	var mu sync.Mutex
+	var found []string
+	devices, err := scanForDevices(context.Background(), scanOptions{Range: network, Found: func(ip string) {
+		mu.Lock()
+		defer mu.Unlock()
+		found = append(found, ip)
+	}})
+	if err != nil {
+		t.Fatalf("scanForDevices should not fail: %v", err)
+	}
+	if !reflect.DeepEqual(devices, []string{"127.0.0.2", "127.0.0.5"}) {
+		t.Errorf("expected the neighbor and the device only the sweep finds, got %v", devices)
+	}
+	if len(found) == 0 || found[0] != "127.0.0.2" {
+		t.Errorf("expected the neighbor to be reported first, got %v", found)
+	}
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// notifyCommand returns the command that shows a desktop notification on
+// goos: a tray balloon through PowerShell on Windows, Notification Center
+// on macOS and notify-send elsewhere. The text may come from a request to
+// serve, so the PowerShell script reads title and text from the variables
+// in env rather than having them pasted in, where quotes of any kind,
+// including the typographic ones PowerShell also accepts, could end the
+// string, and notify-send gets them after --, so a person named -u in a
+// presence request is not read as an option.
+func notifyCommand(goos, title, text string) (args, env []string) {
+	switch goos {
+	case "windows":
+		script := "Add-Type -AssemblyName System.Windows.Forms; " +
+			"$n = New-Object System.Windows.Forms.NotifyIcon; " +
+			"$n.Icon = [System.Drawing.SystemIcons]::Information; " +
+			"$n.BalloonTipTitle = $env:NANOLEAF_NOTIFY_TITLE; " +
+			"$n.BalloonTipText = $env:NANOLEAF_NOTIFY_TEXT; " +
+			"$n.Visible = $true; $n.ShowBalloonTip(5000); Start-Sleep -Seconds 6; $n.Dispose()"
+		return []string{"powershell", "-NoProfile", "-NonInteractive", "-WindowStyle", "Hidden", "-Command", script},
+			[]string{"NANOLEAF_NOTIFY_TITLE=" + title, "NANOLEAF_NOTIFY_TEXT=" + text}
+	case "darwin":
+		quote := func(s string) string {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+		}
+		return []string{"osascript", "-e", "display notification " + quote(text) + " with title " + quote(title)}, nil
+	default:
+		return []string{"notify-send", "--app-name=nanoleaf-go", "--", title, text}, nil
+	}
+}
+
+// notify shows a desktop notification without waiting for it to close.
+// Notifications are a convenience, so a missing notifier is only reported.
+func notify(title, text string) {
+	args, env := notifyCommand(runtime.GOOS, title, text)
+	cmd := exec.Command(args[0], args[1:]...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if err := cmd.Start(); err != nil {
+		errorf("Could not show a notification: %v\n", err)
+		return
+	}
+	go cmd.Wait()
+}
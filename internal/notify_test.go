@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNotifyCommand(t *testing.T) {
+	payload := "unknown presence event \"’; Start-Process calc; ‘'\""
+	windows, env := notifyCommand("windows", "nanoleaf-go", payload)
+	script := windows[len(windows)-1]
+	if windows[0] != "powershell" || strings.Contains(script, "calc") || strings.ContainsAny(script, "’‘‚‛") {
+		t.Errorf("expected the text kept out of the PowerShell script, got %q", script)
+	}
+	if strings.Join(env, "\n") != "NANOLEAF_NOTIFY_TITLE=nanoleaf-go\nNANOLEAF_NOTIFY_TEXT="+payload {
+		t.Errorf("expected title and text in the environment, got %q", env)
+	}
+	darwin, _ := notifyCommand("darwin", "nanoleaf-go", `say "hi"`)
+	if darwin[0] != "osascript" || darwin[2] != `display notification "say \"hi\"" with title "nanoleaf-go"` {
+		t.Errorf("unexpected osascript command %q", darwin)
+	}
+	linux, _ := notifyCommand("linux", "-t", "x")
+	if linux[0] != "notify-send" || fmt.Sprint(linux[len(linux)-3:]) != "[-- -t x]" {
+		t.Errorf("unexpected notify-send command %q", linux)
+	}
+}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const spotifyPlayerURL = "https://api.spotify.com/v1/me/player/currently-playing"
+
+// nowPlaying is the current track and where its album art is
+type nowPlaying struct {
+	Title  string
+	ArtURL string
+}
+
+// trackSource reports the current track, or a zero nowPlaying when nothing
+// is playing
+type trackSource func(ctx context.Context) (nowPlaying, error)
+
+// mprisTrack reads the active MPRIS player through playerctl
+func mprisTrack(ctx context.Context) (nowPlaying, error) {
+	out, err := exec.CommandContext(ctx, "playerctl", "metadata", "--format", "{{artist}} - {{title}}\n{{mpris:artUrl}}").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// playerctl exits with 1 when no player is running
+		if errors.As(err, &exitErr) {
+			return nowPlaying{}, nil
+		}
+		return nowPlaying{}, fmt.Errorf("playerctl failed: %w", err)
+	}
+	title, art, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return nowPlaying{Title: title, ArtURL: strings.TrimSpace(art)}, nil
+}
+
+// spotifyTrack reads the track playing on the Spotify account of token
+func spotifyTrack(httpClient *http.Client, endpoint, token string) trackSource {
+	return func(ctx context.Context) (nowPlaying, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nowPlaying{}, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nowPlaying{}, fmt.Errorf("spotify request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusNoContent:
+			return nowPlaying{}, nil
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			return nowPlaying{}, fmt.Errorf("spotify rejected the access token, it may have expired")
+		default:
+			return nowPlaying{}, fmt.Errorf("spotify request failed with status %d", resp.StatusCode)
+		}
+
+		var result struct {
+			IsPlaying bool `json:"is_playing"`
+			Item      *struct {
+				Name    string `json:"name"`
+				Artists []struct {
+					Name string `json:"name"`
+				} `json:"artists"`
+				Album struct {
+					Images []struct {
+						URL string `json:"url"`
+					} `json:"images"`
+				} `json:"album"`
+			} `json:"item"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nowPlaying{}, fmt.Errorf("failed to parse spotify response: %w", err)
+		}
+		if !result.IsPlaying || result.Item == nil || len(result.Item.Album.Images) == 0 {
+			return nowPlaying{}, nil
+		}
+
+		title := result.Item.Name
+		if len(result.Item.Artists) > 0 {
+			title = result.Item.Artists[0].Name + " - " + title
+		}
+		// Images are ordered largest first; the palette needs no detail
+		images := result.Item.Album.Images
+		return nowPlaying{Title: title, ArtURL: images[len(images)-1].URL}, nil
+	}
+}
+
+// fetchAlbumArt loads album art from a file:// or http(s) URL
+func fetchAlbumArt(ctx context.Context, httpClient *http.Client, artURL string) (image.Image, error) {
+	u, err := url.Parse(artURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "file" {
+		file, err := os.Open(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		img, _, err := image.Decode(file)
+		return img, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", artURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("album art request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("album art request failed with status %d", resp.StatusCode)
+	}
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode album art: %w", err)
+	}
+	return img, nil
+}
+
+// nowPlayingSync shows a flow effect of the album art colors whenever the
+// art changes
+type nowPlayingSync struct {
+	device     *Device
+	httpClient *http.Client
+	colors     int
+	lastArt    string
+}
+
+// update applies the palette of track if its art differs from the last
+// one and reports whether it did
+func (s *nowPlayingSync) update(ctx context.Context, track nowPlaying) (bool, error) {
+	if track.ArtURL == "" || track.ArtURL == s.lastArt {
+		return false, nil
+	}
+
+	img, err := fetchAlbumArt(ctx, s.httpClient, track.ArtURL)
+	if err != nil {
+		return false, err
+	}
+	effect, err := paletteEffect("Now Playing", "flow", dominantColors(img, s.colors), Layout{})
+	if err != nil {
+		return false, err
+	}
+	if err := s.device.DisplayEffect(ctx, effect); err != nil {
+		return false, err
+	}
+	s.lastArt = track.ArtURL
+	return true, nil
+}
+
+func runNowPlaying(ctx context.Context, args []string) error {
+	fs := newFlagSet("now-playing")
+	source := fs.String("source", "mpris", "where to read the track from: mpris (via playerctl) or spotify")
+	token := fs.String("spotify-token", os.Getenv("SPOTIFY_TOKEN"), "Spotify access token with user-read-currently-playing (defaults to $SPOTIFY_TOKEN)")
+	colors := fs.Int("colors", 4, "number of colors to take from the album art")
+	interval := fs.Duration("interval", 5*time.Second, "how often to check the current track")
+	ignoreQuiet := addQuietHoursFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if err := checkInterval(*interval); err != nil {
+		return err
+	}
+	if *colors < 1 || *colors > 16 {
+		return invalidArgs(fmt.Errorf("--colors must be between 1 and 16"))
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	var current trackSource
+	switch *source {
+	case "mpris":
+		if _, err := exec.LookPath("playerctl"); err != nil {
+			return fmt.Errorf("the mpris source needs playerctl")
+		}
+		current = mprisTrack
+	case "spotify":
+		if *token == "" {
+			return invalidArgs(fmt.Errorf("--spotify-token or $SPOTIFY_TOKEN is required"))
+		}
+		current = spotifyTrack(httpClient, spotifyPlayerURL, *token)
+	default:
+		return invalidArgs(fmt.Errorf("unknown source %q, expected mpris or spotify", *source))
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	quiet, err := newQuietGate(device.GetConfig(), *ignoreQuiet)
+	if err != nil {
+		return err
+	}
+	sync := &nowPlayingSync{device: device, httpClient: httpClient, colors: *colors}
+
+	errs, err := newErrorLog(device.GetConfig(), "Now playing update")
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	statusf("Following the %s player every %s (ctrl+c to stop)\n", *source, *interval)
+	statusf("%s\n", quiet)
+	watchManualChanges(ctx, device)
+	for {
+		var changed bool
+		var track nowPlaying
+		err := quiet.settle(ctx, device)
+		if err == nil {
+			track, err = current(ctx)
+		}
+		if err == nil && !quiet.held() {
+			changed, err = sync.update(ctx, track)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			errs.failed(err)
+		} else {
+			errs.succeeded()
+			if changed {
+				statusf("Now playing: %s\n", track.Title)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
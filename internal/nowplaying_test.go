@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSpotifyTrack(t *testing.T) {
+	playing := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !playing {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"is_playing":true,"item":{"name":"Song","artists":[{"name":"Band"}],
+			"album":{"images":[{"url":"https://i.example/640"},{"url":"https://i.example/64"}]}}}`))
+	}))
+	defer server.Close()
+
+	current := spotifyTrack(server.Client(), server.URL, "test-token")
+	track, err := current(context.Background())
+	if err != nil {
+		t.Fatalf("spotifyTrack should not fail: %v", err)
+	}
+	if track != (nowPlaying{Title: "Band - Song", ArtURL: "https://i.example/64"}) {
+		t.Errorf("unexpected track %+v", track)
+	}
+
+	playing = false
+	if track, err := current(context.Background()); err != nil || track != (nowPlaying{}) {
+		t.Errorf("expected nothing playing, got %+v %v", track, err)
+	}
+
+	if _, err := spotifyTrack(server.Client(), server.URL, "expired")(context.Background()); err == nil {
+		t.Error("a rejected token should fail")
+	}
+}
+
+func TestNowPlayingSync(t *testing.T) {
+	art := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range art.Pix {
+		art.Pix[i] = 255
+	}
+	art.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var artPNG bytes.Buffer
+	png.Encode(&artPNG, art)
+
+	var displayed []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/art.png" {
+			w.Write(artPNG.Bytes())
+			return
+		}
+		var payload map[string]map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		displayed = append(displayed, payload["write"])
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	sync := &nowPlayingSync{device: device, httpClient: server.Client(), colors: 2}
+	ctx := context.Background()
+	track := nowPlaying{Title: "Band - Song", ArtURL: server.URL + "/art.png"}
+
+	if changed, err := sync.update(ctx, track); err != nil || !changed {
+		t.Fatalf("the first track should be shown, got %v %v", changed, err)
+	}
+	if changed, _ := sync.update(ctx, track); changed {
+		t.Error("the same art should not be shown again")
+	}
+	if changed, _ := sync.update(ctx, nowPlaying{}); changed {
+		t.Error("nothing playing should keep the last effect")
+	}
+
+	if len(displayed) != 1 || displayed[0]["command"] != "display" || displayed[0]["animType"] != "flow" {
+		t.Fatalf("expected one displayed flow effect, got %v", displayed)
+	}
+	if palette := displayed[0]["palette"].([]interface{}); len(palette) != 2 {
+		t.Errorf("expected 2 colors from the art, got %v", palette)
+	}
+}
@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultOBSURL is where obs-websocket 5, built into OBS 28 and later,
+// listens unless told otherwise
+const defaultOBSURL = "ws://localhost:4455"
+
+// OBSConfig configures the obs command. Password is the one under Tools →
+// WebSocket Server Settings, and may be a cmd: reference.
+type OBSConfig struct {
+	URL      string    `json:"url,omitempty"`
+	Password string    `json:"password,omitempty"`
+	Rules    []OBSRule `json:"rules,omitempty"`
+}
+
+// OBSRule runs Actions on an OBS event: stream-started, stream-stopped,
+// recording-started, recording-stopped, or scene when the program scene
+// changes to Scene, or to any scene without one. Rules are gated like
+// presence rules, with the priority of obs unless set.
+type OBSRule struct {
+	Event            string   `json:"event"`
+	Scene            string   `json:"scene,omitempty"`
+	Actions          []string `json:"actions"`
+	IgnoreQuietHours bool     `json:"ignoreQuietHours,omitempty"`
+	Priority         string   `json:"priority,omitempty"`
+}
+
+var obsEvents = []string{"stream-started", "stream-stopped", "recording-started", "recording-stopped", "scene"}
+
+type obsRule struct {
+	OBSRule
+	actions  []action
+	priority priority
+	name     string
+}
+
+// newOBSRules parses the rules, taking the priority of obs from quiet
+func newOBSRules(quiet *quietGate, rules []OBSRule) ([]obsRule, error) {
+	var parsed []obsRule
+	for i, rule := range rules {
+		valid := false
+		for _, event := range obsEvents {
+			valid = valid || rule.Event == event
+		}
+		if !valid {
+			return nil, fmt.Errorf("obs rule %d: unknown event %q (expected %s)", i+1, rule.Event, strings.Join(obsEvents, ", "))
+		}
+		if rule.Scene != "" && rule.Event != "scene" {
+			return nil, fmt.Errorf("obs rule %d: scene only applies to the scene event", i+1)
+		}
+		actions, err := parseMacro(rule.Actions)
+		if err != nil {
+			return nil, fmt.Errorf("obs rule %d: %w", i+1, err)
+		}
+		p := priorityNotification
+		if quiet != nil && quiet.priority != 0 {
+			p = quiet.priority
+		}
+		if rule.Priority != "" {
+			if p, err = parsePriority(rule.Priority); err != nil {
+				return nil, fmt.Errorf("obs rule %d: %w", i+1, err)
+			}
+		}
+		parsed = append(parsed, obsRule{OBSRule: rule, actions: actions, priority: p, name: fmt.Sprintf("obs rule %d", i+1)})
+	}
+	return parsed, nil
+}
+
+// obsMessage is an obs-websocket 5 message: an op code and its data
+type obsMessage struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+}
+
+// obs-websocket op codes and the event subscriptions the bridge needs
+const (
+	obsOpHello      = 0
+	obsOpIdentify   = 1
+	obsOpIdentified = 2
+	obsOpEvent      = 5
+
+	obsSubscribeScenes  = 1 << 2
+	obsSubscribeOutputs = 1 << 6
+)
+
+// obsAuthentication answers the challenge of the Hello message:
+// base64(sha256(base64(sha256(password + salt)) + challenge))
+func obsAuthentication(password, salt, challenge string) string {
+	secret := sha256.Sum256([]byte(password + salt))
+	auth := sha256.Sum256([]byte(base64.StdEncoding.EncodeToString(secret[:]) + challenge))
+	return base64.StdEncoding.EncodeToString(auth[:])
+}
+
+// obsEvent turns an obs-websocket event into the event and scene of the
+// rules. Output states other than started and stopped, such as starting or
+// reconnecting, are left out.
+func obsEvent(data json.RawMessage) (event, scene string, ok bool) {
+	var e struct {
+		EventType string `json:"eventType"`
+		EventData struct {
+			OutputState string `json:"outputState"`
+			SceneName   string `json:"sceneName"`
+		} `json:"eventData"`
+	}
+	if json.Unmarshal(data, &e) != nil {
+		return "", "", false
+	}
+	output := map[string]string{"StreamStateChanged": "stream", "RecordStateChanged": "recording"}[e.EventType]
+	switch {
+	case e.EventType == "CurrentProgramSceneChanged":
+		return "scene", e.EventData.SceneName, true
+	case output != "" && e.EventData.OutputState == "OBS_WEBSOCKET_OUTPUT_STARTED":
+		return output + "-started", "", true
+	case output != "" && e.EventData.OutputState == "OBS_WEBSOCKET_OUTPUT_STOPPED":
+		return output + "-stopped", "", true
+	}
+	return "", "", false
+}
+
+// dialOBS connects and identifies, subscribing to the output and scene
+// events
+func dialOBS(ctx context.Context, url, password string) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach OBS at %s (is the WebSocket server enabled under Tools?): %w", url, err)
+	}
+	var hello struct {
+		Authentication *struct {
+			Challenge string `json:"challenge"`
+			Salt      string `json:"salt"`
+		} `json:"authentication"`
+	}
+	var msg obsMessage
+	if err := conn.ReadJSON(&msg); err != nil || msg.Op != obsOpHello || json.Unmarshal(msg.D, &hello) != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s did not greet as obs-websocket 5: %v", url, err)
+	}
+	identify := map[string]interface{}{"rpcVersion": 1, "eventSubscriptions": obsSubscribeScenes | obsSubscribeOutputs}
+	if hello.Authentication != nil {
+		if password == "" {
+			conn.Close()
+			return nil, fmt.Errorf("OBS asks for a password, set obs.password in the config or $OBS_PASSWORD")
+		}
+		identify["authentication"] = obsAuthentication(password, hello.Authentication.Salt, hello.Authentication.Challenge)
+	}
+	if err := conn.WriteJSON(map[string]interface{}{"op": obsOpIdentify, "d": identify}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.ReadJSON(&msg); err != nil || msg.Op != obsOpIdentified {
+		conn.Close()
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) && closeErr.Code == 4009 {
+			return nil, fmt.Errorf("OBS refused the password")
+		}
+		return nil, fmt.Errorf("OBS did not accept the connection: %v", err)
+	}
+	return conn, nil
+}
+
+// obsBridge runs the rules matching the events of OBS
+type obsBridge struct {
+	device *Device
+	quiet  *quietGate
+	rules  []obsRule
+}
+
+// handle runs the rules matching event and scene, in config order, and
+// returns how many ran
+func (b *obsBridge) handle(ctx context.Context, event, scene string) (int, error) {
+	ran := 0
+	for _, rule := range b.rules {
+		if rule.Event != event || (rule.Scene != "" && !strings.EqualFold(rule.Scene, scene)) {
+			continue
+		}
+		held, err := runGated(ctx, b.device, b.quiet, rule.name, rule.priority, rule.actions, rule.IgnoreQuietHours)
+		if err != nil {
+			return ran, err
+		}
+		if held != "" {
+			statusf("Holding %s: %s\n", rule.name, held)
+			continue
+		}
+		ran++
+	}
+	return ran, nil
+}
+
+// listen handles the events of conn until it closes or ctx is done
+func (b *obsBridge) listen(ctx context.Context, conn *websocket.Conn, errs *errorLog) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	for {
+		var msg obsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("lost the connection to OBS: %w", err)
+		}
+		if msg.Op != obsOpEvent {
+			continue
+		}
+		event, scene, ok := obsEvent(msg.D)
+		if !ok {
+			continue
+		}
+		label := event
+		if scene != "" {
+			label += " " + scene
+		}
+		ran, err := b.handle(ctx, event, scene)
+		if err != nil {
+			errs.failed(err)
+			continue
+		}
+		errs.succeeded()
+		if ran > 0 {
+			statusf("OBS %s: ran %d rule(s)\n", label, ran)
+		}
+	}
+}
+
+func runOBS(ctx context.Context, args []string) error {
+	fs := newFlagSet("obs")
+	url := fs.String("url", "", "obs-websocket address (default obs.url in the config, else "+defaultOBSURL+")")
+	retry := fs.Duration("retry", 10*time.Second, "how long to wait before connecting again after OBS closed or could not be reached")
+	ignoreQuiet := addQuietHoursFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if *retry <= 0 {
+		return invalidArgs(fmt.Errorf("--retry must be above 0, e.g. 10s"))
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	config := device.GetConfig()
+	settings := OBSConfig{}
+	if config.OBS != nil {
+		settings = *config.OBS
+	}
+	if len(settings.Rules) == 0 {
+		return fmt.Errorf("no obs.rules in the config, add some such as {\"event\": \"stream-started\", \"actions\": [\"color red\"]}")
+	}
+	if *url != "" {
+		settings.URL = *url
+	}
+	if settings.URL == "" {
+		settings.URL = defaultOBSURL
+	}
+	if password := os.Getenv("OBS_PASSWORD"); password != "" {
+		settings.Password = password
+	}
+
+	quiet, err := newQuietGate(config, *ignoreQuiet)
+	if err != nil {
+		return err
+	}
+	rules, err := newOBSRules(quiet, settings.Rules)
+	if err != nil {
+		return err
+	}
+	errs, err := newErrorLog(config, "OBS")
+	if err != nil {
+		return err
+	}
+	bridge := &obsBridge{device: device, quiet: quiet, rules: rules}
+
+	statusf("Following OBS at %s with %d rule(s) (ctrl+c to stop)\n", settings.URL, len(rules))
+	statusf("%s\n", quiet)
+	watchManualChanges(ctx, device)
+	for {
+		dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		conn, err := dialOBS(dialCtx, settings.URL, settings.Password)
+		cancel()
+		if err == nil {
+			errs.succeeded()
+			err = bridge.listen(ctx, conn, errs)
+			conn.Close()
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			errs.failed(err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*retry):
+		}
+	}
+}
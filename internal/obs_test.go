@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestOBSAuthentication(t *testing.T) {
+	// The example of the obs-websocket 5 protocol documentation
+	got := obsAuthentication("supersecretpassword", "lM1GncleQOaCu9lT1yeUZhFYnqhsLLP1G5lAGo3ixaI=", "+IxH4CnCiqpX1rM9scsNynZzbOe4KhDeYcTNS3PDaeY=")
+	if got != "1Ct943GAT+6YQUUX47Ia/ncufilbe6+oD6lY+5kaCu4=" {
+		t.Errorf("unexpected authentication %q", got)
+	}
+}
+
+func TestOBSEvent(t *testing.T) {
+	tests := map[string]string{
+		`{"eventType":"StreamStateChanged","eventData":{"outputActive":true,"outputState":"OBS_WEBSOCKET_OUTPUT_STARTED"}}`:   "stream-started",
+		`{"eventType":"RecordStateChanged","eventData":{"outputActive":false,"outputState":"OBS_WEBSOCKET_OUTPUT_STOPPED"}}`:  "recording-stopped",
+		`{"eventType":"CurrentProgramSceneChanged","eventData":{"sceneName":"Just Chatting"}}`:                                "scene Just Chatting",
+		`{"eventType":"StreamStateChanged","eventData":{"outputActive":false,"outputState":"OBS_WEBSOCKET_OUTPUT_STARTING"}}`: "",
+		`{"eventType":"InputMuteStateChanged","eventData":{}}`:                                                                "",
+	}
+	for data, want := range tests {
+		event, scene, _ := obsEvent(json.RawMessage(data))
+		if got := strings.TrimSpace(event + " " + scene); got != want {
+			t.Errorf("obsEvent(%s) = %q, expected %q", data, got, want)
+		}
+	}
+}
+
+func TestOBSBridge(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	sent := make(chan string, 10)
+	nanoleaf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sent <- string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer nanoleaf.Close()
+	device := NewDevice()
+	device.config.IP = nanoleaf.URL
+	device.config.Token = "test-token"
+
+	obs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(map[string]interface{}{"op": obsOpHello, "d": map[string]interface{}{
+			"rpcVersion":     1,
+			"authentication": map[string]string{"challenge": "challenge", "salt": "salt"},
+		}})
+		var identify struct {
+			D struct {
+				Authentication     string `json:"authentication"`
+				EventSubscriptions int    `json:"eventSubscriptions"`
+			} `json:"d"`
+		}
+		if conn.ReadJSON(&identify) != nil || identify.D.Authentication != obsAuthentication("secret", "salt", "challenge") {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4009, "Authentication failed."))
+			return
+		}
+		conn.WriteJSON(map[string]interface{}{"op": obsOpIdentified, "d": map[string]int{"negotiatedRpcVersion": 1}})
+		for _, event := range []string{
+			`{"eventType":"CurrentProgramSceneChanged","eventData":{"sceneName":"Gaming"}}`,
+			`{"eventType":"StreamStateChanged","eventData":{"outputActive":true,"outputState":"OBS_WEBSOCKET_OUTPUT_STARTED"}}`,
+		} {
+			conn.WriteJSON(map[string]interface{}{"op": obsOpEvent, "d": json.RawMessage(event)})
+		}
+		conn.ReadMessage()
+	}))
+	defer obs.Close()
+	url := "ws" + strings.TrimPrefix(obs.URL, "http")
+
+	if _, err := dialOBS(context.Background(), url, "wrong"); err == nil || !strings.Contains(err.Error(), "refused the password") {
+		t.Errorf("expected a wrong password to be refused, got %v", err)
+	}
+	conn, err := dialOBS(context.Background(), url, "secret")
+	if err != nil {
+		t.Fatalf("dialOBS should not fail: %v", err)
+	}
+
+	rules, err := newOBSRules(nil, []OBSRule{
+		{Event: "scene", Scene: "just chatting", Actions: []string{"brightness 30"}},
+		{Event: "scene", Scene: "gaming", Actions: []string{"brightness 70"}},
+		{Event: "stream-started", Actions: []string{"brightness 100"}},
+	})
+	if err != nil {
+		t.Fatalf("newOBSRules should not fail: %v", err)
+	}
+	errs, _ := newErrorLog(Config{}, "OBS")
+	bridge := &obsBridge{device: device, rules: rules}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bridge.listen(ctx, conn, errs) }()
+
+	for _, want := range []string{`{"brightness":{"value":70}}`, `{"brightness":{"value":100}}`} {
+		select {
+		case got := <-sent:
+			if got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("listen should stop quietly on cancel, got %v", err)
+	}
+
+	for _, invalid := range []OBSRule{
+		{Event: "live", Actions: []string{"on"}},
+		{Event: "stream-started", Scene: "Gaming", Actions: []string{"on"}},
+		{Event: "scene", Actions: []string{"on"}, Priority: "urgent"},
+	} {
+		if _, err := newOBSRules(nil, []OBSRule{invalid}); err == nil {
+			t.Errorf("expected %+v to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultOpenRGBAddr is where the OpenRGB SDK server listens unless told
+// otherwise
+const defaultOpenRGBAddr = "localhost:6742"
+
+// Packet ids of the OpenRGB SDK protocol
+const (
+	openRGBRequestControllerCount = 0
+	openRGBRequestControllerData  = 1
+	openRGBSetClientName          = 50
+)
+
+// openRGBTimeout bounds one request to the SDK server, which answers from
+// memory
+const openRGBTimeout = 5 * time.Second
+
+// openRGBController is what the bridge reads of an OpenRGB device: its
+// name and the current color of each LED
+type openRGBController struct {
+	Name   string
+	Colors []rgbColor
+}
+
+// openRGBClient speaks the OpenRGB SDK protocol, version 0, which every
+// server since OpenRGB 0.5 accepts. Each packet is the magic "ORGB", the
+// device index, the packet id and the size of the data, little-endian.
+type openRGBClient struct {
+	conn net.Conn
+}
+
+func dialOpenRGB(ctx context.Context, addr string) (*openRGBClient, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the OpenRGB SDK server at %s (is the SDK server started in OpenRGB?): %w", addr, err)
+	}
+	c := &openRGBClient{conn: conn}
+	if err := c.send(openRGBSetClientName, 0, []byte("nanoleaf-go\x00")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *openRGBClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *openRGBClient) send(id, device uint32, data []byte) error {
+	c.conn.SetDeadline(time.Now().Add(openRGBTimeout))
+	packet := append([]byte("ORGB"), make([]byte, 12)...)
+	binary.LittleEndian.PutUint32(packet[4:], device)
+	binary.LittleEndian.PutUint32(packet[8:], id)
+	binary.LittleEndian.PutUint32(packet[12:], uint32(len(data)))
+	_, err := c.conn.Write(append(packet, data...))
+	return err
+}
+
+// request sends a packet and returns the data of the reply. Packets the
+// server sends on its own, such as a device list update, are skipped.
+func (c *openRGBClient) request(id, device uint32) ([]byte, error) {
+	if err := c.send(id, device, nil); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			return nil, err
+		}
+		if string(header[:4]) != "ORGB" {
+			return nil, fmt.Errorf("not an OpenRGB SDK server (got %q)", header[:4])
+		}
+		data := make([]byte, binary.LittleEndian.Uint32(header[12:]))
+		if _, err := io.ReadFull(c.conn, data); err != nil {
+			return nil, err
+		}
+		if binary.LittleEndian.Uint32(header[8:]) == id {
+			return data, nil
+		}
+	}
+}
+
+func (c *openRGBClient) controllerCount() (int, error) {
+	data, err := c.request(openRGBRequestControllerCount, 0)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < 4 {
+		return 0, fmt.Errorf("short controller count reply")
+	}
+	return int(binary.LittleEndian.Uint32(data)), nil
+}
+
+func (c *openRGBClient) controller(index int) (openRGBController, error) {
+	data, err := c.request(openRGBRequestControllerData, uint32(index))
+	if err != nil {
+		return openRGBController{}, err
+	}
+	controller, err := parseOpenRGBController(data)
+	if err != nil {
+		return openRGBController{}, fmt.Errorf("controller %d: %w", index, err)
+	}
+	return controller, nil
+}
+
+// openRGBReader reads the little-endian fields of a controller data
+// block, remembering the first read past its end
+type openRGBReader struct {
+	data []byte
+	err  error
+}
+
+func (r *openRGBReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errors.New("controller data is cut short")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *openRGBReader) u16() int {
+	if b := r.take(2); b != nil {
+		return int(binary.LittleEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *openRGBReader) u32() int {
+	if b := r.take(4); b != nil {
+		return int(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
+
+// str reads a string: its length including the terminating NUL, then the
+// bytes
+func (r *openRGBReader) str() string {
+	return strings.TrimRight(string(r.take(r.u16())), "\x00")
+}
+
+func (r *openRGBReader) colors() []rgbColor {
+	n := r.u16()
+	colors := make([]rgbColor, 0, n)
+	for i := 0; i < n; i++ {
+		if b := r.take(4); b != nil {
+			colors = append(colors, rgbColor{b[0], b[1], b[2]})
+		}
+	}
+	return colors
+}
+
+// parseOpenRGBController reads the controller data of protocol version 0,
+// skipping over the modes, zones and LED names to the colors at the end
+func parseOpenRGBController(data []byte) (openRGBController, error) {
+	r := &openRGBReader{data: data}
+	r.u32() // size of the block
+	r.u32() // device type
+	controller := openRGBController{Name: r.str()}
+	for i := 0; i < 4; i++ {
+		r.str() // description, version, serial and location
+	}
+	modes := r.u16()
+	r.u32() // active mode
+	for i := 0; i < modes && r.err == nil; i++ {
+		r.str()
+		r.take(4 * 8) // value, flags, speed and color limits, speed, direction, color mode
+		r.colors()
+	}
+	zones := r.u16()
+	for i := 0; i < zones && r.err == nil; i++ {
+		r.str()
+		r.take(4 * 4) // type and LED counts
+		r.take(r.u16())
+	}
+	leds := r.u16()
+	for i := 0; i < leds && r.err == nil; i++ {
+		r.str()
+		r.take(4)
+	}
+	controller.Colors = r.colors()
+	return controller, r.err
+}
+
+// openRGBGenerator shows the latest LED colors read from OpenRGB, spread
+// over the panels from left to right
+type openRGBGenerator struct {
+	mu     sync.Mutex
+	colors []rgbColor
+	// order lists the light panels of the layout by x, worked out once
+	order []Panel
+}
+
+func (g *openRGBGenerator) set(colors []rgbColor) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.colors = append(g.colors[:0], colors...)
+}
+
+func (g *openRGBGenerator) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+// AppendFrame gives each panel the average of its share of the LEDs, so a
+// strip of 120 LEDs over 12 panels shows 10 on each
+func (g *openRGBGenerator) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.order == nil {
+		g.order = layout.LightPanels()
+		sort.SliceStable(g.order, func(i, j int) bool { return g.order[i].X < g.order[j].X })
+	}
+	dst = dst[:0]
+	n, panels := len(g.colors), len(g.order)
+	for i, p := range g.order {
+		var r, gr, b, count int
+		from, to := i*n/panels, (i+1)*n/panels
+		if to <= from && n > 0 {
+			to = from + 1
+		}
+		for _, c := range g.colors[from:to] {
+			r, gr, b, count = r+int(c.R), gr+int(c.G), b+int(c.B), count+1
+		}
+		if count > 0 {
+			r, gr, b = r/count, gr/count, b/count
+		}
+		dst = append(dst, PanelColor{PanelID: p.ID, R: uint8(r), G: uint8(gr), B: uint8(b)})
+	}
+	return dst
+}
+
+// pollOpenRGB reads the colors of controllers every interval and hands
+// them, one after another, to generator until ctx is done or a read fails
+func pollOpenRGB(ctx context.Context, client *openRGBClient, controllers []int, interval time.Duration, generator *openRGBGenerator) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var colors []rgbColor
+	for {
+		colors = colors[:0]
+		for _, index := range controllers {
+			controller, err := client.controller(index)
+			if err != nil {
+				return err
+			}
+			colors = append(colors, controller.Colors...)
+		}
+		generator.set(colors)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseControllerList reads --controllers, a comma separated list of
+// indexes, checked against the count the server reported
+func parseControllerList(s string, count int) ([]int, error) {
+	if s == "" {
+		all := make([]int, count)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	}
+	var indexes []int
+	for _, part := range strings.Split(s, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || index < 0 || index >= count {
+			return nil, fmt.Errorf("--controllers: %q is not a controller, expected an index from 0 to %d (see --list)", part, count-1)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+func runOpenRGB(ctx context.Context, args []string) error {
+	fs := newFlagSet("openrgb")
+	addr := fs.String("addr", defaultOpenRGBAddr, "address of the OpenRGB SDK server")
+	list := fs.Bool("list", false, "list the OpenRGB controllers and their indexes, then exit")
+	controllerFlag := fs.String("controllers", "", "comma separated controller indexes to mirror, in order (default all)")
+	fps := fs.Int("fps", 20, "frames per second")
+	onExit := streamExitFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if *fps < 1 || *fps > 60 {
+		return invalidArgs(fmt.Errorf("--fps must be between 1 and 60"))
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, openRGBTimeout)
+	client, err := dialOpenRGB(dialCtx, *addr)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	count, err := client.controllerCount()
+	if err != nil {
+		return err
+	}
+	if *list {
+		for i := 0; i < count; i++ {
+			controller, err := client.controller(i)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d  %s (%d LEDs)\n", i, controller.Name, len(controller.Colors))
+		}
+		return nil
+	}
+	if count == 0 {
+		return fmt.Errorf("OpenRGB has no controllers to mirror")
+	}
+	controllers, err := parseControllerList(*controllerFlag, count)
+	if err != nil {
+		return invalidArgs(err)
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	generator := &openRGBGenerator{}
+	polled := make(chan error, 1)
+	go func() {
+		polled <- pollOpenRGB(ctx, client, controllers, time.Second/time.Duration(*fps), generator)
+		stop()
+	}()
+
+	statusf("Mirroring %d OpenRGB controller(s) from %s to %s (ctrl+c to stop)\n", len(controllers), *addr, device.GetDeviceIP())
+	if err := streamWithExit(ctx, device, generator, newFrameScheduler(*fps), *onExit); err != nil {
+		return err
+	}
+	if err := <-polled; err != nil {
+		return fmt.Errorf("lost the OpenRGB SDK server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// encodeOpenRGBController builds the controller data of protocol version 0
+// with one mode, one zone and an LED name per color
+func encodeOpenRGBController(name string, colors []rgbColor) []byte {
+	var b []byte
+	u16 := func(v int) { b = binary.LittleEndian.AppendUint16(b, uint16(v)) }
+	u32 := func(v int) { b = binary.LittleEndian.AppendUint32(b, uint32(v)) }
+	str := func(s string) { u16(len(s) + 1); b = append(b, s...); b = append(b, 0) }
+	colorList := func(colors []rgbColor) {
+		u16(len(colors))
+		for _, c := range colors {
+			b = append(b, c.R, c.G, c.B, 0)
+		}
+	}
+
+	u32(0)
+	u32(2)
+	str(name)
+	for _, s := range []string{"a strip", "1.0", "", "HID"} {
+		str(s)
+	}
+	u16(1)
+	u32(0)
+	str("Direct")
+	for i := 0; i < 8; i++ {
+		u32(i)
+	}
+	colorList([]rgbColor{{1, 2, 3}})
+	u16(1)
+	str("Strip")
+	for i := 0; i < 4; i++ {
+		u32(len(colors))
+	}
+	u16(12)
+	u32(1)
+	u32(1)
+	u32(0)
+	u16(len(colors))
+	for i := range colors {
+		str("LED")
+		u32(i)
+	}
+	colorList(colors)
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+func TestParseOpenRGBController(t *testing.T) {
+	colors := []rgbColor{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}}
+	controller, err := parseOpenRGBController(encodeOpenRGBController("Keyboard", colors))
+	if err != nil {
+		t.Fatalf("parseOpenRGBController should not fail: %v", err)
+	}
+	if controller.Name != "Keyboard" || len(controller.Colors) != 3 || controller.Colors[2] != colors[2] {
+		t.Errorf("expected the name and colors, got %+v", controller)
+	}
+	data := encodeOpenRGBController("Keyboard", colors)
+	if _, err := parseOpenRGBController(data[:len(data)-5]); err == nil {
+		t.Error("expected cut short data to be rejected")
+	}
+}
+
+func TestOpenRGBClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reply := func(id, device uint32, data []byte) {
+			header := append([]byte("ORGB"), make([]byte, 12)...)
+			binary.LittleEndian.PutUint32(header[4:], device)
+			binary.LittleEndian.PutUint32(header[8:], id)
+			binary.LittleEndian.PutUint32(header[12:], uint32(len(data)))
+			conn.Write(append(header, data...))
+		}
+		header := make([]byte, 16)
+		for {
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			data := make([]byte, binary.LittleEndian.Uint32(header[12:]))
+			io.ReadFull(conn, data)
+			device, id := binary.LittleEndian.Uint32(header[4:]), binary.LittleEndian.Uint32(header[8:])
+			switch id {
+			case openRGBRequestControllerCount:
+				// A device list update arriving first should be skipped
+				reply(100, 0, nil)
+				reply(id, 0, binary.LittleEndian.AppendUint32(nil, 2))
+			case openRGBRequestControllerData:
+				reply(id, device, encodeOpenRGBController("Mouse", []rgbColor{{uint8(device), 0, 0}}))
+			}
+		}
+	}()
+
+	client, err := dialOpenRGB(context.Background(), listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dialOpenRGB should not fail: %v", err)
+	}
+	defer client.Close()
+	if count, err := client.controllerCount(); err != nil || count != 2 {
+		t.Fatalf("expected 2 controllers, got %d, %v", count, err)
+	}
+	controller, err := client.controller(1)
+	if err != nil || controller.Name != "Mouse" || controller.Colors[0].R != 1 {
+		t.Errorf("expected the second controller, got %+v, %v", controller, err)
+	}
+}
+
+func TestOpenRGBGeneratorSpreadsLEDs(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 3, X: 200, ShapeType: 7},
+		{ID: 1, X: 0, ShapeType: 7},
+		{ID: 2, X: 100, ShapeType: 7},
+	}}
+	generator := &openRGBGenerator{}
+	generator.set([]rgbColor{{200, 0, 0}, {100, 0, 0}, {0, 90, 0}, {0, 30, 0}, {0, 0, 60}, {0, 0, 20}})
+
+	frame := generator.NextFrame(layout, 0)
+	want := []PanelColor{{PanelID: 1, R: 150}, {PanelID: 2, G: 60}, {PanelID: 3, B: 40}}
+	if len(frame) != len(want) {
+		t.Fatalf("expected a color per panel, got %v", frame)
+	}
+	for i := range want {
+		if frame[i] != want[i] {
+			t.Errorf("expected the LEDs averaged left to right, got %v", frame)
+			break
+		}
+	}
+
+	generator.set([]rgbColor{{255, 255, 255}})
+	for _, c := range generator.NextFrame(layout, 0) {
+		if c.R != 255 {
+			t.Errorf("expected a single LED to light every panel, got %v", c)
+		}
+	}
+}
+
+func TestParseControllerList(t *testing.T) {
+	if all, err := parseControllerList("", 3); err != nil || len(all) != 3 || all[2] != 2 {
+		t.Errorf("expected every controller by default, got %v, %v", all, err)
+	}
+	if picked, err := parseControllerList("2, 0", 3); err != nil || picked[0] != 2 || picked[1] != 0 {
+		t.Errorf("expected the given order, got %v, %v", picked, err)
+	}
+	for _, invalid := range []string{"3", "-1", "mouse"} {
+		if _, err := parseControllerList(invalid, 3); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// operation is a device command started from the UI. It waits in the
+// queue for its turn and can be cancelled while it runs.
+type operation struct {
+	label  string
+	ctx    context.Context
+	cancel context.CancelFunc
+	turn   chan struct{}
+}
+
+// operationQueue runs the UI's device commands one at a time, in the order
+// they were started, so a burst of key presses does not race on the device.
+// The first operation in the queue is the one running.
+type operationQueue struct {
+	mu  sync.Mutex
+	ops []*operation
+}
+
+// enqueue adds an operation; it runs once every earlier one is done
+func (q *operationQueue) enqueue(label string) *operation {
+	ctx, cancel := context.WithCancel(context.Background())
+	op := &operation{label: label, ctx: ctx, cancel: cancel, turn: make(chan struct{})}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ops = append(q.ops, op)
+	if len(q.ops) == 1 {
+		close(op.turn)
+	}
+	return op
+}
+
+// wait blocks until it is the operation's turn, then returns its context
+// limited to timeout, or no deadline when timeout is 0
+func (op *operation) wait(timeout time.Duration) (context.Context, context.CancelFunc, error) {
+	select {
+	case <-op.turn:
+	case <-op.ctx.Done():
+		return nil, nil, op.ctx.Err()
+	}
+	if timeout == 0 {
+		return op.ctx, func() {}, nil
+	}
+	ctx, cancel := context.WithTimeout(op.ctx, timeout)
+	return ctx, cancel, nil
+}
+
+// done removes a finished operation and lets the next one run
+func (q *operationQueue) done(op *operation) {
+	op.cancel()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, queued := range q.ops {
+		if queued != op {
+			continue
+		}
+		q.ops = append(q.ops[:i], q.ops[i+1:]...)
+		if i == 0 && len(q.ops) > 0 {
+			close(q.ops[0].turn)
+		}
+		return
+	}
+}
+
+// cancelRunning cancels the operation in flight and returns its label
+func (q *operationQueue) cancelRunning() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.ops) == 0 {
+		return "", false
+	}
+	q.ops[0].cancel()
+	return q.ops[0].label, true
+}
+
+// snapshot returns the running operation and the labels of the operations
+// waiting behind it
+func (q *operationQueue) snapshot() (running string, queued []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.ops) == 0 {
+		return "", nil
+	}
+	for _, op := range q.ops[1:] {
+		queued = append(queued, op.label)
+	}
+	return q.ops[0].label, queued
+}
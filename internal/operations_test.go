@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOperationQueueRunsInOrder(t *testing.T) {
+	queue := &operationQueue{}
+	first := queue.enqueue("Turn on")
+	second := queue.enqueue("Brightness 40")
+
+	running, queued := queue.snapshot()
+	if running != "Turn on" || len(queued) != 1 || queued[0] != "Brightness 40" {
+		t.Fatalf("unexpected queue %q %v", running, queued)
+	}
+
+	started := make(chan struct{})
+	go func() {
+		if _, cancel, err := second.wait(time.Second); err == nil {
+			cancel()
+		}
+		close(started)
+	}()
+	select {
+	case <-started:
+		t.Fatal("the second operation should wait for the first")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ctx, cancel, err := first.wait(time.Second)
+	if err != nil {
+		t.Fatalf("the first operation should run straight away: %v", err)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("expected the timeout to apply")
+	}
+	cancel()
+	queue.done(first)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the second operation should run once the first is done")
+	}
+	if running, _ := queue.snapshot(); running != "Brightness 40" {
+		t.Errorf("expected the second operation to be running, got %q", running)
+	}
+	queue.done(second)
+	if running, queued := queue.snapshot(); running != "" || queued != nil {
+		t.Errorf("expected an empty queue, got %q %v", running, queued)
+	}
+}
+
+func TestOperationQueueCancel(t *testing.T) {
+	queue := &operationQueue{}
+	if _, ok := queue.cancelRunning(); ok {
+		t.Error("nothing should be cancelled on an empty queue")
+	}
+
+	op := queue.enqueue("Macro evening")
+	ctx, cancel, err := op.wait(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("a zero timeout should leave the context without a deadline")
+	}
+
+	label, ok := queue.cancelRunning()
+	if !ok || label != "Macro evening" {
+		t.Errorf("expected the macro to be cancelled, got %q", label)
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("expected the context to be cancelled, got %v", ctx.Err())
+	}
+	queue.done(op)
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// automationChangeGrace is how long after an automation changed a device
+// the events it causes are still put down to the automation rather than to
+// someone changing the panels by hand
+const automationChangeGrace = 5 * time.Second
+
+// manualWatchRetry spaces out attempts to reconnect to the event stream
+const manualWatchRetry = time.Minute
+
+// runningAutomation names the automation command running, so its changes
+// to devices do not count as manual ones and carry its priority
+var runningAutomation string
+
+// handSource is the change source of requests a person makes through an
+// automation command, such as the menu-bar endpoints of serve, so their
+// changes count as manual ones
+const handSource = "by hand"
+
+// manualOverride parses the manualOverride window of the config, zero when
+// it is not set
+func (c Config) manualOverride() (time.Duration, error) {
+	if c.ManualOverride == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.ManualOverride)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("manualOverride: invalid duration %q, e.g. 1h or 30m", c.ManualOverride)
+	}
+	return d, nil
+}
+
+// deviceChanges is when a device was last changed by hand and by an
+// automation, with the source and priority of that automation
+type deviceChanges struct {
+	Manual    time.Time `json:"manual,omitempty"`
+	Automated time.Time `json:"automated,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Priority  string    `json:"priority,omitempty"`
+}
+
+var changesMu sync.Mutex
+
+func getChangesPath() string {
+	return profilePath(".nanoleaf_changes", ".json")
+}
+
+// loadChanges reads the last changes by device IP. A missing or damaged file
+// only means no change is known.
+func loadChanges() map[string]deviceChanges {
+	changes := make(map[string]deviceChanges)
+	data, err := os.ReadFile(getChangesPath())
+	if err == nil {
+		json.Unmarshal(data, &changes)
+	}
+	return changes
+}
+
+// recordChange notes a change to the device at ip, made by hand unless
+// source names the automation that made it. Every automation command and
+// serve share the file, so one sees the changes of another; the config
+// lock keeps them from writing over each other's changes.
+func recordChange(ip, source string, p priority, at time.Time) error {
+	changesMu.Lock()
+	defer changesMu.Unlock()
+	return withConfigLock(true, func() error {
+		changes := loadChanges()
+		last := changes[ip]
+		if source != "" {
+			last.Automated, last.Source, last.Priority = at, source, p.String()
+		} else {
+			last.Manual = at
+		}
+		changes[ip] = last
+		data, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(getChangesPath(), data, 0600)
+	})
+}
+
+// noteChange records a change about to be made to the device, by hand or by
+// an automation depending on the running command. It is noted before the
+// request so the device's event about it never arrives first, and at most
+// once a second so REST streaming does not rewrite the file every frame.
+// Changes by hand are only needed with manualOverride set.
+func (d *Device) noteChange() {
+	if !d.record {
+		return
+	}
+	d.changeMu.Lock()
+	source, p := d.changeSource, d.changePriority
+	d.changeMu.Unlock()
+	switch source {
+	case handSource:
+		source = ""
+	case "":
+		source = runningAutomation
+		p, _ = d.GetConfig().automationPriority(source)
+	}
+	if source == "" {
+		if window, err := d.GetConfig().manualOverride(); err != nil || window == 0 {
+			return
+		}
+	}
+
+	now := time.Now()
+	d.changeMu.Lock()
+	if now.Sub(d.lastChange) < time.Second && source == d.lastSource {
+		d.changeMu.Unlock()
+		return
+	}
+	d.lastChange, d.lastSource = now, source
+	d.changeMu.Unlock()
+	recordChange(d.GetConfig().IP, source, p, now)
+}
+
+// setChangeSource makes the changes until the next call count as made by
+// source with priority p, e.g. a presence rule, rather than by the running
+// command; handSource makes them manual ones and an empty source undoes it
+func (d *Device) setChangeSource(source string, p priority) {
+	d.changeMu.Lock()
+	defer d.changeMu.Unlock()
+	d.changeSource, d.changePriority = source, p
+}
+
+// lastChanges returns the last changes to the device at ip
+func lastChanges(ip string) deviceChanges {
+	changesMu.Lock()
+	defer changesMu.Unlock()
+	return loadChanges()[ip]
+}
+
+// watchManualChanges records changes made to the device from elsewhere, such
+// as its buttons or the Nanoleaf app, as manual ones while ctx lasts. The
+// device reports every change, so the ones shortly after an automation
+// changed it are taken to be the automation's. Nothing is watched unless
+// manualOverride is set.
+func watchManualChanges(ctx context.Context, device *Device) {
+	if window, err := device.GetConfig().manualOverride(); err != nil || window == 0 {
+		return
+	}
+	ip := device.GetDeviceIP()
+	go func() {
+		for {
+			events, err := device.WatchEvents(ctx, eventState, eventEffects)
+			if err == nil {
+				for range events {
+					now := time.Now()
+					changesMu.Lock()
+					automated := now.Sub(loadChanges()[ip].Automated) < automationChangeGrace
+					changesMu.Unlock()
+					if !automated {
+						recordChange(ip, "", 0, now)
+					}
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(manualWatchRetry):
+			}
+		}
+	}()
+}
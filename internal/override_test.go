@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManualOverrideHoldsAutomations(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	config := Config{IP: "10.0.0.5", ManualOverride: "1h"}
+	gate, err := newQuietGate(config, true)
+	if err != nil || gate == nil {
+		t.Fatalf("newQuietGate should return a gate for the manual override: %v", err)
+	}
+	gate.now = func() time.Time { return now }
+	if gate.held() {
+		t.Error("nothing should be held before a manual change")
+	}
+
+	if err := recordChange("10.0.0.5", "", 0, now.Add(-30*time.Minute)); err != nil {
+		t.Fatalf("recordChange should not fail: %v", err)
+	}
+	if err := recordChange("10.0.0.6", "", 0, now); err != nil {
+		t.Fatal(err)
+	}
+	if !gate.held() {
+		t.Error("changes should be held within an hour of a manual change")
+	}
+	if err := gate.settle(context.Background(), NewDevice()); err != nil {
+		t.Errorf("settle should leave a manually changed device alone: %v", err)
+	}
+
+	now = now.Add(31 * time.Minute)
+	if gate.held() {
+		t.Error("changes should go through once the override ended")
+	}
+
+	if err := recordChange("10.0.0.5", "weather", prioritySchedule, now); err != nil {
+		t.Fatal(err)
+	}
+	if gate.held() {
+		t.Error("an automated change should not start an override")
+	}
+
+	if _, err := newQuietGate(Config{ManualOverride: "soon"}, false); err == nil {
+		t.Error("newQuietGate should reject an invalid manualOverride")
+	}
+	if gate, _ := newQuietGate(Config{}, false); gate.held() {
+		t.Error("expected nothing held without quiet hours or manual override")
+	}
+}
+
+func TestNoteChange(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"value":true}`))
+		case strings.Contains(string(body), "requestAll"):
+			w.Write([]byte(`{"animations":[]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.config.ManualOverride = "1h"
+	ctx := context.Background()
+
+	if err := device.TurnOn(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !lastChanges(server.URL).Manual.IsZero() {
+		t.Error("a device without a saved config should not record changes")
+	}
+
+	device.record = true
+	if _, err := device.client.getPower(ctx, server.URL, "test-token"); err != nil {
+		t.Fatal(err)
+	}
+	if !lastChanges(server.URL).Manual.IsZero() {
+		t.Error("reading the state should not count as a change")
+	}
+	if _, err := device.ListEffects(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !lastChanges(server.URL).Manual.IsZero() {
+		t.Error("listing the effects should not count as a change")
+	}
+	if err := device.TurnOn(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if lastChanges(server.URL).Manual.IsZero() {
+		t.Error("a command outside the automations should count as a manual change")
+	}
+
+	runningAutomation = "weather"
+	defer func() { runningAutomation = "" }()
+	device.lastChange = time.Time{}
+	if err := device.TurnOff(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if changes := lastChanges(server.URL); changes.Automated.IsZero() || changes.Source != "weather" || changes.Priority != "schedule" {
+		t.Errorf("a command of an automation should be recorded with its source and priority, got %+v", changes)
+	}
+}
+
+func TestWatchManualChanges(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	events := make(chan string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/events") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				fmt.Fprintf(w, "id: 1\ndata: %s\n\n", event)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.config.ManualOverride = "1h"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := recordChange(server.URL, "weather", prioritySchedule, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	watchManualChanges(ctx, device)
+	events <- `{"events":[{"attr":2,"value":40}]}`
+	time.Sleep(50 * time.Millisecond)
+	if !lastChanges(server.URL).Manual.IsZero() {
+		t.Error("an event right after an automated change should be put down to the automation")
+	}
+
+	if err := recordChange(server.URL, "weather", prioritySchedule, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	events <- `{"events":[{"attr":1,"value":false}]}`
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if !lastChanges(server.URL).Manual.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("an event without an automated change should be recorded as manual")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
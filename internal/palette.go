@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxPaletteSamples bounds how many pixels are considered when extracting colors
+const maxPaletteSamples = 20000
+
+func runPalette(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "from-image" {
+		return usageError("palette from-image [--colors N] [--anim flow|fade|random|static] [--name NAME] IMAGE")
+	}
+
+	fs := newFlagSet("palette from-image")
+	count := fs.Int("colors", 5, "number of colors to extract")
+	anim := fs.String("anim", "flow", "effect type: flow, fade, random, or static to paint the colors across the panels from left to right")
+	name := fs.String("name", "", "effect name (defaults to the image file name)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return invalidArgs(err)
+	}
+	if fs.NArg() != 1 {
+		return invalidArgs(fmt.Errorf("expected exactly one image file"))
+	}
+	if *count < 1 || *count > 16 {
+		return invalidArgs(fmt.Errorf("--colors must be between 1 and 16"))
+	}
+
+	path := fs.Arg(0)
+	palette, err := paletteFromImageFile(path, *count)
+	if err != nil {
+		return err
+	}
+
+	effectName := *name
+	if effectName == "" {
+		effectName = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	// Only a static effect places colors on particular panels
+	var layout Layout
+	if *anim == "static" {
+		if layout, err = device.GetLayout(ctx); err != nil {
+			return err
+		}
+	}
+	effect, err := paletteEffect(effectName, *anim, palette, layout)
+	if err != nil {
+		return err
+	}
+	if err := device.AddEffect(ctx, effect); err != nil {
+		return err
+	}
+	if err := device.SelectEffect(ctx, effectName); err != nil {
+		return err
+	}
+
+	statusf("Created %q from %d color(s): %s\n", effectName, len(palette), paletteSwatch(palette))
+	return nil
+}
+
+// paletteEffect builds an effect of the given animation type around a
+// palette. A static effect splits the light panels of layout, from left to
+// right, into a band per color, the most common color first.
+func paletteEffect(name, anim string, palette []paletteColor, layout Layout) (map[string]interface{}, error) {
+	effect := fadeEffect(palette, 30, 20)
+	switch anim {
+	case "static":
+		panels := layout.LightPanels()
+		if len(panels) == 0 {
+			return nil, fmt.Errorf("device layout has no light panels")
+		}
+		if len(palette) == 0 {
+			return nil, fmt.Errorf("the image has no colors")
+		}
+		sort.SliceStable(panels, func(i, j int) bool {
+			if panels[i].X != panels[j].X {
+				return panels[i].X < panels[j].X
+			}
+			return panels[i].Y < panels[j].Y
+		})
+		var animData strings.Builder
+		fmt.Fprintf(&animData, "%d", len(panels))
+		for i, p := range panels {
+			color := palette[i*len(palette)/len(panels)]
+			c := hsbToRGB(color.Hue, color.Saturation, color.Brightness)
+			fmt.Fprintf(&animData, " %d 1 %d %d %d 0 10", p.ID, c.R, c.G, c.B)
+		}
+		effect = map[string]interface{}{
+			"animType": "static",
+			"animData": animData.String(),
+			"loop":     false,
+			"palette":  []paletteColor{},
+		}
+	case "fade":
+	case "random":
+		effect["animType"] = "random"
+	case "flow":
+		effect["animType"] = "flow"
+		effect["flowFactor"] = 1.5
+		effect["direction"] = "right"
+	default:
+		return nil, fmt.Errorf("unknown animation type %q", anim)
+	}
+	effect["animName"] = name
+	return effect, nil
+}
+
+func paletteFromImageFile(path string, count int) ([]paletteColor, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return dominantColors(img, count), nil
+}
+
+// dominantColors extracts up to count colors, most common first, by
+// repeatedly splitting the pixel group with the widest color range at the
+// middle of that range
+func dominantColors(img image.Image, count int) []paletteColor {
+	bounds := img.Bounds()
+	step := 1
+	for (bounds.Dx()/step)*(bounds.Dy()/step) > maxPaletteSamples {
+		step++
+	}
+
+	var pixels []rgbColor
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			pixels = append(pixels, rgbColor{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)})
+		}
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+
+	buckets := [][]rgbColor{pixels}
+	for len(buckets) < count {
+		index, channel := widestBucket(buckets)
+		if index < 0 {
+			break
+		}
+		bucket := buckets[index]
+		sort.Slice(bucket, func(i, j int) bool {
+			return channelValue(bucket[i], channel) < channelValue(bucket[j], channel)
+		})
+		low := channelValue(bucket[0], channel)
+		high := channelValue(bucket[len(bucket)-1], channel)
+		middle := sort.Search(len(bucket), func(i int) bool {
+			return int(channelValue(bucket[i], channel)) > (int(low)+int(high))/2
+		})
+		buckets[index] = bucket[:middle]
+		buckets = append(buckets, bucket[middle:])
+	}
+
+	sort.SliceStable(buckets, func(i, j int) bool { return len(buckets[i]) > len(buckets[j]) })
+
+	palette := make([]paletteColor, 0, len(buckets))
+	for _, bucket := range buckets {
+		hue, sat, bri := averageColor(bucket).hsb()
+		palette = append(palette, paletteColor{Hue: hue, Saturation: sat, Brightness: bri})
+	}
+	return palette
+}
+
+// widestBucket finds the splittable bucket with the largest range in any channel
+func widestBucket(buckets [][]rgbColor) (index, channel int) {
+	index, widest := -1, 0
+	for i, bucket := range buckets {
+		if len(bucket) < 2 {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			min, max := uint8(255), uint8(0)
+			for _, p := range bucket {
+				v := channelValue(p, c)
+				if v < min {
+					min = v
+				}
+				if v > max {
+					max = v
+				}
+			}
+			if spread := int(max) - int(min); spread > widest {
+				index, channel, widest = i, c, spread
+			}
+		}
+	}
+	return index, channel
+}
+
+func channelValue(c rgbColor, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+func averageColor(pixels []rgbColor) rgbColor {
+	var r, g, b int
+	for _, p := range pixels {
+		r += int(p.R)
+		g += int(p.G)
+		b += int(p.B)
+	}
+	n := len(pixels)
+	return rgbColor{uint8(r / n), uint8(g / n), uint8(b / n)}
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDominantColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			switch {
+			case x < 70:
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			default:
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+
+	palette := dominantColors(img, 2)
+	if len(palette) != 2 {
+		t.Fatalf("expected 2 colors, got %d", len(palette))
+	}
+	if palette[0].Hue != 0 || palette[0].Saturation != 100 {
+		t.Errorf("expected red first as the most common color, got %+v", palette[0])
+	}
+	if palette[1].Hue != 240 {
+		t.Errorf("expected blue second, got %+v", palette[1])
+	}
+}
+
+func TestDominantColorsSingleColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.RGBA{0, 255, 0, 255})
+		}
+	}
+
+	if palette := dominantColors(img, 5); len(palette) != 1 {
+		t.Errorf("expected a single color for a uniform image, got %v", palette)
+	}
+}
+
+func TestPaletteFromImageFile(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.RGBA{255, 136, 0, 255})
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "orange.png")
+	file, _ := os.Create(path)
+	png.Encode(file, img)
+	file.Close()
+
+	palette, err := paletteFromImageFile(path, 3)
+	if err != nil {
+		t.Fatalf("paletteFromImageFile should not fail: %v", err)
+	}
+	if len(palette) != 1 || palette[0].Hue != 32 {
+		t.Errorf("unexpected palette %v", palette)
+	}
+}
+
+func TestPaletteEffect(t *testing.T) {
+	palette := []paletteColor{{Hue: 10, Saturation: 100, Brightness: 100}}
+
+	effect, err := paletteEffect("Photo", "flow", palette, Layout{})
+	if err != nil {
+		t.Fatalf("paletteEffect should not fail: %v", err)
+	}
+	if effect["animName"] != "Photo" || effect["animType"] != "flow" {
+		t.Errorf("unexpected effect %v", effect)
+	}
+
+	if _, err := paletteEffect("Photo", "spin", palette, Layout{}); err == nil {
+		t.Error("paletteEffect should fail with an unknown animation type")
+	}
+}
+
+func TestPaletteEffectStatic(t *testing.T) {
+	palette := []paletteColor{{Hue: 0, Saturation: 100, Brightness: 100}, {Hue: 240, Saturation: 100, Brightness: 100}}
+	layout := Layout{Panels: []Panel{
+		{ID: 3, X: 200, Y: 0, ShapeType: 7},
+		{ID: 1, X: 0, Y: 0, ShapeType: 7},
+		{ID: 9, X: 50, Y: 0, ShapeType: 12},
+		{ID: 4, X: 300, Y: 0, ShapeType: 7},
+		{ID: 2, X: 100, Y: 0, ShapeType: 7},
+	}}
+
+	effect, err := paletteEffect("Photo", "static", palette, layout)
+	if err != nil {
+		t.Fatalf("paletteEffect should not fail: %v", err)
+	}
+	want := "4 1 1 255 0 0 0 10 2 1 255 0 0 0 10 3 1 0 0 255 0 10 4 1 0 0 255 0 10"
+	if effect["animType"] != "static" || effect["animData"] != want || effect["animName"] != "Photo" {
+		t.Errorf("expected the panels split into a red and a blue band, got %v", effect)
+	}
+	if _, err := paletteEffect("Photo", "static", palette, Layout{}); err == nil {
+		t.Error("paletteEffect should fail for a layout without light panels")
+	}
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// deviceState is the full state of the device as reported by GET state
+type deviceState struct {
+	On         bool
+	Brightness int
+	ColorMode  string
+	Hue, Sat   int
+	ColorTemp  int
+}
+
+func (c *NanoleafClient) getState(ctx context.Context, ip, token string) (deviceState, error) {
+	ctx = withAuthToken(ctx, token)
+	type value struct {
+		Value int `json:"value"`
+	}
+	var result struct {
+		On struct {
+			Value bool `json:"value"`
+		} `json:"on"`
+		Brightness value  `json:"brightness"`
+		Hue        value  `json:"hue"`
+		Sat        value  `json:"sat"`
+		CT         value  `json:"ct"`
+		ColorMode  string `json:"colorMode"`
+	}
+	err := c.getJSON(ctx, c.buildURL(ip, "api/v1/state"), &result)
+	return deviceState{
+		On:         result.On.Value,
+		Brightness: result.Brightness.Value,
+		ColorMode:  result.ColorMode,
+		Hue:        result.Hue.Value,
+		Sat:        result.Sat.Value,
+		ColorTemp:  result.CT.Value,
+	}, err
+}
+
+// solidLook lights every panel in one color
+type solidLook struct{ color rgbColor }
+
+func (l solidLook) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return eachPanel(layout, func(Panel, float64, float64) rgbColor { return l.color })
+}
+
+// dimmedLook scales another look by the device brightness
+type dimmedLook struct {
+	EffectGenerator
+	brightness float64
+}
+
+func (l dimmedLook) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	frame := l.EffectGenerator.NextFrame(layout, t)
+	for i, c := range frame {
+		scaled := scaleColor(rgbColor{c.R, c.G, c.B}, l.brightness)
+		frame[i].R, frame[i].G, frame[i].B = scaled.R, scaled.G, scaled.B
+	}
+	return frame
+}
+
+// CurrentLook approximates what the panels show: the solid color or white
+// temperature they are set to, or a preview of the selected effect
+func (d *Device) CurrentLook(ctx context.Context) (EffectGenerator, error) {
+	state, err := d.client.getState(ctx, d.GetConfig().IP, d.GetConfig().Token)
+	if err != nil {
+		return nil, err
+	}
+	if !state.On {
+		return solidLook{}, nil
+	}
+
+	var look EffectGenerator
+	switch state.ColorMode {
+	case "hs":
+		look = solidLook{hsbToRGB(state.Hue, state.Sat, 100)}
+	case "ct":
+		look = solidLook{kelvinToRGB(state.ColorTemp)}
+	default:
+		look, err = d.selectedEffectLook(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dimmedLook{EffectGenerator: look, brightness: float64(state.Brightness) / 100}, nil
+}
+
+// selectedEffectLook previews the selected effect, or shows white when it
+// cannot be previewed
+func (d *Device) selectedEffectLook(ctx context.Context) (EffectGenerator, error) {
+	name, err := d.client.getSelectedEffect(ctx, d.GetConfig().IP, d.GetConfig().Token)
+	if err != nil {
+		return nil, err
+	}
+	effects, err := d.client.requestAllEffects(ctx, d.GetConfig().IP, d.GetConfig().Token)
+	if err != nil {
+		return nil, err
+	}
+	for _, effect := range effects {
+		if effect["animName"] != name {
+			continue
+		}
+		if preview, err := newEffectPreview(effect); err == nil {
+			return preview, nil
+		}
+	}
+	return solidLook{rgbColor{255, 255, 255}}, nil
+}
+
+// kelvinToRGB approximates the color of a white temperature
+func kelvinToRGB(kelvin int) rgbColor {
+	temp := float64(kelvin) / 100
+	clamp := func(v float64) uint8 { return uint8(math.Max(0, math.Min(255, v))) }
+
+	red, green, blue := 255.0, 0.0, 255.0
+	if temp <= 66 {
+		green = 99.4708025861*math.Log(temp) - 161.1195681661
+		if temp <= 19 {
+			blue = 0
+		} else {
+			blue = 138.5177312231*math.Log(temp-10) - 305.0447927307
+		}
+	} else {
+		red = 329.698727446 * math.Pow(temp-60, -0.1332047592)
+		green = 288.1221695283 * math.Pow(temp-60, -0.0755148492)
+	}
+	return rgbColor{clamp(red), clamp(green), clamp(blue)}
+}
+
+// streamLook mirrors the frames of a stream this app is sending
+type streamLook struct{ scheduler *frameScheduler }
+
+func (l streamLook) NextFrame(Layout, time.Duration) []PanelColor {
+	return l.scheduler.LastFrame()
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCurrentLook(t *testing.T) {
+	state := `{"on":{"value":true},"brightness":{"value":50},"hue":{"value":240},"sat":{"value":100},"ct":{"value":2700},"colorMode":"hs"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/test-token/state":
+			w.Write([]byte(state))
+		case "/api/v1/test-token/effects/select":
+			w.Write([]byte(`"Ocean"`))
+		case "/api/v1/test-token/effects":
+			w.Write([]byte(`{"animations":[{"animName":"Ocean","animType":"fade",
+				"palette":[{"hue":180,"saturation":100,"brightness":100}]}]}`))
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	layout := Layout{Panels: []Panel{{ID: 7}}}
+	colorOf := func() rgbColor {
+		look, err := device.CurrentLook(context.Background())
+		if err != nil {
+			t.Fatalf("CurrentLook should not fail: %v", err)
+		}
+		frame := look.NextFrame(layout, time.Second)
+		return rgbColor{frame[0].R, frame[0].G, frame[0].B}
+	}
+
+	if got := colorOf(); got != (rgbColor{0, 0, 128}) {
+		t.Errorf("expected blue at half brightness, got %v", got)
+	}
+
+	state = `{"on":{"value":true},"brightness":{"value":100},"colorMode":"effect"}`
+	if got := colorOf(); got != (rgbColor{0, 255, 255}) {
+		t.Errorf("expected the cyan of the selected effect, got %v", got)
+	}
+
+	state = `{"on":{"value":false},"brightness":{"value":100},"colorMode":"hs"}`
+	if got := colorOf(); got != (rgbColor{}) {
+		t.Errorf("expected dark panels when off, got %v", got)
+	}
+}
+
+func TestKelvinToRGB(t *testing.T) {
+	warm, cool := kelvinToRGB(2700), kelvinToRGB(6500)
+	if warm.R != 255 || warm.B >= warm.G {
+		t.Errorf("expected 2700K to be orange-ish, got %v", warm)
+	}
+	if cool.B < 240 || cool.R < 240 {
+		t.Errorf("expected 6500K to be near white, got %v", cool)
+	}
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+func getPausePath() string {
+	return profilePath(".nanoleaf_paused", "")
+}
+
+// pausedSince reports whether the automations are paused and since when.
+// The pause is a file rather than process state, so daemon pause reaches
+// every automation command and serve, and a pause outlives restarts.
+func pausedSince() (time.Time, bool) {
+	data, err := os.ReadFile(getPausePath())
+	if err != nil {
+		return time.Time{}, false
+	}
+	since, _ := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	return since, true
+}
+
+// automationsPaused reports whether the automations hold their changes
+// until they are resumed
+func automationsPaused() bool {
+	_, paused := pausedSince()
+	return paused
+}
+
+// pauseAutomations pauses every automation until resumeAutomations. It
+// keeps the time of an earlier pause.
+func pauseAutomations(now time.Time) error {
+	if automationsPaused() {
+		return nil
+	}
+	return writeFileAtomic(getPausePath(), []byte(now.Format(time.RFC3339)+"\n"), 0600)
+}
+
+func resumeAutomations() error {
+	if err := os.Remove(getPausePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// watchPauseSignals pauses the automations on pauseSignal and resumes them
+// on resumeSignal, until ctx is done. Where there are no such signals, e.g.
+// on Windows, only daemon pause and resume work.
+func watchPauseSignals(ctx context.Context) {
+	if pauseSignal == nil {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, pauseSignal, resumeSignal)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				var err error
+				if sig == pauseSignal {
+					if err = pauseAutomations(time.Now()); err == nil {
+						statusf("Automations paused, send %s or run daemon resume to resume them\n", resumeSignalName)
+					}
+				} else if err = resumeAutomations(); err == nil {
+					statusf("Automations resumed\n")
+				}
+				if err != nil {
+					errorf("Could not change the pause: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+func runDaemon(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("daemon pause|resume|status")
+	}
+	switch args[0] {
+	case "pause":
+		if err := pauseAutomations(time.Now()); err != nil {
+			return err
+		}
+		statusf("Automations paused, they hold their changes until daemon resume\n")
+	case "resume":
+		if err := resumeAutomations(); err != nil {
+			return err
+		}
+		statusf("Automations resumed\n")
+	case "status":
+		if since, paused := pausedSince(); paused {
+			fmt.Printf("paused since %s\n", since.Format("2006-01-02 15:04"))
+		} else {
+			fmt.Println("running")
+		}
+	default:
+		return usageError("daemon pause|resume|status")
+	}
+	return nil
+}
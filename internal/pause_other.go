@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package internal
+
+import "os"
+
+// pauseSignal and resumeSignal are not available here, see watchPauseSignals
+var pauseSignal, resumeSignal os.Signal
+
+const resumeSignalName = ""
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPauseAutomations(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var gate *quietGate
+	if gate.held() {
+		t.Fatal("a nil gate should not hold changes before a pause")
+	}
+	since := time.Date(2024, 3, 1, 18, 30, 0, 0, time.UTC)
+	if err := pauseAutomations(since); err != nil {
+		t.Fatalf("pauseAutomations should not fail: %v", err)
+	}
+	if err := pauseAutomations(since.Add(time.Hour)); err != nil {
+		t.Fatalf("pausing again should not fail: %v", err)
+	}
+	if got, paused := pausedSince(); !paused || !got.Equal(since) {
+		t.Errorf("expected a pause since %v, got %v (paused %v)", since, got, paused)
+	}
+	if !gate.held() {
+		t.Error("a nil gate should hold changes while paused")
+	}
+	if err := gate.settle(context.Background(), NewDevice()); err != nil {
+		t.Errorf("settle should do nothing while paused: %v", err)
+	}
+
+	if err := resumeAutomations(); err != nil {
+		t.Fatalf("resumeAutomations should not fail: %v", err)
+	}
+	if err := resumeAutomations(); err != nil {
+		t.Fatalf("resuming again should not fail: %v", err)
+	}
+	if automationsPaused() || gate.held() {
+		t.Error("changes should go through again after a resume")
+	}
+}
+
+func TestPresenceRulesPaused(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	presence, err := newPresenceTracker(NewDevice(), nil, []PresenceRule{
+		{Event: "home", Actions: []string{"on"}, IgnoreQuietHours: true},
+	})
+	if err != nil {
+		t.Fatalf("newPresenceTracker should not fail: %v", err)
+	}
+	if err := pauseAutomations(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	ran, err := presence.update(context.Background(), "alex", "enter")
+	if err != nil || ran != 0 {
+		t.Errorf("expected no rules to run while paused, got %d, %v", ran, err)
+	}
+	if people := presence.whoIsHome(); len(people) != 1 {
+		t.Errorf("presence should still be tracked while paused, got %v", people)
+	}
+}
+
+func TestPauseSignals(t *testing.T) {
+	if pauseSignal == nil {
+		t.Skip("no pause signals on this platform")
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchPauseSignals(ctx)
+
+	waitFor := func(paused bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for automationsPaused() != paused {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected paused to become %v", paused)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	process, _ := os.FindProcess(os.Getpid())
+	process.Signal(pauseSignal)
+	waitFor(true)
+	process.Signal(resumeSignal)
+	waitFor(false)
+}
@@ -0,0 +1,49 @@
+package internal
+
+import "time"
+
+// flashThreshold is the change in a panel's brightness, out of 255, that
+// counts as a flash rather than a fade
+const flashThreshold = 51
+
+// flashLimiter keeps every panel of a stream to a flash per
+// minFlashInterval when photosensitive is set. A panel that would flash
+// sooner holds its color until the interval is up; fades change too little
+// per frame to count and pass unchanged.
+type flashLimiter struct {
+	shown   map[int]rgbColor
+	flashed map[int]time.Duration
+}
+
+func newFlashLimiter() *flashLimiter {
+	return &flashLimiter{shown: make(map[int]rgbColor), flashed: make(map[int]time.Duration)}
+}
+
+// luma is the perceived brightness of c, from 0 to 255
+func luma(c rgbColor) int {
+	return (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+}
+
+// limit holds in place the panels of frame, shown at t, that would flash
+// too soon after their last flash
+func (l *flashLimiter) limit(frame []PanelColor, t time.Duration) {
+	for i, pc := range frame {
+		c := rgbColor{pc.R, pc.G, pc.B}
+		shown, ok := l.shown[pc.PanelID]
+		if ok {
+			change := luma(c) - luma(shown)
+			if change < 0 {
+				change = -change
+			}
+			if change >= flashThreshold {
+				last, flashedBefore := l.flashed[pc.PanelID]
+				if flashedBefore && t-last < minFlashInterval {
+					frame[i].R, frame[i].G, frame[i].B = shown.R, shown.G, shown.B
+					continue
+				}
+				l.flashed[pc.PanelID] = t
+			}
+		}
+		l.shown[pc.PanelID] = c
+	}
+}
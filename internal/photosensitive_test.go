@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlashLimiterHoldsRapidFlashes(t *testing.T) {
+	limiter := newFlashLimiter()
+	flashes := 0
+	var shown rgbColor
+	// A strobe alternating black and white at 20 fps for a second
+	for i := 0; i < 20; i++ {
+		value := uint8(0)
+		if i%2 == 1 {
+			value = 255
+		}
+		frame := []PanelColor{{PanelID: 1, R: value, G: value, B: value}}
+		limiter.limit(frame, time.Duration(i)*50*time.Millisecond)
+		got := rgbColor{frame[0].R, frame[0].G, frame[0].B}
+		if i > 0 && got != shown {
+			flashes++
+		}
+		shown = got
+	}
+	if flashes > 3 {
+		t.Errorf("expected at most 3 flashes a second, got %d", flashes)
+	}
+	if flashes == 0 {
+		t.Error("expected the strobe to flash at the allowed rate")
+	}
+}
+
+func TestFlashLimiterPassesFades(t *testing.T) {
+	limiter := newFlashLimiter()
+	for i := 0; i < 20; i++ {
+		value := uint8(i * 12)
+		frame := []PanelColor{{PanelID: 1, R: value, G: value, B: value}}
+		limiter.limit(frame, time.Duration(i)*50*time.Millisecond)
+		if frame[0].R != value {
+			t.Fatalf("frame %d: expected the fade to pass at %d, got %d", i, value, frame[0].R)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// plainPrompt reads numbered menu choices and answers line by line, without
+// any styling, cursor movement or box drawing
+type plainPrompt struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// choose lists options and returns the picked index. It returns false when
+// the input ends or the user enters q.
+func (p *plainPrompt) choose(title string, options []string) (int, bool) {
+	fmt.Fprintf(p.out, "\n%s\n", title)
+	for i, option := range options {
+		fmt.Fprintf(p.out, "%d. %s\n", i+1, option)
+	}
+	for {
+		answer, ok := p.ask(fmt.Sprintf("Choose 1 to %d, or q to go back", len(options)))
+		if !ok || strings.EqualFold(answer, "q") {
+			return 0, false
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return n - 1, true
+		}
+		fmt.Fprintf(p.out, "Please enter a number from 1 to %d.\n", len(options))
+	}
+}
+
+// ask prints prompt and returns the trimmed answer, or false at end of input
+func (p *plainPrompt) ask(prompt string) (string, bool) {
+	fmt.Fprintf(p.out, "%s: ", prompt)
+	if !p.in.Scan() {
+		fmt.Fprintln(p.out)
+		return "", false
+	}
+	return strings.TrimSpace(p.in.Text()), true
+}
+
+func (p *plainPrompt) report(done string, err error) {
+	if err != nil {
+		fmt.Fprintf(p.out, "Error: %v\n", err)
+		return
+	}
+	fmt.Fprintln(p.out, done)
+}
+
+// RunPlain is a line based alternative to the interactive UI for screen
+// readers and dumb terminals: numbered menus printed to out, answers read
+// from in
+func RunPlain(device *Device, in io.Reader, out io.Writer) error {
+	p := &plainPrompt{in: bufio.NewScanner(in), out: out}
+
+	// Without a config the setup menu is shown
+	device.LoadConfig()
+	ctx, cancel := device.createContext()
+	ready := device.IsDeviceReady(ctx)
+	cancel()
+	if ready {
+		fmt.Fprintf(out, "Connected to %s.\n", device.GetDeviceName())
+	}
+
+	for {
+		var ok bool
+		if ready {
+			ready, ok = p.control(device)
+		} else {
+			ready, ok = p.setup(device)
+		}
+		if !ok {
+			return nil
+		}
+	}
+}
+
+// setup offers scanning and pairing; it reports whether a device is ready
+// and false when the user quits
+func (p *plainPrompt) setup(device *Device) (bool, bool) {
+	choice, ok := p.choose("Not connected.", []string{"Scan for devices", "Pair device", "Quit"})
+	if !ok || choice == 2 {
+		return false, false
+	}
+
+	// Each action gets its own timeout, started only once the user is done
+	// at the prompt
+	switch choice {
+	case 0:
+		fmt.Fprintln(p.out, "Scanning...")
+		ctx, cancel := device.createContext()
+		defer cancel()
+		devices, err := device.ScanForDevices(ctx, nil)
+		switch {
+		case err != nil:
+			p.report("", err)
+		case len(devices) == 0:
+			fmt.Fprintln(p.out, "No devices found.")
+		case len(devices) == 1:
+			device.SetDevice(devices[0])
+			fmt.Fprintf(p.out, "Found %s.\n", devices[0])
+		default:
+			if i, ok := p.choose(fmt.Sprintf("Found %d devices.", len(devices)), devices); ok {
+				device.SetDevice(devices[i])
+			}
+		}
+	case 1:
+		if device.GetDeviceIP() == "" {
+			fmt.Fprintln(p.out, "Scan for devices first.")
+			return false, true
+		}
+		if _, ok := p.ask(fmt.Sprintf("Hold the power button of %s for about 5 seconds, then press enter", device.GetDeviceIP())); !ok {
+			return false, false
+		}
+		ctx, cancel := device.createContext()
+		defer cancel()
+		err := device.PairDevice(ctx)
+		var unsaved *UnsavedTokenError
+		if errors.As(err, &unsaved) {
+			fmt.Fprintf(p.out, "Paired, but the token could not be saved: %v\nToken: %s\n", unsaved.Err, unsaved.Token)
+			return true, true
+		}
+		p.report("Paired.", err)
+		return err == nil, true
+	}
+	return false, true
+}
+
+// control runs one command on the paired device; it reports whether the
+// device is still usable and false when the user quits
+func (p *plainPrompt) control(device *Device) (bool, bool) {
+	colorOption := "Color"
+	caps, capsErr := func() (Capabilities, error) {
+		ctx, cancel := device.createContext()
+		defer cancel()
+		return device.GetCapabilities(ctx)
+	}()
+	if capsErr == nil && !caps.Color {
+		colorOption = "Color temperature"
+	}
+	options := []string{"Status", "Turn on", "Turn off", "Brightness", colorOption, "Effect"}
+	presets := device.GetConfig().Presets
+	if len(presetKeys(presets)) > 0 {
+		options = append(options, "Preset")
+	}
+	options = append(options, "Quit")
+
+	choice, ok := p.choose(fmt.Sprintf("Connected to %s.", device.GetDeviceName()), options)
+	if !ok || options[choice] == "Quit" {
+		return true, false
+	}
+
+	var err error
+	switch options[choice] {
+	case "Status":
+		err = p.status(device)
+	case "Turn on":
+		err = p.runAction(device, "on", "Turned on.")
+	case "Turn off":
+		err = p.runAction(device, "off", "Turned off.")
+	case "Brightness":
+		if value, ok := p.ask("Brightness from 0 to 100"); ok {
+			err = p.runAction(device, "brightness "+value, "Brightness set to "+value+".")
+		}
+	case "Color":
+		if value, ok := p.ask("Color name or #rrggbb"); ok {
+			err = p.runAction(device, "color "+value, "Color set to "+value+".")
+		}
+	case "Color temperature":
+		if value, ok := p.ask(fmt.Sprintf("Color temperature from %d to %d Kelvin", caps.ColorTempMin, caps.ColorTempMax)); ok {
+			err = p.runAction(device, "temperature "+value, "Color temperature set to "+value+".")
+		}
+	case "Effect":
+		err = p.effect(device)
+	case "Preset":
+		keys := presetKeys(presets)
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			names[i] = presets[key].String()
+		}
+		if i, ok := p.choose("Presets", names); ok {
+			err = p.runAction(device, "preset "+keys[i], "Applied "+names[i]+".")
+		}
+	}
+
+	if errors.Is(err, ErrUnauthorized) {
+		device.ClearToken()
+		fmt.Fprintln(p.out, "The device no longer accepts the saved token, pair it again.")
+		return false, true
+	}
+	return true, true
+}
+
+func (p *plainPrompt) runAction(device *Device, s, done string) error {
+	a, err := parseAction(s)
+	if err == nil {
+		ctx, cancel := device.createContext()
+		defer cancel()
+		err = a.run(ctx, device)
+	}
+	p.report(done, err)
+	return err
+}
+
+func (p *plainPrompt) status(device *Device) error {
+	ctx, cancel := device.createContext()
+	defer cancel()
+	status, err := device.GetStatus(ctx)
+	if err != nil {
+		p.report("", err)
+		return err
+	}
+	power := "off"
+	if status.On {
+		power = "on"
+	}
+	fmt.Fprintf(p.out, "Power %s, brightness %d percent", power, status.Brightness)
+	if status.Effect != "" {
+		fmt.Fprintf(p.out, ", effect %s", status.Effect)
+	}
+	fmt.Fprintln(p.out, ".")
+	return nil
+}
+
+func (p *plainPrompt) effect(device *Device) error {
+	ctx, cancel := device.createContext()
+	effects, err := device.ListEffects(ctx)
+	cancel()
+	if err != nil {
+		p.report("", err)
+		return err
+	}
+
+	var names []string
+	for _, effect := range effects {
+		if name, _ := effect["animName"].(string); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(p.out, "No effects installed.")
+		return nil
+	}
+	sort.Strings(names)
+	i, ok := p.choose("Effects", names)
+	if !ok {
+		return nil
+	}
+	return p.runAction(device, "effect "+names[i], "Effect set to "+names[i]+".")
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPlainPromptChoose(t *testing.T) {
+	var out bytes.Buffer
+	p := &plainPrompt{in: bufio.NewScanner(strings.NewReader("7\nabc\n2\n")), out: &out}
+
+	choice, ok := p.choose("Pick one", []string{"First", "Second"})
+	if !ok || choice != 1 {
+		t.Errorf("expected the second option, got %d %v", choice, ok)
+	}
+	if strings.Count(out.String(), "Please enter a number from 1 to 2.") != 2 {
+		t.Errorf("invalid answers should be reported, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "1. First\n2. Second\n") {
+		t.Errorf("options should be numbered, got %q", out.String())
+	}
+
+	if _, ok := p.choose("Pick one", []string{"First"}); ok {
+		t.Error("choose should fail at the end of input")
+	}
+	p.in = bufio.NewScanner(strings.NewReader("q\n"))
+	if _, ok := p.choose("Pick one", []string{"First"}); ok {
+		t.Error("choose should fail when going back")
+	}
+}
+
+func TestRunPlain(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	var brightness string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut:
+			body := new(bytes.Buffer)
+			body.ReadFrom(r.Body)
+			brightness = body.String()
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/v1/test-token/state/on":
+			w.Write([]byte(`{"value":true}`))
+		default:
+			w.Write([]byte(`{"model":"NL42"}`))
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	var out bytes.Buffer
+	// Brightness, then 40, then quit
+	if err := RunPlain(device, strings.NewReader("4\n40\n7\n"), &out); err != nil {
+		t.Fatalf("RunPlain should not fail: %v", err)
+	}
+	if !strings.Contains(brightness, `"value":40`) {
+		t.Errorf("expected a brightness update, got %q", brightness)
+	}
+	if !strings.Contains(out.String(), "Brightness set to 40.") {
+		t.Errorf("expected a confirmation, got %q", out.String())
+	}
+	if strings.ContainsAny(out.String(), "\x1b│─") {
+		t.Errorf("output should be plain text, got %q", out.String())
+	}
+}
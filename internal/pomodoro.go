@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type pomodoroPhase int
+
+const (
+	pomodoroFocus pomodoroPhase = iota
+	pomodoroBreak
+	pomodoroLongBreak
+)
+
+const pomodoroRounds = 4
+
+var pomodoroDurations = map[pomodoroPhase]time.Duration{
+	pomodoroFocus:     25 * time.Minute,
+	pomodoroBreak:     5 * time.Minute,
+	pomodoroLongBreak: 15 * time.Minute,
+}
+
+var calmEffect = fadeEffect([]paletteColor{
+	{Hue: 200, Saturation: 60, Brightness: 60},
+	{Hue: 240, Saturation: 50, Brightness: 50},
+	{Hue: 180, Saturation: 40, Brightness: 60},
+}, 80, 40)
+
+// pomodoro tracks the current phase of a focus/break cycle. Every
+// pomodoroRounds focus sessions are followed by a long break.
+type pomodoro struct {
+	active  bool
+	session time.Time
+	phase   pomodoroPhase
+	round   int
+	endsAt  time.Time
+}
+
+func newPomodoro(now time.Time) pomodoro {
+	return pomodoro{
+		active:  true,
+		session: now,
+		phase:   pomodoroFocus,
+		round:   1,
+		endsAt:  now.Add(pomodoroDurations[pomodoroFocus]),
+	}
+}
+
+func (p pomodoro) next(now time.Time) pomodoro {
+	switch p.phase {
+	case pomodoroFocus:
+		if p.round%pomodoroRounds == 0 {
+			p.phase = pomodoroLongBreak
+		} else {
+			p.phase = pomodoroBreak
+		}
+	default:
+		p.phase = pomodoroFocus
+		p.round = p.round%pomodoroRounds + 1
+	}
+	p.endsAt = now.Add(pomodoroDurations[p.phase])
+	return p
+}
+
+func (p pomodoro) remaining(now time.Time) time.Duration {
+	if now.After(p.endsAt) {
+		return 0
+	}
+	return p.endsAt.Sub(now).Round(time.Second)
+}
+
+func (p pomodoro) String() string {
+	switch p.phase {
+	case pomodoroBreak:
+		return "Break"
+	case pomodoroLongBreak:
+		return "Long break"
+	default:
+		return fmt.Sprintf("Focus %d/%d", p.round, pomodoroRounds)
+	}
+}
+
+// apply transitions the panels to the look of the current phase
+func (p pomodoro) apply(ctx context.Context, device *Device) error {
+	switch p.phase {
+	case pomodoroBreak:
+		if err := device.SetColor(ctx, 120, 100); err != nil {
+			return err
+		}
+		return device.FadeBrightness(ctx, 100, 5)
+	case pomodoroLongBreak:
+		if err := device.SetColor(ctx, 160, 70); err != nil {
+			return err
+		}
+		return device.FadeBrightness(ctx, 100, 5)
+	default:
+		if err := device.DisplayEffect(ctx, calmEffect); err != nil {
+			return err
+		}
+		return device.FadeBrightness(ctx, 30, 10)
+	}
+}
+
+func formatTimer(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
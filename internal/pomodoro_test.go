@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPomodoroCycle(t *testing.T) {
+	now := time.Now()
+	p := newPomodoro(now)
+
+	expected := []struct {
+		phase pomodoroPhase
+		round int
+	}{
+		{pomodoroBreak, 1},
+		{pomodoroFocus, 2},
+		{pomodoroBreak, 2},
+		{pomodoroFocus, 3},
+		{pomodoroBreak, 3},
+		{pomodoroFocus, 4},
+		{pomodoroLongBreak, 4},
+		{pomodoroFocus, 1},
+	}
+
+	for i, step := range expected {
+		p = p.next(now)
+		if p.phase != step.phase || p.round != step.round {
+			t.Fatalf("step %d: expected phase %d round %d, got phase %d round %d", i, step.phase, step.round, p.phase, p.round)
+		}
+		if !p.endsAt.Equal(now.Add(pomodoroDurations[step.phase])) {
+			t.Errorf("step %d: unexpected end time %v", i, p.endsAt)
+		}
+	}
+}
+
+func TestPomodoroRemaining(t *testing.T) {
+	now := time.Now()
+	p := newPomodoro(now)
+
+	if p.remaining(now) != 25*time.Minute {
+		t.Errorf("expected 25m remaining, got %v", p.remaining(now))
+	}
+	if p.remaining(now.Add(time.Hour)) != 0 {
+		t.Errorf("expected no time remaining after the phase ended")
+	}
+}
+
+func TestFormatTimer(t *testing.T) {
+	if got := formatTimer(24*time.Minute + 5*time.Second); got != "24:05" {
+		t.Errorf("expected 24:05, got %s", got)
+	}
+}
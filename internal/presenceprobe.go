@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Defaults of NetworkPresence
+const (
+	defaultProbeInterval = 30 * time.Second
+	defaultAwayAfter     = 10 * time.Minute
+)
+
+// probePort is where a probe knocks. iPhones keep it open for syncing, and
+// other phones refuse the connection, which answers just as well.
+const probePort = "62078"
+
+// probeTimeout is how long a probe waits for an answer
+const probeTimeout = 2 * time.Second
+
+// NetworkPresence has serve tell who is home from their phones on the
+// network, feeding the presence rules as the webhooks do. A person enters
+// when any of their Devices is seen and leaves once none has been for
+// AwayAfter, which rides out phones that sleep their Wi-Fi.
+type NetworkPresence struct {
+	Interval  string          `json:"interval,omitempty"`
+	AwayAfter string          `json:"awayAfter,omitempty"`
+	Devices   []PresenceProbe `json:"devices,omitempty"`
+}
+
+// PresenceProbe is a phone of Person, found at IP or by MAC in the ARP
+// table, which follows the phone when DHCP gives it a new address
+type PresenceProbe struct {
+	Person string `json:"person"`
+	IP     string `json:"ip,omitempty"`
+	MAC    string `json:"mac,omitempty"`
+}
+
+// probeHost reports whether a host answered at ip, with a connection or a
+// refusal. Ping needs privileges this command should not ask for.
+var probeHost = func(ctx context.Context, ip string) bool {
+	dialer := net.Dialer{Timeout: probeTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, probePort))
+	if err == nil {
+		conn.Close()
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// presenceProber probes the phones every interval and tells the tracker
+// who entered or left
+type presenceProber struct {
+	mu        sync.Mutex
+	presence  *presenceTracker
+	interval  time.Duration
+	awayAfter time.Duration
+	probes    []PresenceProbe
+	now       func() time.Time
+	home      map[string]bool
+	lastSeen  map[string]time.Time
+}
+
+func newPresenceProber(presence *presenceTracker, settings *NetworkPresence) (*presenceProber, error) {
+	p := &presenceProber{presence: presence, interval: defaultProbeInterval, awayAfter: defaultAwayAfter, now: time.Now, home: make(map[string]bool), lastSeen: make(map[string]time.Time)}
+	if settings == nil {
+		return p, nil
+	}
+	var err error
+	if settings.Interval != "" {
+		if p.interval, err = time.ParseDuration(settings.Interval); err != nil || p.interval < time.Second {
+			return nil, fmt.Errorf("networkPresence.interval: invalid duration %q, e.g. 30s", settings.Interval)
+		}
+	}
+	if settings.AwayAfter != "" {
+		if p.awayAfter, err = time.ParseDuration(settings.AwayAfter); err != nil || p.awayAfter <= 0 {
+			return nil, fmt.Errorf("networkPresence.awayAfter: invalid duration %q, e.g. 10m", settings.AwayAfter)
+		}
+	}
+	for i, probe := range settings.Devices {
+		if probe.Person == "" {
+			return nil, fmt.Errorf("networkPresence.devices[%d]: person is required", i)
+		}
+		if probe.IP == "" && probe.MAC == "" {
+			return nil, fmt.Errorf("networkPresence.devices[%d]: set the ip or mac of the phone", i)
+		}
+		if probe.IP != "" && net.ParseIP(probe.IP) == nil {
+			return nil, fmt.Errorf("networkPresence.devices[%d]: invalid ip %q", i, probe.IP)
+		}
+		if probe.MAC != "" {
+			if _, err := net.ParseMAC(probe.MAC); err != nil {
+				return nil, fmt.Errorf("networkPresence.devices[%d]: invalid mac %q", i, probe.MAC)
+			}
+		}
+	}
+	p.probes = settings.Devices
+	return p, nil
+}
+
+// setSettings applies changed settings, keeping who was seen
+func (p *presenceProber) setSettings(settings *NetworkPresence) error {
+	next, err := newPresenceProber(p.presence, settings)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval, p.awayAfter, p.probes = next.interval, next.awayAfter, next.probes
+	return nil
+}
+
+// seen returns the people with a phone that answered. The probes also have
+// the system resolve the phones' MAC addresses, so the ARP table read after
+// them is fresh.
+func (p *presenceProber) seen(ctx context.Context, probes []PresenceProbe) map[string]bool {
+	seen := make(map[string]bool)
+	for _, probe := range probes {
+		if probe.IP != "" && !seen[probe.Person] && probeHost(ctx, probe.IP) {
+			seen[probe.Person] = true
+		}
+	}
+	macs := parseARPMACs(arpTable())
+	for _, probe := range probes {
+		if probe.MAC == "" || seen[probe.Person] {
+			continue
+		}
+		if ip, ok := macs[normalizeMAC(probe.MAC)]; ok && probeHost(ctx, ip) {
+			seen[probe.Person] = true
+		}
+	}
+	return seen
+}
+
+// round probes the phones once and runs the presence rules for whoever
+// arrived, or has been gone for awayAfter
+func (p *presenceProber) round(ctx context.Context) {
+	p.mu.Lock()
+	probes, awayAfter := p.probes, p.awayAfter
+	p.mu.Unlock()
+	if len(probes) == 0 {
+		return
+	}
+	seen := p.seen(ctx, probes)
+	now := p.now()
+
+	done := make(map[string]bool)
+	for _, probe := range probes {
+		person := probe.Person
+		if done[person] {
+			continue
+		}
+		done[person] = true
+		event := ""
+		switch {
+		case seen[person]:
+			p.lastSeen[person] = now
+			if !p.home[person] {
+				p.home[person], event = true, "enter"
+			}
+		case p.home[person] && now.Sub(p.lastSeen[person]) >= awayAfter:
+			p.home[person], event = false, "leave"
+		}
+		if event == "" {
+			continue
+		}
+		statusf("Network presence: %s %s\n", person, map[string]string{"enter": "arrived", "leave": "left"}[event])
+		if _, err := p.presence.update(ctx, person, event); err != nil {
+			errorf("Presence %s %s failed: %v\n", person, event, err)
+			if p.presence.notify != nil {
+				p.presence.notify("Presence rule failed", err.Error())
+			}
+		}
+	}
+}
+
+// run probes every interval until ctx is done
+func (p *presenceProber) run(ctx context.Context) {
+	for {
+		p.round(ctx)
+		p.mu.Lock()
+		interval := p.interval
+		p.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// normalizeMAC writes a MAC address as lower case pairs joined by colons,
+// which is how Linux prints them; macOS leaves out leading zeros and
+// Windows uses dashes
+func normalizeMAC(mac string) string {
+	parts := strings.FieldsFunc(strings.ToLower(mac), func(r rune) bool { return r == ':' || r == '-' })
+	for i, part := range parts {
+		if len(part) == 1 {
+			parts[i] = "0" + part
+		}
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPresenceProber(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	nanoleaf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer nanoleaf.Close()
+	device := NewDevice()
+	device.config.IP = nanoleaf.URL
+	device.config.Token = "test-token"
+
+	up := map[string]bool{}
+	originalProbe, originalARP := probeHost, arpTable
+	probeHost = func(ctx context.Context, ip string) bool { return up[ip] }
+	arpTable = func() string { return "192.168.1.60 0x1 0x2 0:55:da:50:12:34 * wlan0" }
+	defer func() { probeHost, arpTable = originalProbe, originalARP }()
+
+	presence, err := newPresenceTracker(device, nil, nil)
+	if err != nil {
+		t.Fatalf("newPresenceTracker should not fail: %v", err)
+	}
+	prober, err := newPresenceProber(presence, &NetworkPresence{AwayAfter: "5m", Devices: []PresenceProbe{
+		{Person: "alex", IP: "192.168.1.50"},
+		{Person: "sam", MAC: "00-55-DA-50-12-34"},
+	}})
+	if err != nil {
+		t.Fatalf("newPresenceProber should not fail: %v", err)
+	}
+	now := time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC)
+	prober.now = func() time.Time { return now }
+
+	up["192.168.1.50"], up["192.168.1.60"] = true, true
+	prober.round(context.Background())
+	if !presence.home["alex"] || !presence.home["sam"] {
+		t.Fatalf("expected alex by ip and sam by mac to enter, got %v", presence.home)
+	}
+
+	up["192.168.1.50"] = false
+	now = now.Add(4 * time.Minute)
+	prober.round(context.Background())
+	if !presence.home["alex"] {
+		t.Error("expected alex to stay home before awayAfter")
+	}
+	now = now.Add(time.Minute)
+	prober.round(context.Background())
+	if presence.home["alex"] || !presence.home["sam"] {
+		t.Errorf("expected alex to leave after awayAfter and sam to stay, got %v", presence.home)
+	}
+
+	for _, settings := range []NetworkPresence{
+		{Interval: "10ms"},
+		{Devices: []PresenceProbe{{IP: "192.168.1.50"}}},
+		{Devices: []PresenceProbe{{Person: "alex"}}},
+		{Devices: []PresenceProbe{{Person: "alex", MAC: "not-a-mac"}}},
+	} {
+		if _, err := newPresenceProber(nil, &settings); err == nil {
+			t.Errorf("expected %+v to be rejected", settings)
+		}
+	}
+}
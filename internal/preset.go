@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Preset is a saved look applied with a number key. Any combination of
+// fields may be set; an effect is applied before color and brightness.
+// ColorTemp is in Kelvin and is the only color option for white-only models.
+// The UI asks for confirmation before applying a preset unless it is Trusted.
+// With Transition, in seconds, color and brightness change gradually along
+// Easing (ease-in-out by default).
+type Preset struct {
+	Name       string  `json:"name,omitempty"`
+	Effect     string  `json:"effect,omitempty"`
+	Color      string  `json:"color,omitempty"`
+	ColorTemp  *int    `json:"colorTemp,omitempty"`
+	Brightness *int    `json:"brightness,omitempty"`
+	Transition float64 `json:"transition,omitempty"`
+	Easing     string  `json:"easing,omitempty"`
+	Trusted    bool    `json:"trusted,omitempty"`
+}
+
+// maxTransition keeps a mistyped transition from tying up the device
+const maxTransition = 10 * time.Minute
+
+// transition returns how long the preset takes to apply
+func (p Preset) transition() time.Duration {
+	return min(time.Duration(p.Transition*float64(time.Second)), maxTransition)
+}
+
+func (p Preset) apply(ctx context.Context, device *Device) error {
+	if p.Effect == "" && p.Color == "" && p.ColorTemp == nil && p.Brightness == nil {
+		return fmt.Errorf("preset has no effect, color or brightness")
+	}
+
+	if p.Effect != "" {
+		if err := device.SelectEffect(ctx, p.Effect); err != nil {
+			return err
+		}
+	}
+	if p.transition() > 0 && (p.Color != "" || p.Brightness != nil) {
+		if p.ColorTemp != nil {
+			if err := device.SetColorTemp(ctx, *p.ColorTemp); err != nil {
+				return err
+			}
+		}
+		return p.transitionTo(ctx, device)
+	}
+	if p.Color != "" {
+		color, err := parseColor(p.Color)
+		if err != nil {
+			return err
+		}
+		hue, sat, _ := color.hsb()
+		if err := device.SetColor(ctx, hue, sat); err != nil {
+			return err
+		}
+	}
+	if p.ColorTemp != nil {
+		if err := device.SetColorTemp(ctx, *p.ColorTemp); err != nil {
+			return err
+		}
+	}
+	if p.Brightness != nil {
+		if err := device.SetBrightness(ctx, *p.Brightness); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transitionTo moves to the color and brightness of the preset over its
+// transition; unset values stay where they are
+func (p Preset) transitionTo(ctx context.Context, device *Device) error {
+	var target lightState
+	if p.Color != "" {
+		color, err := parseColor(p.Color)
+		if err != nil {
+			return err
+		}
+		target.Hue, target.Sat, _ = color.hsb()
+	}
+	if p.Brightness != nil {
+		target.Bri = *p.Brightness
+	} else {
+		status, err := device.GetStatus(ctx)
+		if err != nil {
+			return err
+		}
+		target.Bri = status.Brightness
+	}
+	return device.Transition(ctx, target, p.Color != "", p.transition(), p.Easing)
+}
+
+func (p Preset) String() string {
+	if p.Name != "" {
+		return p.Name
+	}
+
+	var parts []string
+	if p.Effect != "" {
+		parts = append(parts, p.Effect)
+	}
+	if p.Color != "" {
+		parts = append(parts, p.Color)
+	}
+	if p.ColorTemp != nil {
+		parts = append(parts, fmt.Sprintf("%dK", *p.ColorTemp))
+	}
+	if p.Brightness != nil {
+		parts = append(parts, fmt.Sprintf("%d%%", *p.Brightness))
+	}
+	return strings.Join(parts, " ")
+}
+
+// diff describes what applying the preset would change from status, e.g.
+// "brightness 80→30". Color changes have no current value to compare with.
+func (p Preset) diff(status DeviceStatus) []string {
+	var changes []string
+	if !status.On {
+		changes = append(changes, "power off→on")
+	}
+	if p.Effect != "" && p.Effect != status.Effect {
+		changes = append(changes, fmt.Sprintf("effect %s→%s", status.Effect, p.Effect))
+	}
+	if p.Color != "" {
+		changes = append(changes, "color → "+p.Color)
+	}
+	if p.ColorTemp != nil {
+		changes = append(changes, fmt.Sprintf("temperature → %dK", *p.ColorTemp))
+	}
+	if p.Brightness != nil && *p.Brightness != status.Brightness {
+		changes = append(changes, fmt.Sprintf("brightness %d→%d", status.Brightness, *p.Brightness))
+	}
+	return changes
+}
+
+// presetKeys returns the configured preset keys 1-9 in order
+func presetKeys(presets map[string]Preset) []string {
+	var keys []string
+	for key := range presets {
+		if len(key) == 1 && key[0] >= '1' && key[0] <= '9' {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPresetApply(t *testing.T) {
+	var updates []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		for key := range payload {
+			updates = append(updates, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	brightness := 30
+	preset := Preset{Effect: "Northern Lights", Brightness: &brightness}
+	if err := preset.apply(context.Background(), device); err != nil {
+		t.Fatalf("apply should not fail: %v", err)
+	}
+
+	expected := []string{"select", "brightness"}
+	if !reflect.DeepEqual(updates, expected) {
+		t.Errorf("expected updates %v, got %v", expected, updates)
+	}
+}
+
+func TestPresetApplyInvalid(t *testing.T) {
+	device := NewDevice()
+	ctx := context.Background()
+
+	if err := (Preset{}).apply(ctx, device); err == nil {
+		t.Error("apply should fail for an empty preset")
+	}
+	if err := (Preset{Color: "notacolor"}).apply(ctx, device); err == nil {
+		t.Error("apply should fail for an unknown color")
+	}
+}
+
+func TestPresetString(t *testing.T) {
+	brightness := 30
+	if got := (Preset{Color: "warmwhite", Brightness: &brightness}).String(); got != "warmwhite 30%" {
+		t.Errorf("expected 'warmwhite 30%%', got %q", got)
+	}
+	if got := (Preset{Name: "Evening", Color: "warmwhite"}).String(); got != "Evening" {
+		t.Errorf("expected Evening, got %q", got)
+	}
+}
+
+func TestPresetKeys(t *testing.T) {
+	presets := map[string]Preset{"3": {}, "1": {}, "0": {}, "10": {}, "x": {}}
+	if keys := presetKeys(presets); !reflect.DeepEqual(keys, []string{"1", "3"}) {
+		t.Errorf("expected keys [1 3], got %v", keys)
+	}
+}
+
+func TestPresetDiff(t *testing.T) {
+	brightness, temp := 30, 2700
+	preset := Preset{Effect: "Snowfall", ColorTemp: &temp, Brightness: &brightness}
+
+	changes := preset.diff(DeviceStatus{On: true, Brightness: 80, Effect: "Blaze"})
+	expected := []string{"effect Blaze→Snowfall", "temperature → 2700K", "brightness 80→30"}
+	if strings.Join(changes, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected %v, got %v", expected, changes)
+	}
+
+	changes = preset.diff(DeviceStatus{On: false, Brightness: 30, Effect: "Snowfall"})
+	if strings.Join(changes, ", ") != "power off→on, temperature → 2700K" {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
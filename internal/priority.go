@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// priority orders the automations that want the same device. A change made
+// by a higher priority holds the lower ones for priorityHold, so automations
+// triggered together end up in the same state every time.
+type priority int
+
+const (
+	priorityCircadian priority = iota + 1
+	prioritySchedule
+	// priorityManual is the rank of changes made by hand, see manualOverride
+	priorityManual
+	priorityNotification
+)
+
+var priorityNames = map[priority]string{
+	priorityCircadian:    "circadian",
+	prioritySchedule:     "schedule",
+	priorityManual:       "manual",
+	priorityNotification: "notification",
+}
+
+func (p priority) String() string {
+	return priorityNames[p]
+}
+
+func parsePriority(name string) (priority, error) {
+	for p, n := range priorityNames {
+		if strings.EqualFold(n, name) {
+			return p, nil
+		}
+	}
+	names := make([]string, 0, len(priorityNames))
+	for _, p := range slices.Sorted(maps.Keys(priorityNames)) {
+		names = append(names, priorityNames[p])
+	}
+	return 0, fmt.Errorf("unknown priority %q, expected %s", name, strings.Join(names, ", "))
+}
+
+// commandPriorities are the priorities of the automation commands unless
+// the config's priorities say otherwise. watch-url usually drives a status
+// lamp and obs an on-air light, adaptive-brightness follows the light of
+// the day.
+var commandPriorities = map[string]priority{
+	"adaptive-brightness": priorityCircadian,
+	"calendar":            prioritySchedule,
+	"holidays":            prioritySchedule,
+	"hue-sync":            prioritySchedule,
+	"now-playing":         prioritySchedule,
+	"obs":                 priorityNotification,
+	"serve":               prioritySchedule,
+	"watch-url":           priorityNotification,
+	"weather":             prioritySchedule,
+}
+
+// defaultPriorityHold is how long a change holds lower priorities
+const defaultPriorityHold = 30 * time.Minute
+
+// automationPriority returns the priority of the automation command name
+func (c Config) automationPriority(name string) (priority, error) {
+	if configured, ok := c.Priorities[name]; ok {
+		p, err := parsePriority(configured)
+		if err != nil {
+			return 0, fmt.Errorf("priorities.%s: %w", name, err)
+		}
+		return p, nil
+	}
+	if p, ok := commandPriorities[name]; ok {
+		return p, nil
+	}
+	return prioritySchedule, nil
+}
+
+// priorityHold parses the priorityHold of the config
+func (c Config) priorityHold() (time.Duration, error) {
+	if c.PriorityHold == "" {
+		return defaultPriorityHold, nil
+	}
+	d, err := time.ParseDuration(c.PriorityHold)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("priorityHold: invalid duration %q, e.g. 30m", c.PriorityHold)
+	}
+	return d, nil
+}
+
+// validatePriorities checks the priorities and priorityHold of the config
+func (c Config) validatePriorities() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Priorities)) {
+		if _, ok := commandPriorities[name]; !ok {
+			return fmt.Errorf("priorities.%s: not an automation command", name)
+		}
+		if _, err := c.automationPriority(name); err != nil {
+			return err
+		}
+	}
+	_, err := c.priorityHold()
+	return err
+}
+
+// yields returns why an automation of priority p should leave the device
+// alone right now, or "" when it may change it: the automations are paused,
+// someone changed the device by hand within manualOverride, or another
+// automation of a higher priority changed it within priorityHold.
+// Notifications go through manual changes; equal priorities take turns.
+func (g *quietGate) yields(p priority) string {
+	if automationsPaused() {
+		return "automations are paused"
+	}
+	if g == nil {
+		return ""
+	}
+	now := g.now()
+	last := lastChanges(g.ip)
+	if p < priorityManual && g.override > 0 && !last.Manual.IsZero() && now.Sub(last.Manual) < g.override {
+		return "the device was changed by hand"
+	}
+	if q, err := parsePriority(last.Priority); err == nil && q > p && last.Source != g.source && now.Sub(last.Automated) < g.hold {
+		return fmt.Sprintf("%s (%s) has priority", last.Source, q)
+	}
+	return ""
+}
+
+// logHold prints why the gate holds changes once, when the reason changes
+func (g *quietGate) logHold(reason string) {
+	if g == nil || reason == g.reason {
+		return
+	}
+	if reason != "" {
+		statusf("Holding changes: %s\n", reason)
+	} else if g.reason != "" {
+		statusf("No longer holding changes\n")
+	}
+	g.reason = reason
+}
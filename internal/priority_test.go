@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	for name, want := range map[string]priority{
+		"circadian":    priorityCircadian,
+		"Schedule":     prioritySchedule,
+		"manual":       priorityManual,
+		"NOTIFICATION": priorityNotification,
+	} {
+		if got, err := parsePriority(name); err != nil || got != want {
+			t.Errorf("parsePriority(%q) = %v, %v, expected %v", name, got, err, want)
+		}
+	}
+	if _, err := parsePriority("urgent"); err == nil || !strings.Contains(err.Error(), "circadian, schedule, manual, notification") {
+		t.Errorf("expected an unknown priority to list the known ones, got %v", err)
+	}
+}
+
+func TestAutomationPriority(t *testing.T) {
+	config := Config{Priorities: map[string]string{"weather": "notification"}}
+	if p, _ := config.automationPriority("weather"); p != priorityNotification {
+		t.Errorf("expected the configured priority, got %v", p)
+	}
+	if p, _ := config.automationPriority("adaptive-brightness"); p != priorityCircadian {
+		t.Errorf("expected adaptive-brightness to default to circadian, got %v", p)
+	}
+	if p, _ := config.automationPriority(""); p != prioritySchedule {
+		t.Errorf("expected other commands to default to schedule, got %v", p)
+	}
+
+	for _, invalid := range []Config{
+		{Priorities: map[string]string{"weather": "urgent"}},
+		{Priorities: map[string]string{"on": "schedule"}},
+		{PriorityHold: "a while"},
+	} {
+		if err := invalid.validatePriorities(); err == nil {
+			t.Errorf("expected %+v to be rejected", invalid)
+		}
+	}
+	if hold, err := (Config{}).priorityHold(); err != nil || hold != defaultPriorityHold {
+		t.Errorf("expected the default hold, got %v, %v", hold, err)
+	}
+}
+
+func TestQuietGateYields(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	gate := func(source string, p priority) *quietGate {
+		return &quietGate{now: func() time.Time { return now }, ip: "10.0.0.5", override: time.Hour, source: source, priority: p, hold: 30 * time.Minute}
+	}
+	weather := gate("weather", prioritySchedule)
+	circadian := gate("adaptive-brightness", priorityCircadian)
+	notification := gate("watch-url", priorityNotification)
+
+	if err := recordChange("10.0.0.5", "weather", prioritySchedule, now.Add(-10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if reason := circadian.yields(circadian.priority); !strings.Contains(reason, "weather (schedule)") {
+		t.Errorf("expected circadian to yield to a recent schedule change, got %q", reason)
+	}
+	if reason := weather.yields(weather.priority); reason != "" {
+		t.Errorf("an automation should not yield to itself, got %q", reason)
+	}
+	if reason := gate("holidays", prioritySchedule).yields(prioritySchedule); reason != "" {
+		t.Errorf("equal priorities should take turns, got %q", reason)
+	}
+	now = now.Add(25 * time.Minute)
+	if reason := circadian.yields(circadian.priority); reason != "" {
+		t.Errorf("expected the hold to end after priorityHold, got %q", reason)
+	}
+
+	if err := recordChange("10.0.0.5", "", 0, now); err != nil {
+		t.Fatal(err)
+	}
+	if reason := weather.yields(weather.priority); reason != "the device was changed by hand" {
+		t.Errorf("expected a schedule to yield to a manual change, got %q", reason)
+	}
+	if reason := notification.yields(notification.priority); reason != "" {
+		t.Errorf("expected a notification to go through a manual change, got %q", reason)
+	}
+}
+
+func TestPresenceRulesByPriority(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sent = append(sent, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.record = true
+	quiet := &quietGate{now: time.Now, ip: server.URL, source: "serve", priority: prioritySchedule, hold: time.Hour}
+
+	presence, err := newPresenceTracker(device, quiet, []PresenceRule{
+		{Event: "home", Actions: []string{"brightness 90"}, Priority: "notification"},
+		{Event: "enter", Actions: []string{"brightness 20"}, Priority: "circadian"},
+		{Event: "enter", Actions: []string{"brightness 50"}},
+		{Event: "leave", Actions: []string{"brightness 10"}},
+	})
+	if err != nil {
+		t.Fatalf("newPresenceTracker should not fail: %v", err)
+	}
+
+	ran, err := presence.update(context.Background(), "alex", "enter")
+	if err != nil || ran != 3 {
+		t.Fatalf("expected the three matching rules to run, got %d, %v", ran, err)
+	}
+	want := []string{`{"brightness":{"value":20}}`, `{"brightness":{"value":50}}`, `{"brightness":{"value":90}}`}
+	if strings.Join(sent, " ") != strings.Join(want, " ") {
+		t.Errorf("expected the highest priority to run last, got %v", sent)
+	}
+	if last := lastChanges(server.URL); last.Source != "presence rule 1" || last.Priority != "notification" {
+		t.Errorf("expected the change recorded as the winning rule's, got %+v", last)
+	}
+
+	if ran, _ := presence.update(context.Background(), "alex", "leave"); ran != 0 {
+		t.Errorf("expected a lower priority to be held after the notification, %d rules ran", ran)
+	}
+
+	if _, err := newPresenceTracker(device, nil, []PresenceRule{{Event: "home", Actions: []string{"on"}, Priority: "urgent"}}); err == nil {
+		t.Error("expected an error for an unknown priority")
+	}
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// profileEnv selects the profile when --profile is not given
+const profileEnv = "NANOLEAF_PROFILE"
+
+// defaultProfile names the config used without a profile
+const defaultProfile = "default"
+
+var profileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// activeProfile is the profile selected for this run, empty for the
+// default one. Each profile has its own config, lock, backups, device
+// registry, UI session, history and usage log, so a laptop can keep e.g. home and office apart.
+var activeProfile string
+
+// globalFlags are the flags other than --profile that go before the
+// command, in any order
+var globalFlags = map[string]bool{
+	"--ignore-firmware": true,
+	"--quiet":           true,
+	"--stdin":           true,
+	"--no-tui":          true,
+}
+
+// SelectProfile takes --profile NAME or --profile=NAME off the global flags
+// at the start of args, falling back to $NANOLEAF_PROFILE, and makes it the
+// active profile. It returns the remaining args, the other global flags
+// kept in place.
+func SelectProfile(args []string, getenv func(string) string) ([]string, error) {
+	name := getenv(profileEnv)
+	rest := make([]string, 0, len(args))
+	i := 0
+	for ; i < len(args); i++ {
+		if value, ok := strings.CutPrefix(args[i], "--profile="); ok {
+			name = value
+		} else if args[i] == "--profile" {
+			if i+1 >= len(args) {
+				return nil, invalidArgs(fmt.Errorf("--profile needs a name"))
+			}
+			name = args[i+1]
+			i++
+		} else if globalFlags[args[i]] {
+			rest = append(rest, args[i])
+		} else {
+			break
+		}
+	}
+	if err := setProfile(name); err != nil {
+		return nil, err
+	}
+	return append(rest, args[i:]...), nil
+}
+
+func setProfile(name string) error {
+	if name == "" || name == defaultProfile {
+		activeProfile = ""
+		return nil
+	}
+	if !profileName.MatchString(name) {
+		return invalidArgs(fmt.Errorf("profile %q: use letters, digits, - and _", name))
+	}
+	activeProfile = name
+	return nil
+}
+
+// profilePath returns the path of a file in the home directory for the
+// active profile, e.g. .nanoleaf_config.office.json for base
+// .nanoleaf_config and ext .json
+func profilePath(base, ext string) string {
+	homeDir, _ := os.UserHomeDir()
+	if activeProfile != "" {
+		base += "." + activeProfile
+	}
+	return filepath.Join(homeDir, base+ext)
+}
+
+// listProfiles returns the profiles that have a config, the default one
+// first
+func listProfiles() ([]string, error) {
+	homeDir, _ := os.UserHomeDir()
+	matches, err := filepath.Glob(filepath.Join(homeDir, ".nanoleaf_config.*"))
+	if err != nil {
+		return nil, err
+	}
+	var profiles []string
+	seen := make(map[string]bool)
+	var named []string
+	for _, match := range matches {
+		ext := filepath.Ext(match)
+		if !slices.Contains(configExts, ext) {
+			continue
+		}
+		// .nanoleaf_config.json is the default config and
+		// .nanoleaf_config.json.json the one of a profile named json
+		rest := strings.TrimSuffix(filepath.Base(match), ext)
+		name, ok := strings.CutPrefix(rest, ".nanoleaf_config.")
+		if rest == ".nanoleaf_config" {
+			name, ok = defaultProfile, true
+		}
+		if !ok {
+			continue
+		}
+		if !profileName.MatchString(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if name == defaultProfile {
+			profiles = append(profiles, name)
+		} else {
+			named = append(named, name)
+		}
+	}
+	sort.Strings(named)
+	return append(profiles, named...), nil
+}
+
+func runProfiles(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError("profiles")
+	}
+	profiles, err := listProfiles()
+	if err != nil {
+		return err
+	}
+	active := activeProfile
+	if active == "" {
+		active = defaultProfile
+	}
+	for _, name := range profiles {
+		marker := " "
+		if name == active {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, name)
+	}
+	if len(profiles) == 0 {
+		statusf("No profile has a config yet, pair a device with the interactive UI first\n")
+	}
+	return nil
+}
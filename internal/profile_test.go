@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelectProfile(t *testing.T) {
+	t.Cleanup(func() { activeProfile = "" })
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+
+	args, err := SelectProfile([]string{"--profile", "office", "status"}, getenv)
+	if err != nil || activeProfile != "office" || !reflect.DeepEqual(args, []string{"status"}) {
+		t.Errorf("expected the office profile and the status command, got %q, %v, %v", activeProfile, args, err)
+	}
+	args, err = SelectProfile([]string{"--profile=travel"}, getenv)
+	if err != nil || activeProfile != "travel" || len(args) != 0 {
+		t.Errorf("expected the travel profile, got %q, %v, %v", activeProfile, args, err)
+	}
+
+	args, err = SelectProfile([]string{"--quiet", "--profile", "office", "--ignore-firmware", "on"}, getenv)
+	if err != nil || activeProfile != "office" || !reflect.DeepEqual(args, []string{"--quiet", "--ignore-firmware", "on"}) {
+		t.Errorf("expected --profile among the other global flags, got %q, %v, %v", activeProfile, args, err)
+	}
+	args, _ = SelectProfile([]string{"effect", "--profile", "x"}, getenv)
+	if activeProfile != "" || len(args) != 3 {
+		t.Errorf("expected --profile after the command to be left to the command, got %q, %v", activeProfile, args)
+	}
+
+	env[profileEnv] = "home"
+	if _, err := SelectProfile([]string{"on"}, getenv); err != nil || activeProfile != "home" {
+		t.Errorf("expected the profile from $%s, got %q, %v", profileEnv, activeProfile, err)
+	}
+	if _, err := SelectProfile([]string{"--profile", "default"}, getenv); err != nil || activeProfile != "" {
+		t.Errorf("--profile should override $%s, got %q, %v", profileEnv, activeProfile, err)
+	}
+
+	for _, args := range [][]string{{"--profile"}, {"--profile", "../etc"}} {
+		if _, err := SelectProfile(args, getenv); ExitCode(err) != ExitInvalidArgs {
+			t.Errorf("%v: expected invalid arguments, got %v", args, err)
+		}
+	}
+}
+
+func TestProfilePaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Cleanup(func() { activeProfile = "" })
+
+	if err := saveConfig(Config{IP: "192.168.1.100", Token: "home-token"}); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	if err := setProfile("office"); err != nil {
+		t.Fatalf("setProfile should not fail: %v", err)
+	}
+	if got := getConfigPath(); got != filepath.Join(home, ".nanoleaf_config.office.json") {
+		t.Errorf("unexpected config path %s", got)
+	}
+	if configExists() {
+		t.Error("the office profile should not see the default config")
+	}
+	if getHistoryPath() != filepath.Join(home, ".nanoleaf_history.office.jsonl") || getUsagePath() != filepath.Join(home, ".nanoleaf_usage.office.jsonl") {
+		t.Errorf("expected a history and usage log of the profile, got %s and %s", getHistoryPath(), getUsagePath())
+	}
+	if err := saveConfig(Config{IP: "10.0.0.5", Token: "office-token"}); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	os.WriteFile(filepath.Join(home, ".nanoleaf_config.bad name.json"), []byte("{}"), 0600)
+
+	profiles, err := listProfiles()
+	if err != nil || !reflect.DeepEqual(profiles, []string{"default", "office"}) {
+		t.Errorf("expected the default and office profiles, got %v, %v", profiles, err)
+	}
+
+	setProfile("")
+	config, err := loadConfig()
+	if err != nil || config.Token != "home-token" {
+		t.Errorf("the default config should be unchanged, got %+v, %v", config, err)
+	}
+}
+
+func TestListProfilesNamedAfterFormats(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, name := range []string{".nanoleaf_config.json.json", ".nanoleaf_config.toml.toml", ".nanoleaf_config.yaml.json"} {
+		os.WriteFile(filepath.Join(home, name), []byte("{}"), 0600)
+	}
+
+	profiles, err := listProfiles()
+	if err != nil || !reflect.DeepEqual(profiles, []string{"json", "toml", "yaml"}) {
+		t.Errorf("expected profiles named after formats and no default, got %v, %v", profiles, err)
+	}
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// qrQuietZone is the light border around the code in modules; 2 keeps the
+// code inside the UI box while phones still read it reliably
+const qrQuietZone = 2
+
+// pairingPayload encodes a device's credentials as the flat JSON object the
+// import command reads, so a scanned code can be imported elsewhere
+func pairingPayload(ip, token string) string {
+	data, _ := json.Marshal(flatPairing{IP: ip, Token: token})
+	return string(data)
+}
+
+// configPairingPayload returns the pairingPayload of the paired device at
+// ip, or of the active device when ip is empty
+func configPairingPayload(config Config, ip string) (string, error) {
+	if ip == "" {
+		ip = config.IP
+	}
+	token := config.Devices[ip].Token
+	if ip == config.IP {
+		token = config.Token
+	}
+	if token == "" {
+		return "", fmt.Errorf("device %s is not paired", ip)
+	}
+	return pairingPayload(ip, token), nil
+}
+
+// renderQR draws text as a QR code with half block characters, two modules
+// per line. Light modules are drawn so the code reads on dark terminals.
+func renderQR(text string) (string, error) {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return "", err
+	}
+
+	light := func(x, y int) bool { return !code.Black(x, y) }
+	var b strings.Builder
+	for y := -qrQuietZone; y < code.Size+qrQuietZone; y += 2 {
+		for x := -qrQuietZone; x < code.Size+qrQuietZone; x++ {
+			top, bottom := light(x, y), y+1 < code.Size+qrQuietZone && light(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func runQR(ctx context.Context, args []string) error {
+	fs := newFlagSet("qr")
+	ip := fs.String("ip", "", "paired device to show (defaults to the active device)")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	payload, err := configPairingPayload(device.GetConfig(), *ip)
+	if err != nil {
+		return err
+	}
+	code, err := renderQR(payload)
+	if err != nil {
+		return err
+	}
+	fmt.Println(code)
+	fmt.Println(payload)
+	return nil
+}
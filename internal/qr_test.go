@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPairingPayloadImports(t *testing.T) {
+	payload := pairingPayload("192.168.1.100", "abc")
+	pairings, err := parsePairings([]byte(payload))
+	if err != nil {
+		t.Fatalf("payload %s should be importable: %v", payload, err)
+	}
+	expected := []importedPairing{{IP: "192.168.1.100", Token: "abc"}}
+	if !reflect.DeepEqual(pairings, expected) {
+		t.Errorf("expected %v, got %v", expected, pairings)
+	}
+}
+
+func TestConfigPairingPayload(t *testing.T) {
+	config := Config{IP: "192.168.1.100", Token: "abc", Devices: map[string]PairedDevice{
+		"192.168.1.100": {Token: "abc"},
+		"192.168.1.101": {Token: "def"},
+	}}
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"", pairingPayload("192.168.1.100", "abc")},
+		{"192.168.1.101", pairingPayload("192.168.1.101", "def")},
+	}
+	for _, tt := range tests {
+		if got, err := configPairingPayload(config, tt.ip); err != nil || got != tt.want {
+			t.Errorf("%q: expected %s, got %s, %v", tt.ip, tt.want, got, err)
+		}
+	}
+	if _, err := configPairingPayload(config, "192.168.1.102"); err == nil {
+		t.Error("expected an error for a device that is not paired")
+	}
+}
+
+func TestRenderQR(t *testing.T) {
+	code, err := renderQR(pairingPayload("192.168.100.100", strings.Repeat("x", 32)))
+	if err != nil {
+		t.Fatalf("renderQR should not fail: %v", err)
+	}
+
+	lines := strings.Split(code, "\n")
+	width := utf8.RuneCountInString(lines[0])
+	// The UI box is 46 columns wide inside
+	if width > 46 {
+		t.Errorf("code is %d columns wide, too wide for the UI", width)
+	}
+	if len(lines) != (width+1)/2 {
+		t.Errorf("expected %d lines for a %d module code, got %d", (width+1)/2, width, len(lines))
+	}
+	for _, line := range lines {
+		if utf8.RuneCountInString(line) != width {
+			t.Fatalf("lines should all be %d wide", width)
+		}
+	}
+	if lines[0] != strings.Repeat("█", width) {
+		t.Error("the first line should be quiet zone")
+	}
+}
@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QuietHours is a daily window, e.g. 22:00 to 07:00, during which the
+// automation commands (weather, watch-url, hue-sync, now-playing, holidays,
+// adaptive-brightness, obs, calendar) and presence rules hold their changes
+// until the window ends, or keep running with brightness capped at
+// MaxBrightness when it is set. Times are in Timezone, else the timezone of
+// the config. DSTSkipped and DSTRepeated settle a start or end that a DST
+// change skips or repeats, see wallClock.
+type QuietHours struct {
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	MaxBrightness int    `json:"maxBrightness,omitempty"`
+	Timezone      string `json:"timezone,omitempty"`
+	DSTSkipped    string `json:"dstSkipped,omitempty"`
+	DSTRepeated   string `json:"dstRepeated,omitempty"`
+}
+
+// parseClock parses "HH:MM" into the time since midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (q QuietHours) validate() error {
+	if _, err := parseClock(q.Start); err != nil {
+		return fmt.Errorf("quietHours.start: %w", err)
+	}
+	if _, err := parseClock(q.End); err != nil {
+		return fmt.Errorf("quietHours.end: %w", err)
+	}
+	if q.MaxBrightness < 0 || q.MaxBrightness > 100 {
+		return fmt.Errorf("quietHours.maxBrightness must be between 0 and 100")
+	}
+	if _, err := (Config{}).scheduleZone(q.Timezone); err != nil {
+		return fmt.Errorf("quietHours.timezone: %w", err)
+	}
+	if err := validateDSTPolicies(q.DSTSkipped, q.DSTRepeated); err != nil {
+		return fmt.Errorf("quietHours.%w", err)
+	}
+	return nil
+}
+
+// quietBoundary is when the quiet hours begin or end
+type quietBoundary struct {
+	at    time.Time
+	begin bool
+}
+
+// boundaries returns when the quiet hours begin and end in loc, in order,
+// on the days from the one before from to the one of to. The end is never
+// skipped, or the window would run into the next day.
+func (q QuietHours) boundaries(from, to time.Time, loc *time.Location) []quietBoundary {
+	start, _ := parseClock(q.Start)
+	end, _ := parseClock(q.End)
+	var bounds []quietBoundary
+	for day := startOfDay(from.In(loc)).AddDate(0, 0, -1); !day.After(to); day = day.AddDate(0, 0, 1) {
+		for _, at := range wallClock(day, start, loc, q.DSTSkipped, q.DSTRepeated) {
+			bounds = append(bounds, quietBoundary{at: at, begin: true})
+		}
+		for _, at := range wallClock(day, end, loc, dstShift, q.DSTRepeated) {
+			bounds = append(bounds, quietBoundary{at: at})
+		}
+	}
+	sort.SliceStable(bounds, func(i, j int) bool { return bounds[i].at.Before(bounds[j].at) })
+	return bounds
+}
+
+// active reports whether now falls inside the window in loc, or the zone of
+// this computer when loc is nil. The window may run past midnight. It
+// expects a validated window.
+func (q QuietHours) active(now time.Time, loc *time.Location) bool {
+	if loc == nil {
+		loc = time.Local
+	}
+	active := false
+	for _, b := range q.boundaries(now, now, loc) {
+		if b.at.After(now) {
+			break
+		}
+		active = b.begin
+	}
+	return active
+}
+
+// endAfter returns when the quiet hours active at t end in loc
+func (q QuietHours) endAfter(t time.Time, loc *time.Location) time.Time {
+	for _, b := range q.boundaries(t, t.AddDate(0, 0, 2), loc) {
+		if !b.begin && b.at.After(t) {
+			return b.at
+		}
+	}
+	return t
+}
+
+// quietGate applies the quiet hours, daemon pause, the manual override and
+// the priorities to an automation loop. A nil gate only holds changes while
+// the automations are paused.
+type quietGate struct {
+	hours QuietHours
+	now   func() time.Time
+	// capped is set once brightness was capped in the current window
+	capped bool
+	// ip and override hold changes for override after the device at ip was
+	// last changed by hand
+	ip       string
+	override time.Duration
+	// source and priority are those of the automation, and hold how long
+	// changes by higher priorities hold it, see yields
+	source   string
+	priority priority
+	hold     time.Duration
+	// reason is why changes were last held, see logHold
+	reason string
+	// loc is the time zone of the quiet hours
+	loc *time.Location
+}
+
+// addQuietHoursFlag adds the per-command override of the quiet hours
+func addQuietHoursFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("ignore-quiet-hours", false, "keep running as usual during the quiet hours in the config")
+}
+
+// newQuietGate returns the gate for the running automation on the active
+// device, with the configured quiet hours unless ignore is set
+func newQuietGate(config Config, ignore bool) (*quietGate, error) {
+	override, err := config.manualOverride()
+	if err != nil {
+		return nil, err
+	}
+	hold, err := config.priorityHold()
+	if err != nil {
+		return nil, err
+	}
+	p, err := config.automationPriority(runningAutomation)
+	if err != nil {
+		return nil, err
+	}
+	gate := &quietGate{now: time.Now, ip: config.IP, override: override, source: runningAutomation, priority: p, hold: hold}
+	if config.QuietHours != nil && !ignore {
+		if err := config.QuietHours.validate(); err != nil {
+			return nil, err
+		}
+		gate.hours = *config.QuietHours
+		if gate.loc, err = config.scheduleZone(gate.hours.Timezone); err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+	}
+	return gate, nil
+}
+
+// quiet reports whether the quiet hours are active
+func (g *quietGate) quiet() bool {
+	return g != nil && g.hours.Start != "" && g.hours.active(g.now(), g.loc)
+}
+
+// quietHeld reports whether the quiet hours hold changes rather than cap
+// the brightness
+func (g *quietGate) quietHeld() bool {
+	return g.quiet() && g.hours.MaxBrightness == 0
+}
+
+// held reports whether changes should wait until the quiet hours, the
+// manual override or the hold of a higher priority end, or the automations
+// are resumed
+func (g *quietGate) held() bool {
+	p := prioritySchedule
+	if g != nil {
+		p = g.priority
+	}
+	reason := g.yields(p)
+	g.logHold(reason)
+	return reason != "" || g.quietHeld()
+}
+
+// settle caps device for the changes the automation is about to make.
+// While capped quiet hours are active no change sends a brightness above
+// the cap, and the panels are lowered to it once per window, so a change
+// never shows at full brightness first.
+func (g *quietGate) settle(ctx context.Context, device *Device) error {
+	device.setBrightnessCap(g.limit)
+	if g.held() {
+		return nil
+	}
+	if !g.quiet() || g.hours.MaxBrightness == 0 {
+		if g != nil {
+			g.capped = false
+		}
+		return nil
+	}
+	if g.capped {
+		return nil
+	}
+	if err := device.client.lowerToCap(ctx, device.GetConfig().IP, device.GetConfig().Token); err != nil {
+		return err
+	}
+	g.capped = true
+	return nil
+}
+
+// limit returns the highest brightness allowed right now
+func (g *quietGate) limit() int {
+	if !g.quiet() || g.hours.MaxBrightness == 0 {
+		return 100
+	}
+	return g.hours.MaxBrightness
+}
+
+// String describes the gate for the start up message of a command
+func (g *quietGate) String() string {
+	var parts []string
+	if g.hours.Start != "" {
+		window := fmt.Sprintf("Quiet hours: quiet from %s to %s", g.hours.Start, g.hours.End)
+		if g.hours.MaxBrightness > 0 {
+			window = fmt.Sprintf("Quiet hours: brightness capped at %d%% from %s to %s", g.hours.MaxBrightness, g.hours.Start, g.hours.End)
+		}
+		if g.loc != nil && g.loc != time.Local {
+			window += " " + g.loc.String() + " time"
+		}
+		parts = append(parts, window)
+	}
+	if g.override > 0 {
+		parts = append(parts, fmt.Sprintf("Manual changes are left alone for %s", shortDuration(g.override)))
+	}
+	parts = append(parts, fmt.Sprintf("Priority: %s, held for %s by changes of higher priorities", g.priority, shortDuration(g.hold)))
+	return strings.Join(parts, "\n")
+}
+
+// shortDuration formats d without trailing zero units, e.g. 1h for 1h0m0s
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursActive(t *testing.T) {
+	at := func(clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return t
+	}
+	tests := []struct {
+		hours  QuietHours
+		clock  string
+		active bool
+	}{
+		{QuietHours{Start: "22:00", End: "07:00"}, "23:30", true},
+		{QuietHours{Start: "22:00", End: "07:00"}, "03:00", true},
+		{QuietHours{Start: "22:00", End: "07:00"}, "07:00", false},
+		{QuietHours{Start: "22:00", End: "07:00"}, "12:00", false},
+		{QuietHours{Start: "13:00", End: "15:00"}, "13:00", true},
+		{QuietHours{Start: "13:00", End: "15:00"}, "16:00", false},
+	}
+	for _, tt := range tests {
+		if got := tt.hours.active(at(tt.clock), time.UTC); got != tt.active {
+			t.Errorf("%s-%s at %s: expected active %v, got %v", tt.hours.Start, tt.hours.End, tt.clock, tt.active, got)
+		}
+	}
+
+	if err := (QuietHours{Start: "10pm", End: "07:00"}).validate(); err == nil {
+		t.Error("expected an error for an invalid start")
+	}
+	if err := (QuietHours{Start: "22:00", End: "07:00", MaxBrightness: 120}).validate(); err == nil {
+		t.Error("expected an error for a cap above 100")
+	}
+}
+
+func TestQuietGate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// sent are the brightness values and effects sent, in order
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PUT":
+			var payload map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if brightness, ok := payload["brightness"].(map[string]interface{}); ok {
+				sent = append(sent, fmt.Sprint(brightness["value"]))
+			}
+			if effect, ok := payload["select"]; ok {
+				sent = append(sent, fmt.Sprint(effect))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/v1/test-token/state/brightness":
+			w.Write([]byte(`{"value":80}`))
+		case r.URL.Path == "/api/v1/test-token/effects/select":
+			w.Write([]byte(`"Flames"`))
+		default:
+			w.Write([]byte(`{"value":true}`))
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	ctx := context.Background()
+
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.Local)
+	config := Config{QuietHours: &QuietHours{Start: "22:00", End: "07:00"}}
+	gate, err := newQuietGate(config, false)
+	if err != nil {
+		t.Fatalf("newQuietGate should not fail: %v", err)
+	}
+	gate.now = func() time.Time { return now }
+	if !gate.held() {
+		t.Error("changes should be held during quiet hours without a cap")
+	}
+	if gate, _ := newQuietGate(config, true); gate.held() {
+		t.Error("the override should never hold changes")
+	}
+
+	config.QuietHours.MaxBrightness = 20
+	gate, _ = newQuietGate(config, false)
+	gate.now = func() time.Time { return now }
+	if gate.held() {
+		t.Error("changes should go through with a brightness cap")
+	}
+	for i := 0; i < 2; i++ {
+		if err := gate.settle(ctx, device); err != nil {
+			t.Fatalf("settle should not fail: %v", err)
+		}
+	}
+	if fmt.Sprint(sent) != "[20]" {
+		t.Errorf("expected the cap once on entering the window, got %v", sent)
+	}
+
+	// Changes never send more than the cap, and one that keeps the
+	// brightness lowers it first, since the device reports 80
+	sent = nil
+	if err := device.SetBrightness(ctx, 90); err != nil {
+		t.Fatal(err)
+	}
+	if err := device.SelectEffect(ctx, "Aurora"); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(sent) != "[20 20 Aurora]" {
+		t.Errorf("expected nothing above the cap to be sent, got %v", sent)
+	}
+
+	sent = nil
+	now = now.Add(9 * time.Hour)
+	gate.settle(ctx, device)
+	if err := device.SetBrightness(ctx, 90); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(sent) != "[90]" {
+		t.Errorf("brightness should not be capped outside quiet hours, got %v", sent)
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		10 * time.Minute:          "10m",
+		time.Hour:                 "1h",
+		90 * time.Minute:          "1h30m",
+		45 * time.Second:          "45s",
+		2*time.Hour + time.Second: "2h0m1s",
+	}
+	for d, want := range tests {
+		if got := shortDuration(d); got != want {
+			t.Errorf("shortDuration(%s) = %q, expected %q", d, got, want)
+		}
+	}
+}
+
+func TestQuietGateStringZone(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	gate := &quietGate{hours: QuietHours{Start: "22:00", End: "07:00"}, loc: loc, priority: prioritySchedule, hold: time.Hour}
+	if got := gate.String(); !strings.Contains(got, "quiet from 22:00 to 07:00 Europe/Berlin time") {
+		t.Errorf("expected the zone in %q", got)
+	}
+	gate.hours.MaxBrightness = 20
+	if got := gate.String(); !strings.Contains(got, "capped at 20% from 22:00 to 07:00 Europe/Berlin time") {
+		t.Errorf("expected the zone in %q", got)
+	}
+}
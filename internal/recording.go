@@ -0,0 +1,379 @@
+package internal
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A recording is a gzipped stream of frames. After a magic header, each
+// frame holds its offset from the previous frame in milliseconds, the panel
+// count and the panel ID and RGB of each panel, all numbers as uvarints.
+var recordingMagic = []byte("NLREC\x01")
+
+// recordedFrame is one frame of a recording, at its offset from the start
+type recordedFrame struct {
+	at     time.Duration
+	colors []PanelColor
+}
+
+type recording struct {
+	frames []recordedFrame
+}
+
+// length is how long the recording plays: up to the last frame plus the
+// gap before it, so a loop keeps the pace
+func (r recording) length() time.Duration {
+	n := len(r.frames)
+	switch n {
+	case 0:
+		return 0
+	case 1:
+		return time.Second
+	}
+	last := r.frames[n-1].at
+	return last + max(time.Millisecond, last-r.frames[n-2].at)
+}
+
+// frameAt returns the frame showing at t
+func (r recording) frameAt(t time.Duration) []PanelColor {
+	i := sort.Search(len(r.frames), func(i int) bool { return r.frames[i].at > t })
+	return r.frames[max(0, i-1)].colors
+}
+
+// frameRecorder writes frames to a recording file as they are streamed
+type frameRecorder struct {
+	file   *os.File
+	gz     *gzip.Writer
+	w      *bufio.Writer
+	last   time.Duration
+	frames int
+	err    error
+	// buf is reused to encode each frame
+	buf []byte
+}
+
+func createRecording(path string) (*frameRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(file)
+	r := &frameRecorder{file: file, gz: gz, w: bufio.NewWriter(gz)}
+	if _, err := r.w.Write(recordingMagic); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// record appends a frame shown at t. The first error is kept for Close.
+func (r *frameRecorder) record(t time.Duration, colors []PanelColor) {
+	if r.err != nil {
+		return
+	}
+	// Offsets are whole milliseconds; the rest carries over to the next
+	// frame so they do not drift
+	delta := max(0, t-r.last) / time.Millisecond
+	r.last += delta * time.Millisecond
+	buf := binary.AppendUvarint(r.buf[:0], uint64(delta))
+	buf = binary.AppendUvarint(buf, uint64(len(colors)))
+	for _, c := range colors {
+		buf = binary.AppendUvarint(buf, uint64(c.PanelID))
+		buf = append(buf, c.R, c.G, c.B)
+	}
+	_, r.err = r.w.Write(buf)
+	r.buf = buf
+	r.frames++
+}
+
+func (r *frameRecorder) Close() error {
+	err := r.err
+	for _, step := range []func() error{r.w.Flush, r.gz.Close, r.file.Close} {
+		if stepErr := step(); err == nil {
+			err = stepErr
+		}
+	}
+	return err
+}
+
+// recordingGenerator records the frames of the generator it wraps
+type recordingGenerator struct {
+	generator EffectGenerator
+	recorder  *frameRecorder
+}
+
+func (g recordingGenerator) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g recordingGenerator) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	frame := nextFrame(g.generator, dst, layout, t)
+	g.recorder.record(t, frame)
+	return frame
+}
+
+func readRecording(path string) (recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return recording{}, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return recording{}, fmt.Errorf("%s is not a recording: %w", path, err)
+	}
+	return decodeRecording(bufio.NewReader(gz))
+}
+
+func decodeRecording(r *bufio.Reader) (recording, error) {
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != string(recordingMagic) {
+		return recording{}, fmt.Errorf("not a recording or an unsupported version")
+	}
+
+	var rec recording
+	var at time.Duration
+	for {
+		delta, err := binary.ReadUvarint(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		count, err2 := binary.ReadUvarint(r)
+		if err != nil || err2 != nil || count > 4096 {
+			return rec, fmt.Errorf("recording is truncated or corrupt after %d frame(s)", len(rec.frames))
+		}
+		at += time.Duration(delta) * time.Millisecond
+
+		frame := recordedFrame{at: at, colors: make([]PanelColor, count)}
+		for i := range frame.colors {
+			id, err := binary.ReadUvarint(r)
+			var rgb [3]byte
+			if err == nil {
+				_, err = io.ReadFull(r, rgb[:])
+			}
+			if err != nil {
+				return rec, fmt.Errorf("recording is truncated or corrupt after %d frame(s)", len(rec.frames))
+			}
+			frame.colors[i] = PanelColor{PanelID: int(id), R: rgb[0], G: rgb[1], B: rgb[2]}
+		}
+		rec.frames = append(rec.frames, frame)
+	}
+	if len(rec.frames) == 0 {
+		return rec, fmt.Errorf("recording has no frames")
+	}
+	return rec, nil
+}
+
+// recordingPlayer plays a recording back as a generator, once or looping,
+// at speed times the recorded pace
+type recordingPlayer struct {
+	rec   recording
+	loop  bool
+	speed float64
+}
+
+func (p recordingPlayer) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	t = time.Duration(float64(t) * p.speed)
+	if p.loop {
+		t %= p.rec.length()
+	}
+	frame := p.rec.frameAt(t)
+	return append([]PanelColor(nil), frame...)
+}
+
+// matchingPanels counts the recorded panels that are in layout, to catch
+// recordings made on another device
+func (r recording) matchingPanels(layout Layout) int {
+	ids := make(map[int]bool)
+	for _, panel := range layout.LightPanels() {
+		ids[panel.ID] = true
+	}
+	matched := 0
+	for _, c := range r.frames[0].colors {
+		if ids[c.PanelID] {
+			matched++
+		}
+	}
+	return matched
+}
+
+// The controller keeps every frame of a custom effect in memory, but
+// Nanoleaf documents no limit on their size. These defaults are kept small
+// to be safe rather than taken from a known limit, and --frames and
+// --max-size change them. Frames are at least a tenth of a second apart,
+// the resolution of transition times.
+const (
+	defaultCustomFrames   = 120
+	defaultCustomAnimData = 32 * 1024
+	customFrameStep       = 100 * time.Millisecond
+)
+
+// customEffect downsamples the recording into a looping custom effect for
+// the panels of layout, with at most maxFrames frames and maxSize
+// characters of animation data, 0 for the defaults. It returns the effect
+// and the number of frames kept.
+func (r recording) customEffect(layout Layout, maxFrames, maxSize int) (map[string]interface{}, int, error) {
+	panels := layout.LightPanels()
+	if len(panels) == 0 {
+		return nil, 0, fmt.Errorf("device layout has no light panels")
+	}
+	if maxFrames <= 0 {
+		maxFrames = defaultCustomFrames
+	}
+	if maxSize <= 0 {
+		maxSize = defaultCustomAnimData
+	}
+
+	length := r.length()
+	frames := max(1, min(len(r.frames), maxFrames, int(length/customFrameStep)))
+	for ; frames > 0; frames = frames * 9 / 10 {
+		animData := r.customAnimData(panels, frames, length)
+		if len(animData) <= maxSize {
+			return map[string]interface{}{
+				"animType": "custom",
+				"animData": animData,
+				"loop":     true,
+				"palette":  []paletteColor{},
+			}, frames, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("%d panels are too many for a custom effect of %d characters, raise --max-size", len(panels), maxSize)
+}
+
+// customAnimData samples the recording at frames evenly spaced times over
+// length, in the custom effect format: the panel count, then for each panel its ID, frame
+// count and R, G, B, W and transition time of each frame
+func (r recording) customAnimData(panels []Panel, frames int, length time.Duration) string {
+	step := length / time.Duration(frames)
+	transition := max(1, int((step+customFrameStep/2)/customFrameStep))
+
+	samples := make([]map[int]PanelColor, frames)
+	for i := range samples {
+		samples[i] = make(map[int]PanelColor)
+		for _, c := range r.frameAt(time.Duration(i) * step) {
+			samples[i][c.PanelID] = c
+		}
+	}
+
+	var animData strings.Builder
+	fmt.Fprintf(&animData, "%d", len(panels))
+	for _, p := range panels {
+		fmt.Fprintf(&animData, " %d %d", p.ID, frames)
+		for _, sample := range samples {
+			c := sample[p.ID]
+			fmt.Fprintf(&animData, " %d %d %d 0 %d", c.R, c.G, c.B, transition)
+		}
+	}
+	return animData.String()
+}
+
+// startRecording wraps generator to record it to path, when path is set.
+// The returned stop closes the file and reports how it went.
+func startRecording(generator EffectGenerator, path string) (EffectGenerator, func() error, error) {
+	if path == "" {
+		return generator, func() error { return nil }, nil
+	}
+	recorder, err := createRecording(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	stop := func() error {
+		if err := recorder.Close(); err != nil {
+			return fmt.Errorf("recording failed: %w", err)
+		}
+		statusf("Recorded %d frame(s) to %s\n", recorder.frames, path)
+		return nil
+	}
+	return recordingGenerator{generator: generator, recorder: recorder}, stop, nil
+}
+
+func runReplay(ctx context.Context, args []string) error {
+	fs := newFlagSet("replay")
+	loop := fs.Bool("loop", false, "play the recording over and over until ctrl+c")
+	speed := fs.Float64("speed", 1, "playback speed multiplier")
+	fps := fs.Int("fps", 20, "frames per second")
+	save := fs.String("save", "", "turn the recording into an effect on the device under this name instead of streaming it")
+	frames := fs.Int("frames", defaultCustomFrames, "most frames to keep with --save")
+	maxSize := fs.Int("max-size", defaultCustomAnimData, "most characters of animation data with --save")
+	onExit := streamExitFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if fs.NArg() != 1 {
+		return usageError("replay [--loop] [--speed 1] [--fps 20] [--on-exit restore] [--save NAME [--frames N] [--max-size N]] FILE")
+	}
+	if *speed <= 0 || *fps < 1 || *fps > 60 {
+		return invalidArgs(fmt.Errorf("--speed must be positive and --fps between 1 and 60"))
+	}
+	if *frames < 1 || *maxSize < 1 {
+		return invalidArgs(fmt.Errorf("--frames and --max-size must be above 0"))
+	}
+
+	rec, err := readRecording(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	layoutCtx, cancel := device.createContext()
+	layout, err := device.GetLayout(layoutCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if rec.matchingPanels(layout) == 0 {
+		return fmt.Errorf("none of the recorded panels are on %s, it was recorded on another device", device.GetDeviceName())
+	}
+	if *save != "" {
+		return saveRecordingEffect(ctx, device, rec, layout, *save, *frames, *maxSize)
+	}
+
+	length := time.Duration(float64(rec.length()) / *speed)
+	if !*loop {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, length)
+		defer cancel()
+		statusf("Replaying %s (%s) on %s (ctrl+c to stop)\n", fs.Arg(0), length.Round(time.Second/10), device.GetDeviceName())
+	} else {
+		statusf("Looping %s (%s) on %s (ctrl+c to stop)\n", fs.Arg(0), length.Round(time.Second/10), device.GetDeviceName())
+	}
+	return streamWithExit(ctx, device, recordingPlayer{rec: rec, loop: *loop, speed: *speed}, newFrameScheduler(*fps), *onExit)
+}
+
+// saveRecordingEffect installs the recording as a custom effect and selects
+// it, so it keeps playing without this computer
+func saveRecordingEffect(ctx context.Context, device *Device, rec recording, layout Layout, name string, maxFrames, maxSize int) error {
+	effect, frames, err := rec.customEffect(layout, maxFrames, maxSize)
+	if err != nil {
+		return err
+	}
+	effect["animName"] = name
+	if err := device.AddEffect(ctx, effect); err != nil {
+		var statusErr *updateStatusError
+		if errors.As(err, &statusErr) {
+			return fmt.Errorf("%w (the device may not take an effect this large, try fewer --frames or a smaller --max-size)", err)
+		}
+		return err
+	}
+	if err := device.SelectEffect(ctx, name); err != nil {
+		return err
+	}
+	if frames < len(rec.frames) {
+		statusf("Kept %d of %d frame(s) to fit the device\n", frames, len(rec.frames))
+	}
+	statusf("Saved and selected %q\n", name)
+	return nil
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.nlrec")
+	recorder, err := createRecording(path)
+	if err != nil {
+		t.Fatalf("createRecording should not fail: %v", err)
+	}
+	frames := [][]PanelColor{
+		{{PanelID: 5, R: 255}, {PanelID: 300, G: 255}},
+		{{PanelID: 5, B: 255}, {PanelID: 300, R: 10, G: 20, B: 30}},
+		{{PanelID: 5, R: 1, G: 2, B: 3}, {PanelID: 300}},
+	}
+	generator := recordingGenerator{generator: replayFrames(frames), recorder: recorder}
+	for i := range frames {
+		generator.NextFrame(Layout{}, time.Duration(i)*50*time.Millisecond+300*time.Microsecond)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close should not fail: %v", err)
+	}
+
+	rec, err := readRecording(path)
+	if err != nil {
+		t.Fatalf("readRecording should not fail: %v", err)
+	}
+	if len(rec.frames) != 3 || rec.frames[2].at != 100*time.Millisecond {
+		t.Fatalf("unexpected frames %+v", rec.frames)
+	}
+	if rec.frames[1].colors[1] != frames[1][1] {
+		t.Errorf("expected %+v, got %+v", frames[1][1], rec.frames[1].colors[1])
+	}
+	if rec.length() != 150*time.Millisecond {
+		t.Errorf("expected a length of 150ms, got %s", rec.length())
+	}
+
+	once := recordingPlayer{rec: rec, speed: 1}
+	if got := once.NextFrame(Layout{}, 75*time.Millisecond); got[0] != frames[1][0] {
+		t.Errorf("expected the second frame at 75ms, got %+v", got)
+	}
+	if got := once.NextFrame(Layout{}, time.Second); got[0] != frames[2][0] {
+		t.Errorf("expected the last frame to stay after the end, got %+v", got)
+	}
+	looped := recordingPlayer{rec: rec, loop: true, speed: 2}
+	if got := looped.NextFrame(Layout{}, 80*time.Millisecond); got[0] != frames[0][0] {
+		t.Errorf("expected the loop to start over at 160ms, got %+v", got)
+	}
+}
+
+func TestReadRecordingRejectsOtherFiles(t *testing.T) {
+	if _, err := readRecording(filepath.Join("testdata", "missing.nlrec")); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+
+	path := filepath.Join(t.TempDir(), "empty.nlrec")
+	recorder, err := createRecording(path)
+	if err != nil {
+		t.Fatalf("createRecording should not fail: %v", err)
+	}
+	recorder.Close()
+	if _, err := readRecording(path); err == nil {
+		t.Error("expected a recording without frames to fail")
+	}
+}
+
+func TestRecordingMatchingPanels(t *testing.T) {
+	rec := recording{frames: []recordedFrame{{colors: []PanelColor{{PanelID: 5}, {PanelID: 6}}}}}
+	layout := Layout{Panels: []Panel{{ID: 5, ShapeType: 7}, {ID: 9, ShapeType: 7}}}
+	if got := rec.matchingPanels(layout); got != 1 {
+		t.Errorf("expected 1 matching panel, got %d", got)
+	}
+}
+
+// replayFrames is a generator returning the given frames in turn
+type replayFrames [][]PanelColor
+
+func (f replayFrames) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return f[int(t/(50*time.Millisecond))%len(f)]
+}
+
+func TestRecordingCustomEffect(t *testing.T) {
+	// Two seconds at 20 fps, red for the first second and blue after
+	var rec recording
+	for i := 0; i < 40; i++ {
+		color := PanelColor{PanelID: 5, R: 255}
+		if i >= 20 {
+			color = PanelColor{PanelID: 5, B: 255}
+		}
+		rec.frames = append(rec.frames, recordedFrame{at: time.Duration(i) * 50 * time.Millisecond, colors: []PanelColor{color}})
+	}
+	layout := Layout{Panels: []Panel{{ID: 5, ShapeType: 7}, {ID: 6, ShapeType: 7}}}
+
+	effect, frames, err := rec.customEffect(layout, 0, 0)
+	if err != nil {
+		t.Fatalf("customEffect should not fail: %v", err)
+	}
+	if frames != 20 {
+		t.Errorf("expected frames a tenth of a second apart, 20 in total, got %d", frames)
+	}
+	if effect["animType"] != "custom" || effect["loop"] != true {
+		t.Errorf("expected a looping custom effect, got %v", effect)
+	}
+
+	effect, frames, err = rec.customEffect(layout, 2, 0)
+	if err != nil || frames != 2 {
+		t.Fatalf("expected 2 frames, got %d, %v", frames, err)
+	}
+	expected := "2 5 2 255 0 0 0 10 0 0 255 0 10 6 2 0 0 0 0 10 0 0 0 0 10"
+	if effect["animData"] != expected {
+		t.Errorf("expected %q, got %q", expected, effect["animData"])
+	}
+
+	big := Layout{}
+	for id := 1; id <= 200; id++ {
+		big.Panels = append(big.Panels, Panel{ID: id, ShapeType: 7})
+	}
+	effect, frames, err = rec.customEffect(big, 0, 0)
+	if err != nil {
+		t.Fatalf("customEffect should not fail: %v", err)
+	}
+	if frames >= 20 || len(effect["animData"].(string)) > defaultCustomAnimData {
+		t.Errorf("expected fewer frames to fit the device, got %d frames in %d characters", frames, len(effect["animData"].(string)))
+	}
+	effect, frames, err = rec.customEffect(big, 0, 1<<20)
+	if err != nil || frames != 20 {
+		t.Errorf("expected a larger --max-size to keep every frame, got %d, %v", frames, err)
+	}
+	if _, _, err := rec.customEffect(big, 0, 10); err == nil {
+		t.Error("expected an error when no frame fits")
+	}
+}
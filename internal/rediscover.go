@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultRediscoveryInterval is how often serve scans for devices unless
+// told otherwise
+const defaultRediscoveryInterval = 5 * time.Minute
+
+// maxDiscoveryEvents bounds the events kept for GET /discovery
+const maxDiscoveryEvents = 50
+
+// RediscoveryConfig has serve scan the network every Interval and keep the
+// device registry current. A paired device that shows up at a new address
+// has its pairing moved along. Every change is logged and, when Webhook is
+// set, posted to it as JSON.
+type RediscoveryConfig struct {
+	Interval string `json:"interval,omitempty"`
+	Webhook  string `json:"webhook,omitempty"`
+}
+
+// discoveryEvent is a change rediscovery noticed: added for a device not
+// seen before, moved for a paired device at a new address, offline for a
+// device that stopped answering and online for one that is back
+type discoveryEvent struct {
+	Event string    `json:"event"`
+	IP    string    `json:"ip"`
+	From  string    `json:"from,omitempty"`
+	Name  string    `json:"name,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+func (e discoveryEvent) String() string {
+	label := e.IP
+	if e.Name != "" {
+		label = fmt.Sprintf("%s (%s)", e.Name, e.IP)
+	}
+	if e.Event == "moved" {
+		return fmt.Sprintf("Device %s moved from %s", label, e.From)
+	}
+	return fmt.Sprintf("Device %s %s", label, e.Event)
+}
+
+// discoverDevices finds the devices on the network. Tests replace it.
+var discoverDevices = func(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return scanForDevices(ctx, scanOptions{})
+}
+
+// rediscoverer scans for devices every interval
+type rediscoverer struct {
+	mu       sync.Mutex
+	device   *Device
+	interval time.Duration
+	webhook  string
+	enabled  bool
+	// online are the devices that answered the last scan, nil before the
+	// first, which only takes stock rather than report every device
+	online map[string]bool
+	events []discoveryEvent
+	now    func() time.Time
+	// notify, when set, is told about the events
+	notify func(title, text string)
+}
+
+func newRediscoverer(device *Device, settings *RediscoveryConfig) (*rediscoverer, error) {
+	r := &rediscoverer{device: device, interval: defaultRediscoveryInterval, now: time.Now}
+	if settings == nil {
+		return r, nil
+	}
+	r.enabled, r.webhook = true, settings.Webhook
+	if settings.Interval != "" {
+		interval, err := time.ParseDuration(settings.Interval)
+		if err != nil || interval < time.Minute {
+			return nil, fmt.Errorf("rediscovery.interval: invalid duration %q, at least 1m, e.g. 5m", settings.Interval)
+		}
+		r.interval = interval
+	}
+	if r.webhook != "" {
+		if _, err := http.NewRequest(http.MethodPost, r.webhook, nil); err != nil {
+			return nil, fmt.Errorf("rediscovery.webhook: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// setSettings applies changed settings
+func (r *rediscoverer) setSettings(settings *RediscoveryConfig) error {
+	next, err := newRediscoverer(r.device, settings)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interval, r.webhook, r.enabled = next.interval, next.webhook, next.enabled
+	return nil
+}
+
+// recent returns the latest events, oldest first
+func (r *rediscoverer) recent() []discoveryEvent {
+	if r == nil {
+		return []discoveryEvent{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]discoveryEvent{}, r.events...)
+}
+
+// round scans once, updates the registry and the pairings, and reports
+// what changed
+func (r *rediscoverer) round(ctx context.Context) ([]discoveryEvent, error) {
+	found, err := discoverDevices(ctx)
+	if err != nil && len(found) == 0 {
+		return nil, err
+	}
+	registry, err := LoadDeviceRegistry()
+	if err != nil {
+		registry = NewDeviceRegistry()
+	}
+	config := r.device.GetConfig()
+	now := r.now()
+
+	r.mu.Lock()
+	previous := r.online
+	r.mu.Unlock()
+	online := make(map[string]bool)
+	for _, ip := range found {
+		online[ip] = true
+	}
+	known := make(map[string]bool)
+	for _, record := range registry.Devices() {
+		known[record.IP] = true
+	}
+
+	var events []discoveryEvent
+	moved := make(map[string]bool)
+	for _, ip := range found {
+		if known[ip] {
+			continue
+		}
+		// A paired device that stopped answering and took this address
+		// still accepts its token here
+		from := ""
+		for pairedIP, paired := range pairedTokens(config) {
+			if online[pairedIP] || moved[pairedIP] {
+				continue
+			}
+			infoCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			_, infoErr := r.device.client.getInfo(infoCtx, ip, paired)
+			cancel()
+			if infoErr == nil {
+				from = pairedIP
+				break
+			}
+		}
+		if from == "" {
+			events = append(events, discoveryEvent{Event: "added", IP: ip, Time: now})
+			continue
+		}
+		moved[from] = true
+		registry.Move(from, ip)
+		if err := r.device.movePaired(from, ip); err != nil {
+			return events, fmt.Errorf("failed to move the pairing of %s to %s: %w", from, ip, err)
+		}
+		events = append(events, discoveryEvent{Event: "moved", IP: ip, From: from, Name: config.Devices[from].Name, Time: now})
+	}
+
+	for _, ip := range found {
+		registry.Seen(ip, now)
+		if previous != nil && known[ip] && !previous[ip] {
+			events = append(events, discoveryEvent{Event: "online", IP: ip, Name: config.Devices[ip].Name, Time: now})
+		}
+	}
+	for ip := range known {
+		if online[ip] || moved[ip] {
+			continue
+		}
+		registry.MarkOffline(ip)
+		if previous[ip] {
+			events = append(events, discoveryEvent{Event: "offline", IP: ip, Name: config.Devices[ip].Name, Time: now})
+		}
+	}
+
+	r.mu.Lock()
+	r.online = online
+	r.events = append(r.events, events...)
+	if len(r.events) > maxDiscoveryEvents {
+		r.events = r.events[len(r.events)-maxDiscoveryEvents:]
+	}
+	r.mu.Unlock()
+	return events, registry.Save()
+}
+
+// pairedTokens returns the token of every paired device by IP
+func pairedTokens(config Config) map[string]string {
+	tokens := make(map[string]string)
+	for ip, paired := range config.Devices {
+		if paired.Token != "" {
+			tokens[ip] = paired.Token
+		}
+	}
+	if config.IP != "" && config.Token != "" {
+		tokens[config.IP] = config.Token
+	}
+	return tokens
+}
+
+// post sends an event to the webhook
+func (r *rediscoverer) post(ctx context.Context, webhook string, event discoveryEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// run scans every interval until ctx is done, while rediscovery is enabled
+func (r *rediscoverer) run(ctx context.Context) {
+	for {
+		r.mu.Lock()
+		enabled, interval, webhook := r.enabled, r.interval, r.webhook
+		r.mu.Unlock()
+		if enabled {
+			events, err := r.round(ctx)
+			if err != nil && ctx.Err() == nil {
+				errorf("Rediscovery failed: %v\n", err)
+			}
+			for _, event := range events {
+				statusf("%s\n", event)
+				if r.notify != nil {
+					r.notify("nanoleaf-go", event.String())
+				}
+				if webhook == "" {
+					continue
+				}
+				if err := r.post(ctx, webhook, event); err != nil && ctx.Err() == nil {
+					errorf("Rediscovery webhook failed: %v\n", err)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRediscovery(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// moved accepts the token of the paired device, added is a device
+	// paired with nothing here
+	moved := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"name": "Shapes"}`))
+	}))
+	defer moved.Close()
+	added := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer added.Close()
+
+	device := NewDevice()
+	if err := device.savePaired("10.0.0.5", "tok", true); err != nil {
+		t.Fatalf("savePaired should not fail: %v", err)
+	}
+	if err := device.Rename("10.0.0.5", "Office"); err != nil {
+		t.Fatalf("Rename should not fail: %v", err)
+	}
+	registry := NewDeviceRegistry()
+	registry.Seen("10.0.0.5", time.Now())
+	registry.Seen("10.0.0.6", time.Now())
+	registry.SetModel("10.0.0.5", "Shapes", "9.2.4")
+	if err := registry.Save(); err != nil {
+		t.Fatalf("Save should not fail: %v", err)
+	}
+
+	var found []string
+	original := discoverDevices
+	discoverDevices = func(ctx context.Context) ([]string, error) { return found, nil }
+	defer func() { discoverDevices = original }()
+
+	r, err := newRediscoverer(device, &RediscoveryConfig{})
+	if err != nil {
+		t.Fatalf("newRediscoverer should not fail: %v", err)
+	}
+	round := func() []string {
+		events, err := r.round(context.Background())
+		if err != nil {
+			t.Fatalf("round should not fail: %v", err)
+		}
+		var got []string
+		for _, event := range events {
+			got = append(got, event.Event+" "+event.IP+" "+event.From)
+		}
+		return got
+	}
+
+	found = []string{moved.URL, added.URL, "10.0.0.6"}
+	got := round()
+	want := []string{"moved " + moved.URL + " 10.0.0.5", "added " + added.URL + " "}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	config := device.GetConfig()
+	if config.IP != moved.URL || config.Devices[moved.URL].Name != "Office" || config.Devices[moved.URL].Token != "tok" {
+		t.Errorf("expected the pairing to move along, got %+v", config)
+	}
+	if _, ok := config.Devices["10.0.0.5"]; ok {
+		t.Error("expected the old address to be forgotten")
+	}
+	saved, _ := LoadDeviceRegistry()
+	if record, ok := saved.Get(moved.URL); !ok || record.Model != "Shapes" {
+		t.Errorf("expected the registry record to move along, got %+v", record)
+	}
+	if _, ok := saved.Get("10.0.0.5"); ok {
+		t.Error("expected the old registry record to be gone")
+	}
+
+	found = []string{moved.URL, added.URL}
+	if got := round(); len(got) != 1 || got[0] != "offline 10.0.0.6 " {
+		t.Errorf("expected 10.0.0.6 to go offline, got %v", got)
+	}
+	found = []string{moved.URL, added.URL, "10.0.0.6"}
+	if got := round(); len(got) != 1 || got[0] != "online 10.0.0.6 " {
+		t.Errorf("expected 10.0.0.6 to be back, got %v", got)
+	}
+	if len(r.recent()) != 4 {
+		t.Errorf("expected the 4 events to be kept, got %v", r.recent())
+	}
+
+	var posted discoveryEvent
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&posted)
+	}))
+	defer webhook.Close()
+	if err := r.post(context.Background(), webhook.URL, discoveryEvent{Event: "offline", IP: "10.0.0.6"}); err != nil {
+		t.Fatalf("post should not fail: %v", err)
+	}
+	if posted.Event != "offline" || posted.IP != "10.0.0.6" {
+		t.Errorf("expected the event to be posted, got %+v", posted)
+	}
+
+	if _, err := newRediscoverer(nil, &RediscoveryConfig{Interval: "10s"}); err == nil {
+		t.Error("expected an interval under a minute to be rejected")
+	}
+}
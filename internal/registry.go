@@ -0,0 +1,287 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeviceRecord is what is known about a device seen on the network
+type DeviceRecord struct {
+	IP       string    `json:"ip"`
+	Name     string    `json:"name,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Firmware string    `json:"firmware,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+	Online   bool      `json:"-"`
+	// Latency is the round trip of the last health check
+	Latency time.Duration `json:"-"`
+}
+
+// Status describes reachability, e.g. "online" or "last seen 3h ago"
+func (d DeviceRecord) Status(now time.Time) string {
+	switch {
+	case d.Online:
+		return "online"
+	case d.LastSeen.IsZero():
+		return "offline"
+	}
+
+	ago := now.Sub(d.LastSeen)
+	switch {
+	case ago < time.Minute:
+		return "last seen just now"
+	case ago < time.Hour:
+		return fmt.Sprintf("last seen %dm ago", int(ago.Minutes()))
+	case ago < 48*time.Hour:
+		return fmt.Sprintf("last seen %dh ago", int(ago.Hours()))
+	default:
+		return fmt.Sprintf("last seen %dd ago", int(ago.Hours()/24))
+	}
+}
+
+// DeviceRegistry tracks every known device and whether it is reachable. It
+// is safe for concurrent use and is persisted next to the config so the CLI
+// and the UI share one view of the network.
+type DeviceRegistry struct {
+	mu      sync.Mutex
+	devices map[string]*DeviceRecord
+	// moved are the addresses Move took records from, so Save does not
+	// bring them back from the file
+	moved map[string]bool
+}
+
+func NewDeviceRegistry() *DeviceRegistry {
+	return &DeviceRegistry{devices: make(map[string]*DeviceRecord), moved: make(map[string]bool)}
+}
+
+func getRegistryPath() string {
+	return profilePath(".nanoleaf_devices", ".json")
+}
+
+// LoadDeviceRegistry reads the saved registry, returning an empty one when
+// nothing has been saved yet
+func LoadDeviceRegistry() (*DeviceRegistry, error) {
+	registry := NewDeviceRegistry()
+	data, err := os.ReadFile(getRegistryPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return registry, err
+	}
+
+	records, err := parseRegistry(data)
+	if err != nil {
+		return registry, err
+	}
+	for i := range records {
+		registry.devices[records[i].IP] = &records[i]
+	}
+	return registry, nil
+}
+
+func parseRegistry(data []byte) ([]DeviceRecord, error) {
+	var records []DeviceRecord
+	err := json.Unmarshal(data, &records)
+	return records, err
+}
+
+// Save writes the registry to disk. The UI, serve and scan each keep a
+// registry of their own, so the records saved by another process since
+// this one was loaded are kept rather than overwritten: devices only they
+// know are added, and the later LastSeen of the two wins. The file is
+// written in one step under the config lock.
+func (r *DeviceRegistry) Save() error {
+	return withConfigLock(true, func() error {
+		data, _ := os.ReadFile(getRegistryPath())
+		// A damaged file is replaced by this registry
+		saved, _ := parseRegistry(data)
+
+		r.mu.Lock()
+		for _, record := range saved {
+			if r.moved[record.IP] {
+				continue
+			}
+			current, ok := r.devices[record.IP]
+			if !ok {
+				copied := record
+				r.devices[record.IP] = &copied
+				continue
+			}
+			if record.LastSeen.After(current.LastSeen) {
+				current.LastSeen = record.LastSeen
+			}
+		}
+		r.mu.Unlock()
+
+		data, err := json.MarshalIndent(r.Devices(), "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(getRegistryPath(), data, 0600)
+	})
+}
+
+// Seen records ip as online at the given time, adding it if it is new
+func (r *DeviceRegistry) Seen(ip string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record := r.record(ip)
+	record.Online = true
+	record.LastSeen = at
+}
+
+// MarkOffline records that ip did not respond, adding it if it is new
+func (r *DeviceRegistry) MarkOffline(ip string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.record(ip).Online = false
+}
+
+// SetLatency records the round trip time of a health check of ip
+func (r *DeviceRegistry) SetLatency(ip string, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.record(ip).Latency = latency
+}
+
+// SetModel records the model name and firmware reported by ip
+func (r *DeviceRegistry) SetModel(ip, model, firmware string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record := r.record(ip)
+	record.Model = model
+	record.Firmware = firmware
+}
+
+// Move gives the record of from, a device that got a new address, to to
+func (r *DeviceRegistry) Move(from, to string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.devices[from]
+	if !ok {
+		return
+	}
+	delete(r.devices, from)
+	r.moved[from] = true
+	record.IP = to
+	r.devices[to] = record
+}
+
+// Get returns the record for ip
+func (r *DeviceRegistry) Get(ip string) (DeviceRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.devices[ip]
+	if !ok {
+		return DeviceRecord{}, false
+	}
+	return *record, true
+}
+
+// Devices returns a snapshot of all records sorted by IP
+func (r *DeviceRegistry) Devices() []DeviceRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := make([]DeviceRecord, 0, len(r.devices))
+	for _, record := range r.devices {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].IP < records[j].IP })
+	return records
+}
+
+// Refresh probes every known device and updates its reachability
+func (r *DeviceRegistry) Refresh(ctx context.Context) error {
+	var ips []string
+	for _, record := range r.Devices() {
+		ips = append(ips, record.IP)
+	}
+
+	online, err := probeHosts(ctx, ips, nil)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	reachable := make(map[string]bool)
+	for _, ip := range online {
+		reachable[ip] = true
+		r.Seen(ip, now)
+	}
+	for _, ip := range ips {
+		if !reachable[ip] {
+			r.MarkOffline(ip)
+		}
+	}
+	return nil
+}
+
+// record returns the entry for ip, creating it if needed. r.mu must be held.
+func (r *DeviceRegistry) record(ip string) *DeviceRecord {
+	record, ok := r.devices[ip]
+	if !ok {
+		record = &DeviceRecord{IP: ip}
+		r.devices[ip] = record
+		delete(r.moved, ip)
+	}
+	return record
+}
+
+func runDevices(ctx context.Context, args []string) error {
+	fs := newFlagSet("devices")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+
+	registry, err := LoadDeviceRegistry()
+	if err != nil {
+		return err
+	}
+	if len(registry.Devices()) == 0 {
+		return fmt.Errorf("no known devices, run scan first")
+	}
+	if err := registry.Refresh(ctx); err != nil {
+		return err
+	}
+
+	// Names are optional, devices are still listed before anything is paired
+	config, _ := loadConfig()
+	now := time.Now()
+	for _, record := range registry.Devices() {
+		name := config.Devices[record.IP].Name
+		fmt.Printf("%-16s %-24s %-14s %s\n", record.IP, name, record.Model, record.Status(now))
+	}
+	return registry.Save()
+}
+
+func runRename(ctx context.Context, args []string) error {
+	fs := newFlagSet("rename")
+	ip := fs.String("ip", "", "device to rename (defaults to the paired device)")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+
+	device := NewDevice()
+	if err := device.LoadConfig(); err != nil {
+		return fmt.Errorf("%w (pair a device with the interactive UI first)", err)
+	}
+	if *ip == "" {
+		*ip = device.GetDeviceIP()
+	}
+	return device.Rename(*ip, strings.Join(fs.Args(), " "))
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeviceRegistry(t *testing.T) {
+	registry := NewDeviceRegistry()
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	registry.Seen("192.168.1.57", seen)
+	registry.MarkOffline("192.168.1.20")
+
+	devices := registry.Devices()
+	if len(devices) != 2 || devices[0].IP != "192.168.1.20" || devices[1].IP != "192.168.1.57" {
+		t.Fatalf("unexpected devices %+v", devices)
+	}
+	if devices[0].Online || !devices[0].LastSeen.IsZero() {
+		t.Errorf("192.168.1.20 should be offline and never seen: %+v", devices[0])
+	}
+	if !devices[1].Online || !devices[1].LastSeen.Equal(seen) {
+		t.Errorf("192.168.1.57 should be online and seen: %+v", devices[1])
+	}
+
+	registry.SetModel("192.168.1.57", "Shapes", "9.2.4")
+	registry.MarkOffline("192.168.1.57")
+	record, ok := registry.Get("192.168.1.57")
+	if !ok || record.Online || !record.LastSeen.Equal(seen) {
+		t.Errorf("going offline should keep the last seen time: %+v", record)
+	}
+	if record.Model != "Shapes" || record.Firmware != "9.2.4" {
+		t.Errorf("unexpected model %q firmware %q", record.Model, record.Firmware)
+	}
+}
+
+func TestDeviceRegistrySaveAndLoad(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	registry, err := LoadDeviceRegistry()
+	if err != nil || len(registry.Devices()) != 0 {
+		t.Fatalf("missing registry should load empty: %v", err)
+	}
+
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	registry.Seen("192.168.1.57", seen)
+	if err := registry.Save(); err != nil {
+		t.Fatalf("Save should not fail: %v", err)
+	}
+
+	loaded, err := LoadDeviceRegistry()
+	if err != nil {
+		t.Fatalf("LoadDeviceRegistry should not fail: %v", err)
+	}
+	record, ok := loaded.Get("192.168.1.57")
+	if !ok || !record.LastSeen.Equal(seen) {
+		t.Errorf("unexpected loaded record %+v", record)
+	}
+	if record.Online {
+		t.Error("online status should not be persisted")
+	}
+}
+
+func TestDeviceRegistrySaveKeepsOtherSaves(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Two processes load the same registry and save their own changes
+	first := NewDeviceRegistry()
+	first.Seen("192.168.1.57", seen)
+	first.Seen("192.168.1.58", seen)
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save should not fail: %v", err)
+	}
+	ui, _ := LoadDeviceRegistry()
+	serve, _ := LoadDeviceRegistry()
+
+	serve.Seen("192.168.1.60", seen.Add(time.Hour))
+	serve.Seen("192.168.1.57", seen.Add(time.Hour))
+	serve.Move("192.168.1.58", "192.168.1.61")
+	if err := serve.Save(); err != nil {
+		t.Fatalf("Save should not fail: %v", err)
+	}
+	ui.SetModel("192.168.1.57", "Shapes", "9.2.4")
+	if err := ui.Save(); err != nil {
+		t.Fatalf("Save should not fail: %v", err)
+	}
+
+	loaded, _ := LoadDeviceRegistry()
+	if _, ok := loaded.Get("192.168.1.60"); !ok {
+		t.Error("expected the device serve added to survive the UI save")
+	}
+	if record, _ := loaded.Get("192.168.1.57"); record.Model != "Shapes" || !record.LastSeen.Equal(seen.Add(time.Hour)) {
+		t.Errorf("expected the UI model and the later LastSeen, got %+v", record)
+	}
+	if err := serve.Save(); err != nil {
+		t.Fatalf("Save should not fail: %v", err)
+	}
+	loaded, _ = LoadDeviceRegistry()
+	if _, ok := loaded.Get("192.168.1.58"); ok {
+		t.Error("expected the moved address not to come back from the file")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			registry := NewDeviceRegistry()
+			registry.Seen(fmt.Sprintf("10.0.0.%d", i), seen)
+			if err := registry.Save(); err != nil {
+				t.Errorf("Save should not fail: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	loaded, err := LoadDeviceRegistry()
+	if err != nil {
+		t.Fatalf("expected concurrent saves to leave a valid file: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		if _, ok := loaded.Get(fmt.Sprintf("10.0.0.%d", i)); !ok {
+			t.Errorf("expected 10.0.0.%d to be kept", i)
+		}
+	}
+}
+
+func TestDeviceRecordStatus(t *testing.T) {
+	now := time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		record   DeviceRecord
+		expected string
+	}{
+		{DeviceRecord{Online: true}, "online"},
+		{DeviceRecord{}, "offline"},
+		{DeviceRecord{LastSeen: now.Add(-20 * time.Second)}, "last seen just now"},
+		{DeviceRecord{LastSeen: now.Add(-5 * time.Minute)}, "last seen 5m ago"},
+		{DeviceRecord{LastSeen: now.Add(-3 * time.Hour)}, "last seen 3h ago"},
+		{DeviceRecord{LastSeen: now.Add(-72 * time.Hour)}, "last seen 3d ago"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.record.Status(now); got != tt.expected {
+			t.Errorf("Status() = %q, expected %q", got, tt.expected)
+		}
+	}
+}
@@ -2,79 +2,304 @@ package internal
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"net"
+	"os"
+	"sort"
 	"sync"
 	"time"
 )
 
-func scanForDevices(ctx context.Context) ([]string, error) {
-	// Get local IP to determine subnet
+const (
+	// maxAutoScanBits caps detected networks at a /22 (1022 hosts) around
+	// the interface address; larger ranges need an explicit --range
+	maxAutoScanBits = 10
+	// maxScanBits caps any range at a /16, 65534 hosts, which a sweep
+	// still finishes in minutes; a shorter prefix would hold millions of
+	// addresses in memory
+	maxScanBits     = 16
+	scanConcurrency = 256
+)
+
+// scanOptions controls which networks scanForDevices probes
+type scanOptions struct {
+	// IncludeVPN also scans point-to-point interfaces such as VPN tunnels
+	IncludeVPN bool
+	// Range replaces the detected interface networks when set
+	Range *net.IPNet
+	// Full skips the neighbor table and probes the hosts in address order
+	// only
+	Full bool
+	// Warnf reports networks that were narrowed down, if set
+	Warnf func(format string, args ...interface{})
+	// Found is called with each device as soon as it answers, if set
+	Found func(ip string)
+}
+
+// scanForDevices returns the devices found on the local networks. When ctx
+// ends first, the devices found so far are returned with its error.
+func scanForDevices(ctx context.Context, opts scanOptions) ([]string, error) {
+	networks, err := scanNetworks(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("no suitable network interface found")
+	}
+
+	// Hosts the OS has talked to recently are probed first, which usually
+	// reports the panels at once. The sweep still follows for the devices
+	// the table does not list, such as ones nothing has talked to lately.
+	var devices []string
+	probed := make(map[string]bool)
+	if !opts.Full {
+		neighbors := neighborHosts(networks)
+		devices, err = probeHosts(ctx, neighbors, opts.Found)
+		if err != nil {
+			return devices, err
+		}
+		for _, ip := range neighbors {
+			probed[ip] = true
+		}
+	}
+
+	var hosts []string
+	for _, network := range networks {
+		for _, ip := range hostsInNetwork(network) {
+			if !probed[ip] {
+				hosts = append(hosts, ip)
+			}
+		}
+	}
+	swept, err := probeHosts(ctx, hosts, opts.Found)
+	devices = append(devices, swept...)
+	sort.Strings(devices)
+	return devices, err
+}
+
+// probeHosts concurrently checks hosts for Nanoleaf devices (port 16021),
+// calling found, if set, for each one. When ctx ends first, the devices
+// found so far are returned with its error.
+func probeHosts(ctx context.Context, hosts []string, found func(ip string)) ([]string, error) {
+	seen := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, scanConcurrency)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer wg.Wait()
+
+		for _, ip := range hosts {
+			select {
+			case <-ctx.Done():
+				return
+			case sem <- struct{}{}:
+			}
+
+			wg.Add(1)
+			go func(ip string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				dialer := net.Dialer{Timeout: 100 * time.Millisecond}
+				conn, err := dialer.DialContext(ctx, "tcp", ip+":16021")
+				if err != nil {
+					return
+				}
+				conn.Close()
+				mu.Lock()
+				seen[ip] = true
+				mu.Unlock()
+				if found != nil {
+					found(ip)
+				}
+			}(ip)
+		}
+	}()
+
+	// Wait for all probes to complete or context cancellation
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-done:
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	devices := make([]string, 0, len(seen))
+	for ip := range seen {
+		devices = append(devices, ip)
+	}
+	sort.Strings(devices)
+	return devices, err
+}
+
+// scanNetworks returns the network of every private IPv4 address on an
+// active, non-loopback interface, or just opts.Range when it is set
+func scanNetworks(opts scanOptions) ([]*net.IPNet, error) {
+	if opts.Range != nil {
+		return []*net.IPNet{opts.Range}, nil
+	}
+
 	interfaces, err := net.Interfaces()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
 	}
 
-	var subnet string
+	var found []scanInterface
 	for _, iface := range interfaces {
-		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+		addrs, err := iface.Addrs()
+		if err != nil {
 			continue
 		}
+		found = append(found, scanInterface{name: iface.Name, flags: iface.Flags, addrs: addrs})
+	}
+	return interfaceNetworks(found, opts), nil
+}
 
-		addrs, err := iface.Addrs()
-		if err != nil {
+// scanInterface is what interfaceNetworks needs of a network interface
+type scanInterface struct {
+	name  string
+	flags net.Flags
+	addrs []net.Addr
+}
+
+// interfaceNetworks picks the networks to scan from interfaces: the network
+// of each private IPv4 address, capped at maxAutoScanBits, once however
+// many interfaces are on it. Interfaces that are down or loopback are
+// skipped, as are point-to-point ones unless opts.IncludeVPN.
+func interfaceNetworks(interfaces []scanInterface, opts scanOptions) []*net.IPNet {
+	seen := make(map[string]bool)
+	var networks []*net.IPNet
+	for _, iface := range interfaces {
+		if iface.flags&net.FlagUp == 0 || iface.flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if iface.flags&net.FlagPointToPoint != 0 && !opts.IncludeVPN {
 			continue
 		}
 
-		for _, addr := range addrs {
-			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
-				ip := ipNet.IP.To4()
-				if ip[0] == 192 && ip[1] == 168 {
-					subnet = fmt.Sprintf("192.168.%d", ip[2])
-					break
-				}
+		for _, addr := range iface.addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil || !ipNet.IP.IsPrivate() {
+				continue
+			}
+			network := capNetwork(ipNet, maxAutoScanBits)
+			if maskSize(network.Mask) != maskSize(ipNet.Mask) && opts.Warnf != nil {
+				opts.Warnf("%s on %s is too large to scan, scanning %s (use --range to override)", ipNet, iface.name, network)
+			}
+			if !seen[network.String()] {
+				seen[network.String()] = true
+				networks = append(networks, network)
 			}
 		}
-		if subnet != "" {
+	}
+	return networks
+}
+
+// capNetwork returns the network of addr, narrowed to at most maxBits host
+// bits around the address itself
+func capNetwork(addr *net.IPNet, maxBits int) *net.IPNet {
+	ip := addr.IP.To4()
+	ones := maskSize(addr.Mask)
+	if 32-ones > maxBits {
+		ones = 32 - maxBits
+	}
+	mask := net.CIDRMask(ones, 32)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+func maskSize(mask net.IPMask) int {
+	ones, _ := mask.Size()
+	return ones
+}
+
+// hostsInNetwork lists the usable IPv4 host addresses of network, skipping
+// the network and broadcast addresses where the prefix has them. Networks
+// larger than maxScanBits have none.
+func hostsInNetwork(network *net.IPNet) []string {
+	ip := network.IP.To4()
+	if ip == nil {
+		return nil
+	}
+	ones, bits := network.Mask.Size()
+	if bits != 32 || 32-ones > maxScanBits {
+		return nil
+	}
+
+	start := binary.BigEndian.Uint32(ip.Mask(network.Mask))
+	size := uint32(1) << (32 - ones)
+	first, last := start, start+size-1
+	if size > 2 {
+		first, last = start+1, last-1
+	}
+
+	hosts := make([]string, 0, last-first+1)
+	for n := first; ; n++ {
+		var host [4]byte
+		binary.BigEndian.PutUint32(host[:], n)
+		hosts = append(hosts, net.IP(host[:]).String())
+		if n == last {
 			break
 		}
 	}
+	return hosts
+}
 
-	if subnet == "" {
-		return nil, fmt.Errorf("no suitable network interface found")
+func runScan(ctx context.Context, args []string) error {
+	fs := newFlagSet("scan")
+	includeVPN := fs.Bool("vpn", false, "also scan VPN (point-to-point) interfaces")
+	cidr := fs.String("range", "", "scan this network instead, e.g. 10.0.0.0/16")
+	full := fs.Bool("full", false, "skip the ARP table and probe the hosts in address order only")
+	timeout := fs.Duration("timeout", 30*time.Second, "give up after this long")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
 	}
 
-	// Scan the subnet for Nanoleaf devices (port 16021)
-	var devices []string
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+	opts := scanOptions{
+		IncludeVPN: *includeVPN,
+		Full:       *full,
+		Warnf: func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+		},
+	}
+	if *cidr != "" {
+		_, network, err := net.ParseCIDR(*cidr)
+		if err != nil || network.IP.To4() == nil {
+			return invalidArgs(fmt.Errorf("invalid range %q, expected an IPv4 CIDR such as 192.168.0.0/22", *cidr))
+		}
+		if maskSize(network.Mask) < 32-maxScanBits {
+			return invalidArgs(fmt.Errorf("range %q is too large, scan at most a /%d at a time", *cidr, 32-maxScanBits))
+		}
+		opts.Range = network
+	}
 
-	for i := 1; i < 255; i++ {
-		wg.Add(1)
-		go func(ip string) {
-			defer wg.Done()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
 
-			conn, err := net.DialTimeout("tcp", ip+":16021", 100*time.Millisecond)
-			if err == nil {
-				conn.Close()
-				mu.Lock()
-				devices = append(devices, ip)
-				mu.Unlock()
-			}
-		}(fmt.Sprintf("%s.%d", subnet, i))
+	devices, err := scanForDevices(ctx, opts)
+	if err != nil && len(devices) == 0 {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: scan stopped early (%v), listing the devices found so far\n", err)
+	}
+	if len(devices) == 0 {
+		return fmt.Errorf("no devices found")
 	}
 
-	// Wait for all scans to complete or context cancellation
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
-
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-done:
-		return devices, nil
+	registry, err := LoadDeviceRegistry()
+	if err != nil {
+		registry = NewDeviceRegistry()
+	}
+	now := time.Now()
+	for _, ip := range devices {
+		registry.Seen(ip, now)
+		fmt.Println(ip)
 	}
+	return registry.Save()
 }
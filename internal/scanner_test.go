@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHostsInNetwork(t *testing.T) {
+	tests := []struct {
+		cidr  string
+		count int
+		first string
+		last  string
+	}{
+		{"192.168.1.0/24", 254, "192.168.1.1", "192.168.1.254"},
+		{"10.0.0.0/22", 1022, "10.0.0.1", "10.0.3.254"},
+		{"10.0.0.8/30", 2, "10.0.0.9", "10.0.0.10"},
+		{"10.0.0.8/31", 2, "10.0.0.8", "10.0.0.9"},
+		{"10.0.0.8/32", 1, "10.0.0.8", "10.0.0.8"},
+	}
+
+	for _, tt := range tests {
+		_, network, _ := net.ParseCIDR(tt.cidr)
+		hosts := hostsInNetwork(network)
+		if len(hosts) != tt.count {
+			t.Errorf("%s: expected %d hosts, got %d", tt.cidr, tt.count, len(hosts))
+			continue
+		}
+		if hosts[0] != tt.first || hosts[len(hosts)-1] != tt.last {
+			t.Errorf("%s: expected %s-%s, got %s-%s", tt.cidr, tt.first, tt.last, hosts[0], hosts[len(hosts)-1])
+		}
+	}
+}
+
+func TestHostsInNetworkRejectsShortPrefixes(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/8", "0.0.0.0/0", "172.16.0.0/15"} {
+		_, network, _ := net.ParseCIDR(cidr)
+		if hosts := hostsInNetwork(network); hosts != nil {
+			t.Errorf("%s: expected no hosts for a prefix shorter than /16, got %d", cidr, len(hosts))
+		}
+	}
+	_, network, _ := net.ParseCIDR("10.1.0.0/16")
+	if hosts := hostsInNetwork(network); len(hosts) != 65534 {
+		t.Errorf("expected a /16 to be swept, got %d hosts", len(hosts))
+	}
+}
+
+func TestCapNetwork(t *testing.T) {
+	addr := &net.IPNet{IP: net.ParseIP("172.16.45.10"), Mask: net.CIDRMask(16, 32)}
+	if network := capNetwork(addr, maxAutoScanBits); network.String() != "172.16.44.0/22" {
+		t.Errorf("expected 172.16.44.0/22, got %s", network)
+	}
+
+	addr = &net.IPNet{IP: net.ParseIP("192.168.1.20"), Mask: net.CIDRMask(25, 32)}
+	if network := capNetwork(addr, maxAutoScanBits); network.String() != "192.168.1.0/25" {
+		t.Errorf("expected 192.168.1.0/25, got %s", network)
+	}
+}
+
+func TestInterfaceNetworks(t *testing.T) {
+	addrs := func(cidrs ...string) []net.Addr {
+		var list []net.Addr
+		for _, cidr := range cidrs {
+			ip, network, _ := net.ParseCIDR(cidr)
+			list = append(list, &net.IPNet{IP: ip, Mask: network.Mask})
+		}
+		return list
+	}
+	up := net.FlagUp | net.FlagBroadcast
+	interfaces := []scanInterface{
+		{name: "lo", flags: net.FlagUp | net.FlagLoopback, addrs: addrs("127.0.0.1/8")},
+		{name: "eth0", flags: up, addrs: addrs("192.168.1.20/24", "fe80::1/64", "203.0.113.5/24")},
+		{name: "wlan0", flags: up, addrs: addrs("192.168.1.30/24")},
+		{name: "eth1", flags: net.FlagBroadcast, addrs: addrs("10.9.0.2/24")},
+		{name: "tun0", flags: net.FlagUp | net.FlagPointToPoint, addrs: addrs("10.8.0.2/24")},
+		{name: "eth2", flags: up, addrs: addrs("172.16.45.10/16")},
+		{name: "eth3", flags: up, addrs: addrs("10.1.200.7/16")},
+	}
+
+	var warnings []string
+	opts := scanOptions{Warnf: func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}}
+	var got []string
+	for _, network := range interfaceNetworks(interfaces, opts) {
+		got = append(got, network.String())
+	}
+	want := []string{"192.168.1.0/24", "172.16.44.0/22", "10.1.200.0/22"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "eth2") || !strings.Contains(warnings[1], "eth3") {
+		t.Errorf("expected a warning for each capped /16, got %q", warnings)
+	}
+
+	opts.IncludeVPN = true
+	networks := interfaceNetworks(interfaces, opts)
+	if len(networks) != 4 || networks[1].String() != "10.8.0.0/24" {
+		t.Errorf("expected the VPN network with --vpn, got %v", networks)
+	}
+}
+
+func BenchmarkHostsInNetwork(b *testing.B) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/22")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hostsInNetwork(network)
+	}
+}
+
+// BenchmarkProbeHosts sweeps a loopback /24 where no host answers, the cost
+// of a scan that has to fall back from the neighbor table
+func BenchmarkProbeHosts(b *testing.B) {
+	_, network, _ := net.ParseCIDR("127.0.0.0/24")
+	hosts := hostsInNetwork(network)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := probeHosts(ctx, hosts, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSimulation keeps a mistyped --to from printing years of schedule
+const maxSimulation = 366 * 24 * time.Hour
+
+// scheduleEvent is a step of the simulated schedule: what source, the quiet
+// hours or an automation command, does at a time
+type scheduleEvent struct {
+	at     time.Time
+	source string
+	action string
+	// suppressed is set when the quiet hours hold the action, which then
+	// happens at the end of the window
+	suppressed bool
+	// conflicts are the automations the action holds or overrules
+	conflicts []string
+}
+
+func (e scheduleEvent) String() string {
+	status := "         "
+	switch {
+	case e.suppressed:
+		status = "held     "
+	case len(e.conflicts) > 0:
+		status = "conflict "
+	}
+	line := fmt.Sprintf("%s%s  %-12s %s", status, e.at.Format("Mon Jan 02 15:04 MST"), e.source, e.action)
+	for _, conflict := range e.conflicts {
+		line += "\n         → " + conflict
+	}
+	return line
+}
+
+// simulateSchedule lists what the time-driven automations would do from
+// from until to: the quiet hours begin and end, and the holidays command
+// shows the scene of each holiday, held until the morning when the quiet
+// hours hold changes. Conflicts are holidays sharing a day and the
+// automations a scene holds by priority. Each runs in its own time zone,
+// and times a DST change moves, skips or repeats are marked.
+func simulateSchedule(config Config, from, to time.Time) ([]scheduleEvent, error) {
+	gate := &quietGate{}
+	if config.QuietHours != nil {
+		if err := config.QuietHours.validate(); err != nil {
+			return nil, err
+		}
+		gate.hours = *config.QuietHours
+	}
+	quietZone, err := config.scheduleZone(gate.hours.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("timezone: %w", err)
+	}
+	gate.loc = quietZone
+	settings := HolidayConfig{}
+	if config.Holidays != nil {
+		settings = *config.Holidays
+	}
+	calendar, err := holidayCalendar(settings)
+	if err != nil {
+		return nil, err
+	}
+	holidayZone, err := config.scheduleZone(settings.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("holidays.timezone: %w", err)
+	}
+	if err := config.validatePriorities(); err != nil {
+		return nil, err
+	}
+	hold, _ := config.priorityHold()
+	scenePriority, _ := config.automationPriority("holidays")
+
+	var events []scheduleEvent
+	add := func(e scheduleEvent) {
+		if !e.at.Before(from) && e.at.Before(to) {
+			events = append(events, e)
+		}
+	}
+	if hours := gate.hours; hours.Start != "" {
+		begins := "begin, automations hold their changes until " + hours.End
+		ends := "end, held changes go through"
+		if hours.MaxBrightness > 0 {
+			begins = fmt.Sprintf("begin, brightness capped at %d%% until %s", hours.MaxBrightness, hours.End)
+			ends = "end, brightness no longer capped"
+		}
+		for day := startOfDay(from.In(quietZone)); day.Before(to); day = day.AddDate(0, 0, 1) {
+			for _, e := range quietEvents(day, hours.Start, begins, quietZone, hours.DSTSkipped, hours.DSTRepeated) {
+				add(e)
+			}
+			for _, e := range quietEvents(day, hours.End, ends, quietZone, dstShift, hours.DSTRepeated) {
+				add(e)
+			}
+		}
+	}
+
+	for day := startOfDay(from.In(holidayZone)); day.Before(to); day = day.AddDate(0, 0, 1) {
+		var today []holiday
+		for _, h := range calendar {
+			date := h.date(day.Year())
+			if date.Month() == day.Month() && date.Day() == day.Day() {
+				today = append(today, h)
+			}
+		}
+		if len(today) == 0 {
+			continue
+		}
+		scene := scheduleEvent{at: day, source: "holidays", action: today[0].Name + " scene"}
+		for _, other := range today[1:] {
+			scene.conflicts = append(scene.conflicts, fmt.Sprintf("%s falls on the same day and is not shown", other.Name))
+		}
+		gate.now = func() time.Time { return day }
+		if gate.quietHeld() {
+			add(scheduleEvent{at: day, source: "holidays", action: fmt.Sprintf("%s scene held by the quiet hours until %s", today[0].Name, gate.hours.End), suppressed: true})
+			scene.at = gate.hours.endAfter(day, quietZone)
+		} else if gate.quiet() {
+			scene.action += fmt.Sprintf(" at no more than %d%% brightness", gate.hours.MaxBrightness)
+		}
+		scene.conflicts = append(scene.conflicts, heldByScene(config, scenePriority, scene.at.Add(hold))...)
+		add(scene)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.Before(events[j].at) })
+	return events, nil
+}
+
+// quietEvents returns the quiet hours beginning or ending at clock on day,
+// which is action, noting when DST moves, skips or repeats it. Only a
+// beginning is ever skipped.
+func quietEvents(day time.Time, clock, action string, loc *time.Location, skipped, repeated string) []scheduleEvent {
+	at, _ := parseClock(clock)
+	instants := wallClock(day, at, loc, skipped, repeated)
+	if len(instants) == 0 {
+		jump := wallClock(day, at, loc, dstShift, repeated)[0]
+		return []scheduleEvent{{at: jump, source: "quiet hours", action: fmt.Sprintf("skipped, DST skips %s", clock)}}
+	}
+	var events []scheduleEvent
+	for i, instant := range instants {
+		e := scheduleEvent{at: instant, source: "quiet hours", action: action}
+		switch {
+		case instant.Format("15:04") != clock:
+			e.action += fmt.Sprintf(" (DST skips %s)", clock)
+		case len(instants) > 1:
+			e.action += fmt.Sprintf(" (DST repeats %s, %d of 2)", clock, i+1)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// heldByScene lists the automation commands of a lower priority than p,
+// which a change at p holds until until
+func heldByScene(config Config, p priority, until time.Time) []string {
+	names := make([]string, 0, len(commandPriorities))
+	for name := range commandPriorities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var held []string
+	for _, name := range names {
+		if q, _ := config.automationPriority(name); q < p && name != "holidays" {
+			held = append(held, fmt.Sprintf("holds %s (%s) until %s", name, q, until.Format("Jan 02 15:04")))
+		}
+	}
+	return held
+}
+
+// startOfDay returns the midnight that starts the day of t
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// parseScheduleTime reads a --from or --to: today, tomorrow, now, a date
+// as 2006-01-02, or an offset from base such as +7d or +12h
+func parseScheduleTime(s string, base, now time.Time) (time.Time, error) {
+	switch s {
+	case "now":
+		return now, nil
+	case "today":
+		return startOfDay(now), nil
+	case "tomorrow":
+		return startOfDay(now).AddDate(0, 0, 1), nil
+	}
+	if offset, ok := strings.CutPrefix(s, "+"); ok {
+		if days, ok := strings.CutSuffix(offset, "d"); ok {
+			if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+				return base.AddDate(0, 0, n), nil
+			}
+		} else if d, err := time.ParseDuration(offset); err == nil && d >= 0 {
+			return base.Add(d), nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected today, tomorrow, now, a date such as 2024-12-24 or an offset such as +7d", s)
+}
+
+func runSchedule(ctx context.Context, args []string) error {
+	const usage = "schedule simulate [--from today] [--to +7d]"
+	if len(args) == 0 || args[0] != "simulate" {
+		return usageError(usage)
+	}
+	fs := newFlagSet("schedule simulate")
+	fromFlag := fs.String("from", "today", "start of the simulation: today, tomorrow, now, a date or an offset from now such as +1d")
+	toFlag := fs.String("to", "+7d", "end of the simulation: a date or an offset from --from such as +7d")
+	if err := fs.Parse(args[1:]); err != nil {
+		return invalidArgs(err)
+	}
+	if fs.NArg() > 0 {
+		return usageError(usage)
+	}
+
+	now := time.Now()
+	from, err := parseScheduleTime(*fromFlag, now, now)
+	if err != nil {
+		return invalidArgs(fmt.Errorf("--from: %w", err))
+	}
+	to, err := parseScheduleTime(*toFlag, from, now)
+	if err != nil {
+		return invalidArgs(fmt.Errorf("--to: %w", err))
+	}
+	if !to.After(from) {
+		return invalidArgs(fmt.Errorf("--to must be after --from"))
+	}
+	if to.Sub(from) > maxSimulation {
+		return invalidArgs(fmt.Errorf("simulate at most a year at a time"))
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("%w (pair a device with the interactive UI first)", err)
+	}
+	events, err := simulateSchedule(config, from, to)
+	if err != nil {
+		return err
+	}
+
+	statusf("Schedule from %s to %s\n", from.Format("Mon Jan 02 15:04 MST"), to.Format("Mon Jan 02 15:04 MST"))
+	if since, paused := pausedSince(); paused {
+		statusf("Automations are paused since %s, nothing below happens until daemon resume\n", since.Format("Jan 02 15:04"))
+	}
+	if len(events) == 0 {
+		fmt.Println("Nothing scheduled")
+		return nil
+	}
+	for _, event := range events {
+		fmt.Println(event)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulateSchedule(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	from := time.Date(2024, 10, 30, 0, 0, 0, 0, time.UTC)
+	config := Config{
+		Timezone:   "UTC",
+		QuietHours: &QuietHours{Start: "22:00", End: "07:00"},
+		Priorities: map[string]string{"weather": "circadian"},
+	}
+
+	events, err := simulateSchedule(config, from, from.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("simulateSchedule should not fail: %v", err)
+	}
+	var lines []string
+	for _, e := range events {
+		lines = append(lines, e.at.Format("Jan 02 15:04")+" "+e.source)
+	}
+	want := []string{
+		"Oct 30 07:00 quiet hours",
+		"Oct 30 22:00 quiet hours",
+		"Oct 31 00:00 holidays",
+		"Oct 31 07:00 quiet hours",
+		"Oct 31 07:00 holidays",
+		"Oct 31 22:00 quiet hours",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(lines, "\n"))
+	}
+	if !events[2].suppressed {
+		t.Error("expected the Halloween scene at midnight to be held by the quiet hours")
+	}
+	scene := events[4]
+	if scene.action != "Halloween scene" || len(scene.conflicts) != 2 {
+		t.Fatalf("expected the scene after the quiet hours holding two automations, got %+v", scene)
+	}
+	if scene.conflicts[1] != "holds weather (circadian) until Oct 31 07:30" {
+		t.Errorf("expected the configured priority and hold, got %q", scene.conflicts[1])
+	}
+}
+
+func TestSimulateScheduleCappedQuietHours(t *testing.T) {
+	from := time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC)
+	config := Config{
+		Timezone:   "UTC",
+		QuietHours: &QuietHours{Start: "00:00", End: "06:00", MaxBrightness: 10},
+		Holidays:   &HolidayConfig{Disabled: []string{"New Year's Eve"}},
+	}
+	events, err := simulateSchedule(config, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("simulateSchedule should not fail: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected the quiet hours and one scene, got %v", events)
+	}
+	if scene := events[1]; scene.suppressed || scene.action != "Christmas scene at no more than 10% brightness" {
+		t.Errorf("expected the scene capped rather than held, got %+v", scene)
+	}
+
+	if _, err := simulateSchedule(Config{QuietHours: &QuietHours{Start: "late"}}, from, from.Add(time.Hour)); err == nil {
+		t.Error("expected invalid quiet hours to be rejected")
+	}
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 15, 30, 0, 0, time.UTC)
+	base := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"now":        now,
+		"today":      time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		"tomorrow":   time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		"+7d":        time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC),
+		"+12h":       time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC),
+		"2024-12-24": time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC),
+	}
+	for input, want := range tests {
+		if got, err := parseScheduleTime(input, base, now); err != nil || !got.Equal(want) {
+			t.Errorf("parseScheduleTime(%q) = %v, %v, expected %v", input, got, err, want)
+		}
+	}
+	for _, invalid := range []string{"", "next week", "+-1d", "+3w"} {
+		if _, err := parseScheduleTime(invalid, base, now); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
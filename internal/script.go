@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Scripts are Lua programs with a global "nanoleaf" table for controlling
+// the paired device:
+//
+//	nanoleaf.on() / nanoleaf.off()
+//	nanoleaf.brightness(80)
+//	nanoleaf.color("teal") or nanoleaf.color(hue, saturation)
+//	nanoleaf.temperature(2700)
+//	nanoleaf.effect("Northern Lights")
+//	nanoleaf.preset(2) / nanoleaf.macro("wake")
+//	nanoleaf.sleep(1.5)
+
+func runScriptCommand(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("run SCRIPT.lua")
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	return runScript(ctx, device, args[0])
+}
+
+func runScript(ctx context.Context, device *Device, path string) error {
+	L := newScriptState(ctx, device)
+	defer L.Close()
+	return L.DoFile(path)
+}
+
+func newScriptState(ctx context.Context, device *Device) *lua.LState {
+	L := lua.NewState()
+	L.SetContext(ctx)
+
+	// check raises a Lua error for a failed device call so scripts can pcall it
+	check := func(L *lua.LState, err error) int {
+		if err != nil {
+			L.RaiseError("%s", err.Error())
+		}
+		return 0
+	}
+
+	api := L.NewTable()
+	L.SetFuncs(api, map[string]lua.LGFunction{
+		"on": func(L *lua.LState) int {
+			return check(L, device.TurnOn(ctx))
+		},
+		"off": func(L *lua.LState) int {
+			return check(L, device.TurnOff(ctx))
+		},
+		"brightness": func(L *lua.LState) int {
+			return check(L, device.SetBrightness(ctx, L.CheckInt(1)))
+		},
+		"color": func(L *lua.LState) int {
+			if L.Get(1).Type() == lua.LTNumber {
+				return check(L, device.SetColor(ctx, L.CheckInt(1), L.CheckInt(2)))
+			}
+			color, err := parseColor(L.CheckString(1))
+			if err != nil {
+				return check(L, err)
+			}
+			hue, sat, _ := color.hsb()
+			return check(L, device.SetColor(ctx, hue, sat))
+		},
+		"temperature": func(L *lua.LState) int {
+			return check(L, device.SetColorTemp(ctx, L.CheckInt(1)))
+		},
+		"effect": func(L *lua.LState) int {
+			return check(L, device.SelectEffect(ctx, L.CheckString(1)))
+		},
+		"preset": func(L *lua.LState) int {
+			preset, ok := device.GetConfig().Presets[L.CheckAny(1).String()]
+			if !ok {
+				return check(L, fmt.Errorf("preset %s is not configured", L.Get(1)))
+			}
+			return check(L, preset.apply(ctx, device))
+		},
+		"macro": func(L *lua.LState) int {
+			return check(L, runMacro(ctx, device, L.CheckString(1)))
+		},
+		"sleep": func(L *lua.LState) int {
+			delay := time.Duration(float64(L.CheckNumber(1)) * float64(time.Second))
+			select {
+			case <-ctx.Done():
+				return check(L, ctx.Err())
+			case <-time.After(delay):
+				return 0
+			}
+		},
+	})
+	L.SetGlobal("nanoleaf", api)
+	return L
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunScript(t *testing.T) {
+	var updates []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if _, ok := payload["hue"]; ok {
+			updates = append(updates, "color")
+		} else {
+			for key := range payload {
+				updates = append(updates, key)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	path := filepath.Join(t.TempDir(), "script.lua")
+	os.WriteFile(path, []byte(`
+nanoleaf.on()
+for level = 10, 20, 10 do
+  nanoleaf.brightness(level)
+  nanoleaf.sleep(0.01)
+end
+nanoleaf.color("teal")
+nanoleaf.color(120, 50)
+local ok = pcall(nanoleaf.brightness, 500)
+if ok then error("expected brightness 500 to fail") end
+nanoleaf.effect("Blaze")
+`), 0644)
+
+	if err := runScript(context.Background(), device, path); err != nil {
+		t.Fatalf("runScript should not fail: %v", err)
+	}
+
+	expected := []string{"on", "brightness", "brightness", "color", "color", "select"}
+	if !reflect.DeepEqual(updates, expected) {
+		t.Errorf("expected updates %v, got %v", expected, updates)
+	}
+}
+
+func TestRunScriptError(t *testing.T) {
+	device := NewDevice()
+	path := filepath.Join(t.TempDir(), "script.lua")
+	os.WriteFile(path, []byte(`nanoleaf.color("notacolor")`), 0644)
+
+	err := runScript(context.Background(), device, path)
+	if err == nil || !strings.Contains(err.Error(), "unknown color") {
+		t.Errorf("expected an unknown color error, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretCommandPrefix marks a secret in the config that is the output of a
+// command, e.g. "cmd:pass show nanoleaf/office", so the secret itself can
+// stay in a password manager
+const secretCommandPrefix = "cmd:"
+
+// secretCommandTimeout leaves time to unlock the password manager
+const secretCommandTimeout = time.Minute
+
+// secretRef is a secret read through a command
+type secretRef struct {
+	ref   string
+	value string
+}
+
+var (
+	// secretCache keeps the output of each secret command for the rest of
+	// the run, so reloading the config does not ask to unlock again
+	secretCache   = make(map[string]string)
+	secretCacheMu sync.Mutex
+)
+
+// runSecretCommand runs the command of a cmd: reference through the shell
+// and returns the first line of its output, which is where pass and the
+// 1Password CLI put the secret
+var runSecretCommand = func(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	line = strings.TrimRight(line, "\r")
+	if line == "" {
+		return "", fmt.Errorf("it printed nothing")
+	}
+	return line, nil
+}
+
+// resolveSecret returns value, or the output of its command when it is a
+// cmd: reference
+func resolveSecret(value string) (string, bool, error) {
+	command, ok := strings.CutPrefix(value, secretCommandPrefix)
+	if !ok {
+		return value, false, nil
+	}
+	command = strings.TrimSpace(command)
+	secretCacheMu.Lock()
+	defer secretCacheMu.Unlock()
+	if secret, ok := secretCache[command]; ok {
+		return secret, true, nil
+	}
+	secret, err := runSecretCommand(command)
+	if err != nil {
+		return "", true, fmt.Errorf("%q failed: %w", value, err)
+	}
+	secretCache[command] = secret
+	return secret, true, nil
+}
+
+// resolveSecrets runs the cmd: references of the tokens, serve.secret,
+// obs.password and the calendar URLs, which hold a private token, remembering
+// them for withSecretRefs
+func (c *Config) resolveSecrets() error {
+	c.secrets = nil
+	resolve := func(key string, value *string) error {
+		secret, isRef, err := resolveSecret(*value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if isRef {
+			if c.secrets == nil {
+				c.secrets = make(map[string]secretRef)
+			}
+			c.secrets[key] = secretRef{ref: *value, value: secret}
+			*value = secret
+		}
+		return nil
+	}
+
+	if err := resolve("token", &c.Token); err != nil {
+		return err
+	}
+	ips := make([]string, 0, len(c.Devices))
+	for ip := range c.Devices {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		paired := c.Devices[ip]
+		if err := resolve("devices."+ip+".token", &paired.Token); err != nil {
+			return err
+		}
+		c.Devices[ip] = paired
+	}
+	if c.Serve != nil {
+		serve := *c.Serve
+		if err := resolve("serve.secret", &serve.Secret); err != nil {
+			return err
+		}
+		c.Serve = &serve
+	}
+	if c.OBS != nil {
+		obs := *c.OBS
+		if err := resolve("obs.password", &obs.Password); err != nil {
+			return err
+		}
+		c.OBS = &obs
+	}
+	if len(c.Calendars) > 0 {
+		calendars := append([]CalendarSource(nil), c.Calendars...)
+		for i := range calendars {
+			if err := resolve(fmt.Sprintf("calendars.%d.url", i), &calendars[i].URL); err != nil {
+				return err
+			}
+		}
+		c.Calendars = calendars
+	}
+	return nil
+}
+
+// withSecretRefs returns the config to save: secrets that still have the
+// value their command printed go back to the reference. A secret that
+// changed since, such as the token of a device paired again, is saved as
+// it is.
+func (c Config) withSecretRefs() Config {
+	if len(c.secrets) == 0 {
+		return c
+	}
+	restore := func(key string, value *string) {
+		if ref, ok := c.secrets[key]; ok && ref.value == *value {
+			*value = ref.ref
+		}
+	}
+	restore("token", &c.Token)
+	devices := make(map[string]PairedDevice, len(c.Devices))
+	for ip, paired := range c.Devices {
+		restore("devices."+ip+".token", &paired.Token)
+		devices[ip] = paired
+	}
+	if c.Devices != nil {
+		c.Devices = devices
+	}
+	if c.Serve != nil {
+		serve := *c.Serve
+		restore("serve.secret", &serve.Secret)
+		c.Serve = &serve
+	}
+	if c.OBS != nil {
+		obs := *c.OBS
+		restore("obs.password", &obs.Password)
+		c.OBS = &obs
+	}
+	if len(c.Calendars) > 0 {
+		calendars := append([]CalendarSource(nil), c.Calendars...)
+		for i := range calendars {
+			restore(fmt.Sprintf("calendars.%d.url", i), &calendars[i].URL)
+		}
+		c.Calendars = calendars
+	}
+	return c
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// stubSecretCommands answers secret commands from secrets and counts them
+func stubSecretCommands(t *testing.T, secrets map[string]string) *int {
+	runs := 0
+	original := runSecretCommand
+	runSecretCommand = func(command string) (string, error) {
+		runs++
+		if secret, ok := secrets[command]; ok {
+			return secret, nil
+		}
+		return "", fmt.Errorf("exit status 1: %s is not in the password store", command)
+	}
+	t.Cleanup(func() {
+		runSecretCommand = original
+		secretCacheMu.Lock()
+		secretCache = make(map[string]string)
+		secretCacheMu.Unlock()
+	})
+	return &runs
+}
+
+func TestConfigSecretReferences(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	runs := stubSecretCommands(t, map[string]string{
+		"pass show nanoleaf/office": "office-token",
+		"op read op://Home/serve":   "serve-key",
+	})
+	data := `{
+  "ip": "10.0.0.5",
+  "token": "cmd:pass show nanoleaf/office",
+  "devices": {
+    "10.0.0.5": {"token": "cmd:pass show nanoleaf/office"},
+    "10.0.0.6": {"token": "plain-token"}
+  },
+  "serve": {"secret": "cmd: op read op://Home/serve"}
+}`
+	if err := os.WriteFile(getConfigPath(), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig should not fail: %v", err)
+	}
+	if config.Token != "office-token" || config.Devices["10.0.0.5"].Token != "office-token" || config.Serve.Secret != "serve-key" {
+		t.Errorf("the references should be resolved, got %+v", config)
+	}
+	if *runs != 2 {
+		t.Errorf("expected each command to run once, ran %d", *runs)
+	}
+
+	config.Theme = "nord"
+	config.Devices["10.0.0.6"] = PairedDevice{Token: "new-token"}
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("saveConfig should not fail: %v", err)
+	}
+	saved, _ := os.ReadFile(getConfigPath())
+	for _, want := range []string{`"token": "cmd:pass show nanoleaf/office"`, `"secret": "cmd: op read op://Home/serve"`, `"token": "new-token"`} {
+		if !strings.Contains(string(saved), want) {
+			t.Errorf("expected %s in the saved config:\n%s", want, saved)
+		}
+	}
+	if strings.Contains(string(saved), "office-token") || strings.Contains(string(saved), "serve-key") {
+		t.Errorf("the secrets should not be saved:\n%s", saved)
+	}
+
+	// A device paired again has a new token, which is saved as it is
+	config, _ = loadConfig()
+	config.Token = "repaired-token"
+	saveConfig(config)
+	saved, _ = os.ReadFile(getConfigPath())
+	if !strings.Contains(string(saved), `"token": "repaired-token"`) {
+		t.Errorf("a changed secret should be saved:\n%s", saved)
+	}
+	if *runs != 2 {
+		t.Errorf("reloading should not run the commands again, ran %d", *runs)
+	}
+}
+
+func TestConfigSecretReferenceFails(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubSecretCommands(t, nil)
+	if err := os.WriteFile(getConfigPath(), []byte(`{"ip": "10.0.0.5", "token": "cmd:pass show missing"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "token:") || !strings.Contains(err.Error(), "not in the password store") {
+		t.Errorf("expected the failing reference in the error, got %v", err)
+	}
+}
+
+func TestSecretsResolvedOutsideConfigLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubSecretCommands(t, nil)
+	locked := false
+	runSecretCommand = func(command string) (string, error) {
+		if configMu.TryLock() {
+			configMu.Unlock()
+		} else {
+			locked = true
+		}
+		return "office-token", nil
+	}
+	if err := os.WriteFile(getConfigPath(), []byte(`{"ip": "10.0.0.5", "token": "cmd:pass show nanoleaf/office"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadConfig()
+	if err != nil || config.Token != "office-token" {
+		t.Fatalf("expected the reference to be resolved, got %q, %v", config.Token, err)
+	}
+	if locked {
+		t.Error("the secret command ran while the config lock was held")
+	}
+}
+
+func TestRunSecretCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("needs a POSIX shell")
+	}
+	secret, err := runSecretCommand("printf 'first-line\\nurl: example.com\\n'")
+	if err != nil || secret != "first-line" {
+		t.Errorf("expected the first line, got %q, %v", secret, err)
+	}
+	if _, err := runSecretCommand("echo denied >&2; exit 1"); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("expected the error output, got %v", err)
+	}
+	if _, err := runSecretCommand("true"); err == nil {
+		t.Error("expected an error for a command that prints nothing")
+	}
+}
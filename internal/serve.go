@@ -0,0 +1,532 @@
+package internal
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultServeAddr is where serve listens unless configured otherwise. It
+// only accepts requests from this computer; listening on the network needs
+// a secret, see checkServeListen.
+const defaultServeAddr = "127.0.0.1:8421"
+
+// errUnknownPresenceEvent is a presence request for an event other than
+// enter and leave, the client's mistake rather than the device's
+var errUnknownPresenceEvent = errors.New("unknown presence event")
+
+// ServeConfig configures the serve command. Requests must carry Secret as
+// a bearer token when it is set. Notify shows a
+// desktop notification when presence rules or triggers run or fail.
+type ServeConfig struct {
+	Listen   string                 `json:"listen,omitempty"`
+	Secret   string                 `json:"secret,omitempty"`
+	Notify   bool                   `json:"notify,omitempty"`
+	Presence []PresenceRule         `json:"presence,omitempty"`
+	Triggers map[string]TriggerRule `json:"triggers,omitempty"`
+	// NetworkPresence feeds the presence rules from phones on the network
+	NetworkPresence *NetworkPresence `json:"networkPresence,omitempty"`
+	// Rediscovery keeps the device registry current, see RediscoveryConfig
+	Rediscovery *RediscoveryConfig `json:"rediscovery,omitempty"`
+}
+
+// PresenceRule runs Actions when Person, or anyone when empty, enters or
+// leaves. The events home and away fire when the first person arrives and
+// when the last one leaves. Rules wait out the quiet hours unless
+// IgnoreQuietHours is set, and never run while the automations are paused
+// or yield to a manual change or a higher priority. Priority is that of
+// serve unless set.
+type PresenceRule struct {
+	Event            string   `json:"event"`
+	Person           string   `json:"person,omitempty"`
+	Actions          []string `json:"actions"`
+	IgnoreQuietHours bool     `json:"ignoreQuietHours,omitempty"`
+	Priority         string   `json:"priority,omitempty"`
+}
+
+var presenceEvents = []string{"enter", "leave", "home", "away"}
+
+type presenceRule struct {
+	PresenceRule
+	actions  []action
+	priority priority
+	// name tells the rule apart in the log, e.g. presence rule 2
+	name string
+}
+
+// presenceTracker keeps who is home and runs the matching rules
+type presenceTracker struct {
+	mu     sync.Mutex
+	device *Device
+	quiet  *quietGate
+	rules  []presenceRule
+	home   map[string]bool
+	// notify, when set, is told about rules that ran or failed
+	notify func(title, text string)
+}
+
+func newPresenceTracker(device *Device, quiet *quietGate, rules []PresenceRule) (*presenceTracker, error) {
+	t := &presenceTracker{device: device, quiet: quiet, home: make(map[string]bool)}
+	for i, rule := range rules {
+		valid := false
+		for _, event := range presenceEvents {
+			valid = valid || rule.Event == event
+		}
+		if !valid {
+			return nil, fmt.Errorf("presence rule %d: unknown event %q (expected %s)", i+1, rule.Event, strings.Join(presenceEvents, ", "))
+		}
+		actions, err := parseMacro(rule.Actions)
+		if err != nil {
+			return nil, fmt.Errorf("presence rule %d: %w", i+1, err)
+		}
+		p := prioritySchedule
+		if quiet != nil && quiet.priority != 0 {
+			p = quiet.priority
+		}
+		if rule.Priority != "" {
+			if p, err = parsePriority(rule.Priority); err != nil {
+				return nil, fmt.Errorf("presence rule %d: %w", i+1, err)
+			}
+		}
+		t.rules = append(t.rules, presenceRule{PresenceRule: rule, actions: actions, priority: p, name: fmt.Sprintf("presence rule %d", i+1)})
+	}
+	return t, nil
+}
+
+// update records that person entered or left and runs the rules that
+// match. It returns how many rules ran. Rules run from the lowest priority
+// to the highest, in config order within one, so where matching rules
+// disagree the highest priority wins every time.
+func (t *presenceTracker) update(ctx context.Context, person, event string) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wasEmpty := len(t.home) == 0
+	switch event {
+	case "enter":
+		t.home[person] = true
+	case "leave":
+		delete(t.home, person)
+	default:
+		return 0, fmt.Errorf("%w %q, expected enter or leave", errUnknownPresenceEvent, event)
+	}
+	events := []string{event}
+	if wasEmpty && len(t.home) > 0 {
+		events = append(events, "home")
+	}
+	if !wasEmpty && len(t.home) == 0 {
+		events = append(events, "away")
+	}
+
+	var matched []presenceRule
+	for _, rule := range t.rules {
+		matches := false
+		for _, e := range events {
+			matches = matches || rule.Event == e
+		}
+		if matches && (rule.Person == "" || strings.EqualFold(rule.Person, person)) {
+			matched = append(matched, rule)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].priority < matched[j].priority })
+
+	ran := 0
+	var winner presenceRule
+	held := t.quiet.quietHeld()
+	for _, rule := range matched {
+		// A pause, a manual change or a higher priority holds every rule,
+		// the quiet hours only those that do not ignore them
+		if reason := t.quiet.yields(rule.priority); reason != "" {
+			statusf("Holding %s: %s\n", rule.name, reason)
+			continue
+		}
+		if held && !rule.IgnoreQuietHours {
+			continue
+		}
+		if err := t.runRule(ctx, rule); err != nil {
+			return ran, err
+		}
+		ran++
+		winner = rule
+	}
+	if ran > 1 {
+		statusf("%d presence rules ran, %s (%s) went last and wins\n", ran, winner.name, winner.priority)
+	}
+	return ran, nil
+}
+
+// runRule runs the actions of rule, recording the changes as the rule's
+func (t *presenceTracker) runRule(ctx context.Context, rule presenceRule) error {
+	if t.device == nil {
+		return nil
+	}
+	if rule.IgnoreQuietHours {
+		t.device.setBrightnessCap(nil)
+		defer t.device.setBrightnessCap(t.quiet.limit)
+	} else if err := t.quiet.settle(ctx, t.device); err != nil {
+		return err
+	}
+	t.device.setChangeSource(rule.name, rule.priority)
+	defer t.device.setChangeSource("", 0)
+	for _, a := range rule.actions {
+		if err := a.run(ctx, t.device); err != nil {
+			return fmt.Errorf("%s rule failed at %q: %w", rule.Event, a, err)
+		}
+	}
+	return nil
+}
+
+// setRules replaces the rules and quiet hours, keeping who is home
+func (t *presenceTracker) setRules(quiet *quietGate, rules []PresenceRule) error {
+	next, err := newPresenceTracker(t.device, quiet, rules)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quiet, t.rules = next.quiet, next.rules
+	return nil
+}
+
+// gate returns the quiet gate of the rules
+func (t *presenceTracker) gate() *quietGate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.quiet
+}
+
+// whoIsHome lists the people at home, sorted
+func (t *presenceTracker) whoIsHome() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	people := make([]string, 0, len(t.home))
+	for person := range t.home {
+		people = append(people, person)
+	}
+	sort.Strings(people)
+	return people
+}
+
+// serveHandler routes the serve endpoints. Actions run on ctx rather than
+// the request context so a phone dropping the connection does not cut a
+// rule short. Requests from web pages not served from this computer are
+// refused, see isLoopbackOrigin.
+func serveHandler(ctx context.Context, secret string, presence *presenceTracker, triggers *triggerSet, rediscovery *rediscoverer, menu *menubar) http.Handler {
+	mux := http.NewServeMux()
+	menu.handle(ctx, mux)
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, menu.device.HTTPMetrics())
+	})
+	mux.HandleFunc("GET /presence", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"home": presence.whoIsHome()})
+	})
+	mux.HandleFunc("POST /presence/{person}/{event}", func(w http.ResponseWriter, r *http.Request) {
+		person, event := r.PathValue("person"), r.PathValue("event")
+		ran, err := presence.update(ctx, person, event)
+		if err != nil {
+			errorf("Presence %s %s failed: %v\n", person, event, err)
+			if presence.notify != nil {
+				presence.notify("Presence rule failed", err.Error())
+			}
+			status := http.StatusBadGateway
+			if errors.Is(err, errUnknownPresenceEvent) {
+				status = http.StatusBadRequest
+			}
+			writeJSON(w, status, map[string]interface{}{"error": err.Error()})
+			return
+		}
+		statusf("%s: %s, %d rule(s) ran\n", person, event, ran)
+		if presence.notify != nil && ran > 0 {
+			presence.notify("nanoleaf-go", fmt.Sprintf("%s: %s, %d rule(s) ran", person, event, ran))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"home": presence.whoIsHome(), "ran": ran})
+	})
+
+	mux.HandleFunc("GET /discovery", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"events": rediscovery.recent()})
+	})
+	mux.HandleFunc("GET /trigger", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"triggers": triggers.names()})
+	})
+	mux.HandleFunc("POST /trigger/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		held, err := triggers.fire(ctx, name)
+		switch {
+		case errors.Is(err, errUnknownTrigger):
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"error": err.Error()})
+		case err != nil:
+			errorf("Trigger %s failed: %v\n", name, err)
+			if presence.notify != nil {
+				presence.notify("Trigger failed", err.Error())
+			}
+			writeJSON(w, http.StatusBadGateway, map[string]interface{}{"error": err.Error()})
+		case held != "":
+			statusf("Holding trigger %s: %s\n", name, held)
+			writeJSON(w, http.StatusOK, map[string]interface{}{"ran": false, "held": held})
+		default:
+			statusf("Trigger %s ran\n", name)
+			if presence.notify != nil {
+				presence.notify("nanoleaf-go", fmt.Sprintf("Trigger %s ran", name))
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"ran": true})
+		}
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !isLoopbackOrigin(origin) {
+			writeJSON(w, http.StatusForbidden, map[string]interface{}{"error": "requests from web pages are refused"})
+			return
+		}
+		if secret != "" {
+			key, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(key), []byte(secret)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"error": "missing or wrong secret"})
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackOrigin reports whether origin, the Origin header a browser
+// sends, is a page served from this computer. Any other web page could
+// otherwise make the browser post to serve, which without a secret runs
+// triggers and toggles the device for whoever asks.
+func isLoopbackOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if u.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveReloader applies changes to the config while serve runs. Requests
+// and reloads take turns so no request sees a change half applied.
+type serveReloader struct {
+	mu          sync.Mutex
+	device      *Device
+	presence    *presenceTracker
+	triggers    *triggerSet
+	prober      *presenceProber
+	rediscovery *rediscoverer
+	// settings are the serve settings as last read from the config
+	settings ServeConfig
+	// notify, when set, is told about reloads
+	notify func(title, text string)
+}
+
+// reload reads the config when it changed on disk and applies the presence
+// rules, triggers, network presence, rediscovery and quiet hours in it. A
+// config with invalid rules keeps the previous ones. The caller holds r.mu.
+func (r *serveReloader) reload() {
+	reloaded, err := r.device.ReloadConfig()
+	if err != nil {
+		errorf("The config changed but could not be reloaded: %v\n", err)
+		return
+	}
+	if !reloaded {
+		return
+	}
+	config := r.device.GetConfig()
+	var settings ServeConfig
+	if config.Serve != nil {
+		settings = *config.Serve
+	}
+	quiet, err := newQuietGate(config, false)
+	if err == nil {
+		// Checked first so invalid triggers or probes keep the presence
+		// rules too
+		_, err = newTriggerSet(nil, quiet, settings.Triggers)
+	}
+	if err == nil {
+		_, err = newPresenceProber(nil, settings.NetworkPresence)
+	}
+	if err == nil {
+		_, err = newRediscoverer(nil, settings.Rediscovery)
+	}
+	if err == nil {
+		err = r.presence.setRules(quiet, settings.Presence)
+	}
+	if err == nil {
+		err = r.triggers.setRules(quiet, settings.Triggers)
+	}
+	if err == nil && r.prober != nil {
+		err = r.prober.setSettings(settings.NetworkPresence)
+	}
+	if err == nil && r.rediscovery != nil {
+		err = r.rediscovery.setSettings(settings.Rediscovery)
+	}
+	if err != nil {
+		errorf("Config reloaded, keeping the previous presence rules, triggers and quiet hours: %v\n", err)
+		if r.notify != nil {
+			r.notify("nanoleaf-go", fmt.Sprintf("Config reloaded with errors: %v", err))
+		}
+		return
+	}
+	statusf("Config reloaded, %d presence rule(s) and %d trigger(s)\n", len(settings.Presence), len(settings.Triggers))
+	if settings.Listen != r.settings.Listen || settings.Secret != r.settings.Secret {
+		statusf("serve.listen and serve.secret take effect when serve is restarted\n")
+	}
+	r.settings = settings
+	if r.notify != nil {
+		r.notify("nanoleaf-go", "Config reloaded")
+	}
+}
+
+// handler reloads the config, when needed, before each request. The lock
+// only covers the reload, so a slow action such as a macro does not hold up
+// the other endpoints.
+func (r *serveReloader) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.reload()
+		r.mu.Unlock()
+		next.ServeHTTP(w, req)
+	})
+}
+
+// watch reloads the config when it changes between requests, until ctx is
+// done
+func (r *serveReloader) watch(ctx context.Context) {
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			r.reload()
+			r.mu.Unlock()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// checkServeListen refuses to listen beyond this computer without a secret,
+// which would let anyone on the network drive the panels
+func checkServeListen(listen, secret string) error {
+	if secret != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return fmt.Errorf("serve.listen: invalid address %q, e.g. 127.0.0.1:8421", listen)
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	return fmt.Errorf("serve.listen: set serve.secret before listening on %s, or listen on 127.0.0.1", listen)
+}
+
+func runServe(ctx context.Context, args []string) error {
+	fs := newFlagSet("serve")
+	listen := fs.String("listen", "", "address to listen on, overrides serve.listen in the config (default "+defaultServeAddr+")")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	config := device.GetConfig()
+	settings := ServeConfig{Listen: defaultServeAddr}
+	if config.Serve != nil {
+		settings = *config.Serve
+		if settings.Listen == "" {
+			settings.Listen = defaultServeAddr
+		}
+	}
+	if *listen != "" {
+		settings.Listen = *listen
+	}
+	if err := checkServeListen(settings.Listen, settings.Secret); err != nil {
+		return err
+	}
+
+	quiet, err := newQuietGate(config, false)
+	if err != nil {
+		return err
+	}
+	presence, err := newPresenceTracker(device, quiet, settings.Presence)
+	if err != nil {
+		return err
+	}
+	triggers, err := newTriggerSet(device, quiet, settings.Triggers)
+	if err != nil {
+		return err
+	}
+	prober, err := newPresenceProber(presence, settings.NetworkPresence)
+	if err != nil {
+		return err
+	}
+	rediscovery, err := newRediscoverer(device, settings.Rediscovery)
+	if err != nil {
+		return err
+	}
+	reloader := &serveReloader{device: device, presence: presence, triggers: triggers, prober: prober, rediscovery: rediscovery}
+	if config.Serve != nil {
+		reloader.settings = *config.Serve
+	}
+	if settings.Notify {
+		// Notifications keep the quiet hours too
+		quietNotify := func(title, text string) {
+			if !presence.gate().quiet() {
+				notify(title, text)
+			}
+		}
+		presence.notify = quietNotify
+		reloader.notify = quietNotify
+		rediscovery.notify = quietNotify
+	}
+	go reloader.watch(ctx)
+	go prober.run(ctx)
+	go rediscovery.run(ctx)
+	watchManualChanges(ctx, device)
+
+	server := &http.Server{
+		Addr:              settings.Listen,
+		Handler:           reloader.handler(serveHandler(ctx, settings.Secret, presence, triggers, rediscovery, &menubar{device: device})),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	statusf("Serving on %s with %d presence rule(s) and %d trigger(s) (ctrl+c to stop)\n", settings.Listen, len(settings.Presence), len(settings.Triggers))
+	if settings.NetworkPresence != nil && len(settings.NetworkPresence.Devices) > 0 {
+		statusf("Looking for %d phone(s) on the network every %s\n", len(settings.NetworkPresence.Devices), shortDuration(prober.interval))
+	}
+	if rediscovery.enabled {
+		statusf("Scanning for devices every %s\n", shortDuration(rediscovery.interval))
+	}
+	if settings.Secret == "" {
+		statusf("No serve.secret is set, so only this computer can send requests\n")
+	}
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
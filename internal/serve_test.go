@@ -0,0 +1,273 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPresenceRules(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var sent []string
+	nanoleaf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sent = append(sent, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer nanoleaf.Close()
+
+	device := NewDevice()
+	device.config.IP = nanoleaf.URL
+	device.config.Token = "test-token"
+
+	presence, err := newPresenceTracker(device, nil, []PresenceRule{
+		{Event: "home", Actions: []string{"on"}},
+		{Event: "enter", Person: "alex", Actions: []string{"brightness 80"}},
+		{Event: "away", Actions: []string{"off"}},
+	})
+	if err != nil {
+		t.Fatalf("newPresenceTracker should not fail: %v", err)
+	}
+	server := httptest.NewServer(serveHandler(context.Background(), "secret", presence, nil, nil, &menubar{device: device}))
+	defer server.Close()
+
+	post := func(path string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", server.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	if _, body := post("/presence/alex/enter"); body["ran"] != 2.0 {
+		t.Errorf("expected the home and alex rules to run, got %v", body)
+	}
+	if _, body := post("/presence/sam/enter"); body["ran"] != 0.0 {
+		t.Errorf("expected no rules for a second arrival, got %v", body)
+	}
+	post("/presence/alex/leave")
+	if _, body := post("/presence/sam/leave"); body["ran"] != 1.0 {
+		t.Errorf("expected the away rule when the last person leaves, got %v", body)
+	}
+	want := []string{`{"on":{"value":true}}`, `{"brightness":{"value":80}}`, `{"on":{"value":false}}`}
+	if strings.Join(sent, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, sent)
+	}
+	if status, _ := post("/presence/alex/wander"); status != http.StatusBadRequest {
+		t.Errorf("expected an unknown event to fail, got %d", status)
+	}
+
+	resp, err := http.Post(server.URL+"/presence/alex/enter?key=secret", "", nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the secret to be accepted only as a bearer token, got %d", resp.StatusCode)
+	}
+
+	if _, err := newPresenceTracker(device, nil, []PresenceRule{{Event: "arrive", Actions: []string{"on"}}}); err == nil {
+		t.Error("expected an error for an unknown event")
+	}
+}
+
+func TestServeRefusesWebPages(t *testing.T) {
+	server := httptest.NewServer(serveHandler(context.Background(), "", &presenceTracker{}, nil, nil, &menubar{device: NewDevice()}))
+	defer server.Close()
+
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"http://localhost:3000", http.StatusOK},
+		{"http://127.0.0.1:8421", http.StatusOK},
+		{"https://example.com", http.StatusForbidden},
+		{"http://192.168.1.20", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", server.URL+"/presence", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("origin %q: expected %d, got %d", tt.origin, tt.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestServeReloaderAppliesRules(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	config := Config{IP: "192.168.1.100", Token: "test-token", Serve: &ServeConfig{
+		Presence: []PresenceRule{{Event: "home", Actions: []string{"on"}}},
+	}}
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	device := NewDevice()
+	if err := device.LoadConfig(); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	presence, err := newPresenceTracker(device, nil, config.Serve.Presence)
+	if err != nil {
+		t.Fatalf("newPresenceTracker should not fail: %v", err)
+	}
+	presence.home["alex"] = true
+	triggers := &triggerSet{device: device}
+	reloader := &serveReloader{device: device, presence: presence, triggers: triggers, settings: *config.Serve}
+
+	config.Serve.Presence = append(config.Serve.Presence, PresenceRule{Event: "away", Actions: []string{"off"}})
+	config.Serve.Triggers = map[string]TriggerRule{"doorbell": {Actions: []string{"on"}}}
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	reloader.reload()
+	if len(presence.rules) != 2 {
+		t.Errorf("expected the added rule to be applied, got %d rule(s)", len(presence.rules))
+	}
+	if names := triggers.names(); len(names) != 1 || names[0] != "doorbell" {
+		t.Errorf("expected the added trigger to be applied, got %v", names)
+	}
+	if home := presence.whoIsHome(); len(home) != 1 {
+		t.Errorf("who is home should be kept, got %v", home)
+	}
+
+	config.Serve.Presence = []PresenceRule{{Event: "sunrise", Actions: []string{"on"}}}
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	reloader.reload()
+	if len(presence.rules) != 2 {
+		t.Errorf("invalid rules should keep the previous ones, got %d rule(s)", len(presence.rules))
+	}
+}
+
+func TestCheckServeListen(t *testing.T) {
+	for _, tt := range []struct {
+		listen, secret string
+		ok             bool
+	}{
+		{defaultServeAddr, "", true},
+		{"localhost:8421", "", true},
+		{"[::1]:8421", "", true},
+		{":8421", "", false},
+		{"192.168.1.5:8421", "", false},
+		{":8421", "secret", true},
+		{"8421", "", false},
+	} {
+		if err := checkServeListen(tt.listen, tt.secret); (err == nil) != tt.ok {
+			t.Errorf("checkServeListen(%q, %q) = %v, expected ok %v", tt.listen, tt.secret, err, tt.ok)
+		}
+	}
+}
+
+func TestServeReloaderDoesNotSerializeRequests(t *testing.T) {
+	release := make(chan struct{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	reloader := &serveReloader{device: NewDevice()}
+	server := httptest.NewServer(reloader.handler(next))
+	defer server.Close()
+	defer close(release)
+
+	go http.Get(server.URL + "/slow")
+	time.Sleep(50 * time.Millisecond)
+	client := http.Client{Timeout: time.Second}
+	resp, err := client.Get(server.URL + "/fast")
+	if err != nil {
+		t.Fatalf("a request should not wait for a slow one: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestServeTriggers(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var sent []string
+	nanoleaf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sent = append(sent, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer nanoleaf.Close()
+
+	device := NewDevice()
+	device.config.IP = nanoleaf.URL
+	device.config.Token = "test-token"
+	device.record = true
+	quiet := &quietGate{now: time.Now, ip: nanoleaf.URL, source: "serve", priority: prioritySchedule, hold: time.Hour}
+
+	triggers, err := newTriggerSet(device, quiet, map[string]TriggerRule{
+		"doorbell": {Actions: []string{"brightness 90"}, Priority: "notification"},
+		"movie":    {Actions: []string{"brightness 10"}},
+	})
+	if err != nil {
+		t.Fatalf("newTriggerSet should not fail: %v", err)
+	}
+	server := httptest.NewServer(serveHandler(context.Background(), "secret", &presenceTracker{}, triggers, nil, &menubar{device: device}))
+	defer server.Close()
+
+	post := func(path string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", server.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	if status, body := post("/trigger/doorbell"); status != http.StatusOK || body["ran"] != true {
+		t.Fatalf("expected the doorbell trigger to run, got %d %v", status, body)
+	}
+	if len(sent) != 1 || sent[0] != `{"brightness":{"value":90}}` {
+		t.Errorf("expected the trigger's action sent, got %v", sent)
+	}
+	if last := lastChanges(nanoleaf.URL); last.Source != "trigger doorbell" {
+		t.Errorf("expected the change recorded as the trigger's, got %+v", last)
+	}
+	if _, body := post("/trigger/movie"); body["ran"] != false || !strings.Contains(body["held"].(string), "trigger doorbell") {
+		t.Errorf("expected a lower priority trigger to be held, got %v", body)
+	}
+	if status, _ := post("/trigger/fireworks"); status != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown trigger, got %d", status)
+	}
+
+	req, _ := http.NewRequest("POST", server.URL+"/trigger/doorbell", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a trigger without the secret to be refused, got %d", resp.StatusCode)
+	}
+
+	if _, err := newTriggerSet(nil, nil, map[string]TriggerRule{"bad": {Actions: []string{"dance"}}}); err == nil {
+		t.Error("expected an error for an invalid action")
+	}
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+// uiSession is where the UI was left, so the next run resumes there. It is
+// kept apart from the config because it changes on every run.
+type uiSession struct {
+	// Device is the IP of the active device
+	Device string `json:"device,omitempty"`
+	// Menu is the main menu choice the cursor was on. The label is kept
+	// rather than its index, which shifts with what the device supports.
+	Menu string `json:"menu,omitempty"`
+	// Effect is the effect last showing on the device
+	Effect string `json:"effect,omitempty"`
+}
+
+func getSessionPath() string {
+	return profilePath(".nanoleaf_session", ".json")
+}
+
+// loadSession reads the saved session, returning an empty one when nothing
+// has been saved yet
+func loadSession() (uiSession, error) {
+	var session uiSession
+	data, err := os.ReadFile(getSessionPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	err = json.Unmarshal(data, &session)
+	return session, err
+}
+
+func saveSession(session uiSession) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(getSessionPath(), data, 0600)
+}
+
+// isNamedEffect reports whether effect is one a user picked rather than
+// the placeholder the device reports for a solid color or a stream
+func isNamedEffect(effect string) bool {
+	return effect != "" && !(strings.HasPrefix(effect, "*") && strings.HasSuffix(effect, "*"))
+}
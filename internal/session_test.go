@@ -0,0 +1,40 @@
+package internal
+
+import "testing"
+
+func TestSession(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	session, err := loadSession()
+	if err != nil {
+		t.Fatalf("a missing session should not fail: %v", err)
+	}
+	if session != (uiSession{}) {
+		t.Errorf("expected an empty session, got %+v", session)
+	}
+
+	saved := uiSession{Device: "192.168.1.100", Menu: "[g] Effects Gallery", Effect: "Northern Lights"}
+	if err := saveSession(saved); err != nil {
+		t.Fatalf("saveSession should not fail: %v", err)
+	}
+	session, err = loadSession()
+	if err != nil {
+		t.Fatalf("loadSession should not fail: %v", err)
+	}
+	if session != saved {
+		t.Errorf("expected %+v, got %+v", saved, session)
+	}
+}
+
+func TestIsNamedEffect(t *testing.T) {
+	for effect, expected := range map[string]bool{
+		"Northern Lights": true,
+		"*Solid*":         false,
+		"*ExtControl*":    false,
+		"":                false,
+	} {
+		if got := isNamedEffect(effect); got != expected {
+			t.Errorf("isNamedEffect(%q) = %v, expected %v", effect, got, expected)
+		}
+	}
+}
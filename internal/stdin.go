@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// serveStdin runs the commands read from r, one per line, until r ends or
+// "quit" is read. Each command is an action as in macros ("on", "brightness
+// 40", "color #ff8800", "effect Northern Lights", "2s") or "status", and is
+// answered on w with a line starting with "ok" or "error:", so the program
+// writing the commands can wait for each one. Blank lines and lines
+// starting with # are skipped.
+func serveStdin(ctx context.Context, device *Device, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			return nil
+		}
+
+		reply, err := runStdinCommand(ctx, device, line)
+		if err != nil {
+			// Keep the protocol one line per command
+			fmt.Fprintf(w, "error: %s\n", strings.ReplaceAll(err.Error(), "\n", " "))
+		} else if reply != "" {
+			fmt.Fprintf(w, "ok %s\n", reply)
+		} else {
+			fmt.Fprintln(w, "ok")
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+func runStdinCommand(ctx context.Context, device *Device, line string) (string, error) {
+	if line == "status" {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+		status, err := device.GetStatus(ctx)
+		if err != nil {
+			return "", err
+		}
+		return status.String(), nil
+	}
+
+	a, err := parseAction(line)
+	if err != nil {
+		return "", err
+	}
+	// Delays and preset transitions run for their whole duration
+	timeout := commandTimeout + a.delay
+	if preset, ok := device.GetConfig().Presets[a.arg]; ok && a.verb == "preset" {
+		timeout += preset.transition()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return "", a.run(ctx, device)
+}
+
+func runStdin(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError("--stdin < COMMANDS")
+	}
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	return serveStdin(ctx, device, os.Stdin, os.Stdout)
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeStdin(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut:
+			paths = append(paths, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/v1/test-token/state/on":
+			w.Write([]byte(`{"value":true}`))
+		case r.URL.Path == "/api/v1/test-token/state/brightness":
+			w.Write([]byte(`{"value":40}`))
+		case r.URL.Path == "/api/v1/test-token/effects/select":
+			w.Write([]byte(`"Northern Lights"`))
+		}
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	input := "on\n\n# dim for the evening\nbrightness 40\ndance\nstatus\nquit\noff\n"
+	var out bytes.Buffer
+	if err := serveStdin(context.Background(), device, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("serveStdin should not fail: %v", err)
+	}
+
+	expected := "ok\nok\nerror: unknown action \"dance\"\nok On · 40% · Northern Lights\n"
+	if out.String() != expected {
+		t.Errorf("expected replies %q, got %q", expected, out.String())
+	}
+	if len(paths) != 2 {
+		t.Errorf("expected two updates before quit, got %v", paths)
+	}
+}
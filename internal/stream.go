@@ -0,0 +1,336 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamPort is the UDP port for external control (v2) frames
+var streamPort = 60222
+
+// PanelColor is the color of one panel in a streamed frame
+type PanelColor struct {
+	PanelID int
+	R, G, B uint8
+}
+
+// Stream transports. Frames go over UDP, and with auto they fall back to
+// REST display commands when the network turns UDP away, e.g. between VLANs.
+const (
+	transportAuto = "auto"
+	transportUDP  = "udp"
+	transportREST = "rest"
+)
+
+// restStreamFPS caps the frame rate over REST, which takes a request a frame
+const restStreamFPS = 5
+
+// streamSession sends frames to a device in external control mode, or as
+// static display commands once it fell back to REST
+type streamSession struct {
+	conn      net.Conn
+	device    *Device
+	transport string
+	rest      bool
+	// refused reports the error a read of the UDP session ran into, see
+	// watchRefusal
+	refused chan error
+}
+
+// StartStream switches the device to external control and opens the UDP
+// session, unless the config asks for REST
+func (d *Device) StartStream(ctx context.Context) (*streamSession, error) {
+	transport := d.GetConfig().StreamTransport
+	if transport == "" {
+		transport = transportAuto
+	}
+	if transport != transportAuto && transport != transportUDP && transport != transportREST {
+		return nil, fmt.Errorf("unknown stream transport %q, expected auto, udp or rest", transport)
+	}
+	if err := d.client.lowerToCap(ctx, d.GetConfig().IP, d.GetConfig().Token); err != nil {
+		return nil, fmt.Errorf("failed to cap the brightness: %w", err)
+	}
+	if transport == transportREST {
+		return &streamSession{device: d, transport: transport, rest: true}, nil
+	}
+
+	effect := map[string]interface{}{
+		"command":           "display",
+		"animType":          "extControl",
+		"extControlVersion": "v2",
+	}
+	err := d.client.writeEffect(ctx, d.GetConfig().IP, d.GetConfig().Token, effect)
+	if err := d.logAction("start streaming", err); err != nil {
+		return nil, fmt.Errorf("failed to enable streaming: %w", err)
+	}
+
+	session := &streamSession{device: d, transport: transport}
+	session.conn, err = net.Dial("udp", net.JoinHostPort(deviceHost(d.GetConfig().IP), strconv.Itoa(streamPort)))
+	if err != nil && !session.fallBack(err) {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	if err == nil {
+		session.watchRefusal()
+	}
+	return session, nil
+}
+
+// watchRefusal reads from the UDP session until it is closed. The device
+// never answers frames, so a read only ends early on the port unreachable
+// reply of a network turning UDP away, which some systems report on a read
+// but not on the next write. A network that drops the frames without a
+// reply cannot be told from a device showing them.
+func (s *streamSession) watchRefusal() {
+	s.refused = make(chan error, 1)
+	go func(conn net.Conn, refused chan<- error) {
+		buf := make([]byte, 64)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					refused <- err
+				}
+				return
+			}
+		}
+	}(s.conn, s.refused)
+}
+
+// sendFrame sends one frame; transition is in tenths of a second
+func (s *streamSession) sendFrame(ctx context.Context, colors []PanelColor, transition int) error {
+	if s.rest {
+		return s.device.client.writeEffect(ctx, s.device.GetConfig().IP, s.device.GetConfig().Token, staticFrameEffect(colors, transition))
+	}
+	select {
+	case err := <-s.refused:
+		return err
+	default:
+	}
+	buf := framePool.Get().(*[]byte)
+	*buf = appendFrame((*buf)[:0], colors, transition)
+	_, err := s.conn.Write(*buf)
+	framePool.Put(buf)
+	return err
+}
+
+// fallBack switches an auto session to REST after UDP failed with err, and
+// reports whether it did. A closed or filtered port shows up as an error on
+// the first writes or reads, once the network reported the port
+// unreachable.
+func (s *streamSession) fallBack(err error) bool {
+	if s.rest || s.transport != transportAuto {
+		return false
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.rest = true
+	if s.device.warn != nil {
+		s.device.warn(fmt.Sprintf("UDP streaming is blocked on this network (%v), falling back to REST at %d fps", err, restStreamFPS))
+	}
+	return true
+}
+
+func (s *streamSession) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// staticFrameEffect turns a frame into a static display command, the REST
+// stand-in for a streamed frame
+func staticFrameEffect(colors []PanelColor, transition int) map[string]interface{} {
+	data := []string{strconv.Itoa(len(colors))}
+	for _, c := range colors {
+		data = append(data, fmt.Sprintf("%d 1 %d %d %d 0 %d", c.PanelID, c.R, c.G, c.B, transition))
+	}
+	return map[string]interface{}{
+		"command":  "display",
+		"animType": "static",
+		"animData": strings.Join(data, " "),
+		"loop":     false,
+		"palette":  []interface{}{},
+	}
+}
+
+// encodeFrame builds an external control v2 frame: the panel count followed
+// by id, RGBW and transition time for each panel, all big-endian
+func encodeFrame(colors []PanelColor, transition int) []byte {
+	return appendFrame(make([]byte, 0, 2+len(colors)*8), colors, transition)
+}
+
+// appendFrame appends the encoded frame to dst, which lets a stream reuse
+// one buffer for every frame instead of allocating at 60 fps
+func appendFrame(dst []byte, colors []PanelColor, transition int) []byte {
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(colors)))
+	for _, c := range colors {
+		dst = binary.BigEndian.AppendUint16(dst, uint16(c.PanelID))
+		dst = append(dst, c.R, c.G, c.B, 0)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(transition))
+	}
+	return dst
+}
+
+// framePool holds the buffers frames are encoded into before they are sent
+var framePool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 2+64*8)
+		return &buf
+	},
+}
+
+// deviceHost extracts the host from a configured device address, which is
+// normally a bare IP but may be a full URL
+func deviceHost(ip string) string {
+	if strings.HasPrefix(ip, "http") {
+		if u, err := url.Parse(ip); err == nil {
+			return u.Hostname()
+		}
+	}
+	return ip
+}
+
+func runStream(ctx context.Context, args []string) error {
+	fs := newFlagSet("stream")
+	name := fs.String("generator", "rainbow-wave", "generator name ("+strings.Join(generatorNames(), ", ")+")")
+	fps := fs.Int("fps", 20, "frames per second")
+	speed := fs.Float64("speed", 1, "animation speed multiplier")
+	palette := fs.String("palette", "", "comma separated colors, e.g. red,orange,#ffcc00")
+	duration := fs.Duration("duration", 0, "stop after this long (0 runs until ctrl+c)")
+	record := fs.String("record", "", "also record the frames to this file, for replay")
+	onExit := streamExitFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if *fps < 1 || *fps > 60 {
+		return invalidArgs(fmt.Errorf("--fps must be between 1 and 60"))
+	}
+
+	opts := GeneratorOptions{Speed: *speed}
+	if *palette != "" {
+		for _, name := range strings.Split(*palette, ",") {
+			color, err := parseColor(name)
+			if err != nil {
+				return err
+			}
+			opts.Palette = append(opts.Palette, color)
+		}
+	}
+
+	generator, err := newGenerator(*name, opts)
+	if err != nil {
+		return err
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	capsCtx, cancel := device.createContext()
+	caps, err := device.GetCapabilities(capsCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if caps.StreamingVersion < 2 {
+		return fmt.Errorf("%s firmware %s does not support streaming, update the firmware first", caps.Model, caps.Firmware)
+	}
+
+	generator, stopRecording, err := startRecording(generator, *record)
+	if err != nil {
+		return err
+	}
+
+	statusf("Streaming %s to %s at %d fps (ctrl+c to stop)\n", *name, device.GetDeviceIP(), *fps)
+	scheduler := newFrameScheduler(*fps)
+	err = streamWithExit(ctx, device, generator, scheduler, *onExit)
+	if scheduler.Stats().Sent > 0 {
+		statusf("%s\n", scheduler.Stats())
+	}
+	if recErr := stopRecording(); err == nil {
+		err = recErr
+	}
+	return err
+}
+
+// streamGenerator renders generator frames to the device at the pace of
+// scheduler until ctx is done
+func streamGenerator(ctx context.Context, device *Device, generator EffectGenerator, scheduler *frameScheduler) error {
+	setupCtx, cancel := device.createContext()
+	layout, err := device.GetLayout(setupCtx)
+	if err == nil && len(layout.LightPanels()) == 0 {
+		err = fmt.Errorf("device layout has no light panels")
+	}
+	var session *streamSession
+	if err == nil {
+		session, err = device.StartStream(setupCtx)
+	}
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	config := device.GetConfig()
+	factors := config.BrightnessCorrection.factors(layout)
+	var flashes *flashLimiter
+	if config.Photosensitive {
+		flashes = newFlashLimiter()
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	// frame is drawn again in place each time, so a steady stream does not
+	// allocate per frame
+	var frame []PanelColor
+	start := time.Now()
+	scheduler.start(start)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		frameStart := time.Now()
+		frame = nextFrame(generator, frame, layout, frameStart.Sub(start))
+		scheduler.record(frame)
+		correctFrame(frame, factors)
+		if flashes != nil {
+			flashes.limit(frame, frameStart.Sub(start))
+		}
+		err := session.sendFrame(ctx, frame, 1)
+		if err != nil && session.fallBack(err) {
+			err = session.sendFrame(ctx, frame, 1)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to send frame: %w", err)
+		}
+		if session.rest {
+			scheduler.useREST(restStreamFPS)
+		}
+		now := time.Now()
+		scheduler.sent(now, now.Sub(frameStart))
+		timer.Reset(scheduler.wait(now))
+	}
+}
@@ -0,0 +1,263 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEncodeFrame(t *testing.T) {
+	frame := encodeFrame([]PanelColor{
+		{PanelID: 258, R: 255, G: 128, B: 0},
+		{PanelID: 7, R: 1, G: 2, B: 3},
+	}, 1)
+
+	expected := []byte{
+		0, 2,
+		1, 2, 255, 128, 0, 0, 0, 1,
+		0, 7, 1, 2, 3, 0, 0, 1,
+	}
+	if !bytes.Equal(frame, expected) {
+		t.Errorf("expected frame %v, got %v", expected, frame)
+	}
+}
+
+func TestAppendFrameReusesBuffer(t *testing.T) {
+	colors := make([]PanelColor, 60)
+	for i := range colors {
+		colors[i] = PanelColor{PanelID: i + 1, R: uint8(i), G: 128, B: 255}
+	}
+	buf := appendFrame(nil, colors, 1)
+	if !bytes.Equal(buf, encodeFrame(colors, 1)) {
+		t.Fatal("appendFrame should encode as encodeFrame does")
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = appendFrame(buf[:0], colors, 1)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations once the buffer is large enough, got %v", allocs)
+	}
+}
+
+func TestStreamFrameDoesNotAllocate(t *testing.T) {
+	var layout Layout
+	for i := 0; i < 60; i++ {
+		layout.Panels = append(layout.Panels, Panel{ID: i + 1, X: i % 10 * 100, Y: i / 10 * 100, ShapeType: 7})
+	}
+	correction := &BrightnessCorrection{ShapeScale: map[int]float64{7: 0.8}}
+	factors := correction.factors(layout)
+	generator, err := newGenerator("plasma", GeneratorOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := nextFrame(generator, nil, layout, 0)
+	buf := appendFrame(nil, frame, 1)
+	at := time.Duration(0)
+	allocs := testing.AllocsPerRun(100, func() {
+		at += 16 * time.Millisecond
+		frame = nextFrame(generator, frame, layout, at)
+		correctFrame(frame, factors)
+		buf = appendFrame(buf[:0], frame, 1)
+	})
+	if allocs != 0 {
+		t.Errorf("expected a steady stream not to allocate per frame, got %v", allocs)
+	}
+}
+
+func TestDeviceHost(t *testing.T) {
+	if host := deviceHost("192.168.1.100"); host != "192.168.1.100" {
+		t.Errorf("expected 192.168.1.100, got %s", host)
+	}
+	if host := deviceHost("http://127.0.0.1:16021"); host != "127.0.0.1" {
+		t.Errorf("expected 127.0.0.1, got %s", host)
+	}
+}
+
+func TestStreamGenerator(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer udp.Close()
+
+	originalPort := streamPort
+	streamPort = udp.LocalAddr().(*net.UDPAddr).Port
+	defer func() { streamPort = originalPort }()
+
+	var extControl bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"sideLength":150,"positionData":[{"panelId":5,"x":0,"y":0,"shapeType":7},{"panelId":6,"x":150,"y":0,"shapeType":7},{"panelId":0,"x":75,"y":0,"shapeType":12}]}`))
+			return
+		}
+		var payload map[string]map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		extControl = payload["write"]["animType"] == "extControl" && payload["write"]["extControlVersion"] == "v2"
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := streamGenerator(ctx, device, rainbowWave{}, newFrameScheduler(20)); err != nil {
+		t.Fatalf("streamGenerator should not fail: %v", err)
+	}
+	if !extControl {
+		t.Error("expected external control v2 to be enabled")
+	}
+
+	buf := make([]byte, 64)
+	udp.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := udp.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("expected a frame to be received: %v", err)
+	}
+	if n != 2+2*8 || buf[1] != 2 {
+		t.Errorf("expected a frame for the 2 light panels, got %v", buf[:n])
+	}
+}
+
+func TestStreamFallsBackToREST(t *testing.T) {
+	// A port nothing listens on, so the network refuses the frames
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedPort := udp.LocalAddr().(*net.UDPAddr).Port
+	udp.Close()
+
+	originalPort := streamPort
+	streamPort = closedPort
+	defer func() { streamPort = originalPort }()
+
+	var mu sync.Mutex
+	var static []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"sideLength":150,"positionData":[{"panelId":5,"x":0,"y":0,"shapeType":7},{"panelId":6,"x":150,"y":0,"shapeType":7}]}`))
+			return
+		}
+		var payload map[string]map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload["write"]["animType"] == "static" {
+			mu.Lock()
+			static = append(static, payload["write"]["animData"].(string))
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var warnings []string
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.warn = func(message string) { warnings = append(warnings, message) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	scheduler := newFrameScheduler(20)
+	if err := streamGenerator(ctx, device, rainbowWave{}, scheduler); err != nil {
+		t.Fatalf("streamGenerator should fall back instead of failing: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(static) == 0 || !strings.HasPrefix(static[0], "2 5 1 ") {
+		t.Fatalf("expected frames as static display commands, got %v", static)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "REST") {
+		t.Errorf("expected one warning about the fallback, got %v", warnings)
+	}
+	if stats := scheduler.Stats(); !stats.REST || stats.Sent > restStreamFPS {
+		t.Errorf("expected at most %d REST frames in half a second, got %+v", restStreamFPS, stats)
+	}
+
+	device.config.StreamTransport = "carrier-pigeon"
+	if _, err := device.StartStream(context.Background()); err == nil {
+		t.Error("expected an unknown transport to be rejected")
+	}
+}
+
+func TestStreamSessionWatchesRefusal(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedAddr := udp.LocalAddr().String()
+	udp.Close()
+
+	conn, err := net.Dial("udp", closedAddr)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	session := &streamSession{conn: conn}
+	session.watchRefusal()
+	defer session.Close()
+
+	conn.Write(encodeFrame([]PanelColor{{PanelID: 5}}, 1))
+	select {
+	case <-session.refused:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the port unreachable reply to be reported")
+	}
+}
+
+func TestStaticFrameEffect(t *testing.T) {
+	effect := staticFrameEffect([]PanelColor{{PanelID: 5, R: 255}, {PanelID: 6, G: 128, B: 7}}, 1)
+	if effect["animType"] != "static" || effect["animData"] != "2 5 1 255 0 0 0 1 6 1 0 128 7 0 1" {
+		t.Errorf("unexpected static frame %v", effect)
+	}
+}
+
+// BenchmarkStreamFrame draws, corrects and encodes frames of every generator
+// as a stream at 60 fps to a large layout would
+func BenchmarkStreamFrame(b *testing.B) {
+	var layout Layout
+	for i := 0; i < 60; i++ {
+		layout.Panels = append(layout.Panels, Panel{ID: i + 1, X: i % 10 * 100, Y: i / 10 * 100, ShapeType: 7})
+	}
+	correction := &BrightnessCorrection{ShapeScale: map[int]float64{7: 0.8}}
+	factors := correction.factors(layout)
+
+	for _, name := range generatorNames() {
+		b.Run(name, func(b *testing.B) {
+			generator, err := newGenerator(name, GeneratorOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			var frame []PanelColor
+			var buf []byte
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				frame = nextFrame(generator, frame, layout, time.Duration(i)*time.Second/60)
+				correctFrame(frame, factors)
+				buf = appendFrame(buf[:0], frame, 1)
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeFrame(b *testing.B) {
+	colors := make([]PanelColor, 60)
+	for i := range colors {
+		colors[i] = PanelColor{PanelID: i + 1, R: uint8(i), G: 128, B: 255}
+	}
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = appendFrame(buf[:0], colors, 1)
+	}
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// What the panels show once a stream, music or replay ends. Any other value
+// names an effect to switch to.
+const (
+	// streamExitRestore brings back the state from before the stream
+	streamExitRestore = "restore"
+	// streamExitKeep keeps the last frame as a static display
+	streamExitKeep = "keep"
+	// streamExitOff turns the panels off
+	streamExitOff = "off"
+)
+
+// streamExitFlag adds --on-exit to the flags of a streaming command
+func streamExitFlag(fs *flag.FlagSet) *string {
+	return fs.String("on-exit", "", "what the panels show afterwards: restore, keep, off or an effect name (default streamExit in the config, else restore)")
+}
+
+// streamExit leaves external control mode after a stream. Without it the
+// device stays frozen on the last frame until it is given another command.
+type streamExit struct {
+	device *Device
+	mode   string
+	before deviceState
+	effect string
+	// after names what the state is restored after, for the history
+	after string
+}
+
+// prepareStreamExit reads what the exit needs before the stream takes over
+// the device, which for restore is the state and effect shown so far. An
+// exit effect the device does not have is refused here, rather than once
+// the stream is over.
+func prepareStreamExit(ctx context.Context, device *Device, mode string) (*streamExit, error) {
+	if mode == "" {
+		mode = device.GetConfig().StreamExit
+	}
+	if mode == "" {
+		mode = streamExitRestore
+	}
+	exit := &streamExit{device: device, mode: mode, after: "streaming"}
+	ip, token := device.GetConfig().IP, device.GetConfig().Token
+	switch mode {
+	case streamExitRestore:
+	case streamExitKeep, streamExitOff:
+		return exit, nil
+	default:
+		names, err := device.client.getEffectNames(ctx, ip, token)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(names, mode) {
+			return nil, fmt.Errorf("no effect %q on the device to show after streaming, expected %s, %s, %s or an effect name", mode, streamExitRestore, streamExitKeep, streamExitOff)
+		}
+		return exit, nil
+	}
+
+	var err error
+	if exit.before, err = device.client.getState(ctx, ip, token); err != nil {
+		return nil, err
+	}
+	if exit.before.ColorMode == "effect" {
+		if exit.effect, err = device.client.getSelectedEffect(ctx, ip, token); err != nil {
+			return nil, err
+		}
+	}
+	return exit, nil
+}
+
+// park applies the exit once the stream ended, showing lastFrame for keep.
+// Streams usually end on ctrl+c, which canceled the command's context, so
+// park has a timeout of its own.
+func (e *streamExit) park(lastFrame []PanelColor) error {
+	ctx, cancel := e.device.createContext()
+	defer cancel()
+
+	switch e.mode {
+	case streamExitRestore:
+		return e.restore(ctx)
+	case streamExitKeep:
+		if len(lastFrame) == 0 {
+			return nil
+		}
+		return e.device.DisplayEffect(ctx, staticFrameEffect(lastFrame, 0))
+	case streamExitOff:
+		return e.device.TurnOff(ctx)
+	default:
+		return e.device.SelectEffect(ctx, e.mode)
+	}
+}
+
+// restore shows the effect, color or white temperature from before the
+// stream again. Effects the device names with asterisks, such as *Static*
+// for a custom display, cannot be selected, so only brightness and power
+// come back for those.
+func (e *streamExit) restore(ctx context.Context) error {
+	d := e.device
+	ip, token := d.GetConfig().IP, d.GetConfig().Token
+	var steps []func() error
+	switch {
+	case e.before.ColorMode == "effect" && e.effect != "" && !strings.HasPrefix(e.effect, "*"):
+		steps = append(steps,
+			func() error { return d.client.selectEffect(ctx, ip, token, e.effect) },
+			func() error { return d.client.setBrightness(ctx, ip, token, e.before.Brightness) })
+	case e.before.ColorMode == "ct":
+		steps = append(steps,
+			func() error { return d.client.setColorTemp(ctx, ip, token, e.before.ColorTemp) },
+			func() error { return d.client.setBrightness(ctx, ip, token, e.before.Brightness) })
+	case e.before.ColorMode == "hs":
+		steps = append(steps, func() error {
+			return d.client.setHSB(ctx, ip, token, e.before.Hue, e.before.Sat, e.before.Brightness)
+		})
+	default:
+		steps = append(steps, func() error { return d.client.setBrightness(ctx, ip, token, e.before.Brightness) })
+	}
+	if !e.before.On {
+		steps = append(steps, func() error { return d.client.setPower(ctx, ip, token, false) })
+	}
+
+	var err error
+	for _, step := range steps {
+		if err = step(); err != nil {
+			break
+		}
+	}
+	return d.logAction("restore state after "+e.after, err)
+}
+
+// streamWithExit streams like streamGenerator and then parks the device as
+// exitMode, or the config, asks
+func streamWithExit(ctx context.Context, device *Device, generator EffectGenerator, scheduler *frameScheduler, exitMode string) error {
+	prepareCtx, cancel := device.createContext()
+	exit, err := prepareStreamExit(prepareCtx, device, exitMode)
+	cancel()
+	if err != nil {
+		return err
+	}
+	err = streamGenerator(ctx, device, generator, scheduler)
+	if exitErr := exit.park(scheduler.LastFrame()); err == nil && exitErr != nil {
+		err = fmt.Errorf("stream stopped but the panels could not be reset: %w", exitErr)
+	}
+	return err
+}
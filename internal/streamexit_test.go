@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamWithExit(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer udp.Close()
+
+	originalPort := streamPort
+	streamPort = udp.LocalAddr().(*net.UDPAddr).Port
+	defer func() { streamPort = originalPort }()
+
+	tests := []struct {
+		name  string
+		mode  string
+		state string
+		// want are the updates after the stream, as path and body
+		want []string
+	}{
+		{
+			name:  "restores the effect",
+			state: `{"on":{"value":true},"brightness":{"value":40},"colorMode":"effect"}`,
+			want:  []string{`effects {"select":"Forest"}`, `state {"brightness":{"value":40}}`},
+		},
+		{
+			name:  "restores color and power",
+			state: `{"on":{"value":false},"brightness":{"value":70},"hue":{"value":200},"sat":{"value":90},"colorMode":"hs"}`,
+			want:  []string{`state {"brightness":{"value":70},"hue":{"value":200},"sat":{"value":90}}`, `state {"on":{"value":false}}`},
+		},
+		{
+			name:  "restores white temperature",
+			state: `{"on":{"value":true},"brightness":{"value":30},"ct":{"value":2700},"colorMode":"ct"}`,
+			want:  []string{`state {"ct":{"value":2700}}`, `state {"brightness":{"value":30}}`},
+		},
+		{
+			name: "switches to an exit effect",
+			mode: "Northern Lights",
+			want: []string{`effects {"select":"Northern Lights"}`},
+		},
+		{
+			name: "turns off",
+			mode: streamExitOff,
+			want: []string{`state {"on":{"value":false}}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			var mu sync.Mutex
+			var updates []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path := strings.TrimPrefix(r.URL.Path, "/api/v1/test-token/")
+				if r.Method == http.MethodGet {
+					switch path {
+					case "state":
+						w.Write([]byte(tt.state))
+					case "effects/select":
+						w.Write([]byte(`"Forest"`))
+					case "effects/effectsList":
+						w.Write([]byte(`["Forest","Northern Lights"]`))
+					default:
+						w.Write([]byte(`{"sideLength":150,"positionData":[{"panelId":5,"x":0,"y":0,"shapeType":7}]}`))
+					}
+					return
+				}
+				var payload map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&payload)
+				if write, ok := payload["write"].(map[string]interface{}); !ok || write["animType"] != "extControl" {
+					body, _ := json.Marshal(payload)
+					mu.Lock()
+					updates = append(updates, path+" "+string(body))
+					mu.Unlock()
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			device := NewDevice()
+			device.config.IP = server.URL
+			device.config.Token = "test-token"
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if err := streamWithExit(ctx, device, rainbowWave{}, newFrameScheduler(20), tt.mode); err != nil {
+				t.Fatalf("streamWithExit should not fail: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(updates, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("expected updates\n%s\ngot\n%s", strings.Join(tt.want, "\n"), strings.Join(updates, "\n"))
+			}
+		})
+	}
+}
+
+func TestStreamExitKeepsLastFrame(t *testing.T) {
+	var payload map[string]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	exit, err := prepareStreamExit(context.Background(), device, streamExitKeep)
+	if err != nil {
+		t.Fatalf("prepareStreamExit should not fail: %v", err)
+	}
+	if err := exit.park([]PanelColor{{PanelID: 5, R: 255, G: 128}}); err != nil {
+		t.Fatalf("park should not fail: %v", err)
+	}
+	if payload["write"]["animType"] != "static" || payload["write"]["animData"] != "1 5 1 255 128 0 0 0" {
+		t.Errorf("expected the last frame as a static display, got %v", payload["write"])
+	}
+}
+
+func TestStreamExitRefusesUnknownEffect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["Forest","Northern Lights"]`))
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	device.config.StreamExit = "restor"
+
+	if _, err := prepareStreamExit(context.Background(), device, ""); err == nil || !strings.Contains(err.Error(), `"restor"`) {
+		t.Errorf("expected a mistyped streamExit to be refused before streaming, got %v", err)
+	}
+	if _, err := prepareStreamExit(context.Background(), device, "Forest"); err != nil {
+		t.Errorf("expected an installed effect to be accepted, got %v", err)
+	}
+}
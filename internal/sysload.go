@@ -0,0 +1,300 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where the system load is read from. Only Linux has them; they are
+// variables so tests can point them at files of their own.
+var (
+	procStat     = "/proc/stat"
+	procMeminfo  = "/proc/meminfo"
+	thermalZones = "/sys/class/thermal/thermal_zone*/temp"
+)
+
+// The metrics system-load can show
+const (
+	metricCPU         = "cpu"
+	metricMemory      = "memory"
+	metricTemperature = "temperature"
+)
+
+// cpuTimes are the jiffies the CPUs spent busy and in total since boot
+type cpuTimes struct {
+	busy, total uint64
+}
+
+// readCPUTimes sums the first line of /proc/stat, counting idle and
+// iowait as not busy
+func readCPUTimes() (cpuTimes, error) {
+	file, err := os.Open(procStat)
+	if err != nil {
+		return cpuTimes{}, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		var times cpuTimes
+		for i, field := range fields[1:] {
+			n, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return cpuTimes{}, fmt.Errorf("%s: %w", procStat, err)
+			}
+			times.total += n
+			// idle and iowait are the 4th and 5th columns
+			if i != 3 && i != 4 {
+				times.busy += n
+			}
+		}
+		return times, nil
+	}
+	return cpuTimes{}, fmt.Errorf("%s has no cpu line", procStat)
+}
+
+// cpuLoad is the share of the time between two readings the CPUs were busy
+func cpuLoad(before, after cpuTimes) float64 {
+	if after.total <= before.total {
+		return 0
+	}
+	return float64(after.busy-before.busy) / float64(after.total-before.total)
+}
+
+// readMemoryUse is the share of memory not available to new programs
+func readMemoryUse() (float64, error) {
+	data, err := os.ReadFile(procMeminfo)
+	if err != nil {
+		return 0, err
+	}
+	values := make(map[string]float64)
+	for _, line := range strings.Split(string(data), "\n") {
+		name, rest, ok := strings.Cut(line, ":")
+		if fields := strings.Fields(rest); ok && len(fields) > 0 {
+			values[name], _ = strconv.ParseFloat(fields[0], 64)
+		}
+	}
+	if values["MemTotal"] == 0 {
+		return 0, fmt.Errorf("%s has no MemTotal", procMeminfo)
+	}
+	return 1 - values["MemAvailable"]/values["MemTotal"], nil
+}
+
+// readTemperature returns the hottest thermal zone in °C
+func readTemperature() (float64, error) {
+	zones, _ := filepath.Glob(thermalZones)
+	hottest, found := 0.0, false
+	for _, zone := range zones {
+		data, err := os.ReadFile(zone)
+		if err != nil {
+			continue
+		}
+		millidegrees, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+		if err != nil {
+			continue
+		}
+		if c := millidegrees / 1000; !found || c > hottest {
+			hottest, found = c, true
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("no readable thermal zone at %s", thermalZones)
+	}
+	return hottest, nil
+}
+
+// loadSampler reads the chosen metrics as levels from 0 (idle, cool) to 1
+// (busy, hot)
+type loadSampler struct {
+	metrics          []string
+	minTemp, maxTemp float64
+	lastCPU          cpuTimes
+}
+
+// sample reads every metric. CPU load is measured between two samples, so
+// the first reads as idle.
+func (s *loadSampler) sample() ([]float64, error) {
+	levels := make([]float64, len(s.metrics))
+	for i, metric := range s.metrics {
+		switch metric {
+		case metricCPU:
+			times, err := readCPUTimes()
+			if err != nil {
+				return nil, err
+			}
+			if s.lastCPU.total > 0 {
+				levels[i] = cpuLoad(s.lastCPU, times)
+			}
+			s.lastCPU = times
+		case metricMemory:
+			use, err := readMemoryUse()
+			if err != nil {
+				return nil, err
+			}
+			levels[i] = use
+		case metricTemperature:
+			c, err := readTemperature()
+			if err != nil {
+				return nil, err
+			}
+			levels[i] = (c - s.minTemp) / (s.maxTemp - s.minTemp)
+		}
+		levels[i] = min(max(levels[i], 0), 1)
+	}
+	return levels, nil
+}
+
+// loadColor runs from green through yellow to red as level goes from 0 to 1
+func loadColor(level float64) rgbColor {
+	green, yellow, red := rgbColor{0, 255, 0}, rgbColor{255, 200, 0}, rgbColor{255, 0, 0}
+	if level < 0.5 {
+		return mixColors(green, yellow, level*2)
+	}
+	return mixColors(yellow, red, (level-0.5)*2)
+}
+
+// loadGenerator colors the panels by the latest levels, the panels split
+// from left to right into a band per metric. The colors glide to a new
+// reading rather than jump, since readings come seconds apart.
+type loadGenerator struct {
+	mu     sync.Mutex
+	levels []float64
+	shown  []float64
+	last   time.Duration
+	// order lists the light panels of the layout by x, worked out once
+	order []Panel
+}
+
+func (g *loadGenerator) set(levels []float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.levels = append(g.levels[:0], levels...)
+}
+
+func (g *loadGenerator) NextFrame(layout Layout, t time.Duration) []PanelColor {
+	return g.AppendFrame(nil, layout, t)
+}
+
+func (g *loadGenerator) AppendFrame(dst []PanelColor, layout Layout, t time.Duration) []PanelColor {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.order == nil {
+		g.order = layout.LightPanels()
+		sort.SliceStable(g.order, func(i, j int) bool { return g.order[i].X < g.order[j].X })
+	}
+	if len(g.shown) != len(g.levels) {
+		g.shown = append(g.shown[:0], g.levels...)
+	}
+	step := min((t-g.last).Seconds()*2, 1)
+	g.last = t
+	for i := range g.shown {
+		g.shown[i] += (g.levels[i] - g.shown[i]) * step
+	}
+
+	dst = dst[:0]
+	for i, p := range g.order {
+		c := rgbColor{}
+		if len(g.shown) > 0 {
+			c = loadColor(g.shown[i*len(g.shown)/len(g.order)])
+		}
+		dst = append(dst, PanelColor{PanelID: p.ID, R: c.R, G: c.G, B: c.B})
+	}
+	return dst
+}
+
+// parseMetrics reads --metrics, a comma separated list of metrics
+func parseMetrics(s string) ([]string, error) {
+	var metrics []string
+	for _, metric := range strings.Split(s, ",") {
+		metric = strings.TrimSpace(metric)
+		switch metric {
+		case metricCPU, metricMemory, metricTemperature:
+			metrics = append(metrics, metric)
+		default:
+			return nil, fmt.Errorf("--metrics: unknown metric %q, expected %s, %s or %s", metric, metricCPU, metricMemory, metricTemperature)
+		}
+	}
+	return metrics, nil
+}
+
+func runSystemLoad(ctx context.Context, args []string) error {
+	fs := newFlagSet("system-load")
+	metricsFlag := fs.String("metrics", metricCPU, "comma separated metrics to show left to right: cpu, memory, temperature")
+	interval := fs.Duration("interval", 2*time.Second, "how often to read the metrics")
+	minTemp := fs.Float64("min-temp", 40, "temperature in °C shown as green")
+	maxTemp := fs.Float64("max-temp", 90, "temperature in °C shown as red")
+	fps := fs.Int("fps", 10, "frames per second")
+	onExit := streamExitFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	metrics, err := parseMetrics(*metricsFlag)
+	if err != nil {
+		return invalidArgs(err)
+	}
+	if err := checkInterval(*interval); err != nil {
+		return err
+	}
+	if *maxTemp <= *minTemp {
+		return invalidArgs(fmt.Errorf("--max-temp must be above --min-temp"))
+	}
+	if *fps < 1 || *fps > 60 {
+		return invalidArgs(fmt.Errorf("--fps must be between 1 and 60"))
+	}
+
+	sampler := &loadSampler{metrics: metrics, minTemp: *minTemp, maxTemp: *maxTemp}
+	levels, err := sampler.sample()
+	if err != nil {
+		return fmt.Errorf("failed to read the system load, which needs Linux: %w", err)
+	}
+	generator := &loadGenerator{}
+	generator.set(levels)
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	sampled := make(chan error, 1)
+	go func() {
+		defer stop()
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				sampled <- nil
+				return
+			case <-ticker.C:
+			}
+			levels, err := sampler.sample()
+			if err != nil {
+				sampled <- err
+				return
+			}
+			generator.set(levels)
+		}
+	}()
+
+	statusf("Showing %s on %s (ctrl+c to stop)\n", strings.Join(metrics, ", "), device.GetDeviceIP())
+	if err := streamWithExit(ctx, device, generator, newFrameScheduler(*fps), *onExit); err != nil {
+		return err
+	}
+	if err := <-sampled; err != nil {
+		return fmt.Errorf("failed to read the system load: %w", err)
+	}
+	return nil
+}
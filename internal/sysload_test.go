@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSampler(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	oldStat, oldMeminfo, oldZones := procStat, procMeminfo, thermalZones
+	defer func() { procStat, procMeminfo, thermalZones = oldStat, oldMeminfo, oldZones }()
+	procStat = write("stat", "cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 100 0 100 700 100 0 0 0 0 0\n")
+	procMeminfo = write("meminfo", "MemTotal:       16000000 kB\nMemFree:         1000000 kB\nMemAvailable:    4000000 kB\n")
+	write("zone0", "45000\n")
+	write("zone1", "65000\n")
+	thermalZones = filepath.Join(dir, "zone*")
+
+	sampler := &loadSampler{metrics: []string{metricCPU, metricMemory, metricTemperature}, minTemp: 40, maxTemp: 90}
+	levels, err := sampler.sample()
+	if err != nil {
+		t.Fatalf("sample should not fail: %v", err)
+	}
+	if levels[0] != 0 || levels[1] != 0.75 || levels[2] != 0.5 {
+		t.Errorf("expected idle CPU at first, 75%% memory and the hottest zone half way, got %v", levels)
+	}
+
+	// 300 more busy and 100 more idle jiffies
+	write("stat", "cpu  300 0 200 800 100 0 0 0 0 0\n")
+	if levels, _ = sampler.sample(); levels[0] != 0.75 {
+		t.Errorf("expected 75%% CPU load between the samples, got %v", levels[0])
+	}
+
+	thermalZones = filepath.Join(dir, "none*")
+	if _, err := sampler.sample(); err == nil {
+		t.Error("expected an error without thermal zones")
+	}
+}
+
+func TestLoadGenerator(t *testing.T) {
+	layout := Layout{Panels: []Panel{
+		{ID: 2, X: 100, ShapeType: 7},
+		{ID: 1, X: 0, ShapeType: 7},
+	}}
+	generator := &loadGenerator{}
+	generator.set([]float64{0, 1})
+	frame := generator.NextFrame(layout, 0)
+	if frame[0] != (PanelColor{PanelID: 1, G: 255}) || frame[1] != (PanelColor{PanelID: 2, R: 255}) {
+		t.Errorf("expected a green band and a red band from left to right, got %v", frame)
+	}
+	if c := loadColor(0.5); c != (rgbColor{255, 200, 0}) {
+		t.Errorf("expected yellow half way, got %v", c)
+	}
+
+	generator.set([]float64{1, 1})
+	if frame = generator.NextFrame(layout, 0); frame[0].G != 255 {
+		t.Errorf("expected no change without time passing, got %v", frame[0])
+	}
+	if frame = generator.NextFrame(layout, 1e9); frame[0] != (PanelColor{PanelID: 1, R: 255}) {
+		t.Errorf("expected the color to reach the new reading within a second, got %v", frame[0])
+	}
+
+	if _, err := parseMetrics("cpu,disk"); err == nil {
+		t.Error("expected an unknown metric to be rejected")
+	}
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// uiTheme holds the colors of the UI styles. Every state also carries a
+// symbol, so themes only change how easy the colors are to tell apart.
+type uiTheme struct {
+	title      lipgloss.CompleteColor
+	border     lipgloss.CompleteColor
+	selectedFg lipgloss.CompleteColor
+	selectedBg lipgloss.CompleteColor
+	errorColor lipgloss.CompleteColor
+	success    lipgloss.CompleteColor
+	text       lipgloss.CompleteColor
+	separator  lipgloss.CompleteColor
+	good       lipgloss.CompleteColor
+	slow       lipgloss.CompleteColor
+	down       lipgloss.CompleteColor
+	bold       bool
+}
+
+// shade is a theme color with the closest matches picked by hand for 256
+// and 16 color terminals, where the automatic mapping of the neon colors
+// turns muddy or collides
+func shade(trueColor, ansi256, ansi string) lipgloss.CompleteColor {
+	return lipgloss.CompleteColor{TrueColor: trueColor, ANSI256: ansi256, ANSI: ansi}
+}
+
+var uiThemes = map[string]uiTheme{
+	"default": {
+		title:      shade("#FF00FF", "201", "13"),
+		border:     shade("#00FFFF", "51", "14"),
+		selectedFg: shade("#000000", "16", "0"),
+		selectedBg: shade("#d4d177", "186", "11"),
+		errorColor: shade("#FF0080", "198", "9"),
+		success:    shade("#00FF80", "48", "10"),
+		text:       shade("#FF99FF", "219", "15"),
+		separator:  shade("#FFFF00", "226", "11"),
+		good:       shade("#00FF00", "46", "10"),
+		slow:       shade("#FFFF00", "226", "11"),
+		down:       shade("#FF0000", "196", "9"),
+	},
+	// White text with the Okabe-Ito blue, yellow and orange, which stay
+	// distinct with the common forms of color blindness
+	"high-contrast": {
+		title:      shade("#FFFFFF", "231", "15"),
+		border:     shade("#FFFFFF", "231", "15"),
+		selectedFg: shade("#000000", "16", "0"),
+		selectedBg: shade("#F0E442", "227", "11"),
+		errorColor: shade("#E69F00", "214", "3"),
+		success:    shade("#56B4E9", "74", "12"),
+		text:       shade("#FFFFFF", "231", "15"),
+		separator:  shade("#D0D0D0", "252", "7"),
+		good:       shade("#56B4E9", "74", "12"),
+		slow:       shade("#F0E442", "227", "11"),
+		down:       shade("#E69F00", "214", "3"),
+		bold:       true,
+	},
+}
+
+// Status symbols shown next to the colors
+const (
+	successSymbol = "✓"
+	errorSymbol   = "✗"
+	// selectedSymbol marks the selection when there are no colors to
+	// highlight it with
+	selectedSymbol = "›"
+)
+
+// applyTheme restyles the UI with the named theme, the default when empty
+func applyTheme(name string) error {
+	if name == "" {
+		name = "default"
+	}
+	theme, ok := uiThemes[name]
+	if !ok {
+		names := make([]string, 0, len(uiThemes))
+		for name := range uiThemes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	titleBoxStyle = titleBoxStyle.Foreground(theme.title).BorderForeground(theme.title)
+	menuStyle = menuStyle.BorderForeground(theme.border)
+	paneStyle = paneStyle.BorderForeground(theme.border)
+	selectedStyle = selectedStyle.Foreground(theme.selectedFg).Background(theme.selectedBg).Bold(theme.bold).UnsetTransform()
+	if activeColorMode == colorNone {
+		selectedStyle = selectedStyle.Transform(withSymbol(selectedSymbol))
+	}
+	errorStyle = errorStyle.Foreground(theme.errorColor).Bold(theme.bold)
+	successStyle = successStyle.Foreground(theme.success).Bold(theme.bold)
+	textStyle = textStyle.Foreground(theme.text)
+	separatorStyle = separatorStyle.Foreground(theme.separator)
+	latencyGoodStyle = latencyGoodStyle.Foreground(theme.good)
+	latencySlowStyle = latencySlowStyle.Foreground(theme.slow)
+	latencyDownStyle = latencyDownStyle.Foreground(theme.down).Bold(theme.bold)
+	return nil
+}
+
+func withSymbol(symbol string) func(string) string {
+	return func(s string) string {
+		return symbol + " " + s
+	}
+}
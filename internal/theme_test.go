@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyTheme(t *testing.T) {
+	defer applyTheme("")
+
+	if err := applyTheme("high-contrast"); err != nil {
+		t.Fatalf("applyTheme should not fail: %v", err)
+	}
+	if successStyle.GetForeground() != uiThemes["high-contrast"].success {
+		t.Error("the success style should use the theme color")
+	}
+	if !strings.Contains(errorStyle.Render("failed"), errorSymbol+" failed") {
+		t.Errorf("errors should keep their symbol, got %q", errorStyle.Render("failed"))
+	}
+
+	if err := applyTheme("sepia"); err == nil {
+		t.Error("applyTheme should fail for an unknown theme")
+	}
+	if err := applyTheme(""); err != nil {
+		t.Fatalf("the default theme should apply: %v", err)
+	}
+	if successStyle.GetForeground() != uiThemes["default"].success {
+		t.Error("the default theme should be restored")
+	}
+}
+
+func TestStatusSymbols(t *testing.T) {
+	if !strings.Contains(successStyle.Render("done"), successSymbol+" done") {
+		t.Errorf("success messages should start with %s, got %q", successSymbol, successStyle.Render("done"))
+	}
+	if !strings.Contains(errorStyle.Render("failed"), errorSymbol+" failed") {
+		t.Errorf("error messages should start with %s, got %q", errorSymbol, errorStyle.Render("failed"))
+	}
+}
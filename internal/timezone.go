@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	// Windows has no zone database of its own, so the binary brings one
+	_ "time/tzdata"
+)
+
+// What the quiet hours do at a time of day that a DST change skips or
+// repeats
+const (
+	// dstShift moves a skipped time to when the clocks jump ahead
+	dstShift = "shift"
+	// dstSkip leaves a skipped time out that day
+	dstSkip = "skip"
+	// dstOnce keeps the first of a repeated time
+	dstOnce = "once"
+	// dstTwice keeps both
+	dstTwice = "twice"
+)
+
+// scheduleZone loads the time zone of a schedule: name, else the timezone
+// of the config, else the zone of this computer
+func (c Config) scheduleZone(name string) (*time.Location, error) {
+	if name == "" {
+		name = c.Timezone
+	}
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q, expected a name such as Europe/Berlin", name)
+	}
+	return loc, nil
+}
+
+// validateDSTPolicies checks what to do with skipped and repeated times
+func validateDSTPolicies(skipped, repeated string) error {
+	switch skipped {
+	case "", dstShift, dstSkip:
+	default:
+		return fmt.Errorf("dstSkipped: unknown policy %q, expected %s or %s", skipped, dstShift, dstSkip)
+	}
+	switch repeated {
+	case "", dstOnce, dstTwice:
+	default:
+		return fmt.Errorf("dstRepeated: unknown policy %q, expected %s or %s", repeated, dstOnce, dstTwice)
+	}
+	return nil
+}
+
+// wallClock returns when the clocks of loc show clock on the date of day.
+// That is once on most days. A time the clocks jump past when DST starts
+// is moved to the jump, or left out with skipped set to skip; a time they
+// show twice when DST ends is kept once, or twice with repeated set to
+// twice. Only the date of day is used, so it may be in any zone.
+func wallClock(day time.Time, clock time.Duration, loc *time.Location, skipped, repeated string) []time.Time {
+	year, month, date := day.Date()
+	// wall is the clock reading as if loc were UTC, which is off from the
+	// instant by the zone offset in effect then
+	wall := time.Date(year, month, date, 0, 0, 0, 0, time.UTC).Add(clock)
+	var instants []time.Time
+	tried := make(map[int]bool)
+	for _, probe := range []time.Duration{-24 * time.Hour, 0, 24 * time.Hour} {
+		_, offset := wall.Add(probe).In(loc).Zone()
+		if tried[offset] {
+			continue
+		}
+		tried[offset] = true
+		at := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		y, m, d := at.Date()
+		if y == year && m == month && d == date && time.Duration(at.Hour())*time.Hour+time.Duration(at.Minute())*time.Minute == clock {
+			instants = append(instants, at)
+		}
+	}
+	sort.Slice(instants, func(i, j int) bool { return instants[i].Before(instants[j]) })
+
+	switch {
+	case len(instants) == 0 && skipped != dstSkip:
+		// Read with the offset from before the jump the clock lands after
+		// it, in the zone that starts at the jump
+		_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+		jump, _ := wall.Add(-time.Duration(before) * time.Second).In(loc).ZoneBounds()
+		instants = append(instants, jump)
+	case len(instants) > 1 && repeated != dstTwice:
+		instants = instants[:1]
+	}
+	return instants
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustZone(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("failed to load %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestWallClock(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	clock := func(s string) time.Duration {
+		d, _ := parseClock(s)
+		return d
+	}
+	format := func(instants []time.Time) string {
+		var s []string
+		for _, at := range instants {
+			s = append(s, at.Format("15:04 MST"))
+		}
+		return strings.Join(s, ", ")
+	}
+	springForward := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	fallBack := time.Date(2024, 11, 3, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		day      time.Time
+		clock    string
+		skipped  string
+		repeated string
+		want     string
+	}{
+		{"an ordinary time", springForward, "07:00", "", "", "07:00 EDT"},
+		{"a skipped time moves to the jump", springForward, "02:30", "", "", "03:00 EDT"},
+		{"a skipped time can be left out", springForward, "02:30", dstSkip, "", ""},
+		{"a repeated time counts once", fallBack, "01:30", "", "", "01:30 EDT"},
+		{"a repeated time can count twice", fallBack, "01:30", "", dstTwice, "01:30 EDT, 01:30 EST"},
+		{"an alarm after the change keeps its time", fallBack, "07:00", "", dstTwice, "07:00 EST"},
+	}
+	for _, tt := range tests {
+		if got := format(wallClock(tt.day, clock(tt.clock), newYork, tt.skipped, tt.repeated)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestScheduleZone(t *testing.T) {
+	config := Config{Timezone: "Europe/Berlin"}
+	if loc, err := config.scheduleZone(""); err != nil || loc.String() != "Europe/Berlin" {
+		t.Errorf("expected the timezone of the config, got %v, %v", loc, err)
+	}
+	if loc, err := config.scheduleZone("Asia/Tokyo"); err != nil || loc.String() != "Asia/Tokyo" {
+		t.Errorf("expected the zone of the schedule to win, got %v, %v", loc, err)
+	}
+	if loc, _ := (Config{}).scheduleZone(""); loc != time.Local {
+		t.Errorf("expected the local zone by default, got %v", loc)
+	}
+	if _, err := config.scheduleZone("Mars/Olympus"); err == nil {
+		t.Error("expected an unknown zone to be rejected")
+	}
+
+	for _, invalid := range []QuietHours{
+		{Start: "22:00", End: "07:00", Timezone: "Mars/Olympus"},
+		{Start: "22:00", End: "07:00", DSTSkipped: "later"},
+		{Start: "22:00", End: "07:00", DSTRepeated: "thrice"},
+	} {
+		if err := invalid.validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestQuietHoursInTimezone(t *testing.T) {
+	tokyo := mustZone(t, "Asia/Tokyo")
+	hours := QuietHours{Start: "22:00", End: "07:00"}
+	// 14:00 UTC is 23:00 in Tokyo
+	now := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	if !hours.active(now, tokyo) {
+		t.Error("expected the quiet hours to follow the clocks of their zone")
+	}
+	if hours.active(now, time.UTC) {
+		t.Error("expected no quiet hours at 14:00 UTC")
+	}
+}
+
+func TestQuietHoursAcrossDST(t *testing.T) {
+	newYork := mustZone(t, "America/New_York")
+	hours := QuietHours{Start: "01:30", End: "06:00"}
+	// The clocks go back from 02:00 EDT to 01:00 EST, showing 01:00 to
+	// 02:00 twice. Under a clock comparison the quiet hours would end at
+	// 01:00 EST and begin again half an hour later.
+	secondOne := time.Date(2024, 11, 3, 6, 15, 0, 0, time.UTC)
+	if got := secondOne.In(newYork).Format("15:04 MST"); got != "01:15 EST" {
+		t.Fatalf("expected 01:15 EST, got %s", got)
+	}
+	if !hours.active(secondOne, newYork) {
+		t.Error("expected the quiet hours begun at the first 01:30 to go on through the repeated hour")
+	}
+
+	ends := 0
+	for _, b := range hours.boundaries(time.Date(2024, 11, 2, 12, 0, 0, 0, newYork), time.Date(2024, 11, 4, 12, 0, 0, 0, newYork), newYork) {
+		if !b.begin && b.at.In(newYork).Format("15:04") != "06:00" {
+			t.Errorf("expected the quiet hours to end at 06:00 every day, got %s", b.at.In(newYork))
+		}
+		if !b.begin {
+			ends++
+		}
+	}
+	if ends != 4 {
+		t.Errorf("expected one end a day, got %d", ends)
+	}
+
+	// The clocks jump from 02:00 to 03:00 EDT
+	skipped := QuietHours{Start: "02:30", End: "06:00", DSTSkipped: dstSkip}
+	if skipped.active(time.Date(2024, 3, 10, 3, 30, 0, 0, newYork), newYork) {
+		t.Error("expected no quiet hours on the night DST skips their start")
+	}
+	shifted := QuietHours{Start: "02:30", End: "06:00"}
+	if !shifted.active(time.Date(2024, 3, 10, 3, 0, 0, 0, newYork), newYork) {
+		t.Error("expected the quiet hours to begin when the clocks jump")
+	}
+}
+
+func TestSimulateScheduleAcrossDST(t *testing.T) {
+	config := Config{
+		Timezone:   "America/New_York",
+		QuietHours: &QuietHours{Start: "02:30", End: "07:00", DSTSkipped: dstSkip},
+		Holidays:   &HolidayConfig{Disabled: []string{"St. Patrick's Day"}},
+	}
+	newYork := mustZone(t, "America/New_York")
+	from := time.Date(2024, 3, 10, 0, 0, 0, 0, newYork)
+	events, err := simulateSchedule(config, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("simulateSchedule should not fail: %v", err)
+	}
+	var lines []string
+	for _, e := range events {
+		lines = append(lines, e.at.Format("15:04 MST")+" "+e.action)
+	}
+	want := []string{
+		"03:00 EDT skipped, DST skips 02:30",
+		"07:00 EDT end, held changes go through",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(lines, "\n"))
+	}
+
+	config.QuietHours.DSTSkipped = ""
+	events, _ = simulateSchedule(config, from, from.AddDate(0, 0, 1))
+	if len(events) != 2 || events[0].action != "begin, automations hold their changes until 07:00 (DST skips 02:30)" {
+		t.Errorf("expected the start moved to the jump, got %v", events)
+	}
+}
@@ -0,0 +1,76 @@
+package internal
+
+import "time"
+
+const (
+	// toastLifetime is how long a toast stays on screen
+	toastLifetime = 5 * time.Second
+	// maxToasts is how many toasts stack up before the oldest makes room
+	maxToasts = 3
+	// toastLogLimit bounds the archive of past toasts
+	toastLogLimit = 50
+)
+
+// toast is a transient message of the UI
+type toast struct {
+	id   int
+	text string
+	at   time.Time
+}
+
+// toastStack holds the toasts on screen, newest last, and the log of every
+// toast shown so they can be read after they are dismissed
+type toastStack struct {
+	shown []toast
+	log   []toast
+	next  int
+}
+
+// push shows text as a new toast and returns its id for dismissing it.
+// Repeating the newest toast only renews it.
+func (s toastStack) push(text string, now time.Time) (toastStack, int) {
+	s.next++
+	t := toast{id: s.next, text: text, at: now}
+	shown := s.shown
+	if n := len(shown); n > 0 && shown[n-1].text == text {
+		shown = shown[:n-1]
+	}
+	if len(shown) >= maxToasts {
+		shown = shown[len(shown)-maxToasts+1:]
+	}
+	s.shown = append(append([]toast(nil), shown...), t)
+
+	log := append(append([]toast(nil), s.log...), t)
+	if len(log) > toastLogLimit {
+		log = log[len(log)-toastLogLimit:]
+	}
+	s.log = log
+	return s, t.id
+}
+
+// dismiss removes the toast with id from the screen; it stays in the log
+func (s toastStack) dismiss(id int) toastStack {
+	var shown []toast
+	for _, t := range s.shown {
+		if t.id != id {
+			shown = append(shown, t)
+		}
+	}
+	s.shown = shown
+	return s
+}
+
+// archived returns up to n of the newest toasts no longer on screen
+func (s toastStack) archived(n int) []toast {
+	onScreen := make(map[int]bool)
+	for _, t := range s.shown {
+		onScreen[t.id] = true
+	}
+	var past []toast
+	for i := len(s.log) - 1; i >= 0 && len(past) < n; i-- {
+		if !onScreen[s.log[i].id] {
+			past = append(past, s.log[i])
+		}
+	}
+	return past
+}
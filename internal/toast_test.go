@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToastStack(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	var stack toastStack
+	var first int
+	stack, first = stack.push("Device turned on", now)
+	stack, _ = stack.push("Brightness set to 40", now)
+	if len(stack.shown) != 2 {
+		t.Fatalf("expected two toasts, got %d", len(stack.shown))
+	}
+
+	stack = stack.dismiss(first)
+	if len(stack.shown) != 1 || stack.shown[0].text != "Brightness set to 40" {
+		t.Errorf("unexpected toasts after dismissing %+v", stack.shown)
+	}
+	if past := stack.archived(5); len(past) != 1 || past[0].text != "Device turned on" {
+		t.Errorf("the dismissed toast should be in the log, got %+v", past)
+	}
+
+	stack, _ = stack.push("Brightness set to 40", now)
+	if len(stack.shown) != 1 {
+		t.Errorf("a repeated toast should renew the newest one, got %+v", stack.shown)
+	}
+
+	for _, text := range []string{"a", "b", "c", "d"} {
+		stack, _ = stack.push(text, now)
+	}
+	if len(stack.shown) != maxToasts || stack.shown[0].text != "b" || stack.shown[maxToasts-1].text != "d" {
+		t.Errorf("expected the oldest toasts to make room, got %+v", stack.shown)
+	}
+
+	for i := 0; i < toastLogLimit; i++ {
+		stack, _ = stack.push(string(rune('a'+i%26)), now)
+	}
+	if len(stack.log) != toastLogLimit {
+		t.Errorf("expected the log to be capped at %d, got %d", toastLogLimit, len(stack.log))
+	}
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// easings shape the progress of a transition, mapping [0, 1] onto [0, 1]
+var easings = map[string]func(float64) float64{
+	"linear":   func(t float64) float64 { return t },
+	"ease-in":  func(t float64) float64 { return t * t * t },
+	"ease-out": func(t float64) float64 { return 1 - math.Pow(1-t, 3) },
+	"ease-in-out": func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		return 1 - math.Pow(-2*t+2, 3)/2
+	},
+}
+
+// defaultEasing is used when a transition names none
+const defaultEasing = "ease-in-out"
+
+// transitionStep is how often a transition updates the device
+const transitionStep = 100 * time.Millisecond
+
+func easingNames() []string {
+	names := make([]string, 0, len(easings))
+	for name := range easings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupEasing(name string) (func(float64) float64, error) {
+	if name == "" {
+		name = defaultEasing
+	}
+	ease, ok := easings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown easing %q (available: %s)", name, strings.Join(easingNames(), ", "))
+	}
+	return ease, nil
+}
+
+// lightState is the solid color and brightness a transition moves between
+type lightState struct {
+	Hue, Sat, Bri int
+}
+
+// blendLight returns the state at progress between from and to. The hue
+// takes the shorter way around the color wheel.
+func blendLight(from, to lightState, progress float64) lightState {
+	lerp := func(a, b float64) float64 { return a + (b-a)*progress }
+	hueDiff := math.Mod(float64(to.Hue-from.Hue)+540, 360) - 180
+	return lightState{
+		Hue: int(math.Round(math.Mod(float64(from.Hue)+hueDiff*progress+360, 360))),
+		Sat: int(math.Round(lerp(float64(from.Sat), float64(to.Sat)))),
+		Bri: int(math.Round(lerp(float64(from.Bri), float64(to.Bri)))),
+	}
+}
+
+// runTransition calls apply with the eased progress every transitionStep
+// over duration, ending with exactly 1
+func runTransition(ctx context.Context, duration time.Duration, ease func(float64) float64, apply func(progress float64) error) error {
+	ticker := time.NewTicker(transitionStep)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= duration {
+			return apply(1)
+		}
+		if err := apply(ease(float64(elapsed) / float64(duration))); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Transition moves the panels from their current solid color and brightness
+// to target over duration. Only brightness changes when color is false, so
+// a running effect keeps playing.
+func (d *Device) Transition(ctx context.Context, target lightState, color bool, duration time.Duration, easing string) error {
+	if easing == "" {
+		easing = defaultEasing
+	}
+	ease, err := lookupEasing(easing)
+	if err != nil {
+		return err
+	}
+	if color {
+		if caps, err := d.GetCapabilities(ctx); err == nil && !caps.Color {
+			return fmt.Errorf("%s panels are white only, set a color temperature instead", caps.Model)
+		}
+	}
+
+	from := target
+	if from.Bri, err = d.client.getBrightness(ctx, d.GetConfig().IP, d.GetConfig().Token); err != nil {
+		return err
+	}
+	if color {
+		if from.Hue, from.Sat, err = d.client.getColor(ctx, d.GetConfig().IP, d.GetConfig().Token); err != nil {
+			return err
+		}
+	}
+
+	err = runTransition(ctx, duration, ease, func(progress float64) error {
+		state := blendLight(from, target, progress)
+		if !color {
+			return d.client.setBrightness(ctx, d.GetConfig().IP, d.GetConfig().Token, state.Bri)
+		}
+		return d.client.setHSB(ctx, d.GetConfig().IP, d.GetConfig().Token, state.Hue, state.Sat, state.Bri)
+	})
+
+	action := fmt.Sprintf("brightness %d", target.Bri)
+	if color {
+		action = fmt.Sprintf("color hue %d sat %d brightness %d", target.Hue, target.Sat, target.Bri)
+	}
+	if err := d.logAction(fmt.Sprintf("%s over %s (%s)", action, duration, easing), err); err != nil {
+		return err
+	}
+	d.recordUsage(func(s *usageSample) { s.On, s.Brightness = true, target.Bri })
+	return nil
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEasings(t *testing.T) {
+	for name, ease := range easings {
+		if ease(0) != 0 || math.Abs(ease(1)-1) > 1e-9 {
+			t.Errorf("%s should run from 0 to 1, got %v to %v", name, ease(0), ease(1))
+		}
+		if got := ease(0.5); math.Abs(got-0.5) > 0.4 {
+			t.Errorf("%s is far off at the midpoint: %v", name, got)
+		}
+	}
+	if easings["ease-in-out"](0.1) >= 0.1 || easings["ease-in-out"](0.9) <= 0.9 {
+		t.Error("ease-in-out should start and end slower than linear")
+	}
+	if _, err := lookupEasing("bounce"); err == nil {
+		t.Error("expected an error for an unknown easing")
+	}
+}
+
+func TestBlendLight(t *testing.T) {
+	from := lightState{Hue: 350, Sat: 100, Bri: 20}
+	to := lightState{Hue: 30, Sat: 50, Bri: 80}
+
+	if got := blendLight(from, to, 0.5); got != (lightState{Hue: 10, Sat: 75, Bri: 50}) {
+		t.Errorf("expected the hue to cross 0 the short way, got %+v", got)
+	}
+	if got := blendLight(from, to, 1); got != to {
+		t.Errorf("expected the target at the end, got %+v", got)
+	}
+}
+
+func TestPresetTransition(t *testing.T) {
+	var brightness []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/test-token/state/brightness":
+			w.Write([]byte(`{"value":10}`))
+			return
+		case "/api/v1/test-token/state/hue", "/api/v1/test-token/state/sat":
+			w.Write([]byte(`{"value":0}`))
+			return
+		}
+		var payload map[string]map[string]int
+		json.NewDecoder(r.Body).Decode(&payload)
+		brightness = append(brightness, payload["brightness"]["value"])
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+
+	target := 90
+	preset := Preset{Color: "blue", Brightness: &target, Transition: 0.35, Easing: "linear"}
+	start := time.Now()
+	if err := preset.apply(context.Background(), device); err != nil {
+		t.Fatalf("apply should not fail: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+		t.Errorf("expected the transition to take its duration, took %s", elapsed)
+	}
+
+	if len(brightness) < 3 || brightness[len(brightness)-1] != 90 {
+		t.Fatalf("expected several steps ending at 90, got %v", brightness)
+	}
+	for i := 1; i < len(brightness); i++ {
+		if brightness[i] < brightness[i-1] {
+			t.Errorf("brightness should only rise, got %v", brightness)
+		}
+	}
+}
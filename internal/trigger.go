@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// errUnknownTrigger is a trigger request for a name the config does not
+// define
+var errUnknownTrigger = errors.New("unknown trigger")
+
+// TriggerRule runs Actions when POST /trigger/{name} is requested, for
+// webhooks from IFTTT, Shortcuts or a doorbell. Like the presence rules,
+// triggers wait out the quiet hours unless IgnoreQuietHours is set, never
+// run while the automations are paused or yield to a manual change or a
+// higher priority, and have the priority of serve unless set.
+type TriggerRule struct {
+	Actions          []string `json:"actions"`
+	IgnoreQuietHours bool     `json:"ignoreQuietHours,omitempty"`
+	Priority         string   `json:"priority,omitempty"`
+}
+
+type triggerRule struct {
+	TriggerRule
+	actions  []action
+	priority priority
+}
+
+// triggerSet runs the triggers of the config by name, one at a time
+type triggerSet struct {
+	mu     sync.Mutex
+	device *Device
+	quiet  *quietGate
+	rules  map[string]triggerRule
+}
+
+func newTriggerSet(device *Device, quiet *quietGate, rules map[string]TriggerRule) (*triggerSet, error) {
+	t := &triggerSet{device: device, quiet: quiet, rules: make(map[string]triggerRule, len(rules))}
+	for name, rule := range rules {
+		actions, err := parseMacro(rule.Actions)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: %w", name, err)
+		}
+		p := prioritySchedule
+		if quiet != nil && quiet.priority != 0 {
+			p = quiet.priority
+		}
+		if rule.Priority != "" {
+			if p, err = parsePriority(rule.Priority); err != nil {
+				return nil, fmt.Errorf("trigger %s: %w", name, err)
+			}
+		}
+		t.rules[name] = triggerRule{TriggerRule: rule, actions: actions, priority: p}
+	}
+	return t, nil
+}
+
+// fire runs the trigger called name. It returns why the trigger was held,
+// or "" when its actions ran.
+func (t *triggerSet) fire(ctx context.Context, name string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rule, ok := t.rules[name]
+	if !ok {
+		return "", fmt.Errorf("%w %q, expected one of serve.triggers", errUnknownTrigger, name)
+	}
+	return runGated(ctx, t.device, t.quiet, "trigger "+name, rule.priority, rule.actions, rule.IgnoreQuietHours)
+}
+
+// runGated runs actions on device as source at priority p, as the
+// triggers and OBS rules do: not while the automations are paused or a
+// manual change or a higher priority holds them, nor in the quiet hours
+// unless ignoreQuiet is set. It returns why the actions were held, or ""
+// when they ran.
+func runGated(ctx context.Context, device *Device, quiet *quietGate, source string, p priority, actions []action, ignoreQuiet bool) (string, error) {
+	if reason := quiet.yields(p); reason != "" {
+		return reason, nil
+	}
+	if quiet.quietHeld() && !ignoreQuiet {
+		return "the quiet hours", nil
+	}
+	if device == nil {
+		return "", nil
+	}
+	if ignoreQuiet {
+		device.setBrightnessCap(nil)
+		defer device.setBrightnessCap(quiet.limit)
+	} else if err := quiet.settle(ctx, device); err != nil {
+		return "", err
+	}
+	device.setChangeSource(source, p)
+	defer device.setChangeSource("", 0)
+	for _, a := range actions {
+		if err := a.run(ctx, device); err != nil {
+			return "", fmt.Errorf("%s failed at %q: %w", source, a, err)
+		}
+	}
+	return "", nil
+}
+
+// setRules replaces the triggers and quiet hours
+func (t *triggerSet) setRules(quiet *quietGate, rules map[string]TriggerRule) error {
+	next, err := newTriggerSet(t.device, quiet, rules)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quiet, t.rules = next.quiet, next.rules
+	return nil
+}
+
+// names lists the triggers, sorted
+func (t *triggerSet) names() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	names := make([]string, 0, len(t.rules))
+	for name := range t.rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,9 +1,14 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -12,14 +17,131 @@ import (
 
 type UI struct {
 	device      *Device
+	registry    *DeviceRegistry
 	cursor      int
 	message     string
+	toasts      toastStack
 	inputMode   bool
+	inputKind   inputKind
 	inputPrompt string
 	textInput   textinput.Model
 	deviceReady bool
+	status      *DeviceStatus
+	brightness  *coalescer
+	// focus is the pane of the main screen that receives the keys
+	focus        pane
+	deviceCursor int
+	// width is the width of the terminal, which decides whether the detail
+	// pane fits
+	width int
+	// session is where the previous run left off; resumed is set once the
+	// menu cursor is back there
+	session uiSession
+	resumed bool
+	// operations queues the device commands started from the menus
+	operations *operationQueue
+	lastError  string
+
+	healthChecking bool
+	healthSession  int
+	healthKnown    bool
+	reachable      bool
+	latency        time.Duration
+	caps           *Capabilities
+	pomodoro       pomodoro
+
+	galleryMode   bool
+	gallery       []galleryEntry
+	galleryCursor int
+	// preview animates the selected gallery effect on the panel map
+	preview        *effectPreview
+	previewStart   time.Time
+	previewSession int
+	previewLayout  Layout
+
+	macroMode   bool
+	macroCursor int
+	// macroCtx ends when the macro screen closes, cancelling the macros
+	// started from it
+	macroCtx    context.Context
+	macroCancel context.CancelFunc
+
+	infoMode bool
+	info     infoResultMsg
+
+	// paletteMode shows the ctrl+p command palette over the main menu
+	paletteMode    bool
+	paletteInput   textinput.Model
+	paletteCursor  int
+	paletteEffects []string
+
+	maintenanceMode   bool
+	maintenanceCursor int
+
+	liveMode    bool
+	liveCursor  int
+	liveSpeed   float64
+	liveRunning string
+	liveSession int
+	liveCancel  context.CancelFunc
+	// liveScheduler paces the running stream and holds its frame stats
+	liveScheduler *frameScheduler
+
+	linesMode      bool
+	linesStyle     string
+	linesDirection int
+	linesPalette   int
+
+	// dialog asks for confirmation over the current screen
+	dialog *confirmDialog
+
+	scanned     []string
+	scanning    bool
+	scanFound   int
+	pairing     bool
+	taskCancel  context.CancelFunc
+	qrMode      bool
+	qrCode      string
+	pairMode    bool
+	pairQueue   []string
+	pairIndex   int
+	pairResults []string
+	pairBusy    bool
+
+	historyMode   bool
+	history       []historyEntry
+	historyCursor int
+
+	canvasMode       bool
+	canvasLayouts    map[string]Layout
+	canvasDevices    []string
+	canvasPlacements map[string]CanvasPlacement
+	canvasCursor     int
+
+	layoutMode      bool
+	layout          Layout
+	layoutTransform LayoutTransform
+	// layoutLook colors the map with what the panels show
+	layoutLook      EffectGenerator
+	layoutLookStart time.Time
+	layoutSession   int
+	layoutCancel    context.CancelFunc
+	layoutEvents    <-chan deviceEvent
+
+	// warnings carries the warnings of the device, such as a stream falling
+	// back to REST, from whichever goroutine ran into them
+	warnings chan string
 }
 
+type inputKind int
+
+const (
+	inputBrightness inputKind = iota
+	inputColor
+	inputColorTemp
+	inputRename
+)
+
 // Messages for async operations
 type (
 	deviceCheckMsg struct{ ready bool }
@@ -27,14 +149,37 @@ type (
 		devices []string
 		err     error
 	}
+	scanProgressMsg struct {
+		found  int
+		events <-chan tea.Msg
+	}
 	pairResultMsg   struct{ err error }
 	actionResultMsg struct {
 		message string
 		err     error
 	}
+	galleryResultMsg struct {
+		entries []galleryEntry
+		err     error
+	}
+	registryRefreshMsg struct{}
+	capabilitiesMsg    struct{ caps Capabilities }
+	statusResultMsg    struct {
+		status DeviceStatus
+		err    error
+	}
+	pomodoroTickMsg struct {
+		session time.Time
+		now     time.Time
+	}
+	toastExpiredMsg struct{ id int }
+	warningMsg      struct{ text string }
 )
 
 func NewUI(device *Device) *UI {
+	applyColorMode(detectColorMode(os.Getenv))
+	applyTheme("")
+
 	ti := textinput.New()
 	ti.Focus()
 	ti.CharLimit = 3
@@ -44,9 +189,31 @@ func NewUI(device *Device) *UI {
 	ti.PlaceholderStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF99FF")) // Electric pink
 	ti.Cursor.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FFFF"))     // Cyan cursor
 
+	// A registry that fails to load is rebuilt by the next scan
+	registry, _ := LoadDeviceRegistry()
+	// Without a saved session the UI simply starts at the top
+	session, _ := loadSession()
+
+	// A warning the UI is too busy to take is dropped rather than stalling
+	// the stream or request it came from
+	warnings := make(chan string, 4)
+	device.warn = func(message string) {
+		select {
+		case warnings <- message:
+		default:
+		}
+	}
+
 	return &UI{
-		device:    device,
-		textInput: ti,
+		device:     device,
+		warnings:   warnings,
+		registry:   registry,
+		session:    session,
+		brightness: &coalescer{},
+		operations: &operationQueue{},
+		textInput:  ti,
+		liveSpeed:  1,
+		linesStyle: "gradient",
 	}
 }
 
@@ -56,19 +223,191 @@ func (ui UI) Init() tea.Cmd {
 
 	// Load config and check device status
 	if err := ui.device.LoadConfig(); err == nil {
+		if err := applyTheme(ui.device.GetConfig().Theme); err != nil {
+			cmds = append(cmds, func() tea.Msg { return actionResultMsg{err: err} })
+		}
+		if err := ui.resumeDevice(); err != nil {
+			cmds = append(cmds, func() tea.Msg { return actionResultMsg{err: err} })
+		}
 		cmds = append(cmds, ui.checkDeviceStatus())
 	}
+	cmds = append(cmds, ui.refreshRegistry(), watchConfig(), waitForWarning(ui.warnings))
 
 	return tea.Batch(cmds...)
 }
 
+// waitForWarning delivers the next warning of the device
+func waitForWarning(warnings <-chan string) tea.Cmd {
+	return func() tea.Msg {
+		return warningMsg{text: <-warnings}
+	}
+}
+
+// Update hands msg to the current screen, then shows the message it set
+// as a toast that dismisses itself
 func (ui UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if expired, ok := msg.(toastExpiredMsg); ok {
+		ui.toasts = ui.toasts.dismiss(expired.id)
+		return ui, nil
+	}
+	model, cmd := ui.update(msg)
+	next, ok := model.(UI)
+	if !ok || next.message == "" {
+		return model, cmd
+	}
+	var id int
+	next.toasts, id = next.toasts.push(next.message, time.Now())
+	next.message = ""
+	return next, tea.Batch(cmd, tea.Tick(toastLifetime, func(time.Time) tea.Msg {
+		return toastExpiredMsg{id: id}
+	}))
+}
+
+func (ui UI) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if tick, ok := msg.(pomodoroTickMsg); ok {
+		return ui.updatePomodoro(tick)
+	}
+	if _, ok := msg.(configWatchMsg); ok {
+		return ui.reloadConfig()
+	}
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		ui.width = size.Width
+		return ui, nil
+	}
+	if warning, ok := msg.(warningMsg); ok {
+		ui.message = errorStyle.Render("Warning: " + warning.text)
+		return ui, waitForWarning(ui.warnings)
+	}
+	if result, ok := msg.(statusResultMsg); ok {
+		if result.err != nil {
+			ui.status = nil
+		} else {
+			ui.status = &result.status
+		}
+		return ui, nil
+	}
+	if err := messageError(msg); errors.Is(err, ErrUnauthorized) {
+		return ui.tokenRejected()
+	} else if err != nil {
+		ui.lastError = err.Error()
+	}
+	if key, ok := msg.(tea.KeyMsg); ok && key.String() == "ctrl+x" {
+		return ui.cancelOperation()
+	}
+	switch msg := msg.(type) {
+	case brightnessFlushMsg:
+		return ui.flushBrightness()
+	case brightnessSentMsg:
+		return ui.handleBrightnessSent(msg)
+	case healthResultMsg:
+		return ui.handleHealthResult(msg)
+	}
+	if result, ok := msg.(capabilitiesMsg); ok {
+		ui.caps = &result.caps
+		// The menu is complete once the capabilities are known
+		ui = ui.resumeCursor()
+		ui.resumed = true
+		return ui, nil
+	}
+	if stopped, ok := msg.(liveStoppedMsg); ok {
+		return ui.handleLiveStopped(stopped)
+	}
+	if key, ok := msg.(tea.KeyMsg); ok && ui.dialog != nil {
+		return ui.updateDialog(key)
+	}
+	if ui.paletteMode {
+		return ui.updatePalette(msg)
+	}
+	if ui.galleryMode {
+		return ui.updateGallery(msg)
+	}
+	if ui.macroMode {
+		return ui.updateMacros(msg)
+	}
+	if ui.maintenanceMode {
+		return ui.updateMaintenance(msg)
+	}
+	if ui.infoMode {
+		return ui.updateInfo(msg)
+	}
+	if ui.liveMode {
+		return ui.updateLive(msg)
+	}
+	if ui.linesMode {
+		return ui.updateLines(msg)
+	}
+	if ui.historyMode {
+		return ui.updateHistory(msg)
+	}
+	if ui.canvasMode {
+		return ui.updateCanvas(msg)
+	}
+	if ui.qrMode {
+		return ui.updateQR(msg)
+	}
+	if ui.pairMode {
+		return ui.updatePairing(msg)
+	}
+	if ui.layoutMode {
+		return ui.updateLayout(msg)
+	}
 	if ui.inputMode {
 		return ui.updateInput(msg)
 	}
 	return ui.updateMenu(msg)
 }
 
+// messageError returns the error carried by a device command result
+func messageError(msg tea.Msg) error {
+	switch msg := msg.(type) {
+	case actionResultMsg:
+		return msg.err
+	case statusResultMsg:
+		return msg.err
+	case healthResultMsg:
+		return msg.err
+	case brightnessSentMsg:
+		return msg.err
+	case layoutResultMsg:
+		return msg.err
+	case layoutLookMsg:
+		return msg.err
+	case layoutEventsMsg:
+		return msg.err
+	case previewLayoutMsg:
+		return msg.err
+	case liveStoppedMsg:
+		return msg.err
+	case infoResultMsg:
+		return msg.err
+	}
+	return nil
+}
+
+// tokenRejected leaves every screen and drops the stale token so the user
+// can pair again instead of retrying a token that will never work
+func (ui UI) tokenRejected() (tea.Model, tea.Cmd) {
+	if !ui.deviceReady {
+		return ui, nil
+	}
+	ui = ui.stopLive().closeLayout().closeMacros()
+	ui.galleryMode, ui.liveMode = false, false
+	ui.maintenanceMode, ui.infoMode, ui.dialog = false, false, nil
+	ui.paletteMode, ui.focus = false, paneMenu
+	ui.preview = nil
+	ui.linesMode, ui.historyMode, ui.inputMode = false, false, false
+	ui.deviceReady = false
+	ui.status = nil
+	ui.healthChecking, ui.healthKnown = false, false
+	ui.cursor = 0
+	if err := ui.device.ClearToken(); err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Device token rejected, failed to clear it: %v", err))
+		return ui, nil
+	}
+	ui.message = errorStyle.Render("Device token rejected — press p to re-pair")
+	return ui, nil
+}
+
 func (ui UI) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -77,6 +416,14 @@ func (ui UI) updateInput(msg tea.Msg) (tea.Model, tea.Cmd) {
 			value := ui.textInput.Value()
 			ui.inputMode = false
 			ui.textInput.SetValue("")
+			switch ui.inputKind {
+			case inputColor:
+				return ui, ui.handleColorInput(value)
+			case inputColorTemp:
+				return ui, ui.handleColorTempInput(value)
+			case inputRename:
+				return ui.handleRenameInput(value)
+			}
 			return ui, ui.handleBrightnessInput(value)
 		case "esc":
 			ui.inputMode = false
@@ -97,27 +444,69 @@ func (ui UI) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case deviceCheckMsg:
 		ui.deviceReady = msg.ready
 		if msg.ready {
+			ui = ui.resumeCursor()
 			ui.message = successStyle.Render("Device connected")
+			var health tea.Cmd
+			ui, health = ui.startHealthChecks()
+			return ui, tea.Batch(ui.refreshStatus(), ui.fetchCapabilities(), health)
+		}
+		if ui.device.GetConfig().Token != "" {
+			ui.message = errorStyle.Render(fmt.Sprintf("%s is not reachable", ui.device.GetDeviceName()))
 		}
 		return ui, nil
 
+	case scanProgressMsg:
+		ui.scanFound = msg.found
+		return ui, waitForScan(msg.events)
+
 	case scanResultMsg:
-		if msg.err != nil {
-			ui.message = errorStyle.Render(fmt.Sprintf("Scan failed: %v", msg.err))
-		} else if len(msg.devices) > 0 {
+		ui.scanning, ui.taskCancel = false, nil
+		cancelled := errors.Is(msg.err, context.Canceled)
+		switch {
+		case len(msg.devices) > 0:
+			ui.scanned = msg.devices
 			ui.device.SetDevice(msg.devices[0])
-			ui.message = successStyle.Render(fmt.Sprintf("Found %d device(s)", len(msg.devices)))
-		} else {
+			// The status and capabilities shown were those of the previous device
+			ui.status, ui.caps = nil, nil
+			found := fmt.Sprintf("Found %d device(s)", len(msg.devices))
+			if cancelled {
+				found = fmt.Sprintf("Scan cancelled, found %d device(s) so far", len(msg.devices))
+			} else if msg.err != nil {
+				found = fmt.Sprintf("%s before the scan stopped: %v", found, msg.err)
+			}
+			ui.message = successStyle.Render(found)
+		case cancelled:
+			ui.message = textStyle.Render("Scan cancelled")
+		case msg.err != nil:
+			ui.message = errorStyle.Render(fmt.Sprintf("Scan failed: %v", msg.err))
+		default:
 			ui.message = errorStyle.Render("No devices found")
 		}
 		return ui, nil
 
 	case pairResultMsg:
+		ui.pairing, ui.taskCancel = false, nil
+		if errors.Is(msg.err, context.Canceled) {
+			ui.message = textStyle.Render("Pairing cancelled")
+			return ui, nil
+		}
+		var unsaved *UnsavedTokenError
+		if errors.As(msg.err, &unsaved) {
+			// The token works for this session, show it so it is not lost
+			ui.deviceReady = true
+			ui.message = errorStyle.Render(fmt.Sprintf("Paired, but the token could not be saved: %v\nToken: %s", unsaved.Err, unsaved.Token))
+			var health tea.Cmd
+			ui, health = ui.startHealthChecks()
+			return ui, tea.Batch(ui.refreshStatus(), ui.fetchCapabilities(), health)
+		}
 		if msg.err != nil {
 			ui.message = errorStyle.Render(fmt.Sprintf("Pairing failed: %v", msg.err))
 		} else {
 			ui.deviceReady = true
-			ui.message = successStyle.Render("Successfully paired with device")
+			ui.message = successStyle.Render("Successfully paired with device · i for a QR code")
+			var health tea.Cmd
+			ui, health = ui.startHealthChecks()
+			return ui, tea.Batch(ui.refreshStatus(), ui.fetchCapabilities(), health)
 		}
 		return ui, nil
 
@@ -126,20 +515,73 @@ func (ui UI) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 			ui.message = errorStyle.Render(fmt.Sprintf("Action failed: %v", msg.err))
 		} else {
 			ui.message = successStyle.Render(msg.message)
+			return ui, ui.refreshStatus()
+		}
+		return ui, nil
+
+	case layoutResultMsg:
+		return ui.handleLayoutResult(msg)
+
+	case canvasResultMsg:
+		return ui.handleCanvasResult(msg)
+
+	case infoResultMsg:
+		return ui.handleInfoResult(msg)
+
+	case historyResultMsg:
+		return ui.handleHistoryResult(msg)
+
+	case presetDiffMsg:
+		return ui.handlePresetDiff(msg)
+
+	case galleryResultMsg:
+		if msg.err != nil {
+			ui.message = errorStyle.Render(fmt.Sprintf("Gallery failed: %v", msg.err))
+		} else if len(msg.entries) == 0 {
+			ui.message = errorStyle.Render("Gallery is empty")
+		} else {
+			ui.galleryMode = true
+			ui.gallery = msg.entries
+			ui.galleryCursor = ui.galleryRowOf(ui.session.Effect)
+			ui.preview = nil
+			ui.message = ""
 		}
 		return ui, nil
 
 	case tea.KeyMsg:
+		if ui.focus == paneDevices {
+			return ui.updateDevicePane(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return ui, tea.Quit
+		case "ctrl+p":
+			return ui.openPalette()
+		case "tab":
+			if len(ui.pairedIPs()) > 0 {
+				ui.focus = paneDevices
+				ui.deviceCursor = 0
+				for i, entry := range ui.paneEntries() {
+					if entry.ip == ui.device.GetDeviceIP() {
+						ui.deviceCursor = i
+					}
+				}
+			}
+		case "esc":
+			if ui.scanning || ui.pairing {
+				return ui.cancelTask()
+			}
 		case "s":
-			if !ui.deviceReady {
-				return ui, ui.handleScan()
+			if !ui.deviceReady && !ui.scanning {
+				return ui.startScan()
 			}
 		case "p":
-			if !ui.deviceReady && ui.device.GetDeviceIP() != "" {
-				return ui, ui.handlePair()
+			if !ui.deviceReady && !ui.pairing && ui.device.GetDeviceIP() != "" {
+				return ui.startPair()
+			}
+		case "a":
+			if !ui.deviceReady && len(ui.scanned) > 1 {
+				return ui.startPairAll()
 			}
 		case "o":
 			if ui.deviceReady {
@@ -149,12 +591,77 @@ func (ui UI) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if ui.deviceReady {
 				return ui, ui.handleTurnOff()
 			}
+		case "t":
+			if ui.deviceReady {
+				return ui.togglePomodoro()
+			}
+		case "g":
+			if ui.deviceReady {
+				ui.message = textStyle.Render("Loading gallery...")
+				return ui, ui.handleGallery()
+			}
+		case "l":
+			if ui.deviceReady && ui.supportsStreaming() {
+				ui.liveMode = true
+			}
+		case "v":
+			if ui.deviceReady {
+				return ui.openLayout()
+			}
+		case "w":
+			if ui.deviceReady && len(ui.device.GetConfig().pairedDevices()) > 1 {
+				return ui.openCanvas()
+			}
+		case "n":
+			if ui.deviceReady && ui.isLines() {
+				ui.linesMode = true
+			}
+		case "r":
+			if ui.deviceReady {
+				return ui.startInput(inputRename)
+			}
+		case "h":
+			if ui.deviceReady {
+				return ui.openHistory()
+			}
+		case "d":
+			if ui.deviceReady {
+				return ui.openInfo()
+			}
+		case "M":
+			if ui.deviceReady {
+				return ui.openMaintenance()
+			}
+		case "i":
+			if ui.deviceReady {
+				return ui.openQR()
+			}
+		case "m":
+			if ui.deviceReady && len(ui.device.GetConfig().Macros) > 0 {
+				ui = ui.openMacros()
+			}
 		case "b":
 			if ui.deviceReady {
-				ui.inputMode = true
-				ui.inputPrompt = "Enter brightness (0-100)"
-				ui.textInput.Placeholder = "0-100"
-				return ui, textinput.Blink
+				return ui.startInput(inputBrightness)
+			}
+		case "+", "=":
+			if ui.deviceReady {
+				return ui.adjustBrightness(brightnessStep)
+			}
+		case "-":
+			if ui.deviceReady {
+				return ui.adjustBrightness(-brightnessStep)
+			}
+		case "c":
+			if ui.deviceReady && ui.supportsColor() {
+				return ui.startInput(inputColor)
+			}
+			if ui.deviceReady {
+				return ui.startInput(inputColorTemp)
+			}
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			if preset, ok := ui.device.GetConfig().Presets[msg.String()]; ok && ui.deviceReady {
+				return ui, ui.confirmPreset(preset)
 			}
 		case "up", "k":
 			if ui.cursor > 0 {
@@ -175,9 +682,38 @@ func (ui UI) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (ui UI) getMenuChoices() []string {
 	if ui.deviceReady {
-		return []string{"[o] Turn On", "[x] Turn Off", "[b] Brightness", "[q] Quit"}
+		pomodoroChoice := "[t] Pomodoro"
+		if ui.pomodoro.active {
+			pomodoroChoice = "[t] Stop Pomodoro"
+		}
+		choices := []string{"[o] Turn On", "[x] Turn Off", "[b] Brightness"}
+		if ui.supportsColor() {
+			choices = append(choices, "[c] Color")
+		} else {
+			choices = append(choices, "[c] Color Temperature")
+		}
+		choices = append(choices, pomodoroChoice, "[g] Effects Gallery")
+		if ui.supportsStreaming() {
+			choices = append(choices, "[l] Live Effects")
+		}
+		choices = append(choices, "[v] Panel Layout")
+		if len(ui.device.GetConfig().pairedDevices()) > 1 {
+			choices = append(choices, "[w] Multi-Device Canvas")
+		}
+		if ui.isLines() {
+			choices = append(choices, "[n] Lines Controls")
+		}
+		if len(ui.device.GetConfig().Macros) > 0 {
+			choices = append(choices, "[m] Macros")
+		}
+		choices = append(choices, "[r] Rename Device", "[d] Device Info", "[h] History", "[M] Maintenance")
+		return append(choices, "[q] Quit")
+	}
+	choices := []string{"[s] Scan Devices", "[p] Pair Device"}
+	if len(ui.scanned) > 1 {
+		choices = append(choices, "[a] Pair All Found Devices")
 	}
-	return []string{"[s] Scan Devices", "[p] Pair Device", "[q] Quit"}
+	return append(choices, "[q] Quit")
 }
 
 func (ui UI) handleMenuSelect() (tea.Model, tea.Cmd) {
@@ -189,18 +725,49 @@ func (ui UI) handleMenuSelect() (tea.Model, tea.Cmd) {
 	selected := choices[ui.cursor]
 	switch selected {
 	case "[s] Scan Devices":
-		return ui, ui.handleScan()
+		if !ui.scanning {
+			return ui.startScan()
+		}
+	case "[a] Pair All Found Devices":
+		return ui.startPairAll()
 	case "[p] Pair Device":
-		return ui, ui.handlePair()
+		if !ui.pairing {
+			return ui.startPair()
+		}
 	case "[o] Turn On":
 		return ui, ui.handleTurnOn()
 	case "[x] Turn Off":
 		return ui, ui.handleTurnOff()
 	case "[b] Brightness":
-		ui.inputMode = true
-		ui.inputPrompt = "Enter brightness (0-100)"
-		ui.textInput.Placeholder = "0-100"
-		return ui, textinput.Blink
+		return ui.startInput(inputBrightness)
+	case "[c] Color":
+		return ui.startInput(inputColor)
+	case "[c] Color Temperature":
+		return ui.startInput(inputColorTemp)
+	case "[t] Pomodoro", "[t] Stop Pomodoro":
+		return ui.togglePomodoro()
+	case "[g] Effects Gallery":
+		ui.message = textStyle.Render("Loading gallery...")
+		return ui, ui.handleGallery()
+	case "[l] Live Effects":
+		ui.liveMode = true
+	case "[v] Panel Layout":
+		return ui.openLayout()
+	case "[w] Multi-Device Canvas":
+		return ui.openCanvas()
+	case "[n] Lines Controls":
+		ui.linesMode = true
+		return ui, nil
+	case "[r] Rename Device":
+		return ui.startInput(inputRename)
+	case "[h] History":
+		return ui.openHistory()
+	case "[d] Device Info":
+		return ui.openInfo()
+	case "[M] Maintenance":
+		return ui.openMaintenance()
+	case "[m] Macros":
+		ui = ui.openMacros()
 	case "[q] Quit":
 		return ui, tea.Quit
 	}
@@ -213,44 +780,192 @@ func (ui UI) checkDeviceStatus() tea.Cmd {
 		ctx, cancel := ui.device.createContext()
 		defer cancel()
 		ready := ui.device.IsDeviceReady(ctx)
+		if ip := ui.device.GetDeviceIP(); ip != "" {
+			if ready {
+				ui.registry.Seen(ip, time.Now())
+			} else {
+				ui.registry.MarkOffline(ip)
+			}
+			ui.registry.Save()
+		}
 		return deviceCheckMsg{ready: ready}
 	}
 }
 
-func (ui UI) handleScan() tea.Cmd {
+// refreshStatus polls the small state endpoints for the status line
+func (ui UI) refreshStatus() tea.Cmd {
 	return func() tea.Msg {
 		ctx, cancel := ui.device.createContext()
 		defer cancel()
-		devices, err := ui.device.ScanForDevices(ctx)
-		return scanResultMsg{devices: devices, err: err}
+		status, err := ui.device.GetStatus(ctx)
+		return statusResultMsg{status: status, err: err}
 	}
 }
 
-func (ui UI) handlePair() tea.Cmd {
+// refreshRegistry probes the known devices so the list shows which are online
+func (ui UI) refreshRegistry() tea.Cmd {
 	return func() tea.Msg {
 		ctx, cancel := ui.device.createContext()
 		defer cancel()
-		err := ui.device.PairDevice(ctx)
-		return pairResultMsg{err: err}
+		if ui.registry.Refresh(ctx) == nil {
+			ui.registry.Save()
+		}
+		return registryRefreshMsg{}
 	}
 }
 
-func (ui UI) handleTurnOn() tea.Cmd {
+// fetchCapabilities resolves what the connected model supports so the menu
+// can hide actions it would reject
+func (ui UI) fetchCapabilities() tea.Cmd {
 	return func() tea.Msg {
 		ctx, cancel := ui.device.createContext()
 		defer cancel()
-		err := ui.device.TurnOn(ctx)
-		return actionResultMsg{message: "Device turned on", err: err}
+		caps, err := ui.device.GetCapabilities(ctx)
+		if err != nil {
+			return nil
+		}
+		ui.registry.SetModel(ui.device.GetDeviceIP(), caps.Model, caps.Firmware)
+		ui.registry.Save()
+		return capabilitiesMsg{caps: caps}
 	}
 }
 
-func (ui UI) handleTurnOff() tea.Cmd {
+// supportsColor is true unless the device is known to be white-only
+func (ui UI) supportsColor() bool {
+	return ui.caps == nil || ui.caps.Color
+}
+
+// supportsStreaming is true unless the device is known to lack external
+// control v2
+func (ui UI) supportsStreaming() bool {
+	return ui.caps == nil || ui.caps.StreamingVersion >= 2
+}
+
+// startScan scans in the background, reporting each device found so the
+// progress shows, until it is done or esc cancels it
+func (ui UI) startScan() (tea.Model, tea.Cmd) {
+	ctx, cancel := ui.device.createContext()
+	ui.scanning, ui.scanFound, ui.taskCancel = true, 0, cancel
+
+	events := make(chan tea.Msg, 1)
+	go func() {
+		defer cancel()
+		var mu sync.Mutex
+		count := 0
+		devices, err := ui.device.ScanForDevices(ctx, func(string) {
+			mu.Lock()
+			defer mu.Unlock()
+			count++
+			// A newer count replaces one the UI has not read yet. Only this
+			// callback sends while the scan runs, so once the stale count
+			// is drained the send cannot block.
+			select {
+			case <-events:
+			default:
+			}
+			events <- scanProgressMsg{found: count, events: events}
+		})
+		for _, ip := range devices {
+			ui.registry.Seen(ip, time.Now())
+		}
+		if len(devices) > 0 {
+			ui.registry.Save()
+		}
+		events <- scanResultMsg{devices: devices, err: err}
+	}()
+	return ui, waitForScan(events)
+}
+
+func waitForScan(events <-chan tea.Msg) tea.Cmd {
 	return func() tea.Msg {
-		ctx, cancel := ui.device.createContext()
+		return <-events
+	}
+}
+
+func (ui UI) startPair() (tea.Model, tea.Cmd) {
+	ctx, cancel := ui.device.createContext()
+	ui.pairing, ui.taskCancel = true, cancel
+	return ui, func() tea.Msg {
 		defer cancel()
+		err := ui.device.PairDevice(ctx)
+		return pairResultMsg{err: err}
+	}
+}
+
+// cancelTask stops a running scan or pairing; its result reports what was
+// done so far
+func (ui UI) cancelTask() (tea.Model, tea.Cmd) {
+	if ui.taskCancel != nil {
+		ui.taskCancel()
+	}
+	return ui, nil
+}
+
+func (ui UI) handleTurnOn() tea.Cmd {
+	return ui.queueOperation("Turn on", commandTimeout, func(ctx context.Context) tea.Msg {
+		err := ui.device.TurnOn(ctx)
+		return actionResultMsg{message: "Device turned on", err: err}
+	})
+}
+
+func (ui UI) handleTurnOff() tea.Cmd {
+	return ui.queueOperation("Turn off", commandTimeout, func(ctx context.Context) tea.Msg {
 		err := ui.device.TurnOff(ctx)
 		return actionResultMsg{message: "Device turned off", err: err}
+	})
+}
+
+func (ui UI) handlePreset(preset Preset) tea.Cmd {
+	// A transition runs for its whole duration
+	timeout := preset.transition() + commandTimeout
+	return ui.queueOperation(fmt.Sprintf("Preset %s", preset), timeout, func(ctx context.Context) tea.Msg {
+		err := preset.apply(ctx, ui.device)
+		return actionResultMsg{message: fmt.Sprintf("Applied preset %s", preset), err: err}
+	})
+}
+
+func (ui UI) startInput(kind inputKind) (tea.Model, tea.Cmd) {
+	ui.inputMode = true
+	ui.inputKind = kind
+	switch kind {
+	case inputColor:
+		ui.inputPrompt = "Enter color (name or #rrggbb)"
+		ui.textInput.Placeholder = "teal"
+		ui.textInput.CharLimit = 24
+	case inputColorTemp:
+		minTemp, maxTemp := 1200, 6500
+		if ui.caps != nil {
+			minTemp, maxTemp = ui.caps.ColorTempMin, ui.caps.ColorTempMax
+		}
+		ui.inputPrompt = fmt.Sprintf("Enter color temperature (%d-%dK)", minTemp, maxTemp)
+		ui.textInput.Placeholder = "2700"
+		ui.textInput.CharLimit = 5
+	case inputRename:
+		ui.inputPrompt = "Enter a name (empty to show the IP)"
+		ui.textInput.Placeholder = "Living Room Hexagons"
+		ui.textInput.CharLimit = 32
+	default:
+		ui.inputPrompt = "Enter brightness (0-100)"
+		ui.textInput.Placeholder = "0-100"
+		ui.textInput.CharLimit = 3
+	}
+	return ui, textinput.Blink
+}
+
+func (ui UI) handleColorInput(value string) tea.Cmd {
+	color, err := parseColor(value)
+	if err != nil {
+		return func() tea.Msg {
+			return actionResultMsg{err: err}
+		}
 	}
+
+	value = strings.TrimSpace(value)
+	return ui.queueOperation("Color "+value, commandTimeout, func(ctx context.Context) tea.Msg {
+		hue, sat, _ := color.hsb()
+		err := ui.device.SetColor(ctx, hue, sat)
+		return actionResultMsg{message: fmt.Sprintf("Color set to %s", value), err: err}
+	})
 }
 
 func (ui UI) handleBrightnessInput(value string) tea.Cmd {
@@ -261,21 +976,86 @@ func (ui UI) handleBrightnessInput(value string) tea.Cmd {
 		}
 	}
 
+	return ui.queueOperation(fmt.Sprintf("Brightness %d", brightness), commandTimeout, func(ctx context.Context) tea.Msg {
+		err := ui.device.SetBrightness(ctx, brightness)
+		return actionResultMsg{message: fmt.Sprintf("Brightness set to %d", brightness), err: err}
+	})
+}
+
+func (ui UI) handleColorTempInput(value string) tea.Cmd {
+	kelvin, err := parseColorTemp(value)
+	if err != nil {
+		return func() tea.Msg {
+			return actionResultMsg{err: err}
+		}
+	}
+
+	return ui.queueOperation(fmt.Sprintf("Color temperature %dK", kelvin), commandTimeout, func(ctx context.Context) tea.Msg {
+		err := ui.device.SetColorTemp(ctx, kelvin)
+		return actionResultMsg{message: fmt.Sprintf("Color temperature set to %dK", kelvin), err: err}
+	})
+}
+
+func (ui UI) handleRenameInput(value string) (tea.Model, tea.Cmd) {
+	if err := ui.device.Rename(ui.device.GetDeviceIP(), value); err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Rename failed: %v", err))
+		return ui, nil
+	}
+	ui.message = successStyle.Render(fmt.Sprintf("Renamed to %s", ui.device.GetDeviceName()))
+	return ui, nil
+}
+
+func (ui UI) togglePomodoro() (tea.Model, tea.Cmd) {
+	if ui.pomodoro.active {
+		ui.pomodoro = pomodoro{}
+		ui.message = successStyle.Render("Pomodoro stopped")
+		return ui, nil
+	}
+	ui.pomodoro = newPomodoro(time.Now())
+	return ui, tea.Batch(ui.applyPomodoro(), pomodoroTick(ui.pomodoro.session))
+}
+
+func (ui UI) updatePomodoro(tick pomodoroTickMsg) (tea.Model, tea.Cmd) {
+	// Ticks from a stopped session are dropped so restarts don't stack timers
+	if !ui.pomodoro.active || !tick.session.Equal(ui.pomodoro.session) {
+		return ui, nil
+	}
+	if ui.pomodoro.remaining(tick.now) > 0 {
+		return ui, pomodoroTick(tick.session)
+	}
+	ui.pomodoro = ui.pomodoro.next(tick.now)
+	return ui, tea.Batch(ui.applyPomodoro(), pomodoroTick(tick.session))
+}
+
+func (ui UI) applyPomodoro() tea.Cmd {
+	p := ui.pomodoro
 	return func() tea.Msg {
 		ctx, cancel := ui.device.createContext()
 		defer cancel()
-		err := ui.device.SetBrightness(ctx, brightness)
-		return actionResultMsg{message: fmt.Sprintf("Brightness set to %d", brightness), err: err}
+		err := p.apply(ctx, ui.device)
+		return actionResultMsg{message: fmt.Sprintf("%s started", p), err: err}
 	}
 }
 
+func pomodoroTick(session time.Time) tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return pomodoroTickMsg{session: session, now: t}
+	})
+}
+
 func (ui UI) View() string {
 	// Title box
 	status := "Not Connected"
 	if ui.deviceReady {
-		status = fmt.Sprintf("Connected to %s", ui.device.GetDeviceIP())
+		status = fmt.Sprintf("Connected to %s", ui.device.GetDeviceName())
+		if indicator := ui.latencyIndicator(); indicator != "" {
+			status += " " + indicator
+		}
 	}
 	titleContent := fmt.Sprintf("Nanoleaf Controller / %s", status)
+	if activeProfile != "" {
+		titleContent = fmt.Sprintf("Nanoleaf Controller [%s] / %s", activeProfile, status)
+	}
 	titleBox := titleBoxStyle.Render(titleContent)
 
 	// Menu
@@ -288,6 +1068,42 @@ func (ui UI) View() string {
 			menuItems[i] = textStyle.Render(choice)
 		}
 	}
+	if ui.dialog != nil {
+		menuItems = ui.dialogView()
+	} else if ui.paletteMode {
+		menuItems = ui.paletteView()
+	} else if ui.galleryMode {
+		menuItems = ui.galleryView()
+	} else if ui.macroMode {
+		menuItems = ui.macroView()
+	} else if ui.maintenanceMode {
+		menuItems = ui.maintenanceView()
+	} else if ui.infoMode {
+		menuItems = ui.infoView()
+	} else if ui.liveMode {
+		menuItems = ui.liveView()
+	} else if ui.linesMode {
+		menuItems = ui.linesView()
+	} else if ui.historyMode {
+		menuItems = ui.historyView()
+	} else if ui.canvasMode {
+		menuItems = ui.canvasView()
+	} else if ui.qrMode {
+		menuItems = ui.qrView()
+	} else if ui.pairMode {
+		menuItems = ui.pairingView()
+	} else if ui.layoutMode {
+		menuItems = ui.layoutView()
+	} else if !ui.deviceReady {
+		menuItems = append(menuItems, ui.taskProgressView()...)
+		menuItems = append(menuItems, ui.knownDevicesView()...)
+	} else if presets := ui.device.GetConfig().Presets; len(presets) > 0 {
+		var labels []string
+		for _, key := range presetKeys(presets) {
+			labels = append(labels, fmt.Sprintf("[%s] %s", key, presets[key]))
+		}
+		menuItems = append(menuItems, "", textStyle.Render("Presets: "+strings.Join(labels, " · ")))
+	}
 
 	// Separator line
 	separator := separatorStyle.Render(strings.Repeat("─", 46)) // 46 chars to fit within 50 width box
@@ -299,7 +1115,21 @@ func (ui UI) View() string {
 		cancelText := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF9933")).Render("(esc to cancel)") // Light orange
 		logContent = fmt.Sprintf("%s\n%s\n%s", prompt, ui.textInput.View(), cancelText)
 	} else {
-		logContent = ui.message
+		logContent = ui.toastsView()
+	}
+	if ui.deviceReady {
+		logContent = lipgloss.JoinVertical(lipgloss.Left, logContent, "", ui.statusBarView())
+	}
+	detail := ui.showDetailPane()
+	if log := ui.toastLogView(); log != "" && !detail {
+		logContent = lipgloss.JoinVertical(lipgloss.Left, logContent, "", log)
+	}
+	if ui.deviceReady && ui.status != nil {
+		logContent = lipgloss.JoinVertical(lipgloss.Left, separatorStyle.Render(ui.status.String()), logContent)
+	}
+	if ui.pomodoro.active {
+		timer := separatorStyle.Render(fmt.Sprintf("%s · %s left", ui.pomodoro, formatTimer(ui.pomodoro.remaining(time.Now()))))
+		logContent = lipgloss.JoinVertical(lipgloss.Left, timer, logContent)
 	}
 
 	// Separator line
@@ -319,7 +1149,51 @@ func (ui UI) View() string {
 	mainBox := menuStyle.Render(combinedContent)
 
 	// Stack title box directly on main box (no spacing)
-	return lipgloss.JoinVertical(lipgloss.Center, titleBox, mainBox)
+	main := lipgloss.JoinVertical(lipgloss.Center, titleBox, mainBox)
+	panes := []string{main}
+	if len(ui.pairedIPs()) > 0 {
+		panes = append([]string{ui.devicePaneView(lipgloss.Height(main))}, panes...)
+	}
+	if detail {
+		panes = append(panes, ui.detailPaneView(lipgloss.Height(main)))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, panes...)
+}
+
+// taskProgressView shows the scan or pairing in progress
+func (ui UI) taskProgressView() []string {
+	switch {
+	case ui.scanning:
+		return []string{"", textStyle.Render(fmt.Sprintf("Scanning... %d found so far · esc to cancel", ui.scanFound))}
+	case ui.pairing:
+		return []string{"", textStyle.Render(fmt.Sprintf("Pairing with %s... esc to cancel", ui.device.GetDeviceIP()))}
+	}
+	return nil
+}
+
+// knownDevicesView lists the devices from the registry on the setup screen
+func (ui UI) knownDevicesView() []string {
+	devices := ui.registry.Devices()
+	if len(devices) == 0 {
+		return nil
+	}
+
+	lines := []string{"", textStyle.Render("Known devices:")}
+	config := ui.device.GetConfig()
+	now := time.Now()
+	for _, record := range devices {
+		marker := "○"
+		if record.Online {
+			marker = "●"
+		}
+		line := fmt.Sprintf("  %s %s  %s", marker, config.displayName(record.IP), record.Status(now))
+		if record.IP == ui.device.GetDeviceIP() {
+			lines = append(lines, selectedStyle.Render(line))
+		} else {
+			lines = append(lines, separatorStyle.Render(line))
+		}
+	}
+	return lines
 }
 
 // Styles
@@ -341,12 +1215,18 @@ var (
 			Padding(1, 2).
 			Width(50)
 
+	paneStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#00FFFF")).
+			Padding(0, 1).
+			Width(devicePaneWidth)
+
 	selectedStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#000000")).
 			Background(lipgloss.Color("#d4d177"))
 
-	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0080")) // Error red
-	successStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF80")) // Success green
-	textStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF99FF")) // Electric pink for default text
-	separatorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFF00")) // Electric yellow for separators
+	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0080")).Transform(withSymbol(errorSymbol))   // Error red
+	successStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF80")).Transform(withSymbol(successSymbol)) // Success green
+	textStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF99FF"))                                      // Electric pink for default text
+	separatorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFF00"))                                      // Electric yellow for separators
 )
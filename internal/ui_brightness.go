@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	brightnessStep = 5
+	// brightnessFlushInterval caps held +/- keys at 10 requests per second
+	brightnessFlushInterval = 100 * time.Millisecond
+)
+
+type (
+	brightnessFlushMsg struct{}
+	brightnessSentMsg  struct {
+		value int
+		err   error
+	}
+)
+
+// adjustBrightness moves the brightness target by delta; the request itself
+// is sent by the next flush so held keys collapse into one update
+func (ui UI) adjustBrightness(delta int) (tea.Model, tea.Cmd) {
+	// Continue from the pending target while keys are held, otherwise from
+	// the last known device state
+	current := ui.brightness.target
+	if !ui.brightness.busy() {
+		if ui.status != nil {
+			current = ui.status.Brightness
+		} else if !ui.brightness.hasSent {
+			current = 50
+		}
+	}
+	target := max(0, min(100, current+delta))
+	ui.message = textStyle.Render(fmt.Sprintf("Brightness %d", target))
+	if ui.brightness.set(target) {
+		return ui, scheduleBrightnessFlush()
+	}
+	return ui, nil
+}
+
+func scheduleBrightnessFlush() tea.Cmd {
+	return tea.Tick(brightnessFlushInterval, func(time.Time) tea.Msg {
+		return brightnessFlushMsg{}
+	})
+}
+
+func (ui UI) flushBrightness() (tea.Model, tea.Cmd) {
+	value, ok := ui.brightness.flush()
+	if !ok {
+		return ui, nil
+	}
+	return ui, func() tea.Msg {
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		return brightnessSentMsg{value: value, err: ui.device.SetBrightness(ctx, value)}
+	}
+}
+
+func (ui UI) handleBrightnessSent(msg brightnessSentMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	if ui.brightness.done(msg.value, msg.err == nil) {
+		cmd = scheduleBrightnessFlush()
+	}
+	if msg.err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Action failed: %v", msg.err))
+	} else if ui.status != nil {
+		ui.status.Brightness = msg.value
+	}
+	return ui, cmd
+}
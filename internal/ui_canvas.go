@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// canvasStep is how far one arrow key press moves a device, in layout units
+const canvasStep = 50
+
+type canvasResultMsg struct {
+	layouts map[string]Layout
+	err     error
+}
+
+func (ui UI) openCanvas() (tea.Model, tea.Cmd) {
+	ui.message = textStyle.Render("Loading layouts...")
+	return ui, func() tea.Msg {
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		layouts, err := ui.device.PairedLayouts(ctx)
+		return canvasResultMsg{layouts: layouts, err: err}
+	}
+}
+
+func (ui UI) handleCanvasResult(msg canvasResultMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Canvas failed: %v", msg.err))
+		return ui, nil
+	}
+	if len(msg.layouts) == 0 {
+		ui.message = errorStyle.Render("No paired device answered")
+		return ui, nil
+	}
+
+	ui.canvasLayouts = msg.layouts
+	ui.canvasDevices = make([]string, 0, len(msg.layouts))
+	for ip := range msg.layouts {
+		ui.canvasDevices = append(ui.canvasDevices, ip)
+	}
+	sort.Strings(ui.canvasDevices)
+
+	// Start from the saved placements and fill in the default slots
+	ui.canvasPlacements = make(map[string]CanvasPlacement)
+	saved := ui.device.GetConfig().Canvas
+	for i, ip := range ui.canvasDevices {
+		placement, ok := saved[ip]
+		if !ok {
+			placement = CanvasPlacement{X: i * canvasSpacing}
+		}
+		ui.canvasPlacements[ip] = placement
+	}
+	ui.canvasCursor = 0
+	ui.canvasMode = true
+	ui.message = ""
+	return ui, nil
+}
+
+func (ui UI) updateCanvas(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		ip := ui.canvasDevices[ui.canvasCursor]
+		placement := ui.canvasPlacements[ip]
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q":
+			ui.canvasMode = false
+			ui.message = ""
+			return ui, nil
+		case "tab":
+			ui.canvasCursor = (ui.canvasCursor + 1) % len(ui.canvasDevices)
+			return ui, nil
+		case "left", "h":
+			placement.X -= canvasStep
+		case "right", "l":
+			placement.X += canvasStep
+		case "up", "k":
+			placement.Y += canvasStep
+		case "down", "j":
+			placement.Y -= canvasStep
+		case "r":
+			placement.Rotation = (placement.Rotation + 90) % 360
+		case "enter":
+			if err := ui.device.SetCanvas(ui.canvasPlacements); err != nil {
+				ui.message = errorStyle.Render(fmt.Sprintf("Failed to save: %v", err))
+			} else {
+				ui.message = successStyle.Render("Canvas saved")
+			}
+			return ui, nil
+		}
+		// The map is shared with the UI copy Bubble Tea keeps, so copy it
+		placements := make(map[string]CanvasPlacement, len(ui.canvasPlacements))
+		for key, value := range ui.canvasPlacements {
+			placements[key] = value
+		}
+		placements[ip] = placement
+		ui.canvasPlacements = placements
+	}
+	return ui, nil
+}
+
+func (ui UI) canvasView() []string {
+	canvas, owners := composeCanvas(ui.canvasLayouts, ui.canvasPlacements)
+	selected := ui.canvasDevices[ui.canvasCursor]
+	cell := func(p Panel) string {
+		if owners[p.ID] == selected {
+			return selectedStyle.Render(panelSymbol(p))
+		}
+		return textStyle.Render(panelSymbol(p))
+	}
+
+	lines := []string{separatorStyle.Render("Multi-Device Canvas"), ""}
+	lines = append(lines, renderLayoutMap(canvas, layoutMapWidth, layoutMapHeight, cell)...)
+
+	config := ui.device.GetConfig()
+	placement := ui.canvasPlacements[selected]
+	return append(lines,
+		"",
+		textStyle.Render(fmt.Sprintf("%s · (%d, %d) · %d°", config.displayName(selected), placement.X, placement.Y, placement.Rotation)),
+		textStyle.Render("tab device · arrows move · r rotate · enter save"),
+	)
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// configWatchInterval is how often the UI and serve look for changes to the
+// config file. Comparing its modification time and size is cheap and, unlike
+// file notifications, also works for homes on network drives.
+const configWatchInterval = 2 * time.Second
+
+type configWatchMsg struct{}
+
+func watchConfig() tea.Cmd {
+	return tea.Tick(configWatchInterval, func(time.Time) tea.Msg {
+		return configWatchMsg{}
+	})
+}
+
+// reloadConfig applies the config when another process, such as a CLI
+// command or serve, changed it: the theme is applied again, new devices
+// show up in the device pane and a new active device is connected to
+func (ui UI) reloadConfig() (tea.Model, tea.Cmd) {
+	ip := ui.device.GetDeviceIP()
+	reloaded, err := ui.device.ReloadConfig()
+	if err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Config changed but could not be reloaded: %v", err))
+		return ui, watchConfig()
+	}
+	if !reloaded {
+		return ui, watchConfig()
+	}
+	// serve moves the pairing of a device that got a new address, and the
+	// registry along with it
+	if registry, err := LoadDeviceRegistry(); err == nil {
+		ui.registry = registry
+	}
+	if err := applyTheme(ui.device.GetConfig().Theme); err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Config reloaded, %v", err))
+	} else {
+		ui.message = successStyle.Render("Config reloaded")
+	}
+	if ui.device.GetDeviceIP() == ip {
+		return ui, watchConfig()
+	}
+	ui.deviceReady = false
+	ui.status, ui.caps = nil, nil
+	ui.healthChecking, ui.healthKnown = false, false
+	ui.cursor = 0
+	if ui.device.GetConfig().Token == "" {
+		return ui, watchConfig()
+	}
+	return ui, tea.Batch(watchConfig(), ui.checkDeviceStatus())
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// presetDiffMsg carries the changes a preset would make, for confirmation
+type presetDiffMsg struct {
+	preset  Preset
+	changes []string
+	err     error
+}
+
+// confirmPreset applies trusted presets straight away and otherwise
+// compares the preset with the current state for the confirmation screen
+func (ui UI) confirmPreset(preset Preset) tea.Cmd {
+	if preset.Trusted {
+		return ui.handlePreset(preset)
+	}
+	return func() tea.Msg {
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		status, err := ui.device.GetStatus(ctx)
+		if err != nil {
+			return presetDiffMsg{preset: preset, err: err}
+		}
+		return presetDiffMsg{preset: preset, changes: preset.diff(status)}
+	}
+}
+
+func (ui UI) handlePresetDiff(msg presetDiffMsg) (tea.Model, tea.Cmd) {
+	changes := msg.changes
+	if msg.err != nil {
+		changes = []string{fmt.Sprintf("current state unknown (%v)", msg.err)}
+	} else if len(changes) == 0 {
+		changes = []string{"Nothing would change"}
+	}
+	details := make([]string, len(changes))
+	for i, change := range changes {
+		details[i] = "  " + change
+	}
+	preset := msg.preset
+	return ui.ask(confirmDialog{
+		title:    fmt.Sprintf("Apply preset %s?", preset),
+		details:  details,
+		action:   "apply",
+		declined: "Preset not applied",
+		confirm: func(ui UI) (tea.Model, tea.Cmd) {
+			return ui, ui.handlePreset(preset)
+		},
+	})
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// detailPaneWidth fits a label and a model or effect name
+	detailPaneWidth = 32
+	// detailLogLines is how many past toasts the detail pane lists, more
+	// than the main box has room for
+	detailLogLines = 8
+)
+
+// showDetailPane reports whether the info and log pane is shown right of
+// the main box: with a device connected and a terminal wide enough for
+// every pane. Narrower terminals keep the log in the main box.
+func (ui UI) showDetailPane() bool {
+	if !ui.deviceReady {
+		return false
+	}
+	need := menuStyle.GetWidth() + menuStyle.GetHorizontalBorderSize() + detailPaneWidth + 2
+	if len(ui.pairedIPs()) > 0 {
+		need += devicePaneWidth + 2
+	}
+	return ui.width >= need
+}
+
+// detailPaneView shows what is known about the active device and the log
+// of past messages, as tall as the main box so the panes line up
+func (ui UI) detailPaneView(height int) string {
+	lines := []string{separatorStyle.Render("Info"), ""}
+	row := func(label, value string) {
+		lines = append(lines, textStyle.Render(clip(fmt.Sprintf("%-10s %s", label, value), detailPaneWidth-4)))
+	}
+	row("Device", ui.device.GetDeviceName())
+	row("Address", ui.device.GetDeviceIP())
+	if ui.caps != nil {
+		row("Model", ui.caps.Model)
+		row("Firmware", ui.caps.Firmware)
+	}
+	if ui.status != nil {
+		power := "off"
+		if ui.status.On {
+			power = "on"
+		}
+		row("Power", power)
+		row("Brightness", fmt.Sprintf("%d%%", ui.status.Brightness))
+		if ui.status.Effect != "" {
+			row("Effect", ui.status.Effect)
+		}
+	}
+	if ui.healthKnown && ui.reachable {
+		row("Latency", fmt.Sprintf("%dms", ui.latency.Milliseconds()))
+	}
+
+	lines = append(lines, "", separatorStyle.Render("Log"))
+	past := ui.toasts.archived(detailLogLines)
+	if len(past) == 0 {
+		lines = append(lines, textStyle.Render("Nothing yet"))
+	}
+	truncate := lipgloss.NewStyle().MaxWidth(detailPaneWidth - 2)
+	for _, t := range past {
+		text, _, _ := strings.Cut(t.text, "\n")
+		lines = append(lines, truncate.Render(separatorStyle.Render(t.at.Format("15:04"))+" "+text))
+	}
+
+	return paneStyle.Width(detailPaneWidth).Height(max(height-2, 0)).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
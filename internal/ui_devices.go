@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pane is the part of the main screen that receives the keys
+type pane int
+
+const (
+	paneMenu pane = iota
+	paneDevices
+)
+
+// devicePaneWidth leaves room for a name next to the online marker
+const devicePaneWidth = 24
+
+// pairedIPs lists the paired devices shown in the device pane
+func (ui UI) pairedIPs() []string {
+	var ips []string
+	for ip := range ui.device.GetConfig().pairedDevices() {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// paneEntry is a line of the device pane: a group, or a device by IP
+type paneEntry struct {
+	group string
+	ip    string
+}
+
+// paneEntries lists the groups and then the paired devices of the pane
+func (ui UI) paneEntries() []paneEntry {
+	var entries []paneEntry
+	for _, name := range ui.device.GetConfig().groupNames() {
+		entries = append(entries, paneEntry{group: name})
+	}
+	for _, ip := range ui.pairedIPs() {
+		entries = append(entries, paneEntry{ip: ip})
+	}
+	return entries
+}
+
+func (ui UI) updateDevicePane(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	entries := ui.paneEntries()
+	if ui.deviceCursor >= len(entries) {
+		return ui, nil
+	}
+	entry := entries[ui.deviceCursor]
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return ui, tea.Quit
+	case "tab", "esc":
+		ui.focus = paneMenu
+	case "up", "k":
+		if ui.deviceCursor > 0 {
+			ui.deviceCursor--
+		}
+	case "down", "j":
+		if ui.deviceCursor < len(entries)-1 {
+			ui.deviceCursor++
+		}
+	case "o", "x":
+		if entry.group != "" {
+			return ui, ui.handleGroupPower(entry.group, msg.String() == "o")
+		}
+		return ui, ui.handlePairedPower(entry.ip, msg.String() == "o")
+	case "u":
+		if entry.ip != "" {
+			return ui.confirmUnpair(entry.ip)
+		}
+	case "enter":
+		if entry.group != "" {
+			ui.message = textStyle.Render(fmt.Sprintf("o/x turn %s on or off, enter on a device makes it the active one", entry.group))
+			return ui, nil
+		}
+		return ui.switchDevice(entry.ip)
+	}
+	return ui, nil
+}
+
+func (ui UI) handleGroupPower(name string, on bool) tea.Cmd {
+	state := "off"
+	if on {
+		state = "on"
+	}
+	return ui.queueOperation(fmt.Sprintf("Turn %s %s", name, state), commandTimeout, func(ctx context.Context) tea.Msg {
+		err := ui.device.SetGroupPower(ctx, name, on)
+		return actionResultMsg{message: fmt.Sprintf("%s turned %s", name, state), err: err}
+	})
+}
+
+func (ui UI) confirmUnpair(ip string) (tea.Model, tea.Cmd) {
+	name := ui.device.GetConfig().displayName(ip)
+	return ui.ask(confirmDialog{
+		title:    fmt.Sprintf("Unpair %s?", name),
+		details:  []string{"Its token is forgotten; pairing it again", "needs the power button held."},
+		action:   "unpair",
+		declined: fmt.Sprintf("%s is still paired", name),
+		confirm: func(ui UI) (tea.Model, tea.Cmd) {
+			return ui.unpair(ip, name)
+		},
+	})
+}
+
+func (ui UI) unpair(ip, name string) (tea.Model, tea.Cmd) {
+	active := ip == ui.device.GetDeviceIP()
+	if err := ui.device.Unpair(ip); err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Unpair failed: %v", err))
+		return ui, nil
+	}
+	ui.message = successStyle.Render(fmt.Sprintf("Unpaired %s", name))
+	if len(ui.pairedIPs()) == 0 {
+		ui.focus = paneMenu
+	} else {
+		ui.deviceCursor = min(ui.deviceCursor, len(ui.paneEntries())-1)
+	}
+	if !active {
+		return ui, nil
+	}
+	ui.deviceReady = false
+	ui.status, ui.caps = nil, nil
+	ui.healthChecking, ui.healthKnown = false, false
+	ui.cursor = 0
+	if ui.device.GetDeviceIP() == "" {
+		return ui, nil
+	}
+	return ui, ui.checkDeviceStatus()
+}
+
+// switchDevice makes another paired device the active one and connects to it
+func (ui UI) switchDevice(ip string) (tea.Model, tea.Cmd) {
+	ui.focus = paneMenu
+	if ip == ui.device.GetDeviceIP() && ui.deviceReady {
+		return ui, nil
+	}
+	if err := ui.device.UseDevice(ip); err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Switching device failed: %v", err))
+		return ui, nil
+	}
+	ui.deviceReady = false
+	ui.status, ui.caps = nil, nil
+	ui.healthChecking, ui.healthKnown = false, false
+	ui.cursor = 0
+	ui.message = textStyle.Render(fmt.Sprintf("Connecting to %s...", ui.device.GetDeviceName()))
+	return ui, ui.checkDeviceStatus()
+}
+
+// devicePaneView lists the groups and paired devices left of the main box,
+// as tall as the main box so the panes line up
+func (ui UI) devicePaneView(height int) string {
+	online := make(map[string]bool)
+	for _, record := range ui.registry.Devices() {
+		online[record.IP] = record.Online
+	}
+	config := ui.device.GetConfig()
+
+	header := separatorStyle.Render("Devices")
+	if ui.focus == paneDevices {
+		header = selectedStyle.Render("Devices")
+	}
+	lines := []string{header, ""}
+	for i, entry := range ui.paneEntries() {
+		var line string
+		if entry.group != "" {
+			members, _ := config.groupMembers(entry.group)
+			line = clip(fmt.Sprintf("◆ %s (%d)", entry.group, len(members)), devicePaneWidth-4)
+		} else {
+			marker := "○"
+			if online[entry.ip] {
+				marker = "●"
+			}
+			active := " "
+			if entry.ip == ui.device.GetDeviceIP() {
+				active = "▸"
+			}
+			line = clip(fmt.Sprintf("%s %s %s", active, marker, config.displayName(entry.ip)), devicePaneWidth-4)
+		}
+		if ui.focus == paneDevices && i == ui.deviceCursor {
+			lines = append(lines, selectedStyle.Render(line))
+		} else {
+			lines = append(lines, textStyle.Render(line))
+		}
+	}
+	if ui.focus == paneDevices {
+		lines = append(lines, "", textStyle.Render("enter use · o/x power"), textStyle.Render("u unpair"))
+	} else {
+		lines = append(lines, "", textStyle.Render("tab to select"))
+	}
+
+	style := paneStyle.Height(max(height-2, 0))
+	if ui.focus == paneDevices {
+		style = style.BorderForeground(titleBoxStyle.GetForeground())
+	}
+	return style.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
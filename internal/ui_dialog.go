@@ -0,0 +1,54 @@
+package internal
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// confirmDialog asks a yes/no question before a destructive operation. It
+// is shown over the current screen, which keeps receiving results. Only y
+// confirms, so an enter pressed out of habit does not.
+type confirmDialog struct {
+	title string
+	// details explain what is about to change
+	details []string
+	// action names what y does in the key hint, such as "delete"
+	action string
+	// declined is the message shown when the answer is no
+	declined string
+	confirm  func(ui UI) (tea.Model, tea.Cmd)
+}
+
+// ask shows dialog until it is answered
+func (ui UI) ask(dialog confirmDialog) (tea.Model, tea.Cmd) {
+	ui.dialog = &dialog
+	return ui, nil
+}
+
+func (ui UI) updateDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	dialog := ui.dialog
+	switch msg.String() {
+	case "ctrl+c":
+		return ui, tea.Quit
+	case "y", "Y":
+		ui.dialog = nil
+		return dialog.confirm(ui)
+	case "n", "N", "esc", "q":
+		ui.dialog = nil
+		if dialog.declined != "" {
+			ui.message = textStyle.Render(dialog.declined)
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) dialogView() []string {
+	lines := []string{separatorStyle.Render(ui.dialog.title), ""}
+	for _, detail := range ui.dialog.details {
+		lines = append(lines, textStyle.Render(detail))
+	}
+	action := ui.dialog.action
+	if action == "" {
+		action = "confirm"
+	}
+	return append(lines, "", textStyle.Render("y to "+action+" · n or esc to cancel"))
+}
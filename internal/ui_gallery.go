@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func (ui UI) updateGallery(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case actionResultMsg:
+		if msg.err != nil {
+			ui.message = errorStyle.Render(fmt.Sprintf("Install failed: %v", msg.err))
+		} else {
+			ui.message = successStyle.Render(msg.message)
+		}
+	case previewLayoutMsg:
+		return ui.handlePreviewLayout(msg)
+	case previewTickMsg:
+		return ui.handlePreviewTick(msg)
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q":
+			ui.galleryMode = false
+			ui.preview = nil
+			ui.message = ""
+		case "up", "k":
+			if ui.galleryCursor > 0 {
+				ui.galleryCursor--
+				if ui.preview != nil {
+					return ui.startPreview()
+				}
+			}
+		case "down", "j":
+			if ui.galleryCursor < len(ui.galleryRows())-1 {
+				ui.galleryCursor++
+				if ui.preview != nil {
+					return ui.startPreview()
+				}
+			}
+		case "p":
+			return ui.togglePreview()
+		case "f":
+			return ui.toggleFavorite()
+		case "a":
+			if row := ui.galleryRows()[ui.galleryCursor]; row.entry != nil {
+				return ui, ui.handleApplyEffect(row.name, row.entry)
+			}
+		case "x":
+			return ui.confirmDeleteEffect(ui.galleryRows()[ui.galleryCursor].name)
+		case "enter":
+			row := ui.galleryRows()[ui.galleryCursor]
+			if row.section == "Gallery" {
+				return ui, ui.handleInstallEffect(*row.entry)
+			}
+			return ui, ui.handleApplyEffect(row.name, nil)
+		}
+	}
+	return ui, nil
+}
+
+// galleryRow is one line of the effects browser: a recent or favorite
+// effect by name, or a gallery entry. entry is set whenever the gallery has
+// an effect of that name.
+type galleryRow struct {
+	section string
+	name    string
+	entry   *galleryEntry
+}
+
+// galleryRows lists the recent and favorite effects of the device above the
+// gallery entries
+func (ui UI) galleryRows() []galleryRow {
+	byName := make(map[string]*galleryEntry, len(ui.gallery))
+	for i := range ui.gallery {
+		byName[ui.gallery[i].Name] = &ui.gallery[i]
+	}
+
+	var rows []galleryRow
+	for _, name := range ui.device.RecentEffects() {
+		rows = append(rows, galleryRow{section: "Recent", name: name, entry: byName[name]})
+	}
+	for _, name := range ui.device.FavoriteEffects() {
+		rows = append(rows, galleryRow{section: "Favorites", name: name, entry: byName[name]})
+	}
+	for i := range ui.gallery {
+		rows = append(rows, galleryRow{section: "Gallery", name: ui.gallery[i].Name, entry: &ui.gallery[i]})
+	}
+	return rows
+}
+
+func (ui UI) toggleFavorite() (tea.Model, tea.Cmd) {
+	name := ui.galleryRows()[ui.galleryCursor].name
+	favorite, err := ui.device.ToggleFavorite(name)
+	switch {
+	case err != nil:
+		ui.message = errorStyle.Render(fmt.Sprintf("Failed to save favorites: %v", err))
+	case favorite:
+		ui.message = successStyle.Render(fmt.Sprintf("Added %s to favorites", name))
+	default:
+		ui.message = successStyle.Render(fmt.Sprintf("Removed %s from favorites", name))
+	}
+	// The rows above the cursor may have changed
+	ui.galleryCursor = min(ui.galleryCursor, len(ui.galleryRows())-1)
+	return ui, nil
+}
+
+func (ui UI) handleGallery() tea.Cmd {
+	url := galleryURL(ui.device.GetConfig())
+	return func() tea.Msg {
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		entries, err := fetchGallery(ctx, url)
+		return galleryResultMsg{entries: entries, err: err}
+	}
+}
+
+func (ui UI) handleInstallEffect(entry galleryEntry) tea.Cmd {
+	return ui.queueOperation("Install "+entry.Name, commandTimeout, func(ctx context.Context) tea.Msg {
+		err := ui.device.AddEffect(ctx, entry.Effect)
+		return actionResultMsg{message: fmt.Sprintf("Installed %s", entry.Name), err: err}
+	})
+}
+
+func (ui UI) confirmDeleteEffect(name string) (tea.Model, tea.Cmd) {
+	return ui.ask(confirmDialog{
+		title:    fmt.Sprintf("Delete %s from %s?", name, ui.device.GetDeviceName()),
+		details:  []string{"The effect is removed from the device."},
+		action:   "delete",
+		declined: fmt.Sprintf("%s was kept", name),
+		confirm: func(ui UI) (tea.Model, tea.Cmd) {
+			return ui, ui.queueOperation("Delete "+name, commandTimeout, func(ctx context.Context) tea.Msg {
+				err := ui.device.DeleteEffect(ctx, name)
+				return actionResultMsg{message: fmt.Sprintf("Deleted %s", name), err: err}
+			})
+		},
+	})
+}
+
+// handleApplyEffect selects an installed effect, installing it from the
+// gallery first when entry is set
+func (ui UI) handleApplyEffect(name string, entry *galleryEntry) tea.Cmd {
+	return ui.queueOperation("Apply "+name, commandTimeout, func(ctx context.Context) tea.Msg {
+		if entry != nil {
+			if err := ui.device.AddEffect(ctx, entry.Effect); err != nil {
+				return actionResultMsg{err: err}
+			}
+		}
+		err := ui.device.SelectEffect(ctx, name)
+		return actionResultMsg{message: fmt.Sprintf("Applied %s", name), err: err}
+	})
+}
+
+func (ui UI) galleryView() []string {
+	lines := []string{separatorStyle.Render("Effects Gallery")}
+	rows := ui.galleryRows()
+	favorites := make(map[string]bool)
+	for _, name := range ui.device.FavoriteEffects() {
+		favorites[name] = true
+	}
+
+	for i, row := range rows {
+		if i == 0 || rows[i-1].section != row.section {
+			lines = append(lines, "", separatorStyle.Render(row.section))
+		}
+		name := row.name
+		if row.section == "Gallery" && row.entry.Author != "" {
+			name = fmt.Sprintf("%s by %s", row.name, row.entry.Author)
+		}
+		if row.section != "Favorites" && favorites[row.name] {
+			name += " ★"
+		}
+		if i == ui.galleryCursor {
+			name = selectedStyle.Render(name)
+		} else {
+			name = textStyle.Render(name)
+		}
+		swatch := "  "
+		if row.entry != nil {
+			swatch = paletteSwatch(effectPalette(row.entry.Effect))
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", swatch, name))
+	}
+
+	selected := rows[ui.galleryCursor]
+	if selected.entry != nil && selected.entry.Description != "" {
+		lines = append(lines, "", textStyle.Render(selected.entry.Description))
+	}
+	if ui.preview != nil {
+		lines = append(lines, ui.previewView()...)
+	}
+	help := "enter to install · a to apply · f to favorite · p to preview · esc to go back"
+	if selected.section != "Gallery" {
+		help = "enter to apply · f to favorite · x to delete · p to preview · esc to go back"
+	}
+	lines = append(lines, "", textStyle.Render(help))
+	return lines
+}
+
+// paletteSwatch renders each palette color as a small colored block
+func paletteSwatch(palette []paletteColor) string {
+	var b strings.Builder
+	for _, color := range palette {
+		rgb := hsbToRGB(color.Hue, color.Saturation, color.Brightness)
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color(rgb.String())).Render("██"))
+	}
+	return b.String()
+}
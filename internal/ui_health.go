@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const healthCheckInterval = 10 * time.Second
+
+type healthResultMsg struct {
+	session int
+	latency time.Duration
+	err     error
+}
+
+var (
+	latencyGoodStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF00"))
+	latencySlowStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFF00"))
+	latencyDownStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000"))
+)
+
+// checkHealth measures the round trip to the device once
+func (ui UI) checkHealth() tea.Cmd {
+	session := ui.healthSession
+	return func() tea.Msg {
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		latency, err := ui.device.Ping(ctx)
+		if ip := ui.device.GetDeviceIP(); err == nil {
+			ui.registry.Seen(ip, time.Now())
+			ui.registry.SetLatency(ip, latency)
+		} else {
+			ui.registry.MarkOffline(ip)
+		}
+		return healthResultMsg{session: session, latency: latency, err: err}
+	}
+}
+
+// startHealthChecks begins the periodic checks unless they already run
+func (ui UI) startHealthChecks() (UI, tea.Cmd) {
+	if ui.healthChecking {
+		return ui, nil
+	}
+	ui.healthChecking = true
+	ui.healthSession++
+	return ui, ui.checkHealth()
+}
+
+func (ui UI) handleHealthResult(msg healthResultMsg) (tea.Model, tea.Cmd) {
+	// Results of a stopped check loop end it
+	if !ui.healthChecking || msg.session != ui.healthSession {
+		return ui, nil
+	}
+	ui.latency = msg.latency
+	ui.reachable = msg.err == nil
+	ui.healthKnown = true
+	check := ui.checkHealth()
+	return ui, tea.Tick(healthCheckInterval, func(time.Time) tea.Msg {
+		return check()
+	})
+}
+
+// latencyIndicator renders the last round trip time with a colored symbol:
+// a full green dot under 50ms, a half yellow one under 200ms, an empty red
+// one when slower and a cross when offline
+func (ui UI) latencyIndicator() string {
+	if !ui.healthKnown {
+		return ""
+	}
+	switch {
+	case !ui.reachable:
+		return latencyDownStyle.Render("✗ offline")
+	case ui.latency < 50*time.Millisecond:
+		return latencyGoodStyle.Render(fmt.Sprintf("● %dms", ui.latency.Milliseconds()))
+	case ui.latency < 200*time.Millisecond:
+		return latencySlowStyle.Render(fmt.Sprintf("◐ %dms", ui.latency.Milliseconds()))
+	default:
+		return latencyDownStyle.Render(fmt.Sprintf("○ %dms", ui.latency.Milliseconds()))
+	}
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	historyLimit       = 200
+	historyVisibleRows = 10
+)
+
+type historyResultMsg struct {
+	entries []historyEntry
+	err     error
+}
+
+func (ui UI) openHistory() (tea.Model, tea.Cmd) {
+	return ui, func() tea.Msg {
+		entries, err := readHistory(time.Time{}, historyLimit)
+		return historyResultMsg{entries: entries, err: err}
+	}
+}
+
+func (ui UI) handleHistoryResult(msg historyResultMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("History failed: %v", msg.err))
+		return ui, nil
+	}
+	if len(msg.entries) == 0 {
+		ui.message = textStyle.Render("No commands recorded yet")
+		return ui, nil
+	}
+	ui.historyMode = true
+	ui.history = msg.entries
+	// Start at the newest entry
+	ui.historyCursor = len(msg.entries) - 1
+	ui.message = ""
+	return ui, nil
+}
+
+func (ui UI) updateHistory(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q":
+			ui.historyMode = false
+		case "up", "k":
+			if ui.historyCursor > 0 {
+				ui.historyCursor--
+			}
+		case "down", "j":
+			if ui.historyCursor < len(ui.history)-1 {
+				ui.historyCursor++
+			}
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) historyView() []string {
+	lines := []string{separatorStyle.Render("History"), ""}
+
+	// Keep the cursor inside a window of the most recent rows
+	start := max(0, min(ui.historyCursor-historyVisibleRows/2, len(ui.history)-historyVisibleRows))
+	end := min(len(ui.history), start+historyVisibleRows)
+	for i := start; i < end; i++ {
+		entry := ui.history[i]
+		mark := "ok"
+		if entry.Error != "" {
+			mark = "failed"
+		}
+		label := fmt.Sprintf("%s %s (%s)", entry.Time.Local().Format("01-02 15:04"), entry.Action, mark)
+		if i == ui.historyCursor {
+			lines = append(lines, selectedStyle.Render(label))
+		} else {
+			lines = append(lines, textStyle.Render(label))
+		}
+	}
+
+	selected := ui.history[ui.historyCursor]
+	lines = append(lines, "", separatorStyle.Render(selected.Device))
+	if selected.Error != "" {
+		lines = append(lines, errorStyle.Render(selected.Error))
+	}
+	return append(lines, "", textStyle.Render(fmt.Sprintf("%d of %d · esc to go back", ui.historyCursor+1, len(ui.history))))
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// infoResultMsg carries the device details for the info screen
+type infoResultMsg struct {
+	info    map[string]interface{}
+	caps    Capabilities
+	wifi    wifiInfo
+	wifiErr error
+	err     error
+}
+
+func (ui UI) openInfo() (tea.Model, tea.Cmd) {
+	ui.message = textStyle.Render("Loading device info...")
+	return ui, ui.fetchInfo()
+}
+
+func (ui UI) fetchInfo() tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		info, err := ui.device.client.getInfo(ctx, ui.device.GetConfig().IP, ui.device.GetConfig().Token)
+		if err != nil {
+			return infoResultMsg{err: err}
+		}
+		msg := infoResultMsg{info: info, caps: resolveCapabilities(info)}
+		msg.wifi, msg.wifiErr = ui.device.GetWifi(ctx, info)
+		return msg
+	}
+}
+
+func (ui UI) handleInfoResult(msg infoResultMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Device info failed: %v", msg.err))
+		return ui, nil
+	}
+	ui.infoMode = true
+	ui.info = msg
+	ui.message = ""
+	return ui, nil
+}
+
+func (ui UI) updateInfo(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case infoResultMsg:
+		return ui.handleInfoResult(msg)
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q", "d":
+			ui.infoMode = false
+		case "r":
+			return ui, ui.fetchInfo()
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) infoView() []string {
+	info := ui.info
+	name, _ := info.info["name"].(string)
+	serial, _ := info.info["serialNo"].(string)
+
+	lines := []string{separatorStyle.Render(ui.device.GetDeviceName()), ""}
+	row := func(label, value string) {
+		if value != "" {
+			lines = append(lines, textStyle.Render(fmt.Sprintf("%-10s %s", label, value)))
+		}
+	}
+	row("Name", name)
+	row("IP", ui.device.GetDeviceIP())
+	row("Model", info.caps.Model)
+	row("Firmware", info.caps.Firmware)
+	row("Serial", serial)
+	if ui.healthKnown && ui.reachable {
+		row("Latency", fmt.Sprintf("%dms", ui.latency.Milliseconds()))
+	}
+
+	switch {
+	case errors.Is(info.wifiErr, errNoWifiInfo):
+		row("Wi-Fi", "not reported by this firmware")
+	case info.wifiErr != nil:
+		row("Wi-Fi", "could not be read: "+info.wifiErr.Error())
+	case info.wifi.quality() == "fair" || info.wifi.quality() == "weak":
+		row("Wi-Fi", info.wifi.String())
+		lines = append(lines, "", errorStyle.Render("A weak signal makes the panels lag, try moving the router closer"))
+	default:
+		row("Wi-Fi", info.wifi.String())
+	}
+	return append(lines, "", textStyle.Render("r to refresh · esc to go back"))
+}
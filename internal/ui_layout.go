@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	layoutMapWidth  = 44
+	layoutMapHeight = 12
+)
+
+// layoutLookInterval is the frame time of the colors on the layout map
+const layoutLookInterval = 100 * time.Millisecond
+
+type (
+	layoutResultMsg struct {
+		layout Layout
+		err    error
+		// refresh replaces the layout of the open map after a layout event
+		refresh bool
+	}
+	layoutLookMsg struct {
+		session int
+		look    EffectGenerator
+		err     error
+	}
+	layoutEventsMsg struct {
+		session int
+		events  <-chan deviceEvent
+		err     error
+	}
+	layoutEventMsg struct {
+		session int
+		event   deviceEvent
+		ok      bool
+	}
+	layoutTickMsg struct{ session int }
+)
+
+func (ui UI) openLayout() (tea.Model, tea.Cmd) {
+	ui.message = textStyle.Render("Loading layout...")
+	return ui, ui.fetchLayout(false)
+}
+
+func (ui UI) fetchLayout(refresh bool) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		layout, err := ui.device.GetLayout(ctx)
+		return layoutResultMsg{layout: layout, err: err, refresh: refresh}
+	}
+}
+
+func (ui UI) updateLayout(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case layoutResultMsg:
+		return ui.handleLayoutResult(msg)
+	case layoutLookMsg:
+		if msg.session == ui.layoutSession {
+			if msg.err != nil {
+				ui.message = errorStyle.Render(fmt.Sprintf("Colors unavailable: %v", msg.err))
+			} else {
+				ui.layoutLook = msg.look
+			}
+		}
+	case layoutEventsMsg:
+		if msg.session != ui.layoutSession {
+			return ui, nil
+		}
+		if msg.err != nil {
+			ui.message = errorStyle.Render(fmt.Sprintf("Live colors unavailable: %v", msg.err))
+			return ui, nil
+		}
+		ui.layoutEvents = msg.events
+		return ui, ui.nextLayoutEvent()
+	case layoutEventMsg:
+		if msg.session != ui.layoutSession || !msg.ok {
+			return ui, nil
+		}
+		if msg.event.Type == eventLayout {
+			return ui, tea.Batch(ui.fetchLayout(true), ui.nextLayoutEvent())
+		}
+		return ui, tea.Batch(ui.fetchLook(), ui.nextLayoutEvent())
+	case layoutTickMsg:
+		if msg.session == ui.layoutSession {
+			return ui, layoutTick(msg.session)
+		}
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q":
+			ui = ui.closeLayout()
+			ui.message = ""
+		case "r":
+			ui.layoutTransform = ui.layoutTransform.rotated()
+		case "m":
+			ui.layoutTransform.Mirror = !ui.layoutTransform.Mirror
+		case "enter":
+			if err := ui.device.SetLayoutTransform(ui.layoutTransform); err != nil {
+				ui.message = errorStyle.Render(fmt.Sprintf("Failed to save: %v", err))
+			} else {
+				ui.message = successStyle.Render("Layout orientation saved")
+			}
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) handleLayoutResult(msg layoutResultMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Layout failed: %v", msg.err))
+		return ui, nil
+	}
+	ui.layout = msg.layout
+	if msg.refresh {
+		return ui, nil
+	}
+	ui.layoutMode = true
+	ui.layoutTransform = LayoutTransform{}
+	if saved := ui.device.GetConfig().LayoutTransform; saved != nil {
+		ui.layoutTransform = *saved
+	}
+	ui.message = ""
+	return ui.startLayoutLook()
+}
+
+// startLayoutLook colors the map with what the panels show. A stream sent
+// from here is mirrored frame by frame; otherwise the state is fetched again
+// whenever the device event stream reports a change.
+func (ui UI) startLayoutLook() (tea.Model, tea.Cmd) {
+	ui.layoutSession++
+	ui.layoutLookStart = time.Now()
+	if ui.liveScheduler != nil {
+		ui.layoutLook = streamLook{ui.liveScheduler}
+		return ui, layoutTick(ui.layoutSession)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ui.layoutCancel = cancel
+	session := ui.layoutSession
+	watch := func() tea.Msg {
+		events, err := ui.device.WatchEvents(ctx, eventState, eventLayout, eventEffects)
+		return layoutEventsMsg{session: session, events: events, err: err}
+	}
+	return ui, tea.Batch(ui.fetchLook(), watch, layoutTick(session))
+}
+
+func (ui UI) fetchLook() tea.Cmd {
+	session := ui.layoutSession
+	return func() tea.Msg {
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		look, err := ui.device.CurrentLook(ctx)
+		return layoutLookMsg{session: session, look: look, err: err}
+	}
+}
+
+func (ui UI) nextLayoutEvent() tea.Cmd {
+	session, events := ui.layoutSession, ui.layoutEvents
+	return func() tea.Msg {
+		event, ok := <-events
+		return layoutEventMsg{session: session, event: event, ok: ok}
+	}
+}
+
+func layoutTick(session int) tea.Cmd {
+	return tea.Tick(layoutLookInterval, func(time.Time) tea.Msg {
+		return layoutTickMsg{session: session}
+	})
+}
+
+// closeLayout leaves the layout map and stops following the device
+func (ui UI) closeLayout() UI {
+	if ui.layoutCancel != nil {
+		ui.layoutCancel()
+	}
+	ui.layoutCancel = nil
+	ui.layoutEvents = nil
+	ui.layoutLook = nil
+	ui.layoutSession++
+	ui.layoutMode = false
+	return ui
+}
+
+func (ui UI) layoutView() []string {
+	layout := ui.layoutTransform.apply(ui.layout)
+	cell := panelSymbol
+	if ui.layoutLook != nil {
+		colors := make(map[int]rgbColor)
+		for _, c := range ui.layoutLook.NextFrame(layout, time.Since(ui.layoutLookStart)) {
+			colors[c.PanelID] = rgbColor{c.R, c.G, c.B}
+		}
+		cell = func(p Panel) string {
+			color, ok := colors[p.ID]
+			if !ok {
+				return textStyle.Render(panelSymbol(p))
+			}
+			return lipgloss.NewStyle().Foreground(lipgloss.Color(color.String())).Render(panelSymbol(p))
+		}
+	}
+
+	lines := []string{separatorStyle.Render("Panel Layout"), ""}
+	lines = append(lines, renderLayoutMap(layout, layoutMapWidth, layoutMapHeight, cell)...)
+
+	mirror := "off"
+	if ui.layoutTransform.Mirror {
+		mirror = "on"
+	}
+	return append(lines,
+		"",
+		textStyle.Render(fmt.Sprintf("Rotation: %d° · Mirror: %s", ui.layoutTransform.Rotation, mirror)),
+		textStyle.Render("r rotate · m mirror · enter save · esc back"),
+	)
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// isLines reports whether the connected device is a Lines set
+func (ui UI) isLines() bool {
+	return ui.caps != nil && ui.caps.Model == "Lines"
+}
+
+func (ui UI) updateLines(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q":
+			ui.linesMode = false
+		case "up", "k":
+			if ui.linesPalette > 0 {
+				ui.linesPalette--
+			}
+		case "down", "j":
+			if ui.linesPalette < len(linesPalettes)-1 {
+				ui.linesPalette++
+			}
+		case "left":
+			ui.linesDirection = (ui.linesDirection + len(linesDirections) - 1) % len(linesDirections)
+		case "right":
+			ui.linesDirection = (ui.linesDirection + 1) % len(linesDirections)
+		case "tab":
+			if ui.linesStyle == "gradient" {
+				ui.linesStyle = "flow"
+			} else {
+				ui.linesStyle = "gradient"
+			}
+		case "enter":
+			return ui, ui.applyLines()
+		}
+	case actionResultMsg:
+		if msg.err != nil {
+			ui.message = errorStyle.Render(fmt.Sprintf("Action failed: %v", msg.err))
+		} else {
+			ui.message = successStyle.Render(msg.message)
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) applyLines() tea.Cmd {
+	choice := linesPalettes[ui.linesPalette]
+	style, direction := ui.linesStyle, linesDirections[ui.linesDirection]
+	return func() tea.Msg {
+		var palette []rgbColor
+		for _, name := range choice.colors {
+			c, _ := parseColor(name)
+			palette = append(palette, c)
+		}
+
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		layout, err := ui.device.GetLayout(ctx)
+		if err != nil {
+			return actionResultMsg{err: err}
+		}
+		effect, err := linesEffect(layout, style, direction, palette)
+		if err == nil {
+			err = ui.device.DisplayEffect(ctx, effect)
+		}
+		return actionResultMsg{message: fmt.Sprintf("%s %s %s", choice.name, style, direction), err: err}
+	}
+}
+
+func (ui UI) linesView() []string {
+	lines := []string{separatorStyle.Render("Lines Controls"), ""}
+	for i, p := range linesPalettes {
+		label := fmt.Sprintf("%-8s %s", p.name, strings.Join(p.colors, " "))
+		if i == ui.linesPalette {
+			lines = append(lines, selectedStyle.Render(label))
+		} else {
+			lines = append(lines, textStyle.Render(label))
+		}
+	}
+	return append(lines,
+		"",
+		textStyle.Render(fmt.Sprintf("Style: %s (tab)  Direction: %s (←/→)", ui.linesStyle, linesDirections[ui.linesDirection])),
+		textStyle.Render("enter to apply · esc to go back"),
+	)
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const liveFPS = 20
+
+// maxStreamFPS is the highest frame rate of a stream, as --fps of stream
+// and music allow
+const maxStreamFPS = 60
+
+// streamFPS returns the frame rate of the live effects, streamFps or
+// liveFPS when it is not set
+func (c Config) streamFPS() (int, error) {
+	if c.StreamFPS == 0 {
+		return liveFPS, nil
+	}
+	if c.StreamFPS < 1 || c.StreamFPS > maxStreamFPS {
+		return 0, fmt.Errorf("streamFps must be between 1 and %d", maxStreamFPS)
+	}
+	return c.StreamFPS, nil
+}
+
+type liveStoppedMsg struct {
+	session int
+	err     error
+}
+
+func (ui UI) updateLive(msg tea.Msg) (tea.Model, tea.Cmd) {
+	names := generatorNames()
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q":
+			ui.liveMode = false
+		case "up", "k":
+			if ui.liveCursor > 0 {
+				ui.liveCursor--
+			}
+		case "down", "j":
+			if ui.liveCursor < len(names)-1 {
+				ui.liveCursor++
+			}
+		case "left", "-":
+			if ui.liveSpeed > 0.25 {
+				ui.liveSpeed -= 0.25
+			}
+		case "right", "+":
+			if ui.liveSpeed < 4 {
+				ui.liveSpeed += 0.25
+			}
+		case "s":
+			var stats string
+			if ui.liveScheduler != nil {
+				stats = ui.liveScheduler.Stats().String()
+			}
+			ui = ui.stopLive()
+			ui.message = successStyle.Render("Streaming stopped")
+			if stats != "" {
+				ui.message += "\n" + separatorStyle.Render(stats)
+			}
+		case "enter":
+			return ui.startLive(names[ui.liveCursor])
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) startLive(name string) (tea.Model, tea.Cmd) {
+	generator, err := newGenerator(name, GeneratorOptions{Speed: ui.liveSpeed})
+	if err != nil {
+		ui.message = errorStyle.Render(err.Error())
+		return ui, nil
+	}
+	fps, err := ui.device.GetConfig().streamFPS()
+	if err != nil {
+		ui.message = errorStyle.Render(err.Error())
+		return ui, nil
+	}
+
+	ui = ui.stopLive()
+	ctx, cancel := context.WithCancel(context.Background())
+	ui.liveSession++
+	ui.liveCancel = cancel
+	ui.liveRunning = name
+	ui.message = successStyle.Render(fmt.Sprintf("Streaming %s at %.2gx speed", name, ui.liveSpeed))
+
+	scheduler := newFrameScheduler(fps)
+	ui.liveScheduler = scheduler
+
+	session := ui.liveSession
+	return ui, func() tea.Msg {
+		err := streamWithExit(ctx, ui.device, generator, scheduler, "")
+		return liveStoppedMsg{session: session, err: err}
+	}
+}
+
+func (ui UI) stopLive() UI {
+	if ui.liveCancel != nil {
+		ui.liveCancel()
+	}
+	ui.liveCancel = nil
+	ui.liveScheduler = nil
+	ui.liveRunning = ""
+	return ui
+}
+
+func (ui UI) handleLiveStopped(msg liveStoppedMsg) (tea.Model, tea.Cmd) {
+	// A stopped or replaced session has already been cleaned up
+	if msg.session != ui.liveSession || ui.liveRunning == "" {
+		return ui, nil
+	}
+	ui = ui.stopLive()
+	if msg.err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Streaming failed: %v", msg.err))
+	}
+	return ui, nil
+}
+
+func (ui UI) liveView() []string {
+	lines := []string{separatorStyle.Render("Live Effects"), ""}
+	for i, name := range generatorNames() {
+		label := name
+		if name == ui.liveRunning {
+			label += " (streaming)"
+		}
+		if i == ui.liveCursor {
+			lines = append(lines, selectedStyle.Render(label))
+		} else {
+			lines = append(lines, textStyle.Render(label))
+		}
+	}
+	return append(lines,
+		"",
+		textStyle.Render(fmt.Sprintf("Speed: %.2gx (←/→ to adjust)", ui.liveSpeed)),
+		textStyle.Render("enter to stream · s to stop · esc to go back"),
+	)
+}
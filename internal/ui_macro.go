@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (ui UI) updateMacros(msg tea.Msg) (tea.Model, tea.Cmd) {
+	names := macroNames(ui.device.GetConfig().Macros)
+
+	switch msg := msg.(type) {
+	case actionResultMsg:
+		if msg.err != nil {
+			ui.message = errorStyle.Render(fmt.Sprintf("Action failed: %v", msg.err))
+		} else {
+			ui.message = successStyle.Render(msg.message)
+		}
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q":
+			ui = ui.closeMacros()
+		case "up", "k":
+			if ui.macroCursor > 0 {
+				ui.macroCursor--
+			}
+		case "down", "j":
+			if ui.macroCursor < len(names)-1 {
+				ui.macroCursor++
+			}
+		case "enter":
+			if ui.macroCursor < len(names) {
+				name := names[ui.macroCursor]
+				ui.message = textStyle.Render(fmt.Sprintf("Running %s... (esc stops it)", name))
+				return ui, ui.handleRunMacro(name, ui.macroCtx)
+			}
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) openMacros() UI {
+	ui.macroMode = true
+	ui.macroCursor = 0
+	ui.macroCtx, ui.macroCancel = context.WithCancel(context.Background())
+	return ui
+}
+
+// closeMacros leaves the macro screen and stops the macros run from it
+func (ui UI) closeMacros() UI {
+	if ui.macroCancel != nil {
+		ui.macroCancel()
+	}
+	ui.macroCtx, ui.macroCancel = nil, nil
+	ui.macroMode = false
+	return ui
+}
+
+// handleRunMacro runs a macro until it ends, ctrl+x cancels it or screen,
+// the context of the screen it was started from, is done
+func (ui UI) handleRunMacro(name string, screen context.Context) tea.Cmd {
+	// Macros may contain delays, so they run without the usual deadline
+	return ui.queueOperation("Macro "+name, 0, func(ctx context.Context) tea.Msg {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(screen, cancel)
+		defer stop()
+		err := runMacro(ctx, ui.device, name)
+		if err != nil && screen.Err() != nil {
+			err = fmt.Errorf("macro %s stopped", name)
+		}
+		return actionResultMsg{message: fmt.Sprintf("Macro %s done", name), err: err}
+	})
+}
+
+func (ui UI) macroView() []string {
+	macros := ui.device.GetConfig().Macros
+	lines := []string{separatorStyle.Render("Macros"), ""}
+	for i, name := range macroNames(macros) {
+		label := fmt.Sprintf("%s: %s", name, strings.Join(macros[name], ", "))
+		if i == ui.macroCursor {
+			lines = append(lines, selectedStyle.Render(label))
+		} else {
+			lines = append(lines, textStyle.Render(label))
+		}
+	}
+	return append(lines, "", textStyle.Render("enter to run · esc to go back"))
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (ui UI) openMaintenance() (tea.Model, tea.Cmd) {
+	ui.maintenanceMode = true
+	ui.maintenanceCursor = 0
+	return ui, nil
+}
+
+func (ui UI) updateMaintenance(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case actionResultMsg:
+		if msg.err != nil {
+			ui.message = errorStyle.Render(fmt.Sprintf("Action failed: %v", msg.err))
+		} else {
+			ui.message = successStyle.Render(msg.message)
+		}
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q":
+			ui.maintenanceMode = false
+		case "up", "k":
+			if ui.maintenanceCursor > 0 {
+				ui.maintenanceCursor--
+			}
+		case "down", "j":
+			if ui.maintenanceCursor < len(maintenanceActions)-1 {
+				ui.maintenanceCursor++
+			}
+		case "enter":
+			action := maintenanceActions[ui.maintenanceCursor]
+			if action.confirm != "" {
+				return ui.ask(confirmDialog{
+					title:    fmt.Sprintf("%s %s?", action.label, ui.device.GetDeviceName()),
+					details:  []string{action.confirm},
+					action:   strings.ToLower(action.label),
+					declined: "Nothing was changed",
+					confirm: func(ui UI) (tea.Model, tea.Cmd) {
+						return ui, ui.runMaintenance(action)
+					},
+				})
+			}
+			return ui, ui.runMaintenance(action)
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) runMaintenance(action maintenanceAction) tea.Cmd {
+	return ui.queueOperation(action.label, maintenanceTimeout, func(ctx context.Context) tea.Msg {
+		message, err := action.run(ctx, ui.device)
+		return actionResultMsg{message: message, err: err}
+	})
+}
+
+func (ui UI) maintenanceView() []string {
+	lines := []string{separatorStyle.Render("Maintenance"), ""}
+	for i, action := range maintenanceActions {
+		if i == ui.maintenanceCursor {
+			lines = append(lines, selectedStyle.Render(action.label))
+		} else {
+			lines = append(lines, textStyle.Render(action.label))
+		}
+	}
+	return append(lines, "", textStyle.Render("enter to run · esc to go back"))
+}
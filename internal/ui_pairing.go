@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pairQueueMsg is the outcome of pairing one device from the queue
+type pairQueueMsg struct {
+	ip  string
+	err error
+}
+
+// startPairAll queues every device found by the last scan for pairing
+func (ui UI) startPairAll() (tea.Model, tea.Cmd) {
+	ui.pairMode = true
+	ui.pairQueue = append([]string(nil), ui.scanned...)
+	ui.pairIndex = 0
+	ui.pairResults = nil
+	ui.pairBusy = false
+	ui.message = ""
+	return ui, nil
+}
+
+func (ui UI) updatePairing(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case pairQueueMsg:
+		ui.pairBusy, ui.taskCancel = false, nil
+		var unsaved *UnsavedTokenError
+		if errors.Is(msg.err, context.Canceled) {
+			// Stopping the queue keeps what was paired before
+			ui.pairResults = append(ui.pairResults, msg.ip+" cancelled")
+			return ui.finishPairing()
+		} else if errors.As(msg.err, &unsaved) {
+			ui.pairResults = append(ui.pairResults, fmt.Sprintf("%s not saved, token %s", msg.ip, unsaved.Token))
+		} else if msg.err != nil {
+			ui.pairResults = append(ui.pairResults, fmt.Sprintf("%s failed: %v", msg.ip, msg.err))
+		} else {
+			ui.pairResults = append(ui.pairResults, msg.ip+" paired")
+		}
+		return ui.nextPair()
+	case tea.KeyMsg:
+		if ui.pairBusy {
+			switch msg.String() {
+			case "ctrl+c":
+				return ui, tea.Quit
+			case "esc":
+				return ui.cancelTask()
+			}
+			return ui, nil
+		}
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q":
+			return ui.finishPairing()
+		case "s":
+			ui.pairResults = append(ui.pairResults, ui.pairQueue[ui.pairIndex]+" skipped")
+			return ui.nextPair()
+		case "enter":
+			ip := ui.pairQueue[ui.pairIndex]
+			ctx, cancel := ui.device.createContext()
+			ui.pairBusy, ui.taskCancel = true, cancel
+			return ui, func() tea.Msg {
+				defer cancel()
+				return pairQueueMsg{ip: ip, err: ui.device.PairAnother(ctx, ip)}
+			}
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) nextPair() (tea.Model, tea.Cmd) {
+	ui.pairIndex++
+	if ui.pairIndex >= len(ui.pairQueue) {
+		return ui.finishPairing()
+	}
+	return ui, nil
+}
+
+// finishPairing leaves the queue and connects to the active device if one
+// was paired
+func (ui UI) finishPairing() (tea.Model, tea.Cmd) {
+	ui.pairMode = false
+	paired := 0
+	for _, result := range ui.pairResults {
+		if strings.HasSuffix(result, " paired") {
+			paired++
+		}
+	}
+	ui.message = successStyle.Render(fmt.Sprintf("Paired %d of %d device(s)", paired, len(ui.pairQueue)))
+	if paired == 0 {
+		return ui, nil
+	}
+	return ui, ui.checkDeviceStatus()
+}
+
+func (ui UI) pairingView() []string {
+	lines := []string{separatorStyle.Render(fmt.Sprintf("Pairing %d of %d", ui.pairIndex+1, len(ui.pairQueue))), ""}
+	for _, result := range ui.pairResults {
+		lines = append(lines, separatorStyle.Render(result))
+	}
+	if len(ui.pairResults) > 0 {
+		lines = append(lines, "")
+	}
+
+	ip := ui.pairQueue[ui.pairIndex]
+	if ui.pairBusy {
+		lines = append(lines, textStyle.Render(fmt.Sprintf("Pairing %s... esc to cancel", ip)))
+	} else {
+		lines = append(lines,
+			selectedStyle.Render(ip),
+			textStyle.Render("Hold its power button for ~5 seconds until the"),
+			textStyle.Render("light flashes, then press enter"),
+		)
+	}
+	return append(lines, "", textStyle.Render("enter to pair · s to skip · esc to stop"))
+}
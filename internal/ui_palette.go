@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// paletteLimit is how many matches the command palette shows at once
+const paletteLimit = 8
+
+// paletteItem is one action the command palette can run
+type paletteItem struct {
+	label string
+	run   func(ui UI) (tea.Model, tea.Cmd)
+}
+
+// paletteEffectsMsg carries the effects installed on the device, so the
+// palette can offer them alongside the recent and favorite ones
+type paletteEffectsMsg struct {
+	names []string
+	err   error
+}
+
+func (ui UI) openPalette() (tea.Model, tea.Cmd) {
+	input := textinput.New()
+	input.Placeholder = "liv off, north lig..."
+	input.CharLimit = 64
+	input.Width = 40
+	input.Focus()
+	ui.paletteMode = true
+	ui.paletteInput = input
+	ui.paletteCursor = 0
+	if !ui.deviceReady {
+		return ui, textinput.Blink
+	}
+	return ui, tea.Batch(textinput.Blink, ui.fetchPaletteEffects())
+}
+
+func (ui UI) fetchPaletteEffects() tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := ui.device.createContext()
+		defer cancel()
+		effects, err := ui.device.ListEffects(ctx)
+		if err != nil {
+			return paletteEffectsMsg{err: err}
+		}
+		var names []string
+		for _, effect := range effects {
+			if name, _ := effect["animName"].(string); name != "" {
+				names = append(names, name)
+			}
+		}
+		return paletteEffectsMsg{names: names}
+	}
+}
+
+// paletteItems lists every action, effect, preset, macro and paired device
+// the palette can run from the current state
+func (ui UI) paletteItems() []paletteItem {
+	var items []paletteItem
+	for i, choice := range ui.getMenuChoices() {
+		// Drop the "[x] " key hint, it only gets in the way of matching
+		label := choice
+		if end := strings.Index(choice, "] "); end >= 0 {
+			label = choice[end+2:]
+		}
+		index := i
+		items = append(items, paletteItem{label: label, run: func(ui UI) (tea.Model, tea.Cmd) {
+			ui.cursor = index
+			return ui.handleMenuSelect()
+		}})
+	}
+	if !ui.deviceReady {
+		return items
+	}
+
+	config := ui.device.GetConfig()
+	seen := make(map[string]bool)
+	for _, names := range [][]string{ui.device.FavoriteEffects(), ui.device.RecentEffects(), ui.paletteEffects} {
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			effect := name
+			items = append(items, paletteItem{label: "Effect: " + effect, run: func(ui UI) (tea.Model, tea.Cmd) {
+				ui.message = textStyle.Render(fmt.Sprintf("Applying %s...", effect))
+				return ui, ui.handleApplyEffect(effect, nil)
+			}})
+		}
+	}
+	for _, key := range presetKeys(config.Presets) {
+		preset := config.Presets[key]
+		items = append(items, paletteItem{label: "Preset: " + preset.String(), run: func(ui UI) (tea.Model, tea.Cmd) {
+			return ui, ui.confirmPreset(preset)
+		}})
+	}
+	for _, name := range macroNames(config.Macros) {
+		macro := name
+		items = append(items, paletteItem{label: "Macro: " + macro, run: func(ui UI) (tea.Model, tea.Cmd) {
+			ui.message = textStyle.Render(fmt.Sprintf("Running %s...", macro))
+			return ui, ui.handleRunMacro(macro, context.Background())
+		}})
+	}
+	var ips []string
+	for ip := range config.pairedDevices() {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		name := config.displayName(ip)
+		for _, on := range []bool{true, false} {
+			target, power := ip, on
+			label := name + ": Turn Off"
+			if on {
+				label = name + ": Turn On"
+			}
+			items = append(items, paletteItem{label: label, run: func(ui UI) (tea.Model, tea.Cmd) {
+				return ui, ui.handlePairedPower(target, power)
+			}})
+		}
+	}
+	return items
+}
+
+func (ui UI) handlePairedPower(ip string, on bool) tea.Cmd {
+	state := "off"
+	if on {
+		state = "on"
+	}
+	name := ui.device.GetConfig().displayName(ip)
+	return ui.queueOperation(fmt.Sprintf("Turn %s %s", name, state), commandTimeout, func(ctx context.Context) tea.Msg {
+		err := ui.device.SetPairedPower(ctx, ip, on)
+		return actionResultMsg{message: fmt.Sprintf("%s turned %s", name, state), err: err}
+	})
+}
+
+// paletteMatches ranks the palette items against what has been typed
+func (ui UI) paletteMatches() []paletteItem {
+	items := ui.paletteItems()
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.label
+	}
+	var matches []paletteItem
+	for _, i := range fuzzyRank(ui.paletteInput.Value(), labels) {
+		matches = append(matches, items[i])
+		if len(matches) == paletteLimit {
+			break
+		}
+	}
+	return matches
+}
+
+func (ui UI) updatePalette(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case paletteEffectsMsg:
+		// Without the installed effects the palette still offers the rest
+		if msg.err == nil {
+			ui.paletteEffects = msg.names
+		}
+		return ui, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "ctrl+p":
+			ui.paletteMode = false
+			return ui, nil
+		case "up", "ctrl+k":
+			if ui.paletteCursor > 0 {
+				ui.paletteCursor--
+			}
+			return ui, nil
+		case "down", "ctrl+j":
+			if ui.paletteCursor < len(ui.paletteMatches())-1 {
+				ui.paletteCursor++
+			}
+			return ui, nil
+		case "enter":
+			matches := ui.paletteMatches()
+			ui.paletteMode = false
+			if ui.paletteCursor >= len(matches) {
+				ui.message = errorStyle.Render(fmt.Sprintf("Nothing matches %q", ui.paletteInput.Value()))
+				return ui, nil
+			}
+			return matches[ui.paletteCursor].run(ui)
+		}
+	}
+
+	var cmd tea.Cmd
+	before := ui.paletteInput.Value()
+	ui.paletteInput, cmd = ui.paletteInput.Update(msg)
+	if ui.paletteInput.Value() != before {
+		ui.paletteCursor = 0
+	}
+	return ui, cmd
+}
+
+func (ui UI) paletteView() []string {
+	lines := []string{separatorStyle.Render("Command Palette"), "", ui.paletteInput.View(), ""}
+	matches := ui.paletteMatches()
+	if len(matches) == 0 {
+		lines = append(lines, textStyle.Render("No matches"))
+	}
+	for i, item := range matches {
+		if i == ui.paletteCursor {
+			lines = append(lines, selectedStyle.Render(item.label))
+		} else {
+			lines = append(lines, textStyle.Render(item.label))
+		}
+	}
+	return append(lines, "", textStyle.Render("enter to run · ↑/↓ to choose · esc to close"))
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// previewInterval is the frame time of effect previews
+const previewInterval = 100 * time.Millisecond
+
+type (
+	previewLayoutMsg struct {
+		layout Layout
+		err    error
+	}
+	previewTickMsg struct{ session int }
+)
+
+// togglePreview starts or stops previewing the selected gallery effect. The
+// layout is fetched once, the animation itself never touches the device.
+func (ui UI) togglePreview() (tea.Model, tea.Cmd) {
+	if ui.preview != nil {
+		ui.preview = nil
+		ui.previewSession++
+		return ui, nil
+	}
+	if len(ui.previewLayout.LightPanels()) == 0 {
+		ui.message = textStyle.Render("Loading layout...")
+		return ui, func() tea.Msg {
+			ctx, cancel := ui.device.createContext()
+			defer cancel()
+			layout, err := ui.device.GetLayout(ctx)
+			return previewLayoutMsg{layout: layout, err: err}
+		}
+	}
+	return ui.startPreview()
+}
+
+func (ui UI) startPreview() (tea.Model, tea.Cmd) {
+	row := ui.galleryRows()[ui.galleryCursor]
+	if row.entry == nil {
+		ui.preview = nil
+		ui.message = textStyle.Render(fmt.Sprintf("No preview: %s is not in the gallery", row.name))
+		return ui, nil
+	}
+	preview, err := newEffectPreview(row.entry.Effect)
+	if err != nil {
+		ui.preview = nil
+		ui.message = errorStyle.Render(fmt.Sprintf("No preview: %v", err))
+		return ui, nil
+	}
+	ui.preview = preview
+	ui.previewStart = time.Now()
+	ui.previewSession++
+	ui.message = ""
+	return ui, previewTick(ui.previewSession)
+}
+
+func previewTick(session int) tea.Cmd {
+	return tea.Tick(previewInterval, func(time.Time) tea.Msg {
+		return previewTickMsg{session: session}
+	})
+}
+
+func (ui UI) handlePreviewLayout(msg previewLayoutMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("Layout failed: %v", msg.err))
+		return ui, nil
+	}
+	ui.previewLayout = msg.layout
+	if saved := ui.device.GetConfig().LayoutTransform; saved != nil {
+		ui.previewLayout = saved.apply(msg.layout)
+	}
+	return ui.startPreview()
+}
+
+func (ui UI) handlePreviewTick(msg previewTickMsg) (tea.Model, tea.Cmd) {
+	// Ticks of a stopped or replaced preview end here
+	if msg.session != ui.previewSession || ui.preview == nil {
+		return ui, nil
+	}
+	return ui, previewTick(msg.session)
+}
+
+// previewView draws the current frame of the preview on the panel map
+func (ui UI) previewView() []string {
+	frame := ui.preview.NextFrame(ui.previewLayout, time.Since(ui.previewStart))
+	colors := make(map[int]rgbColor, len(frame))
+	for _, c := range frame {
+		colors[c.PanelID] = rgbColor{c.R, c.G, c.B}
+	}
+
+	lines := []string{""}
+	for _, line := range renderLayoutMap(ui.previewLayout, layoutMapWidth, layoutMapHeight, func(p Panel) string {
+		style := lipgloss.NewStyle().Foreground(lipgloss.Color(colors[p.ID].String()))
+		return style.Render(panelSymbol(p))
+	}) {
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	return append(lines, textStyle.Render("Preview only, the panels are unchanged"))
+}
@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openQR shows the credentials of the active device as a QR code
+func (ui UI) openQR() (tea.Model, tea.Cmd) {
+	config := ui.device.GetConfig()
+	code, err := renderQR(pairingPayload(config.IP, config.Token))
+	if err != nil {
+		ui.message = errorStyle.Render(fmt.Sprintf("QR code failed: %v", err))
+		return ui, nil
+	}
+	ui.qrMode = true
+	ui.qrCode = code
+	ui.message = ""
+	return ui, nil
+}
+
+func (ui UI) updateQR(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c":
+			return ui, tea.Quit
+		case "esc", "q", "enter", "i":
+			ui.qrMode = false
+		}
+	}
+	return ui, nil
+}
+
+func (ui UI) qrView() []string {
+	lines := strings.Split(ui.qrCode, "\n")
+	return append(lines, "", textStyle.Render("Scan to import the device elsewhere · esc to close"))
+}
@@ -0,0 +1,52 @@
+package internal
+
+// resumeDevice makes the device of the previous session active again, if it
+// is still paired
+func (ui UI) resumeDevice() error {
+	ip := ui.session.Device
+	if ip == "" || ip == ui.device.GetDeviceIP() {
+		return nil
+	}
+	if _, ok := ui.device.GetConfig().pairedDevices()[ip]; !ok {
+		return nil
+	}
+	return ui.device.UseDevice(ip)
+}
+
+// resumeCursor moves the menu cursor back to where the previous session
+// left it
+func (ui UI) resumeCursor() UI {
+	if ui.resumed || ui.session.Menu == "" {
+		return ui
+	}
+	for i, choice := range ui.getMenuChoices() {
+		if choice == ui.session.Menu {
+			ui.cursor = i
+		}
+	}
+	return ui
+}
+
+// galleryRowOf returns the first gallery row showing effect, or the top
+func (ui UI) galleryRowOf(effect string) int {
+	for i, row := range ui.galleryRows() {
+		if row.name == effect {
+			return i
+		}
+	}
+	return 0
+}
+
+// SaveSession remembers the active device, the menu position and the
+// effect showing for the next run
+func (ui UI) SaveSession() error {
+	session := ui.session
+	session.Device = ui.device.GetDeviceIP()
+	if choices := ui.getMenuChoices(); ui.deviceReady && ui.cursor < len(choices) {
+		session.Menu = choices[ui.cursor]
+	}
+	if ui.status != nil && isNamedEffect(ui.status.Effect) {
+		session.Effect = ui.status.Effect
+	}
+	return saveSession(session)
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// statusBarWidth keeps the status bar inside the main box
+const statusBarWidth = 46
+
+// queueOperation runs fn on the operation queue, after every device command
+// started before it. A timeout of 0 leaves the command without a deadline;
+// ctrl+x cancels it either way.
+func (ui UI) queueOperation(label string, timeout time.Duration, fn func(ctx context.Context) tea.Msg) tea.Cmd {
+	op := ui.operations.enqueue(label)
+	return func() tea.Msg {
+		defer ui.operations.done(op)
+		ctx, cancel, err := op.wait(timeout)
+		if err != nil {
+			return actionResultMsg{err: fmt.Errorf("%s cancelled", label)}
+		}
+		defer cancel()
+		msg := fn(ctx)
+		if result, ok := msg.(actionResultMsg); ok && result.err != nil && op.ctx.Err() != nil {
+			result.err = fmt.Errorf("%s cancelled", label)
+			return result
+		}
+		return msg
+	}
+}
+
+func (ui UI) cancelOperation() (tea.Model, tea.Cmd) {
+	label, ok := ui.operations.cancelRunning()
+	if !ok {
+		ui.message = textStyle.Render("Nothing to cancel")
+		return ui, nil
+	}
+	ui.message = textStyle.Render(fmt.Sprintf("Cancelling %s...", label))
+	return ui, nil
+}
+
+// statusBarView shows the running and queued device commands and the last
+// error at the bottom of the screen
+func (ui UI) statusBarView() string {
+	running, queued := ui.operations.snapshot()
+	parts := []string{"Idle"}
+	if running != "" {
+		parts = []string{"⟳ " + running}
+	}
+	if len(queued) > 0 {
+		parts = append(parts, fmt.Sprintf("%d queued", len(queued)))
+	}
+	if running != "" {
+		parts = append(parts, "ctrl+x cancels")
+	}
+	lines := []string{separatorStyle.Render(clip(strings.Join(parts, " · "), statusBarWidth))}
+	if ui.lastError != "" {
+		lines = append(lines, separatorStyle.Render(clip("Last error: "+ui.lastError, statusBarWidth)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// clip shortens s to width runes, ending it with … when cut
+func clip(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// toastLogLines is how many dismissed toasts stay visible in the log
+const toastLogLines = 3
+
+// toastsView stacks the toasts on screen, newest last
+func (ui UI) toastsView() string {
+	texts := make([]string, len(ui.toasts.shown))
+	for i, t := range ui.toasts.shown {
+		texts[i] = t.text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// toastLogView lists the latest dismissed toasts with the time they were
+// shown, newest first
+func (ui UI) toastLogView() string {
+	past := ui.toasts.archived(toastLogLines)
+	if len(past) == 0 {
+		return ""
+	}
+	lines := []string{separatorStyle.Render("Log")}
+	truncate := lipgloss.NewStyle().MaxWidth(statusBarWidth)
+	for _, t := range past {
+		// Only the first line of a multi-line message fits
+		text, _, _ := strings.Cut(t.text, "\n")
+		lines = append(lines, truncate.Render(separatorStyle.Render(t.at.Format("15:04"))+" "+text))
+	}
+	return strings.Join(lines, "\n")
+}
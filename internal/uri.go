@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// uriScheme is the scheme of the links the uri command runs
+const uriScheme = "nanoleaf"
+
+// uriRegistryKey is where the handler for nanoleaf:// links is registered,
+// per user so no administrator rights are needed
+const uriRegistryKey = `HKCU\Software\Classes\` + uriScheme
+
+// uriAction turns a link such as nanoleaf://toggle, nanoleaf://brightness/40
+// or nanoleaf://effect/Northern%20Lights into the action it stands for: an
+// action as in macros, "toggle" or "macro NAME"
+func uriAction(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != uriScheme {
+		return "", fmt.Errorf("expected a %s:// link, got %q", uriScheme, raw)
+	}
+	verb := strings.ToLower(u.Host)
+	arg := strings.Trim(u.Path, "/")
+	switch verb {
+	case "":
+		return "", fmt.Errorf("%q has no action, e.g. %s://toggle", raw, uriScheme)
+	case "toggle":
+		if arg != "" {
+			return "", fmt.Errorf("toggle takes no argument")
+		}
+		return verb, nil
+	case "macro":
+		if arg == "" {
+			return "", fmt.Errorf("macro needs a name")
+		}
+		return verb + " " + arg, nil
+	}
+	line := strings.TrimSpace(verb + " " + arg)
+	if _, err := parseAction(line); err != nil {
+		return "", err
+	}
+	return line, nil
+}
+
+// actionLink is the reverse of uriAction, turning "brightness +10" into
+// nanoleaf://brightness/+10
+func actionLink(line string) (string, error) {
+	verb, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	link := (&url.URL{Scheme: uriScheme, Host: strings.ToLower(verb), Path: "/" + strings.TrimSpace(arg)}).String()
+	link = strings.TrimSuffix(link, "/")
+	if _, err := uriAction(link); err != nil {
+		return "", err
+	}
+	return link, nil
+}
+
+// uriRegistryCommands are the reg commands that make exe the handler for
+// nanoleaf:// links. conhost --headless keeps the console window of exe
+// from flashing up when a shortcut or Stream Deck button opens a link.
+func uriRegistryCommands(exe string) [][]string {
+	command := fmt.Sprintf(`conhost.exe --headless "%s" uri "%%1"`, exe)
+	return [][]string{
+		{"reg", "add", uriRegistryKey, "/ve", "/d", "URL:nanoleaf-go", "/f"},
+		{"reg", "add", uriRegistryKey, "/v", "URL Protocol", "/d", "", "/f"},
+		{"reg", "add", uriRegistryKey + `\shell\open\command`, "/ve", "/d", command, "/f"},
+	}
+}
+
+func runRegistryCommand(args []string) error {
+	if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", strings.Join(args[:2], " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// runLink runs the action of a link on the paired device
+func runLink(ctx context.Context, line string) error {
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	if line == "toggle" {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+		_, err := device.TogglePower(ctx)
+		return err
+	}
+	if name, ok := strings.CutPrefix(line, "macro "); ok {
+		return runMacro(ctx, device, name)
+	}
+	_, err = runStdinCommand(ctx, device, line)
+	return err
+}
+
+func runURI(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("uri register|unregister|nanoleaf://ACTION")
+	}
+
+	switch args[0] {
+	case "register", "unregister":
+		if runtime.GOOS != "windows" {
+			return fmt.Errorf("%s:// links can only be registered on Windows", uriScheme)
+		}
+		if args[0] == "unregister" {
+			if err := runRegistryCommand([]string{"reg", "delete", uriRegistryKey, "/f"}); err != nil {
+				return err
+			}
+			statusf("%s:// links are no longer handled\n", uriScheme)
+			return nil
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		for _, command := range uriRegistryCommands(exe) {
+			if err := runRegistryCommand(command); err != nil {
+				return err
+			}
+		}
+		statusf("%s:// links now run %s, e.g. %s://toggle\n", uriScheme, exe, uriScheme)
+		return nil
+	}
+
+	line, err := uriAction(args[0])
+	if err != nil {
+		return invalidArgs(err)
+	}
+	if err := runLink(ctx, line); err != nil {
+		// Links run without a console, so this is the only way to see it
+		notify("nanoleaf-go", fmt.Sprintf("%s failed: %v", line, err))
+		return err
+	}
+	return nil
+}
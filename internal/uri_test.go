@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestURIAction(t *testing.T) {
+	tests := map[string]string{
+		"nanoleaf://toggle":                   "toggle",
+		"nanoleaf://on":                       "on",
+		"nanoleaf://brightness/40":            "brightness 40",
+		"nanoleaf://effect/Northern%20Lights": "effect Northern Lights",
+		"nanoleaf://preset/2/":                "preset 2",
+		"nanoleaf://macro/movie%20night":      "macro movie night",
+		"NANOLEAF://Color/%23ff8800":          "color #ff8800",
+	}
+	for raw, want := range tests {
+		got, err := uriAction(raw)
+		if err != nil || got != want {
+			t.Errorf("uriAction(%q) = %q, %v; expected %q", raw, got, err, want)
+		}
+	}
+
+	for _, raw := range []string{"https://toggle", "nanoleaf://", "nanoleaf://toggle/now", "nanoleaf://brightness/400", "nanoleaf://dance", "nanoleaf://macro"} {
+		if _, err := uriAction(raw); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestURIRegistryCommands(t *testing.T) {
+	commands := uriRegistryCommands(`C:\Tools\nanoleaf-go.exe`)
+	if len(commands) != 3 {
+		t.Fatalf("expected 3 reg commands, got %d", len(commands))
+	}
+	last := commands[2]
+	if !strings.HasSuffix(last[2], `\shell\open\command`) {
+		t.Errorf("expected the open command key last, got %q", last[2])
+	}
+	want := `conhost.exe --headless "C:\Tools\nanoleaf-go.exe" uri "%1"`
+	if last[5] != want {
+		t.Errorf("expected %q, got %q", want, last[5])
+	}
+}
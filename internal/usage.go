@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxUsageSamples bounds the history kept per device
+const maxUsageSamples = 5000
+
+// defaultPanelWatts is used for models without a known per-panel wattage
+const defaultPanelWatts = 2.0
+
+// usageSample is the power state of a device from Time until the next sample
+type usageSample struct {
+	Time       time.Time `json:"time"`
+	On         bool      `json:"on"`
+	Brightness int       `json:"brightness"`
+}
+
+// usageSummary is the usage of a device over a period
+type usageSummary struct {
+	OnTime time.Duration
+	// FullBrightnessHours is the on-time weighted by brightness, i.e. the
+	// hours the panels would have drawn full power for
+	FullBrightnessHours float64
+}
+
+// usageMu serializes access to the usage file between concurrent UI commands
+var usageMu sync.Mutex
+
+// usageCompactSize is the size from which the usage log is rewritten with
+// only the last maxUsageSamples of each device
+var usageCompactSize int64 = 4 << 20
+
+// usageRecord is a line of the usage log
+type usageRecord struct {
+	IP string `json:"ip"`
+	usageSample
+}
+
+// usageCache is the last sample of each device as this process last wrote
+// the log, valid while the log still has the size it left it at
+var usageCache struct {
+	path string
+	size int64
+	last map[string]usageSample
+}
+
+func getUsagePath() string {
+	return profilePath(".nanoleaf_usage", ".jsonl")
+}
+
+// loadUsage reads the samples of every device, oldest first, keeping the
+// last maxUsageSamples of each
+func loadUsage() (map[string][]usageSample, error) {
+	usage := make(map[string][]usageSample)
+	file, err := os.Open(getUsagePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return usage, nil
+	}
+	if err != nil {
+		return usage, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record usageRecord
+		// Skip lines cut short by a crash rather than failing the whole log
+		if json.Unmarshal(scanner.Bytes(), &record) != nil || record.IP == "" {
+			continue
+		}
+		samples := append(usage[record.IP], record.usageSample)
+		if len(samples) > maxUsageSamples {
+			samples = samples[1:]
+		}
+		usage[record.IP] = samples
+	}
+	return usage, scanner.Err()
+}
+
+// writeUsage replaces the log with the samples of usage
+func writeUsage(usage map[string][]usageSample) error {
+	var data []byte
+	for ip, samples := range usage {
+		for _, sample := range samples {
+			line, err := json.Marshal(usageRecord{IP: ip, usageSample: sample})
+			if err != nil {
+				return err
+			}
+			data = append(append(data, line...), '\n')
+		}
+	}
+	return writeFileAtomic(getUsagePath(), data, 0600)
+}
+
+// lastUsage returns the last sample of every device, from the cache unless
+// another process wrote the log since
+func lastUsage() (map[string]usageSample, error) {
+	path := getUsagePath()
+	size := int64(0)
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	if usageCache.path == path && usageCache.size == size && usageCache.last != nil {
+		return usageCache.last, nil
+	}
+	usage, err := loadUsage()
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > usageCompactSize {
+		if err := writeUsage(usage); err != nil {
+			return nil, err
+		}
+	}
+	last := make(map[string]usageSample)
+	for ip, samples := range usage {
+		last[ip] = samples[len(samples)-1]
+	}
+	usageCache.path, usageCache.last = path, last
+	usageCache.size = 0
+	if info, err := os.Stat(path); err == nil {
+		usageCache.size = info.Size()
+	}
+	return last, nil
+}
+
+// appendUsage records a new sample for ip derived from the previous one by
+// update. Samples that do not change the state are dropped. The sample is
+// appended to the log, which is only read again when another process wrote
+// to it, and rewritten once it grows past usageCompactSize.
+func appendUsage(ip string, at time.Time, update func(*usageSample)) error {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	last, err := lastUsage()
+	if err != nil {
+		return err
+	}
+	previous, seen := last[ip]
+	next := usageSample{Brightness: 100}
+	if seen {
+		next = previous
+	}
+	update(&next)
+	if seen && next.On == previous.On && next.Brightness == previous.Brightness {
+		return nil
+	}
+
+	next.Time = at
+	line, err := json.Marshal(usageRecord{IP: ip, usageSample: next})
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(getUsagePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		// The log may hold part of the line now, so read it again next time
+		usageCache.last = nil
+		return err
+	}
+	last[ip] = next
+	if info, err := file.Stat(); err == nil {
+		usageCache.size = info.Size()
+		if info.Size() > usageCompactSize {
+			usageCache.last = nil
+		}
+	}
+	return nil
+}
+
+// summarizeUsage totals the on-time between since and until, assuming each
+// sample's state lasted until the next one
+func summarizeUsage(samples []usageSample, since, until time.Time) usageSummary {
+	var summary usageSummary
+	for i, sample := range samples {
+		if !sample.On {
+			continue
+		}
+		start, end := sample.Time, until
+		if i+1 < len(samples) {
+			end = samples[i+1].Time
+		}
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(until) {
+			end = until
+		}
+		if !end.After(start) {
+			continue
+		}
+		duration := end.Sub(start)
+		summary.OnTime += duration
+		summary.FullBrightnessHours += duration.Hours() * float64(sample.Brightness) / 100
+	}
+	return summary
+}
+
+// recordUsage appends to the usage history when recording is enabled. Usage
+// is best effort, so failures never affect the device call.
+func (d *Device) recordUsage(update func(*usageSample)) {
+	if !d.record || d.GetConfig().IP == "" {
+		return
+	}
+	appendUsage(d.GetConfig().IP, time.Now(), update)
+}
+
+func runStats(ctx context.Context, args []string) error {
+	fs := newFlagSet("stats")
+	period := fs.Duration("period", 30*24*time.Hour, "how far back to look")
+	price := fs.Float64("price", 0, "electricity price per kWh, to estimate the cost")
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+
+	usage, err := loadUsage()
+	if err != nil {
+		return err
+	}
+	samples := usage[device.GetDeviceIP()]
+	if len(samples) == 0 {
+		return fmt.Errorf("no usage recorded for %s yet", device.GetDeviceIP())
+	}
+
+	caps, err := device.GetCapabilities(ctx)
+	if err != nil {
+		return err
+	}
+	layout, err := device.GetLayout(ctx)
+	if err != nil {
+		return err
+	}
+	panels := len(layout.LightPanels())
+	watts := float64(panels) * caps.PanelWatts
+
+	now := time.Now()
+	since := now.Add(-*period)
+	if samples[0].Time.After(since) {
+		since = samples[0].Time
+	}
+	summary := summarizeUsage(samples, since, now)
+	kwh := summary.FullBrightnessHours * watts / 1000
+
+	fmt.Printf("Device:      %s (%s, %d panels, ~%.1fW at full brightness)\n", device.GetDeviceIP(), caps.Model, panels, watts)
+	fmt.Printf("Since:       %s\n", since.Format("2006-01-02 15:04"))
+	fmt.Printf("On for:      %s\n", summary.OnTime.Round(time.Minute))
+	if summary.OnTime > 0 {
+		fmt.Printf("Brightness:  %.0f%% on average\n", summary.FullBrightnessHours/summary.OnTime.Hours()*100)
+	}
+	fmt.Printf("Energy:      %.2f kWh (estimated)\n", kwh)
+	if *price > 0 {
+		fmt.Printf("Cost:        %.2f\n", kwh**price)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSummarizeUsage(t *testing.T) {
+	start := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	samples := []usageSample{
+		{Time: start, On: true, Brightness: 100},
+		{Time: start.Add(time.Hour), On: true, Brightness: 50},
+		{Time: start.Add(3 * time.Hour), On: false, Brightness: 50},
+		{Time: start.Add(10 * time.Hour), On: true, Brightness: 20},
+	}
+
+	summary := summarizeUsage(samples, start, start.Add(12*time.Hour))
+	if summary.OnTime != 5*time.Hour {
+		t.Errorf("expected 5h on, got %s", summary.OnTime)
+	}
+	// 1h at 100% + 2h at 50% + 2h at 20%
+	if summary.FullBrightnessHours < 2.39 || summary.FullBrightnessHours > 2.41 {
+		t.Errorf("expected 2.4 full brightness hours, got %v", summary.FullBrightnessHours)
+	}
+
+	// Only the part of the first sample after since counts
+	summary = summarizeUsage(samples, start.Add(30*time.Minute), start.Add(time.Hour))
+	if summary.OnTime != 30*time.Minute {
+		t.Errorf("expected 30m on, got %s", summary.OnTime)
+	}
+}
+
+func TestAppendUsage(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	now := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	appendUsage("192.168.1.57", now, func(s *usageSample) { s.On = true })
+	appendUsage("192.168.1.57", now.Add(time.Minute), func(s *usageSample) { s.On = true })
+	appendUsage("192.168.1.57", now.Add(2*time.Minute), func(s *usageSample) { s.Brightness = 40 })
+
+	usage, err := loadUsage()
+	if err != nil {
+		t.Fatalf("loadUsage should not fail: %v", err)
+	}
+	samples := usage["192.168.1.57"]
+	if len(samples) != 2 {
+		t.Fatalf("unchanged states should be dropped, got %+v", samples)
+	}
+	if !samples[1].On || samples[1].Brightness != 40 || !samples[1].Time.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("unexpected sample %+v", samples[1])
+	}
+}
+
+func TestUsageLog(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	now := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+
+	appendUsage("192.168.1.57", now, func(s *usageSample) { s.On = true })
+	appendUsage("192.168.1.57", now.Add(time.Minute), func(s *usageSample) { s.On = false })
+
+	// A sample another process appended is seen before the next is compared
+	file, _ := os.OpenFile(getUsagePath(), os.O_APPEND|os.O_WRONLY, 0600)
+	file.WriteString(`{"ip":"192.168.1.57","time":"2024-05-01T18:30:00Z","on":true,"brightness":60}` + "\n")
+	file.Close()
+	appendUsage("192.168.1.57", now.Add(time.Hour), func(s *usageSample) { s.On = false })
+	usage, _ := loadUsage()
+	if samples := usage["192.168.1.57"]; len(samples) != 4 || samples[3].On {
+		t.Fatalf("expected the change back to off to be recorded, got %+v", samples)
+	}
+
+	// A log past the limit is rewritten with what loadUsage keeps,
+	// dropping lines cut short by a crash
+	original := usageCompactSize
+	usageCompactSize = 400
+	defer func() { usageCompactSize = original }()
+	file, _ = os.OpenFile(getUsagePath(), os.O_APPEND|os.O_WRONLY, 0600)
+	for i := 0; i < 20; i++ {
+		file.WriteString(`{"ip":"192.168.1.57","ti` + "\n")
+	}
+	file.Close()
+	appendUsage("192.168.1.57", now.Add(2*time.Hour), func(s *usageSample) { s.On = true })
+	info, err := os.Stat(getUsagePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > usageCompactSize {
+		t.Errorf("expected the log to be compacted, got %d bytes", info.Size())
+	}
+	usage, _ = loadUsage()
+	if samples := usage["192.168.1.57"]; len(samples) != 5 || !samples[4].On {
+		t.Errorf("expected compacting to keep every sample, got %+v", samples)
+	}
+}
@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runWatchURL(ctx context.Context, args []string) error {
+	fs := newFlagSet("watch-url")
+	url := fs.String("url", "", "JSON endpoint to poll")
+	path := fs.String("jq", ".", "path of the value to extract, e.g. .status or .jobs[0].state")
+	mapping := fs.String("map", "", "value to color mapping, e.g. success=green,failed=red")
+	interval := fs.Duration("interval", 30*time.Second, "poll interval")
+	ignoreQuiet := addQuietHoursFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if err := checkInterval(*interval); err != nil {
+		return err
+	}
+	if *url == "" || *mapping == "" {
+		return invalidArgs(fmt.Errorf("--url and --map are required"))
+	}
+
+	colors, err := parseColorMap(*mapping)
+	if err != nil {
+		return err
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	quiet, err := newQuietGate(device.GetConfig(), *ignoreQuiet)
+	if err != nil {
+		return err
+	}
+
+	statusf("Watching %s every %s (ctrl+c to stop)\n", *url, *interval)
+	statusf("%s\n", quiet)
+	watchManualChanges(ctx, device)
+	return watchURL(ctx, device, &http.Client{Timeout: 10 * time.Second}, quiet, *url, *path, colors, *interval)
+}
+
+func watchURL(ctx context.Context, device *Device, httpClient *http.Client, quiet *quietGate, url, path string, colors map[string]rgbColor, interval time.Duration) error {
+	errs, err := newErrorLog(device.GetConfig(), "Watch")
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		held := quiet.held()
+		err := quiet.settle(ctx, device)
+		var value string
+		if err == nil {
+			value, err = fetchJSONValue(ctx, httpClient, url, path)
+		}
+		if err == nil && value != last && !held {
+			if color, ok := colors[value]; ok {
+				hue, sat, _ := color.hsb()
+				err = device.SetColor(ctx, hue, sat)
+				if err == nil {
+					statusf("%s -> %s\n", value, color)
+				}
+			} else {
+				statusf("%s -> no color mapped, leaving panels unchanged\n", value)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			errs.failed(err)
+		} else {
+			errs.succeeded()
+			if !held {
+				last = value
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseColorMap parses "value=color,value=color" pairs
+func parseColorMap(s string) (map[string]rgbColor, error) {
+	colors := make(map[string]rgbColor)
+	for _, pair := range strings.Split(s, ",") {
+		key, name, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid mapping %q, expected value=color", pair)
+		}
+		color, err := parseColor(name)
+		if err != nil {
+			return nil, err
+		}
+		colors[strings.TrimSpace(key)] = color
+	}
+	return colors, nil
+}
+
+func fetchJSONValue(ctx context.Context, httpClient *http.Client, url, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+
+	var data interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	value, err := extractJSONPath(data, path)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
+
+// extractJSONPath resolves a jq-style path such as .build.jobs[0].status
+func extractJSONPath(data interface{}, path string) (interface{}, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), ".")
+	current := data
+
+	for rest != "" {
+		var key string
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid index in path %q", path)
+			}
+			list, ok := current.([]interface{})
+			if !ok || index < 0 || index >= len(list) {
+				return nil, fmt.Errorf("path %q not found", path)
+			}
+			current = list[index]
+			rest = strings.TrimPrefix(rest[end+1:], ".")
+			continue
+		}
+
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			key, rest = rest, ""
+		} else {
+			key, rest = rest[:end], strings.TrimPrefix(rest[end:], ".")
+		}
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("path %q not found", path)
+		}
+		if current, ok = object[key]; !ok {
+			return nil, fmt.Errorf("path %q not found", path)
+		}
+	}
+	return current, nil
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractJSONPath(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`{"status":"success","jobs":[{"state":"failed"}],"count":3}`), &data)
+
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{".status", "success"},
+		{"status", "success"},
+		{".jobs[0].state", "failed"},
+		{".count", float64(3)},
+	}
+
+	for _, tt := range tests {
+		value, err := extractJSONPath(data, tt.path)
+		if err != nil {
+			t.Errorf("extractJSONPath(%q) should not fail: %v", tt.path, err)
+			continue
+		}
+		if value != tt.expected {
+			t.Errorf("extractJSONPath(%q) = %v, expected %v", tt.path, value, tt.expected)
+		}
+	}
+
+	for _, path := range []string{".missing", ".jobs[5].state", ".status.nested", ".jobs[x]"} {
+		if _, err := extractJSONPath(data, path); err == nil {
+			t.Errorf("extractJSONPath(%q) should fail", path)
+		}
+	}
+}
+
+func TestParseColorMap(t *testing.T) {
+	colors, err := parseColorMap("success=green, failed=#ff0000")
+	if err != nil {
+		t.Fatalf("parseColorMap should not fail: %v", err)
+	}
+	if colors["success"] != (rgbColor{0, 128, 0}) {
+		t.Errorf("unexpected color for success: %v", colors["success"])
+	}
+	if colors["failed"] != (rgbColor{255, 0, 0}) {
+		t.Errorf("unexpected color for failed: %v", colors["failed"])
+	}
+
+	if _, err := parseColorMap("success"); err == nil {
+		t.Error("parseColorMap should fail without a color")
+	}
+	if _, err := parseColorMap("success=notacolor"); err == nil {
+		t.Error("parseColorMap should fail with an unknown color")
+	}
+}
+
+func TestFetchJSONValue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"build":{"status":"failed","passed":false}}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	value, err := fetchJSONValue(ctx, server.Client(), server.URL, ".build.status")
+	if err != nil {
+		t.Fatalf("fetchJSONValue should not fail: %v", err)
+	}
+	if value != "failed" {
+		t.Errorf("expected failed, got %s", value)
+	}
+
+	value, err = fetchJSONValue(ctx, server.Client(), server.URL, ".build.passed")
+	if err != nil {
+		t.Fatalf("fetchJSONValue should not fail: %v", err)
+	}
+	if value != "false" {
+		t.Errorf("expected false, got %s", value)
+	}
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const openMeteoURL = "https://api.open-meteo.com/v1/forecast"
+
+type weatherCondition string
+
+const (
+	weatherClear      weatherCondition = "clear"
+	weatherClearNight weatherCondition = "clear-night"
+	weatherCloudy     weatherCondition = "cloudy"
+	weatherFog        weatherCondition = "fog"
+	weatherRain       weatherCondition = "rain"
+	weatherSnow       weatherCondition = "snow"
+	weatherStorm      weatherCondition = "storm"
+)
+
+// weatherEffects maps each condition to the effect shown for it
+var weatherEffects = map[weatherCondition]map[string]interface{}{
+	weatherClear: fadeEffect([]paletteColor{
+		{Hue: 30, Saturation: 90, Brightness: 100},
+		{Hue: 40, Saturation: 80, Brightness: 90},
+	}, 50, 50),
+	weatherClearNight: fadeEffect([]paletteColor{
+		{Hue: 230, Saturation: 80, Brightness: 40},
+		{Hue: 260, Saturation: 70, Brightness: 30},
+	}, 60, 60),
+	weatherCloudy: fadeEffect([]paletteColor{
+		{Hue: 210, Saturation: 10, Brightness: 80},
+		{Hue: 200, Saturation: 20, Brightness: 60},
+	}, 60, 40),
+	weatherFog: fadeEffect([]paletteColor{
+		{Hue: 190, Saturation: 5, Brightness: 60},
+		{Hue: 190, Saturation: 10, Brightness: 40},
+	}, 80, 40),
+	weatherRain: fadeEffect([]paletteColor{
+		{Hue: 220, Saturation: 100, Brightness: 100},
+		{Hue: 220, Saturation: 100, Brightness: 30},
+	}, 15, 5),
+	weatherSnow: fadeEffect([]paletteColor{
+		{Hue: 0, Saturation: 0, Brightness: 100},
+		{Hue: 195, Saturation: 30, Brightness: 90},
+	}, 40, 30),
+	weatherStorm: fadeEffect([]paletteColor{
+		{Hue: 270, Saturation: 90, Brightness: 60},
+		{Hue: 240, Saturation: 100, Brightness: 20},
+		{Hue: 0, Saturation: 0, Brightness: 100},
+	}, 5, 10),
+}
+
+// conditionForCode groups WMO weather interpretation codes into conditions
+func conditionForCode(code int, isDay bool) weatherCondition {
+	switch {
+	case code <= 1:
+		if isDay {
+			return weatherClear
+		}
+		return weatherClearNight
+	case code <= 3:
+		return weatherCloudy
+	case code == 45 || code == 48:
+		return weatherFog
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return weatherSnow
+	case code >= 95:
+		return weatherStorm
+	default:
+		return weatherRain
+	}
+}
+
+func fetchWeatherCondition(ctx context.Context, httpClient *http.Client, provider string, lat, lon float64) (weatherCondition, error) {
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%.4f", lat))
+	query.Set("longitude", fmt.Sprintf("%.4f", lon))
+	query.Set("current", "weather_code,is_day")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", provider+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("weather request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("weather request failed with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Current struct {
+			WeatherCode int `json:"weather_code"`
+			IsDay       int `json:"is_day"`
+		} `json:"current"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse weather response: %w", err)
+	}
+
+	return conditionForCode(result.Current.WeatherCode, result.Current.IsDay == 1), nil
+}
+
+func runWeather(ctx context.Context, args []string) error {
+	fs := newFlagSet("weather")
+	lat := fs.Float64("lat", 0, "latitude")
+	lon := fs.Float64("lon", 0, "longitude")
+	provider := fs.String("provider", openMeteoURL, "Open-Meteo compatible forecast endpoint")
+	interval := fs.Duration("interval", time.Hour, "refresh interval")
+	ignoreQuiet := addQuietHoursFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return invalidArgs(err)
+	}
+	if err := checkInterval(*interval); err != nil {
+		return err
+	}
+	if *lat == 0 && *lon == 0 {
+		return invalidArgs(fmt.Errorf("--lat and --lon are required"))
+	}
+
+	device, err := loadPairedDevice()
+	if err != nil {
+		return err
+	}
+	quiet, err := newQuietGate(device.GetConfig(), *ignoreQuiet)
+	if err != nil {
+		return err
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	errs, err := newErrorLog(device.GetConfig(), "Weather update")
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	statusf("Following the weather at %.4f,%.4f every %s (ctrl+c to stop)\n", *lat, *lon, *interval)
+	statusf("%s\n", quiet)
+	watchManualChanges(ctx, device)
+	var last weatherCondition
+	for {
+		// While held, last stays put so the change is made once the quiet hours end
+		held := quiet.held()
+		err := quiet.settle(ctx, device)
+		var condition weatherCondition
+		if err == nil {
+			condition, err = fetchWeatherCondition(ctx, httpClient, *provider, *lat, *lon)
+		}
+		changed := err == nil && condition != last && !held
+		if changed {
+			err = device.DisplayEffect(ctx, weatherEffects[condition])
+			if err == nil {
+				statusf("Weather is %s\n", condition)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			errs.failed(err)
+		} else {
+			errs.succeeded()
+			if !held {
+				last = condition
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
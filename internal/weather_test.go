@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConditionForCode(t *testing.T) {
+	tests := []struct {
+		code     int
+		isDay    bool
+		expected weatherCondition
+	}{
+		{0, true, weatherClear},
+		{1, false, weatherClearNight},
+		{3, true, weatherCloudy},
+		{45, true, weatherFog},
+		{61, true, weatherRain},
+		{81, true, weatherRain},
+		{73, true, weatherSnow},
+		{86, true, weatherSnow},
+		{95, true, weatherStorm},
+	}
+
+	for _, tt := range tests {
+		if got := conditionForCode(tt.code, tt.isDay); got != tt.expected {
+			t.Errorf("conditionForCode(%d, %v) = %s, expected %s", tt.code, tt.isDay, got, tt.expected)
+		}
+	}
+}
+
+func TestWeatherEffectsCoverAllConditions(t *testing.T) {
+	for _, condition := range []weatherCondition{weatherClear, weatherClearNight, weatherCloudy, weatherFog, weatherRain, weatherSnow, weatherStorm} {
+		if _, ok := weatherEffects[condition]; !ok {
+			t.Errorf("no effect defined for %s", condition)
+		}
+	}
+}
+
+func TestFetchWeatherCondition(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("latitude") != "52.5200" {
+			t.Errorf("expected latitude 52.5200, got %s", r.URL.Query().Get("latitude"))
+		}
+		if r.URL.Query().Get("current") != "weather_code,is_day" {
+			t.Errorf("unexpected current fields %s", r.URL.Query().Get("current"))
+		}
+		w.Write([]byte(`{"current":{"weather_code":63,"is_day":1}}`))
+	}))
+	defer server.Close()
+
+	condition, err := fetchWeatherCondition(context.Background(), server.Client(), server.URL, 52.52, 13.41)
+	if err != nil {
+		t.Fatalf("fetchWeatherCondition should not fail: %v", err)
+	}
+	if condition != weatherRain {
+		t.Errorf("expected rain, got %s", condition)
+	}
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errNoWifiInfo is returned by firmware that does not report its Wi-Fi link
+var errNoWifiInfo = errors.New("the firmware does not report Wi-Fi details")
+
+// wifiInfo is the Wi-Fi link of a device as reported by newer firmware
+type wifiInfo struct {
+	SSID string
+	// RSSI is the signal strength in dBm
+	RSSI    int
+	Channel int
+	// experimental is set when the details came from the wifi endpoint
+	experimental bool
+}
+
+// parseWifiInfo reads the Wi-Fi details from an info or wifi response. They
+// are either in a "wifi" object or at the top level depending on firmware.
+func parseWifiInfo(info map[string]interface{}) (wifiInfo, bool) {
+	fields := info
+	if wifi, ok := info["wifi"].(map[string]interface{}); ok {
+		fields = wifi
+	}
+
+	rssi, ok := fields["rssi"].(float64)
+	if !ok {
+		if rssi, ok = fields["wifiRssi"].(float64); !ok {
+			return wifiInfo{}, false
+		}
+	}
+	wifi := wifiInfo{RSSI: int(rssi)}
+	if channel, ok := fields["channel"].(float64); ok {
+		wifi.Channel = int(channel)
+	}
+	wifi.SSID, _ = fields["ssid"].(string)
+	return wifi, true
+}
+
+// quality rates the signal the way it affects the panels: streaming and
+// quick brightness changes start to lag once it is fair or weak
+func (w wifiInfo) quality() string {
+	switch {
+	case w.RSSI >= -55:
+		return "excellent"
+	case w.RSSI >= -67:
+		return "good"
+	case w.RSSI >= -75:
+		return "fair"
+	}
+	return "weak"
+}
+
+// band returns the frequency band of the channel, empty if unknown
+func (w wifiInfo) band() string {
+	switch {
+	case w.Channel >= 1 && w.Channel <= 14:
+		return "2.4 GHz"
+	case w.Channel >= 32:
+		return "5 GHz"
+	}
+	return ""
+}
+
+func (w wifiInfo) String() string {
+	s := fmt.Sprintf("%d dBm (%s)", w.RSSI, w.quality())
+	if w.Channel > 0 {
+		s += fmt.Sprintf(", channel %d", w.Channel)
+		if band := w.band(); band != "" {
+			s += " (" + band + ")"
+		}
+	}
+	if w.SSID != "" {
+		s += " on " + w.SSID
+	}
+	if w.experimental {
+		s += ", experimental"
+	}
+	return s
+}
+
+// GetWifi returns the Wi-Fi link of the device from the info response, or
+// else from a wifi endpoint. That endpoint is not in Nanoleaf's published
+// API; some firmware has been seen to serve it, so what it reports is
+// marked experimental, and firmware without it, which answers 404, has no
+// Wi-Fi details rather than an error.
+func (d *Device) GetWifi(ctx context.Context, info map[string]interface{}) (wifiInfo, error) {
+	if wifi, ok := parseWifiInfo(info); ok {
+		return wifi, nil
+	}
+
+	var details map[string]interface{}
+	err := d.client.getJSON(withAuthToken(ctx, d.GetConfig().Token), d.client.buildURL(d.GetConfig().IP, "api/v1/wifi"), &details)
+	if errors.Is(err, errNotFound) {
+		return wifiInfo{}, errNoWifiInfo
+	}
+	if err != nil {
+		return wifiInfo{}, err
+	}
+	wifi, ok := parseWifiInfo(details)
+	if !ok {
+		return wifiInfo{}, errNoWifiInfo
+	}
+	wifi.experimental = true
+	return wifi, nil
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseWifiInfo(t *testing.T) {
+	wifi, ok := parseWifiInfo(map[string]interface{}{
+		"name": "Shapes 4C1A",
+		"wifi": map[string]interface{}{"rssi": -61.0, "channel": 6.0, "ssid": "home"},
+	})
+	if !ok || wifi != (wifiInfo{SSID: "home", RSSI: -61, Channel: 6}) {
+		t.Fatalf("unexpected wifi info %+v", wifi)
+	}
+	if got := wifi.String(); got != "-61 dBm (good), channel 6 (2.4 GHz) on home" {
+		t.Errorf("unexpected description %q", got)
+	}
+
+	wifi, ok = parseWifiInfo(map[string]interface{}{"wifiRssi": -80.0, "channel": 149.0})
+	if !ok || wifi.quality() != "weak" || wifi.band() != "5 GHz" {
+		t.Errorf("unexpected top level wifi info %+v", wifi)
+	}
+
+	if _, ok := parseWifiInfo(map[string]interface{}{"name": "Canvas"}); ok {
+		t.Error("expected no wifi info without an RSSI")
+	}
+}
+
+func TestGetWifiFallsBackToEndpoint(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/test-token/wifi" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"rssi":-70,"channel":36}`))
+	}))
+	defer server.Close()
+
+	device := NewDevice()
+	device.config.IP = server.URL
+	device.config.Token = "test-token"
+	ctx := context.Background()
+
+	wifi, err := device.GetWifi(ctx, map[string]interface{}{"name": "Lines"})
+	if err != nil || wifi.RSSI != -70 || wifi.quality() != "fair" {
+		t.Errorf("expected the wifi endpoint to be used, got %+v, %v", wifi, err)
+	}
+	if got := wifi.String(); got != "-70 dBm (fair), channel 36 (5 GHz), experimental" {
+		t.Errorf("expected the endpoint's reading marked experimental, got %q", got)
+	}
+
+	status = http.StatusNotFound
+	if _, err := device.GetWifi(ctx, map[string]interface{}{}); !errors.Is(err, errNoWifiInfo) {
+		t.Errorf("expected errNoWifiInfo for firmware without the endpoint, got %v", err)
+	}
+	status = http.StatusInternalServerError
+	if _, err := device.GetWifi(ctx, map[string]interface{}{}); err == nil || errors.Is(err, errNoWifiInfo) {
+		t.Errorf("expected a failing endpoint to report its error, got %v", err)
+	}
+}